@@ -6,11 +6,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
 	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy"
+	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
 	"go.keploy.io/server/pkg/service/record"
 	"go.keploy.io/server/utils"
 	"go.uber.org/zap"
@@ -42,7 +45,7 @@ func readRecordConfig(configPath string) (*models.Record, error) {
 
 var filters = models.TestFilter{}
 
-func (t *Record) GetRecordConfig(path *string, proxyPort *uint32, appCmd *string, appContainer, networkName *string, Delay *uint64, buildDelay *time.Duration, passThroughPorts *[]uint, passThrough *[]models.Filters, configPath string, recordTimer *time.Duration) error {
+func (t *Record) GetRecordConfig(path *string, proxyPort *uint32, appCmd *string, appContainer, networkName *string, Delay *uint64, buildDelay *time.Duration, passThroughPorts *[]uint, passThrough *[]models.Filters, configPath string, recordTimer *time.Duration, mockFormat, mockLayout *string, proxyOptions *proxy.Option) error {
 	configFilePath := filepath.Join(configPath, "keploy-config.yaml")
 	if isExist := utils.CheckFileExists(configFilePath); !isExist {
 		return errFileNotFound
@@ -78,6 +81,54 @@ func (t *Record) GetRecordConfig(path *string, proxyPort *uint32, appCmd *string
 	if *buildDelay == 30*time.Second && confRecord.BuildDelay != 0 {
 		*buildDelay = confRecord.BuildDelay
 	}
+	if *mockFormat == "" {
+		*mockFormat = confRecord.MockFormat
+	}
+	if *mockLayout == "" {
+		*mockLayout = confRecord.MockLayout
+	}
+	if proxyOptions.PostgresNoticePolicy == "" {
+		proxyOptions.PostgresNoticePolicy = confRecord.PostgresNoticePolicy
+	}
+	if proxyOptions.PostgresMaxSessionDuration == 0 {
+		proxyOptions.PostgresMaxSessionDuration = confRecord.PostgresMaxSessionDuration
+	}
+	proxyOptions.PostgresContinueSession = proxyOptions.PostgresContinueSession || confRecord.PostgresContinueSession
+	if len(proxyOptions.DisabledIntegrations) == 0 {
+		proxyOptions.DisabledIntegrations = confRecord.DisabledIntegrations
+	}
+	if len(proxyOptions.PostgresStalenessTolerance) == 0 {
+		proxyOptions.PostgresStalenessTolerance = confRecord.PostgresStalenessTolerance
+	}
+	if proxyOptions.PostgresMaxDataRows == 0 {
+		proxyOptions.PostgresMaxDataRows = confRecord.PostgresMaxDataRows
+	}
+	proxyOptions.PostgresPassthroughSSL = proxyOptions.PostgresPassthroughSSL || confRecord.PostgresPassthroughSSL
+	if proxyOptions.Http3Port == 0 {
+		proxyOptions.Http3Port = confRecord.Http3Port
+	}
+	proxyOptions.PassThroughRawTCP = proxyOptions.PassThroughRawTCP || confRecord.PassThroughRawTCP
+	if proxyOptions.PostgresMaxPendingBytes == 0 {
+		proxyOptions.PostgresMaxPendingBytes = confRecord.PostgresMaxPendingBytes
+	}
+	if proxyOptions.PostgresRecordingWarmup == (postgresparser.RecordingWarmup{}) {
+		proxyOptions.PostgresRecordingWarmup = postgresparser.RecordingWarmup{
+			Duration: confRecord.PostgresRecordingWarmup.Duration,
+			Requests: confRecord.PostgresRecordingWarmup.Requests,
+		}
+	}
+	proxyOptions.PostgresIgnoredFields = postgresparser.IgnoredFields{
+		Columns:       confRecord.PostgresIgnoredFields.Columns,
+		ColumnIndexes: confRecord.PostgresIgnoredFields.ColumnIndexes,
+		Parameters:    confRecord.PostgresIgnoredFields.Parameters,
+		MessageFields: confRecord.PostgresIgnoredFields.MessageFields,
+	}
+	if proxyOptions.PostgresDriftTestSet == "" {
+		proxyOptions.PostgresDriftTestSet = confRecord.PostgresDriftTestSet
+	}
+	if len(proxyOptions.PostgresStripSchemas) == 0 {
+		proxyOptions.PostgresStripSchemas = confRecord.PostgresStripSchemas
+	}
 	passThroughPortProvided := len(*passThroughPorts) == 0
 
 	for _, filter := range confRecord.Stubs.Filters {
@@ -170,9 +221,145 @@ func (r *Record) GetCmd() *cobra.Command {
 				r.logger.Error("Failed to read the timer value")
 			}
 
+			introspectionOnly, err := cmd.Flags().GetBool("introspectionOnly")
+			if err != nil {
+				r.logger.Error("failed to read the introspectionOnly flag")
+				return err
+			}
+
+			rawPayloads, err := cmd.Flags().GetBool("postgresRawPayloads")
+			if err != nil {
+				r.logger.Error("failed to read the postgresRawPayloads flag")
+				return err
+			}
+
+			dedupMocks, err := cmd.Flags().GetBool("postgresDedupMocks")
+			if err != nil {
+				r.logger.Error("failed to read the postgresDedupMocks flag")
+				return err
+			}
+
+			mockFormat, err := cmd.Flags().GetString("mockFormat")
+			if err != nil {
+				r.logger.Error("failed to read the mockFormat flag")
+				return err
+			}
+
+			mockLayout, err := cmd.Flags().GetString("mockLayout")
+			if err != nil {
+				r.logger.Error("failed to read the mockLayout flag")
+				return err
+			}
+
+			noticePolicy, err := cmd.Flags().GetString("postgresNoticePolicy")
+			if err != nil {
+				r.logger.Error("failed to read the postgresNoticePolicy flag")
+				return err
+			}
+
+			maxSessionDuration, err := cmd.Flags().GetDuration("postgresMaxSessionDuration")
+			if err != nil {
+				r.logger.Error("failed to read the postgresMaxSessionDuration flag")
+				return err
+			}
+
+			continueSession, err := cmd.Flags().GetBool("postgresContinueSession")
+			if err != nil {
+				r.logger.Error("failed to read the postgresContinueSession flag")
+				return err
+			}
+
+			disabledIntegrations, err := cmd.Flags().GetStringSlice("disabledIntegrations")
+			if err != nil {
+				r.logger.Error("failed to read the disabledIntegrations flag")
+				return err
+			}
+
+			stalenessTolerance, err := cmd.Flags().GetStringToString("postgresStalenessTolerance")
+			if err != nil {
+				r.logger.Error("failed to read the postgresStalenessTolerance flag")
+				return err
+			}
+			tolerances := make(map[string]float64, len(stalenessTolerance))
+			for column, value := range stalenessTolerance {
+				delta, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					r.logger.Error("invalid postgres staleness tolerance, expected column=delta", zap.String("column", column), zap.String("delta", value))
+					return err
+				}
+				tolerances[column] = delta
+			}
+
+			maxDataRows, err := cmd.Flags().GetInt("postgresMaxDataRows")
+			if err != nil {
+				r.logger.Error("failed to read the postgresMaxDataRows flag")
+				return err
+			}
+
+			passthroughSSL, err := cmd.Flags().GetBool("postgresPassthroughSSL")
+			if err != nil {
+				r.logger.Error("failed to read the postgresPassthroughSSL flag")
+				return err
+			}
+
+			passThroughRawTCP, err := cmd.Flags().GetBool("passThroughRawTCP")
+			if err != nil {
+				r.logger.Error("failed to read the passThroughRawTCP flag")
+				return err
+			}
+
+			http3Port, err := cmd.Flags().GetUint32("http3Port")
+			if err != nil {
+				r.logger.Error("failed to read the http3Port flag")
+				return err
+			}
+
+			maxPendingBytes, err := cmd.Flags().GetInt("postgresMaxPendingBytes")
+			if err != nil {
+				r.logger.Error("failed to read the postgresMaxPendingBytes flag")
+				return err
+			}
+
+			warmupDuration, err := cmd.Flags().GetDuration("postgresWarmupDuration")
+			if err != nil {
+				r.logger.Error("failed to read the postgresWarmupDuration flag")
+				return err
+			}
+
+			warmupRequests, err := cmd.Flags().GetInt("postgresWarmupRequests")
+			if err != nil {
+				r.logger.Error("failed to read the postgresWarmupRequests flag")
+				return err
+			}
+
+			driftTestSet, err := cmd.Flags().GetString("postgresDriftTestSet")
+			if err != nil {
+				r.logger.Error("failed to read the postgresDriftTestSet flag")
+				return err
+			}
+
+			proxyOptions := proxy.Option{
+				PostgresNoticePolicy:       noticePolicy,
+				PostgresMaxSessionDuration: maxSessionDuration,
+				PostgresContinueSession:    continueSession,
+				PostgresRecordRawPayloads:  rawPayloads,
+				PostgresDeduplicateMocks:   dedupMocks,
+				DisabledIntegrations:       disabledIntegrations,
+				PostgresStalenessTolerance: tolerances,
+				PostgresMaxDataRows:        maxDataRows,
+				PostgresPassthroughSSL:     passthroughSSL,
+				PassThroughRawTCP:          passThroughRawTCP,
+				Http3Port:                  http3Port,
+				PostgresMaxPendingBytes:    maxPendingBytes,
+				PostgresRecordingWarmup: postgresparser.RecordingWarmup{
+					Duration: warmupDuration,
+					Requests: warmupRequests,
+				},
+				PostgresDriftTestSet: driftTestSet,
+			}
 			passThrough := []models.Filters{}
 
-			err = r.GetRecordConfig(&path, &proxyPort, &appCmd, &appContainer, &networkName, &delay, &buildDelay, &ports, &passThrough, configPath, &recordTimer)
+			err = r.GetRecordConfig(&path, &proxyPort, &appCmd, &appContainer, &networkName, &delay, &buildDelay, &ports, &passThrough, configPath, &recordTimer, &mockFormat, &mockLayout, &proxyOptions)
 			if err != nil {
 				if err == errFileNotFound {
 					r.logger.Info("Keploy config not found, continuing without configuration")
@@ -191,6 +378,13 @@ func (r *Record) GetCmd() *cobra.Command {
 				return errors.New("missing required -c flag or appCmd in config file")
 			}
 
+			switch postgresparser.NoticePolicy(proxyOptions.PostgresNoticePolicy) {
+			case "", postgresparser.NoticeKeep, postgresparser.NoticeDrop:
+			default:
+				r.logger.Error("unknown postgres notice policy, expected keep or drop", zap.Any("policy", proxyOptions.PostgresNoticePolicy))
+				return errors.New("invalid postgres notice policy")
+			}
+
 			if isDockerCmd && len(path) > 0 {
 				curDir, err := os.Getwd()
 				if err != nil {
@@ -299,7 +493,7 @@ func (r *Record) GetCmd() *cobra.Command {
 				}
 			}
 			r.logger.Debug("the ports are", zap.Any("ports", ports))
-			r.recorder.StartCaptureTraffic(path, proxyPort, appCmd, appContainer, networkName, delay, buildDelay, ports, &filters, enableTele, passThrough, recordTimer)
+			r.recorder.StartCaptureTraffic(path, proxyPort, appCmd, appContainer, networkName, delay, buildDelay, ports, &filters, enableTele, passThrough, recordTimer, introspectionOnly, mockFormat, mockLayout, proxyOptions)
 			return nil
 		},
 	}
@@ -310,9 +504,28 @@ func (r *Record) GetCmd() *cobra.Command {
 
 	recordCmd.Flags().Duration("recordTimer", 0, "Timer to stop keploy recorder after a specified time")
 
+	recordCmd.Flags().String("mockFormat", "", "Format of the recorded mock files: yaml or json")
+	recordCmd.Flags().String("mockLayout", "", "Layout of the recorded mocks: flat (default) or content-addressed to store each mock once for all the test-sets")
+	recordCmd.Flags().Bool("introspectionOnly", false, "Record only the schema introspection queries of the postgres sessions into the "+models.IntrospectionTestSet+" fixture")
+	recordCmd.Flags().Bool("postgresRawPayloads", false, "Keep the raw payload of every recorded postgres message along with its decoded form, to debug the decoder")
+	recordCmd.Flags().String("postgresNoticePolicy", "", "Policy for the NoticeResponse messages of the postgres server: keep (default) or drop to leave them out of the mocks")
+	recordCmd.Flags().Duration("postgresMaxSessionDuration", 0, "Flush the recorded postgres sessions as mocks at this interval, leaving the rest of the session unrecorded unless --postgresContinueSession is set")
+	recordCmd.Flags().Bool("postgresContinueSession", false, "Keep recording the postgres sessions after --postgresMaxSessionDuration")
+	recordCmd.Flags().Bool("postgresDedupMocks", false, "Record the postgres exchanges repeated with the same requests and responses, such as the handshake of every connection, as a single mock")
+	recordCmd.Flags().StringSlice("disabledIntegrations", []string{}, "Integrations left out of the detection of the outgoing calls, among mysql, postgres, mongo, redis, http and grpc")
+	recordCmd.Flags().StringToString("postgresStalenessTolerance", map[string]string{}, "Largest difference accepted between the recorded and live values of the postgres columns read from a replica e.g. --postgresStalenessTolerance balance=0.5")
+	recordCmd.Flags().Int("postgresMaxDataRows", 0, "Largest number of rows of a postgres response recorded in a readable form, the larger responses are stored as their raw payload. 0 records every row")
+	recordCmd.Flags().Bool("postgresPassthroughSSL", false, "Forward the SSL sessions of the postgres clients to the server instead of decrypting them with the CA of keploy")
+	recordCmd.Flags().Bool("passThroughRawTCP", false, "Pass the connections of the protocols keploy does not support through unrecorded. They are recorded as generic mocks, framed by the reads of the proxy, otherwise")
+	recordCmd.Flags().Int("postgresMaxPendingBytes", 0, "Largest size in bytes of the incomplete postgres messages of a connection, such as a COPY FROM STDIN stream, kept in memory. 0 keeps the default of 64MB")
+	recordCmd.Flags().Duration("postgresWarmupDuration", 0, "Duration after its startup during which the postgres exchanges of a connection are left unrecorded, except the ones setting the state of the session")
+	recordCmd.Flags().Int("postgresWarmupRequests", 0, "Number of postgres exchanges of a connection left unrecorded after its startup, except the ones setting the state of the session")
+	recordCmd.Flags().String("postgresDriftTestSet", "", "Recorded test-set whose postgres mocks the live exchanges are compared with, to report the drift of the database since its recording")
+
 	recordCmd.Flags().String("containerName", "", "Name of the application's docker container")
 
 	recordCmd.Flags().Uint32("proxyport", 0, "Choose a port to run Keploy Proxy.")
+	recordCmd.Flags().Uint32("http3Port", 0, "UDP port on which the proxy serves the HTTP/3 calls over QUIC, such as 443. The HTTP/3 calls are not intercepted when 0")
 
 	recordCmd.Flags().StringP("networkName", "n", "", "Name of the application's docker network")
 
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy"
+	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
+	"go.uber.org/zap"
+)
+
+// recordConfig merges the record config into the proxy options of the flags.
+func recordConfig(t *testing.T, config string, proxyOptions *proxy.Option) {
+	t.Helper()
+	var (
+		path, appCmd, appContainer, networkName, mockFormat, mockLayout string
+		proxyPort                                                       uint32
+		delay                                                           uint64
+		buildDelay, recordTimer                                         time.Duration
+		ports                                                           []uint
+		passThrough                                                     []models.Filters
+	)
+	err := NewCmdRecord(zap.NewNop()).GetRecordConfig(&path, &proxyPort, &appCmd, &appContainer, &networkName, &delay, &buildDelay, &ports, &passThrough, writeConfig(t, config), &recordTimer, &mockFormat, &mockLayout, proxyOptions)
+	if err != nil {
+		t.Fatalf("failed to read the config: %v", err)
+	}
+}
+
+func TestRecordMaxPendingBytes(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		flag   int
+		config string
+		want   int
+	}{
+		{name: "default", config: "record:\n", want: 0},
+		{name: "config", config: "record:\n  postgresMaxPendingBytes: 1024\n", want: 1024},
+		{name: "flag over the config", flag: 2048, config: "record:\n  postgresMaxPendingBytes: 1024\n", want: 2048},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyOptions := proxy.Option{PostgresMaxPendingBytes: tt.flag}
+			recordConfig(t, tt.config, &proxyOptions)
+			if proxyOptions.PostgresMaxPendingBytes != tt.want {
+				t.Errorf("got the limit %d, want %d", proxyOptions.PostgresMaxPendingBytes, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordWarmup(t *testing.T) {
+	const config = "record:\n  postgresRecordingWarmup:\n    duration: 30s\n    requests: 5\n"
+	for _, tt := range []struct {
+		name   string
+		flags  postgresparser.RecordingWarmup
+		config string
+		want   postgresparser.RecordingWarmup
+	}{
+		{name: "default", config: "record:\n"},
+		{name: "config", config: config, want: postgresparser.RecordingWarmup{Duration: 30 * time.Second, Requests: 5}},
+		{name: "flags over the config", flags: postgresparser.RecordingWarmup{Requests: 2}, config: config, want: postgresparser.RecordingWarmup{Requests: 2}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyOptions := proxy.Option{PostgresRecordingWarmup: tt.flags}
+			recordConfig(t, tt.config, &proxyOptions)
+			if proxyOptions.PostgresRecordingWarmup != tt.want {
+				t.Errorf("got the warmup %+v, want %+v", proxyOptions.PostgresRecordingWarmup, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecordHttp3Port(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		flag   uint32
+		config string
+		want   uint32
+	}{
+		{name: "off by default", config: "record:\n", want: 0},
+		{name: "config", config: "record:\n  http3Port: 8443\n", want: 8443},
+		{name: "flag over the config", flag: 443, config: "record:\n  http3Port: 8443\n", want: 443},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyOptions := proxy.Option{Http3Port: tt.flag}
+			recordConfig(t, tt.config, &proxyOptions)
+			if proxyOptions.Http3Port != tt.want {
+				t.Errorf("got the http3 port %d, want %d", proxyOptions.Http3Port, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +14,8 @@ import (
 	"go.keploy.io/server/pkg"
 	"go.keploy.io/server/pkg/graph"
 	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy"
+	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
 	"go.keploy.io/server/pkg/service/test"
 	"go.keploy.io/server/utils"
 	"go.uber.org/zap"
@@ -42,7 +45,7 @@ func ReadTestConfig(configPath string) (*models.Test, error) {
 	return &doc.Test, nil
 }
 
-func (t *Test) getTestConfig(path *string, proxyPort *uint32, appCmd *string, testFilters *map[string][]string, appContainer, networkName *string, Delay *uint64, buildDelay *time.Duration, passThroughPorts *[]uint, apiTimeout *uint64, globalNoise *models.GlobalNoise, testSetNoise *models.TestsetNoise, coverageReportPath *string, withCoverage *bool, generateTestReport *bool, configPath string, ignoreOrdering *bool, passThroughHosts *[]models.Filters) error {
+func (t *Test) getTestConfig(path *string, proxyPort *uint32, appCmd *string, testFilters *map[string][]string, appContainer, networkName *string, Delay *uint64, buildDelay *time.Duration, passThroughPorts *[]uint, apiTimeout *uint64, globalNoise *models.GlobalNoise, testSetNoise *models.TestsetNoise, coverageReportPath *string, withCoverage *bool, generateTestReport *bool, configPath string, ignoreOrdering *bool, passThroughHosts *[]models.Filters, divergenceThreshold *float64, postgresReadTimeout *time.Duration, reportFormat *string, proxyOptions *proxy.Option) error {
 	configFilePath := filepath.Join(configPath, "keploy-config.yaml")
 	if isExist := utils.CheckFileExists(configFilePath); !isExist {
 		return errFileNotFound
@@ -91,6 +94,70 @@ func (t *Test) getTestConfig(path *string, proxyPort *uint32, appCmd *string, te
 	if !*ignoreOrdering {
 		*ignoreOrdering = confTest.IgnoreOrdering
 	}
+	if *divergenceThreshold == 0 {
+		*divergenceThreshold = confTest.DivergenceThreshold
+	}
+	if *postgresReadTimeout == 0 {
+		*postgresReadTimeout = confTest.PostgresReadTimeout
+	}
+	if *reportFormat == "" {
+		*reportFormat = string(confTest.ReportFormat)
+	}
+	if proxyOptions.PostgresNoticePolicy == "" {
+		proxyOptions.PostgresNoticePolicy = confTest.PostgresNoticePolicy
+	}
+	if proxyOptions.PostgresMinSimilarity == 0 {
+		proxyOptions.PostgresMinSimilarity = confTest.PostgresMinSimilarity
+	}
+	if len(proxyOptions.DisabledIntegrations) == 0 {
+		proxyOptions.DisabledIntegrations = confTest.DisabledIntegrations
+	}
+	if proxyOptions.MySQLGreetingCapabilities == 0 {
+		proxyOptions.MySQLGreetingCapabilities = confTest.MySQLGreetingCapabilities
+	}
+	proxyOptions.MySQLOKNoise = confTest.MySQLOKNoise
+	proxyOptions.PostgresPaceCopyOut = proxyOptions.PostgresPaceCopyOut || confTest.PostgresPaceCopyOut
+	proxyOptions.PostgresPassthroughSSL = proxyOptions.PostgresPassthroughSSL || confTest.PostgresPassthroughSSL
+	if proxyOptions.Http3Port == 0 {
+		proxyOptions.Http3Port = confTest.Http3Port
+	}
+	if proxyOptions.PostgresReplayJitter.Distribution == "" {
+		proxyOptions.PostgresReplayJitter = postgresparser.ReplayJitter{
+			Distribution: postgresparser.JitterDistribution(confTest.PostgresReplayJitter.Distribution),
+			Min:          confTest.PostgresReplayJitter.Min,
+			Max:          confTest.PostgresReplayJitter.Max,
+			Mean:         confTest.PostgresReplayJitter.Mean,
+			StdDev:       confTest.PostgresReplayJitter.StdDev,
+		}
+	}
+	if proxyOptions.PostgresMatchMode == "" {
+		proxyOptions.PostgresMatchMode = confTest.PostgresMatchMode
+	}
+	proxyOptions.PostgresPaceNotifications = proxyOptions.PostgresPaceNotifications || confTest.PostgresPaceNotifications
+	if proxyOptions.PostgresPassword == "" {
+		proxyOptions.PostgresPassword = confTest.PostgresPassword
+	}
+	proxyOptions.PostgresSkipAuthentication = proxyOptions.PostgresSkipAuthentication || confTest.PostgresSkipAuthentication
+	if proxyOptions.PostgresMaxPendingBytes == 0 {
+		proxyOptions.PostgresMaxPendingBytes = confTest.PostgresMaxPendingBytes
+	}
+	proxyOptions.PostgresIgnoredFields = postgresparser.IgnoredFields{
+		Columns:       confTest.PostgresIgnoredFields.Columns,
+		ColumnIndexes: confTest.PostgresIgnoredFields.ColumnIndexes,
+		Parameters:    confTest.PostgresIgnoredFields.Parameters,
+		MessageFields: confTest.PostgresIgnoredFields.MessageFields,
+	}
+	proxyOptions.PostgresReplayLatency = proxyOptions.PostgresReplayLatency || confTest.PostgresReplayLatency
+	if proxyOptions.PostgresMaxReplayLatency == 0 {
+		proxyOptions.PostgresMaxReplayLatency = confTest.PostgresMaxReplayLatency
+	}
+	proxyOptions.HeaderPredicates = confTest.Stubs.HeaderPredicates
+	if len(proxyOptions.PostgresStripSchemas) == 0 {
+		proxyOptions.PostgresStripSchemas = confTest.PostgresStripSchemas
+	}
+	if len(proxyOptions.PostgresStalenessTolerance) == 0 {
+		proxyOptions.PostgresStalenessTolerance = confTest.PostgresStalenessTolerance
+	}
 	passThroughPortProvided := len(*passThroughPorts) == 0
 	for _, filter := range confTest.Stubs.Filters {
 		if filter.Port != 0 && filter.Host == "" && filter.Path == "" && passThroughPortProvided {
@@ -248,6 +315,184 @@ func (t *Test) GetCmd() *cobra.Command {
 				return err
 			}
 
+			divergenceThreshold, err := cmd.Flags().GetFloat64("divergenceThreshold")
+			if err != nil {
+				t.logger.Error("failed to read the divergence threshold flag")
+				return err
+			}
+
+			recordUnmatched, err := cmd.Flags().GetBool("recordUnmatched")
+			if err != nil {
+				t.logger.Error("failed to read the record unmatched flag")
+				return err
+			}
+
+			postgresReadTimeout, err := cmd.Flags().GetDuration("postgresReadTimeout")
+			if err != nil {
+				t.logger.Error("failed to read the postgres read timeout flag")
+				return err
+			}
+
+			reportFormat, err := cmd.Flags().GetString("reportFormat")
+			if err != nil {
+				t.logger.Error("failed to read the report format flag")
+				return err
+			}
+
+			noticePolicy, err := cmd.Flags().GetString("postgresNoticePolicy")
+			if err != nil {
+				t.logger.Error("failed to read the postgres notice policy flag")
+				return err
+			}
+
+			minSimilarity, err := cmd.Flags().GetFloat64("postgresMinSimilarity")
+			if err != nil {
+				t.logger.Error("failed to read the postgres min similarity flag")
+				return err
+			}
+
+			disabledIntegrations, err := cmd.Flags().GetStringSlice("disabledIntegrations")
+			if err != nil {
+				t.logger.Error("failed to read the disabledIntegrations flag")
+				return err
+			}
+
+			greetingCapabilities, err := cmd.Flags().GetUint32("mysqlGreetingCapabilities")
+			if err != nil {
+				t.logger.Error("failed to read the mysqlGreetingCapabilities flag")
+				return err
+			}
+
+			paceCopyOut, err := cmd.Flags().GetBool("postgresPaceCopyOut")
+			if err != nil {
+				t.logger.Error("failed to read the postgresPaceCopyOut flag")
+				return err
+			}
+
+			passthroughSSL, err := cmd.Flags().GetBool("postgresPassthroughSSL")
+			if err != nil {
+				t.logger.Error("failed to read the postgresPassthroughSSL flag")
+				return err
+			}
+
+			http3Port, err := cmd.Flags().GetUint32("http3Port")
+			if err != nil {
+				t.logger.Error("failed to read the http3Port flag")
+				return err
+			}
+
+			jitterDistribution, err := cmd.Flags().GetString("postgresJitterDistribution")
+			if err != nil {
+				t.logger.Error("failed to read the postgresJitterDistribution flag")
+				return err
+			}
+
+			jitterMin, err := cmd.Flags().GetDuration("postgresJitterMin")
+			if err != nil {
+				t.logger.Error("failed to read the postgresJitterMin flag")
+				return err
+			}
+
+			jitterMax, err := cmd.Flags().GetDuration("postgresJitterMax")
+			if err != nil {
+				t.logger.Error("failed to read the postgresJitterMax flag")
+				return err
+			}
+
+			jitterMean, err := cmd.Flags().GetDuration("postgresJitterMean")
+			if err != nil {
+				t.logger.Error("failed to read the postgresJitterMean flag")
+				return err
+			}
+
+			jitterStdDev, err := cmd.Flags().GetDuration("postgresJitterStdDev")
+			if err != nil {
+				t.logger.Error("failed to read the postgresJitterStdDev flag")
+				return err
+			}
+
+			matchMode, err := cmd.Flags().GetString("postgresMatchMode")
+			if err != nil {
+				t.logger.Error("failed to read the postgresMatchMode flag")
+				return err
+			}
+
+			paceNotifications, err := cmd.Flags().GetBool("postgresPaceNotifications")
+			if err != nil {
+				t.logger.Error("failed to read the postgresPaceNotifications flag")
+				return err
+			}
+
+			postgresPassword, err := cmd.Flags().GetString("postgresPassword")
+			if err != nil {
+				t.logger.Error("failed to read the postgresPassword flag")
+				return err
+			}
+
+			skipAuthentication, err := cmd.Flags().GetBool("postgresSkipAuthentication")
+			if err != nil {
+				t.logger.Error("failed to read the postgresSkipAuthentication flag")
+				return err
+			}
+
+			maxPendingBytes, err := cmd.Flags().GetInt("postgresMaxPendingBytes")
+			if err != nil {
+				t.logger.Error("failed to read the postgresMaxPendingBytes flag")
+				return err
+			}
+
+			replayLatency, err := cmd.Flags().GetBool("postgresReplayLatency")
+			if err != nil {
+				t.logger.Error("failed to read the postgresReplayLatency flag")
+				return err
+			}
+
+			maxReplayLatency, err := cmd.Flags().GetDuration("postgresMaxReplayLatency")
+			if err != nil {
+				t.logger.Error("failed to read the postgresMaxReplayLatency flag")
+				return err
+			}
+
+			stalenessTolerance, err := cmd.Flags().GetStringToString("postgresStalenessTolerance")
+			if err != nil {
+				t.logger.Error("failed to read the postgresStalenessTolerance flag")
+				return err
+			}
+			tolerances := make(map[string]float64, len(stalenessTolerance))
+			for column, value := range stalenessTolerance {
+				delta, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					t.logger.Error("invalid postgres staleness tolerance, expected column=delta", zap.String("column", column), zap.String("delta", value))
+					return err
+				}
+				tolerances[column] = delta
+			}
+
+			proxyOptions := proxy.Option{
+				PostgresNoticePolicy:      noticePolicy,
+				PostgresMinSimilarity:     minSimilarity,
+				DisabledIntegrations:      disabledIntegrations,
+				MySQLGreetingCapabilities: greetingCapabilities,
+				PostgresPaceCopyOut:       paceCopyOut,
+				PostgresPassthroughSSL:    passthroughSSL,
+				Http3Port:                 http3Port,
+				PostgresReplayJitter: postgresparser.ReplayJitter{
+					Distribution: postgresparser.JitterDistribution(jitterDistribution),
+					Min:          jitterMin,
+					Max:          jitterMax,
+					Mean:         jitterMean,
+					StdDev:       jitterStdDev,
+				},
+				PostgresMatchMode:          matchMode,
+				PostgresPaceNotifications:  paceNotifications,
+				PostgresPassword:           postgresPassword,
+				PostgresSkipAuthentication: skipAuthentication,
+				PostgresMaxPendingBytes:    maxPendingBytes,
+				PostgresReplayLatency:      replayLatency,
+				PostgresMaxReplayLatency:   maxReplayLatency,
+				PostgresStalenessTolerance: tolerances,
+			}
+
 			testFilters := map[string][]string{}
 
 			testsets, err := cmd.Flags().GetStringSlice("testsets")
@@ -264,7 +509,7 @@ func (t *Test) GetCmd() *cobra.Command {
 			testsetNoise := make(models.TestsetNoise)
 
 			passThroughHosts := []models.Filters{}
-			err = t.getTestConfig(&path, &proxyPort, &appCmd, &testFilters, &appContainer, &networkName, &delay, &buildDelay, &ports, &apiTimeout, &globalNoise, &testsetNoise, &coverageReportPath, &withCoverage, &generateTestReport, configPath, &ignoreOrdering, &passThroughHosts)
+			err = t.getTestConfig(&path, &proxyPort, &appCmd, &testFilters, &appContainer, &networkName, &delay, &buildDelay, &ports, &apiTimeout, &globalNoise, &testsetNoise, &coverageReportPath, &withCoverage, &generateTestReport, configPath, &ignoreOrdering, &passThroughHosts, &divergenceThreshold, &postgresReadTimeout, &reportFormat, &proxyOptions)
 			if err != nil {
 				if err == errFileNotFound {
 					t.logger.Info("Keploy config not found, continuing without configuration")
@@ -273,6 +518,47 @@ func (t *Test) GetCmd() *cobra.Command {
 				}
 			}
 
+			if err := models.CompileHeaderPredicates(proxyOptions.HeaderPredicates); err != nil {
+				t.logger.Error("invalid header predicate in the stubs of the config", zap.Error(err))
+				return err
+			}
+
+			switch models.TestReportFormat(reportFormat) {
+			case "", models.TestReportFormatYAML, models.TestReportFormatJUnit:
+			default:
+				t.logger.Error("unknown test report format, expected yaml or junit", zap.Any("format", reportFormat))
+				return errors.New("invalid report format")
+			}
+
+			switch postgresparser.NoticePolicy(proxyOptions.PostgresNoticePolicy) {
+			case "", postgresparser.NoticeKeep, postgresparser.NoticeDrop:
+			default:
+				t.logger.Error("unknown postgres notice policy, expected keep or drop", zap.Any("policy", proxyOptions.PostgresNoticePolicy))
+				return errors.New("invalid postgres notice policy")
+			}
+
+			switch proxyOptions.PostgresReplayJitter.Distribution {
+			case "", postgresparser.JitterUniform, postgresparser.JitterNormal:
+			default:
+				t.logger.Error("unknown postgres jitter distribution, expected uniform or normal", zap.Any("distribution", proxyOptions.PostgresReplayJitter.Distribution))
+				return errors.New("invalid postgres jitter distribution")
+			}
+
+			if !postgresparser.MatchMode(proxyOptions.PostgresMatchMode).Valid() {
+				t.logger.Error("unknown postgres match mode, expected exact, normalized or template", zap.Any("mode", proxyOptions.PostgresMatchMode))
+				return errors.New("invalid postgres match mode")
+			}
+
+			if divergenceThreshold < 0 || divergenceThreshold > 1 {
+				t.logger.Error("the divergence threshold must be between 0 and 1", zap.Any("threshold", divergenceThreshold))
+				return errors.New("invalid divergence threshold")
+			}
+
+			if proxyOptions.PostgresMinSimilarity < 0 || proxyOptions.PostgresMinSimilarity > 1 {
+				t.logger.Error("the postgres min similarity must be between 0 and 1", zap.Any("similarity", proxyOptions.PostgresMinSimilarity))
+				return errors.New("invalid postgres min similarity")
+			}
+
 			if appCmd == "" {
 				t.logger.Error("Couldn't find appCmd")
 				if isDockerCmd {
@@ -439,23 +725,28 @@ func (t *Test) GetCmd() *cobra.Command {
 			} else {
 
 				t.tester.StartTest(path, testReportPath, appCmd, test.TestOptions{
-					Tests:              testFilters,
-					AppContainer:       appContainer,
-					AppNetwork:         networkName,
-					MongoPassword:      mongoPassword,
-					Delay:              delay,
-					BuildDelay:         buildDelay,
-					PassThroughPorts:   ports,
-					ApiTimeout:         apiTimeout,
-					ProxyPort:          proxyPort,
-					GlobalNoise:        globalNoise,
-					TestsetNoise:       testsetNoise,
-					WithCoverage:       withCoverage,
-					CoverageReportPath: coverageReportPath,
-					IgnoreOrdering:     ignoreOrdering,
-					RemoveUnusedMocks:  removeUnusedMocks,
-					PassthroughHosts:   passThroughHosts,
-					GenerateTestReport: generateTestReport,
+					Tests:               testFilters,
+					AppContainer:        appContainer,
+					AppNetwork:          networkName,
+					MongoPassword:       mongoPassword,
+					Delay:               delay,
+					BuildDelay:          buildDelay,
+					PassThroughPorts:    ports,
+					ApiTimeout:          apiTimeout,
+					ProxyPort:           proxyPort,
+					GlobalNoise:         globalNoise,
+					TestsetNoise:        testsetNoise,
+					WithCoverage:        withCoverage,
+					CoverageReportPath:  coverageReportPath,
+					IgnoreOrdering:      ignoreOrdering,
+					RemoveUnusedMocks:   removeUnusedMocks,
+					PassthroughHosts:    passThroughHosts,
+					GenerateTestReport:  generateTestReport,
+					DivergenceThreshold: divergenceThreshold,
+					RecordUnmatched:     recordUnmatched,
+					PostgresReadTimeout: postgresReadTimeout,
+					ReportFormat:        models.TestReportFormat(reportFormat),
+					ProxyOptions:        proxyOptions,
 				}, enableTele)
 
 				fileExist := utils.CheckFileExists(path)
@@ -479,6 +770,7 @@ func (t *Test) GetCmd() *cobra.Command {
 	testCmd.Flags().Uint32("port", 6789, "Port at which you want to run graphql Server")
 
 	testCmd.Flags().Uint32("proxyport", 0, "Choose a port to run Keploy Proxy.")
+	testCmd.Flags().Uint32("http3Port", 0, "UDP port on which the proxy serves the HTTP/3 calls over QUIC, such as 443. The HTTP/3 calls are not intercepted when 0")
 
 	testCmd.Flags().StringP("command", "c", "", "Command to start the user application")
 
@@ -513,6 +805,30 @@ func (t *Test) GetCmd() *cobra.Command {
 
 	testCmd.Flags().Bool("removeUnusedMocks", false, "Removes unused mocks from mock file")
 
+	testCmd.Flags().Bool("recordUnmatched", false, "Record the postgres connections without any recorded session into the test-set, replaying the others")
+	testCmd.Flags().String("reportFormat", "", "Format of the test reports, yaml or junit to also write a JUnit XML report (default yaml)")
+	testCmd.Flags().Duration("postgresReadTimeout", 0, "Time to wait for the next packets of a postgres request before matching it with the mocks (default 10ms)")
+	testCmd.Flags().String("postgresNoticePolicy", "", "Policy for the NoticeResponse messages of the postgres mocks: keep (default) or drop to leave them out of the replayed responses")
+	testCmd.Flags().Float64("postgresMinSimilarity", 0, "Lowest similarity (0 to 1) with a mock accepted for the postgres requests without an exact match (default 0.5)")
+	testCmd.Flags().Float64("divergenceThreshold", 0, "Rate of the dependency calls matching none of the mocks (0 to 1) above which the test run fails. 0 disables the check")
+	testCmd.Flags().StringSlice("disabledIntegrations", []string{}, "Integrations left out of the detection of the outgoing calls, among mysql, postgres, mongo, redis, http and grpc")
+	testCmd.Flags().Uint32("mysqlGreetingCapabilities", 0, "Mask of the capability flags advertised by the replayed MySQL server greeting, e.g. 0xf7ff. The recorded capabilities are replayed when 0")
+	testCmd.Flags().Bool("postgresPaceCopyOut", false, "Replay the chunks of the postgres COPY OUT streams at the pace they were recorded at")
+	testCmd.Flags().Bool("postgresPassthroughSSL", false, "Forward the SSL sessions of the postgres clients to the server instead of decrypting them with the CA of keploy")
+	testCmd.Flags().String("postgresJitterDistribution", "", "Distribution of the random delays of the replayed postgres responses: uniform between the min and the max or normal around the mean. No delay when empty")
+	testCmd.Flags().Duration("postgresJitterMin", 0, "Lower bound of the random delays of the replayed postgres responses")
+	testCmd.Flags().Duration("postgresJitterMax", 0, "Upper bound of the random delays of the replayed postgres responses, unbounded for the normal distribution when 0")
+	testCmd.Flags().Duration("postgresJitterMean", 0, "Mean of the normally distributed delays of the replayed postgres responses")
+	testCmd.Flags().Duration("postgresJitterStdDev", 0, "Standard deviation of the normally distributed delays of the replayed postgres responses")
+	testCmd.Flags().String("postgresMatchMode", "", "How strictly the postgres statements are compared with the mocks: exact (default), normalized or template to fall back on the mocks recorded with other values")
+	testCmd.Flags().Bool("postgresPaceNotifications", false, "Replay the postgres notifications pushed while the client was idle after the delays they were recorded with")
+	testCmd.Flags().String("postgresPassword", "", "Password of the postgres user, to replay the SCRAM and MD5 authentications of the clients")
+	testCmd.Flags().Bool("postgresSkipAuthentication", false, "Let the postgres clients in without replaying their authentication")
+	testCmd.Flags().Int("postgresMaxPendingBytes", 0, "Largest size in bytes of the incomplete postgres messages of a connection, such as a COPY FROM STDIN stream, kept in memory. 0 keeps the default of 64MB")
+	testCmd.Flags().Bool("postgresReplayLatency", false, "Delay the replayed postgres responses by the latency they were recorded with")
+	testCmd.Flags().Duration("postgresMaxReplayLatency", 0, "Largest latency replayed for a postgres response, 5s when 0")
+	testCmd.Flags().StringToString("postgresStalenessTolerance", map[string]string{}, "Largest difference accepted between the recorded values of the postgres columns read from a replica and the live ones of the unmatched requests passed through, which are then not counted as diverging e.g. --postgresStalenessTolerance balance=0.5")
+
 	testCmd.Flags().MarkHidden("enableTele")
 
 	testCmd.Flags().Bool("withCoverage", false, "Capture the code coverage of the go binary in the command flag.")
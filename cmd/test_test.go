@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy"
+	"go.uber.org/zap"
+)
+
+func runTest(args ...string) error {
+	cmd := NewCmdTest(zap.NewNop()).GetCmd()
+	cmd.SetArgs(args)
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return cmd.Execute()
+}
+
+// writeConfig writes the keploy config into a new directory and returns it.
+func writeConfig(t *testing.T, config string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "keploy-config.yaml"), []byte(config), 0o644); err != nil {
+		t.Fatalf("failed to write the config: %v", err)
+	}
+	return dir
+}
+
+func TestTestJitterDistribution(t *testing.T) {
+	empty := t.TempDir()
+	for _, tt := range []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "no jitter", args: []string{"--config-path", empty}, wantErr: "missing required -c flag or appCmd in config file"},
+		{name: "uniform flag", args: []string{"--config-path", empty, "--postgresJitterDistribution", "uniform", "--postgresJitterMax", "20ms"}, wantErr: "missing required -c flag or appCmd in config file"},
+		{name: "unknown flag", args: []string{"--config-path", empty, "--postgresJitterDistribution", "pareto"}, wantErr: "invalid postgres jitter distribution"},
+		{
+			name:    "unknown in the config",
+			args:    []string{"--config-path", writeConfig(t, "test:\n  postgresReplayJitter:\n    distribution: pareto\n    max: 20ms\n")},
+			wantErr: "invalid postgres jitter distribution",
+		},
+		{
+			name:    "normal in the config",
+			args:    []string{"--config-path", writeConfig(t, "test:\n  postgresReplayJitter:\n    distribution: normal\n    mean: 20ms\n    stdDev: 5ms\n")},
+			wantErr: "missing required -c flag or appCmd in config file",
+		},
+		{
+			// the flag takes precedence over the config
+			name:    "flag over the config",
+			args:    []string{"--config-path", writeConfig(t, "test:\n  postgresReplayJitter:\n    distribution: pareto\n"), "--postgresJitterDistribution", "uniform"},
+			wantErr: "missing required -c flag or appCmd in config file",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runTest(tt.args...)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("got the error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTestMatchMode(t *testing.T) {
+	empty := t.TempDir()
+	for _, tt := range []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{name: "default", args: []string{"--config-path", empty}, wantErr: "missing required -c flag or appCmd in config file"},
+		{name: "template flag", args: []string{"--config-path", empty, "--postgresMatchMode", "template"}, wantErr: "missing required -c flag or appCmd in config file"},
+		{name: "unknown flag", args: []string{"--config-path", empty, "--postgresMatchMode", "fuzzy"}, wantErr: "invalid postgres match mode"},
+		{name: "exact in the config", args: []string{"--config-path", writeConfig(t, "test:\n  postgresMatchMode: exact\n")}, wantErr: "missing required -c flag or appCmd in config file"},
+		{name: "unknown in the config", args: []string{"--config-path", writeConfig(t, "test:\n  postgresMatchMode: fuzzy\n")}, wantErr: "invalid postgres match mode"},
+		{
+			// the flag takes precedence over the config
+			name:    "flag over the config",
+			args:    []string{"--config-path", writeConfig(t, "test:\n  postgresMatchMode: fuzzy\n"), "--postgresMatchMode", "normalized"},
+			wantErr: "missing required -c flag or appCmd in config file",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runTest(tt.args...)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("got the error %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// testConfig merges the test config into the proxy options of the flags.
+func testConfig(t *testing.T, config string, proxyOptions *proxy.Option) {
+	t.Helper()
+	var (
+		path, appCmd, appContainer, networkName, coverageReportPath, reportFormat string
+		proxyPort                                                                 uint32
+		delay, apiTimeout                                                         uint64
+		buildDelay, postgresReadTimeout                                           time.Duration
+		ports                                                                     []uint
+		globalNoise                                                               models.GlobalNoise
+		testsetNoise                                                              models.TestsetNoise
+		withCoverage, generateTestReport, ignoreOrdering                          bool
+		passThroughHosts                                                          []models.Filters
+		divergenceThreshold                                                       float64
+	)
+	testFilters := map[string][]string{}
+	err := NewCmdTest(zap.NewNop()).getTestConfig(&path, &proxyPort, &appCmd, &testFilters, &appContainer, &networkName, &delay, &buildDelay, &ports, &apiTimeout, &globalNoise, &testsetNoise, &coverageReportPath, &withCoverage, &generateTestReport, writeConfig(t, config), &ignoreOrdering, &passThroughHosts, &divergenceThreshold, &postgresReadTimeout, &reportFormat, proxyOptions)
+	if err != nil {
+		t.Fatalf("failed to read the config: %v", err)
+	}
+}
+
+func TestTestMaxPendingBytes(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		flag   int
+		config string
+		want   int
+	}{
+		{name: "default", config: "test:\n", want: 0},
+		{name: "config", config: "test:\n  postgresMaxPendingBytes: 1024\n", want: 1024},
+		{name: "flag over the config", flag: 2048, config: "test:\n  postgresMaxPendingBytes: 1024\n", want: 2048},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyOptions := proxy.Option{PostgresMaxPendingBytes: tt.flag}
+			testConfig(t, tt.config, &proxyOptions)
+			if proxyOptions.PostgresMaxPendingBytes != tt.want {
+				t.Errorf("got the limit %d, want %d", proxyOptions.PostgresMaxPendingBytes, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestHttp3Port(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		flag   uint32
+		config string
+		want   uint32
+	}{
+		{name: "off by default", config: "test:\n", want: 0},
+		{name: "config", config: "test:\n  http3Port: 8443\n", want: 8443},
+		{name: "flag over the config", flag: 443, config: "test:\n  http3Port: 8443\n", want: 443},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyOptions := proxy.Option{Http3Port: tt.flag}
+			testConfig(t, tt.config, &proxyOptions)
+			if proxyOptions.Http3Port != tt.want {
+				t.Errorf("got the http3 port %d, want %d", proxyOptions.Http3Port, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"go.keploy.io/server/pkg/platform/yaml"
+	"go.uber.org/zap"
+)
+
+func NewCmdValidateMocks(logger *zap.Logger) *ValidateMocks {
+	return &ValidateMocks{
+		logger: logger,
+	}
+}
+
+// ValidateMocks checks that every recorded request of the mocks has a
+// response, and the other way round.
+type ValidateMocks struct {
+	logger *zap.Logger
+}
+
+func (v *ValidateMocks) GetCmd() *cobra.Command {
+	var validateMocksCmd = &cobra.Command{
+		Use:     "validate-mocks",
+		Short:   "report the recorded mocks which have requests without a response or responses without a request",
+		Example: "keploy validate-mocks --path /path/to/localdir --testsets test-set-1",
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			path, err := cmd.Flags().GetString("path")
+			if err != nil {
+				v.logger.Error("failed to read the path of the recordings")
+				return err
+			}
+
+			testSets, err := cmd.Flags().GetStringSlice("testsets")
+			if err != nil {
+				v.logger.Error("failed to read the testsets")
+				return err
+			}
+
+			path, err = filepath.Abs(path)
+			if err != nil {
+				v.logger.Error("failed to get the absolute path from relative path", zap.Error(err))
+				return err
+			}
+			path = filepath.Join(path, "keploy")
+
+			ys := yaml.NewYamlStore(filepath.Join(path, "tests"), path, "", "", v.logger, nil)
+			if len(testSets) == 0 {
+				testSets, err = ys.ReadTestSessionIndices()
+				if err != nil {
+					v.logger.Error("failed to read the recorded test-sets", zap.Error(err))
+					return err
+				}
+			}
+
+			issues := 0
+			for _, testSet := range testSets {
+				report, err := ys.ValidateMocks(testSet)
+				if err != nil {
+					return err
+				}
+				issues += len(report.Issues)
+			}
+			if issues > 0 {
+				v.logger.Error("some recorded mocks cannot be replayed", zap.Int("mocks", issues))
+				return errors.New("invalid mocks")
+			}
+			v.logger.Info("the recorded mocks are valid", zap.Strings("testsets", testSets))
+			return nil
+		},
+	}
+
+	validateMocksCmd.Flags().StringP("path", "p", ".", "Path to the local directory where the keploy recordings are stored")
+
+	validateMocksCmd.Flags().StringSliceP("testsets", "t", []string{}, "Testsets whose mocks are validated, all of them by default e.g. --testsets \"test-set-1, test-set-2\"")
+
+	return validateMocksCmd
+}
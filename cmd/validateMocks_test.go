@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform/yaml"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// writeMockFile writes the mocks as the mock file of the test-set of the
+// recordings in path.
+func writeMockFile(t *testing.T, path, testSet string, mocks ...*models.Mock) {
+	t.Helper()
+	var data []byte
+	for i, mock := range mocks {
+		doc, err := yaml.EncodeMock(mock, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to encode the mock: %v", err)
+		}
+		d, err := yamlLib.Marshal(doc)
+		if err != nil {
+			t.Fatalf("failed to marshal the mock: %v", err)
+		}
+		if i > 0 {
+			data = append(data, "---\n"...)
+		}
+		data = append(data, d...)
+	}
+	dir := filepath.Join(path, "keploy", testSet)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("failed to create the test-set: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "mocks.yaml"), data, 0o644); err != nil {
+		t.Fatalf("failed to write the mock file: %v", err)
+	}
+}
+
+func genericMock(name string, answered bool) *models.Mock {
+	mock := &models.Mock{
+		Version: models.GetVersion(),
+		Name:    name,
+		Kind:    models.GENERIC,
+		Spec: models.MockSpec{
+			GenericRequests: []models.GenericPayload{{Origin: models.FromClient, Message: []models.OutputBinary{{Type: models.String, Data: "PING\r\n"}}}},
+		},
+	}
+	if answered {
+		mock.Spec.GenericResponses = []models.GenericPayload{{Origin: models.FromServer, Message: []models.OutputBinary{{Type: models.String, Data: "+PONG\r\n"}}}}
+	}
+	return mock
+}
+
+func runValidateMocks(args ...string) error {
+	cmd := NewCmdValidateMocks(zap.NewNop()).GetCmd()
+	cmd.SetArgs(args)
+	cmd.SilenceUsage = true
+	cmd.SilenceErrors = true
+	return cmd.Execute()
+}
+
+func TestValidateMocksExitStatus(t *testing.T) {
+	path := t.TempDir()
+	writeMockFile(t, path, "test-set-0", genericMock("mock-0", true), genericMock("mock-1", true))
+	writeMockFile(t, path, "test-set-1", genericMock("mock-0", true), genericMock("mock-1", false))
+
+	if err := runValidateMocks("--path", path, "--testsets", "test-set-0"); err != nil {
+		t.Errorf("failed to validate the valid mocks: %v", err)
+	}
+	if err := runValidateMocks("--path", path, "--testsets", "test-set-1"); err == nil {
+		t.Errorf("validated the mock without a response")
+	}
+	// all the test-sets are validated by default
+	if err := runValidateMocks("--path", path); err == nil {
+		t.Errorf("validated the test-sets with a mock without a response")
+	}
+
+	valid := t.TempDir()
+	writeMockFile(t, valid, "test-set-0", genericMock("mock-0", true))
+	if err := runValidateMocks("--path", valid); err != nil {
+		t.Errorf("failed to validate the test-sets of valid mocks: %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package graph
 
 import (
+	"fmt"
+
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/platform"
 	"go.keploy.io/server/pkg/proxy"
+	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
 	"go.keploy.io/server/pkg/service/test"
 	"go.uber.org/zap"
 )
@@ -34,3 +37,22 @@ type Resolver struct {
 	ApiTimeout         uint64
 	ServeTest          bool
 }
+
+// SetCurrentTestName tags the mocks recorded from now on with the name of the
+// test case driving the recording.
+func (r *Resolver) SetCurrentTestName(name string) {
+	if r.LoadedHooks == nil {
+		return
+	}
+	r.LoadedHooks.SetCurrentTestName(name)
+}
+
+// ExplainPostgresMismatch explains why the postgres requests, such as a
+// failing replay request, match none of the mocks of the test-set. It lists
+// the top candidate mocks with the fields of their requests which differ.
+func (r *Resolver) ExplainPostgresMismatch(testSet string, requestBuffers [][]byte, top int) (*postgresparser.MatchExplanation, error) {
+	if r.Storage == nil {
+		return nil, fmt.Errorf(Emoji + "no mock storage is configured")
+	}
+	return postgresparser.ExplainMismatchFromTestSet(r.Storage, testSet, requestBuffers, top)
+}
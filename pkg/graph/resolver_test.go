@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"context"
+	"testing"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// mockDB keeps the mocks written through the hooks.
+type mockDB struct {
+	platform.TestCaseDB
+	mocks []*models.Mock
+}
+
+func (db *mockDB) WriteMock(mock platform.KindSpecifier, ctx context.Context) error {
+	db.mocks = append(db.mocks, mock.(*models.Mock))
+	return nil
+}
+
+func TestSetCurrentTestNameTagsTheRecordedMocks(t *testing.T) {
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	r := &Resolver{LoadedHooks: h}
+
+	r.SetCurrentTestName("get-user")
+	if err := h.AppendMocks(&models.Mock{Name: "mock-0", Kind: models.Postgres}, context.Background()); err != nil {
+		t.Fatalf("failed to append the mock: %v", err)
+	}
+	r.SetCurrentTestName("")
+	if err := h.AppendMocks(&models.Mock{Name: "mock-1", Kind: models.Postgres}, context.Background()); err != nil {
+		t.Fatalf("failed to append the mock: %v", err)
+	}
+
+	if len(db.mocks) != 2 {
+		t.Fatalf("got %d mocks, want 2", len(db.mocks))
+	}
+	if got := db.mocks[0].Spec.Metadata[models.TestNameMetadata]; got != "get-user" {
+		t.Fatalf("got test name %q in the metadata, want %q", got, "get-user")
+	}
+	if got, ok := db.mocks[1].Spec.Metadata[models.TestNameMetadata]; ok {
+		t.Fatalf("got test name %q in the metadata once cleared, want none", got)
+	}
+
+	// a resolver without hooks ignores the name
+	(&Resolver{}).SetCurrentTestName("get-user")
+}
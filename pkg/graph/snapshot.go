@@ -0,0 +1,62 @@
+package graph
+
+import (
+	"go.keploy.io/server/pkg/models"
+)
+
+const redacted = "[REDACTED]"
+
+// ResolverSnapshot is the effective configuration of the Resolver along with
+// its runtime state, meant to be shared when reporting issues. Secrets are
+// redacted.
+type ResolverSnapshot struct {
+	KeployServerPort   uint32              `json:"keployServerPort" yaml:"keployServerPort"`
+	ProxyPort          uint32              `json:"proxyPort" yaml:"proxyPort"`
+	PassThroughPorts   []uint              `json:"passThroughPorts" yaml:"passThroughPorts"`
+	Lang               string              `json:"lang" yaml:"lang"`
+	MongoPassword      string              `json:"mongoPassword" yaml:"mongoPassword"`
+	Path               string              `json:"path" yaml:"path"`
+	TestReportPath     string              `json:"testReportPath" yaml:"testReportPath"`
+	GenerateTestReport bool                `json:"generateTestReport" yaml:"generateTestReport"`
+	Delay              uint64              `json:"delay" yaml:"delay"`
+	ApiTimeout         uint64              `json:"apiTimeout" yaml:"apiTimeout"`
+	AppPid             uint32              `json:"appPid" yaml:"appPid"`
+	ServeTest          bool                `json:"serveTest" yaml:"serveTest"`
+	TestFilter         map[string][]string `json:"testFilter" yaml:"testFilter"`
+
+	Mode             models.Mode `json:"mode" yaml:"mode"`
+	TcsMocksCount    int         `json:"tcsMocksCount" yaml:"tcsMocksCount"`
+	ConfigMocksCount int         `json:"configMocksCount" yaml:"configMocksCount"`
+}
+
+// Snapshot returns the redacted configuration and the runtime state of the
+// Resolver for diagnostics.
+func (r *Resolver) Snapshot() ResolverSnapshot {
+	snapshot := ResolverSnapshot{
+		KeployServerPort:   r.KeployServerPort,
+		ProxyPort:          r.ProxyPort,
+		PassThroughPorts:   r.PassThroughPorts,
+		Lang:               r.Lang,
+		Path:               r.Path,
+		TestReportPath:     r.TestReportPath,
+		GenerateTestReport: r.GenerateTestReport,
+		Delay:              r.Delay,
+		ApiTimeout:         r.ApiTimeout,
+		AppPid:             r.AppPid,
+		ServeTest:          r.ServeTest,
+		TestFilter:         r.TestFilter,
+		Mode:               models.GetMode(),
+	}
+	if r.MongoPassword != "" {
+		snapshot.MongoPassword = redacted
+	}
+	if r.LoadedHooks != nil {
+		if mocks, err := r.LoadedHooks.GetTcsMocks(); err == nil {
+			snapshot.TcsMocksCount = len(mocks)
+		}
+		if mocks, err := r.LoadedHooks.GetConfigMocks(); err == nil {
+			snapshot.ConfigMocksCount = len(mocks)
+		}
+	}
+	return snapshot
+}
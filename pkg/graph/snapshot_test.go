@@ -0,0 +1,53 @@
+package graph
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSnapshotRedactsTheMongoPassword(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetTcsMocks([]*models.Mock{{Name: "mock-0"}, {Name: "mock-1"}})
+	h.SetConfigMocks([]*models.Mock{{Name: "mock-2"}})
+	r := &Resolver{ProxyPort: 16789, Path: "/app/keploy", MongoPassword: "s3cr3t-pa55", LoadedHooks: h}
+
+	snapshot := r.Snapshot()
+	if snapshot.MongoPassword != redacted {
+		t.Errorf("got the mongo password %q, want %q", snapshot.MongoPassword, redacted)
+	}
+	if snapshot.ProxyPort != 16789 || snapshot.Path != "/app/keploy" {
+		t.Errorf("got the proxy port %d and the path %q, want the ones of the resolver", snapshot.ProxyPort, snapshot.Path)
+	}
+	if snapshot.TcsMocksCount != 2 || snapshot.ConfigMocksCount != 1 {
+		t.Errorf("got %d tcs mocks and %d config mocks, want 2 and 1", snapshot.TcsMocksCount, snapshot.ConfigMocksCount)
+	}
+
+	// the password never reaches the shared forms of the snapshot
+	encodedJSON, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("failed to marshal the snapshot to json: %v", err)
+	}
+	encodedYAML, err := yaml.Marshal(snapshot)
+	if err != nil {
+		t.Fatalf("failed to marshal the snapshot to yaml: %v", err)
+	}
+	for _, encoded := range []string{string(encodedJSON), string(encodedYAML)} {
+		if strings.Contains(encoded, "s3cr3t-pa55") {
+			t.Errorf("leaked the mongo password in the snapshot %s", encoded)
+		}
+	}
+
+	// an unset password is not reported as redacted
+	if got := (&Resolver{}).Snapshot().MongoPassword; got != "" {
+		t.Errorf("got the mongo password %q of a resolver without any", got)
+	}
+}
@@ -54,6 +54,11 @@ type Hook struct {
 	proxyPort   uint32
 	tcsMocks    *treeDb
 	configMocks *treeDb
+	// loadedTcsMocks and loadedConfigMocks are the mocks as they were set,
+	// used to restore the matching state with ResetState. They are copies of
+	// the mocks handed to the matchers, which update those in place.
+	loadedTcsMocks    []*models.Mock
+	loadedConfigMocks []*models.Mock
 	// here key represents mock name and value is true only when
 	// it is not a config mock or it is not being used by any test case yet.
 	consumedMocks            map[string]bool
@@ -102,6 +107,21 @@ type Hook struct {
 
 	idc              clients.InternalDockerClient
 	passThroughHosts models.Stubs
+
+	// currentTestName tags the recorded mocks with the test driving them.
+	currentTestName string
+	// currentTestSet is the test-set replayed in test mode, which stores the
+	// mocks recorded meanwhile.
+	currentTestSet string
+	// roundTrips counts the dependency calls matched with a mock, per kind
+	// of mock.
+	roundTrips map[models.Kind]int
+	// divergences counts the dependency calls which matched none of the
+	// mocks and were passed through to the dependency.
+	divergences int
+	// fuzzyMatches are the names of the mocks matched by similarity only, the
+	// requests of which drifted since the recording.
+	fuzzyMatches []string
 }
 
 func NewHook(db platform.TestCaseDB, mainRoutineId int, logger *zap.Logger) (*Hook, error) {
@@ -135,6 +155,7 @@ func NewHook(db platform.TestCaseDB, mainRoutineId int, logger *zap.Logger) (*Ho
 		configMocks:   configMocks,
 		tcsMocks:      tcsMocks,
 		consumedMocks: make(map[string]bool),
+		roundTrips:    make(map[models.Kind]int),
 		mu:            &sync.Mutex{},
 		userIpAddress: make(chan string),
 		idc:           idc,
@@ -160,7 +181,45 @@ func (h *Hook) SetPassThroughHosts(passThroughHosts []models.Filters) {
 	}
 }
 
+// SetCurrentTestName sets the name of the test case driving the recording.
+// The mocks recorded until it changes carry it in their metadata so that they
+// can be filtered or cleaned up per test. An empty name stops the tagging.
+func (h *Hook) SetCurrentTestName(name string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.currentTestName = name
+}
+
+func (h *Hook) GetCurrentTestName() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.currentTestName
+}
+
+// SetCurrentTestSet sets the test-set being replayed. The mocks recorded in
+// test mode, for the dependencies without any mock, are added to it.
+func (h *Hook) SetCurrentTestSet(testSet string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.currentTestSet = testSet
+}
+
+func (h *Hook) GetCurrentTestSet() string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.currentTestSet
+}
+
 func (h *Hook) AppendMocks(m *models.Mock, ctx context.Context) error {
+	if testSet := h.GetCurrentTestSet(); testSet != "" && models.GetMode() == models.MODE_TEST {
+		ctx = context.WithValue(ctx, "testSet", testSet)
+	}
+	if testName := h.GetCurrentTestName(); testName != "" {
+		if m.Spec.Metadata == nil {
+			m.Spec.Metadata = make(map[string]string)
+		}
+		m.Spec.Metadata[models.TestNameMetadata] = testName
+	}
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	err := h.TestCaseDB.WriteMock(m, ctx)
@@ -187,23 +246,49 @@ func (h *Hook) RemoveUnusedMocks(testSet string) error {
 }
 
 func (h *Hook) SetTcsMocks(m []*models.Mock) {
-	h.tcsMocks.deleteAll()
-	for index, mock := range m {
-		mock.TestModeInfo.SortOrder = index
-		mock.TestModeInfo.Id = index
-		h.tcsMocks.insert(mock.TestModeInfo, mock)
-	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	insertMocks(h.tcsMocks, m)
+	h.loadedTcsMocks = cloneMocks(m)
 }
 
 func (h *Hook) SetConfigMocks(m []*models.Mock) {
-	h.configMocks.deleteAll()
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	insertMocks(h.configMocks, m)
+	h.loadedConfigMocks = cloneMocks(m)
+}
+
+// GetLoadedMocks returns the mocks as they were last set, the consumed ones
+// included.
+func (h *Hook) GetLoadedMocks() []*models.Mock {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	mocks := make([]*models.Mock, 0, len(h.loadedTcsMocks)+len(h.loadedConfigMocks))
+	mocks = append(mocks, h.loadedTcsMocks...)
+	return append(mocks, h.loadedConfigMocks...)
+}
+
+// insertMocks replaces the mocks of the tree with m, in their order.
+func insertMocks(db *treeDb, m []*models.Mock) {
+	db.deleteAll()
 	for index, mock := range m {
 		mock.TestModeInfo.SortOrder = index
 		mock.TestModeInfo.Id = index
-		h.configMocks.insert(mock.TestModeInfo, mock)
+		db.insert(mock.TestModeInfo, mock)
 	}
 }
 
+// cloneMocks copies the mocks so that the matching of the originals leaves
+// the copies untouched.
+func cloneMocks(m []*models.Mock) []*models.Mock {
+	clones := make([]*models.Mock, 0, len(m))
+	for _, mock := range m {
+		clones = append(clones, mock.Clone())
+	}
+	return clones
+}
+
 func (h *Hook) UpdateConfigMock(oldMock *models.Mock, newMock *models.Mock) bool {
 	isUpdated := h.configMocks.update(oldMock.TestModeInfo, newMock.TestModeInfo, newMock)
 	if isUpdated {
@@ -330,12 +415,89 @@ func (h *Hook) GetConsumedMocks() map[string]bool {
 	return h.consumedMocks
 }
 
+// IsMockConsumed reports whether the mock has been matched since the mocks
+// were last set.
+func (h *Hook) IsMockConsumed(mockName string) bool {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	_, ok := h.consumedMocks[mockName]
+	return ok
+}
+
 func (h *Hook) UpdateConsumedMocks(mockName string, isTcsUnused bool) {
 	h.mutex.Lock()
 	defer h.mutex.Unlock()
 	h.consumedMocks[mockName] = isTcsUnused
 }
 
+// RecordRoundTrip counts a dependency call of the replay answered with a mock
+// of the kind, however many mocks the matcher consumed or updated for it.
+func (h *Hook) RecordRoundTrip(kind models.Kind) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.roundTrips[kind]++
+}
+
+// GetRoundTrips returns the number of dependency calls matched with a mock
+// since the mocks were last reset, per kind of mock.
+func (h *Hook) GetRoundTrips() map[models.Kind]int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	roundTrips := make(map[models.Kind]int, len(h.roundTrips))
+	for kind, count := range h.roundTrips {
+		roundTrips[kind] = count
+	}
+	return roundTrips
+}
+
+// RecordDivergence counts a dependency call of the replay which matched none
+// of the mocks.
+func (h *Hook) RecordDivergence() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.divergences++
+}
+
+// GetDivergences returns the number of dependency calls which matched none of
+// the mocks since the mocks were last reset.
+func (h *Hook) GetDivergences() int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.divergences
+}
+
+// RecordFuzzyMatch notes a dependency call of the replay which had no exact
+// match and was matched with the most similar mock.
+func (h *Hook) RecordFuzzyMatch(mockName string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.fuzzyMatches = append(h.fuzzyMatches, mockName)
+}
+
+// GetFuzzyMatches returns the names of the mocks matched by similarity only
+// since the mocks were last reset, in the order they were matched.
+func (h *Hook) GetFuzzyMatches() []string {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return append([]string(nil), h.fuzzyMatches...)
+}
+
+// ResetState restores the mocks consumed or updated by the matchers since
+// they were last set and forgets the consumed mocks, so that a new scenario
+// can be replayed over the same proxy without tearing down its connections.
+func (h *Hook) ResetState() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	// the matchers update the mocks in place, they are handed copies so that
+	// the loaded mocks are kept pristine for the next reset
+	insertMocks(h.tcsMocks, cloneMocks(h.loadedTcsMocks))
+	insertMocks(h.configMocks, cloneMocks(h.loadedConfigMocks))
+	h.consumedMocks = make(map[string]bool)
+	h.roundTrips = make(map[models.Kind]int)
+	h.divergences = 0
+	h.fuzzyMatches = nil
+}
+
 func (h *Hook) ResetDeps() int {
 	h.tcsMocks.deleteAll()
 	return 1
@@ -0,0 +1,79 @@
+package hooks
+
+import (
+	"context"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// testSetDB keeps the test-sets the mocks are written into.
+type testSetDB struct {
+	platform.TestCaseDB
+	testSets []string
+}
+
+func (db *testSetDB) WriteMock(mock platform.KindSpecifier, ctx context.Context) error {
+	testSet, _ := ctx.Value("testSet").(string)
+	db.testSets = append(db.testSets, testSet)
+	return nil
+}
+
+func TestAppendMocksIntoTheReplayedTestSet(t *testing.T) {
+	previous := models.GetMode()
+	t.Cleanup(func() { models.SetMode(previous) })
+
+	db := &testSetDB{}
+	h, err := NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetCurrentTestSet("test-set-3")
+	for _, mode := range []models.Mode{models.MODE_TEST, models.MODE_RECORD} {
+		models.SetMode(mode)
+		if err := h.AppendMocks(&models.Mock{Kind: models.Postgres}, context.Background()); err != nil {
+			t.Fatalf("failed to append the mock: %v", err)
+		}
+	}
+	// a recording keeps the test-set of its context
+	models.SetMode(models.MODE_RECORD)
+	if err := h.AppendMocks(&models.Mock{Kind: models.Postgres}, context.WithValue(context.Background(), "testSet", "test-set-0")); err != nil {
+		t.Fatalf("failed to append the mock: %v", err)
+	}
+
+	want := []string{"test-set-3", "", "test-set-0"}
+	if len(db.testSets) != len(want) {
+		t.Fatalf("wrote the mocks into %q, want %q", db.testSets, want)
+	}
+	for i := range want {
+		if db.testSets[i] != want[i] {
+			t.Errorf("wrote the mock %d into %q, want %q", i, db.testSets[i], want[i])
+		}
+	}
+}
+
+func TestRoundTripsCountedPerKind(t *testing.T) {
+	h, err := NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	for _, kind := range []models.Kind{models.Postgres, models.Postgres, models.HTTP, models.Postgres} {
+		h.RecordRoundTrip(kind)
+	}
+	roundTrips := h.GetRoundTrips()
+	if len(roundTrips) != 2 || roundTrips[models.Postgres] != 3 || roundTrips[models.HTTP] != 1 {
+		t.Fatalf("counted the round trips %v, want 3 postgres and 1 http", roundTrips)
+	}
+	// the counts returned are a copy
+	roundTrips[models.Postgres] = 0
+	if got := h.GetRoundTrips()[models.Postgres]; got != 3 {
+		t.Fatalf("counted %d postgres round trips after changing the copy, want 3", got)
+	}
+
+	h.ResetState()
+	if roundTrips := h.GetRoundTrips(); len(roundTrips) != 0 {
+		t.Fatalf("counted the round trips %v after the reset, want none", roundTrips)
+	}
+}
@@ -0,0 +1,92 @@
+package hooks
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// MatcherState is the serializable state of the mock matchers: the mocks
+// still pending along with their ordering, and the consumed mocks.
+type MatcherState struct {
+	TcsMocks      []PendingMock   `json:"tcsMocks"`
+	ConfigMocks   []PendingMock   `json:"configMocks"`
+	ConsumedMocks map[string]bool `json:"consumedMocks"`
+}
+
+// PendingMock refers to a mock not consumed yet by its position in the mocks
+// last set on the hook.
+type PendingMock struct {
+	Name         string              `json:"name"`
+	TestModeInfo models.TestModeInfo `json:"testModeInfo"`
+}
+
+// SnapshotMatcherState serializes the current state of the matchers so that
+// it can be restored with RestoreMatcherState to reproduce a replay.
+func (h *Hook) SnapshotMatcherState() ([]byte, error) {
+	tcsMocks, err := h.GetTcsMocks()
+	if err != nil {
+		return nil, err
+	}
+	configMocks, err := h.GetConfigMocks()
+	if err != nil {
+		return nil, err
+	}
+	state := MatcherState{
+		TcsMocks:      pendingMocks(tcsMocks),
+		ConfigMocks:   pendingMocks(configMocks),
+		ConsumedMocks: make(map[string]bool),
+	}
+	h.mutex.Lock()
+	for name, isTcsUnused := range h.consumedMocks {
+		state.ConsumedMocks[name] = isTcsUnused
+	}
+	h.mutex.Unlock()
+	return json.Marshal(state)
+}
+
+// RestoreMatcherState restores the state of the matchers serialized by
+// SnapshotMatcherState. The mocks must not have been set again since.
+func (h *Hook) RestoreMatcherState(data []byte) error {
+	var state MatcherState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("failed to decode the matcher state: %v", err)
+	}
+	if err := restorePendingMocks(h.tcsMocks, h.loadedTcsMocks, state.TcsMocks); err != nil {
+		return err
+	}
+	if err := restorePendingMocks(h.configMocks, h.loadedConfigMocks, state.ConfigMocks); err != nil {
+		return err
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.consumedMocks = make(map[string]bool, len(state.ConsumedMocks))
+	for name, isTcsUnused := range state.ConsumedMocks {
+		h.consumedMocks[name] = isTcsUnused
+	}
+	return nil
+}
+
+func pendingMocks(mocks []*models.Mock) []PendingMock {
+	pending := make([]PendingMock, 0, len(mocks))
+	for _, mock := range mocks {
+		pending = append(pending, PendingMock{Name: mock.Name, TestModeInfo: mock.TestModeInfo})
+	}
+	return pending
+}
+
+func restorePendingMocks(db *treeDb, loaded []*models.Mock, pending []PendingMock) error {
+	for _, p := range pending {
+		if p.TestModeInfo.Id < 0 || p.TestModeInfo.Id >= len(loaded) || loaded[p.TestModeInfo.Id].Name != p.Name {
+			return fmt.Errorf("the mock %s of the matcher state is not loaded", p.Name)
+		}
+	}
+	db.deleteAll()
+	for _, p := range pending {
+		mock := loaded[p.TestModeInfo.Id].Clone()
+		mock.TestModeInfo = p.TestModeInfo
+		db.insert(mock.TestModeInfo, mock)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package hooks
+
+import (
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func mockNames(mocks []*models.Mock) []string {
+	names := make([]string, 0, len(mocks))
+	for _, mock := range mocks {
+		names = append(names, mock.Name)
+	}
+	return names
+}
+
+func TestRestoreMatcherState(t *testing.T) {
+	h, err := NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetTcsMocks([]*models.Mock{{Name: "mock-0"}, {Name: "mock-1"}, {Name: "mock-2"}})
+	h.SetConfigMocks([]*models.Mock{{Name: "config-0"}})
+
+	tcs, _ := h.GetTcsMocks()
+	if !h.DeleteTcsMock(tcs[0]) {
+		t.Fatalf("failed to consume the first mock")
+	}
+	snapshot, err := h.SnapshotMatcherState()
+	if err != nil {
+		t.Fatalf("failed to snapshot the matcher state: %v", err)
+	}
+
+	// the replay goes on past the snapshot
+	tcs, _ = h.GetTcsMocks()
+	config, _ := h.GetConfigMocks()
+	if !h.DeleteTcsMock(tcs[0]) || !h.DeleteConfigMock(config[0]) {
+		t.Fatalf("failed to consume the mocks following the snapshot")
+	}
+
+	if err := h.RestoreMatcherState(snapshot); err != nil {
+		t.Fatalf("failed to restore the matcher state: %v", err)
+	}
+	tcs, _ = h.GetTcsMocks()
+	config, _ = h.GetConfigMocks()
+	if names := mockNames(tcs); len(names) != 2 || names[0] != "mock-1" || names[1] != "mock-2" {
+		t.Fatalf("left the mocks %v to match after the restore, want mock-1 and mock-2", names)
+	}
+	if names := mockNames(config); len(names) != 1 || names[0] != "config-0" {
+		t.Fatalf("left the config mocks %v to match after the restore, want config-0", names)
+	}
+	if !h.IsMockConsumed("mock-0") || h.IsMockConsumed("mock-1") || h.IsMockConsumed("config-0") {
+		t.Fatalf("restored the consumed mocks %v, want mock-0 only", h.consumedMocks)
+	}
+
+	// the replay matches the same mock again
+	if !h.DeleteTcsMock(tcs[0]) || tcs[0].Name != "mock-1" {
+		t.Fatalf("failed to consume mock-1 again after the restore")
+	}
+	again, err := h.SnapshotMatcherState()
+	if err != nil {
+		t.Fatalf("failed to snapshot the matcher state: %v", err)
+	}
+	if err := h.RestoreMatcherState(snapshot); err != nil {
+		t.Fatalf("failed to restore the matcher state twice: %v", err)
+	}
+	if string(again) == string(snapshot) {
+		t.Fatalf("the matcher state didn't advance with the consumed mock")
+	}
+}
+
+func TestRestoreMatcherStateOfOtherMocks(t *testing.T) {
+	h, err := NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetTcsMocks([]*models.Mock{{Name: "mock-0"}})
+	snapshot, err := h.SnapshotMatcherState()
+	if err != nil {
+		t.Fatalf("failed to snapshot the matcher state: %v", err)
+	}
+
+	h.SetTcsMocks([]*models.Mock{{Name: "other-0"}})
+	if err := h.RestoreMatcherState(snapshot); err == nil {
+		t.Fatalf("restored the state of mocks which are not loaded")
+	}
+	if tcs, _ := h.GetTcsMocks(); len(tcs) != 1 || tcs[0].Name != "other-0" {
+		t.Fatalf("replaced the loaded mocks with %v", mockNames(tcs))
+	}
+	if err := h.RestoreMatcherState([]byte("{")); err == nil {
+		t.Fatalf("restored a malformed matcher state")
+	}
+}
@@ -8,6 +8,8 @@ record:
   # mandatory
   command: ""
   proxyport: 0
+  # UDP port of the HTTP/3 proxy, such as 443. 0 leaves the HTTP/3 calls unintercepted
+  http3Port: 0
   containerName: ""
   networkName: ""
   delay: 5
@@ -28,6 +30,8 @@ test:
   # mandatory
   command: ""
   proxyport: 0
+  # UDP port of the HTTP/3 proxy, such as 443. 0 leaves the HTTP/3 calls unintercepted
+  http3Port: 0
   containerName: ""
   networkName: ""
   # example: "test-set-1": ["test-1", "test-2", "test-3"]
@@ -41,6 +45,7 @@ test:
   buildDelay: 30s
   apiTimeout: 5
   ignoreOrdering: false
+  divergenceThreshold: 0
   stubs:
     filters:
       - path: ""
@@ -60,12 +65,57 @@ type Record struct {
 	Path          string        `json:"path" yaml:"path"`
 	Command       string        `json:"command" yaml:"command"`
 	ProxyPort     uint32        `json:"proxyport" yaml:"proxyport"`
+	Http3Port     uint32        `json:"http3Port,omitempty" yaml:"http3Port,omitempty"` // UDP port of the HTTP/3 proxy, which is off when 0
 	ContainerName string        `json:"containerName" yaml:"containerName"`
 	NetworkName   string        `json:"networkName" yaml:"networkName"`
 	Delay         uint64        `json:"delay" yaml:"delay"`
 	BuildDelay    time.Duration `json:"buildDelay" yaml:"buildDelay"`
 	Tests         TestFilter    `json:"tests" yaml:"tests"`
 	Stubs         Stubs         `json:"stubs" yaml:"stubs"`
+	// MockFormat is the format of the recorded mock files, yaml by default.
+	MockFormat string `json:"mockFormat,omitempty" yaml:"mockFormat,omitempty"`
+	// MockLayout is the layout of the recorded mocks, flat by default or
+	// content-addressed to store each mock once for all the test-sets.
+	MockLayout string `json:"mockLayout,omitempty" yaml:"mockLayout,omitempty"`
+	// PostgresNoticePolicy is either keep (default) or drop to leave the
+	// NoticeResponse messages out of the postgres mocks.
+	PostgresNoticePolicy string `json:"postgresNoticePolicy,omitempty" yaml:"postgresNoticePolicy,omitempty"`
+	// PostgresMaxSessionDuration flushes the recorded postgres sessions at
+	// this interval, stopping their recording unless PostgresContinueSession
+	// is set.
+	PostgresMaxSessionDuration time.Duration `json:"postgresMaxSessionDuration,omitempty" yaml:"postgresMaxSessionDuration,omitempty"`
+	PostgresContinueSession    bool          `json:"postgresContinueSession,omitempty" yaml:"postgresContinueSession,omitempty"`
+	// DisabledIntegrations are the integrations (mysql, postgres, mongo,
+	// redis, http, grpc) whose calls are not recorded.
+	DisabledIntegrations []string `json:"disabledIntegrations,omitempty" yaml:"disabledIntegrations,omitempty"`
+	// PostgresStalenessTolerance maps the postgres columns read from a
+	// replica to the largest difference accepted between their recorded and
+	// live values.
+	PostgresStalenessTolerance map[string]float64 `json:"postgresStalenessTolerance,omitempty" yaml:"postgresStalenessTolerance,omitempty"`
+	// PostgresMaxDataRows caps the rows of a postgres response recorded in a
+	// readable form, every row is recorded when zero.
+	PostgresMaxDataRows int `json:"postgresMaxDataRows,omitempty" yaml:"postgresMaxDataRows,omitempty"`
+	// PostgresPassthroughSSL forwards the SSL sessions of the postgres
+	// clients to the server instead of decrypting them with the CA of keploy.
+	PostgresPassthroughSSL bool `json:"postgresPassthroughSSL,omitempty" yaml:"postgresPassthroughSSL,omitempty"`
+	// PassThroughRawTCP forwards the connections of the unsupported protocols
+	// unrecorded, they are recorded as generic mocks otherwise.
+	PassThroughRawTCP bool `json:"passThroughRawTCP,omitempty" yaml:"passThroughRawTCP,omitempty"`
+	// PostgresMaxPendingBytes caps the size of the incomplete postgres
+	// messages of a connection kept in memory, 64MB when zero.
+	PostgresMaxPendingBytes int `json:"postgresMaxPendingBytes,omitempty" yaml:"postgresMaxPendingBytes,omitempty"`
+	// PostgresRecordingWarmup leaves out the postgres exchanges of every
+	// connection until its duration or count of exchanges is over.
+	PostgresRecordingWarmup RecordingWarmup `json:"postgresRecordingWarmup,omitempty" yaml:"postgresRecordingWarmup,omitempty"`
+	// PostgresIgnoredFields are the postgres values generated anew on every
+	// run, left out of the matching and of the comparisons of the responses.
+	PostgresIgnoredFields IgnoredFields `json:"postgresIgnoredFields,omitempty" yaml:"postgresIgnoredFields,omitempty"`
+	// PostgresDriftTestSet is the recorded test-set whose postgres mocks the
+	// live exchanges are compared with, to report the drift of the database.
+	PostgresDriftTestSet string `json:"postgresDriftTestSet,omitempty" yaml:"postgresDriftTestSet,omitempty"`
+	// PostgresStripSchemas are the schemas whose qualifiers are left out of
+	// the recorded postgres queries, and of the replayed ones to match them.
+	PostgresStripSchemas []string `json:"postgresStripSchemas,omitempty" yaml:"postgresStripSchemas,omitempty"`
 }
 
 type TestFilter struct {
@@ -74,6 +124,10 @@ type TestFilter struct {
 
 type Stubs struct {
 	Filters []Filters `json:"filters" yaml:"filters"`
+	// HeaderPredicates scope the HTTP and gRPC mocks to the requests whose
+	// headers satisfy all of them. They apply to the mocks replayed in test
+	// mode.
+	HeaderPredicates []HeaderPredicate `json:"headerPredicates,omitempty" yaml:"headerPredicates,omitempty"`
 }
 type Filters struct {
 	Path       string            `json:"path" yaml:"path"`
@@ -91,6 +145,7 @@ type Test struct {
 	Path                    string              `json:"path" yaml:"path"`
 	Command                 string              `json:"command" yaml:"command"`
 	ProxyPort               uint32              `json:"proxyport" yaml:"proxyport"`
+	Http3Port               uint32              `json:"http3Port,omitempty" yaml:"http3Port,omitempty"` // UDP port of the HTTP/3 proxy, which is off when 0
 	ContainerName           string              `json:"containerName" yaml:"containerName"`
 	NetworkName             string              `json:"networkName" yaml:"networkName"`
 	SelectedTests           map[string][]string `json:"selectedTests" yaml:"selectedTests"`
@@ -105,6 +160,94 @@ type Test struct {
 	GenerateTestReport      bool                `json:"generateTestReport" yaml:"generateTestReport"`
 	IgnoreOrdering          bool                `json:"ignoreOrdering" yaml:"ignoreOrdering"`
 	Stubs                   Stubs               `json:"stubs" yaml:"stubs"`
+	DivergenceThreshold     float64             `json:"divergenceThreshold" yaml:"divergenceThreshold"` // rate of the dependency calls matching none of the mocks above which the test run fails, 0 disables the check
+	// PostgresReadTimeout is how long the requests of the postgres clients
+	// are read before they are matched with the mocks, 10ms when zero.
+	PostgresReadTimeout time.Duration `json:"postgresReadTimeout,omitempty" yaml:"postgresReadTimeout,omitempty"`
+	// ReportFormat is the format of the test reports, yaml or junit.
+	ReportFormat TestReportFormat `json:"reportFormat,omitempty" yaml:"reportFormat,omitempty"`
+	// PostgresNoticePolicy is either keep (default) or drop to leave the
+	// NoticeResponse messages out of the replayed postgres responses.
+	PostgresNoticePolicy string `json:"postgresNoticePolicy,omitempty" yaml:"postgresNoticePolicy,omitempty"`
+	// PostgresMinSimilarity is the lowest similarity with a mock, between 0
+	// and 1, accepted for the postgres requests without an exact match.
+	PostgresMinSimilarity float64 `json:"postgresMinSimilarity,omitempty" yaml:"postgresMinSimilarity,omitempty"`
+	// DisabledIntegrations are the integrations (mysql, postgres, mongo,
+	// redis, http, grpc) whose calls are not replayed.
+	DisabledIntegrations []string `json:"disabledIntegrations,omitempty" yaml:"disabledIntegrations,omitempty"`
+	// MySQLGreetingCapabilities masks the capability flags advertised by the
+	// replayed MySQL server greeting, the recorded ones are kept when zero.
+	MySQLGreetingCapabilities uint32 `json:"mysqlGreetingCapabilities,omitempty" yaml:"mysqlGreetingCapabilities,omitempty"`
+	// MySQLOKNoise are the fields of the MySQL OK packets, such as the last
+	// insert id, which differ between the recording and the replay.
+	MySQLOKNoise MySQLOKNoise `json:"mysqlOKNoise,omitempty" yaml:"mysqlOKNoise,omitempty"`
+	// PostgresPaceCopyOut replays the chunks of the postgres COPY OUT streams
+	// at the pace they were recorded at.
+	PostgresPaceCopyOut bool `json:"postgresPaceCopyOut,omitempty" yaml:"postgresPaceCopyOut,omitempty"`
+	// PostgresPassthroughSSL forwards the SSL sessions of the postgres
+	// clients to the server instead of decrypting them with the CA of keploy.
+	PostgresPassthroughSSL bool `json:"postgresPassthroughSSL,omitempty" yaml:"postgresPassthroughSSL,omitempty"`
+	// PostgresReplayJitter delays the replayed postgres responses by a random
+	// duration, to simulate a dependency whose latency varies.
+	PostgresReplayJitter ReplayJitter `json:"postgresReplayJitter,omitempty" yaml:"postgresReplayJitter,omitempty"`
+	// PostgresMatchMode is either exact (default), normalized or template to
+	// match the postgres statements recorded with other values as a fallback.
+	PostgresMatchMode string `json:"postgresMatchMode,omitempty" yaml:"postgresMatchMode,omitempty"`
+	// PostgresPaceNotifications replays the postgres notifications pushed
+	// while the client was idle after the delays they were recorded with.
+	PostgresPaceNotifications bool `json:"postgresPaceNotifications,omitempty" yaml:"postgresPaceNotifications,omitempty"`
+	// PostgresPassword is the password of the postgres user, to replay the
+	// SCRAM and MD5 authentications of the clients.
+	PostgresPassword string `json:"postgresPassword,omitempty" yaml:"postgresPassword,omitempty"`
+	// PostgresSkipAuthentication lets the postgres clients in without
+	// replaying their authentication.
+	PostgresSkipAuthentication bool `json:"postgresSkipAuthentication,omitempty" yaml:"postgresSkipAuthentication,omitempty"`
+	// PostgresMaxPendingBytes caps the size of the incomplete postgres
+	// messages of a connection kept in memory, 64MB when zero.
+	PostgresMaxPendingBytes int `json:"postgresMaxPendingBytes,omitempty" yaml:"postgresMaxPendingBytes,omitempty"`
+	// PostgresIgnoredFields are the postgres values generated anew on every
+	// run, left out of the matching and of the comparisons of the responses.
+	PostgresIgnoredFields IgnoredFields `json:"postgresIgnoredFields,omitempty" yaml:"postgresIgnoredFields,omitempty"`
+	// PostgresReplayLatency delays the replayed postgres responses by the
+	// latency they were recorded with.
+	PostgresReplayLatency bool `json:"postgresReplayLatency,omitempty" yaml:"postgresReplayLatency,omitempty"`
+	// PostgresMaxReplayLatency caps the replayed latency of the postgres
+	// responses, 5s when zero.
+	PostgresMaxReplayLatency time.Duration `json:"postgresMaxReplayLatency,omitempty" yaml:"postgresMaxReplayLatency,omitempty"`
+	// PostgresStripSchemas are the schemas whose qualifiers are left out of
+	// the recorded postgres queries, and of the replayed ones to match them.
+	PostgresStripSchemas []string `json:"postgresStripSchemas,omitempty" yaml:"postgresStripSchemas,omitempty"`
+	// PostgresStalenessTolerance maps the postgres columns read from a
+	// replica to the largest difference accepted between their recorded
+	// values and the live ones of the requests passed through.
+	PostgresStalenessTolerance map[string]float64 `json:"postgresStalenessTolerance,omitempty" yaml:"postgresStalenessTolerance,omitempty"`
+}
+
+// ReplayJitter is the distribution the delays of the replayed responses are
+// drawn from, uniform between Min and Max or normal around Mean.
+type ReplayJitter struct {
+	Distribution string        `json:"distribution" yaml:"distribution"`
+	Min          time.Duration `json:"min,omitempty" yaml:"min,omitempty"`
+	Max          time.Duration `json:"max,omitempty" yaml:"max,omitempty"`
+	Mean         time.Duration `json:"mean,omitempty" yaml:"mean,omitempty"`
+	StdDev       time.Duration `json:"stdDev,omitempty" yaml:"stdDev,omitempty"`
+}
+
+// RecordingWarmup is how long after its startup, and for how many exchanges,
+// a connection is left unrecorded.
+type RecordingWarmup struct {
+	Duration time.Duration `json:"duration,omitempty" yaml:"duration,omitempty"`
+	Requests int           `json:"requests,omitempty" yaml:"requests,omitempty"`
+}
+
+// IgnoredFields are the postgres values ignored when the requests are matched
+// and the responses compared, by column, by parameter of a query or by field
+// of a message.
+type IgnoredFields struct {
+	Columns       []string         `json:"columns,omitempty" yaml:"columns,omitempty"`
+	ColumnIndexes []int            `json:"columnIndexes,omitempty" yaml:"columnIndexes,omitempty"`
+	Parameters    map[string][]int `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	MessageFields []string         `json:"messageFields,omitempty" yaml:"messageFields,omitempty"`
 }
 
 type Globalnoise struct {
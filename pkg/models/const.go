@@ -13,6 +13,9 @@ const (
 	TestSetPattern      string = "test-set-"
 	String              string = "string"
 	TestRunTemplateName string = "test-run-"
+	// IntrospectionTestSet holds the mocks of the schema introspection
+	// recorded apart from the test-sets. It is not listed as a test-set.
+	IntrospectionTestSet string = TestSetPattern + "introspection"
 )
 
 var (
@@ -129,12 +132,12 @@ const (
 	CLIENT_IGNORE_SPACE
 	CLIENT_PROTOCOL_41
 	CLIENT_INTERACTIVE
-	CLIENT_SSL = 0x00000800
+	CLIENT_SSL
 	CLIENT_IGNORE_SIGPIPE
 	CLIENT_TRANSACTIONS
 	CLIENT_RESERVED
 	CLIENT_SECURE_CONNECTION
-	CLIENT_MULTI_STATEMENTS = 1 << (iota + 2)
+	CLIENT_MULTI_STATEMENTS
 	CLIENT_MULTI_RESULTS
 	CLIENT_PS_MULTI_RESULTS
 	CLIENT_PLUGIN_AUTH
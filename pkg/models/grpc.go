@@ -14,12 +14,18 @@ type GrpcLengthPrefixedMessage struct {
 type GrpcReq struct {
 	Headers GrpcHeaders               `json:"headers" yaml:"headers"`
 	Body    GrpcLengthPrefixedMessage `json:"body" yaml:"body"`
+	// Messages are the messages streamed after the Body, by the streaming
+	// calls.
+	Messages []GrpcLengthPrefixedMessage `json:"messages,omitempty" yaml:"messages,omitempty"`
 }
 
 type GrpcResp struct {
 	Headers  GrpcHeaders               `json:"headers" yaml:"headers"`
 	Body     GrpcLengthPrefixedMessage `json:"body" yaml:"body"`
 	Trailers GrpcHeaders               `json:"trailers" yaml:"trailers"`
+	// Messages are the messages streamed after the Body, by the streaming
+	// calls.
+	Messages []GrpcLengthPrefixedMessage `json:"messages,omitempty" yaml:"messages,omitempty"`
 }
 
 // GrpcStream is a helper function to combine the request-response model in a single struct.
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// HeaderPredicate scopes the mocks of the header based protocols (HTTP and
+// gRPC) to the requests carrying a particular header. A predicate with
+// neither Value nor Regex only requires the header to be present.
+type HeaderPredicate struct {
+	Name  string `json:"name" yaml:"name"`
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	Regex string `json:"regex,omitempty" yaml:"regex,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// CompileHeaderPredicates compiles the regexes of the predicates once, for
+// Match to reuse them. It returns an error for an invalid regex.
+func CompileHeaderPredicates(predicates []HeaderPredicate) error {
+	for i := range predicates {
+		if predicates[i].Regex == "" {
+			continue
+		}
+		regex, err := regexp.Compile(predicates[i].Regex)
+		if err != nil {
+			return fmt.Errorf("invalid regex of the predicate of the %s header: %v", predicates[i].Name, err)
+		}
+		predicates[i].regex = regex
+	}
+	return nil
+}
+
+// Match reports whether the header value satisfies the predicate. present
+// tells whether the header was sent at all. The regex of a predicate not
+// compiled with CompileHeaderPredicates is compiled on every call.
+func (p HeaderPredicate) Match(value string, present bool) bool {
+	if !present {
+		return false
+	}
+	if p.Value != "" && value != p.Value {
+		return false
+	}
+	if p.Regex != "" {
+		regex := p.regex
+		if regex == nil {
+			var err error
+			if regex, err = regexp.Compile(p.Regex); err != nil {
+				return false
+			}
+		}
+		if !regex.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchHeaderMap reports whether the headers satisfy every predicate. The
+// header names are compared case-insensitively.
+func MatchHeaderMap(predicates []HeaderPredicate, headers map[string]string) bool {
+	for _, p := range predicates {
+		value, present := "", false
+		for k, v := range headers {
+			if strings.EqualFold(k, p.Name) {
+				value, present = v, true
+				break
+			}
+		}
+		if !p.Match(value, present) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchHTTPHeader is MatchHeaderMap for the multi-valued http.Header, where
+// a predicate is satisfied by any of the values of the header.
+func MatchHTTPHeader(predicates []HeaderPredicate, headers http.Header) bool {
+	for _, p := range predicates {
+		values, present := headers[http.CanonicalHeaderKey(p.Name)]
+		matched := false
+		for _, v := range values {
+			if p.Match(v, present) {
+				matched = true
+				break
+			}
+		}
+		if !matched && !(len(values) == 0 && p.Match("", present)) {
+			return false
+		}
+	}
+	return true
+}
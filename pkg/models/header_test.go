@@ -0,0 +1,29 @@
+package models
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestCompileHeaderPredicates(t *testing.T) {
+	predicates := []HeaderPredicate{{Name: "x-tenant", Regex: "^acme-[0-9]+$"}, {Name: "authorization"}}
+	if err := CompileHeaderPredicates(predicates); err != nil {
+		t.Fatalf("failed to compile the predicates: %v", err)
+	}
+	if predicates[0].regex == nil {
+		t.Fatalf("left the regex of the predicate uncompiled")
+	}
+	if !MatchHeaderMap(predicates, map[string]string{"X-Tenant": "acme-42", "Authorization": "Bearer token"}) {
+		t.Fatalf("rejected the headers satisfying the predicates")
+	}
+	if MatchHeaderMap(predicates, map[string]string{"X-Tenant": "globex-42", "Authorization": "Bearer token"}) {
+		t.Fatalf("accepted the tenant not matching the regex")
+	}
+	if MatchHTTPHeader(predicates, http.Header{"X-Tenant": {"acme-42"}}) {
+		t.Fatalf("accepted the headers without the authorization")
+	}
+
+	if err := CompileHeaderPredicates([]HeaderPredicate{{Name: "x-tenant", Regex: "acme-("}}); err == nil {
+		t.Fatalf("compiled the invalid regex, want an error")
+	}
+}
@@ -1,6 +1,11 @@
 package models
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
 
 type Mock struct {
 	Version      Version      `json:"Version,omitempty" bson:"Version,omitempty"`
@@ -20,6 +25,205 @@ func (m *Mock) GetKind() string {
 	return string(m.Kind)
 }
 
+// Clone returns a copy of the mock along with the requests, responses and
+// metadata of its spec, which the matchers update in place, so that the copy
+// is left untouched by the matching of the mock.
+func (m *Mock) Clone() *Mock {
+	clone := *m
+	spec := &clone.Spec
+	if spec.Metadata != nil {
+		spec.Metadata = make(map[string]string, len(m.Spec.Metadata))
+		for key, value := range m.Spec.Metadata {
+			spec.Metadata[key] = value
+		}
+	}
+	spec.GenericRequests = append([]GenericPayload(nil), spec.GenericRequests...)
+	spec.GenericResponses = append([]GenericPayload(nil), spec.GenericResponses...)
+	spec.MongoRequests = append([]MongoRequest(nil), spec.MongoRequests...)
+	spec.MongoResponses = append([]MongoResponse(nil), spec.MongoResponses...)
+	spec.PostgresRequests = append([]Backend(nil), spec.PostgresRequests...)
+	spec.PostgresResponses = append([]Frontend(nil), spec.PostgresResponses...)
+	spec.MySqlRequests = append([]MySQLRequest(nil), spec.MySqlRequests...)
+	spec.MySqlResponses = append([]MySQLResponse(nil), spec.MySqlResponses...)
+	spec.RedisRequests = append([]RedisRequest(nil), spec.RedisRequests...)
+	spec.RedisResponses = append([]RedisReply(nil), spec.RedisResponses...)
+	if spec.HttpReq != nil {
+		req := *spec.HttpReq
+		spec.HttpReq = &req
+	}
+	if spec.HttpResp != nil {
+		resp := *spec.HttpResp
+		spec.HttpResp = &resp
+	}
+	if spec.GRPCReq != nil {
+		req := *spec.GRPCReq
+		spec.GRPCReq = &req
+	}
+	if spec.GRPCResp != nil {
+		resp := *spec.GRPCResp
+		spec.GRPCResp = &resp
+	}
+	return &clone
+}
+
+// ContentHash returns a digest of the recorded request and response of the
+// mock. The name, test mode info, timestamps, disabled flag and volatile
+// metadata, see VolatileMetadata, are left out so that the same interaction
+// recorded twice results in the same hash.
+func (m *Mock) ContentHash() string {
+	return hashMockSpec(m.Kind, m.Spec)
+}
+
+// ContentHashIgnoring returns the ContentHash of the mock with the noisy fields
+// of its MySQL OK packets left out, so that the same statement recorded with
+// another auto-increment id results in the same hash.
+func (m *Mock) ContentHashIgnoring(noise MySQLOKNoise) string {
+	spec := m.Spec
+	spec.MySqlResponses = noise.withoutNoise(spec.MySqlResponses)
+	return hashMockSpec(m.Kind, spec)
+}
+
+// RequestHash returns a digest of the recorded request of the mock only.
+func (m *Mock) RequestHash() string {
+	spec := m.Spec
+	spec.GenericResponses = nil
+	spec.HttpResp = nil
+	spec.MongoResponses = nil
+	spec.PostgresResponses = nil
+	spec.GRPCResp = nil
+	spec.MySqlResponses = nil
+	spec.RedisResponses = nil
+	return hashMockSpec(m.Kind, spec)
+}
+
+func hashMockSpec(kind Kind, spec MockSpec) string {
+	spec.Created = 0
+	spec.ReqTimestampMock = time.Time{}
+	spec.ResTimestampMock = time.Time{}
+	spec.Disabled = false
+	spec.Metadata = withoutVolatileMetadata(spec.Metadata)
+	data, err := json.Marshal(struct {
+		Kind Kind
+		Spec MockSpec
+	}{kind, spec})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// withoutVolatileMetadata returns a copy of the metadata without the
+// VolatileMetadata keys, or the metadata itself when it has none of them.
+func withoutVolatileMetadata(metadata map[string]string) map[string]string {
+	var stable map[string]string
+	for _, key := range VolatileMetadata {
+		if _, ok := metadata[key]; !ok {
+			continue
+		}
+		if stable == nil {
+			stable = make(map[string]string, len(metadata))
+			for k, v := range metadata {
+				stable[k] = v
+			}
+		}
+		delete(stable, key)
+	}
+	if stable == nil {
+		return metadata
+	}
+	return stable
+}
+
+// MockMergeReport summarises the merge of the mocks of a test-set into another.
+type MockMergeReport struct {
+	Merged     int            `json:"merged" yaml:"merged"`
+	Duplicates int            `json:"duplicates" yaml:"duplicates"`
+	Conflicts  []MockConflict `json:"conflicts,omitempty" yaml:"conflicts,omitempty"`
+}
+
+// MockConflict records two mocks having the same request but different responses.
+type MockConflict struct {
+	Kind     Kind   `json:"kind" yaml:"kind"`
+	Existing string `json:"existing" yaml:"existing"`
+	Incoming string `json:"incoming" yaml:"incoming"`
+}
+
+func (r *MockMergeReport) GetKind() string {
+	return "MockMergeReport"
+}
+
+// MockFilter selects the mocks read with ReadMocks. The zero value selects all
+// the mocks.
+type MockFilter struct {
+	// Kinds are the kinds of the selected mocks, any kind when empty.
+	Kinds []Kind
+	// PostgresPacketTypes selects the postgres mocks having a request with at
+	// least one of these message types, such as "Q" for the simple queries.
+	PostgresPacketTypes []string
+	// Match is called with the mocks selected by the other fields, and
+	// selects those for which it returns true.
+	Match func(mock *Mock) bool
+}
+
+func (f *MockFilter) GetKind() string {
+	return "MockFilter"
+}
+
+// Selects reports whether the filter selects the mock.
+func (f *MockFilter) Selects(mock *Mock) bool {
+	if len(f.Kinds) > 0 {
+		found := false
+		for _, kind := range f.Kinds {
+			if mock.Kind == kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.PostgresPacketTypes) > 0 && !hasPostgresPacketType(mock, f.PostgresPacketTypes) {
+		return false
+	}
+	return f.Match == nil || f.Match(mock)
+}
+
+func hasPostgresPacketType(mock *Mock, packetTypes []string) bool {
+	if mock.Kind != Postgres {
+		return false
+	}
+	for _, request := range mock.Spec.PostgresRequests {
+		for _, recorded := range request.PacketTypes {
+			for _, packetType := range packetTypes {
+				if recorded == packetType {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// MockValidationReport lists the problems found in the mocks of a test-set.
+type MockValidationReport struct {
+	Issues []MockIssue `json:"issues,omitempty" yaml:"issues,omitempty"`
+}
+
+// MockIssue locates a mock which cannot be replayed as recorded.
+type MockIssue struct {
+	File    string `json:"file" yaml:"file"`
+	Index   int    `json:"index" yaml:"index"`
+	Name    string `json:"name" yaml:"name"`
+	Kind    Kind   `json:"kind" yaml:"kind"`
+	Problem string `json:"problem" yaml:"problem"`
+}
+
+func (r *MockValidationReport) GetKind() string {
+	return "MockValidationReport"
+}
+
 type MockSpec struct {
 	Metadata          map[string]string `json:"Metadata,omitempty" bson:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
 	GenericRequests   []GenericPayload  `json:"RequestBin,omitempty" bson:"generic_requests,omitempty"`
@@ -37,6 +241,12 @@ type MockSpec struct {
 	MySqlResponses    []MySQLResponse   `json:"MySqlResponses,omitempty" bson:"my_sql_responses,omitempty"`
 	ReqTimestampMock  time.Time         `json:"ReqTimestampMock,omitempty" bson:"req_timestamp_mock,omitempty"`
 	ResTimestampMock  time.Time         `json:"ResTimestampMock,omitempty" bson:"res_timestamp_mock,omitempty"`
+	// RedisRequests are the commands of a Redis mock, answered in order by
+	// the RedisResponses.
+	RedisRequests  []RedisRequest `json:"RedisRequests,omitempty" bson:"redis_requests,omitempty"`
+	RedisResponses []RedisReply   `json:"RedisResponses,omitempty" bson:"redis_responses,omitempty"`
+	// Disabled keeps the mock on disk while excluding it from matching.
+	Disabled bool `json:"Disabled,omitempty" bson:"disabled,omitempty"`
 }
 
 // OutputBinary store the encoded binary output of the egress calls as base64-encoded strings
@@ -45,6 +255,18 @@ type OutputBinary struct {
 	Data string `json:"data" bson:"data" yaml:"data"`
 }
 
+// TestNameMetadata is the metadata key holding the name of the test case
+// which drove the recording of a mock.
+const TestNameMetadata = "test_name"
+
+// ConnectionIDMetadata is the metadata key holding the id the server gave to
+// the connection a mock was recorded over.
+const ConnectionIDMetadata = "connectionId"
+
+// VolatileMetadata are the metadata keys which differ between two recordings
+// of the same interaction.
+var VolatileMetadata = []string{TestNameMetadata, ConnectionIDMetadata}
+
 type OriginType string
 
 const (
@@ -20,6 +20,7 @@ type RowColumnDefinition struct {
 	Type  FieldType   `json:"type,omitempty" yaml:"type,omitempty,flow" bson:"type,omitempty"`
 	Name  string      `json:"name,omitempty" yaml:"name,omitempty,flow" bson:"name,omitempty"`
 	Value interface{} `json:"value,omitempty" yaml:"value,omitempty,flow" bson:"value,omitempty"`
+	Null  bool        `json:"null,omitempty" yaml:"null,omitempty,flow" bson:"null,omitempty"`
 }
 
 // func (r *RowColumnDefinition) UnmarshalBSON(data []byte) error
@@ -67,6 +68,10 @@ type MySQLHandshakeResponse struct {
 	AuthData        string `json:"auth_data,omitempty" yaml:"auth_data,omitempty,flow" bson:"auth_data,omitempty"`
 	Database        string `json:"database,omitempty" yaml:"database,omitempty,flow" bson:"database,omitempty"`
 	AuthPluginName  string `json:"auth_plugin_name,omitempty" yaml:"auth_plugin_name,omitempty,flow" bson:"auth_plugin_name,omitempty"`
+	// ConnectAttributes are the attributes of the connection sent by the
+	// clients, such as _client_name, _client_version and program_name.
+	ConnectAttributes    map[string]string `json:"connect_attributes,omitempty" yaml:"connect_attributes,omitempty,flow" bson:"connect_attributes,omitempty"`
+	ZstdCompressionLevel byte              `json:"zstdcompressionlevel,omitempty" yaml:"zstdcompressionlevel,omitempty,flow" bson:"zstdcompressionlevel,omitempty"`
 }
 
 type MySQLQueryPacket struct {
@@ -81,12 +86,17 @@ type MySQLComStmtExecute struct {
 	NullBitmap     string           `json:"null_bitmap,omitempty" yaml:"null_bitmap,omitempty,flow" bson:"null_bitmap,omitempty"`
 	ParamCount     uint16           `json:"param_count,omitempty" yaml:"param_count,omitempty,flow" bson:"param_count,omitempty"`
 	Parameters     []BoundParameter `json:"parameters,omitempty" yaml:"parameters,omitempty,flow" bson:"parameters,omitempty"`
+	Query          string           `json:"query,omitempty" yaml:"query,omitempty,flow" bson:"query,omitempty"`
 }
 
+// BoundParameter is a parameter of a prepared statement with its MySQL type
+// code, the flag byte sent along (0x80 for unsigned) and its readable Text.
 type BoundParameter struct {
 	Type     byte   `json:"type,omitempty" yaml:"type,omitempty,flow" bson:"type,omitempty"`
 	Unsigned byte   `json:"unsigned,omitempty" yaml:"unsigned,omitempty,flow" bson:"unsigned,omitempty"`
+	Null     bool   `json:"null,omitempty" yaml:"null,omitempty,flow" bson:"null,omitempty"`
 	Value    []byte `json:"value,omitempty" yaml:"value,omitempty,flow" bson:"value,omitempty"`
+	Text     string `json:"text,omitempty" yaml:"text,omitempty,flow" bson:"text,omitempty"`
 }
 
 type MySQLStmtPrepareOk struct {
@@ -108,6 +118,7 @@ type MySQLResultSet struct {
 	OptionalPadding     bool                `json:"optionalPadding,omitempty" yaml:"optionalPadding,omitempty,flow" bson:"optionalPadding,omitempty"`
 	OptionalEOFBytes    string              `json:"optionalEOFBytes,omitempty" yaml:"optionalEOFBytes,omitempty,flow" bson:"optionalEOFBytes,omitempty"`
 	EOFAfterColumns     string              `json:"eofAfterColumns,omitempty" yaml:"eofAfterColumns,omitempty,flow" bson:"eofAfterColumns,omitempty"`
+	Binary              bool                `json:"binary,omitempty" yaml:"binary,omitempty,flow" bson:"binary,omitempty"`
 }
 
 type PacketHeader struct {
@@ -116,8 +127,8 @@ type PacketHeader struct {
 }
 
 type RowHeader struct {
-	PacketLength     uint8 `json:"packet_length,omitempty" yaml:"packet_length,omitempty,flow" bson:"packet_length,omitempty"`
-	PacketSequenceId uint8 `json:"packet_sequence_id,omitempty" yaml:"packet_sequence_id,omitempty,flow" bson:"packet_sequence_id,omitempty"`
+	PacketLength     uint32 `json:"packet_length,omitempty" yaml:"packet_length,omitempty,flow" bson:"packet_length,omitempty"`
+	PacketSequenceId uint8  `json:"packet_sequence_id,omitempty" yaml:"packet_sequence_id,omitempty,flow" bson:"packet_sequence_id,omitempty"`
 }
 
 type ColumnDefinition struct {
@@ -149,6 +160,41 @@ type MySQLOKPacket struct {
 	Info         string `json:"info,omitempty" yaml:"info,omitempty,flow" bson:"info,omitempty"`
 }
 
+// MySQLOKNoise are the fields of the recorded OK packets which are generated
+// anew on every run, such as the auto-increment id of an INSERT. They are
+// left out when the mocks are compared, and are replayed as recorded. The
+// requests carrying the last insert id of their connection match the mocks
+// recorded with another one when LastInsertID is set.
+type MySQLOKNoise struct {
+	AffectedRows bool `json:"affectedRows,omitempty" yaml:"affectedRows,omitempty"`
+	LastInsertID bool `json:"lastInsertId,omitempty" yaml:"lastInsertId,omitempty"`
+}
+
+// withoutNoise returns the responses with the noisy fields of their OK packets
+// zeroed. The responses are returned as is when there is no noise.
+func (n MySQLOKNoise) withoutNoise(responses []MySQLResponse) []MySQLResponse {
+	if !n.AffectedRows && !n.LastInsertID {
+		return responses
+	}
+	cleared := make([]MySQLResponse, len(responses))
+	for i, response := range responses {
+		cleared[i] = response
+		ok, isOK := response.Message.(*MySQLOKPacket)
+		if !isOK || ok == nil {
+			continue
+		}
+		packet := *ok
+		if n.AffectedRows {
+			packet.AffectedRows = 0
+		}
+		if n.LastInsertID {
+			packet.LastInsertID = 0
+		}
+		cleared[i].Message = &packet
+	}
+	return cleared
+}
+
 type MySQLERRPacket struct {
 	Header         byte   `json:"header,omitempty" yaml:"header,omitempty,flow" bson:"header,omitempty"`
 	ErrorCode      uint16 `json:"error_code,omitempty" yaml:"error_code,omitempty,flow" bson:"error_code,omitempty"`
@@ -171,6 +217,16 @@ type MySQLCOM_STMT_RESET struct {
 	StatementID uint32 `json:"statement_id,omitempty" yaml:"statement_id,omitempty,flow" bson:"statement_id,omitempty"`
 }
 
+type MySQLComSetOptionPacket struct {
+	Option uint16 `json:"option" yaml:"option,flow" bson:"option"`
+}
+
+type MySQLEOFPacket struct {
+	Header      byte   `json:"header,omitempty" yaml:"header,omitempty,flow" bson:"header,omitempty"`
+	Warnings    uint16 `json:"warnings,omitempty" yaml:"warnings,omitempty,flow" bson:"warnings,omitempty"`
+	StatusFlags uint16 `json:"status_flags,omitempty" yaml:"status_flags,omitempty,flow" bson:"status_flags,omitempty"`
+}
+
 type MySQLComStmtFetchPacket struct {
 	StatementID uint32 `json:"statement_id,omitempty" yaml:"statement_id,omitempty,flow" bson:"statement_id,omitempty"`
 	RowCount    uint32 `json:"row_count,omitempty" yaml:"row_count,omitempty,flow" bson:"row_count,omitempty"`
@@ -197,4 +253,7 @@ type AuthSwitchRequestPacket struct {
 	StatusTag      byte   `json:"status_tag,omitempty" yaml:"status_tag,omitempty,flow" bson:"status_tag,omitempty"`
 	PluginName     string `json:"plugin_name,omitempty" yaml:"plugin_name,omitempty,flow" bson:"plugin_name,omitempty"`
 	PluginAuthData string `json:"plugin_authdata,omitempty" yaml:"plugin_authdata,omitempty,flow" bson:"plugin_authdata,omitempty"`
+	// Unterminated is set when the request ends with the plugin name, without
+	// its terminator or any plugin data.
+	Unterminated bool `json:"unterminated,omitempty" yaml:"unterminated,omitempty" bson:"unterminated,omitempty"`
 }
@@ -1,7 +1,10 @@
 package models
 
 import (
+	"time"
+
 	"github.com/jackc/pgproto3/v2"
+	"gopkg.in/yaml.v3"
 )
 
 const ProtocolVersionNumber uint32 = 196608 // Replace with actual version number if different
@@ -39,6 +42,20 @@ type Backend struct {
 	PartialMsg          bool                         `json:"partial_msg,omitempty" yaml:"partial_msg,omitempty"`
 	AuthType            int32                        `json:"auth_type" yaml:"auth_type"`
 	BodyLen             int                          `json:"body_len,omitempty" yaml:"body_len,omitempty"`
+	// OriginalQueries are the queries of the request before they were
+	// rewritten by the query transform of the recording.
+	OriginalQueries []string `json:"original_queries,omitempty" yaml:"original_queries,omitempty"`
+	// CopyDatas are the rows of a COPY FROM STDIN stream, in the order they
+	// were sent.
+	CopyDatas []pgproto3.CopyData `json:"copy_datas,omitempty" yaml:"copy_datas,omitempty"`
+	// Describes and Closes are the messages of the statements pipelined
+	// before a single Sync, in the order they were sent.
+	Describes []pgproto3.Describe `json:"describes,omitempty" yaml:"describes,omitempty"`
+	Closes    []pgproto3.Close    `json:"closes,omitempty" yaml:"closes,omitempty"`
+	// RawPayload is the base64 of the request as read from the wire, kept
+	// along with its readable form when the raw payloads are recorded, to
+	// analyze the gaps of the decoder. It is never replayed.
+	RawPayload string `json:"raw_payload,omitempty" yaml:"raw_payload,omitempty"`
 	// AuthMechanism       string                       `json:"auth_mechanism,omitempty" yaml:"auth_mechanism,omitempty"`
 }
 
@@ -63,29 +80,102 @@ type Frontend struct {
 	CommandCompletes                []pgproto3.CommandComplete               `json:"command_complete,omitempty" yaml:"command_complete,omitempty"`
 	CopyBothResponse                pgproto3.CopyBothResponse                `json:"copy_both_response,omitempty" yaml:"copy_both_response,omitempty"`
 	CopyData                        pgproto3.CopyData                        `json:"copy_data,omitempty" yaml:"copy_data,omitempty"`
-	CopyInResponse                  pgproto3.CopyInResponse                  `json:"copy_in_response,omitempty" yaml:"copy_in_response,omitempty"`
+	CopyDatas                       []pgproto3.CopyData                      `json:"copy_datas,omitempty" yaml:"copy_datas,omitempty"`
+	CopyInResponse                  PostgresCopyInResponse                   `json:"copy_in_response,omitempty" yaml:"copy_in_response,omitempty"`
 	CopyOutResponse                 pgproto3.CopyOutResponse                 `json:"copy_out_response,omitempty" yaml:"copy_out_response,omitempty"`
 	CopyDone                        pgproto3.CopyDone                        `json:"copy_done,omitempty" yaml:"copy_done,omitempty"`
 	DataRow                         pgproto3.DataRow                         `yaml:"-"`
 	DataRows                        []pgproto3.DataRow                       `json:"data_row,omitempty" yaml:"data_row,omitempty,flow"`
 	EmptyQueryResponse              pgproto3.EmptyQueryResponse              `json:"empty_query_response,omitempty" yaml:"empty_query_response,omitempty"`
 	ErrorResponse                   pgproto3.ErrorResponse                   `json:"error_response,omitempty" yaml:"error_response,omitempty"`
+	ErrorResponses                  []pgproto3.ErrorResponse                 `json:"error_responses,omitempty" yaml:"error_responses,omitempty"`
 	FunctionCallResponse            pgproto3.FunctionCallResponse            `json:"function_call_response,omitempty" yaml:"function_call_response,omitempty"`
+	FunctionCallResultNull          bool                                     `json:"function_call_result_null,omitempty" yaml:"function_call_result_null,omitempty"`
 	NoData                          pgproto3.NoData                          `json:"no_data,omitempty" yaml:"no_data,omitempty"`
 	NoticeResponse                  pgproto3.NoticeResponse                  `json:"notice_response,omitempty" yaml:"notice_response,omitempty"`
+	NoticeResponses                 []pgproto3.NoticeResponse                `json:"notice_responses,omitempty" yaml:"notice_responses,omitempty"`
 	NotificationResponse            pgproto3.NotificationResponse            `json:"notification_response,omitempty" yaml:"notification_response,omitempty"`
 	ParameterDescription            pgproto3.ParameterDescription            `json:"parameter_description,omitempty" yaml:"parameter_description,omitempty"`
+	ParameterDescriptions           []pgproto3.ParameterDescription          `json:"parameter_descriptions,omitempty" yaml:"parameter_descriptions,omitempty"`
 	ParameterStatus                 pgproto3.ParameterStatus                 `yaml:"-"`
 	ParameterStatusCombined         []pgproto3.ParameterStatus               `json:"parameter_status,omitempty" yaml:"parameter_status,omitempty"`
 	ParseComplete                   pgproto3.ParseComplete                   `yaml:"-"`
 	ParseCompletes                  []pgproto3.ParseComplete                 `json:"parse_complete,omitempty" yaml:"parse_complete,omitempty"`
 	ReadyForQuery                   pgproto3.ReadyForQuery                   `json:"ready_for_query,omitempty" yaml:"ready_for_query,omitempty"`
+	ReadyForQueries                 []pgproto3.ReadyForQuery                 `json:"ready_for_queries,omitempty" yaml:"ready_for_queries,omitempty"`
 	RowDescription                  pgproto3.RowDescription                  `json:"row_description,omitempty" yaml:"row_description,omitempty,flow"`
+	RowDescriptions                 []pgproto3.RowDescription                `json:"row_descriptions,omitempty" yaml:"row_descriptions,omitempty,flow"`
+	RowDescriptionTypes             []string                                 `json:"row_description_types,omitempty" yaml:"row_description_types,omitempty,flow"`
 	PortalSuspended                 pgproto3.PortalSuspended                 `json:"portal_suspended,omitempty" yaml:"portal_suspended,omitempty"`
 	MsgType                         byte                                     `json:"msg_type,omitempty" yaml:"msg_type,omitempty"`
 	AuthType                        int32                                    `json:"auth_type" yaml:"auth_type"`
 	// AuthMechanism                   string                                   `json:"auth_mechanism,omitempty" yaml:"auth_mechanism,omitempty"`
 	BodyLen int `json:"body_len,omitempty" yaml:"body_len,omitempty"`
+	// CopyDataDelay is the time elapsed since the previous response of the
+	// exchange when this response, carrying the chunks of a COPY OUT stream,
+	// was read from the server.
+	CopyDataDelay time.Duration `json:"copy_data_delay,omitempty" yaml:"copy_data_delay,omitempty"`
+	// NotificationResponses are the notifications of the channels listened
+	// to, in the order they were received, when the response holds several.
+	NotificationResponses []pgproto3.NotificationResponse `json:"notification_responses,omitempty" yaml:"notification_responses,omitempty"`
+	// NotificationDelay is the time elapsed since the previous response of
+	// the exchange when this response, pushed by the server without a
+	// request, was read from the server.
+	NotificationDelay time.Duration `json:"notification_delay,omitempty" yaml:"notification_delay,omitempty"`
+	// NegotiateProtocolVersion is the answer of the server to a startup
+	// message requesting a newer minor version of the protocol or protocol
+	// options it doesn't support.
+	NegotiateProtocolVersion PostgresNegotiateProtocolVersion `json:"negotiate_protocol_version,omitempty" yaml:"negotiate_protocol_version,omitempty"`
+	// RawPayload is the base64 of the response as read from the wire, kept
+	// along with its readable form when the raw payloads are recorded, to
+	// analyze the gaps of the decoder. It is never replayed.
+	RawPayload string `json:"raw_payload,omitempty" yaml:"raw_payload,omitempty"`
+	// Pipelines are, on the first response of an exchange whose requests
+	// hold Sync messages, the number of messages answering each pipeline
+	// delimited by a Sync, its ReadyForQuery included.
+	Pipelines []int `json:"pipelines,omitempty" yaml:"pipelines,omitempty,flow"`
+}
+
+// PostgresNegotiateProtocolVersion is the NegotiateProtocolVersion message,
+// which pgproto3 doesn't decode.
+type PostgresNegotiateProtocolVersion struct {
+	// NewestMinorProtocol is the newest minor version of the protocol
+	// supported by the server.
+	NewestMinorProtocol uint32 `json:"newest_minor_protocol,omitempty" yaml:"newest_minor_protocol,omitempty"`
+	// UnrecognizedOptions are the protocol options of the startup message,
+	// the _pq_. parameters, not supported by the server.
+	UnrecognizedOptions []string `json:"unrecognized_options,omitempty" yaml:"unrecognized_options,omitempty"`
+}
+
+// PostgresCopyInResponse is the CopyInResponse starting a COPY FROM STDIN.
+// Its format codes tell the client how to frame the CopyData of the rows, so
+// they are stored under the same keys as those of the CopyOutResponse and
+// the CopyBothResponse, which pgproto3 tags.
+type PostgresCopyInResponse struct {
+	// OverallFormat is 0 for a text COPY and 1 for a binary one.
+	OverallFormat byte `json:"overall_format" yaml:"overall_format"`
+	// ColumnFormatCodes are the formats of the columns, all 1 in a binary
+	// COPY.
+	ColumnFormatCodes []uint16 `json:"column_format_codes" yaml:"column_format_codes"`
+}
+
+// UnmarshalYAML reads the format codes of the mocks recorded before they were
+// tagged as well, under the keys derived from the pgproto3 field names.
+func (r *PostgresCopyInResponse) UnmarshalYAML(node *yaml.Node) error {
+	var fields struct {
+		OverallFormat           *byte    `yaml:"overall_format"`
+		ColumnFormatCodes       []uint16 `yaml:"column_format_codes"`
+		LegacyOverallFormat     byte     `yaml:"overallformat"`
+		LegacyColumnFormatCodes []uint16 `yaml:"columnformatcodes"`
+	}
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	r.OverallFormat, r.ColumnFormatCodes = fields.LegacyOverallFormat, fields.LegacyColumnFormatCodes
+	if fields.OverallFormat != nil {
+		r.OverallFormat, r.ColumnFormatCodes = *fields.OverallFormat, fields.ColumnFormatCodes
+	}
+	return nil
 }
 
 type StartupPacket struct {
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestPostgresCopyInResponseYAML(t *testing.T) {
+	binary := PostgresCopyInResponse{OverallFormat: 1, ColumnFormatCodes: []uint16{1, 1}}
+	encoded, err := yaml.Marshal(binary)
+	if err != nil {
+		t.Fatalf("failed to marshal the CopyInResponse: %v", err)
+	}
+	if !strings.Contains(string(encoded), "overall_format: 1") || !strings.Contains(string(encoded), "column_format_codes:") {
+		t.Errorf("marshaled the CopyInResponse as %q, want the tagged keys", encoded)
+	}
+
+	for _, tt := range []struct {
+		name string
+		yaml string
+		want PostgresCopyInResponse
+	}{
+		{name: "tagged", yaml: string(encoded), want: binary},
+		{name: "recorded before the tags", yaml: "overallformat: 1\ncolumnformatcodes: [1, 1]\n", want: binary},
+		{name: "tagged text format", yaml: "overall_format: 0\ncolumn_format_codes: [0]\noverallformat: 1\n", want: PostgresCopyInResponse{ColumnFormatCodes: []uint16{0}}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got PostgresCopyInResponse
+			if err := yaml.Unmarshal([]byte(tt.yaml), &got); err != nil {
+				t.Fatalf("failed to unmarshal the CopyInResponse: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+// RedisRequest is a command sent to a Redis server, as a RESP array of bulk
+// strings or inline, as a line of space separated arguments.
+type RedisRequest struct {
+	// Command is the name of the command followed by its arguments.
+	Command []string `json:"command" yaml:"command,flow"`
+	Inline  bool     `json:"inline,omitempty" yaml:"inline,omitempty"`
+}
+
+// RedisReplyType is the RESP type of a reply.
+type RedisReplyType string
+
+const (
+	RedisSimpleString   RedisReplyType = "simple_string"
+	RedisError          RedisReplyType = "error"
+	RedisInteger        RedisReplyType = "integer"
+	RedisBulkString     RedisReplyType = "bulk_string"
+	RedisArray          RedisReplyType = "array"
+	RedisNull           RedisReplyType = "null"
+	RedisBoolean        RedisReplyType = "boolean"
+	RedisDouble         RedisReplyType = "double"
+	RedisBigNumber      RedisReplyType = "big_number"
+	RedisBulkError      RedisReplyType = "bulk_error"
+	RedisVerbatimString RedisReplyType = "verbatim_string"
+	RedisMap            RedisReplyType = "map"
+	RedisSet            RedisReplyType = "set"
+	RedisAttribute      RedisReplyType = "attribute"
+	RedisPush           RedisReplyType = "push"
+)
+
+// RedisReply is a reply of a Redis server. The replies of the aggregate types
+// hold their elements in order, the keys and the values of the maps and the
+// attributes alternating.
+type RedisReply struct {
+	Type  RedisReplyType `json:"type" yaml:"type"`
+	Value string         `json:"value,omitempty" yaml:"value,omitempty"`
+	// Nil marks the null bulk strings and arrays of RESP2.
+	Nil      bool         `json:"nil,omitempty" yaml:"nil,omitempty"`
+	Elements []RedisReply `json:"elements,omitempty" yaml:"elements,omitempty"`
+}
@@ -20,11 +20,13 @@ func GetVersion() (V1 Version) {
 
 const (
 	HTTP           Kind     = "Http"
+	HTTP3          Kind     = "Http3"
 	GENERIC        Kind     = "Generic"
 	SQL            Kind     = "SQL"
 	Postgres       Kind     = "Postgres"
 	GRPC_EXPORT    Kind     = "gRPC"
 	Mongo          Kind     = "Mongo"
+	Redis          Kind     = "Redis"
 	BodyTypeUtf8   BodyType = "utf-8"
 	BodyTypeBinary BodyType = "binary"
 	BodyTypePlain  BodyType = "PLAIN"
@@ -1,15 +1,20 @@
 package models
 
 type TestReport struct {
-	Version Version      `json:"version" yaml:"version"`
-	Name    string       `json:"name" yaml:"name"`
-	Status  string       `json:"status" yaml:"status"`
-	Success int          `json:"success" yaml:"success"`
-	Failure int          `json:"failure" yaml:"failure"`
-	Total   int          `json:"total" yaml:"total"`
-	Tests   []TestResult `json:"tests" yaml:"tests,omitempty"`
-	TestSet string       `json:"testSet" yaml:"test_set"`
-	ID      string       `-`
+	Version Version `json:"version" yaml:"version"`
+	Name    string  `json:"name" yaml:"name"`
+	Status  string  `json:"status" yaml:"status"`
+	Success int     `json:"success" yaml:"success"`
+	Failure int     `json:"failure" yaml:"failure"`
+	Total   int     `json:"total" yaml:"total"`
+	// Divergences is the number of dependency calls of the replay which
+	// matched none of the mocks, and DivergenceRate their share of all the
+	// dependency calls.
+	Divergences    int          `json:"divergences,omitempty" yaml:"divergences,omitempty"`
+	DivergenceRate float64      `json:"divergenceRate,omitempty" yaml:"divergence_rate,omitempty"`
+	Tests          []TestResult `json:"tests" yaml:"tests,omitempty"`
+	TestSet        string       `json:"testSet" yaml:"test_set"`
+	ID             string       `-`
 }
 
 func (tr *TestReport) GetKind() string {
@@ -29,6 +34,22 @@ type TestResult struct {
 	Res          HttpResp   `json:"resp" yaml:"resp,omitempty"`
 	Noise        Noise      `json:"noise" yaml:"noise,omitempty"`
 	Result       Result     `json:"result" yaml:"result"`
+
+	// DependencyCalls is the number of dependency calls matched with a mock
+	// while the test ran, to assert the round trips of an endpoint (such as
+	// its N+1 queries). DependencyCallsByKind splits it per kind of mock.
+	DependencyCalls       int          `json:"dependencyCalls" yaml:"dependency_calls"`
+	DependencyCallsByKind map[Kind]int `json:"dependencyCallsByKind,omitempty" yaml:"dependency_calls_by_kind,omitempty"`
+	// Divergences is the number of dependency calls which matched none of the
+	// mocks while the test ran, and were passed through to the dependency.
+	Divergences int `json:"divergences,omitempty" yaml:"divergences,omitempty"`
+	// FuzzyMatches are the names of the mocks matched by similarity only
+	// while the test ran, the queries of which drifted since the recording.
+	FuzzyMatches []string `json:"fuzzyMatches,omitempty" yaml:"fuzzy_matches,omitempty"`
+	// StartedMillis and CompletedMillis are Started and Completed in
+	// milliseconds, for the durations of the test cases run under a second.
+	StartedMillis   int64 `json:"startedMillis,omitempty" yaml:"started_millis,omitempty"`
+	CompletedMillis int64 `json:"completedMillis,omitempty" yaml:"completed_millis,omitempty"`
 }
 
 func (tr *TestResult) GetKind() string {
@@ -46,6 +67,15 @@ const (
 	TestRunStatusFaultUserApp TestRunStatus = "APP_FAULT"
 )
 
+// TestReportFormat is the format of the test reports. The native reports are
+// always written, TestReportFormatJUnit writes a JUnit XML report next to them.
+type TestReportFormat string
+
+const (
+	TestReportFormatYAML  TestReportFormat = "yaml"
+	TestReportFormatJUnit TestReportFormat = "junit"
+)
+
 type Result struct {
 	StatusCode    IntResult      `json:"status_code" bson:"status_code" yaml:"status_code"`
 	HeadersResult []HeaderResult `json:"headers_result" bson:"headers_result" yaml:"headers_result"`
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+)
+
+// WireMockMappings is the JSON document of the stub mappings loaded by
+// WireMock, produced from the recorded HTTP mocks.
+type WireMockMappings struct {
+	Mappings []WireMockMapping `json:"mappings"`
+}
+
+func (m *WireMockMappings) GetKind() string {
+	return "WireMockMappings"
+}
+
+type WireMockMapping struct {
+	Name     string           `json:"name,omitempty"`
+	Request  WireMockRequest  `json:"request"`
+	Response WireMockResponse `json:"response"`
+}
+
+type WireMockRequest struct {
+	Method       string                `json:"method"`
+	URL          string                `json:"url"`
+	BodyPatterns []WireMockBodyPattern `json:"bodyPatterns,omitempty"`
+}
+
+// WireMockBodyPattern holds one of the WireMock body matchers.
+type WireMockBodyPattern struct {
+	EqualTo     string          `json:"equalTo,omitempty"`
+	EqualToJSON json.RawMessage `json:"equalToJson,omitempty"`
+}
+
+type WireMockResponse struct {
+	Status     int               `json:"status"`
+	StatusText string            `json:"statusMessage,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body,omitempty"`
+}
+
+// wireMockSkippedHeaders are the response headers computed by WireMock or
+// bound to the recorded exchange.
+var wireMockSkippedHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Date":              true,
+	"Connection":        true,
+}
+
+// WireMockMapping converts an HTTP mock to a WireMock stub mapping matching
+// its method, path, query and body. It reports false for the other kinds.
+func (m *Mock) WireMockMapping() (WireMockMapping, bool) {
+	if m.Kind != HTTP || m.Spec.HttpReq == nil || m.Spec.HttpResp == nil {
+		return WireMockMapping{}, false
+	}
+	req, resp := m.Spec.HttpReq, m.Spec.HttpResp
+
+	requestURL := req.URL
+	if parsed, err := url.Parse(req.URL); err == nil {
+		requestURL = parsed.RequestURI()
+	}
+	mapping := WireMockMapping{
+		Name: m.Name,
+		Request: WireMockRequest{
+			Method: string(req.Method),
+			URL:    requestURL,
+		},
+		Response: WireMockResponse{
+			Status:     resp.StatusCode,
+			StatusText: resp.StatusMessage,
+		},
+	}
+
+	if req.Body != "" {
+		pattern := WireMockBodyPattern{EqualTo: req.Body}
+		if json.Valid([]byte(req.Body)) {
+			pattern = WireMockBodyPattern{EqualToJSON: json.RawMessage(req.Body)}
+		}
+		mapping.Request.BodyPatterns = []WireMockBodyPattern{pattern}
+	}
+
+	for name, value := range resp.Header {
+		if wireMockSkippedHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		if mapping.Response.Headers == nil {
+			mapping.Response.Headers = make(map[string]string)
+		}
+		mapping.Response.Headers[name] = value
+	}
+	mapping.Response.Body = resp.Body
+	return mapping, true
+}
@@ -2,6 +2,8 @@ package platform
 
 import (
 	"context"
+
+	"go.keploy.io/server/pkg/models"
 )
 
 type TestCaseDB interface {
@@ -13,7 +15,12 @@ type TestCaseDB interface {
 	ReadTestcases(testSet string, lastSeenId KindSpecifier, options KindSpecifier) ([]KindSpecifier, error)
 	ReadTcsMocks(tc KindSpecifier, testSet string) ([]KindSpecifier, error)
 	ReadConfigMocks(testSet string) ([]KindSpecifier, error)
+	ReadMocks(ctx context.Context, testSet string, filter KindSpecifier) ([]KindSpecifier, error)
 	ReadTestSessionIndices() ([]string, error)
+	MergeMocks(srcTestSet, dstTestSet string) (*models.MockMergeReport, error)
+	ValidateMocks(testSet string) (*models.MockValidationReport, error)
+	ExportWireMock(testSet string) (KindSpecifier, error)
+	SetMockDisabled(testSet, name string, disabled bool) error
 }
 
 type TestReportDB interface {
@@ -0,0 +1,89 @@
+package yaml
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// SetMockDisabled switches the named mock of the test-set off or back on.
+// A disabled mock stays in the mock file but is skipped while matching.
+func (ys *Yaml) SetMockDisabled(testSet, name string, disabled bool) error {
+	ys.mockMutex.Lock()
+	defer ys.mockMutex.Unlock()
+	mocks, err := ys.readMocks(testSet)
+	if err != nil {
+		ys.Logger.Error("failed to read the mocks of the test-set", zap.Error(err), zap.String("test-set", testSet))
+		return err
+	}
+
+	found := false
+	for _, mock := range mocks {
+		if mock.Name == name {
+			mock.Spec.Disabled = disabled
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("mock %s not found in test-set %s", name, testSet)
+	}
+	return ys.rewriteMocks(filepath.Join(ys.MockPath, testSet), mocks)
+}
+
+// rewriteMocks replaces the mocks of the test-set directory. They are written
+// to a temporary directory next to it first, and the mock file is renamed
+// over the former one once complete, so that the mocks are never left half
+// written. The mock file is removed when there is no mock left, and so are
+// the objects of the mock store no test-set refers to anymore.
+func (ys *Yaml) rewriteMocks(path string, mocks []*models.Mock) error {
+	tmpPath, err := os.MkdirTemp(filepath.Dir(path), "."+filepath.Base(path)+"-")
+	if err != nil {
+		ys.Logger.Error("failed to create the temporary directory of the mocks", zap.Error(err), zap.String("path", path))
+		return err
+	}
+	defer os.RemoveAll(tmpPath)
+
+	name, extension := ys.mockFileName(), "yaml"
+	contentAddressed := ys.contentAddressed(path)
+	var serializer MockSerializer
+	if contentAddressed {
+		name = mockIndexName
+	} else if serializer = ys.mockSerializerOf(path, name); serializer != nil {
+		extension = serializer.Extension()
+	}
+	for _, mock := range mocks {
+		switch {
+		case contentAddressed:
+			err = ys.appendContentAddressedMock(tmpPath, mock)
+		case serializer != nil:
+			err = appendSerializedMock(serializer, tmpPath, name, mock, ys.Logger)
+		default:
+			var doc *NetworkTrafficDoc
+			doc, err = EncodeMock(mock, ys.Logger)
+			if err == nil {
+				err = ys.Write(tmpPath, name, doc)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	fileName := name + "." + extension
+	// no mock file is written without any mock
+	if len(mocks) == 0 {
+		err = os.Remove(filepath.Join(path, fileName))
+		if os.IsNotExist(err) {
+			err = nil
+		}
+	} else {
+		err = os.Rename(filepath.Join(tmpPath, fileName), filepath.Join(path, fileName))
+	}
+	if err != nil || !contentAddressed {
+		return err
+	}
+	return ys.collectMockStore(path)
+}
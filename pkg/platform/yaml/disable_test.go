@@ -0,0 +1,170 @@
+package yaml
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// newTestStore returns a store of the mocks of a temporary directory.
+func newTestStore(t *testing.T) *Yaml {
+	t.Helper()
+	path := t.TempDir()
+	ys := NewYamlStore(filepath.Join(path, "tests"), path, "", "", zap.NewNop(), nil).(*Yaml)
+	// the created files are shared with all the users through sudo, left
+	// to the user of the test instead
+	ys.ShareFiles = func(string) error { return nil }
+	return ys
+}
+
+func httpMock(url string) *models.Mock {
+	return &models.Mock{
+		Version: models.GetVersion(),
+		Kind:    models.HTTP,
+		Spec: models.MockSpec{
+			Metadata: map[string]string{"name": url},
+			HttpReq:  &models.HttpReq{Method: "GET", URL: url, Header: map[string]string{}},
+			HttpResp: &models.HttpResp{StatusCode: 200, Header: map[string]string{}, Body: url},
+		},
+	}
+}
+
+// writeMocks records the mocks of the urls into the test-set and returns
+// them with the names they were given.
+func writeMocks(t *testing.T, ys *Yaml, testSet string, urls ...string) []*models.Mock {
+	t.Helper()
+	ctx := context.WithValue(context.Background(), "testSet", testSet)
+	mocks := []*models.Mock{}
+	for _, url := range urls {
+		mock := httpMock(url)
+		if err := ys.WriteMock(mock, ctx); err != nil {
+			t.Fatalf("failed to write the mock of %s: %v", url, err)
+		}
+		mocks = append(mocks, mock)
+	}
+	return mocks
+}
+
+func mockURLs(mocks []platform.KindSpecifier) []string {
+	urls := []string{}
+	for _, mock := range mocks {
+		urls = append(urls, mock.(*models.Mock).Spec.HttpReq.URL)
+	}
+	return urls
+}
+
+func storedURLs(t *testing.T, ys *Yaml, testSet string) map[string]bool {
+	t.Helper()
+	mocks, err := ys.readMocks(testSet)
+	if err != nil {
+		t.Fatalf("failed to read the mocks of %s: %v", testSet, err)
+	}
+	urls := map[string]bool{}
+	for _, mock := range mocks {
+		urls[mock.Spec.HttpReq.URL] = mock.Spec.Disabled
+	}
+	return urls
+}
+
+func TestDisabledMockIsNotReadForMatching(t *testing.T) {
+	ys := newTestStore(t)
+	mocks := writeMocks(t, ys, "test-set-0", "/users", "/orders", "/items")
+
+	if err := ys.SetMockDisabled("test-set-0", mocks[1].Name, true); err != nil {
+		t.Fatalf("failed to disable the mock: %v", err)
+	}
+	read, err := ys.ReadTcsMocks(nil, "test-set-0")
+	if err != nil {
+		t.Fatalf("failed to read the mocks: %v", err)
+	}
+	if urls := mockURLs(read); len(urls) != 2 || urls[0] != "/users" || urls[1] != "/items" {
+		t.Fatalf("read the mocks of %v for matching, want /users and /items", urls)
+	}
+	if stored := storedURLs(t, ys, "test-set-0"); len(stored) != 3 || !stored["/orders"] {
+		t.Fatalf("stored the mocks %v, want the three of them with /orders disabled", stored)
+	}
+
+	if err := ys.SetMockDisabled("test-set-0", mocks[1].Name, false); err != nil {
+		t.Fatalf("failed to enable the mock: %v", err)
+	}
+	read, err = ys.ReadTcsMocks(nil, "test-set-0")
+	if err != nil {
+		t.Fatalf("failed to read the mocks: %v", err)
+	}
+	if urls := mockURLs(read); len(urls) != 3 {
+		t.Fatalf("read the mocks of %v for matching once enabled again, want all three", urls)
+	}
+}
+
+func TestDisabledMockEncodedInTheSpec(t *testing.T) {
+	for _, mock := range []*models.Mock{httpMock("/users"), genericMock()} {
+		mock.Spec.Disabled = true
+		doc, err := EncodeMock(mock, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to encode the %s mock: %v", mock.Kind, err)
+		}
+		data, err := yamlLib.Marshal(doc)
+		if err != nil {
+			t.Fatalf("failed to marshal the %s mock: %v", mock.Kind, err)
+		}
+		var encoded struct {
+			Disabled *bool `yaml:"disabled"`
+			Spec     struct {
+				Disabled bool `yaml:"disabled"`
+			} `yaml:"spec"`
+		}
+		if err := yamlLib.Unmarshal(data, &encoded); err != nil {
+			t.Fatalf("failed to unmarshal the %s mock: %v", mock.Kind, err)
+		}
+		if !encoded.Spec.Disabled || encoded.Disabled != nil {
+			t.Errorf("encoded the disabled %s mock as:\n%s\nwant disabled inside its spec", mock.Kind, data)
+		}
+	}
+}
+
+func TestDisabledMockSurvivesRemovalOfUnusedMocks(t *testing.T) {
+	ys := newTestStore(t)
+	mocks := writeMocks(t, ys, "test-set-0", "/users", "/orders", "/items")
+	if err := ys.SetMockDisabled("test-set-0", mocks[1].Name, true); err != nil {
+		t.Fatalf("failed to disable the mock: %v", err)
+	}
+
+	// only /users was used by the test-set
+	if err := ys.UpdateMocks([]platform.KindSpecifier{mocks[0]}, "test-set-0"); err != nil {
+		t.Fatalf("failed to remove the unused mocks: %v", err)
+	}
+	stored := storedURLs(t, ys, "test-set-0")
+	if len(stored) != 2 || stored["/users"] || !stored["/orders"] {
+		t.Fatalf("kept the mocks %v, want /users and the disabled /orders", stored)
+	}
+
+	// none was used
+	if err := ys.UpdateMocks(nil, "test-set-0"); err != nil {
+		t.Fatalf("failed to remove the unused mocks: %v", err)
+	}
+	if stored := storedURLs(t, ys, "test-set-0"); len(stored) != 1 || !stored["/orders"] {
+		t.Fatalf("kept the mocks %v, want the disabled /orders", stored)
+	}
+}
+
+func TestRemovalOfAllUnusedMocksRemovesTheMockFile(t *testing.T) {
+	ys := newTestStore(t)
+	writeMocks(t, ys, "test-set-0", "/users", "/orders")
+
+	if err := ys.UpdateMocks(nil, "test-set-0"); err != nil {
+		t.Fatalf("failed to remove the unused mocks: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ys.MockPath, "test-set-0", "mocks.yaml")); !os.IsNotExist(err) {
+		t.Fatalf("kept the mock file without any mock: %v", err)
+	}
+	// nor does it fail without the file
+	if err := ys.UpdateMocks(nil, "test-set-0"); err != nil {
+		t.Fatalf("failed to remove the unused mocks again: %v", err)
+	}
+}
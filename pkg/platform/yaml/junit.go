@@ -0,0 +1,183 @@
+package yaml
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+)
+
+// junitTestSuites is the root of a JUnit XML report, as read by the CI
+// systems. A test-set is reported as a test suite of its test cases.
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Details string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes the test report as a JUnit XML file, named after
+// the report, in the directory of the test reports.
+func WriteJUnitReport(path string, report *models.TestReport) error {
+	data, err := MarshalJUnitReport(report)
+	if err != nil {
+		return err
+	}
+	validatedPath, err := util.ValidatePath(filepath.Join(path, report.Name+".xml"))
+	if err != nil {
+		return fmt.Errorf("%s failed to validate path: %s", Emoji, err.Error())
+	}
+	err = os.WriteFile(validatedPath, data, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("%s failed to write test report in junit xml file. error: %s", Emoji, err.Error())
+	}
+	return nil
+}
+
+// MarshalJUnitReport returns the test report in the JUnit XML format, with
+// the status and the duration of every test case, and the mismatches of the
+// failed ones.
+func MarshalJUnitReport(report *models.TestReport) ([]byte, error) {
+	suite := junitTestSuite{
+		Name:  report.TestSet,
+		Tests: len(report.Tests),
+	}
+	var started, completed int64
+	for _, test := range report.Tests {
+		testStarted, testCompleted := testMillis(test)
+		testCase := junitTestCase{
+			Name:      test.TestCaseID,
+			ClassName: report.TestSet,
+			Time:      junitSeconds(testCompleted - testStarted),
+		}
+		if test.Status != models.TestStatusPassed {
+			suite.Failures++
+			testCase.Failure = junitTestFailure(test)
+		}
+		suite.Cases = append(suite.Cases, testCase)
+		if started == 0 || (testStarted != 0 && testStarted < started) {
+			started = testStarted
+		}
+		if testCompleted > completed {
+			completed = testCompleted
+		}
+	}
+	suite.Time = junitSeconds(completed - started)
+	if started != 0 {
+		suite.Timestamp = time.UnixMilli(started).UTC().Format("2006-01-02T15:04:05")
+	}
+
+	data, err := xml.MarshalIndent(junitTestSuites{
+		Name:     report.Name,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Time:     suite.Time,
+		Suites:   []junitTestSuite{suite},
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("%s failed to marshal the test report to junit xml. error: %s", Emoji, err.Error())
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// junitTestFailure describes the parts of the response, and the dependency
+// calls, which diverged from the recorded ones.
+func junitTestFailure(test models.TestResult) *junitFailure {
+	var diverged, details []string
+	result := test.Result
+	if !result.StatusCode.Normal {
+		diverged = append(diverged, "status code")
+		details = append(details, fmt.Sprintf("status code: expected %d, got %d", result.StatusCode.Expected, result.StatusCode.Actual))
+	}
+	headers := 0
+	for _, header := range result.HeadersResult {
+		if header.Normal {
+			continue
+		}
+		headers++
+		details = append(details, fmt.Sprintf("header %s: expected %q, got %q", header.Expected.Key, strings.Join(header.Expected.Value, ", "), strings.Join(header.Actual.Value, ", ")))
+	}
+	if headers > 0 {
+		diverged = append(diverged, "headers")
+	}
+	for _, body := range result.BodyResult {
+		if body.Normal {
+			continue
+		}
+		if len(diverged) == 0 || diverged[len(diverged)-1] != "body" {
+			diverged = append(diverged, "body")
+		}
+		details = append(details, fmt.Sprintf("body: expected %s, got %s", body.Expected, body.Actual))
+	}
+	dependencies := false
+	for _, dep := range result.DepResult {
+		for _, meta := range dep.Meta {
+			if meta.Normal {
+				continue
+			}
+			dependencies = true
+			details = append(details, fmt.Sprintf("dependency %s (%s) %s: expected %s, got %s", dep.Name, dep.Type, meta.Key, meta.Expected, meta.Actual))
+		}
+	}
+	if dependencies {
+		diverged = append(diverged, "dependency calls")
+	}
+
+	message := "the test case " + strings.ToLower(string(test.Status))
+	if len(diverged) > 0 {
+		message = "the " + strings.Join(diverged, ", ") + " diverged from the recording"
+	}
+	return &junitFailure{
+		Message: message,
+		Type:    string(test.Status),
+		Details: strings.Join(details, "\n"),
+	}
+}
+
+// testMillis returns the start and the end of the test case in milliseconds,
+// the reports written before they were recorded in milliseconds have them in
+// seconds only.
+func testMillis(test models.TestResult) (int64, int64) {
+	if test.StartedMillis != 0 || test.CompletedMillis != 0 {
+		return test.StartedMillis, test.CompletedMillis
+	}
+	return test.Started * 1000, test.Completed * 1000
+}
+
+// junitSeconds formats the duration in milliseconds as the seconds of the
+// JUnit reports.
+func junitSeconds(millis int64) string {
+	if millis < 0 {
+		millis = 0
+	}
+	return fmt.Sprintf("%d.%03d", millis/1000, millis%1000)
+}
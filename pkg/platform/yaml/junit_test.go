@@ -0,0 +1,98 @@
+package yaml
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func unmarshalJUnitReport(t *testing.T, report *models.TestReport) junitTestSuites {
+	data, err := MarshalJUnitReport(report)
+	if err != nil {
+		t.Fatalf("failed to marshal the report: %v", err)
+	}
+	if !strings.HasPrefix(string(data), xml.Header) {
+		t.Errorf("got report %q, want it to start with the xml header", data)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(data, &suites); err != nil {
+		t.Fatalf("failed to unmarshal the report: %v", err)
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("got %d test suites, want 1", len(suites.Suites))
+	}
+	return suites
+}
+
+func TestMarshalJUnitReportPassed(t *testing.T) {
+	report := &models.TestReport{
+		Name:    "report-1",
+		TestSet: "test-set-0",
+		Tests: []models.TestResult{
+			{TestCaseID: "test-1", Status: models.TestStatusPassed, StartedMillis: 1700000000000, CompletedMillis: 1700000000250},
+			{TestCaseID: "test-2", Status: models.TestStatusPassed, StartedMillis: 1700000000300, CompletedMillis: 1700000001550},
+		},
+	}
+	suites := unmarshalJUnitReport(t, report)
+	if suites.Name != "report-1" || suites.Tests != 2 || suites.Failures != 0 || suites.Time != "1.550" {
+		t.Errorf("got test suites %s with %d tests, %d failures in %s, want report-1 with 2 tests, 0 failures in 1.550", suites.Name, suites.Tests, suites.Failures, suites.Time)
+	}
+	suite := suites.Suites[0]
+	if suite.Timestamp != "2023-11-14T22:13:20" {
+		t.Errorf("got timestamp %s, want 2023-11-14T22:13:20", suite.Timestamp)
+	}
+	for i, want := range []string{"0.250", "1.250"} {
+		testCase := suite.Cases[i]
+		if testCase.ClassName != "test-set-0" || testCase.Time != want {
+			t.Errorf("test case %s: got class %s in %s, want test-set-0 in %s", testCase.Name, testCase.ClassName, testCase.Time, want)
+		}
+		if testCase.Failure != nil {
+			t.Errorf("test case %s: got failure %+v, want none", testCase.Name, testCase.Failure)
+		}
+	}
+}
+
+func TestMarshalJUnitReportFailed(t *testing.T) {
+	report := &models.TestReport{
+		Name:    "report-2",
+		TestSet: "test-set-0",
+		Tests: []models.TestResult{
+			{TestCaseID: "test-1", Status: models.TestStatusPassed, Started: 1700000000, Completed: 1700000002},
+			{
+				TestCaseID: "test-2",
+				Status:     models.TestStatusFailed,
+				Started:    1700000002,
+				Completed:  1700000003,
+				Result: models.Result{
+					StatusCode: models.IntResult{Normal: false, Expected: 200, Actual: 500},
+					BodyResult: []models.BodyResult{{Normal: false, Expected: `{"id":1}`, Actual: `{"error":"internal"}`}},
+				},
+			},
+		},
+	}
+	suites := unmarshalJUnitReport(t, report)
+	if suites.Tests != 2 || suites.Failures != 1 || suites.Time != "3.000" {
+		t.Errorf("got %d tests, %d failures in %s, want 2 tests, 1 failure in 3.000", suites.Tests, suites.Failures, suites.Time)
+	}
+	suite := suites.Suites[0]
+	if suite.Cases[0].Failure != nil {
+		t.Errorf("got failure %+v for the passed test case, want none", suite.Cases[0].Failure)
+	}
+	failure := suite.Cases[1].Failure
+	if failure == nil {
+		t.Fatalf("got no failure for the failed test case")
+	}
+	if failure.Type != string(models.TestStatusFailed) {
+		t.Errorf("got failure type %s, want %s", failure.Type, models.TestStatusFailed)
+	}
+	if want := "the status code, body diverged from the recording"; failure.Message != want {
+		t.Errorf("got failure message %q, want %q", failure.Message, want)
+	}
+	for _, want := range []string{"status code: expected 200, got 500", `body: expected {"id":1}, got {"error":"internal"}`} {
+		if !strings.Contains(failure.Details, want) {
+			t.Errorf("got failure details %q, want them to contain %q", failure.Details, want)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package yaml
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// MockLayout defines how the mocks of the test-sets are laid out on the disk.
+type MockLayout string
+
+const (
+	// FlatMockLayout stores all the mocks of a test-set in its mocks.yaml file.
+	FlatMockLayout MockLayout = ""
+	// ContentAddressedMockLayout stores every mock once, under a path derived
+	// from its content hash, in a store shared by all the test-sets. Each
+	// test-set keeps an index of the hashes of its mocks.
+	ContentAddressedMockLayout MockLayout = "content-addressed"
+)
+
+const (
+	// mockStoreDir is the directory next to the test-sets holding the mocks
+	// in the content addressed layout.
+	mockStoreDir = "mock-store"
+	// mockIndexName is the name of the file listing the mocks of a test-set
+	// in the content addressed layout.
+	mockIndexName = "mocks-index"
+)
+
+// SetMockLayout selects the layout the mocks are recorded in, flat by
+// default. The mocks of the test-sets recorded in the content addressed layout
+// are read as such whatever the layout.
+func (ys *Yaml) SetMockLayout(layout string) error {
+	switch MockLayout(layout) {
+	case FlatMockLayout, ContentAddressedMockLayout:
+		ys.MockLayout = MockLayout(layout)
+		return nil
+	case "flat":
+		ys.MockLayout = FlatMockLayout
+		return nil
+	}
+	return fmt.Errorf("unknown mock layout %q", layout)
+}
+
+// contentAddressed reports whether the mocks of the test-set directory are
+// stored in the content addressed layout.
+func (ys *Yaml) contentAddressed(path string) bool {
+	if ys.MockLayout == ContentAddressedMockLayout {
+		return true
+	}
+	_, err := os.Stat(filepath.Join(path, mockIndexName+".yaml"))
+	return err == nil
+}
+
+// mockIndexEntry maps the name of a mock in a test-set to its content hash.
+type mockIndexEntry struct {
+	Name string `yaml:"name"`
+	Hash string `yaml:"hash"`
+	// Disabled is kept in the index, as the mocks of the store are shared
+	// by the test-sets.
+	Disabled bool `yaml:"disabled,omitempty"`
+}
+
+// mockFileName returns the name of the mock file of the test-sets, without
+// its extension.
+func (ys *Yaml) mockFileName() string {
+	if ys.MockName != "" {
+		return ys.MockName
+	}
+	return "mocks"
+}
+
+// readMocksFrom reads the mocks of the test-set directory. A test-set without
+// mocks results in an empty list.
+func (ys *Yaml) readMocksFrom(path, mockName string) ([]*models.Mock, error) {
+	if ys.contentAddressed(path) {
+		return ys.readContentAddressedMocks(path)
+	}
+	// the mocks may have been recorded in another format
+	if serializer := ys.mockSerializerOf(path, mockName); serializer != nil {
+		return readSerializedMocks(serializer, path, mockName)
+	}
+	mockPath, err := util.ValidatePath(filepath.Join(path, mockName+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(mockPath); err != nil {
+		return []*models.Mock{}, nil
+	}
+	yamls, err := read(path, mockName)
+	if err != nil {
+		return nil, err
+	}
+	return decodeMocks(yamls, ys.Logger)
+}
+
+// appendMock adds the mock to the mocks of the test-set directory.
+func (ys *Yaml) appendMock(path string, mock *models.Mock) error {
+	ys.mockMutex.Lock()
+	defer ys.mockMutex.Unlock()
+	if ys.contentAddressed(path) {
+		return ys.appendContentAddressedMock(path, mock)
+	}
+	if ys.MockSerializer != nil {
+		return appendSerializedMock(ys.MockSerializer, path, ys.mockFileName(), mock, ys.Logger)
+	}
+	mockYaml, err := EncodeMock(mock, ys.Logger)
+	if err != nil {
+		return err
+	}
+	return ys.Write(path, ys.mockFileName(), mockYaml)
+}
+
+// mockStorePath returns the directory holding the mock of the hash, for the
+// test-set directory.
+func mockStorePath(path, hash string) string {
+	return filepath.Join(filepath.Dir(path), mockStoreDir, hash[:2])
+}
+
+// appendContentAddressedMock stores the mock, unless it is stored already,
+// and adds it to the index of the test-set directory. It is called with the
+// mock mutex held.
+func (ys *Yaml) appendContentAddressedMock(path string, mock *models.Mock) error {
+	hash := mock.ContentHash()
+	if hash == "" {
+		return errors.New("failed to compute the content hash of the mock")
+	}
+	storePath := mockStorePath(path, hash)
+	objectPath, err := util.ValidatePath(filepath.Join(storePath, hash+".yaml"))
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(objectPath); err != nil {
+		stored := *mock
+		stored.Name = hash
+		stored.Spec.Disabled = false
+		mockYaml, err := EncodeMock(&stored, ys.Logger)
+		if err != nil {
+			return err
+		}
+		err = ys.Write(storePath, hash, mockYaml)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = ys.createYamlFile(path, mockIndexName)
+	if err != nil {
+		return err
+	}
+	indexPath, err := util.ValidatePath(filepath.Join(path, mockIndexName+".yaml"))
+	if err != nil {
+		return err
+	}
+	entry, err := yamlLib.Marshal([]mockIndexEntry{{Name: mock.Name, Hash: hash, Disabled: mock.Spec.Disabled}})
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(indexPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.ModePerm)
+	if err != nil {
+		ys.Logger.Error("failed to open the mocks index", zap.Error(err), zap.String("path", indexPath))
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(entry)
+	return err
+}
+
+// readMockIndex reads the index of the test-set directory, which is empty
+// when the test-set has no index.
+func readMockIndex(path string) ([]mockIndexEntry, error) {
+	indexPath, err := util.ValidatePath(filepath.Join(path, mockIndexName+".yaml"))
+	if err != nil {
+		return nil, err
+	}
+	entries := []mockIndexEntry{}
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return entries, nil
+		}
+		return nil, err
+	}
+	err = yamlLib.Unmarshal(data, &entries)
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func (ys *Yaml) readContentAddressedMocks(path string) ([]*models.Mock, error) {
+	entries, err := readMockIndex(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mocks := make([]*models.Mock, 0, len(entries))
+	for _, entry := range entries {
+		if len(entry.Hash) < 2 {
+			continue
+		}
+		yamls, err := read(mockStorePath(path, entry.Hash), entry.Hash)
+		if err != nil {
+			return nil, err
+		}
+		decoded, err := decodeMocks(yamls, ys.Logger)
+		if err != nil {
+			return nil, err
+		}
+		for _, mock := range decoded {
+			mock.Name = entry.Name
+			mock.Spec.Disabled = entry.Disabled
+			mocks = append(mocks, mock)
+		}
+	}
+	return mocks, nil
+}
+
+// collectMockStore removes the mocks of the store which none of the indexes
+// of the test-sets next to the test-set directory refers to anymore, once the
+// index of the test-set was rewritten. It is called with the mock mutex held.
+func (ys *Yaml) collectMockStore(path string) error {
+	root := filepath.Dir(path)
+	testSets, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+	referenced := map[string]bool{}
+	for _, testSet := range testSets {
+		if !testSet.IsDir() || testSet.Name() == mockStoreDir {
+			continue
+		}
+		entries, err := readMockIndex(filepath.Join(root, testSet.Name()))
+		if err != nil {
+			ys.Logger.Error("failed to read the mocks index", zap.Error(err), zap.String("test-set", testSet.Name()))
+			return err
+		}
+		for _, entry := range entries {
+			referenced[entry.Hash] = true
+		}
+	}
+
+	storePath := filepath.Join(root, mockStoreDir)
+	prefixes, err := os.ReadDir(storePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, prefix := range prefixes {
+		if !prefix.IsDir() {
+			continue
+		}
+		prefixPath := filepath.Join(storePath, prefix.Name())
+		objects, err := os.ReadDir(prefixPath)
+		if err != nil {
+			return err
+		}
+		kept := 0
+		for _, object := range objects {
+			hash := strings.TrimSuffix(object.Name(), ".yaml")
+			if object.IsDir() || hash == object.Name() || referenced[hash] {
+				kept++
+				continue
+			}
+			err = os.Remove(filepath.Join(prefixPath, object.Name()))
+			if err != nil {
+				ys.Logger.Error("failed to remove the unused mock from the mock store", zap.Error(err), zap.String("hash", hash))
+				return err
+			}
+		}
+		if kept == 0 {
+			err = os.Remove(prefixPath)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package yaml
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"go.keploy.io/server/pkg/platform"
+)
+
+// newContentAddressedStore returns a store of the mocks of a temporary
+// directory in the content addressed layout.
+func newContentAddressedStore(t *testing.T) *Yaml {
+	t.Helper()
+	ys := newTestStore(t)
+	if err := ys.SetMockLayout(string(ContentAddressedMockLayout)); err != nil {
+		t.Fatalf("failed to set the mock layout: %v", err)
+	}
+	return ys
+}
+
+// storedObjects returns the hashes of the mocks of the mock store.
+func storedObjects(t *testing.T, ys *Yaml) map[string]bool {
+	t.Helper()
+	objects, err := filepath.Glob(filepath.Join(ys.MockPath, mockStoreDir, "*", "*.yaml"))
+	if err != nil {
+		t.Fatalf("failed to list the mock store: %v", err)
+	}
+	hashes := map[string]bool{}
+	for _, object := range objects {
+		hashes[filepath.Base(object[:len(object)-len(".yaml")])] = true
+	}
+	return hashes
+}
+
+func TestContentAddressedMocksAreStoredOnce(t *testing.T) {
+	ys := newContentAddressedStore(t)
+	first := writeMocks(t, ys, "test-set-0", "/users", "/orders")
+	second := writeMocks(t, ys, "test-set-1", "/users")
+
+	if _, err := os.Stat(filepath.Join(ys.MockPath, "test-set-0", "mocks.yaml")); !os.IsNotExist(err) {
+		t.Errorf("wrote the flat mock file in the content addressed layout: %v", err)
+	}
+	objects := storedObjects(t, ys)
+	if len(objects) != 2 || !objects[first[0].ContentHash()] || !objects[first[1].ContentHash()] {
+		t.Fatalf("stored the mocks %v, want /users once and /orders", objects)
+	}
+	if second[0].ContentHash() != first[0].ContentHash() {
+		t.Fatalf("got two hashes for the same mock")
+	}
+
+	for testSet, want := range map[string][]string{"test-set-0": {"/users", "/orders"}, "test-set-1": {"/users"}} {
+		mocks, err := ys.readMocks(testSet)
+		if err != nil {
+			t.Fatalf("failed to read the mocks of %s: %v", testSet, err)
+		}
+		if len(mocks) != len(want) {
+			t.Fatalf("read %d mocks from %s, want %d", len(mocks), testSet, len(want))
+		}
+		for i, mock := range mocks {
+			if mock.Spec.HttpReq.URL != want[i] {
+				t.Errorf("%s: got mock %d of %s, want %s", testSet, i, mock.Spec.HttpReq.URL, want[i])
+			}
+		}
+	}
+	mocks, err := ys.readMocks("test-set-1")
+	if err != nil {
+		t.Fatalf("failed to read the mocks of test-set-1: %v", err)
+	}
+	if mocks[0].Name != second[0].Name {
+		t.Errorf("got the mock named %s, want the name %s of its test-set", mocks[0].Name, second[0].Name)
+	}
+}
+
+func TestContentAddressedMocksAreAppendedConcurrently(t *testing.T) {
+	ys := newContentAddressedStore(t)
+	ctx := context.WithValue(context.Background(), "testSet", "test-set-0")
+	const recorded = 50
+	var wg sync.WaitGroup
+	for i := 0; i < recorded; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := ys.WriteMock(httpMock(fmt.Sprintf("/users/%d", i)), ctx); err != nil {
+				t.Errorf("failed to write the mock %d: %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := readMockIndex(filepath.Join(ys.MockPath, "test-set-0"))
+	if err != nil {
+		t.Fatalf("failed to read the mocks index: %v", err)
+	}
+	if len(entries) != recorded {
+		t.Fatalf("indexed %d mocks, want %d", len(entries), recorded)
+	}
+	if objects := storedObjects(t, ys); len(objects) != recorded {
+		t.Fatalf("stored %d mocks, want %d", len(objects), recorded)
+	}
+}
+
+func TestRewrittenIndexCollectsTheUnusedMocks(t *testing.T) {
+	ys := newContentAddressedStore(t)
+	first := writeMocks(t, ys, "test-set-0", "/users", "/orders", "/items")
+	writeMocks(t, ys, "test-set-1", "/orders")
+
+	// only /users was used by test-set-0, /orders is still used by test-set-1
+	if err := ys.UpdateMocks([]platform.KindSpecifier{first[0]}, "test-set-0"); err != nil {
+		t.Fatalf("failed to remove the unused mocks: %v", err)
+	}
+	objects := storedObjects(t, ys)
+	if len(objects) != 2 || !objects[first[0].ContentHash()] || !objects[first[1].ContentHash()] {
+		t.Fatalf("kept the mocks %v, want /users and the shared /orders", objects)
+	}
+	if stored := storedURLs(t, ys, "test-set-0"); len(stored) != 1 {
+		t.Fatalf("kept the mocks %v in test-set-0, want /users", stored)
+	}
+
+	// a disabled mock stays in the store
+	if err := ys.SetMockDisabled("test-set-0", first[0].Name, true); err != nil {
+		t.Fatalf("failed to disable the mock: %v", err)
+	}
+	if objects := storedObjects(t, ys); !objects[first[0].ContentHash()] {
+		t.Fatalf("collected the disabled mock, kept %v", objects)
+	}
+
+	if err := ys.UpdateMocks(nil, "test-set-1"); err != nil {
+		t.Fatalf("failed to remove the unused mocks: %v", err)
+	}
+	if objects := storedObjects(t, ys); len(objects) != 1 || !objects[first[0].ContentHash()] {
+		t.Fatalf("kept the mocks %v, want the disabled /users only", objects)
+	}
+	prefixes, err := os.ReadDir(filepath.Join(ys.MockPath, mockStoreDir))
+	if err != nil {
+		t.Fatalf("failed to list the mock store: %v", err)
+	}
+	if len(prefixes) != 1 {
+		t.Errorf("kept %d directories in the mock store, want the one of /users", len(prefixes))
+	}
+}
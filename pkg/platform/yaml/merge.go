@@ -0,0 +1,91 @@
+package yaml
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// readMocks returns all the mocks recorded for the test-set.
+func (ys *Yaml) readMocks(testSet string) ([]*models.Mock, error) {
+	return ys.readMocksFrom(filepath.Join(ys.MockPath, testSet), ys.mockFileName())
+}
+
+// MergeMocks appends the mocks of srcTestSet to the mocks of dstTestSet. Mocks
+// having the same content as an existing one are skipped, while mocks having
+// the same request as an existing one but a different response are merged and
+// reported as conflicts. The noisy fields of the MySQL OK packets, see
+// MySQLOKNoise, and the volatile metadata, see models.VolatileMetadata, are
+// not a difference.
+func (ys *Yaml) MergeMocks(srcTestSet, dstTestSet string) (*models.MockMergeReport, error) {
+	report := &models.MockMergeReport{}
+
+	srcMocks, err := ys.readMocks(srcTestSet)
+	if err != nil {
+		ys.Logger.Error("failed to read the mocks of the source test-set", zap.Error(err), zap.String("test-set", srcTestSet))
+		return nil, err
+	}
+	dstMocks, err := ys.readMocks(dstTestSet)
+	if err != nil {
+		ys.Logger.Error("failed to read the mocks of the destination test-set", zap.Error(err), zap.String("test-set", dstTestSet))
+		return nil, err
+	}
+
+	contents := map[string]bool{}
+	requests := map[string]*models.Mock{}
+	lastIndex := -1
+	for _, mock := range dstMocks {
+		contents[mock.ContentHashIgnoring(ys.MySQLOKNoise)] = true
+		requests[mock.RequestHash()] = mock
+		lastIndex = maxMockIndex(lastIndex, mock.Name)
+	}
+
+	mockPath := filepath.Join(ys.MockPath, dstTestSet)
+	for _, mock := range srcMocks {
+		contentHash := mock.ContentHashIgnoring(ys.MySQLOKNoise)
+		if contents[contentHash] {
+			report.Duplicates++
+			continue
+		}
+		incomingName := mock.Name
+		lastIndex++
+		mock.Name = fmt.Sprint("mock-", lastIndex)
+		requestHash := mock.RequestHash()
+		if existing, ok := requests[requestHash]; ok {
+			report.Conflicts = append(report.Conflicts, models.MockConflict{
+				Kind:     mock.Kind,
+				Existing: existing.Name,
+				Incoming: srcTestSet + "/" + incomingName,
+			})
+		}
+
+		err = ys.appendMock(mockPath, mock)
+		if err != nil {
+			return nil, err
+		}
+		contents[contentHash] = true
+		// the later mocks of the source conflict with the merged ones too
+		if _, ok := requests[requestHash]; !ok {
+			requests[requestHash] = mock
+		}
+		report.Merged++
+	}
+
+	if len(report.Conflicts) > 0 {
+		ys.Logger.Warn("found mocks with the same request but different responses while merging", zap.Any("conflicts", report.Conflicts))
+	}
+	return report, nil
+}
+
+// maxMockIndex returns the larger of index and the index in the "mock-N" name.
+func maxMockIndex(index int, name string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(name, "mock-"))
+	if err != nil || n < index {
+		return index
+	}
+	return n
+}
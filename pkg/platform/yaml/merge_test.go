@@ -0,0 +1,138 @@
+package yaml
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestMaxMockIndex(t *testing.T) {
+	for _, tt := range []struct {
+		index int
+		name  string
+		want  int
+	}{
+		{index: -1, name: "mock-0", want: 0},
+		{index: 3, name: "mock-12", want: 12},
+		{index: 12, name: "mock-3", want: 12},
+		{index: 4, name: "mocks", want: 4},
+		{index: 4, name: "mock-a", want: 4},
+	} {
+		if got := maxMockIndex(tt.index, tt.name); got != tt.want {
+			t.Errorf("maxMockIndex(%d, %q): got %d, want %d", tt.index, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeMocks(t *testing.T) {
+	ys := newTestStore(t)
+	dst := writeMocks(t, ys, "test-set-0", "/users", "/orders")
+	writeMocks(t, ys, "test-set-1", "/users", "/items")
+	// the same request as the /orders of test-set-0, with another response
+	conflicting := httpMock("/orders")
+	conflicting.Spec.HttpResp.Body = "no orders"
+	ctx := context.WithValue(context.Background(), "testSet", "test-set-1")
+	if err := ys.WriteMock(conflicting, ctx); err != nil {
+		t.Fatalf("failed to write the conflicting mock: %v", err)
+	}
+	lastIndex := maxMockIndex(maxMockIndex(-1, dst[0].Name), dst[1].Name)
+
+	report, err := ys.MergeMocks("test-set-1", "test-set-0")
+	if err != nil {
+		t.Fatalf("failed to merge the mocks: %v", err)
+	}
+	if report.Duplicates != 1 || report.Merged != 2 {
+		t.Errorf("got %d duplicates and %d merged mocks, want 1 and 2", report.Duplicates, report.Merged)
+	}
+	if len(report.Conflicts) != 1 {
+		t.Fatalf("got the conflicts %+v, want the one of /orders", report.Conflicts)
+	}
+	if conflict := report.Conflicts[0]; conflict.Existing != dst[1].Name || conflict.Incoming != "test-set-1/"+conflicting.Name {
+		t.Errorf("got the conflict of %s with %s, want %s with test-set-1/%s", conflict.Existing, conflict.Incoming, dst[1].Name, conflicting.Name)
+	}
+
+	mocks, err := ys.readMocks("test-set-0")
+	if err != nil {
+		t.Fatalf("failed to read the merged mocks: %v", err)
+	}
+	want := []struct{ url, name string }{
+		{"/users", dst[0].Name},
+		{"/orders", dst[1].Name},
+		{"/items", fmt.Sprint("mock-", lastIndex+1)},
+		{"/orders", fmt.Sprint("mock-", lastIndex+2)},
+	}
+	if len(mocks) != len(want) {
+		t.Fatalf("got %d mocks in the merged test-set, want %d", len(mocks), len(want))
+	}
+	for i, mock := range mocks {
+		if mock.Spec.HttpReq.URL != want[i].url || mock.Name != want[i].name {
+			t.Errorf("got mock %s of %s, want %s of %s", mock.Name, mock.Spec.HttpReq.URL, want[i].name, want[i].url)
+		}
+	}
+	if mocks[3].Spec.HttpResp.Body != "no orders" {
+		t.Errorf("got the merged response %q of /orders, want the conflicting one", mocks[3].Spec.HttpResp.Body)
+	}
+	// the source test-set is left as it was
+	if stored := storedURLs(t, ys, "test-set-1"); len(stored) != 3 {
+		t.Errorf("got the mocks %v of the source test-set, want the three of them", stored)
+	}
+
+	// merging again only finds duplicates
+	report, err = ys.MergeMocks("test-set-1", "test-set-0")
+	if err != nil {
+		t.Fatalf("failed to merge the mocks again: %v", err)
+	}
+	if report.Duplicates != 3 || report.Merged != 0 || len(report.Conflicts) != 0 {
+		t.Errorf("got the report %+v merging again, want 3 duplicates only", report)
+	}
+}
+
+func TestMergeMocksConflictingInTheSource(t *testing.T) {
+	ys := newTestStore(t)
+	writeMocks(t, ys, "test-set-0", "/users")
+	writeMocks(t, ys, "test-set-1", "/orders")
+	conflicting := httpMock("/orders")
+	conflicting.Spec.HttpResp.Body = "no orders"
+	if err := ys.WriteMock(conflicting, context.WithValue(context.Background(), "testSet", "test-set-1")); err != nil {
+		t.Fatalf("failed to write the conflicting mock: %v", err)
+	}
+
+	report, err := ys.MergeMocks("test-set-1", "test-set-0")
+	if err != nil {
+		t.Fatalf("failed to merge the mocks: %v", err)
+	}
+	if report.Merged != 2 || len(report.Conflicts) != 1 {
+		t.Fatalf("got the report %+v, want both mocks merged and their conflict", report)
+	}
+	mocks, err := ys.readMocks("test-set-0")
+	if err != nil {
+		t.Fatalf("failed to read the merged mocks: %v", err)
+	}
+	if conflict := report.Conflicts[0]; conflict.Existing != mocks[1].Name || conflict.Incoming != "test-set-1/"+conflicting.Name {
+		t.Errorf("got the conflict of %s with %s, want %s with test-set-1/%s", conflict.Existing, conflict.Incoming, mocks[1].Name, conflicting.Name)
+	}
+}
+
+// TestMergeMocksIgnoringVolatileMetadata merges the same exchange recorded
+// over another connection by another test case.
+func TestMergeMocksIgnoringVolatileMetadata(t *testing.T) {
+	ys := newTestStore(t)
+	for testSet, connection := range map[string]string{"test-set-0": "7", "test-set-1": "12"} {
+		mock := httpMock("/users")
+		mock.Spec.Metadata[models.ConnectionIDMetadata] = connection
+		mock.Spec.Metadata[models.TestNameMetadata] = "test-" + connection
+		if err := ys.WriteMock(mock, context.WithValue(context.Background(), "testSet", testSet)); err != nil {
+			t.Fatalf("failed to write the mock of %s: %v", testSet, err)
+		}
+	}
+
+	report, err := ys.MergeMocks("test-set-1", "test-set-0")
+	if err != nil {
+		t.Fatalf("failed to merge the mocks: %v", err)
+	}
+	if report.Duplicates != 1 || report.Merged != 0 || len(report.Conflicts) != 0 {
+		t.Errorf("got the report %+v, want the exchange as a duplicate", report)
+	}
+}
@@ -123,6 +123,7 @@ func EncodeMock(mock *models.Mock, logger *zap.Logger) (*NetworkTrafficDoc, erro
 			CreatedAt:        mock.Spec.Created,
 			ReqTimestampMock: mock.Spec.ReqTimestampMock,
 			ResTimestampMock: mock.Spec.ResTimestampMock,
+			Disabled:         mock.Spec.Disabled,
 		}
 
 		err := yamlDoc.Spec.Encode(mongoSpec)
@@ -131,7 +132,7 @@ func EncodeMock(mock *models.Mock, logger *zap.Logger) (*NetworkTrafficDoc, erro
 			return nil, err
 		}
 
-	case models.HTTP:
+	case models.HTTP, models.HTTP3:
 		httpSpec := spec.HttpSpec{
 			Metadata:         mock.Spec.Metadata,
 			Request:          *mock.Spec.HttpReq,
@@ -139,6 +140,7 @@ func EncodeMock(mock *models.Mock, logger *zap.Logger) (*NetworkTrafficDoc, erro
 			Created:          mock.Spec.Created,
 			ReqTimestampMock: mock.Spec.ReqTimestampMock,
 			ResTimestampMock: mock.Spec.ResTimestampMock,
+			Disabled:         mock.Spec.Disabled,
 		}
 		err := yamlDoc.Spec.Encode(httpSpec)
 		if err != nil {
@@ -152,6 +154,7 @@ func EncodeMock(mock *models.Mock, logger *zap.Logger) (*NetworkTrafficDoc, erro
 			GenericResponses: mock.Spec.GenericResponses,
 			ReqTimestampMock: mock.Spec.ReqTimestampMock,
 			ResTimestampMock: mock.Spec.ResTimestampMock,
+			Disabled:         mock.Spec.Disabled,
 		}
 		err := yamlDoc.Spec.Encode(genericSpec)
 		if err != nil {
@@ -166,6 +169,7 @@ func EncodeMock(mock *models.Mock, logger *zap.Logger) (*NetworkTrafficDoc, erro
 			PostgresResponses: mock.Spec.PostgresResponses,
 			ReqTimestampMock:  mock.Spec.ReqTimestampMock,
 			ResTimestampMock:  mock.Spec.ResTimestampMock,
+			Disabled:          mock.Spec.Disabled,
 		}
 
 		err := yamlDoc.Spec.Encode(postgresSpec)
@@ -173,12 +177,27 @@ func EncodeMock(mock *models.Mock, logger *zap.Logger) (*NetworkTrafficDoc, erro
 			logger.Error("failed to marshal postgres of external call into yaml", zap.Error(err))
 			return nil, err
 		}
+	case models.Redis:
+		redisSpec := spec.RedisSpec{
+			Metadata:         mock.Spec.Metadata,
+			Requests:         mock.Spec.RedisRequests,
+			Responses:        mock.Spec.RedisResponses,
+			ReqTimestampMock: mock.Spec.ReqTimestampMock,
+			ResTimestampMock: mock.Spec.ResTimestampMock,
+			Disabled:         mock.Spec.Disabled,
+		}
+		err := yamlDoc.Spec.Encode(redisSpec)
+		if err != nil {
+			logger.Error("failed to marshal the redis input-output as yaml", zap.Error(err))
+			return nil, err
+		}
 	case models.GRPC_EXPORT:
 		gRPCSpec := spec.GrpcSpec{
 			GrpcReq:          *mock.Spec.GRPCReq,
 			GrpcResp:         *mock.Spec.GRPCResp,
 			ReqTimestampMock: mock.Spec.ReqTimestampMock,
 			ResTimestampMock: mock.Spec.ResTimestampMock,
+			Disabled:         mock.Spec.Disabled,
 		}
 		err := yamlDoc.Spec.Encode(gRPCSpec)
 		if err != nil {
@@ -219,6 +238,7 @@ func EncodeMock(mock *models.Mock, logger *zap.Logger) (*NetworkTrafficDoc, erro
 			Requests:  requests,
 			Response:  responses,
 			CreatedAt: mock.Spec.Created,
+			Disabled:  mock.Spec.Disabled,
 		}
 		err := yamlDoc.Spec.Encode(sqlSpec)
 		if err != nil {
@@ -297,7 +317,7 @@ func decodeMocks(yamlMocks []*NetworkTrafficDoc, logger *zap.Logger) ([]*models.
 			continue
 		}
 		switch m.Kind {
-		case models.HTTP:
+		case models.HTTP, models.HTTP3:
 			httpSpec := spec.HttpSpec{}
 			err := m.Spec.Decode(&httpSpec)
 			if err != nil {
@@ -312,6 +332,7 @@ func decodeMocks(yamlMocks []*NetworkTrafficDoc, logger *zap.Logger) ([]*models.
 				Created:          httpSpec.Created,
 				ReqTimestampMock: httpSpec.ReqTimestampMock,
 				ResTimestampMock: httpSpec.ResTimestampMock,
+				Disabled:         httpSpec.Disabled,
 			}
 		case models.Mongo:
 			mongoSpec := spec.MongoSpec{}
@@ -338,6 +359,7 @@ func decodeMocks(yamlMocks []*NetworkTrafficDoc, logger *zap.Logger) ([]*models.
 				GRPCReq:          &grpcSpec.GrpcReq,
 				ReqTimestampMock: grpcSpec.ReqTimestampMock,
 				ResTimestampMock: grpcSpec.ResTimestampMock,
+				Disabled:         grpcSpec.Disabled,
 			}
 		case models.GENERIC:
 			genericSpec := spec.GenericSpec{}
@@ -352,6 +374,7 @@ func decodeMocks(yamlMocks []*NetworkTrafficDoc, logger *zap.Logger) ([]*models.
 				GenericResponses: genericSpec.GenericResponses,
 				ReqTimestampMock: genericSpec.ReqTimestampMock,
 				ResTimestampMock: genericSpec.ResTimestampMock,
+				Disabled:         genericSpec.Disabled,
 			}
 
 		case models.Postgres:
@@ -370,6 +393,22 @@ func decodeMocks(yamlMocks []*NetworkTrafficDoc, logger *zap.Logger) ([]*models.
 				PostgresResponses: PostSpec.PostgresResponses,
 				ReqTimestampMock:  PostSpec.ReqTimestampMock,
 				ResTimestampMock:  PostSpec.ResTimestampMock,
+				Disabled:          PostSpec.Disabled,
+			}
+		case models.Redis:
+			redisSpec := spec.RedisSpec{}
+			err := m.Spec.Decode(&redisSpec)
+			if err != nil {
+				logger.Error("failed to unmarshal a yaml doc into redis mock", zap.Error(err), zap.Any("mock name", m.Name))
+				return nil, err
+			}
+			mock.Spec = models.MockSpec{
+				Metadata:         redisSpec.Metadata,
+				RedisRequests:    redisSpec.Requests,
+				RedisResponses:   redisSpec.Responses,
+				ReqTimestampMock: redisSpec.ReqTimestampMock,
+				ResTimestampMock: redisSpec.ResTimestampMock,
+				Disabled:         redisSpec.Disabled,
 			}
 		case models.SQL:
 			mysqlSpec := spec.MySQLSpec{}
@@ -397,6 +436,7 @@ func decodeMySqlMessage(yamlSpec *spec.MySQLSpec, logger *zap.Logger) (*models.M
 	mockSpec := models.MockSpec{
 		Metadata: yamlSpec.Metadata,
 		Created:  yamlSpec.CreatedAt,
+		Disabled: yamlSpec.Disabled,
 	}
 	requests := []models.MySQLRequest{}
 	for _, v := range yamlSpec.Requests {
@@ -470,10 +510,18 @@ func decodeMySqlMessage(yamlSpec *spec.MySQLSpec, logger *zap.Logger) (*models.M
 			}
 			req.Message = requestMessage
 		case "AUTH_SWITCH_RESPONSE":
-			requestMessage := &models.AuthSwitchRequestPacket{}
+			requestMessage := &models.AuthSwitchResponsePacket{}
 			err := v.Message.Decode(requestMessage)
 			if err != nil {
-				logger.Error(Emoji+"failed to unmarshal yml document into MySQLComStmtClosePacket", zap.Error(err))
+				logger.Error(Emoji+"failed to unmarshal yml document into AuthSwitchResponsePacket", zap.Error(err))
+				return nil, err
+			}
+			req.Message = requestMessage
+		case "COM_SET_OPTION":
+			requestMessage := &models.MySQLComSetOptionPacket{}
+			err := v.Message.Decode(requestMessage)
+			if err != nil {
+				logger.Error(Emoji+"failed to unmarshal yml document into MySQLComSetOptionPacket", zap.Error(err))
 				return nil, err
 			}
 			req.Message = requestMessage
@@ -546,6 +594,14 @@ func decodeMySqlMessage(yamlSpec *spec.MySQLSpec, logger *zap.Logger) (*models.M
 				return nil, err
 			}
 			resp.Message = responseMessage
+		case "MySQLEOF":
+			responseMessage := &models.MySQLEOFPacket{}
+			err := v.Message.Decode(responseMessage)
+			if err != nil {
+				logger.Error(Emoji+"failed to unmarshal yml document into MySQLEOFPacket ", zap.Error(err))
+				return nil, err
+			}
+			resp.Message = responseMessage
 		case "MySQLErr":
 			responseMessage := &models.MySQLERRPacket{}
 			err := v.Message.Decode(responseMessage)
@@ -567,6 +623,7 @@ func decodeMongoMessage(yamlSpec *spec.MongoSpec, logger *zap.Logger) (*models.M
 		Created:          yamlSpec.CreatedAt,
 		ReqTimestampMock: yamlSpec.ReqTimestampMock,
 		ResTimestampMock: yamlSpec.ResTimestampMock,
+		Disabled:         yamlSpec.Disabled,
 	}
 
 	// mongo request
@@ -0,0 +1,56 @@
+package yaml
+
+import (
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+func TestHttp3MockRoundTrip(t *testing.T) {
+	mock := &models.Mock{
+		Version: models.V1Beta1,
+		Name:    "mock-0",
+		Kind:    models.HTTP3,
+		Spec: models.MockSpec{
+			Metadata: map[string]string{"name": "Http3"},
+			HttpReq: &models.HttpReq{
+				Method:     "GET",
+				ProtoMajor: 3,
+				URL:        "https://api.example.com/health",
+				Header:     map[string]string{"User-Agent": "quic-go HTTP/3"},
+			},
+			HttpResp: &models.HttpResp{
+				StatusCode: 200,
+				ProtoMajor: 3,
+				Header:     map[string]string{"Content-Type": "text/plain"},
+				Body:       "ok",
+			},
+		},
+	}
+	doc, err := EncodeMock(mock, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to encode the mock: %v", err)
+	}
+	// the mock is read back from its yaml document
+	data, err := yamlLib.Marshal(doc)
+	if err != nil {
+		t.Fatalf("failed to marshal the mock: %v", err)
+	}
+	read := &NetworkTrafficDoc{}
+	if err := yamlLib.Unmarshal(data, read); err != nil {
+		t.Fatalf("failed to unmarshal the mock: %v", err)
+	}
+	mocks, err := decodeMocks([]*NetworkTrafficDoc{read}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to decode the mock: %v", err)
+	}
+	if len(mocks) != 1 || mocks[0].Kind != models.HTTP3 {
+		t.Fatalf("decoded %d mocks, want the Http3 one", len(mocks))
+	}
+	got := mocks[0].Spec
+	if got.HttpReq.URL != mock.Spec.HttpReq.URL || got.HttpReq.ProtoMajor != 3 || got.HttpResp.Body != "ok" || got.HttpResp.Header["Content-Type"] != "text/plain" {
+		t.Fatalf("decoded the request %+v and response %+v, want the encoded ones", got.HttpReq, got.HttpResp)
+	}
+}
@@ -0,0 +1,34 @@
+package yaml
+
+import (
+	"context"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// ReadMocks returns all the mocks of the test-set, the config mocks and the
+// mocks of the test cases alike, along with their whole spec. Only the mocks
+// selected by the filter, a *models.MockFilter, are returned when it is not
+// nil. The disabled mocks are returned as well.
+func (ys *Yaml) ReadMocks(ctx context.Context, testSet string, filterRead platform.KindSpecifier) ([]platform.KindSpecifier, error) {
+	filter, _ := filterRead.(*models.MockFilter)
+
+	mocks, err := ys.readMocks(testSet)
+	if err != nil {
+		ys.Logger.Error("failed to read the mocks of the test-set", zap.Error(err), zap.String("test-set", testSet))
+		return nil, err
+	}
+
+	selected := make([]platform.KindSpecifier, 0, len(mocks))
+	for _, mock := range mocks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if filter == nil || filter.Selects(mock) {
+			selected = append(selected, mock)
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,65 @@
+package yaml
+
+import (
+	"context"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func postgresMock(query string, packetTypes ...string) *models.Mock {
+	return &models.Mock{
+		Version: models.GetVersion(),
+		Kind:    models.Postgres,
+		Spec: models.MockSpec{
+			Metadata:          map[string]string{"query": query},
+			PostgresRequests:  []models.Backend{{PacketTypes: packetTypes, Identfier: "ClientRequest"}},
+			PostgresResponses: []models.Frontend{{PacketTypes: []string{"C", "Z"}, Identfier: "ServerResponse"}},
+		},
+	}
+}
+
+func TestReadMocksWithAFilter(t *testing.T) {
+	ys := newTestStore(t)
+	ctx := context.WithValue(context.Background(), "testSet", "test-set-0")
+	simple := postgresMock("SELECT 1", "Q")
+	extended := postgresMock("SELECT $1", "P", "B", "E", "S")
+	disabled := postgresMock("SELECT 2", "Q")
+	disabled.Spec.Disabled = true
+	for _, mock := range []*models.Mock{httpMock("/users"), simple, extended, disabled} {
+		if err := ys.WriteMock(mock, ctx); err != nil {
+			t.Fatalf("failed to write the mock: %v", err)
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		filter *models.MockFilter
+		want   []string
+	}{
+		{name: "all", want: []string{"", "SELECT 1", "SELECT $1", "SELECT 2"}},
+		{name: "kind", filter: &models.MockFilter{Kinds: []models.Kind{models.Postgres}}, want: []string{"SELECT 1", "SELECT $1", "SELECT 2"}},
+		{name: "simple queries", filter: &models.MockFilter{Kinds: []models.Kind{models.Postgres}, PostgresPacketTypes: []string{"Q"}}, want: []string{"SELECT 1", "SELECT 2"}},
+		{name: "http", filter: &models.MockFilter{Kinds: []models.Kind{models.HTTP}, PostgresPacketTypes: []string{"Q"}}, want: []string{}},
+		{name: "match", filter: &models.MockFilter{PostgresPacketTypes: []string{"Q", "P"}, Match: func(mock *models.Mock) bool { return !mock.Spec.Disabled }}, want: []string{"SELECT 1", "SELECT $1"}},
+	} {
+		read, err := ys.ReadMocks(context.Background(), "test-set-0", tt.filter)
+		if err != nil {
+			t.Fatalf("%s: failed to read the mocks: %v", tt.name, err)
+		}
+		if len(read) != len(tt.want) {
+			t.Fatalf("%s: got %d mocks, want %d", tt.name, len(read), len(tt.want))
+		}
+		for i, mock := range read {
+			if query := mock.(*models.Mock).Spec.Metadata["query"]; query != tt.want[i] {
+				t.Errorf("%s: got the mock of %q, want %q", tt.name, query, tt.want[i])
+			}
+		}
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ys.ReadMocks(canceled, "test-set-0", nil); err == nil {
+		t.Errorf("read the mocks with a canceled context")
+	}
+}
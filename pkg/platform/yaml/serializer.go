@@ -0,0 +1,369 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// MockSerializer encodes the mocks of a test-set to a file format. The mocks
+// are appended one at a time to the mock file of the test-set, which is
+// decoded at once.
+type MockSerializer interface {
+	// Extension is the extension of the mock files, without the dot.
+	Extension() string
+	// Marshal encodes the mock to the bytes appended to the mock file.
+	Marshal(mock *models.Mock) ([]byte, error)
+	// Unmarshal decodes all the mocks of a mock file.
+	Unmarshal(data []byte) ([]*models.Mock, error)
+}
+
+var (
+	mockSerializersMu sync.RWMutex
+	mockSerializers   = map[string]MockSerializer{
+		"yaml": YAMLMockSerializer{},
+		"json": JSONMockSerializer{},
+	}
+)
+
+// RegisterMockSerializer makes a mock format available to SetMockFormat.
+func RegisterMockSerializer(format string, serializer MockSerializer) {
+	mockSerializersMu.Lock()
+	defer mockSerializersMu.Unlock()
+	mockSerializers[strings.ToLower(format)] = serializer
+}
+
+// SetMockFormat selects the registered format of the mock files, yaml by
+// default. The content addressed layout always stores yaml files.
+func (ys *Yaml) SetMockFormat(format string) error {
+	mockSerializersMu.RLock()
+	defer mockSerializersMu.RUnlock()
+	serializer, ok := mockSerializers[strings.ToLower(format)]
+	if !ok {
+		return fmt.Errorf("unknown mock format %q", format)
+	}
+	ys.MockSerializer = withLogger(serializer, ys.Logger)
+	return nil
+}
+
+// withLogger returns the serializer of this package logging to the logger.
+// The registered serializers hold no logger as they are shared by the stores.
+func withLogger(serializer MockSerializer, logger *zap.Logger) MockSerializer {
+	switch s := serializer.(type) {
+	case YAMLMockSerializer:
+		s.Logger = logger
+		return s
+	case JSONMockSerializer:
+		s.Logger = logger
+		return s
+	}
+	return serializer
+}
+
+// mockSerializerOf returns the serializer of the mock file of the test-set
+// directory: the one of the selected format, else the one of the format the
+// mocks were recorded in. It returns nil for the yaml files of the default
+// store.
+func (ys *Yaml) mockSerializerOf(path, name string) MockSerializer {
+	if ys.MockSerializer != nil {
+		return ys.MockSerializer
+	}
+	if _, err := os.Stat(filepath.Join(path, name+".yaml")); err == nil {
+		return nil
+	}
+	if serializer := detectMockSerializer(path, name); serializer != nil {
+		return withLogger(serializer, ys.Logger)
+	}
+	return nil
+}
+
+// detectMockSerializer returns the serializer of the registered format whose
+// mock file exists in the test-set directory, if any.
+func detectMockSerializer(path, name string) MockSerializer {
+	mockSerializersMu.RLock()
+	defer mockSerializersMu.RUnlock()
+	for _, serializer := range mockSerializers {
+		if _, err := os.Stat(filepath.Join(path, name+"."+serializer.Extension())); err == nil {
+			return serializer
+		}
+	}
+	return nil
+}
+
+func serializedMockPath(serializer MockSerializer, path, name string) (string, error) {
+	return util.ValidatePath(filepath.Join(path, name+"."+serializer.Extension()))
+}
+
+func readSerializedMocks(serializer MockSerializer, path, name string) ([]*models.Mock, error) {
+	mockPath, err := serializedMockPath(serializer, path, name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(mockPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return []*models.Mock{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return serializer.Unmarshal(data)
+}
+
+func appendSerializedMock(serializer MockSerializer, path, name string, mock *models.Mock, logger *zap.Logger) error {
+	data, err := serializer.Marshal(mock)
+	if err != nil {
+		return err
+	}
+	mockPath, err := serializedMockPath(serializer, path, name)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(path, os.ModePerm)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(mockPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, os.ModePerm)
+	if err != nil {
+		logger.Error("failed to open the mock file", zap.Error(err), zap.String("path", mockPath))
+		return err
+	}
+	defer file.Close()
+	_, err = file.Write(data)
+	return err
+}
+
+// YAMLMockSerializer stores the mocks as the documents of a yaml file, as the
+// default store does.
+type YAMLMockSerializer struct {
+	Logger *zap.Logger
+}
+
+func (s YAMLMockSerializer) logger() *zap.Logger {
+	if s.Logger == nil {
+		return zap.NewNop()
+	}
+	return s.Logger
+}
+
+func (s YAMLMockSerializer) Extension() string {
+	return "yaml"
+}
+
+func (s YAMLMockSerializer) Marshal(mock *models.Mock) ([]byte, error) {
+	doc, err := EncodeMock(mock, s.logger())
+	if err != nil {
+		return nil, err
+	}
+	d, err := yamlLib.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte("---\n"), d...), nil
+}
+
+func (s YAMLMockSerializer) Unmarshal(data []byte) ([]*models.Mock, error) {
+	decoder := yamlLib.NewDecoder(bytes.NewReader(data))
+	docs := []*NetworkTrafficDoc{}
+	for {
+		var doc NetworkTrafficDoc
+		err := decoder.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the yaml mock documents. error: %v", err.Error())
+		}
+		docs = append(docs, &doc)
+	}
+	return decodeMocks(docs, s.logger())
+}
+
+// JSONMockSerializer stores the mocks as JSON lines. The specs go through
+// the yaml encoding of the mocks, so that they decode to the same types.
+type JSONMockSerializer struct {
+	Logger *zap.Logger
+}
+
+// jsonMockDoc is the JSON form of a NetworkTrafficDoc.
+type jsonMockDoc struct {
+	Version models.Version  `json:"version"`
+	Kind    models.Kind     `json:"kind"`
+	Name    string          `json:"name"`
+	Spec    json.RawMessage `json:"spec"`
+	Curl    string          `json:"curl,omitempty"`
+}
+
+// jsonBinaryKey marks the yaml !!binary values in the JSON form of a spec.
+const jsonBinaryKey = "$binary"
+
+// jsonNumberKey marks the yaml numbers without a JSON form, such as .inf and
+// .nan, in the JSON form of a spec.
+const jsonNumberKey = "$number"
+
+func (s JSONMockSerializer) Extension() string {
+	return "json"
+}
+
+func (s JSONMockSerializer) Marshal(mock *models.Mock) ([]byte, error) {
+	doc, err := EncodeMock(mock, YAMLMockSerializer(s).logger())
+	if err != nil {
+		return nil, err
+	}
+	spec, err := json.Marshal(yamlNodeToJSON(&doc.Spec))
+	if err != nil {
+		return nil, err
+	}
+	line, err := json.Marshal(jsonMockDoc{
+		Version: doc.Version,
+		Kind:    doc.Kind,
+		Name:    doc.Name,
+		Spec:    spec,
+		Curl:    doc.Curl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+func (s JSONMockSerializer) Unmarshal(data []byte) ([]*models.Mock, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	docs := []*NetworkTrafficDoc{}
+	for {
+		var line jsonMockDoc
+		err := decoder.Decode(&line)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the json mock documents. error: %v", err.Error())
+		}
+		specDecoder := json.NewDecoder(bytes.NewReader(line.Spec))
+		specDecoder.UseNumber()
+		var spec interface{}
+		err = specDecoder.Decode(&spec)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the spec of the json mock %s. error: %v", line.Name, err.Error())
+		}
+		docs = append(docs, &NetworkTrafficDoc{
+			Version: line.Version,
+			Kind:    line.Kind,
+			Name:    line.Name,
+			Spec:    *jsonToYAMLNode(spec),
+			Curl:    line.Curl,
+		})
+	}
+	return decodeMocks(docs, YAMLMockSerializer(s).logger())
+}
+
+// yamlNodeToJSON converts a yaml node to the values of its JSON form, keeping
+// the numbers as they were written.
+func yamlNodeToJSON(node *yamlLib.Node) interface{} {
+	switch node.Kind {
+	case yamlLib.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return yamlNodeToJSON(node.Content[0])
+	case yamlLib.AliasNode:
+		return yamlNodeToJSON(node.Alias)
+	case yamlLib.MappingNode:
+		object := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			object[node.Content[i].Value] = yamlNodeToJSON(node.Content[i+1])
+		}
+		return object
+	case yamlLib.SequenceNode:
+		array := make([]interface{}, len(node.Content))
+		for i, child := range node.Content {
+			array[i] = yamlNodeToJSON(child)
+		}
+		return array
+	}
+	switch node.ShortTag() {
+	case "!!int", "!!float":
+		return yamlNumberToJSON(node)
+	case "!!bool":
+		return node.Value == "true"
+	case "!!null":
+		return nil
+	case "!!binary":
+		return map[string]interface{}{jsonBinaryKey: node.Value}
+	}
+	return node.Value
+}
+
+// yamlNumberToJSON returns the JSON number of a yaml number. The numbers
+// written in a form JSON lacks, such as 0x1F or 1_000, are converted, while
+// the ones without a JSON number, such as .inf, are marked.
+func yamlNumberToJSON(node *yamlLib.Node) interface{} {
+	if json.Valid([]byte(node.Value)) {
+		return json.Number(node.Value)
+	}
+	if node.ShortTag() == "!!int" {
+		var i int64
+		if err := node.Decode(&i); err == nil {
+			return json.Number(strconv.FormatInt(i, 10))
+		}
+		var u uint64
+		if err := node.Decode(&u); err == nil {
+			return json.Number(strconv.FormatUint(u, 10))
+		}
+	} else {
+		var f float64
+		if err := node.Decode(&f); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	return map[string]interface{}{jsonNumberKey: node.Value}
+}
+
+// jsonToYAMLNode converts the values decoded from JSON, with numbers, back to
+// a yaml node.
+func jsonToYAMLNode(value interface{}) *yamlLib.Node {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if binary, ok := v[jsonBinaryKey].(string); ok && len(v) == 1 {
+			return &yamlLib.Node{Kind: yamlLib.ScalarNode, Tag: "!!binary", Value: binary}
+		}
+		// the tag of the plain scalar is resolved from its value
+		if number, ok := v[jsonNumberKey].(string); ok && len(v) == 1 {
+			return &yamlLib.Node{Kind: yamlLib.ScalarNode, Value: number}
+		}
+		node := &yamlLib.Node{Kind: yamlLib.MappingNode, Tag: "!!map"}
+		for key, child := range v {
+			node.Content = append(node.Content, &yamlLib.Node{Kind: yamlLib.ScalarNode, Tag: "!!str", Value: key}, jsonToYAMLNode(child))
+		}
+		return node
+	case []interface{}:
+		node := &yamlLib.Node{Kind: yamlLib.SequenceNode, Tag: "!!seq"}
+		for _, child := range v {
+			node.Content = append(node.Content, jsonToYAMLNode(child))
+		}
+		return node
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(string(v), ".eE") {
+			tag = "!!float"
+		}
+		return &yamlLib.Node{Kind: yamlLib.ScalarNode, Tag: tag, Value: string(v)}
+	case bool:
+		return &yamlLib.Node{Kind: yamlLib.ScalarNode, Tag: "!!bool", Value: fmt.Sprint(v)}
+	case nil:
+		return &yamlLib.Node{Kind: yamlLib.ScalarNode, Tag: "!!null", Value: "null"}
+	}
+	return &yamlLib.Node{Kind: yamlLib.ScalarNode, Tag: "!!str", Value: fmt.Sprint(value)}
+}
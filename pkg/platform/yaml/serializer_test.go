@@ -0,0 +1,147 @@
+package yaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// ndjsonMockSerializer is a custom format of the mock files, the JSON lines
+// of the mocks with another extension.
+type ndjsonMockSerializer struct {
+	JSONMockSerializer
+}
+
+func (s ndjsonMockSerializer) Extension() string {
+	return "ndjson"
+}
+
+func genericMock() *models.Mock {
+	return &models.Mock{
+		Version: models.GetVersion(),
+		Name:    "mock-1",
+		Kind:    models.GENERIC,
+		Spec: models.MockSpec{
+			Metadata:         map[string]string{"type": "config"},
+			GenericRequests:  []models.GenericPayload{{Origin: models.FromClient, Message: []models.OutputBinary{{Type: models.String, Data: "PING\r\n"}}}},
+			GenericResponses: []models.GenericPayload{{Origin: models.FromServer, Message: []models.OutputBinary{{Type: "binary", Data: "K1BPTkcNCg=="}}}},
+		},
+	}
+}
+
+func TestMockSerializersRoundTrip(t *testing.T) {
+	httpMock := httpMock("/users")
+	httpMock.Name = "mock-0"
+	httpMock.Spec.Disabled = true
+	for _, serializer := range []MockSerializer{YAMLMockSerializer{}, JSONMockSerializer{Logger: zap.NewNop()}, ndjsonMockSerializer{}} {
+		var data []byte
+		for _, mock := range []*models.Mock{httpMock, genericMock()} {
+			encoded, err := serializer.Marshal(mock)
+			if err != nil {
+				t.Fatalf("%s: failed to marshal the mock %s: %v", serializer.Extension(), mock.Name, err)
+			}
+			data = append(data, encoded...)
+		}
+		mocks, err := serializer.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("%s: failed to unmarshal the mocks: %v", serializer.Extension(), err)
+		}
+		if len(mocks) != 2 {
+			t.Fatalf("%s: got %d mocks, want 2", serializer.Extension(), len(mocks))
+		}
+		if got := mocks[0]; got.Name != "mock-0" || !got.Spec.Disabled || !reflect.DeepEqual(got.Spec.HttpReq, httpMock.Spec.HttpReq) || !reflect.DeepEqual(got.Spec.HttpResp, httpMock.Spec.HttpResp) {
+			t.Errorf("%s: got the http mock %+v, want %+v", serializer.Extension(), got, httpMock)
+		}
+		want := genericMock()
+		if got := mocks[1]; got.Kind != models.GENERIC || !reflect.DeepEqual(got.Spec.GenericRequests, want.Spec.GenericRequests) || !reflect.DeepEqual(got.Spec.GenericResponses, want.Spec.GenericResponses) {
+			t.Errorf("%s: got the generic mock %+v, want %+v", serializer.Extension(), got.Spec, want.Spec)
+		}
+	}
+}
+
+func TestSetMockFormat(t *testing.T) {
+	ys := newTestStore(t)
+	for format, want := range map[string]string{"yaml": "yaml", "JSON": "json"} {
+		if err := ys.SetMockFormat(format); err != nil {
+			t.Fatalf("failed to set the mock format %s: %v", format, err)
+		}
+		if got := ys.MockSerializer.Extension(); got != want {
+			t.Errorf("format %s: got the extension %s, want %s", format, got, want)
+		}
+	}
+	if err := ys.SetMockFormat("toml"); err == nil {
+		t.Errorf("set the unknown mock format toml")
+	}
+}
+
+func TestCustomMockSerializerIsDetected(t *testing.T) {
+	RegisterMockSerializer("NDJSON", ndjsonMockSerializer{})
+	ys := newTestStore(t)
+	if err := ys.SetMockFormat("ndjson"); err != nil {
+		t.Fatalf("failed to set the registered mock format: %v", err)
+	}
+	writeMocks(t, ys, "test-set-0", "/users", "/orders")
+	if _, err := os.Stat(filepath.Join(ys.MockPath, "test-set-0", "mocks.ndjson")); err != nil {
+		t.Fatalf("failed to write the mock file of the format: %v", err)
+	}
+
+	// a store of the default format reads the mocks in the format they were
+	// recorded in
+	detecting := NewYamlStore(ys.TcsPath, ys.MockPath, "", "", zap.NewNop(), nil).(*Yaml)
+	detecting.ShareFiles = ys.ShareFiles
+	if serializer := detecting.mockSerializerOf(filepath.Join(ys.MockPath, "test-set-0"), "mocks"); serializer == nil || serializer.Extension() != "ndjson" {
+		t.Fatalf("got the serializer %v of the recorded mocks, want the ndjson one", serializer)
+	}
+	if stored := storedURLs(t, detecting, "test-set-0"); len(stored) != 2 {
+		t.Fatalf("read the mocks %v, want /users and /orders", stored)
+	}
+
+	// the yaml mock files are read by the default store
+	writeMocks(t, detecting, "test-set-1", "/items")
+	if serializer := detecting.mockSerializerOf(filepath.Join(ys.MockPath, "test-set-1"), "mocks"); serializer != nil {
+		t.Errorf("got the serializer %v of the yaml mocks, want none", serializer)
+	}
+}
+
+func TestJSONFormOfTheYAMLScalars(t *testing.T) {
+	var node yamlLib.Node
+	if err := yamlLib.Unmarshal([]byte("payload: !!binary AAEC\nweight: .inf\nflags: 0x1F\ncount: 1_000\nratio: 0.5\n"), &node); err != nil {
+		t.Fatalf("failed to parse the yaml: %v", err)
+	}
+	data, err := json.Marshal(yamlNodeToJSON(&node))
+	if err != nil {
+		t.Fatalf("failed to marshal the json form: %v", err)
+	}
+	want := `{"count":1000,"flags":31,"payload":{"$binary":"AAEC"},"ratio":0.5,"weight":{"$number":".inf"}}`
+	if string(data) != want {
+		t.Fatalf("got the json form %s, want %s", data, want)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("failed to decode the json form: %v", err)
+	}
+	var decoded struct {
+		Payload string  `yaml:"payload"`
+		Weight  float64 `yaml:"weight"`
+		Flags   int     `yaml:"flags"`
+		Count   int     `yaml:"count"`
+		Ratio   float64 `yaml:"ratio"`
+	}
+	if err := jsonToYAMLNode(value).Decode(&decoded); err != nil {
+		t.Fatalf("failed to decode the yaml node: %v", err)
+	}
+	if decoded.Payload != "\x00\x01\x02" || !math.IsInf(decoded.Weight, 1) || decoded.Flags != 31 || decoded.Count != 1000 || decoded.Ratio != 0.5 {
+		t.Errorf("got the scalars %+v back, want the ones of the yaml", decoded)
+	}
+}
@@ -12,4 +12,5 @@ type GenericSpec struct {
 	GenericResponses []models.GenericPayload `json:"ResponseBin,omitempty"`
 	ReqTimestampMock time.Time               `json:"reqTimestampMock,omitempty"`
 	ResTimestampMock time.Time               `json:"resTimestampMock,omitempty"`
+	Disabled         bool                    `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 }
@@ -11,4 +11,5 @@ type GrpcSpec struct {
 	GrpcResp         models.GrpcResp `json:"grpcResp" yaml:"grpcResp"`
 	ReqTimestampMock time.Time       `json:"reqTimestampMock" yaml:"reqTimestampMock,omitempty"`
 	ResTimestampMock time.Time       `json:"resTimestampMock" yaml:"resTimestampMock,omitempty"`
+	Disabled         bool            `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 }
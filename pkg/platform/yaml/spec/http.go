@@ -15,4 +15,5 @@ type HttpSpec struct {
 	Created          int64                  `json:"created" yaml:"created,omitempty"`
 	ReqTimestampMock time.Time              `json:"reqTimestampMock" yaml:"reqTimestampMock,omitempty"`
 	ResTimestampMock time.Time              `json:"resTimestampMock" yaml:"resTimestampMock,omitempty"`
+	Disabled         bool                   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 }
@@ -14,8 +14,8 @@ type MongoSpec struct {
 	CreatedAt        int64             `json:"created" yaml:"created,omitempty"`
 	ReqTimestampMock time.Time         `json:"reqTimestampMock" yaml:"reqTimestampMock,omitempty"`
 	ResTimestampMock time.Time         `json:"resTimestampMock" yaml:"resTimestampMock,omitempty"`
+	Disabled         bool              `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 }
-
 type RequestYaml struct {
 	Header    *models.MongoHeader `json:"header,omitempty" yaml:"header"`
 	Message   yaml.Node           `json:"message,omitempty" yaml:"message"`
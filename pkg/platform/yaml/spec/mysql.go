@@ -10,8 +10,8 @@ type MySQLSpec struct {
 	Requests  []MysqlRequestYaml  `json:"requests" yaml:"requests"`
 	Response  []MysqlResponseYaml `json:"responses" yaml:"responses"`
 	CreatedAt int64               `json:"created" yaml:"created,omitempty"`
+	Disabled  bool                `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 }
-
 type MysqlRequestYaml struct {
 	Header    *models.MySQLPacketHeader `json:"header,omitempty" yaml:"header"`
 	Message   yaml.Node                 `json:"message,omitempty" yaml:"message"`
@@ -15,4 +15,5 @@ type PostgresSpec struct {
 
 	ReqTimestampMock time.Time `json:"ReqTimestampMock,omitempty"`
 	ResTimestampMock time.Time `json:"ResTimestampMock,omitempty"`
+	Disabled         bool      `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 }
@@ -0,0 +1,16 @@
+package spec
+
+import (
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+type RedisSpec struct {
+	Metadata         map[string]string     `json:"metadata" yaml:"metadata"`
+	Requests         []models.RedisRequest `json:"requests" yaml:"requests"`
+	Responses        []models.RedisReply   `json:"responses" yaml:"responses"`
+	ReqTimestampMock time.Time             `json:"reqTimestampMock" yaml:"reqTimestampMock,omitempty"`
+	ResTimestampMock time.Time             `json:"resTimestampMock" yaml:"resTimestampMock,omitempty"`
+	Disabled         bool                  `json:"disabled,omitempty" yaml:"disabled,omitempty"`
+}
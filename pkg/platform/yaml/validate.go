@@ -0,0 +1,90 @@
+package yaml
+
+import (
+	"path/filepath"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// ValidateMocks reports the mocks of the test-set which recorded requests
+// without a response or responses without a request. Replaying such mocks
+// leaves the application waiting for a response that never comes.
+func (ys *Yaml) ValidateMocks(testSet string) (*models.MockValidationReport, error) {
+	report := &models.MockValidationReport{}
+
+	mocks, err := ys.readMocks(testSet)
+	if err != nil {
+		ys.Logger.Error("failed to read the mocks of the test-set", zap.Error(err), zap.String("test-set", testSet))
+		return nil, err
+	}
+
+	path := filepath.Join(ys.MockPath, testSet)
+	for i, mock := range mocks {
+		requests, responses := recordedMessages(mock)
+		problem := ""
+		switch {
+		case requests > 0 && responses == 0:
+			problem = "recorded requests without a response"
+		case requests == 0 && responses > 0:
+			problem = "recorded responses without a request"
+		default:
+			continue
+		}
+		report.Issues = append(report.Issues, models.MockIssue{
+			File:    ys.mockFile(path, mock),
+			Index:   i,
+			Name:    mock.Name,
+			Kind:    mock.Kind,
+			Problem: problem,
+		})
+	}
+	for _, issue := range report.Issues {
+		ys.Logger.Warn("found a one-sided mock", zap.String("file", issue.File), zap.Int("index", issue.Index), zap.String("mock", issue.Name), zap.Any("kind", issue.Kind), zap.String("problem", issue.Problem))
+	}
+	return report, nil
+}
+
+// recordedMessages returns the number of requests and responses recorded in
+// the mock, whatever its kind.
+func recordedMessages(mock *models.Mock) (int, int) {
+	spec := mock.Spec
+	switch mock.Kind {
+	case models.Postgres:
+		return len(spec.PostgresRequests), len(spec.PostgresResponses)
+	case models.SQL:
+		return len(spec.MySqlRequests), len(spec.MySqlResponses)
+	case models.Mongo:
+		return len(spec.MongoRequests), len(spec.MongoResponses)
+	case models.GENERIC:
+		return len(spec.GenericRequests), len(spec.GenericResponses)
+	case models.Redis:
+		return len(spec.RedisRequests), len(spec.RedisResponses)
+	case models.HTTP:
+		return presence(spec.HttpReq != nil), presence(spec.HttpResp != nil)
+	case models.GRPC_EXPORT:
+		return presence(spec.GRPCReq != nil), presence(spec.GRPCResp != nil)
+	}
+	return 0, 0
+}
+
+func presence(present bool) int {
+	if present {
+		return 1
+	}
+	return 0
+}
+
+// mockFile returns the file the mock of the test-set directory is stored in.
+func (ys *Yaml) mockFile(path string, mock *models.Mock) string {
+	if ys.contentAddressed(path) {
+		if hash := mock.ContentHash(); len(hash) >= 2 {
+			return filepath.Join(mockStorePath(path, hash), hash+".yaml")
+		}
+	}
+	name := ys.mockFileName()
+	if serializer := ys.mockSerializerOf(path, name); serializer != nil {
+		return filepath.Join(path, name+"."+serializer.Extension())
+	}
+	return filepath.Join(path, name+".yaml")
+}
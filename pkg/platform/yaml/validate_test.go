@@ -0,0 +1,67 @@
+package yaml
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestValidateMocksReportsTheOneSidedMocks(t *testing.T) {
+	ys := newTestStore(t)
+	ctx := context.WithValue(context.Background(), "testSet", "test-set-0")
+	unanswered := genericMock()
+	unanswered.Spec.GenericResponses = nil
+	unrequested := &models.Mock{
+		Version: models.GetVersion(),
+		Kind:    models.Postgres,
+		Spec: models.MockSpec{
+			PostgresResponses: []models.Frontend{{Payload: "WgAAAAVJ"}},
+		},
+	}
+	for _, mock := range []*models.Mock{httpMock("/users"), unanswered, genericMock(), unrequested} {
+		if err := ys.WriteMock(mock, ctx); err != nil {
+			t.Fatalf("failed to write the mock: %v", err)
+		}
+	}
+
+	report, err := ys.ValidateMocks("test-set-0")
+	if err != nil {
+		t.Fatalf("failed to validate the mocks: %v", err)
+	}
+	want := []models.MockIssue{
+		{Index: 1, Name: unanswered.Name, Kind: models.GENERIC, Problem: "recorded requests without a response"},
+		{Index: 3, Name: unrequested.Name, Kind: models.Postgres, Problem: "recorded responses without a request"},
+	}
+	if len(report.Issues) != len(want) {
+		t.Fatalf("got the issues %+v, want %+v", report.Issues, want)
+	}
+	mockFile := filepath.Join(ys.MockPath, "test-set-0", "mocks.yaml")
+	for i, issue := range report.Issues {
+		want[i].File = mockFile
+		if issue != want[i] {
+			t.Errorf("got the issue %+v, want %+v", issue, want[i])
+		}
+	}
+}
+
+func TestValidateMocksOfAValidTestSet(t *testing.T) {
+	ys := newTestStore(t)
+	writeMocks(t, ys, "test-set-0", "/users", "/orders")
+	report, err := ys.ValidateMocks("test-set-0")
+	if err != nil {
+		t.Fatalf("failed to validate the mocks: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("got the issues %+v of the valid mocks, want none", report.Issues)
+	}
+	// nor is a test-set without mocks invalid
+	report, err = ys.ValidateMocks("test-set-1")
+	if err != nil {
+		t.Fatalf("failed to validate the missing mocks: %v", err)
+	}
+	if len(report.Issues) != 0 {
+		t.Errorf("got the issues %+v without mocks, want none", report.Issues)
+	}
+}
@@ -0,0 +1,33 @@
+package yaml
+
+import (
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// ExportWireMock converts the HTTP mocks of the test-set to WireMock stub
+// mappings. The mocks of the other kinds have no HTTP equivalent and are
+// left out.
+func (ys *Yaml) ExportWireMock(testSet string) (platform.KindSpecifier, error) {
+	mocks, err := ys.readMocks(testSet)
+	if err != nil {
+		ys.Logger.Error("failed to read the mocks of the test-set", zap.Error(err), zap.String("test-set", testSet))
+		return nil, err
+	}
+
+	exported := &models.WireMockMappings{Mappings: []models.WireMockMapping{}}
+	skipped := 0
+	for _, mock := range mocks {
+		mapping, ok := mock.WireMockMapping()
+		if !ok {
+			skipped++
+			continue
+		}
+		exported.Mappings = append(exported.Mappings, mapping)
+	}
+	if skipped > 0 {
+		ys.Logger.Debug("skipped the mocks which cannot be exported to WireMock", zap.Int("count", skipped), zap.String("test-set", testSet))
+	}
+	return exported, nil
+}
@@ -0,0 +1,65 @@
+package yaml
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestExportWireMock(t *testing.T) {
+	ys := newTestStore(t)
+	ctx := context.WithValue(context.Background(), "testSet", "test-set-0")
+	recorded := &models.Mock{
+		Version: models.GetVersion(),
+		Kind:    models.HTTP,
+		Spec: models.MockSpec{
+			Metadata: map[string]string{"name": "Http"},
+			HttpReq: &models.HttpReq{
+				Method: "POST",
+				URL:    "http://api.example.com/orders?currency=EUR",
+				Header: map[string]string{"Content-Type": "application/json"},
+				Body:   `{"item":"book","quantity":2}`,
+			},
+			HttpResp: &models.HttpResp{
+				StatusCode:    201,
+				StatusMessage: "Created",
+				Header: map[string]string{
+					"Content-Type":   "application/json",
+					"Content-Length": "11",
+					"Date":           "Mon, 02 Oct 2023 10:00:00 GMT",
+					"X-Request-Id":   "42",
+				},
+				Body: `{"id":1234}`,
+			},
+		},
+	}
+	plain := httpMock("/health")
+	for _, mock := range []*models.Mock{recorded, genericMock(), plain} {
+		if err := ys.WriteMock(mock, ctx); err != nil {
+			t.Fatalf("failed to write the mock: %v", err)
+		}
+	}
+
+	exported, err := ys.ExportWireMock("test-set-0")
+	if err != nil {
+		t.Fatalf("failed to export the mocks: %v", err)
+	}
+	got, err := json.Marshal(exported)
+	if err != nil {
+		t.Fatalf("failed to marshal the mappings: %v", err)
+	}
+	want := `{"mappings":[` +
+		`{"name":"` + recorded.Name + `","request":{"method":"POST","url":"/orders?currency=EUR","bodyPatterns":[{"equalToJson":{"item":"book","quantity":2}}]},` +
+		`"response":{"status":201,"statusMessage":"Created","headers":{"Content-Type":"application/json","X-Request-Id":"42"},"body":"{\"id\":1234}"}},` +
+		`{"name":"` + plain.Name + `","request":{"method":"GET","url":"/health"},"response":{"status":200,"body":"/health"}}]}`
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, []byte(want)); err != nil {
+		t.Fatalf("failed to compact the expected mappings: %v", err)
+	}
+	if string(got) != compacted.String() {
+		t.Errorf("got the mappings\n%s\nwant\n%s", got, compacted.String())
+	}
+}
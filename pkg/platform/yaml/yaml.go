@@ -37,6 +37,22 @@ type Yaml struct {
 	tele        *telemetry.Telemetry
 	nameCounter int
 	mutex       sync.RWMutex
+	// mockMutex serializes the writes to the mock files, so that the mocks
+	// recorded concurrently are appended whole, and the objects of the mock
+	// store are not collected while a mock referring to them is added.
+	mockMutex sync.Mutex
+	// MockLayout is the layout used to store the mocks, flat by default.
+	MockLayout MockLayout
+	// MockSerializer encodes the mocks of the flat layout, in place of the
+	// default yaml documents when set.
+	MockSerializer MockSerializer
+	// MySQLOKNoise are the fields of the MySQL OK packets left out when the
+	// mocks are compared, so that the same statement recorded with another
+	// auto-increment id is not taken for a conflicting one.
+	MySQLOKNoise models.MySQLOKNoise
+	// ShareFiles shares the keploy directory of the created files with all
+	// the users, through util.ShareWithAllUsers when nil.
+	ShareFiles func(path string) error
 }
 
 func NewYamlStore(tcsPath string, mockPath string, tcsName string, mockName string, Logger *zap.Logger, tele *telemetry.Telemetry) platform.TestCaseDB {
@@ -90,11 +106,21 @@ func findLastIndex(path string, Logger *zap.Logger) (int, error) {
 	return lastIndex, nil
 }
 
+// createYamlFile creates the yaml file, and its directory, shared through
+// ShareFiles.
+func (ys *Yaml) createYamlFile(path, fileName string) (bool, error) {
+	share := ys.ShareFiles
+	if share == nil {
+		share = util.ShareWithAllUsers
+	}
+	return util.CreateYamlFileWith(path, fileName, share, ys.Logger)
+}
+
 // write is used to generate the yaml file for the recorded calls and writes the yaml document.
 func (ys *Yaml) Write(path, fileName string, docRead platform.KindSpecifier) error {
 	//
 	doc, _ := docRead.(*NetworkTrafficDoc)
-	isFileEmpty, err := util.CreateYamlFile(path, fileName, ys.Logger)
+	isFileEmpty, err := ys.createYamlFile(path, fileName)
 	if err != nil {
 		return err
 	}
@@ -336,8 +362,9 @@ func (ys *Yaml) WriteMock(mockRead platform.KindSpecifier, ctx context.Context)
 	mocksTotal, ok := ctx.Value("mocksTotal").(*map[string]int)
 	if !ok {
 		ys.Logger.Debug("failed to get mocksTotal from context")
+	} else {
+		(*mocksTotal)[string(mock.Kind)]++
 	}
-	(*mocksTotal)[string(mock.Kind)]++
 	if ctx.Value("cmd") == "mockrecord" {
 		if ys.tele != nil {
 			ys.tele.RecordedMock(string(mock.Kind))
@@ -348,16 +375,17 @@ func (ys *Yaml) WriteMock(mockRead platform.KindSpecifier, ctx context.Context)
 	}
 
 	mock.Name = fmt.Sprint("mock-", getNextID())
-	mockYaml, err := EncodeMock(mock, ys.Logger)
-	if err != nil {
-		return err
-	}
 
 	// if mock.Name == "" {
 	// 	mock.Name = "mocks"
 	// }
 
-	err = ys.Write(ys.MockPath, "mocks", mockYaml)
+	mockPath := ys.MockPath
+	// the mocks recorded while replaying a test-set are added to it
+	if testSet, ok := ctx.Value("testSet").(string); ok && testSet != "" {
+		mockPath = filepath.Join(ys.MockPath, testSet)
+	}
+	err := ys.appendMock(mockPath, mock)
 	if err != nil {
 		return err
 	}
@@ -365,35 +393,44 @@ func (ys *Yaml) WriteMock(mockRead platform.KindSpecifier, ctx context.Context)
 	return nil
 }
 
+// UpdateMocks replaces the mocks of the test-set with the given ones, in the
+// order of the mock file. The disabled mocks, which are never read to be
+// matched, are kept as they are.
 func (ys *Yaml) UpdateMocks(mockRead []platform.KindSpecifier, testSet string) error {
-	mocks := []*models.Mock{}
+	ys.mockMutex.Lock()
+	defer ys.mockMutex.Unlock()
+	mockPath := filepath.Join(ys.MockPath, testSet)
+	stored, err := ys.readMocksFrom(mockPath, ys.mockFileName())
+	if err != nil {
+		ys.Logger.Error("failed to read the mocks of the test-set", zap.Error(err), zap.String("test-set", testSet))
+		return err
+	}
+	updated := []*models.Mock{}
+	byName := map[string]*models.Mock{}
 	for _, mock := range mockRead {
 		mockModel, ok := mock.(*models.Mock)
 		if !ok {
 			continue
 		}
-		mocks = append(mocks, mockModel)
-	}
-	mockPath := filepath.Join(ys.MockPath, testSet)
-	mockFilePath, err := util.ValidatePath(filepath.Join(mockPath, "mocks.yaml"))
-	if err != nil {
-		return err
+		updated = append(updated, mockModel)
+		byName[mockModel.Name] = mockModel
 	}
-	err = os.Remove(mockFilePath)
-	if err != nil {
-		return err
-	}
-	for _, mock := range mocks {
-		mockYaml, err := EncodeMock(mock, ys.Logger)
-		if err != nil {
-			return err
+
+	mocks := []*models.Mock{}
+	for _, mock := range stored {
+		if updatedMock, ok := byName[mock.Name]; ok {
+			mocks = append(mocks, updatedMock)
+			delete(byName, mock.Name)
+		} else if mock.Spec.Disabled {
+			mocks = append(mocks, mock)
 		}
-		err = ys.Write(mockPath, "mocks", mockYaml)
-		if err != nil {
-			return err
+	}
+	for _, mock := range updated {
+		if _, ok := byName[mock.Name]; ok {
+			mocks = append(mocks, mock)
 		}
 	}
-	return nil
+	return ys.rewriteMocks(mockPath, mocks)
 }
 
 func (ys *Yaml) ReadTcsMocks(tcRead platform.KindSpecifier, testSet string) ([]platform.KindSpecifier, error) {
@@ -408,30 +445,20 @@ func (ys *Yaml) ReadTcsMocks(tcRead platform.KindSpecifier, testSet string) ([]p
 	}
 
 	path := ys.MockPath + "/" + testSet
-	mockPath, err := util.ValidatePath(path + "/" + mockName + ".yaml")
+	mocks, err := ys.readMocksFrom(path, mockName)
 	if err != nil {
+		ys.Logger.Error("failed to read the mocks from config yaml", zap.Error(err), zap.Any("session", filepath.Base(path)))
 		return nil, err
 	}
-
-	if _, err := os.Stat(mockPath); err == nil {
-
-		yamls, err := read(path, mockName)
-		if err != nil {
-			ys.Logger.Error("failed to read the mocks from config yaml", zap.Error(err), zap.Any("session", filepath.Base(path)))
-			return nil, err
-		}
-		mocks, err := decodeMocks(yamls, ys.Logger)
-		if err != nil {
-			ys.Logger.Error("failed to decode the config mocks from yaml docs", zap.Error(err), zap.Any("session", filepath.Base(path)))
-			return nil, err
+	for _, mock := range mocks {
+		// the disabled mocks are never matched
+		if mock.Spec.Disabled {
+			continue
 		}
-
-		for _, mock := range mocks {
-			if mock.Spec.Metadata["type"] != "config" && mock.Kind != "Generic" {
-				tcsMocks = append(tcsMocks, mock)
-			}
-			//if postgres type confgi
+		if mock.Spec.Metadata["type"] != "config" && mock.Kind != "Generic" {
+			tcsMocks = append(tcsMocks, mock)
 		}
+		//if postgres type confgi
 	}
 	filteredMocks := make([]platform.KindSpecifier, 0)
 	if !readTcs {
@@ -488,27 +515,17 @@ func (ys *Yaml) ReadConfigMocks(testSet string) ([]platform.KindSpecifier, error
 	}
 	path := ys.MockPath + "/" + testSet
 
-	mockPath, err := util.ValidatePath(path + "/" + mockName + ".yaml")
+	mocks, err := ys.readMocksFrom(path, mockName)
 	if err != nil {
+		ys.Logger.Error("failed to read the mocks from config yaml", zap.Error(err), zap.Any("session", filepath.Base(path)))
 		return nil, err
 	}
-	if _, err := os.Stat(mockPath); err == nil {
-
-		yamls, err := read(path, mockName)
-		if err != nil {
-			ys.Logger.Error("failed to read the mocks from config yaml", zap.Error(err), zap.Any("session", filepath.Base(path)))
-			return nil, err
-		}
-		mocks, err := decodeMocks(yamls, ys.Logger)
-		if err != nil {
-			ys.Logger.Error("failed to decode the config mocks from yaml docs", zap.Error(err), zap.Any("session", filepath.Base(path)))
-			return nil, err
+	for _, mock := range mocks {
+		if mock.Spec.Disabled {
+			continue
 		}
-
-		for _, mock := range mocks {
-			if mock.Spec.Metadata["type"] == "config" || mock.Kind == "Postgres" || mock.Kind == "Generic" {
-				configMocks = append(configMocks, mock)
-			}
+		if mock.Spec.Metadata["type"] == "config" || mock.Kind == "Postgres" || mock.Kind == "Generic" {
+			configMocks = append(configMocks, mock)
 		}
 	}
 
@@ -581,5 +598,17 @@ func getNextID() int64 {
 }
 
 func (ys *Yaml) ReadTestSessionIndices() ([]string, error) {
-	return pkg.ReadSessionIndices(ys.MockPath, ys.Logger)
+	indices, err := pkg.ReadSessionIndices(ys.MockPath, ys.Logger)
+	if err != nil {
+		return indices, err
+	}
+	// neither the store of the content addressed mocks nor the fixture of the
+	// schema introspection is a test-set
+	testSets := make([]string, 0, len(indices))
+	for _, index := range indices {
+		if index != mockStoreDir && index != models.IntrospectionTestSet {
+			testSets = append(testSets, index)
+		}
+	}
+	return testSets, nil
 }
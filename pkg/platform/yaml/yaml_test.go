@@ -0,0 +1,30 @@
+package yaml
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCreatedFilesAreShared(t *testing.T) {
+	ys := newTestStore(t)
+	keploy := filepath.Join(ys.MockPath, "keploy")
+	ys.MockPath = keploy
+	var shared []string
+	ys.ShareFiles = func(path string) error {
+		shared = append(shared, path)
+		return nil
+	}
+
+	writeMocks(t, ys, "test-set-0", "/users", "/orders")
+	// the file is shared once, when it is created, along with the whole
+	// keploy directory
+	if len(shared) != 1 || filepath.Clean(shared[0]) != keploy {
+		t.Errorf("shared the paths %v, want the keploy directory %s once", shared, keploy)
+	}
+
+	ys.ShareFiles = func(string) error { return errors.New("permission denied") }
+	if _, err := ys.createYamlFile(filepath.Join(keploy, "test-set-1"), "mocks"); err == nil {
+		t.Errorf("created the mock file without sharing it")
+	}
+}
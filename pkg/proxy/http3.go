@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/cloudflare/cfssl/helpers"
+	"github.com/quic-go/quic-go/http3"
+	"go.uber.org/zap"
+)
+
+// listenHttp3 binds the UDP port of the HTTP/3 proxy and returns the server
+// of the HTTP/3 calls reaching it over QUIC, to be served with serveHttp3.
+// The eBPF hooks redirect the TCP connections only, the HTTP/3 calls reach
+// the proxy by name: in test mode the DNS server resolves every host to the
+// proxy, in record mode the application has to resolve the hosts of its
+// HTTP/3 dependencies to the proxy.
+func (ps *ProxySet) listenHttp3(handler http.Handler) error {
+	caKey, err := helpers.ParsePrivateKeyPEM(caPKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse the CA private key: %v", err)
+	}
+	caCert, err := helpers.ParseCertificatePEM(caCrt)
+	if err != nil {
+		return fmt.Errorf("failed to parse the CA certificate: %v", err)
+	}
+
+	conn, err := net.ListenPacket("udp", fmt.Sprintf(":%v", ps.Http3Port))
+	if err != nil {
+		return err
+	}
+
+	ps.Http3Conn = conn
+	ps.Http3Server = &http3.Server{
+		Handler: handler,
+		// the QUIC handshake carries the TLS one, terminated with a
+		// certificate of the CA of the proxy like the TLS connections over TCP
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{
+			GetCertificate: func(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if clientHello.ServerName == "" {
+					return signCertificate("localhost", caKey, caCert)
+				}
+				return signCertificate(clientHello.ServerName, caKey, caCert)
+			},
+		}),
+	}
+	return nil
+}
+
+// serveHttp3 serves the HTTP/3 calls until the proxy is stopped.
+func (ps *ProxySet) serveHttp3() {
+	ps.logger.Info(fmt.Sprintf("starting HTTP/3 proxy at addr %v", ps.Http3Conn.LocalAddr()))
+	err := ps.Http3Server.Serve(ps.Http3Conn)
+	if err != nil && !errors.Is(err, http.ErrServerClosed) && !errors.Is(err, net.ErrClosed) {
+		ps.logger.Error("failed to serve the http3 proxy", zap.Any("addr", ps.Http3Conn.LocalAddr()), zap.Error(err))
+	}
+}
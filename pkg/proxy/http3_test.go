@@ -0,0 +1,120 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/integrations/http3parser"
+	"go.uber.org/zap"
+)
+
+func TestHttp3ProxyReplaysTheMocksOverQUIC(t *testing.T) {
+	if err := models.SetMode(models.MODE_TEST); err != nil {
+		t.Fatalf("failed to set the test mode: %v", err)
+	}
+	defer models.SetMode(models.MODE_OFF)
+
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetTcsMocks([]*models.Mock{{
+		Name: "mock-0",
+		Kind: models.HTTP3,
+		Spec: models.MockSpec{
+			HttpReq: &models.HttpReq{
+				Method: http.MethodGet,
+				URL:    "https://api.example.com/health",
+				Header: map[string]string{"Accept-Encoding": "gzip", "User-Agent": "quic-go HTTP/3"},
+			},
+			HttpResp: &models.HttpResp{
+				StatusCode: http.StatusOK,
+				Header:     map[string]string{"Content-Type": "text/plain"},
+				Body:       "ok",
+			},
+		},
+	}})
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to find a free udp port: %v", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	conn.Close()
+
+	ps := &ProxySet{Http3Port: uint32(port), logger: zap.NewNop(), connMutex: &sync.Mutex{}}
+	if err := ps.listenHttp3(http3parser.NewHttp3Parser(zap.NewNop(), h, context.Background())); err != nil {
+		t.Fatalf("failed to start the http3 proxy: %v", err)
+	}
+	go ps.serveHttp3()
+	defer ps.StopProxyServer()
+
+	// the application trusts the CA of the proxy, which issues the
+	// certificate of the host resolved to the proxy
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(caCrt) {
+		t.Fatalf("failed to load the CA of the proxy")
+	}
+	transport := &http3.RoundTripper{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "api.example.com"}}
+	defer transport.Close()
+	req, err := http.NewRequest(http.MethodGet, "https://"+net.JoinHostPort("127.0.0.1", strconv.Itoa(port))+"/health", nil)
+	if err != nil {
+		t.Fatalf("failed to create the request: %v", err)
+	}
+	req.Host = "api.example.com"
+
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to call the http3 proxy: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || string(body) != "ok" || resp.Header.Get("Content-Type") != "text/plain" {
+		t.Fatalf("got the response %d %q, want the one of the mock", resp.StatusCode, body)
+	}
+}
+
+// TestHttp3ProxyStoppedBeforeServing stops the proxy before its HTTP/3 server
+// is served, which releases the UDP port.
+func TestHttp3ProxyStoppedBeforeServing(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	ps := &ProxySet{logger: zap.NewNop(), connMutex: &sync.Mutex{}}
+	if err := ps.listenHttp3(http3parser.NewHttp3Parser(zap.NewNop(), h, context.Background())); err != nil {
+		t.Fatalf("failed to start the http3 proxy: %v", err)
+	}
+	addr := ps.Http3Conn.LocalAddr().String()
+	ps.StopProxyServer()
+
+	served := make(chan struct{})
+	go func() {
+		ps.serveHttp3()
+		close(served)
+	}()
+	select {
+	case <-served:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("served the http3 proxy after it was stopped")
+	}
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		t.Fatalf("the stopped proxy kept the port %s: %v", addr, err)
+	}
+	conn.Close()
+}
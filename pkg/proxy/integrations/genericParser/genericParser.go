@@ -72,6 +72,7 @@ func decodeGenericOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 		if !matched {
 			// logger.Error("failed to match the dependency call from user application", zap.Any("request packets", len(genericRequests)))
 			clientConn.SetReadDeadline(time.Time{})
+			h.RecordDivergence()
 			logger.Debug("the genericRequests are before pass through", zap.Any("length", len(genericRequests)))
 			for _, vgen := range genericRequests {
 				logger.Debug("the genericRequests are:", zap.Any("h", string(vgen)))
@@ -90,6 +91,7 @@ func decodeGenericOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			// return errors.New("failed to match the dependency call from user application")
 			// continue
 		}
+		h.RecordRoundTrip(models.GENERIC)
 		for _, genericResponse := range genericResponses {
 			encoded := []byte(genericResponse.Message[0].Data)
 			if genericResponse.Message[0].Type != models.String {
@@ -126,6 +128,31 @@ func ReadBuffConn(conn net.Conn, bufferChannel chan []byte, errChannel chan erro
 	}
 }
 
+// appendGenericMock records the requests and responses of an exchange of an
+// unknown protocol as a generic mock, framed by the reads of the proxy.
+func appendGenericMock(requests, responses []models.GenericPayload, reqTimestampMock, resTimestampMock time.Time, h *hooks.Hook, ctx context.Context) {
+	reqs := make([]models.GenericPayload, len(requests))
+	resps := make([]models.GenericPayload, len(responses))
+	copy(reqs, requests)
+	copy(resps, responses)
+	go func() {
+		metadata := make(map[string]string)
+		metadata["type"] = "config"
+		h.AppendMocks(&models.Mock{
+			Version: models.GetVersion(),
+			Name:    "mocks",
+			Kind:    models.GENERIC,
+			Spec: models.MockSpec{
+				GenericRequests:  reqs,
+				GenericResponses: resps,
+				ReqTimestampMock: reqTimestampMock,
+				ResTimestampMock: resTimestampMock,
+				Metadata:         metadata,
+			},
+		}, ctx)
+	}()
+}
+
 func encodeGenericOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) error {
 	// destinationWriteChannel := make(chan []byte)
 	// clientWriteChannel := make(chan []byte)
@@ -160,7 +187,8 @@ func encodeGenericOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 
 	clientBufferChannel := make(chan []byte)
 	destBufferChannel := make(chan []byte)
-	errChannel := make(chan error)
+	// both the readers report their error, the second one once the loop is over
+	errChannel := make(chan error, 2)
 	// read requests from client
 	go func() {
 		// Recover from panic and gracefully shutdown
@@ -190,31 +218,13 @@ func encodeGenericOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 		select {
 		// case <-start.C:
 		case <-sigChan:
-			if !isPreviousChunkRequest && len(genericRequests) > 0 && len(genericResponses) > 0 {
-				genericRequestsCopy := make([]models.GenericPayload, len(genericRequests))
-				genericResponseCopy := make([]models.GenericPayload, len(genericResponses))
-				copy(genericResponseCopy, genericResponses)
-				copy(genericRequestsCopy, genericRequests)
-				go func(reqs []models.GenericPayload, resps []models.GenericPayload) {
-					metadata := make(map[string]string)
-					metadata["type"] = "config"
-					h.AppendMocks(&models.Mock{
-						Version: models.GetVersion(),
-						Name:    "mocks",
-						Kind:    models.GENERIC,
-						Spec: models.MockSpec{
-							GenericRequests:  reqs,
-							GenericResponses: resps,
-							ReqTimestampMock: reqTimestampMock,
-							ResTimestampMock: resTimestampMock,
-							Metadata:         metadata,
-						},
-					}, ctx)
-				}(genericRequestsCopy, genericResponseCopy)
-				clientConn.Close()
-				destConn.Close()
-				return nil
+			// the pending exchange is recorded, answered or not
+			if len(genericRequests) > 0 {
+				appendGenericMock(genericRequests, genericResponses, reqTimestampMock, resTimestampMock, h, ctx)
 			}
+			clientConn.Close()
+			destConn.Close()
+			return nil
 		case buffer := <-clientBufferChannel:
 			// Write the request message to the destination
 			_, err := destConn.Write(buffer)
@@ -225,26 +235,7 @@ func encodeGenericOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 
 			logger.Debug("the iteration for the generic request ends with no of genericReqs:" + strconv.Itoa(len(genericRequests)) + " and genericResps: " + strconv.Itoa(len(genericResponses)))
 			if !isPreviousChunkRequest && len(genericRequests) > 0 && len(genericResponses) > 0 {
-				genericRequestsCopy := make([]models.GenericPayload, len(genericRequests))
-				genericResponseCopy := make([]models.GenericPayload, len(genericResponses))
-				copy(genericResponseCopy, genericResponses)
-				copy(genericRequestsCopy, genericRequests)
-				go func(reqs []models.GenericPayload, resps []models.GenericPayload) {
-					metadata := make(map[string]string)
-					metadata["type"] = "config"
-					h.AppendMocks(&models.Mock{
-						Version: models.GetVersion(),
-						Name:    "mocks",
-						Kind:    models.GENERIC,
-						Spec: models.MockSpec{
-							GenericRequests:  reqs,
-							GenericResponses: resps,
-							ReqTimestampMock: reqTimestampMock,
-							ResTimestampMock: resTimestampMock,
-							Metadata:         metadata,
-						},
-					}, ctx)
-				}(genericRequestsCopy, genericResponseCopy)
+				appendGenericMock(genericRequests, genericResponses, reqTimestampMock, resTimestampMock, h, ctx)
 				genericRequests = []models.GenericPayload{}
 				genericResponses = []models.GenericPayload{}
 			}
@@ -308,6 +299,11 @@ func encodeGenericOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			logger.Debug("the iteration for the generic response ends with no of genericReqs:" + strconv.Itoa(len(genericRequests)) + " and genericResps: " + strconv.Itoa(len(genericResponses)))
 			isPreviousChunkRequest = false
 		case err := <-errChannel:
+			// the exchange before the connection closed has no next request
+			// to flush it, it is recorded answered or not
+			if len(genericRequests) > 0 {
+				appendGenericMock(genericRequests, genericResponses, reqTimestampMock, resTimestampMock, h, ctx)
+			}
 			return err
 			// case <-ticker.C:
 			// 	if !isPreviousChunkRequest && len(genericRequests) > 0 && len(genericResponses) > 0 {
@@ -0,0 +1,143 @@
+package genericparser
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// mockDB hands the mocks recorded by the parser over to the test.
+type mockDB struct {
+	platform.TestCaseDB
+	mocks chan *models.Mock
+}
+
+func (db *mockDB) WriteMock(mock platform.KindSpecifier, ctx context.Context) error {
+	db.mocks <- mock.(*models.Mock)
+	return nil
+}
+
+func setMode(t *testing.T, mode models.Mode) {
+	previous := models.GetMode()
+	models.SetMode(mode)
+	t.Cleanup(func() { models.SetMode(previous) })
+}
+
+// serveLines answers every line read from conn with the line in upper case,
+// the trivial line based protocol of the tests.
+func serveLines(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if _, err := conn.Write([]byte(strings.ToUpper(line))); err != nil {
+			return
+		}
+	}
+}
+
+func readLine(t *testing.T, reader *bufio.Reader) string {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	return line
+}
+
+func TestRecordLineProtocol(t *testing.T) {
+	setMode(t, models.MODE_RECORD)
+	db := &mockDB{mocks: make(chan *models.Mock, 2)}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go serveLines(server)
+	defer server.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ProcessGeneric([]byte("get a\n"), clientConn, destConn, h, zap.NewNop(), context.Background())
+	}()
+
+	reader := bufio.NewReader(app)
+	if got := readLine(t, reader); got != "GET A\n" {
+		t.Fatalf("got response %q, want %q", got, "GET A\n")
+	}
+	if _, err := app.Write([]byte("get b\n")); err != nil {
+		t.Fatalf("failed to write the second request: %v", err)
+	}
+	if got := readLine(t, reader); got != "GET B\n" {
+		t.Fatalf("got response %q, want %q", got, "GET B\n")
+	}
+	app.Close()
+
+	want := [][2]string{{"get a\n", "GET A\n"}, {"get b\n", "GET B\n"}}
+	for i, exchange := range want {
+		select {
+		case mock := <-db.mocks:
+			if mock.Kind != models.GENERIC {
+				t.Fatalf("got mock kind %v, want %v", mock.Kind, models.GENERIC)
+			}
+			if len(mock.Spec.GenericRequests) != 1 || mock.Spec.GenericRequests[0].Message[0].Data != exchange[0] {
+				t.Fatalf("mock %d: got requests %+v, want %q", i, mock.Spec.GenericRequests, exchange[0])
+			}
+			if len(mock.Spec.GenericResponses) != 1 || mock.Spec.GenericResponses[0].Message[0].Data != exchange[1] {
+				t.Fatalf("mock %d: got responses %+v, want %q", i, mock.Spec.GenericResponses, exchange[1])
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("got %d mocks, want %d", i, len(want))
+		}
+	}
+	<-done
+}
+
+func TestReplayLineProtocol(t *testing.T) {
+	setMode(t, models.MODE_TEST)
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	var mocks []*models.Mock
+	for _, exchange := range [][2]string{{"get a\n", "GET A\n"}, {"get b\n", "GET B\n"}} {
+		mock := &models.Mock{
+			Kind: models.GENERIC,
+			Spec: models.MockSpec{
+				GenericRequests:  []models.GenericPayload{{Origin: models.FromClient, Message: []models.OutputBinary{{Type: models.String, Data: exchange[0]}}}},
+				GenericResponses: []models.GenericPayload{{Origin: models.FromServer, Message: []models.OutputBinary{{Type: models.String, Data: exchange[1]}}}},
+			},
+		}
+		mock.TestModeInfo.IsFiltered = true
+		mocks = append(mocks, mock)
+	}
+	h.SetConfigMocks(mocks)
+
+	app, clientConn := net.Pipe()
+	defer app.Close()
+	// the dependency is not reachable while testing
+	go ProcessGeneric([]byte("get b\n"), clientConn, nil, h, zap.NewNop(), context.Background())
+
+	reader := bufio.NewReader(app)
+	if got := readLine(t, reader); got != "GET B\n" {
+		t.Fatalf("got response %q, want %q", got, "GET B\n")
+	}
+	if _, err := app.Write([]byte("get a\n")); err != nil {
+		t.Fatalf("failed to write the second request: %v", err)
+	}
+	if got := readLine(t, reader); got != "GET A\n" {
+		t.Fatalf("got response %q, want %q", got, "GET A\n")
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"golang.org/x/net/http2/hpack"
 
 	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
 )
 
 type transcoder struct {
@@ -17,6 +18,10 @@ type transcoder struct {
 	logger  *zap.Logger
 	framer  *http2.Framer
 	decoder *hpack.Decoder
+	// block reassembles the header blocks continued in CONTINUATION frames.
+	block headerBlock
+	// headerPredicates scope the mocks to the matching request metadata.
+	headerPredicates []models.HeaderPredicate
 }
 
 func NewTranscoder(framer *http2.Framer, logger *zap.Logger, h *hooks.Hook) *transcoder {
@@ -71,20 +76,23 @@ func (srv *transcoder) ProcessDataFrame(dataFrame *http2.DataFrame) error {
 	}
 	srv.sic.AddPayloadForRequest(id, dataFrame.Data())
 
-	if dataFrame.StreamEnded() {
-		defer srv.sic.ResetStream(dataFrame.StreamID)
+	// the request is answered once the client has sent all of it
+	if !dataFrame.StreamEnded() {
+		return nil
 	}
+	defer srv.sic.ResetStream(dataFrame.StreamID)
 
 	grpcReq := srv.sic.FetchRequestForStream(id)
 
 	// Fetch all the mocks. We can't assume that the grpc calls are made in a certain order.
-	mock, err := FilterMocksBasedOnGrpcRequest(grpcReq, srv.hook)
+	mock, err := FilterMocksBasedOnGrpcRequest(grpcReq, srv.hook, srv.headerPredicates...)
 	if err != nil {
 		return fmt.Errorf("failed match mocks: %v", err)
 	}
 	if mock == nil {
 		return fmt.Errorf("failed to mock the output for unrecorded outgoing grpc call")
 	}
+	srv.hook.RecordRoundTrip(models.GRPC_EXPORT)
 
 	grpcMockResp := mock.Spec.GRPCResp
 
@@ -141,6 +149,19 @@ func (srv *transcoder) ProcessDataFrame(dataFrame *http2.DataFrame) error {
 		srv.logger.Error("could not write the data frame onto the client", zap.Error(err))
 		return err
 	}
+	// the streaming calls answer with more messages
+	for _, msg := range grpcMockResp.Messages {
+		payload, err := CreatePayloadFromLengthPrefixedMessage(msg)
+		if err != nil {
+			srv.logger.Error("could not create grpc payload from mocks", zap.Error(err))
+			return err
+		}
+		err = srv.framer.WriteData(id, false, payload)
+		if err != nil {
+			srv.logger.Error("could not write the data frame onto the client", zap.Error(err))
+			return err
+		}
+	}
 
 	// Reset the buffer and start with a new encoding.
 	buf = new(bytes.Buffer)
@@ -231,13 +252,24 @@ func (srv *transcoder) ProcessHeadersFrame(headersFrame *http2.HeadersFrame) err
 		return http2.ConnectionError(http2.ErrCodeProtocol)
 	}
 
-	pseudoHeaders, ordinaryHeaders, err := ExtractHeaders(headersFrame, srv.decoder)
+	// the headers are decoded once their CONTINUATION frames are read
+	if !srv.block.start(headersFrame) {
+		return nil
+	}
+	return srv.processHeaderBlock()
+}
+
+// processHeaderBlock decodes a whole header block of the client, keeping the
+// dynamic table of the decoder in sync with the encoder of the client.
+func (srv *transcoder) processHeaderBlock() error {
+	pseudoHeaders, ordinaryHeaders, err := DecodeHeaderBlock(srv.block.fragment, srv.decoder)
 	if err != nil {
-		fmt.Errorf("could not extract headers from frame: %v", err)
+		srv.logger.Error("could not extract headers from frame", zap.Error(err))
+		return http2.ConnectionError(http2.ErrCodeCompression)
 	}
 
-	srv.sic.AddHeadersForRequest(id, pseudoHeaders, true)
-	srv.sic.AddHeadersForRequest(id, ordinaryHeaders, false)
+	srv.sic.AddHeadersForRequest(srv.block.streamID, pseudoHeaders, true)
+	srv.sic.AddHeadersForRequest(srv.block.streamID, ordinaryHeaders, false)
 	return nil
 }
 
@@ -248,12 +280,18 @@ func (srv *transcoder) ProcessPushPromise(pushPromiseFrame *http2.PushPromiseFra
 	return http2.ConnectionError(http2.ErrCodeProtocol)
 }
 
-func (srv *transcoder) ProcessContinuationFrame(ContinuationFrame *http2.ContinuationFrame) error {
-	// Continuation frame support is overkill currently because the headers won't exceed the frame size
-	// used by our mock server.
-	// However, if we really need this feature, we can implement it later.
-	srv.logger.Error("Continuation Frame received. This is unsupported currently")
-	return fmt.Errorf("continuation frame is unsupported in the current implementation")
+func (srv *transcoder) ProcessContinuationFrame(continuationFrame *http2.ContinuationFrame) error {
+	// The large metadata of the client is continued in CONTINUATION frames.
+	whole, err := srv.block.extend(continuationFrame)
+	if err != nil {
+		srv.logger.Error("As per HTTP/2 spec, CONTINUATION frame must follow the HEADERS frame of its stream.",
+			zap.Any("stream_id", continuationFrame.StreamID))
+		return http2.ConnectionError(http2.ErrCodeProtocol)
+	}
+	if !whole {
+		return nil
+	}
+	return srv.processHeaderBlock()
 }
 
 func (srv *transcoder) ProcessGenericFrame(frame http2.Frame) error {
@@ -71,7 +71,13 @@ func PrintFrame(frame http2.Frame) {
 }
 
 func ExtractHeaders(frame *http2.HeadersFrame, decoder *hpack.Decoder) (pseudoHeaders, ordinaryHeaders map[string]string, err error) {
-	hf, err := decoder.DecodeFull(frame.HeaderBlockFragment())
+	return DecodeHeaderBlock(frame.HeaderBlockFragment(), decoder)
+}
+
+// DecodeHeaderBlock decodes a whole header block into its pseudo and ordinary
+// headers.
+func DecodeHeaderBlock(block []byte, decoder *hpack.Decoder) (pseudoHeaders, ordinaryHeaders map[string]string, err error) {
+	hf, err := decoder.DecodeFull(block)
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not decode headers: %v", err)
 	}
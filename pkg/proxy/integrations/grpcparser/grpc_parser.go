@@ -20,8 +20,9 @@ import (
 )
 
 type GrpcParser struct {
-	logger *zap.Logger
-	hooks  *hooks.Hook
+	logger           *zap.Logger
+	hooks            *hooks.Hook
+	headerPredicates []models.HeaderPredicate
 }
 
 func NewGrpcParser(logger *zap.Logger, h *hooks.Hook) *GrpcParser {
@@ -31,6 +32,12 @@ func NewGrpcParser(logger *zap.Logger, h *hooks.Hook) *GrpcParser {
 	}
 }
 
+// SetHeaderPredicates scopes the replayed mocks to the requests whose
+// metadata satisfies all the predicates.
+func (g *GrpcParser) SetHeaderPredicates(predicates []models.HeaderPredicate) {
+	g.headerPredicates = predicates
+}
+
 // OutgoingType will be a method of GrpcParser.
 func (g *GrpcParser) OutgoingType(buffer []byte) bool {
 	return bytes.HasPrefix(buffer[:], []byte("PRI * HTTP/2"))
@@ -41,16 +48,17 @@ func (g *GrpcParser) ProcessOutgoing(requestBuffer []byte, clientConn, destConn
 	case models.MODE_RECORD:
 		encodeOutgoingGRPC(requestBuffer, clientConn, destConn, g.hooks, g.logger, ctx)
 	case models.MODE_TEST:
-		decodeOutgoingGRPC(requestBuffer, clientConn, destConn, g.hooks, g.logger)
+		decodeOutgoingGRPC(requestBuffer, clientConn, destConn, g.hooks, g.logger, g.headerPredicates)
 	default:
 		g.logger.Fatal("Unsupported mode")
 	}
 
 }
 
-func decodeOutgoingGRPC(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger) {
+func decodeOutgoingGRPC(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, predicates []models.HeaderPredicate) {
 	framer := http2.NewFramer(clientConn, clientConn)
 	srv := NewTranscoder(framer, logger, h)
+	srv.headerPredicates = predicates
 	err := srv.ListenAndServe()
 	if err != nil {
 		logger.Error("could not serve grpc request")
@@ -111,6 +119,36 @@ func encodeOutgoingGRPC(requestBuffer []byte, clientConn, destConn net.Conn, h *
 func TransferFrame(lhs net.Conn, rhs net.Conn, sic *StreamInfoCollection, isReqFromClient bool, decoder *hpack.Decoder, ctx context.Context) error {
 	isRespFromServer := !isReqFromClient
 	framer := http2.NewFramer(lhs, rhs)
+	var block headerBlock
+	// processHeaders records the headers of a whole header block.
+	processHeaders := func() error {
+		streamID := block.streamID
+		pseudoHeaders, ordinaryHeaders, err := DecodeHeaderBlock(block.fragment, decoder)
+		if err != nil {
+			return fmt.Errorf("could not extract headers from frame: %v", err)
+		}
+
+		if isReqFromClient {
+			sic.AddHeadersForRequest(streamID, pseudoHeaders, true)
+			sic.AddHeadersForRequest(streamID, ordinaryHeaders, false)
+		} else if isRespFromServer {
+			// If this is the last fragment of a stream from the server, it has to be a trailer.
+			isTrailer := false
+			if block.endStream {
+				isTrailer = true
+			}
+			sic.AddHeadersForResponse(streamID, pseudoHeaders, true, isTrailer)
+			sic.AddHeadersForResponse(streamID, ordinaryHeaders, false, isTrailer)
+		}
+
+		// The trailers frame has been received. The stream has been closed by the server.
+		// Capture the mock and clear the map, as the stream ID can be reused by client.
+		if isRespFromServer && block.endStream {
+			sic.PersistMockForStream(streamID, ctx)
+			sic.ResetStream(streamID)
+		}
+		return nil
+	}
 	for {
 		frame, err := framer.ReadFrame()
 		if err != nil {
@@ -157,29 +195,11 @@ func TransferFrame(lhs net.Conn, rhs net.Conn, sic *StreamInfoCollection, isReqF
 			if err != nil {
 				return fmt.Errorf("could not write headers frame: %v", err)
 			}
-			pseudoHeaders, ordinaryHeaders, err := ExtractHeaders(headersFrame, decoder)
-			if err != nil {
-				return fmt.Errorf("could not extract headers from frame: %v", err)
-			}
-
-			if isReqFromClient {
-				sic.AddHeadersForRequest(streamID, pseudoHeaders, true)
-				sic.AddHeadersForRequest(streamID, ordinaryHeaders, false)
-			} else if isRespFromServer {
-				// If this is the last fragment of a stream from the server, it has to be a trailer.
-				isTrailer := false
-				if headersFrame.StreamEnded() {
-					isTrailer = true
+			// the headers are decoded once their CONTINUATION frames are read
+			if block.start(headersFrame) {
+				if err := processHeaders(); err != nil {
+					return err
 				}
-				sic.AddHeadersForResponse(streamID, pseudoHeaders, true, isTrailer)
-				sic.AddHeadersForResponse(streamID, ordinaryHeaders, false, isTrailer)
-			}
-
-			// The trailers frame has been received. The stream has been closed by the server.
-			// Capture the mock and clear the map, as the stream ID can be reused by client.
-			if isRespFromServer && headersFrame.StreamEnded() {
-				sic.PersistMockForStream(streamID, ctx)
-				sic.ResetStream(streamID)
 			}
 
 		case *http2.DataFrame:
@@ -218,6 +238,15 @@ func TransferFrame(lhs net.Conn, rhs net.Conn, sic *StreamInfoCollection, isReqF
 			if err != nil {
 				return fmt.Errorf("could not write continuation frame: %v", err)
 			}
+			whole, err := block.extend(continuationFrame)
+			if err != nil {
+				return err
+			}
+			if whole {
+				if err := processHeaders(); err != nil {
+					return err
+				}
+			}
 		case *http2.PriorityFrame:
 			priorityFrame := frame.(*http2.PriorityFrame)
 			err := framer.WritePriority(priorityFrame.StreamID, priorityFrame.PriorityParam)
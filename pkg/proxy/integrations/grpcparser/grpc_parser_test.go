@@ -0,0 +1,136 @@
+package grpcparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// lengthPrefixed returns the length-prefixed message of the protobuf bytes.
+func lengthPrefixed(message []byte) []byte {
+	prefix := make([]byte, 5)
+	binary.BigEndian.PutUint32(prefix[1:], uint32(len(message)))
+	return append(prefix, message...)
+}
+
+// encodeHeaders returns the header block of the fields, encoded against the
+// dynamic table of the encoder.
+func encodeHeaders(t *testing.T, encoder *hpack.Encoder, buf *bytes.Buffer, fields ...string) []byte {
+	buf.Reset()
+	for i := 0; i < len(fields); i += 2 {
+		if err := encoder.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}); err != nil {
+			t.Fatalf("failed to encode the header %s: %v", fields[i], err)
+		}
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+// transferRequests passes the frames written by write through TransferFrame,
+// as the requests of a client, and returns the streams collected.
+func transferRequests(t *testing.T, write func(framer *http2.Framer)) *StreamInfoCollection {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	sic := NewStreamInfoCollection(h)
+	client, proxyClientSide := net.Pipe()
+	proxyServerSide, server := net.Pipe()
+	go io.Copy(io.Discard, server)
+	go func() {
+		write(http2.NewFramer(client, nil))
+		client.Close()
+	}()
+
+	err = TransferFrame(proxyServerSide, proxyClientSide, sic, true, NewDecoder(), context.Background())
+	if err != io.EOF {
+		t.Fatalf("failed to transfer the frames: %v", err)
+	}
+	server.Close()
+	return sic
+}
+
+func TestTransferFrameReassemblesContinuationFrames(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := hpack.NewEncoder(&buf)
+	block := encodeHeaders(t, encoder, &buf, ":method", "POST", ":path", "/users.Users/Get", "content-type", "application/grpc", "x-tenant", "acme")
+
+	sic := transferRequests(t, func(framer *http2.Framer) {
+		// the header block is split into a HEADERS and two CONTINUATION frames
+		framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: block[:5], EndHeaders: false})
+		framer.WriteContinuation(1, false, block[5:10])
+		framer.WriteContinuation(1, true, block[10:])
+	})
+
+	req := sic.FetchRequestForStream(1)
+	if req.Headers.PseudoHeaders[KLabelForPath] != "/users.Users/Get" || req.Headers.OrdinaryHeaders["x-tenant"] != "acme" {
+		t.Fatalf("got headers %+v, want the whole header block", req.Headers)
+	}
+}
+
+func TestTransferFrameKeepsTheHpackStateAcrossStreams(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := hpack.NewEncoder(&buf)
+	first := encodeHeaders(t, encoder, &buf, ":method", "POST", ":path", "/users.Users/Get", "x-tenant", "acme")
+	// the same fields are sent as references to the dynamic table of the
+	// connection on the next stream
+	second := encodeHeaders(t, encoder, &buf, ":method", "POST", ":path", "/users.Users/Get", "x-tenant", "acme")
+	if len(second) >= len(first) {
+		t.Fatalf("the second header block of %d bytes does not refer to the dynamic table", len(second))
+	}
+	if _, _, err := DecodeHeaderBlock(second, NewDecoder()); err == nil {
+		t.Fatalf("decoded the second header block without the state of the first")
+	}
+
+	sic := transferRequests(t, func(framer *http2.Framer) {
+		framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: first, EndHeaders: true})
+		framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 3, BlockFragment: second, EndHeaders: true})
+	})
+
+	for _, streamID := range []uint32{1, 3} {
+		req := sic.FetchRequestForStream(streamID)
+		if req.Headers.PseudoHeaders[KLabelForPath] != "/users.Users/Get" || req.Headers.OrdinaryHeaders["x-tenant"] != "acme" {
+			t.Fatalf("got headers %+v on stream %d, want the headers sent", req.Headers, streamID)
+		}
+	}
+}
+
+func TestTransferFrameSplitsTheStreamedMessages(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := hpack.NewEncoder(&buf)
+	block := encodeHeaders(t, encoder, &buf, ":method", "POST", ":path", "/users.Users/Watch")
+	// field 1 set to 1, 2 and 3
+	data := append(lengthPrefixed([]byte{0x08, 0x01}), lengthPrefixed([]byte{0x08, 0x02})...)
+	data = append(data, lengthPrefixed([]byte{0x08, 0x03})...)
+
+	sic := transferRequests(t, func(framer *http2.Framer) {
+		framer.WriteHeaders(http2.HeadersFrameParam{StreamID: 1, BlockFragment: block, EndHeaders: true})
+		// the frames do not end at the bounds of the messages
+		framer.WriteData(1, false, data[:4])
+		framer.WriteData(1, false, data[4:10])
+		framer.WriteData(1, true, data[10:])
+	})
+
+	req := sic.FetchRequestForStream(1)
+	if req.Body.MessageLength != 2 || req.Body.DecodedData != "1: 1\n" {
+		t.Fatalf("got body %+v, want the first message", req.Body)
+	}
+	want := []models.GrpcLengthPrefixedMessage{{MessageLength: 2, DecodedData: "1: 2\n"}, {MessageLength: 2, DecodedData: "1: 3\n"}}
+	if len(req.Messages) != len(want) {
+		t.Fatalf("got %d streamed messages, want %d", len(req.Messages), len(want))
+	}
+	for i := range want {
+		if req.Messages[i] != want[i] {
+			t.Fatalf("got message %+v, want %+v", req.Messages[i], want[i])
+		}
+	}
+}
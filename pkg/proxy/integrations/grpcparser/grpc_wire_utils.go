@@ -32,6 +32,52 @@ func CreateLengthPrefixedMessageFromPayload(data []byte) models.GrpcLengthPrefix
 	return msg
 }
 
+// IsWholeMessage reports whether the data of a stream holds a whole
+// length-prefixed message.
+func IsWholeMessage(data []byte) bool {
+	return len(data) >= 5 && uint64(len(data)) >= 5+uint64(binary.BigEndian.Uint32(data[1:5]))
+}
+
+// takeMessages decodes the whole length-prefixed messages at the start of the
+// data of a stream. It returns them along with the bytes of the next message,
+// whose frames are yet to come.
+func takeMessages(data []byte) ([]models.GrpcLengthPrefixedMessage, []byte) {
+	var msgs []models.GrpcLengthPrefixedMessage
+	for IsWholeMessage(data) {
+		size := 5 + int(binary.BigEndian.Uint32(data[1:5]))
+		msgs = append(msgs, CreateLengthPrefixedMessageFromPayload(data[:size]))
+		data = data[size:]
+	}
+	return msgs, data
+}
+
+// addMessages sets the first message of a stream as its body, the streaming
+// calls send the others after it.
+func addMessages(body *models.GrpcLengthPrefixedMessage, messages *[]models.GrpcLengthPrefixedMessage, received int, msgs []models.GrpcLengthPrefixedMessage) {
+	for _, msg := range msgs {
+		if received == 0 {
+			*body = msg
+		} else {
+			*messages = append(*messages, msg)
+		}
+		received++
+	}
+}
+
+// sameMessages reports whether the messages streamed after the bodies of two
+// calls are the same.
+func sameMessages(a, b []models.GrpcLengthPrefixedMessage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].CompressionFlag != b[i].CompressionFlag || a[i].DecodedData != b[i].DecodedData {
+			return false
+		}
+	}
+	return true
+}
+
 func CreatePayloadFromLengthPrefixedMessage(msg models.GrpcLengthPrefixedMessage) ([]byte, error) {
 	scanner := protoscope.NewScanner(msg.DecodedData)
 	encodedData, err := scanner.Exec()
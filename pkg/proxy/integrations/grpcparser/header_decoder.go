@@ -1,7 +1,47 @@
 package grpcparser
 
-import "golang.org/x/net/http2/hpack"
+import (
+	"fmt"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
 
 func NewDecoder() *hpack.Decoder {
 	return hpack.NewDecoder(KmaxDynamicTableSize, nil)
 }
+
+// headerBlock reassembles a header block split into a HEADERS frame and the
+// CONTINUATION frames following it. The blocks must be decoded whole, and in
+// the order they were sent on the connection, for the dynamic table of the
+// HPACK decoder to stay in sync with the encoder of the peer over a long-lived
+// connection. As no other frame may come between the frames of a block, a
+// single block is reassembled at a time.
+type headerBlock struct {
+	streamID  uint32
+	endStream bool
+	fragment  []byte
+	pending   bool
+}
+
+// start begins the block of the HEADERS frame. It reports whether the block
+// is whole already.
+func (b *headerBlock) start(frame *http2.HeadersFrame) bool {
+	b.streamID = frame.StreamID
+	b.endStream = frame.StreamEnded()
+	// the framer reuses the buffer of the fragment for the next frame
+	b.fragment = append(b.fragment[:0], frame.HeaderBlockFragment()...)
+	b.pending = !frame.HeadersEnded()
+	return !b.pending
+}
+
+// extend appends the CONTINUATION frame to the block. It reports whether the
+// block is whole.
+func (b *headerBlock) extend(frame *http2.ContinuationFrame) (bool, error) {
+	if !b.pending || frame.StreamID != b.streamID {
+		return false, fmt.Errorf("unexpected continuation frame for stream %d", frame.StreamID)
+	}
+	b.fragment = append(b.fragment, frame.HeaderBlockFragment()...)
+	b.pending = !frame.HeadersEnded()
+	return !b.pending, nil
+}
@@ -26,7 +26,25 @@ func FilterMocksRelatedToGrpc(mocks []*models.Mock) []*models.Mock {
 	return res
 }
 
-func FilterMocksBasedOnGrpcRequest(grpcReq models.GrpcReq, hook *hooks.Hook) (*models.Mock, error) {
+// MatchHeaderPredicates reports whether the request metadata, pseudo and
+// ordinary headers alike, satisfies all the predicates.
+func MatchHeaderPredicates(predicates []models.HeaderPredicate, headers models.GrpcHeaders) bool {
+	merged := make(map[string]string, len(headers.PseudoHeaders)+len(headers.OrdinaryHeaders))
+	for k, v := range headers.PseudoHeaders {
+		merged[k] = v
+	}
+	for k, v := range headers.OrdinaryHeaders {
+		merged[k] = v
+	}
+	return models.MatchHeaderMap(predicates, merged)
+}
+
+// FilterMocksBasedOnGrpcRequest finds the mock recorded for the request. When
+// header predicates are given, the request and the mock must both satisfy them.
+func FilterMocksBasedOnGrpcRequest(grpcReq models.GrpcReq, hook *hooks.Hook, predicates ...models.HeaderPredicate) (*models.Mock, error) {
+	if !MatchHeaderPredicates(predicates, grpcReq.Headers) {
+		return nil, nil
+	}
 	for {
 		mocks, err := hook.GetTcsMocks()
 		if err != nil {
@@ -39,6 +57,9 @@ func FilterMocksBasedOnGrpcRequest(grpcReq models.GrpcReq, hook *hooks.Hook) (*m
 		grpcMocks := FilterMocksRelatedToGrpc(mocks)
 		for _, mock := range grpcMocks {
 			have := mock.Spec.GRPCReq
+			if !MatchHeaderPredicates(predicates, have.Headers) {
+				continue
+			}
 			// Investigate pseudo headers.
 			if have.Headers.PseudoHeaders[KLabelForAuthority] != grpcReq.Headers.PseudoHeaders[KLabelForAuthority] {
 				continue
@@ -67,6 +88,9 @@ func FilterMocksBasedOnGrpcRequest(grpcReq models.GrpcReq, hook *hooks.Hook) (*m
 			if have.Body.DecodedData != grpcReq.Body.DecodedData {
 				continue
 			}
+			if !sameMessages(have.Messages, grpcReq.Messages) {
+				continue
+			}
 
 			matchedMock = mock
 			isMatched = true
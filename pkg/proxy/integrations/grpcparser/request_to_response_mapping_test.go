@@ -0,0 +1,67 @@
+package grpcparser
+
+import (
+	"testing"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// grpcRequest returns the metadata of a call of the tenant as recorded from
+// its HTTP/2 frames.
+func grpcRequest(tenant string) models.GrpcReq {
+	return models.GrpcReq{
+		Headers: models.GrpcHeaders{
+			PseudoHeaders: map[string]string{
+				KLabelForAuthority: "users.svc:50051",
+				KLabelForMethod:    "POST",
+				KLabelForPath:      "/users.Users/Get",
+				KLabelForScheme:    "http",
+			},
+			OrdinaryHeaders: map[string]string{
+				KLabelForContentType: "application/grpc",
+				"x-tenant":           tenant,
+			},
+		},
+		Body: models.GrpcLengthPrefixedMessage{MessageLength: 3, DecodedData: "id:1"},
+	}
+}
+
+func grpcMock(name, tenant string) *models.Mock {
+	req := grpcRequest(tenant)
+	return &models.Mock{
+		Name: name,
+		Kind: models.GRPC_EXPORT,
+		Spec: models.MockSpec{GRPCReq: &req, GRPCResp: &models.GrpcResp{}},
+	}
+}
+
+func TestFilterMocksWithHeaderPredicates(t *testing.T) {
+	predicates := []models.HeaderPredicate{{Name: "X-Tenant", Regex: "^acme-"}, {Name: ":path", Value: "/users.Users/Get"}}
+	if err := models.CompileHeaderPredicates(predicates); err != nil {
+		t.Fatalf("failed to compile the predicates: %v", err)
+	}
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetTcsMocks([]*models.Mock{grpcMock("mock-0", "globex"), grpcMock("mock-1", "acme-1")})
+
+	if mock, err := FilterMocksBasedOnGrpcRequest(grpcRequest("globex"), h, predicates...); err != nil || mock != nil {
+		t.Fatalf("got mock %v and error %v for the request of another tenant, want none", mock, err)
+	}
+	mock, err := FilterMocksBasedOnGrpcRequest(grpcRequest("acme-1"), h, predicates...)
+	if err != nil {
+		t.Fatalf("failed to filter the mocks: %v", err)
+	}
+	if mock == nil || mock.Name != "mock-1" {
+		t.Fatalf("got mock %v, want mock-1 of the acme tenant", mock)
+	}
+
+	// without predicates the header of the tenant is not compared
+	h.SetTcsMocks([]*models.Mock{grpcMock("mock-0", "globex")})
+	if mock, _ := FilterMocksBasedOnGrpcRequest(grpcRequest("acme-1"), h); mock == nil || mock.Name != "mock-0" {
+		t.Fatalf("got mock %v without predicates, want mock-0", mock)
+	}
+}
@@ -18,12 +18,24 @@ type StreamInfoCollection struct {
 	StreamInfo       map[uint32]models.GrpcStream
 	ReqTimestampMock time.Time
 	ResTimestampMock time.Time
+	// requestData and responseData reassemble the DATA frames of the
+	// streams, as a message may be split into several of them.
+	requestData  map[uint32][]byte
+	responseData map[uint32][]byte
+	// requestMessages and responseMessages count the messages of the
+	// streams decoded so far.
+	requestMessages  map[uint32]int
+	responseMessages map[uint32]int
 }
 
 func NewStreamInfoCollection(h *hooks.Hook) *StreamInfoCollection {
 	return &StreamInfoCollection{
-		hook:       h,
-		StreamInfo: make(map[uint32]models.GrpcStream),
+		hook:             h,
+		StreamInfo:       make(map[uint32]models.GrpcStream),
+		requestData:      make(map[uint32][]byte),
+		responseData:     make(map[uint32][]byte),
+		requestMessages:  make(map[uint32]int),
+		responseMessages: make(map[uint32]int),
 	}
 }
 
@@ -75,7 +87,8 @@ func (sic *StreamInfoCollection) AddHeadersForResponse(streamID uint32, headers
 	}
 }
 
-// AddPayloadForRequest adds the DATA frame to the stream.
+// AddPayloadForRequest adds the DATA frame to the stream. The messages are
+// decoded once all of their frames are received.
 // A data frame always appears after at least one header frame. Hence, we implicitly
 // assume that the stream has been initialised.
 func (sic *StreamInfoCollection) AddPayloadForRequest(streamID uint32, payload []byte) {
@@ -84,12 +97,19 @@ func (sic *StreamInfoCollection) AddPayloadForRequest(streamID uint32, payload [
 
 	// We cannot modify non pointer values in nested entries in map.
 	// Create a copy and overwrite it.
+	msgs, rest := takeMessages(append(sic.requestData[streamID], payload...))
+	sic.requestData[streamID] = rest
+	if len(msgs) == 0 {
+		return
+	}
 	info := sic.StreamInfo[streamID]
-	info.GrpcReq.Body = CreateLengthPrefixedMessageFromPayload(payload)
+	addMessages(&info.GrpcReq.Body, &info.GrpcReq.Messages, sic.requestMessages[streamID], msgs)
+	sic.requestMessages[streamID] += len(msgs)
 	sic.StreamInfo[streamID] = info
 }
 
-// AddPayloadForResponse adds the DATA frame to the stream.
+// AddPayloadForResponse adds the DATA frame to the stream. The messages are
+// decoded once all of their frames are received.
 // A data frame always appears after at least one header frame. Hence, we implicitly
 // assume that the stream has been initialised.
 func (sic *StreamInfoCollection) AddPayloadForResponse(streamID uint32, payload []byte) {
@@ -98,8 +118,14 @@ func (sic *StreamInfoCollection) AddPayloadForResponse(streamID uint32, payload
 
 	// We cannot modify non pointer values in nested entries in map.
 	// Create a copy and overwrite it.
+	msgs, rest := takeMessages(append(sic.responseData[streamID], payload...))
+	sic.responseData[streamID] = rest
+	if len(msgs) == 0 {
+		return
+	}
 	info := sic.StreamInfo[streamID]
-	info.GrpcResp.Body = CreateLengthPrefixedMessageFromPayload(payload)
+	addMessages(&info.GrpcResp.Body, &info.GrpcResp.Messages, sic.responseMessages[streamID], msgs)
+	sic.responseMessages[streamID] += len(msgs)
 	sic.StreamInfo[streamID] = info
 }
 
@@ -134,4 +160,8 @@ func (sic *StreamInfoCollection) ResetStream(streamID uint32) {
 	defer sic.mutex.Unlock()
 
 	delete(sic.StreamInfo, streamID)
+	delete(sic.requestData, streamID)
+	delete(sic.responseData, streamID)
+	delete(sic.requestMessages, streamID)
+	delete(sic.responseMessages, streamID)
 }
@@ -0,0 +1,226 @@
+package http3parser
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/integrations/httpparser"
+	"go.uber.org/zap"
+)
+
+// Http3Parser records and replays the HTTP/3 calls terminated by the QUIC
+// listener of the proxy. The calls are stored like the HTTP/1.x ones, under
+// the Http3 mock kind.
+type Http3Parser struct {
+	logger *zap.Logger
+	hooks  *hooks.Hook
+	ctx    context.Context
+	// Upstream forwards the recorded calls to the authority of their request.
+	Upstream http.RoundTripper
+}
+
+func NewHttp3Parser(logger *zap.Logger, h *hooks.Hook, ctx context.Context) *Http3Parser {
+	return &Http3Parser{
+		logger: logger,
+		hooks:  h,
+		ctx:    ctx,
+		Upstream: &http3.RoundTripper{
+			TLSClientConfig: &tls.Config{
+				InsecureSkipVerify: true,
+			},
+			// the responses are recorded and replayed with their encoding
+			DisableCompression: true,
+		},
+	}
+}
+
+// ServeHTTP records the call in record mode and replays its mock in test mode.
+func (p *Http3Parser) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reqBody, err := io.ReadAll(r.Body)
+	if err != nil {
+		p.logger.Error("failed to read the http3 request body", zap.Any("metadata", getReqMeta(r)), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if models.GetMode() == models.MODE_TEST {
+		p.decodeOutgoingHttp3(w, r, reqBody)
+		return
+	}
+	p.encodeOutgoingHttp3(w, r, reqBody)
+}
+
+// encodeOutgoingHttp3 forwards the call to its destination and records it as a mock.
+func (p *Http3Parser) encodeOutgoingHttp3(w http.ResponseWriter, r *http.Request, reqBody []byte) {
+	reqTimestampMock := time.Now()
+	reqURL := &url.URL{Scheme: "https", Host: r.Host, Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, reqURL.String(), bytes.NewReader(reqBody))
+	if err != nil {
+		p.logger.Error("failed to create the http3 request to the destination server", zap.Any("metadata", getReqMeta(r)), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	req.Header = r.Header.Clone()
+
+	resp, err := p.Upstream.RoundTrip(req)
+	if err != nil {
+		p.logger.Error("failed to forward the http3 request to the destination server", zap.Any("metadata", getReqMeta(r)), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		p.logger.Error("failed to read the http3 response body", zap.Any("metadata", getReqMeta(r)), zap.Error(err))
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	resTimestampMock := time.Now()
+
+	writeResponse(w, resp.StatusCode, resp.Header, respBody)
+
+	err = p.hooks.AppendMocks(&models.Mock{
+		Version: models.GetVersion(),
+		Name:    "mocks",
+		Kind:    models.HTTP3,
+		Spec: models.MockSpec{
+			Metadata: map[string]string{
+				"name":      "Http3",
+				"type":      models.HttpClient,
+				"operation": r.Method,
+			},
+			HttpReq: &models.HttpReq{
+				Method:     models.Method(r.Method),
+				ProtoMajor: r.ProtoMajor,
+				ProtoMinor: r.ProtoMinor,
+				URL:        reqURL.String(),
+				Header:     pkg.ToYamlHttpHeader(r.Header),
+				Body:       string(reqBody),
+				URLParams:  pkg.UrlParams(r),
+			},
+			HttpResp: &models.HttpResp{
+				StatusCode: resp.StatusCode,
+				Header:     pkg.ToYamlHttpHeader(resp.Header),
+				Body:       string(respBody),
+				ProtoMajor: resp.ProtoMajor,
+				ProtoMinor: resp.ProtoMinor,
+			},
+			Created:          time.Now().Unix(),
+			ReqTimestampMock: reqTimestampMock,
+			ResTimestampMock: resTimestampMock,
+		},
+	}, p.ctx)
+	if err != nil {
+		p.logger.Error("failed to store the http3 mock", zap.Any("metadata", getReqMeta(r)), zap.Error(err))
+	}
+}
+
+// decodeOutgoingHttp3 replies to the call with the response of its mock.
+func (p *Http3Parser) decodeOutgoingHttp3(w http.ResponseWriter, r *http.Request, reqBody []byte) {
+	isMatched, stub, err := p.match(r, reqBody)
+	if err != nil {
+		p.logger.Error("error while matching http3 mocks", zap.Any("metadata", getReqMeta(r)), zap.Error(err))
+	}
+	if !isMatched {
+		p.logger.Error("Didn't match any prexisting http3 mock", zap.Any("metadata", getReqMeta(r)))
+		http.Error(w, "keploy: no mock matched the http3 request", http.StatusBadGateway)
+		return
+	}
+	writeResponse(w, stub.Spec.HttpResp.StatusCode, pkg.ToHttpHeader(stub.Spec.HttpResp.Header), []byte(stub.Spec.HttpResp.Body))
+}
+
+// match consumes the mock recorded for the request. The mocks are selected the
+// same way as the HTTP/1.x ones: by method, path, header and query keys, then
+// by the closest body.
+func (p *Http3Parser) match(r *http.Request, reqBody []byte) (bool, *models.Mock, error) {
+	isReqBodyJSON := isJSON(reqBody)
+	for {
+		tcsMocks, err := p.hooks.GetTcsMocks()
+		if err != nil {
+			return false, nil, fmt.Errorf("error while getting tcs mocks %v", err)
+		}
+
+		var eligibleMock []*models.Mock
+		for _, mock := range tcsMocks {
+			if mock.Kind != models.HTTP3 {
+				continue
+			}
+			if isJSON([]byte(mock.Spec.HttpReq.Body)) != isReqBodyJSON {
+				continue
+			}
+			parsedURL, err := url.Parse(mock.Spec.HttpReq.URL)
+			if err != nil {
+				p.logger.Error("failed to parse mock url", zap.Error(err))
+				continue
+			}
+			if parsedURL.Path != r.URL.Path || mock.Spec.HttpReq.Method != models.Method(r.Method) {
+				continue
+			}
+			if !haveSameKeys(mock.Spec.HttpReq.Header, r.Header) || !haveSameKeys(mock.Spec.HttpReq.URLParams, r.URL.Query()) {
+				continue
+			}
+			eligibleMock = append(eligibleMock, mock)
+		}
+		if len(eligibleMock) == 0 {
+			return false, nil, nil
+		}
+
+		isMatched, bestMatch := httpparser.Fuzzymatch(eligibleMock, reqBody, p.hooks)
+		if isMatched && !p.hooks.DeleteTcsMock(bestMatch) {
+			// matched concurrently by another call, match among the rest
+			continue
+		}
+		return isMatched, bestMatch, nil
+	}
+}
+
+func writeResponse(w http.ResponseWriter, statusCode int, header http.Header, body []byte) {
+	for key, values := range header {
+		if key == "Content-Length" {
+			continue
+		}
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(statusCode)
+	_, _ = w.Write(body)
+}
+
+func isJSON(body []byte) bool {
+	var js interface{}
+	return json.Unmarshal(body, &js) == nil
+}
+
+func haveSameKeys(mockMap map[string]string, reqMap map[string][]string) bool {
+	if len(mockMap) != len(reqMap) {
+		return false
+	}
+	for key := range mockMap {
+		if _, ok := reqMap[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// extract the request metadata from the request
+func getReqMeta(req *http.Request) map[string]string {
+	return map[string]string{
+		"method": req.Method,
+		"url":    req.URL.String(),
+		"host":   req.Host,
+	}
+}
@@ -0,0 +1,191 @@
+package http3parser
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// mockDB keeps the mocks recorded by the parser.
+type mockDB struct {
+	platform.TestCaseDB
+	mocks []*models.Mock
+}
+
+func (db *mockDB) WriteMock(mock platform.KindSpecifier, ctx context.Context) error {
+	db.mocks = append(db.mocks, mock.(*models.Mock))
+	return nil
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create the certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serveHttp3 serves the handler over QUIC on a local port and returns its address.
+func serveHttp3(t *testing.T, handler http.Handler) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen on udp: %v", err)
+	}
+	server := &http3.Server{
+		Handler:   handler,
+		TLSConfig: http3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}),
+	}
+	go server.Serve(conn)
+	t.Cleanup(func() { server.Close() })
+	return conn.LocalAddr().String()
+}
+
+// call sends a request to the proxy on behalf of the authority, the host the
+// application resolved to the proxy.
+func call(t *testing.T, proxyAddr, authority, method, path, body string) (*http.Response, string) {
+	transport := &http3.RoundTripper{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	t.Cleanup(func() { transport.Close() })
+	req, err := http.NewRequest(method, "https://"+proxyAddr+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create the request: %v", err)
+	}
+	req.Host = authority
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("failed to send the request: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read the response: %v", err)
+	}
+	return resp, string(respBody)
+}
+
+func newParser(t *testing.T, db platform.TestCaseDB) *Http3Parser {
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	return NewHttp3Parser(zap.NewNop(), h, context.Background())
+}
+
+func setMode(t *testing.T, mode models.Mode) {
+	previous := models.GetMode()
+	if err := models.SetMode(mode); err != nil {
+		t.Fatalf("failed to set the mode: %v", err)
+	}
+	t.Cleanup(func() { _ = models.SetMode(previous) })
+}
+
+func TestRecordHttp3Call(t *testing.T) {
+	setMode(t, models.MODE_RECORD)
+	upstream := serveHttp3(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.URL.Path != "/orders" || r.URL.Query().Get("page") != "2" || string(body) != `{"sku":"a1"}` {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("X-Order", "17")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, `{"id":17}`)
+	}))
+	db := &mockDB{}
+	proxyAddr := serveHttp3(t, newParser(t, db))
+
+	resp, body := call(t, proxyAddr, upstream, http.MethodPost, "/orders?page=2", `{"sku":"a1"}`)
+	if resp.StatusCode != http.StatusCreated || body != `{"id":17}` || resp.Header.Get("X-Order") != "17" {
+		t.Fatalf("got the response %d %q with X-Order %q, want the one of the destination", resp.StatusCode, body, resp.Header.Get("X-Order"))
+	}
+
+	if len(db.mocks) != 1 {
+		t.Fatalf("recorded %d mocks, want 1", len(db.mocks))
+	}
+	mock := db.mocks[0]
+	if mock.Kind != models.HTTP3 {
+		t.Fatalf("recorded a mock of kind %q, want %q", mock.Kind, models.HTTP3)
+	}
+	req, res := mock.Spec.HttpReq, mock.Spec.HttpResp
+	if req.Method != http.MethodPost || req.URL != "https://"+upstream+"/orders?page=2" || req.Body != `{"sku":"a1"}` || req.URLParams["page"] != "2" || req.ProtoMajor != 3 {
+		t.Fatalf("recorded the request %+v, want the one sent to the destination", req)
+	}
+	if res.StatusCode != http.StatusCreated || res.Body != `{"id":17}` || res.Header["X-Order"] != "17" || res.ProtoMajor != 3 {
+		t.Fatalf("recorded the response %+v, want the one of the destination", res)
+	}
+}
+
+func TestReplayHttp3Call(t *testing.T) {
+	setMode(t, models.MODE_TEST)
+	parser := newParser(t, nil)
+	parser.hooks.SetTcsMocks([]*models.Mock{
+		{
+			Name: "mock-0",
+			Kind: models.HTTP3,
+			Spec: models.MockSpec{
+				HttpReq: &models.HttpReq{
+					Method:    http.MethodPost,
+					URL:       "https://api.example.com/orders?page=2",
+					URLParams: map[string]string{"page": "2"},
+					Header: map[string]string{
+						"Accept-Encoding": "gzip",
+						"Content-Length":  "12",
+						"Content-Type":    "application/json",
+						"User-Agent":      "quic-go HTTP/3",
+					},
+					Body: `{"sku":"a1"}`,
+				},
+				HttpResp: &models.HttpResp{
+					StatusCode: http.StatusCreated,
+					Header:     map[string]string{"X-Order": "17", "Content-Length": "9"},
+					Body:       `{"id":17}`,
+				},
+			},
+		},
+	})
+	// no destination is dialed in test mode
+	proxyAddr := serveHttp3(t, parser)
+
+	resp, body := call(t, proxyAddr, "api.example.com", http.MethodPost, "/orders?page=2", `{"sku":"a1"}`)
+	if resp.StatusCode != http.StatusCreated || body != `{"id":17}` || resp.Header.Get("X-Order") != "17" {
+		t.Fatalf("got the response %d %q with X-Order %q, want the recorded one", resp.StatusCode, body, resp.Header.Get("X-Order"))
+	}
+	if mocks, _ := parser.hooks.GetTcsMocks(); len(mocks) != 0 {
+		t.Fatalf("left %d mocks after replaying them, want none", len(mocks))
+	}
+
+	// the mock is consumed, the same call matches nothing anymore
+	resp, _ = call(t, proxyAddr, "api.example.com", http.MethodPost, "/orders?page=2", `{"sku":"a1"}`)
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("got the status %d for a call without mock, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+}
@@ -26,8 +26,9 @@ import (
 )
 
 type HttpParser struct {
-	logger *zap.Logger
-	hooks  *hooks.Hook
+	logger           *zap.Logger
+	hooks            *hooks.Hook
+	headerPredicates []models.HeaderPredicate
 }
 
 // ProcessOutgoing implements proxy.DepInterface.
@@ -41,7 +42,7 @@ func (http *HttpParser) ProcessOutgoing(request []byte, clientConn, destConn net
 		}
 
 	case models.MODE_TEST:
-		decodeOutgoingHttp(request, clientConn, destConn, http.hooks, http.logger)
+		decodeOutgoingHttp(request, clientConn, destConn, http.hooks, http.logger, http.headerPredicates...)
 	default:
 		http.logger.Info("Invalid mode detected while intercepting outgoing http call", zap.Any("mode", models.GetMode()))
 	}
@@ -55,6 +56,12 @@ func NewHttpParser(logger *zap.Logger, h *hooks.Hook) *HttpParser {
 	}
 }
 
+// SetHeaderPredicates scopes the replayed mocks to the requests whose
+// headers satisfy all the predicates.
+func (h *HttpParser) SetHeaderPredicates(predicates []models.HeaderPredicate) {
+	h.headerPredicates = predicates
+}
+
 // IsOutgoingHTTP function determines if the outgoing network call is HTTP by comparing the
 // message format with that of an HTTP text message.
 func (h *HttpParser) OutgoingType(buffer []byte) bool {
@@ -430,7 +437,7 @@ func checkIfGzipped(check io.ReadCloser) (bool, *bufio.Reader) {
 }
 
 // Decodes the mocks in test mode so that they can be sent to the user application.
-func decodeOutgoingHttp(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger) {
+func decodeOutgoingHttp(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, predicates ...models.HeaderPredicate) {
 	//Matching algorithmm
 	//Get the mocks
 	for {
@@ -485,7 +492,7 @@ func decodeOutgoingHttp(requestBuffer []byte, clientConn, destConn net.Conn, h *
 		//check if req body is a json
 		isReqBodyJSON := isJSON(reqBody)
 
-		isMatched, stub, err := match(req, reqBody, reqURL, isReqBodyJSON, h, logger, clientConn, destConn, requestBuffer, h.Recover)
+		isMatched, stub, err := match(req, reqBody, reqURL, isReqBodyJSON, h, logger, clientConn, destConn, requestBuffer, h.Recover, predicates...)
 
 		if err != nil {
 			logger.Error("error while matching http mocks", zap.Any("metadata", getReqMeta(req)), zap.Error(err))
@@ -525,6 +532,7 @@ func decodeOutgoingHttp(requestBuffer []byte, clientConn, destConn net.Conn, h *
 			}
 			if !passthroughHost {
 				logger.Error("Didn't match any prexisting http mock", zap.Any("metadata", getReqMeta(req)))
+				h.RecordDivergence()
 			}
 			_, err := util.Passthrough(clientConn, destConn, [][]byte{requestBuffer}, h.Recover, logger)
 			if err != nil {
@@ -532,6 +540,7 @@ func decodeOutgoingHttp(requestBuffer []byte, clientConn, destConn net.Conn, h *
 			}
 			return
 		}
+		h.RecordRoundTrip(models.HTTP)
 
 		statusLine := fmt.Sprintf("HTTP/%d.%d %d %s\r\n", stub.Spec.HttpReq.ProtoMajor, stub.Spec.HttpReq.ProtoMinor, stub.Spec.HttpResp.StatusCode, http.StatusText(int(stub.Spec.HttpResp.StatusCode)))
 
@@ -15,7 +15,11 @@ import (
 	"go.uber.org/zap"
 )
 
-func match(req *http.Request, reqBody []byte, reqURL *url.URL, isReqBodyJSON bool, h *hooks.Hook, logger *zap.Logger, clientConn, destConn net.Conn, requestBuffer []byte, recover func(id int)) (bool, *models.Mock, error) {
+func match(req *http.Request, reqBody []byte, reqURL *url.URL, isReqBodyJSON bool, h *hooks.Hook, logger *zap.Logger, clientConn, destConn net.Conn, requestBuffer []byte, recover func(id int), predicates ...models.HeaderPredicate) (bool, *models.Mock, error) {
+	// requests outside the scope of the header predicates have no mocks
+	if !models.MatchHTTPHeader(predicates, req.Header) {
+		return false, nil, nil
+	}
 	for {
 		tcsMocks, err := h.GetTcsMocks()
 		if err != nil {
@@ -26,6 +30,10 @@ func match(req *http.Request, reqBody []byte, reqURL *url.URL, isReqBodyJSON boo
 
 		for _, mock := range tcsMocks {
 			if mock.Kind == models.HTTP {
+				if !models.MatchHeaderMap(predicates, mock.Spec.HttpReq.Header) {
+					continue
+				}
+
 				isMockBodyJSON := isJSON([]byte(mock.Spec.HttpReq.Body))
 
 				//the body of mock and request aren't of same type
@@ -2,6 +2,7 @@ package mongoparser
 
 import (
 	"fmt"
+	"reflect"
 
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
@@ -59,3 +60,27 @@ func match(h *hooks.Hook, mongoRequests []models.MongoRequest, logger *zap.Logge
 		return true, mock, nil
 	}
 }
+
+// volatileCommandFields differ across the runs of the same command, such as
+// the session generated by the driver and the cluster time it gossips, and
+// are left out of the matching.
+var volatileCommandFields = []string{"lsid", "$clusterTime"}
+
+func removeVolatileFields(command map[string]interface{}) {
+	for _, field := range volatileCommandFields {
+		delete(command, field)
+	}
+}
+
+// sameCursors reports whether the commands iterating or closing a cursor,
+// getMore and killCursors, are for the same cursors. The cursors of the
+// replayed responses are the recorded ones, so that the batches of a find
+// are matched in order and never with the batches of another cursor.
+func sameCursors(expected, actual map[string]interface{}) bool {
+	for _, field := range []string{"getMore", "cursors"} {
+		if !reflect.DeepEqual(expected[field], actual[field]) {
+			return false
+		}
+	}
+	return true
+}
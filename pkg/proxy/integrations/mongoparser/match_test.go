@@ -0,0 +1,52 @@
+package mongoparser
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func singleSection(msg string) string {
+	return "{ SectionSingle msg: " + msg + " }"
+}
+
+func TestCompareOpMsgSection(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		expected string
+		actual   string
+		want     float64
+	}{
+		{
+			name:     "another session and cluster time",
+			expected: `{"find":"users","filter":{"name":"alice"},"lsid":{"id":"a"},"$clusterTime":{"clusterTime":{"$numberLong":"1"}},"$db":"app"}`,
+			actual:   `{"find":"users","filter":{"name":"alice"},"lsid":{"id":"b"},"$clusterTime":{"clusterTime":{"$numberLong":"2"}},"$db":"app"}`,
+			want:     1,
+		},
+		{
+			name:     "getMore of the same cursor",
+			expected: `{"getMore":{"$numberLong":"7"},"collection":"users","lsid":{"id":"a"},"$db":"app"}`,
+			actual:   `{"getMore":{"$numberLong":"7"},"collection":"users","lsid":{"id":"b"},"$db":"app"}`,
+			want:     1,
+		},
+		{
+			name:     "getMore of another cursor",
+			expected: `{"getMore":{"$numberLong":"7"},"collection":"users","$db":"app"}`,
+			actual:   `{"getMore":{"$numberLong":"8"},"collection":"users","$db":"app"}`,
+			want:     0,
+		},
+		{
+			name:     "killCursors of other cursors",
+			expected: `{"killCursors":"users","cursors":[{"$numberLong":"7"}],"$db":"app"}`,
+			actual:   `{"killCursors":"users","cursors":[{"$numberLong":"8"}],"$db":"app"}`,
+			want:     0,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareOpMsgSection(singleSection(tt.expected), singleSection(tt.actual), zap.NewNop())
+			if got != tt.want {
+				t.Errorf("got the score %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -279,6 +279,7 @@ func decodeOutgoingMongo(requestBuffer []byte, clientConn, destConn net.Conn, h
 			}
 			if !isMatched {
 				logger.Debug("mongo request not matched with any tcsMocks", zap.Any("request", mongoRequests))
+				h.RecordDivergence()
 				requestBuffer, err = util.Passthrough(clientConn, destConn, requestBuffers, h.Recover, logger)
 				if err != nil {
 					logger.Error("failed to passthrough the mongo request to the actual database server", zap.Error(err))
@@ -286,6 +287,7 @@ func decodeOutgoingMongo(requestBuffer []byte, clientConn, destConn net.Conn, h
 				}
 				continue
 			}
+			h.RecordRoundTrip(models.Mongo)
 
 			responseTo := mongoRequests[0].Header.RequestID
 			logger.Debug("the mock matched with the current request", zap.Any("mock", matchedMock), zap.Any("responseTo", responseTo))
@@ -727,6 +729,12 @@ func compareOpMsgSection(expectedSection, actualSection string, logger *zap.Logg
 			logger.Error("failed to unmarshal the section of incoming request to bson document", zap.Error(err))
 			return 0
 		}
+		if !sameCursors(expected, actual) {
+			logger.Debug("the getMore or killCursors of the incoming request is for another cursor", zap.Any("expected", expected), zap.Any("actual", actual))
+			return 0
+		}
+		removeVolatileFields(expected)
+		removeVolatileFields(actual)
 		logger.Debug("the expected and actual msg in the single section.", zap.Any("expected", expected), zap.Any("actual", actual), zap.Any("score", calculateMatchingScore(expected, actual)))
 		return calculateMatchingScore(expected, actual)
 
@@ -11,6 +11,11 @@ type AuthSwitchRequestPacket struct {
 	StatusTag      byte   `json:"status_tag,omitempty" yaml:"status_tag,omitempty"`
 	PluginName     string `json:"plugin_name,omitempty" yaml:"plugin_name,omitempty"`
 	PluginAuthData string `json:"plugin_authdata,omitempty" yaml:"plugin_authdata,omitempty"`
+	// Unterminated is set when the request ends with the plugin name, without
+	// its terminator, as the servers asking the client to resend its auth
+	// response with the plugin send it. A lone status tag is the switch to
+	// the old password authentication.
+	Unterminated bool `json:"unterminated,omitempty" yaml:"unterminated,omitempty"`
 }
 
 func decodeAuthSwitchRequest(data []byte) (*AuthSwitchRequestPacket, error) {
@@ -27,6 +32,8 @@ func decodeAuthSwitchRequest(data []byte) (*AuthSwitchRequestPacket, error) {
 	packet.PluginName = string(parts[0])
 	if len(parts) > 1 {
 		packet.PluginAuthData = string(parts[1])
+	} else {
+		packet.Unterminated = true
 	}
 
 	return packet, nil
@@ -43,6 +50,9 @@ func encodeAuthSwitchRequest(packet *models.AuthSwitchRequestPacket) ([]byte, er
 
 	// Write the plugin name
 	buf.WriteString(packet.PluginName)
+	if packet.Unterminated {
+		return buf.Bytes(), nil
+	}
 	buf.WriteByte(0x00) // Null byte separator
 
 	// Write the plugin auth data
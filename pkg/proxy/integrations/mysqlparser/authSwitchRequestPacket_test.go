@@ -0,0 +1,78 @@
+package mysqlparser
+
+import (
+	"bytes"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestAuthSwitchRequestRoundTrips(t *testing.T) {
+	tests := []struct {
+		name       string
+		payload    []byte
+		pluginName string
+	}{
+		{name: "old password switch", payload: []byte{0xFE}},
+		{name: "without plugin data", payload: append([]byte{0xFE}, "mysql_native_password"...), pluginName: "mysql_native_password"},
+		{name: "empty plugin data", payload: append([]byte{0xFE}, "mysql_native_password\x00"...), pluginName: "mysql_native_password"},
+		{name: "with plugin data", payload: append([]byte{0xFE}, "mysql_native_password\x00abcdefghijklmnopqrst\x00"...), pluginName: "mysql_native_password"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := newConnectionState()
+			state.pluginName = "caching_sha2_password"
+			packetType, decoded := decodePayload(t, state, 1, tt.payload)
+			if packetType != "AUTH_SWITCH_REQUEST" {
+				t.Fatalf("decoded a %s packet, want an auth switch request", packetType)
+			}
+			request := decoded.(*AuthSwitchRequestPacket)
+			if request.PluginName != tt.pluginName {
+				t.Fatalf("decoded the plugin %q, want %q", request.PluginName, tt.pluginName)
+			}
+			// the auth-more-data which follows is sent by the switched plugin,
+			// if the request names one
+			wantPlugin := tt.pluginName
+			if wantPlugin == "" {
+				wantPlugin = "caching_sha2_password"
+			}
+			if state.pluginName != wantPlugin {
+				t.Fatalf("connection uses the plugin %q, want %q", state.pluginName, wantPlugin)
+			}
+
+			encoded, err := encodeAuthSwitchRequest(&models.AuthSwitchRequestPacket{
+				StatusTag:      request.StatusTag,
+				PluginName:     request.PluginName,
+				PluginAuthData: request.PluginAuthData,
+				Unterminated:   request.Unterminated,
+			})
+			if err != nil {
+				t.Fatalf("failed to encode the auth switch request: %v", err)
+			}
+			if !bytes.Equal(encoded, tt.payload) {
+				t.Fatalf("replayed %q, want %q", encoded, tt.payload)
+			}
+		})
+	}
+}
+
+func TestEmptyAuthSwitchResponse(t *testing.T) {
+	state := newConnectionState()
+	state.expectingAuthSwitchResponse = true
+	packetType, decoded := decodePayload(t, state, 3, nil)
+	if packetType != "AUTH_SWITCH_RESPONSE" {
+		t.Fatalf("decoded a %s packet, want an auth switch response", packetType)
+	}
+	if response := decoded.(*AuthSwitchResponsePacket); response.AuthResponseData != "" {
+		t.Fatalf("decoded the auth response %q, want an empty one", response.AuthResponseData)
+	}
+	if state.expectingAuthSwitchResponse {
+		t.Fatalf("connection still expects an auth switch response")
+	}
+
+	// the empty packets are only the answer of an auth switch
+	packet := MySQLPacket{Header: MySQLPacketHeader{SequenceID: 3}}
+	if _, _, _, err := DecodeMySQLPacket(packet, nil, nil, state); err == nil {
+		t.Fatalf("decoded an empty packet outside of an auth switch")
+	}
+}
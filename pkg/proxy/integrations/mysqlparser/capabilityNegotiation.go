@@ -0,0 +1,88 @@
+package mysqlparser
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// negotiatedCapabilities returns the capabilities in effect on a connection:
+// those advertised by the server in its greeting and requested by the client
+// in its handshake response.
+func negotiatedCapabilities(greeting *models.MySQLHandshakeV10Packet, response *HandshakeResponse) uint32 {
+	if greeting == nil || response == nil {
+		return 0
+	}
+	return greeting.CapabilityFlags & response.CapabilityFlags
+}
+
+// adaptResultSet returns the recorded result set ended the way the replayed
+// client negotiated: with the EOF packets of the classic protocol, or without
+// the EOF following the columns and with an OK packet ending the rows when
+// CLIENT_DEPRECATE_EOF is set. The recorded result set is left as is.
+func adaptResultSet(resultSet *models.MySQLResultSet, capabilities uint32) *models.MySQLResultSet {
+	deprecateEOF := capabilities&uint32(models.CLIENT_DEPRECATE_EOF) != 0
+	if resultSet.EOFPresent != deprecateEOF || resultSet.PaddingPresent || resultSet.PaddingPresentFinal {
+		return resultSet
+	}
+	end, err := base64.StdEncoding.DecodeString(resultSet.OptionalEOFBytes)
+	if err != nil {
+		return resultSet
+	}
+	packet, rest, err := nextResultSetPacket(end)
+	if err != nil || len(packet) < 5 || packet[4] != 0xfe {
+		return resultSet
+	}
+
+	adapted := *resultSet
+	if deprecateEOF {
+		status, warnings, ok := eofStatus(packet[4:])
+		if !ok {
+			return resultSet
+		}
+		adapted.EOFPresent = false
+		adapted.EOFAfterColumns = ""
+		packet = append([]byte{7, 0, 0, packet[3], 0xfe, 0, 0}, le16(status)...)
+		packet = append(packet, le16(warnings)...)
+	} else {
+		status, warnings, ok := okStatus(packet[4:])
+		if !ok {
+			return resultSet
+		}
+		eof := append([]byte{5, 0, 0, packet[3], 0xfe}, le16(warnings)...)
+		eof = append(eof, le16(status)...)
+		adapted.EOFPresent = true
+		// the sequence ids are renumbered when the response is written
+		adapted.EOFAfterColumns = base64.StdEncoding.EncodeToString(eof)
+		packet = eof
+	}
+	adapted.OptionalEOFBytes = base64.StdEncoding.EncodeToString(append(packet, rest...))
+	return &adapted
+}
+
+// eofStatus returns the status flags and the warning count of an EOF packet.
+func eofStatus(payload []byte) (uint16, uint16, bool) {
+	if len(payload) != 5 {
+		return 0, 0, false
+	}
+	return binary.LittleEndian.Uint16(payload[3:5]), binary.LittleEndian.Uint16(payload[1:3]), true
+}
+
+// okStatus returns the status flags and the warning count of an OK packet.
+func okStatus(payload []byte) (uint16, uint16, bool) {
+	offset := 1
+	for i := 0; i < 2; i++ {
+		if _, err := readLengthEncodedIntegerOff(payload, &offset); err != nil {
+			return 0, 0, false
+		}
+	}
+	if offset+4 > len(payload) {
+		return 0, 0, false
+	}
+	return binary.LittleEndian.Uint16(payload[offset:]), binary.LittleEndian.Uint16(payload[offset+2:]), true
+}
+
+func le16(v uint16) []byte {
+	return binary.LittleEndian.AppendUint16(nil, v)
+}
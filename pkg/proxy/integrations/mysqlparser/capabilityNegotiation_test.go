@@ -0,0 +1,77 @@
+package mysqlparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestCapabilityFlags(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		flag models.CapabilityFlags
+		want uint32
+	}{
+		{name: "CLIENT_SSL", flag: models.CLIENT_SSL, want: 0x00000800},
+		{name: "CLIENT_SECURE_CONNECTION", flag: models.CLIENT_SECURE_CONNECTION, want: 0x00008000},
+		{name: "CLIENT_MULTI_STATEMENTS", flag: models.CLIENT_MULTI_STATEMENTS, want: 0x00010000},
+		{name: "CLIENT_PLUGIN_AUTH", flag: models.CLIENT_PLUGIN_AUTH, want: 0x00080000},
+		{name: "CLIENT_DEPRECATE_EOF", flag: models.CLIENT_DEPRECATE_EOF, want: 0x01000000},
+	} {
+		if uint32(tt.flag) != tt.want {
+			t.Errorf("%s is %#x, want %#x", tt.name, uint32(tt.flag), tt.want)
+		}
+	}
+}
+
+func TestNegotiatedCapabilities(t *testing.T) {
+	greeting := &models.MySQLHandshakeV10Packet{CapabilityFlags: uint32(models.CLIENT_PROTOCOL_41 | models.CLIENT_SSL | models.CLIENT_DEPRECATE_EOF)}
+	response := &HandshakeResponse{CapabilityFlags: uint32(models.CLIENT_PROTOCOL_41 | models.CLIENT_DEPRECATE_EOF | models.CLIENT_MULTI_STATEMENTS)}
+	if got, want := negotiatedCapabilities(greeting, response), uint32(models.CLIENT_PROTOCOL_41|models.CLIENT_DEPRECATE_EOF); got != want {
+		t.Errorf("negotiated %#x, want %#x", got, want)
+	}
+	if got := negotiatedCapabilities(nil, response); got != 0 {
+		t.Errorf("negotiated %#x without the greeting", got)
+	}
+}
+
+func TestAdaptResultSet(t *testing.T) {
+	// the status flags 0x0022 and a warning, in the order of each packet
+	eof := []byte{5, 0, 0, 4, 0xfe, 0x01, 0x00, 0x22, 0x00}
+	ok := []byte{7, 0, 0, 4, 0xfe, 0x00, 0x00, 0x22, 0x00, 0x01, 0x00}
+	encode := base64.StdEncoding.EncodeToString
+	classic := &models.MySQLResultSet{EOFPresent: true, EOFAfterColumns: encode(eof), OptionalEOFBytes: encode(eof)}
+	deprecated := &models.MySQLResultSet{OptionalEOFBytes: encode(ok)}
+
+	for _, tt := range []struct {
+		name          string
+		recorded      *models.MySQLResultSet
+		capabilities  uint32
+		wantEOF       bool
+		wantAfterCols []byte
+		wantEnd       []byte
+	}{
+		{name: "classic replayed to a client deprecating the EOF", recorded: classic, capabilities: uint32(models.CLIENT_DEPRECATE_EOF), wantEnd: ok},
+		{name: "deprecated EOF replayed to a classic client", recorded: deprecated, capabilities: uint32(models.CLIENT_PROTOCOL_41), wantEOF: true, wantAfterCols: eof, wantEnd: eof},
+		{name: "classic replayed to a classic client", recorded: classic, capabilities: uint32(models.CLIENT_PROTOCOL_41), wantEOF: true, wantAfterCols: eof, wantEnd: eof},
+		{name: "deprecated EOF replayed to a client deprecating it", recorded: deprecated, capabilities: uint32(models.CLIENT_DEPRECATE_EOF), wantEnd: ok},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			adapted := adaptResultSet(tt.recorded, tt.capabilities)
+			if adapted.EOFPresent != tt.wantEOF {
+				t.Errorf("got the EOF after the columns %v, want %v", adapted.EOFPresent, tt.wantEOF)
+			}
+			if got, _ := base64.StdEncoding.DecodeString(adapted.EOFAfterColumns); !bytes.Equal(got, tt.wantAfterCols) {
+				t.Errorf("got the EOF after the columns % x, want % x", got, tt.wantAfterCols)
+			}
+			if got, _ := base64.StdEncoding.DecodeString(adapted.OptionalEOFBytes); !bytes.Equal(got, tt.wantEnd) {
+				t.Errorf("got the end of the rows % x, want % x", got, tt.wantEnd)
+			}
+		})
+	}
+	if !classic.EOFPresent || classic.OptionalEOFBytes != encode(eof) || deprecated.EOFPresent || deprecated.OptionalEOFBytes != encode(ok) {
+		t.Errorf("changed the recorded result sets")
+	}
+}
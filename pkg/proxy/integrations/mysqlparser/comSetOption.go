@@ -0,0 +1,34 @@
+package mysqlparser
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	MYSQL_OPTION_MULTI_STATEMENTS_ON  = 0
+	MYSQL_OPTION_MULTI_STATEMENTS_OFF = 1
+	// SERVER_MORE_RESULTS_EXISTS is set in the status of the OK packet ending
+	// a result when the results of more statements follow.
+	SERVER_MORE_RESULTS_EXISTS = 0x0008
+)
+
+type ComSetOptionPacket struct {
+	Option uint16 `json:"option" yaml:"option,flow"`
+}
+
+func decodeComSetOption(packet []byte, state *connectionState) (*ComSetOptionPacket, error) {
+	if len(packet) != 3 || packet[0] != 0x1b {
+		return nil, fmt.Errorf("invalid COM_SET_OPTION packet")
+	}
+	option := binary.LittleEndian.Uint16(packet[1:3])
+	switch option {
+	case MYSQL_OPTION_MULTI_STATEMENTS_ON:
+		state.multiStatementsEnabled = true
+	case MYSQL_OPTION_MULTI_STATEMENTS_OFF:
+		state.multiStatementsEnabled = false
+	default:
+		return nil, fmt.Errorf("unknown COM_SET_OPTION option %d", option)
+	}
+	return &ComSetOptionPacket{Option: option}, nil
+}
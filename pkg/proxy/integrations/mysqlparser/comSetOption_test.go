@@ -0,0 +1,90 @@
+package mysqlparser
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func setMode(t *testing.T, mode models.Mode) {
+	previous := models.GetMode()
+	if err := models.SetMode(mode); err != nil {
+		t.Fatalf("failed to set the mode: %v", err)
+	}
+	t.Cleanup(func() { models.SetMode(previous) })
+}
+
+// okPayload returns the payload of an OK packet with the status flags and
+// without warnings.
+func okPayload(statusFlags uint16) []byte {
+	return append(binary.LittleEndian.AppendUint16([]byte{0x00, 0x01, 0x00}, statusFlags), 0x00, 0x00)
+}
+
+func packetType(t *testing.T, state *connectionState, payload []byte) string {
+	t.Helper()
+	decodedType, _ := decodePayload(t, state, 0, payload)
+	return decodedType
+}
+
+func setOption(option uint16) []byte {
+	return binary.LittleEndian.AppendUint16([]byte{0x1b}, option)
+}
+
+func TestComSetOptionEnablesMultiStatements(t *testing.T) {
+	setMode(t, models.MODE_RECORD)
+	state := newConnectionState()
+	if got := packetType(t, state, setOption(MYSQL_OPTION_MULTI_STATEMENTS_ON)); got != "COM_SET_OPTION" {
+		t.Fatalf("got the packet %s, want COM_SET_OPTION", got)
+	}
+	if !state.multiStatementsEnabled {
+		t.Fatalf("multi-statements are off after they were set on")
+	}
+	if got := packetType(t, state, []byte{0xfe, 0x00, 0x00, 0x02, 0x00}); got != "MySQLEOF" {
+		t.Fatalf("got the response %s to COM_SET_OPTION, want MySQLEOF", got)
+	}
+	if state.lastCommand != 0x00 {
+		t.Errorf("got the last command %#x after the response, want none", state.lastCommand)
+	}
+
+	packetType(t, state, setOption(MYSQL_OPTION_MULTI_STATEMENTS_OFF))
+	if state.multiStatementsEnabled {
+		t.Errorf("multi-statements are on after they were set off")
+	}
+	if _, err := decodeComSetOption(setOption(7), state); err == nil {
+		t.Errorf("decoded the unknown option 7")
+	}
+	if _, err := decodeComSetOption([]byte{0x1b, 0x00}, state); err == nil {
+		t.Errorf("decoded the truncated COM_SET_OPTION")
+	}
+}
+
+func TestMoreResultsKeepTheQueryCommand(t *testing.T) {
+	setMode(t, models.MODE_RECORD)
+	query := append([]byte{0x03}, "INSERT INTO a VALUES (1); INSERT INTO b VALUES (2)"...)
+	for _, tt := range []struct {
+		name           string
+		multiStatement bool
+		want           []byte
+	}{
+		{name: "multi-statements on", multiStatement: true, want: []byte{0x03, 0x00}},
+		{name: "multi-statements off", want: []byte{0x00, 0x00}},
+	} {
+		state := newConnectionState()
+		state.multiStatementsEnabled = tt.multiStatement
+		if got := packetType(t, state, query); got != "MySQLQuery" {
+			t.Fatalf("%s: got the packet %s, want MySQLQuery", tt.name, got)
+		}
+		// the OK of the first statement announces the results of the second one
+		if got := packetType(t, state, okPayload(SERVER_MORE_RESULTS_EXISTS|0x0002)); got != "MySQLOK" {
+			t.Fatalf("%s: got the packet %s, want MySQLOK", tt.name, got)
+		}
+		if state.lastCommand != tt.want[0] {
+			t.Errorf("%s: got the last command %#x after the first result, want %#x", tt.name, state.lastCommand, tt.want[0])
+		}
+		packetType(t, state, okPayload(0x0002))
+		if state.lastCommand != tt.want[1] {
+			t.Errorf("%s: got the last command %#x after the last result, want %#x", tt.name, state.lastCommand, tt.want[1])
+		}
+	}
+}
@@ -3,6 +3,8 @@ package mysqlparser
 import (
 	"encoding/binary"
 	"fmt"
+
+	"go.keploy.io/server/pkg/models"
 )
 
 type EOFPacket struct {
@@ -30,3 +32,11 @@ func decodeMYSQLEOF(data []byte) (*EOFPacket, error) {
 
 	return packet, nil
 }
+
+func encodeMySQLEOF(packet *models.MySQLEOFPacket) ([]byte, error) {
+	data := make([]byte, 5)
+	data[0] = 0xfe
+	binary.LittleEndian.PutUint16(data[1:3], packet.Warnings)
+	binary.LittleEndian.PutUint16(data[3:5], packet.StatusFlags)
+	return data, nil
+}
@@ -4,6 +4,10 @@ import (
 	"encoding/base64"
 	"encoding/binary"
 	"fmt"
+	"math"
+	"strconv"
+
+	"go.keploy.io/server/pkg/models"
 )
 
 type ComStmtExecute struct {
@@ -13,49 +17,230 @@ type ComStmtExecute struct {
 	NullBitmap     string           `json:"null_bitmap,omitempty" yaml:"null_bitmap,omitempty,flow"`
 	ParamCount     uint16           `json:"param_count,omitempty" yaml:"param_count,omitempty,flow"`
 	Parameters     []BoundParameter `json:"parameters,omitempty" yaml:"parameters,omitempty,flow"`
+	Query          string           `json:"query,omitempty" yaml:"query,omitempty,flow"`
 }
 
+// BoundParameter is a parameter of a prepared statement along with its MySQL
+// type code. Unsigned holds the flag byte sent with the type (0x80 for the
+// unsigned integers) and Text the readable form of the value.
 type BoundParameter struct {
 	Type     byte   `json:"type,omitempty" yaml:"type,omitempty,flow"`
 	Unsigned byte   `json:"unsigned,omitempty" yaml:"unsigned,omitempty,flow"`
+	Null     bool   `json:"null,omitempty" yaml:"null,omitempty,flow"`
 	Value    []byte `json:"value,omitempty" yaml:"value,omitempty,flow"`
+	Text     string `json:"text,omitempty" yaml:"text,omitempty,flow"`
+}
+
+const unsignedParameterFlag = 0x80
+
+// notePreparedQuery keeps the query of the last COM_STMT_PREPARE until its
+// COM_STMT_PREPARE_OK assigns it a statement ID.
+func (state *connectionState) notePreparedQuery(query string) {
+	state.preparing = query
 }
 
-func decodeComStmtExecute(packet []byte) (ComStmtExecute, error) {
-	// removed the print statement for cleanliness
-	if len(packet) < 14 { // the minimal size of the packet without parameters should be 14, not 13
-		return ComStmtExecute{}, fmt.Errorf("packet length less than 14 bytes")
+func (state *connectionState) rememberPreparedStatement(statementID uint32, numParams uint16) {
+	state.paramCounts[statementID] = numParams
+	state.queries[statementID] = state.preparing
+	state.preparing = ""
+}
+
+// closePreparedStatement forgets a statement deallocated by COM_STMT_CLOSE,
+// whose ID the server may assign again.
+func (state *connectionState) closePreparedStatement(statementID uint32) {
+	delete(state.paramCounts, statementID)
+	delete(state.queries, statementID)
+}
+
+func (state *connectionState) preparedParamCount(statementID uint32) (uint16, bool) {
+	count, ok := state.paramCounts[statementID]
+	return count, ok
+}
+
+func (state *connectionState) preparedQuery(statementID uint32) string {
+	return state.queries[statementID]
+}
+
+func decodeComStmtExecute(packet []byte, state *connectionState) (ComStmtExecute, error) {
+	if len(packet) < 10 {
+		return ComStmtExecute{}, fmt.Errorf("packet length less than 10 bytes")
 	}
-	var NullBitmapValue []byte
 
 	stmtExecute := ComStmtExecute{}
 	stmtExecute.StatementID = binary.LittleEndian.Uint32(packet[1:5])
 	stmtExecute.Flags = packet[5]
 	stmtExecute.IterationCount = binary.LittleEndian.Uint32(packet[6:10])
+	stmtExecute.Query = state.preparedQuery(stmtExecute.StatementID)
 
-	// the next bytes are reserved for the Null-Bitmap, Parameter Bound Flag and Bound Parameters if they exist
-	// if the length of the packet is greater than 14, then there are parameters
-	if len(packet) > 14 {
-		nullBitmapLength := int((stmtExecute.ParamCount + 7) / 8)
+	// the parameters can only be decoded for the statements whose preparation
+	// has been seen, as the packet does not carry their count
+	paramCount, ok := state.preparedParamCount(stmtExecute.StatementID)
+	if !ok || paramCount == 0 || len(packet) == 10 {
+		return stmtExecute, nil
+	}
+	stmtExecute.ParamCount = paramCount
 
-		NullBitmapValue = packet[10 : 10+nullBitmapLength]
-		stmtExecute.NullBitmap = base64.StdEncoding.EncodeToString(NullBitmapValue)
-		stmtExecute.ParamCount = binary.LittleEndian.Uint16(packet[10+nullBitmapLength:])
+	offset := 10
+	nullBitmapLength := int((paramCount + 7) / 8)
+	if offset+nullBitmapLength+1 > len(packet) {
+		return ComStmtExecute{}, fmt.Errorf("packet length less than expected while reading the null bitmap")
+	}
+	nullBitmap := packet[offset : offset+nullBitmapLength]
+	stmtExecute.NullBitmap = base64.StdEncoding.EncodeToString(nullBitmap)
+	offset += nullBitmapLength
+
+	// the types are only sent when new parameters are bound
+	newParamsBound := packet[offset] == 1
+	offset++
+	if !newParamsBound {
+		return stmtExecute, nil
+	}
+
+	stmtExecute.Parameters = make([]BoundParameter, paramCount)
+	for i := range stmtExecute.Parameters {
+		if offset+2 > len(packet) {
+			return ComStmtExecute{}, fmt.Errorf("packet length less than expected while reading parameter types")
+		}
+		stmtExecute.Parameters[i].Type = packet[offset]
+		stmtExecute.Parameters[i].Unsigned = packet[offset+1]
+		offset += 2
+	}
 
-		// in case new parameters are bound, the new types and values are sent
-		if packet[10+nullBitmapLength] == 1 {
-			// read the types and values of the new parameters
-			stmtExecute.Parameters = make([]BoundParameter, stmtExecute.ParamCount)
-			for i := 0; i < int(stmtExecute.ParamCount); i++ {
-				index := 10 + nullBitmapLength + 1 + 2*i
-				if index+1 >= len(packet) {
-					return ComStmtExecute{}, fmt.Errorf("packet length less than expected while reading parameters")
-				}
-				stmtExecute.Parameters[i].Type = packet[index]
-				stmtExecute.Parameters[i].Unsigned = packet[index+1]
-			}
+	for i := range stmtExecute.Parameters {
+		param := &stmtExecute.Parameters[i]
+		if nullBitmap[i/8]&(1<<(uint(i)%8)) != 0 {
+			param.Null = true
+			continue
 		}
+		length, err := binaryValueLength(models.FieldType(param.Type), packet[offset:])
+		if err != nil {
+			return ComStmtExecute{}, fmt.Errorf("failed to read parameter %d: %v", i, err)
+		}
+		if offset+length > len(packet) {
+			return ComStmtExecute{}, fmt.Errorf("packet length less than expected while reading parameter %d", i)
+		}
+		param.Value = packet[offset : offset+length]
+		param.Text = binaryValueText(models.FieldType(param.Type), param.Unsigned&unsignedParameterFlag != 0, param.Value)
+		offset += length
 	}
 
 	return stmtExecute, nil
 }
+
+// binaryValueLength returns the number of bytes taken by a value of the given
+// type in the binary protocol, including its length prefix if any.
+func binaryValueLength(fieldType models.FieldType, data []byte) (int, error) {
+	switch fieldType {
+	case models.FieldTypeNULL:
+		return 0, nil
+	case models.FieldTypeTiny:
+		return 1, nil
+	case models.FieldTypeShort, models.FieldTypeYear:
+		return 2, nil
+	case models.FieldTypeLong, models.FieldTypeInt24, models.FieldTypeFloat:
+		return 4, nil
+	case models.FieldTypeLongLong, models.FieldTypeDouble:
+		return 8, nil
+	case models.FieldTypeDate, models.FieldTypeDateTime, models.FieldTypeTimestamp, models.FieldTypeTime:
+		if len(data) < 1 {
+			return 0, fmt.Errorf("missing the length of the temporal value")
+		}
+		return 1 + int(data[0]), nil
+	default:
+		if len(data) < 1 {
+			return 0, fmt.Errorf("missing the length of the value")
+		}
+		length, isNull, n := readLengthEncodedInteger(data)
+		if isNull || n > len(data) {
+			return 0, fmt.Errorf("invalid length encoded value")
+		}
+		return n + int(length), nil
+	}
+}
+
+// binaryValueText renders a value of the binary protocol in a readable form.
+func binaryValueText(fieldType models.FieldType, unsigned bool, value []byte) string {
+	switch fieldType {
+	case models.FieldTypeNULL:
+		return ""
+	case models.FieldTypeTiny:
+		if unsigned {
+			return strconv.FormatUint(uint64(value[0]), 10)
+		}
+		return strconv.FormatInt(int64(int8(value[0])), 10)
+	case models.FieldTypeShort, models.FieldTypeYear:
+		v := binary.LittleEndian.Uint16(value)
+		if unsigned {
+			return strconv.FormatUint(uint64(v), 10)
+		}
+		return strconv.FormatInt(int64(int16(v)), 10)
+	case models.FieldTypeLong, models.FieldTypeInt24:
+		v := binary.LittleEndian.Uint32(value)
+		if unsigned {
+			return strconv.FormatUint(uint64(v), 10)
+		}
+		return strconv.FormatInt(int64(int32(v)), 10)
+	case models.FieldTypeLongLong:
+		v := binary.LittleEndian.Uint64(value)
+		if unsigned {
+			return strconv.FormatUint(v, 10)
+		}
+		return strconv.FormatInt(int64(v), 10)
+	case models.FieldTypeFloat:
+		return strconv.FormatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(value))), 'g', -1, 32)
+	case models.FieldTypeDouble:
+		return strconv.FormatFloat(math.Float64frombits(binary.LittleEndian.Uint64(value)), 'g', -1, 64)
+	case models.FieldTypeDate, models.FieldTypeDateTime, models.FieldTypeTimestamp:
+		return binaryDateTimeText(value[1:])
+	case models.FieldTypeTime:
+		return binaryTimeText(value[1:])
+	default:
+		_, _, n := readLengthEncodedInteger(value)
+		return string(value[n:])
+	}
+}
+
+func binaryDateTimeText(v []byte) string {
+	if len(v) < 4 {
+		return "0000-00-00 00:00:00"
+	}
+	text := fmt.Sprintf("%04d-%02d-%02d", binary.LittleEndian.Uint16(v[0:2]), v[2], v[3])
+	if len(v) >= 7 {
+		text += fmt.Sprintf(" %02d:%02d:%02d", v[4], v[5], v[6])
+	}
+	if len(v) >= 11 {
+		text += fmt.Sprintf(".%06d", binary.LittleEndian.Uint32(v[7:11]))
+	}
+	return text
+}
+
+func binaryTimeText(v []byte) string {
+	if len(v) < 8 {
+		return "00:00:00"
+	}
+	sign := ""
+	if v[0] == 1 {
+		sign = "-"
+	}
+	hours := binary.LittleEndian.Uint32(v[1:5])*24 + uint32(v[5])
+	text := fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, v[6], v[7])
+	if len(v) >= 12 {
+		text += fmt.Sprintf(".%06d", binary.LittleEndian.Uint32(v[8:12]))
+	}
+	return text
+}
+
+// stmtExecuteParametersEqual compares the typed values of the parameters of
+// an incoming COM_STMT_EXECUTE with the recorded ones.
+func stmtExecuteParametersEqual(actual []BoundParameter, recorded []models.BoundParameter) bool {
+	if len(actual) != len(recorded) {
+		return false
+	}
+	for i, p := range actual {
+		r := recorded[i]
+		if p.Type != r.Type || p.Unsigned != r.Unsigned || p.Null != r.Null || string(p.Value) != string(r.Value) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,155 @@
+package mysqlparser
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// executePacket returns a COM_STMT_EXECUTE of the statement 1 with its null
+// bitmap and new-params-bound flag followed by the rest of the packet.
+func executePacket(nullBitmap byte, newParamsBound byte, rest ...byte) []byte {
+	packet := []byte{0x17}
+	packet = binary.LittleEndian.AppendUint32(packet, 1)
+	packet = append(packet, 0x00)
+	packet = binary.LittleEndian.AppendUint32(packet, 1)
+	packet = append(packet, nullBitmap, newParamsBound)
+	return append(packet, rest...)
+}
+
+func TestDecodeComStmtExecuteParameters(t *testing.T) {
+	state := newConnectionState()
+	state.notePreparedQuery("SELECT * FROM t WHERE a = ? AND b = ? AND c = ? AND d = ?")
+	state.rememberPreparedStatement(1, 4)
+
+	packet := executePacket(0x04, 1,
+		// the types: an unsigned TINY, a signed LONGLONG, a NULL and a string
+		byte(models.FieldTypeTiny), unsignedParameterFlag,
+		byte(models.FieldTypeLongLong), 0x00,
+		byte(models.FieldTypeVarString), 0x00,
+		byte(models.FieldTypeVarString), 0x00,
+		// the values, the NULL one left out
+		0xff,
+		0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0x02, 'o', 'k',
+	)
+	decoded, err := decodeComStmtExecute(packet, state)
+	if err != nil {
+		t.Fatalf("failed to decode the packet: %v", err)
+	}
+	if decoded.Query != "SELECT * FROM t WHERE a = ? AND b = ? AND c = ? AND d = ?" || decoded.ParamCount != 4 {
+		t.Fatalf("decoded the statement %q of %d parameters", decoded.Query, decoded.ParamCount)
+	}
+	want := []BoundParameter{
+		{Type: byte(models.FieldTypeTiny), Unsigned: unsignedParameterFlag, Value: []byte{0xff}, Text: "255"},
+		{Type: byte(models.FieldTypeLongLong), Value: []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, Text: "-2"},
+		{Type: byte(models.FieldTypeVarString), Null: true},
+		{Type: byte(models.FieldTypeVarString), Value: []byte{0x02, 'o', 'k'}, Text: "ok"},
+	}
+	if len(decoded.Parameters) != len(want) {
+		t.Fatalf("decoded %d parameters, want %d", len(decoded.Parameters), len(want))
+	}
+	for i, param := range decoded.Parameters {
+		if param.Type != want[i].Type || param.Unsigned != want[i].Unsigned || param.Null != want[i].Null ||
+			string(param.Value) != string(want[i].Value) || param.Text != want[i].Text {
+			t.Errorf("decoded the parameter %d as %+v, want %+v", i, param, want[i])
+		}
+	}
+
+	// the same bytes bound as a signed TINY are another value
+	recorded := make([]models.BoundParameter, len(decoded.Parameters))
+	for i, param := range decoded.Parameters {
+		recorded[i] = models.BoundParameter{Type: param.Type, Unsigned: param.Unsigned, Null: param.Null, Value: param.Value}
+	}
+	if !stmtExecuteParametersEqual(decoded.Parameters, recorded) {
+		t.Fatalf("the parameters don't match their own record")
+	}
+	recorded[0].Unsigned = 0
+	if stmtExecuteParametersEqual(decoded.Parameters, recorded) {
+		t.Fatalf("matched the unsigned parameter with a signed one")
+	}
+}
+
+func TestDecodeComStmtExecuteWithoutNewParamsBound(t *testing.T) {
+	state := newConnectionState()
+	state.rememberPreparedStatement(1, 2)
+
+	// the types were sent by a former execution, so the values can't be read
+	decoded, err := decodeComStmtExecute(executePacket(0x00, 0, 0x01, 0x00), state)
+	if err != nil {
+		t.Fatalf("failed to decode the packet: %v", err)
+	}
+	if decoded.ParamCount != 2 || decoded.Parameters != nil {
+		t.Fatalf("decoded the parameters %+v without their types", decoded.Parameters)
+	}
+}
+
+func TestDecodeComStmtExecuteTruncated(t *testing.T) {
+	state := newConnectionState()
+	state.rememberPreparedStatement(1, 1)
+
+	for name, packet := range map[string][]byte{
+		"types":  executePacket(0x00, 1, byte(models.FieldTypeLong)),
+		"values": executePacket(0x00, 1, byte(models.FieldTypeLong), 0x00, 0x01, 0x00),
+		"string": executePacket(0x00, 1, byte(models.FieldTypeVarString), 0x00),
+	} {
+		if _, err := decodeComStmtExecute(packet, state); err == nil {
+			t.Errorf("decoded the packet truncated within its %s", name)
+		}
+	}
+}
+
+func TestPreparedQueriesPerConnection(t *testing.T) {
+	first, second := newConnectionState(), newConnectionState()
+	first.notePreparedQuery("SELECT name FROM users WHERE id = ?")
+	first.rememberPreparedStatement(1, 1)
+	second.notePreparedQuery("DELETE FROM users WHERE id = ?")
+	second.rememberPreparedStatement(1, 1)
+
+	if got := first.preparedQuery(1); got != "SELECT name FROM users WHERE id = ?" {
+		t.Errorf("got the query %q of the first connection", got)
+	}
+	if got := second.preparedQuery(1); got != "DELETE FROM users WHERE id = ?" {
+		t.Errorf("got the query %q of the second connection", got)
+	}
+
+	// the server may assign the ID of a closed statement again
+	first.closePreparedStatement(1)
+	if got := first.preparedQuery(1); got != "" {
+		t.Errorf("got the query %q of a closed statement", got)
+	}
+}
+
+func TestCompareStatementExecutions(t *testing.T) {
+	execution := func(statementID uint32, query string) models.MySQLRequest {
+		return models.MySQLRequest{
+			Header:  &models.MySQLPacketHeader{PacketType: "COM_STMT_EXECUTE"},
+			Message: ComStmtExecute{StatementID: statementID, Query: query},
+		}
+	}
+	recording := func(statementID uint32, query string) models.MySQLRequest {
+		return models.MySQLRequest{
+			Header:  &models.MySQLPacketHeader{PacketType: "COM_STMT_EXECUTE"},
+			Message: &models.MySQLComStmtExecute{StatementID: statementID, Query: query},
+		}
+	}
+	// the equal headers score 3, the same statement 5 more
+	for _, tt := range []struct {
+		name     string
+		incoming models.MySQLRequest
+		recorded models.MySQLRequest
+		want     int
+	}{
+		{name: "same query under another ID", incoming: execution(2, "SELECT 1"), recorded: recording(1, "SELECT 1"), want: 8},
+		{name: "another query under the same ID", incoming: execution(1, "SELECT 2"), recorded: recording(1, "SELECT 1"), want: 3},
+		{name: "preparation unseen", incoming: execution(1, ""), recorded: recording(1, "SELECT 1"), want: 8},
+		{name: "preparation unseen under another ID", incoming: execution(2, ""), recorded: recording(1, "SELECT 1"), want: 3},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareMySQLRequests(tt.incoming, tt.recorded); got != tt.want {
+				t.Errorf("got the score %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"encoding/base64"
 	"fmt"
+	"net"
 
 	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
 )
 
 type HandshakeResponseOk struct {
@@ -14,14 +16,14 @@ type HandshakeResponseOk struct {
 	RemainingBytes  string        `json:"remaining_bytes,omitempty" yaml:"remaining_bytes,omitempty,flow"`
 }
 
-func decodeHandshakeResponseOk(data []byte) (*HandshakeResponseOk, error) {
+func decodeHandshakeResponseOk(data []byte, state *connectionState) (*HandshakeResponseOk, error) {
 	var (
 		packetIndicator string
 		authType        string
 		message         string
 		remainingBytes  []byte
 	)
-	if isPluginData {
+	if state.isPluginData {
 		publicKeyData := string(data[1:])
 		authType = "PublicKeyAuthentication"
 		message = "Public key for authentication"
@@ -39,24 +41,18 @@ func decodeHandshakeResponseOk(data []byte) (*HandshakeResponseOk, error) {
 		packetIndicator = "Unknown"
 	}
 
-	if data[0] == models.AuthMoreData {
-		count := int(data[0])
-		var authData = data[1 : count+1]
-		switch handshakePluginName {
-		case "caching_sha2_password":
-			switch len(authData) {
-			case 1:
-				switch authData[0] {
-				case models.CachingSha2PasswordFastAuthSuccess:
-					authType = "cachingSha2PasswordFastAuthSuccess"
-					message = "Ok"
-					remainingBytes = data[count+1:]
-				case models.CachingSha2PasswordPerformFullAuthentication:
-					authType = "cachingSha2PasswordPerformFullAuthentication"
-					message = ""
-					remainingBytes = data[count+1:]
-				}
-			}
+	// the auth-more-data of the caching_sha2_password fast authentication is
+	// a single status byte, followed by the OK packet when it succeeded
+	if data[0] == models.AuthMoreData && len(data) >= 2 && state.pluginName == "caching_sha2_password" {
+		switch data[1] {
+		case models.CachingSha2PasswordFastAuthSuccess:
+			authType = "cachingSha2PasswordFastAuthSuccess"
+			message = "Ok"
+			remainingBytes = data[2:]
+		case models.CachingSha2PasswordPerformFullAuthentication:
+			authType = "cachingSha2PasswordPerformFullAuthentication"
+			message = ""
+			remainingBytes = data[2:]
 		}
 	}
 
@@ -140,3 +136,24 @@ func encodeHandshakeResponseOk(packet *models.MySQLHandshakeResponseOk) ([]byte,
 	}
 	return payload, nil
 }
+
+// awaitFastAuthOK reads the OK packet following a successful fast
+// authentication when the server sent it apart from the auth-more-data, and
+// relays it to the client. The packet is kept in the remaining bytes of the
+// response, so that both are replayed together.
+func awaitFastAuthOK(response interface{}, clientConn, destConn net.Conn) error {
+	fastAuth, ok := response.(*HandshakeResponseOk)
+	if !ok || fastAuth.PluginDetails.Type != "cachingSha2PasswordFastAuthSuccess" || fastAuth.RemainingBytes != "" {
+		return nil
+	}
+	okPacket, err := util.ReadBytes(destConn)
+	if err != nil {
+		return fmt.Errorf("failed to read the OK packet following the fast authentication: %v", err)
+	}
+	_, err = clientConn.Write(okPacket)
+	if err != nil {
+		return fmt.Errorf("failed to write the OK packet following the fast authentication to the client: %v", err)
+	}
+	fastAuth.RemainingBytes = base64.StdEncoding.EncodeToString(okPacket)
+	return nil
+}
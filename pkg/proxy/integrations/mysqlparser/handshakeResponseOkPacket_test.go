@@ -0,0 +1,67 @@
+package mysqlparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestAwaitFastAuthOK(t *testing.T) {
+	// the OK packet the server sends apart from the fast authentication status
+	okPacket := []byte{0x07, 0x00, 0x00, 0x04, 0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00}
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	defer app.Close()
+	defer server.Close()
+	go server.Write(okPacket)
+	relayed := make(chan []byte, 1)
+	go func() {
+		buffer := make([]byte, len(okPacket))
+		io.ReadFull(app, buffer)
+		relayed <- buffer
+	}()
+
+	response := &HandshakeResponseOk{PluginDetails: PluginDetails{Type: "cachingSha2PasswordFastAuthSuccess"}}
+	if err := awaitFastAuthOK(response, clientConn, destConn); err != nil {
+		t.Fatalf("failed to relay the OK packet: %v", err)
+	}
+	select {
+	case got := <-relayed:
+		if !bytes.Equal(got, okPacket) {
+			t.Errorf("relayed %v to the client, want the OK packet %v", got, okPacket)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("relayed nothing to the client")
+	}
+	if want := base64.StdEncoding.EncodeToString(okPacket); response.RemainingBytes != want {
+		t.Errorf("kept the remaining bytes %q, want the OK packet %q", response.RemainingBytes, want)
+	}
+}
+
+func TestAwaitFastAuthOKReadsNothingElse(t *testing.T) {
+	for _, response := range []interface{}{
+		// the OK packet came along with the status
+		&HandshakeResponseOk{PluginDetails: PluginDetails{Type: "cachingSha2PasswordFastAuthSuccess"}, RemainingBytes: "BwAAAgAAAAIAAAA="},
+		&HandshakeResponseOk{PluginDetails: PluginDetails{Type: "cachingSha2PasswordPerformFullAuthentication"}},
+		&AuthSwitchRequestPacket{},
+	} {
+		// nothing is written to the connections, a read would block forever
+		clientConn, app := net.Pipe()
+		destConn, server := net.Pipe()
+		done := make(chan error, 1)
+		go func() { done <- awaitFastAuthOK(response, clientConn, destConn) }()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Errorf("got the error %v for %+v, want none", err, response)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("waited for an OK packet after %+v", response)
+		}
+		app.Close()
+		server.Close()
+	}
+}
@@ -8,10 +8,11 @@ import (
 )
 
 const (
-	CLIENT_PLUGIN_AUTH                = 0x00080000
-	CLIENT_CONNECT_WITH_DB            = 0x00000008
-	CLIENT_CONNECT_ATTRS              = 0x00100000
-	CLIENT_ZSTD_COMPRESSION_ALGORITHM = 0x00010000
+	CLIENT_PLUGIN_AUTH                    = 0x00080000
+	CLIENT_CONNECT_WITH_DB                = 0x00000008
+	CLIENT_CONNECT_ATTRS                  = 0x00100000
+	CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA = 0x00200000
+	CLIENT_ZSTD_COMPRESSION_ALGORITHM     = 0x00010000
 )
 
 type HandshakeResponse struct {
@@ -53,9 +54,23 @@ func decodeHandshakeResponse(data []byte) (*HandshakeResponse, error) {
 	packet.Username = string(data[:idx])
 	data = data[idx+1:]
 
-	if packet.CapabilityFlags&CLIENT_PLUGIN_AUTH != 0 {
-		length := int(data[0])
-		data = data[1:]
+	if packet.CapabilityFlags&(CLIENT_PLUGIN_AUTH|CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA) != 0 {
+		// the auth data longer than 250 bytes has a length encoded length
+		length, n := int(0), 1
+		if len(data) > 0 {
+			length = int(data[0])
+		}
+		if packet.CapabilityFlags&CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA != 0 {
+			var isNull bool
+			length, isNull, n = decodeLengthEncodedInteger(data)
+			if isNull || n == 0 {
+				return nil, errors.New("malformed handshake response packet: invalid length of the auth data")
+			}
+		}
+		if len(data) < n {
+			return nil, errors.New("handshake response packet too short for auth data")
+		}
+		data = data[n:]
 
 		if length > 0 {
 			if len(data) < length {
@@ -89,42 +104,13 @@ func decodeHandshakeResponse(data []byte) (*HandshakeResponse, error) {
 		data = data[idx+1:]
 	}
 
-	if packet.CapabilityFlags&CLIENT_CONNECT_ATTRS != 0 {
-		if len(data) < 4 {
-			return nil, errors.New("handshake response packet too short for connection attributes")
-		}
-
-		totalLength, isNull, n := decodeLengthEncodedInteger(data)
-		if isNull || n == 0 {
-			return nil, errors.New("error decoding total length of connection attributes")
+	if packet.CapabilityFlags&CLIENT_CONNECT_ATTRS != 0 && len(data) > 0 {
+		attributes, n, err := decodeConnectAttributes(data)
+		if err != nil {
+			return nil, err
 		}
+		packet.ConnectAttributes = attributes
 		data = data[n:]
-
-		attributesData := data[:totalLength]
-		data = data[totalLength:]
-
-		packet.ConnectAttributes = make(map[string]string)
-		for len(attributesData) > 0 {
-			keyLength, isNull, n := decodeLengthEncodedInteger(attributesData)
-			if isNull {
-				return nil, errors.New("malformed handshake response packet: null length encoded integer for connection attribute key")
-			}
-			attributesData = attributesData[n:]
-
-			key := string(attributesData[:keyLength])
-			attributesData = attributesData[keyLength:]
-
-			valueLength, isNull, n := decodeLengthEncodedInteger(attributesData)
-			if isNull {
-				return nil, errors.New("malformed handshake response packet: null length encoded integer for connection attribute value")
-			}
-			attributesData = attributesData[n:]
-
-			value := string(attributesData[:valueLength])
-			attributesData = attributesData[valueLength:]
-
-			packet.ConnectAttributes[key] = value
-		}
 	}
 	if len(data) > 0 {
 		if packet.CapabilityFlags&CLIENT_ZSTD_COMPRESSION_ALGORITHM != 0 {
@@ -139,6 +125,35 @@ func decodeHandshakeResponse(data []byte) (*HandshakeResponse, error) {
 	packet.Reserved = len(reservedBytes)
 	return packet, nil
 }
+
+// decodeConnectAttributes decodes the key/value connection attributes, such as
+// _client_name and program_name, which follow their length encoded total
+// length. It returns the attributes along with the bytes read.
+func decodeConnectAttributes(data []byte) (map[string]string, int, error) {
+	totalLength, isNull, n := decodeLengthEncodedInteger(data)
+	if isNull || n == 0 {
+		return nil, 0, errors.New("error decoding total length of connection attributes")
+	}
+	if len(data) < n+totalLength {
+		return nil, 0, errors.New("handshake response packet too short for connection attributes")
+	}
+	attributesData := data[n : n+totalLength]
+
+	attributes := make(map[string]string)
+	for offset := 0; offset < len(attributesData); {
+		key, err := readLengthEncodedString(attributesData, &offset)
+		if err != nil {
+			return nil, 0, errors.New("malformed handshake response packet: invalid connection attribute key")
+		}
+		value, err := readLengthEncodedString(attributesData, &offset)
+		if err != nil {
+			return nil, 0, errors.New("malformed handshake response packet: invalid connection attribute value")
+		}
+		attributes[key] = value
+	}
+	return attributes, n + totalLength, nil
+}
+
 func decodeLengthEncodedInteger(b []byte) (length int, isNull bool, bytesRead int) {
 	if len(b) == 0 {
 		return 0, true, 0
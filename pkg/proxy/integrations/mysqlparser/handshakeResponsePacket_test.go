@@ -0,0 +1,92 @@
+package mysqlparser
+
+import (
+	"encoding/binary"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	yamlLib "gopkg.in/yaml.v3"
+)
+
+// lengthEncodedString returns the string prefixed with its length encoded
+// integer length.
+func lengthEncodedString(s string) []byte {
+	if len(s) < 251 {
+		return append([]byte{byte(len(s))}, s...)
+	}
+	return append(binary.LittleEndian.AppendUint16([]byte{0xfc}, uint16(len(s))), s...)
+}
+
+// handshakeResponse returns the payload of a handshake response of the
+// client sending the connection attributes.
+func handshakeResponse(attributes [][2]string, zstdLevel byte) []byte {
+	capabilities := uint32(0x0200|0x8000) | CLIENT_PLUGIN_AUTH | CLIENT_CONNECT_WITH_DB | CLIENT_CONNECT_ATTRS | CLIENT_ZSTD_COMPRESSION_ALGORITHM
+	payload := binary.LittleEndian.AppendUint32(nil, capabilities)
+	payload = binary.LittleEndian.AppendUint32(payload, 1<<24)
+	payload = append(payload, 0xff)
+	payload = append(payload, make([]byte, 23)...)
+	payload = append(payload, "keploy\x00"...)
+	payload = append(payload, lengthEncodedString("01234567890123456789")...)
+	payload = append(payload, "orders\x00"...)
+	payload = append(payload, "caching_sha2_password\x00"...)
+	var encoded []byte
+	for _, attribute := range attributes {
+		encoded = append(encoded, lengthEncodedString(attribute[0])...)
+		encoded = append(encoded, lengthEncodedString(attribute[1])...)
+	}
+	payload = append(payload, lengthEncodedString(string(encoded))...)
+	return append(payload, zstdLevel)
+}
+
+func TestHandshakeResponseConnectAttributesRoundTrip(t *testing.T) {
+	attributes := [][2]string{
+		{"_client_name", "libmysql"},
+		{"_client_version", "8.0.36"},
+		{"program_name", "orders-service"},
+		// a value longer than 250 bytes has a length encoded length
+		{"_connection_tag", strings.Repeat("x", 300)},
+	}
+	decoded, err := decodeHandshakeResponse(handshakeResponse(attributes, 3))
+	if err != nil {
+		t.Fatalf("failed to decode the handshake response: %v", err)
+	}
+	want := map[string]string{}
+	for _, attribute := range attributes {
+		want[attribute[0]] = attribute[1]
+	}
+	if !reflect.DeepEqual(decoded.ConnectAttributes, want) {
+		t.Errorf("got the connection attributes %v, want %v", decoded.ConnectAttributes, want)
+	}
+	if decoded.Username != "keploy" || decoded.Database != "orders" || decoded.AuthPluginName != "caching_sha2_password" {
+		t.Errorf("got the user %s of the database %s with %s, want keploy of orders with caching_sha2_password", decoded.Username, decoded.Database, decoded.AuthPluginName)
+	}
+	if decoded.ZstdCompressionLevel != 3 {
+		t.Errorf("got the zstd compression level %d following the attributes, want 3", decoded.ZstdCompressionLevel)
+	}
+
+	// the attributes are kept in the recorded mock
+	data, err := yamlLib.Marshal(decoded)
+	if err != nil {
+		t.Fatalf("failed to marshal the handshake response: %v", err)
+	}
+	var recorded models.MySQLHandshakeResponse
+	if err := yamlLib.Unmarshal(data, &recorded); err != nil {
+		t.Fatalf("failed to unmarshal the recorded handshake response: %v", err)
+	}
+	if !reflect.DeepEqual(recorded.ConnectAttributes, want) {
+		t.Errorf("recorded the connection attributes %v, want %v", recorded.ConnectAttributes, want)
+	}
+	if recorded.ZstdCompressionLevel != 3 {
+		t.Errorf("recorded the zstd compression level %d, want 3", recorded.ZstdCompressionLevel)
+	}
+}
+
+func TestHandshakeResponseTruncatedConnectAttributes(t *testing.T) {
+	payload := handshakeResponse([][2]string{{"_client_name", "libmysql"}}, 3)
+	// the attributes are cut short, along with the compression level
+	if _, err := decodeHandshakeResponse(payload[:len(payload)-5]); err == nil {
+		t.Errorf("decoded the truncated connection attributes")
+	}
+}
@@ -65,31 +65,34 @@ func decodeMySQLHandshakeV10(data []byte) (*HandshakeV10Packet, error) {
 
 	packet.CapabilityFlags = uint32(capabilityFlagsLower) | uint32(capabilityFlagsUpper)<<16
 
-	if packet.CapabilityFlags&0x800000 != 0 {
-		if len(data) < 11 { // AuthPluginDataLen (1 byte) + Reserved (10 bytes)
-			return nil, fmt.Errorf("handshake packet too short for AuthPluginDataLen")
-		}
-		authPluginDataLen := int(data[0])
-		data = data[11:] // Skip 1 byte AuthPluginDataLen and 10 bytes reserved
-
-		if authPluginDataLen > 8 {
-			lenToRead := min(authPluginDataLen-8, len(data))
-			authPluginDataBytes = append(authPluginDataBytes, data[:lenToRead]...)
-			data = data[lenToRead:]
-		}
-	} else {
-		data = data[10:] // Skip reserved 10 bytes if CLIENT_PLUGIN_AUTH is not set
+	// the length of the auth-plugin-data (zero without CLIENT_PLUGIN_AUTH)
+	// followed by 10 reserved bytes
+	if len(data) < 11 {
+		return nil, fmt.Errorf("handshake packet too short for AuthPluginDataLen")
 	}
+	authPluginDataLen := int(data[0])
+	data = data[11:]
 
-	if len(data) == 0 {
-		return nil, fmt.Errorf("handshake packet too short for AuthPluginName")
+	// auth-plugin-data-part-2 is at least 13 bytes long
+	lenToRead := 13
+	if packet.CapabilityFlags&CLIENT_PLUGIN_AUTH != 0 && authPluginDataLen-8 > lenToRead {
+		lenToRead = authPluginDataLen - 8
 	}
+	lenToRead = min(lenToRead, len(data))
+	authPluginDataBytes = append(authPluginDataBytes, data[:lenToRead]...)
+	data = data[lenToRead:]
 
-	idx = bytes.IndexByte(data, 0x00)
-	if idx == -1 {
-		return nil, fmt.Errorf("malformed handshake packet: missing null terminator for AuthPluginName")
+	if packet.CapabilityFlags&CLIENT_PLUGIN_AUTH != 0 {
+		if len(data) == 0 {
+			return nil, fmt.Errorf("handshake packet too short for AuthPluginName")
+		}
+		// some servers leave out the null terminator of the last field
+		idx = bytes.IndexByte(data, 0x00)
+		if idx == -1 {
+			idx = len(data)
+		}
+		packet.AuthPluginName = string(data[:idx])
 	}
-	packet.AuthPluginName = string(data[:idx])
 	packet.AuthPluginData = base64.StdEncoding.EncodeToString(authPluginDataBytes)
 	return packet, nil
 }
@@ -135,7 +138,7 @@ func encodeHandshakePacket(packet *models.MySQLHandshakeV10Packet) ([]byte, erro
 	binary.Write(buf, binary.LittleEndian, uint16(packet.CapabilityFlags>>16))
 
 	// Length of auth-plugin-data
-	if packet.CapabilityFlags&0x800000 != 0 && len(AuthPluginDataValue) >= 21 {
+	if packet.CapabilityFlags&CLIENT_PLUGIN_AUTH != 0 {
 		buf.WriteByte(byte(len(AuthPluginDataValue))) // Length of entire auth plugin data
 	} else {
 		buf.WriteByte(0x00)
@@ -144,15 +147,41 @@ func encodeHandshakePacket(packet *models.MySQLHandshakeV10Packet) ([]byte, erro
 	buf.Write(make([]byte, 10))
 
 	// Auth-plugin-data-part-2 (remaining auth data)
-	if packet.CapabilityFlags&0x800000 != 0 && len(AuthPluginDataValue) >= 21 {
+	buf.Write(AuthPluginDataValue[8:])
 
-		buf.Write(AuthPluginDataValue[8:]) // Write all remaining bytes of auth plugin data
-	}
-	// Auth-plugin name
-	if packet.CapabilityFlags&0x800000 != 0 {
+	// Auth-plugin name, replayed as recorded as it decides the auth flow of the client
+	if packet.CapabilityFlags&CLIENT_PLUGIN_AUTH != 0 {
 		buf.WriteString(packet.AuthPluginName)
 		buf.WriteByte(0x00) // Null terminator
 	}
 
 	return buf.Bytes(), nil
 }
+
+// downgradeGreeting returns the recorded greeting advertising only the
+// capabilities also set in mask, so that the clients can be tested against
+// an older server than the one recorded. The recorded mock is left as is,
+// and a zero mask keeps the greeting unchanged.
+func downgradeGreeting(packet interface{}, mask uint32) interface{} {
+	greeting, ok := packet.(*models.MySQLHandshakeV10Packet)
+	if !ok || mask == 0 {
+		return packet
+	}
+	downgraded := *greeting
+	downgraded.CapabilityFlags &= mask
+	return &downgraded
+}
+
+// greetingConnectionID returns the connection id the server assigned in the
+// greeting among the responses, which the clients may log or echo back.
+func greetingConnectionID(responses []models.MySQLResponse) (uint32, bool) {
+	for _, response := range responses {
+		switch greeting := response.Message.(type) {
+		case *HandshakeV10Packet:
+			return greeting.ConnectionID, true
+		case *models.MySQLHandshakeV10Packet:
+			return greeting.ConnectionID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,175 @@
+package mysqlparser
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// decodePayload decodes a packet payload sent over the connection of state.
+func decodePayload(t *testing.T, state *connectionState, sequenceID uint8, payload []byte) (string, interface{}) {
+	t.Helper()
+	packet := MySQLPacket{
+		Header:  MySQLPacketHeader{PayloadLength: uint32(len(payload)), SequenceID: sequenceID},
+		Payload: payload,
+	}
+	packetType, _, decoded, err := DecodeMySQLPacket(packet, zap.NewNop(), nil, state)
+	if err != nil {
+		t.Fatalf("failed to decode the %x packet: %v", payload[:1], err)
+	}
+	return packetType, decoded
+}
+
+func greeting(pluginName string) *models.MySQLHandshakeV10Packet {
+	return &models.MySQLHandshakeV10Packet{
+		ProtocolVersion: 10,
+		ServerVersion:   "8.0.36",
+		ConnectionID:    7,
+		AuthPluginData:  base64.StdEncoding.EncodeToString([]byte("abcdefghijklmnopqrst\x00")),
+		CapabilityFlags: 0x0200 | 0x8000 | CLIENT_PLUGIN_AUTH, // CLIENT_PROTOCOL_41 and CLIENT_SECURE_CONNECTION
+		CharacterSet:    0xff,
+		StatusFlags:     2,
+		AuthPluginName:  pluginName,
+	}
+}
+
+func TestGreetingPluginNameRoundTrips(t *testing.T) {
+	for _, pluginName := range []string{"caching_sha2_password", "mysql_native_password", "sha256_password"} {
+		t.Run(pluginName, func(t *testing.T) {
+			payload, err := encodeHandshakePacket(greeting(pluginName))
+			if err != nil {
+				t.Fatalf("failed to encode the greeting: %v", err)
+			}
+			state := newConnectionState()
+			packetType, decoded := decodePayload(t, state, 0, payload)
+			if packetType != "MySQLHandshakeV10" {
+				t.Fatalf("decoded a %s packet, want the greeting", packetType)
+			}
+			if got := decoded.(*HandshakeV10Packet).AuthPluginName; got != pluginName {
+				t.Fatalf("decoded the plugin %q, want %q", got, pluginName)
+			}
+			if state.pluginName != pluginName {
+				t.Fatalf("connection uses the plugin %q, want %q", state.pluginName, pluginName)
+			}
+		})
+	}
+}
+
+func TestGreetingPluginNameIsPerConnection(t *testing.T) {
+	native, _ := encodeHandshakePacket(greeting("mysql_native_password"))
+	caching, _ := encodeHandshakePacket(greeting("caching_sha2_password"))
+
+	first, second := newConnectionState(), newConnectionState()
+	decodePayload(t, first, 0, native)
+	decodePayload(t, second, 0, caching)
+	if first.pluginName != "mysql_native_password" || second.pluginName != "caching_sha2_password" {
+		t.Fatalf("the connections use the plugins %q and %q", first.pluginName, second.pluginName)
+	}
+
+	// the fast authentication status is only read for the caching_sha2_password connection
+	fastAuth := []byte{models.AuthMoreData, models.CachingSha2PasswordFastAuthSuccess}
+	_, decoded := decodePayload(t, second, 2, fastAuth)
+	if got := decoded.(*HandshakeResponseOk).PluginDetails.Type; got != "cachingSha2PasswordFastAuthSuccess" {
+		t.Fatalf("decoded the auth-more-data of the caching_sha2_password connection as %q", got)
+	}
+	_, decoded = decodePayload(t, first, 2, fastAuth)
+	if got := decoded.(*HandshakeResponseOk).PluginDetails.Type; got != "" {
+		t.Fatalf("decoded the auth-more-data of the mysql_native_password connection as %q", got)
+	}
+}
+
+func TestDowngradeGreeting(t *testing.T) {
+	recorded := greeting("caching_sha2_password")
+	recorded.CapabilityFlags |= uint32(models.CLIENT_SSL) | uint32(models.CLIENT_DEPRECATE_EOF)
+	capabilities := recorded.CapabilityFlags
+	mask := ^(uint32(models.CLIENT_SSL) | uint32(models.CLIENT_DEPRECATE_EOF))
+
+	downgraded, ok := downgradeGreeting(recorded, mask).(*models.MySQLHandshakeV10Packet)
+	if !ok {
+		t.Fatalf("got the downgraded greeting of another type")
+	}
+	if want := capabilities &^ (uint32(models.CLIENT_SSL) | uint32(models.CLIENT_DEPRECATE_EOF)); downgraded.CapabilityFlags != want {
+		t.Errorf("got the capabilities %#x, want %#x", downgraded.CapabilityFlags, want)
+	}
+	if recorded.CapabilityFlags != capabilities {
+		t.Errorf("downgraded the recorded greeting in place to %#x", recorded.CapabilityFlags)
+	}
+
+	// the client reads the downgraded capabilities from the greeting
+	payload, err := encodeHandshakePacket(downgraded)
+	if err != nil {
+		t.Fatalf("failed to encode the greeting: %v", err)
+	}
+	_, decoded := decodePayload(t, newConnectionState(), 0, payload)
+	advertised := decoded.(*HandshakeV10Packet).CapabilityFlags
+	if advertised&uint32(models.CLIENT_SSL) != 0 || advertised&uint32(models.CLIENT_DEPRECATE_EOF) != 0 {
+		t.Errorf("advertised the capabilities %#x, want neither CLIENT_SSL nor CLIENT_DEPRECATE_EOF", advertised)
+	}
+	if advertised&CLIENT_PLUGIN_AUTH == 0 {
+		t.Errorf("advertised the capabilities %#x, want CLIENT_PLUGIN_AUTH kept", advertised)
+	}
+
+	// without a mask, or for another packet, nothing changes
+	if got := downgradeGreeting(recorded, 0); got != interface{}(recorded) {
+		t.Errorf("got a copy of the greeting without a mask")
+	}
+	okPacket := &models.MySQLOKPacket{}
+	if got := downgradeGreeting(okPacket, mask); got != interface{}(okPacket) {
+		t.Errorf("downgraded the OK packet")
+	}
+}
+
+// mockDB keeps the mocks recorded through the hooks.
+type mockDB struct {
+	platform.TestCaseDB
+	mocks []*models.Mock
+}
+
+func (db *mockDB) WriteMock(mock platform.KindSpecifier, ctx context.Context) error {
+	db.mocks = append(db.mocks, mock.(*models.Mock))
+	return nil
+}
+
+func TestGreetingConnectionID(t *testing.T) {
+	payload, err := encodeHandshakePacket(greeting("caching_sha2_password"))
+	if err != nil {
+		t.Fatalf("failed to encode the greeting: %v", err)
+	}
+	_, decoded := decodePayload(t, newConnectionState(), 0, payload)
+	handshake := []models.MySQLResponse{
+		{Header: &models.MySQLPacketHeader{PacketType: "MySQLHandshakeV10"}, Message: decoded},
+		{Header: &models.MySQLPacketHeader{PacketType: "HANDSHAKE_RESPONSE_OK"}, Message: &HandshakeResponseOk{}},
+	}
+	if id, ok := greetingConnectionID(handshake); !ok || id != 7 {
+		t.Errorf("got the connection id %d (%v) of the greeting, want 7", id, ok)
+	}
+	// the greeting read back from a mock
+	if id, ok := greetingConnectionID([]models.MySQLResponse{{Message: greeting("mysql_native_password")}}); !ok || id != 7 {
+		t.Errorf("got the connection id %d (%v) of the recorded greeting, want 7", id, ok)
+	}
+	if _, ok := greetingConnectionID([]models.MySQLResponse{{Message: &models.MySQLOKPacket{}}}); ok {
+		t.Errorf("got a connection id without a greeting")
+	}
+
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	recordMySQLMessage(h, nil, handshake, "MySQLHandshakeV10", "HANDSHAKE_RESPONSE_OK", "config", 0, context.Background())
+	recordMySQLMessage(h, nil, []models.MySQLResponse{{Message: &models.MySQLOKPacket{}}}, "MySQLQuery", "MySQLOK", "mocks", 0, context.Background())
+	if len(db.mocks) != 2 {
+		t.Fatalf("recorded %d mocks, want 2", len(db.mocks))
+	}
+	if got := db.mocks[0].Spec.Metadata["connectionId"]; got != "7" {
+		t.Errorf("recorded the connection id %q of the handshake, want 7", got)
+	}
+	if got, ok := db.mocks[1].Spec.Metadata["connectionId"]; ok {
+		t.Errorf("recorded the connection id %q of the query, want none", got)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"net"
+	"strconv"
 	"time"
 
 	"go.keploy.io/server/pkg/hooks"
@@ -18,6 +19,11 @@ type MySqlParser struct {
 	logger *zap.Logger
 	hooks  *hooks.Hook
 	delay  uint64
+	// greetingCapabilities masks the capabilities of the replayed greeting.
+	greetingCapabilities uint32
+	// okNoise are the fields of the OK packets which differ between the
+	// recording and the replay.
+	okNoise models.MySQLOKNoise
 }
 
 func NewMySqlParser(logger *zap.Logger, hooks *hooks.Hook, delay uint64) *MySqlParser {
@@ -28,6 +34,21 @@ func NewMySqlParser(logger *zap.Logger, hooks *hooks.Hook, delay uint64) *MySqlP
 	}
 }
 
+// SetGreetingCapabilities restricts the capabilities advertised by the
+// replayed server greeting to the ones set in mask, which are intersected
+// with the recorded capabilities. A zero mask replays them unchanged.
+func (sql *MySqlParser) SetGreetingCapabilities(mask uint32) {
+	sql.greetingCapabilities = mask
+}
+
+// SetOKNoise sets the fields of the recorded OK packets generated anew on
+// every run. With LastInsertID set, the requests carrying the last insert id
+// of their connection match the mocks recorded with the id of the recording,
+// whose responses are replayed as recorded.
+func (sql *MySqlParser) SetOKNoise(noise models.MySQLOKNoise) {
+	sql.okNoise = noise
+}
+
 func (sql *MySqlParser) OutgoingType(buffer []byte) bool {
 	//Returning false here because sql parser is using the ports to check if the packet is mysql or not.
 	return false
@@ -38,7 +59,7 @@ func (sql *MySqlParser) ProcessOutgoing(requestBuffer []byte, clientConn, destCo
 	case models.MODE_RECORD:
 		encodeOutgoingMySql(requestBuffer, clientConn, destConn, sql.hooks, sql.logger, ctx)
 	case models.MODE_TEST:
-		decodeOutgoingMySQL(requestBuffer, clientConn, destConn, sql.hooks, sql.logger, ctx, delay)
+		decodeOutgoingMySQL(requestBuffer, clientConn, destConn, sql.hooks, sql.logger, ctx, delay, sql.greetingCapabilities, sql.okNoise)
 	default:
 	}
 }
@@ -46,23 +67,55 @@ func (sql *MySqlParser) ProcessOutgoing(requestBuffer []byte, clientConn, destCo
 var (
 	isConfigRecorded = false
 )
-var (
-	isPluginData = false
-)
-var (
-	expectingAuthSwitchResponse = false
-)
-var (
-	expectingHandshakeResponse = false
-)
+
+// connectionState is what the parser learns over a client connection to
+// decode its later packets. Every connection has its own, dropped when the
+// connection ends, as the statement IDs are assigned by the server per
+// connection.
+type connectionState struct {
+	// paramCounts and queries are the numbers of parameters and the queries
+	// of the prepared statements by statement ID, which COM_STMT_EXECUTE does
+	// not carry itself. preparing is the query of the COM_STMT_PREPARE
+	// waiting for its statement ID.
+	paramCounts map[uint32]uint16
+	queries     map[uint32]string
+	preparing   string
+	// multiStatementsEnabled is set by the COM_SET_OPTION of the client. The
+	// queries then return a result for each of their statements.
+	multiStatementsEnabled bool
+	// lastInsertID is the auto-increment id generated by the last INSERT of
+	// the connection, as told by its OK packet.
+	lastInsertID uint64
+	// lastCommand is the command the next packet of the server answers, as
+	// the responses of several commands start with the same byte.
+	lastCommand byte
+	// pluginName is the auth plugin of the connection, announced by the
+	// greeting and changed by an auth switch request.
+	pluginName string
+	// expectingHandshakeResponse and expectingAuthSwitchResponse are set
+	// while the next packet of the client answers the greeting or an auth
+	// switch request, and isPluginData while the server sends the public key
+	// of the full authentication.
+	expectingHandshakeResponse  bool
+	expectingAuthSwitchResponse bool
+	isPluginData                bool
+}
+
+func newConnectionState() *connectionState {
+	return &connectionState{
+		paramCounts: make(map[uint32]uint16),
+		queries:     make(map[uint32]string),
+	}
+}
 
 func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) {
 	var (
 		mysqlRequests  = []models.MySQLRequest{}
 		mysqlResponses = []models.MySQLResponse{}
 	)
+	state := newConnectionState()
 	for {
-		lastCommand = 0x00 //resetting last command for new loop
+		state.lastCommand = 0x00 //resetting last command for new loop
 		data, source, err := ReadFirstBuffer(clientConn, destConn)
 		if len(data) == 0 {
 			break
@@ -99,8 +152,8 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 				logger.Error("failed to write auth switch request to client", zap.Error(err))
 				return
 			}
-			expectingHandshakeResponse = true
-			oprRequest, requestHeader, mysqlRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(handshakeResponseFromClient), logger, destConn)
+			state.expectingHandshakeResponse = true
+			oprRequest, requestHeader, mysqlRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(handshakeResponseFromClient), logger, destConn, state)
 			if err != nil {
 				logger.Error("failed to decode MySQL packet from client", zap.Error(err))
 				return
@@ -113,8 +166,8 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 				},
 				Message: mysqlRequest,
 			})
-			expectingHandshakeResponse = false
-			oprResponse1, responseHeader1, mysqlResp1, err := DecodeMySQLPacket(bytesToMySQLPacket(handshakeResponseBuffer), logger, destConn)
+			state.expectingHandshakeResponse = false
+			oprResponse1, responseHeader1, mysqlResp1, err := DecodeMySQLPacket(bytesToMySQLPacket(handshakeResponseBuffer), logger, destConn, state)
 			if err != nil {
 				logger.Error("failed to decode MySQL packet from destination", zap.Error(err))
 				return
@@ -127,11 +180,16 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 				},
 				Message: mysqlResp1,
 			})
-			oprResponse2, responseHeader2, mysqlResp2, err := DecodeMySQLPacket(bytesToMySQLPacket(okPacket1), logger, destConn)
+			oprResponse2, responseHeader2, mysqlResp2, err := DecodeMySQLPacket(bytesToMySQLPacket(okPacket1), logger, destConn, state)
 			if err != nil {
 				logger.Error("failed to decode MySQL packet from OK packet", zap.Error(err))
 				return
 			}
+			err = awaitFastAuthOK(mysqlResp2, clientConn, destConn)
+			if err != nil {
+				logger.Error("failed to complete the fast authentication", zap.Error(err))
+				return
+			}
 			mysqlResponses = append(mysqlResponses, models.MySQLResponse{
 				Header: &models.MySQLPacketHeader{
 					PacketLength: responseHeader2.PayloadLength,
@@ -162,9 +220,9 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					logger.Error("failed to write final response to client", zap.Error(err))
 					return
 				}
-				expectingAuthSwitchResponse = true
+				state.expectingAuthSwitchResponse = true
 
-				oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(authSwitchResponse), logger, destConn)
+				oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(authSwitchResponse), logger, destConn, state)
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from client after full authentication", zap.Error(err))
 					return
@@ -177,11 +235,11 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					},
 					Message: mysqlRequestFinal,
 				})
-				expectingAuthSwitchResponse = false
+				state.expectingAuthSwitchResponse = false
 
-				isPluginData = true
-				oprResponse, responseHeader, mysqlResp, err := DecodeMySQLPacket(bytesToMySQLPacket(ServerResponse), logger, destConn)
-				isPluginData = false
+				state.isPluginData = true
+				oprResponse, responseHeader, mysqlResp, err := DecodeMySQLPacket(bytesToMySQLPacket(ServerResponse), logger, destConn, state)
+				state.isPluginData = false
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from destination after full authentication", zap.Error(err))
 					return
@@ -221,7 +279,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 						logger.Error("failed to write final response to client", zap.Error(err))
 						return
 					}
-					oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(clientResponse), logger, destConn)
+					oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(clientResponse), logger, destConn, state)
 					if err != nil {
 						logger.Error("failed to decode MySQL packet from client after full authentication", zap.Error(err))
 						return
@@ -234,9 +292,9 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 						},
 						Message: mysqlRequestFinal,
 					})
-					isPluginData = true
-					oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn)
-					isPluginData = false
+					state.isPluginData = true
+					oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn, state)
+					state.isPluginData = false
 					if err != nil {
 						logger.Error("failed to decode MySQL packet from destination after full authentication", zap.Error(err))
 						return
@@ -269,7 +327,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 						logger.Error("failed to write final response to client", zap.Error(err))
 						return
 					}
-					finalServerResponsetype1, finalServerResponseHeader1, mysqlRespfinalServerResponse, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse1), logger, destConn)
+					finalServerResponsetype1, finalServerResponseHeader1, mysqlRespfinalServerResponse, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse1), logger, destConn, state)
 					if err != nil {
 						logger.Error("failed to decode MySQL packet from final server response", zap.Error(err))
 						return
@@ -312,8 +370,8 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 						logger.Error("failed to write final response to client", zap.Error(err))
 						return
 					}
-					oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn)
-					isPluginData = false
+					oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn, state)
+					state.isPluginData = false
 					if err != nil {
 						logger.Error("failed to decode MySQL packet from destination after full authentication", zap.Error(err))
 						return
@@ -357,7 +415,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					logger.Error("failed to write final response to client", zap.Error(err))
 					return
 				}
-				oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(clientResponse), logger, destConn)
+				oprRequestFinal, requestHeaderFinal, mysqlRequestFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(clientResponse), logger, destConn, state)
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from client after full authentication", zap.Error(err))
 					return
@@ -370,9 +428,9 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					},
 					Message: mysqlRequestFinal,
 				})
-				isPluginData = true
-				oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn)
-				isPluginData = false
+				state.isPluginData = true
+				oprResponseFinal, responseHeaderFinal, mysqlRespFinal, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse), logger, destConn, state)
+				state.isPluginData = false
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from destination after full authentication", zap.Error(err))
 					return
@@ -405,7 +463,7 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					logger.Error("failed to write final response to client", zap.Error(err))
 					return
 				}
-				finalServerResponsetype1, finalServerResponseHeader1, mysqlRespfinalServerResponse, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse1), logger, destConn)
+				finalServerResponsetype1, finalServerResponseHeader1, mysqlRespfinalServerResponse, err := DecodeMySQLPacket(bytesToMySQLPacket(finalServerResponse1), logger, destConn, state)
 				if err != nil {
 					logger.Error("failed to decode MySQL packet from final server response", zap.Error(err))
 					return
@@ -437,12 +495,12 @@ func encodeOutgoingMySql(requestBuffer []byte, clientConn, destConn net.Conn, h
 					},
 				})
 			}
-			recordMySQLMessage(h, mysqlRequests, mysqlResponses, oprRequest, oprResponse2, "config", ctx)
+			recordMySQLMessage(h, mysqlRequests, mysqlResponses, oprRequest, oprResponse2, "config", 0, ctx)
 			mysqlRequests = []models.MySQLRequest{}
 			mysqlResponses = []models.MySQLResponse{}
-			handleClientQueries(h, nil, clientConn, destConn, logger, ctx)
+			handleClientQueries(h, nil, clientConn, destConn, logger, ctx, state)
 		} else if source == "client" {
-			handleClientQueries(h, nil, clientConn, destConn, logger, ctx)
+			handleClientQueries(h, nil, clientConn, destConn, logger, ctx, state)
 		}
 	}
 	return
@@ -452,10 +510,6 @@ var (
 	mockResponseRead = 0
 )
 
-var (
-	expectingHandshakeResponseTest = false
-)
-
 func getfirstSQLMock(configMocks []*models.Mock) (*models.Mock, bool) {
 	for _, mock := range configMocks {
 		if len(mock.Spec.MySqlResponses) > 0 && mock.Kind == "SQL" && mock.Spec.MySqlResponses[0].Header.PacketType == "MySQLHandshakeV10" {
@@ -465,13 +519,17 @@ func getfirstSQLMock(configMocks []*models.Mock) (*models.Mock, bool) {
 	return nil, false
 }
 
-func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context, delay uint64) {
+func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context, delay uint64, greetingCapabilities uint32, okNoise models.MySQLOKNoise) {
 	firstLoop := true
 	doHandshakeAgain := true
 	prevRequest := ""
 	var requestBuffers [][]byte
+	// the replayed greeting and the capabilities negotiated by the client
+	var greeting *models.MySQLHandshakeV10Packet
+	var capabilities uint32
 	configMocks, _ := h.GetConfigMocks()
 	tcsMocks, _ := h.GetTcsMocks()
+	state := newConnectionState()
 	for {
 		//logger.Debug("Config and TCS Mocks", zap.Any("configMocks", configMocks), zap.Any("tcsMocks", tcsMocks))
 		if firstLoop || doHandshakeAgain {
@@ -485,7 +543,7 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 				return
 			}
 			header := sqlMock.Spec.MySqlResponses[0].Header
-			packet := sqlMock.Spec.MySqlResponses[0].Message
+			packet := downgradeGreeting(sqlMock.Spec.MySqlResponses[0].Message, greetingCapabilities)
 			opr := sqlMock.Spec.MySqlResponses[0].Header.PacketType
 
 			binaryPacket, err := encodeToBinary(&packet, header, opr, 0)
@@ -499,6 +557,11 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 				logger.Error("Failed to write binary packet", zap.Error(err))
 				return
 			}
+			// the auth responses are decoded for the plugin of the replayed greeting
+			if replayed, ok := packet.(*models.MySQLHandshakeV10Packet); ok {
+				state.pluginName = replayed.AuthPluginName
+				greeting = replayed
+			}
 			matchedIndex := 0
 			matchedReqIndex := 0
 			configMocks[matchedIndex].Spec.MySqlResponses = append(configMocks[matchedIndex].Spec.MySqlResponses[:matchedReqIndex], configMocks[matchedIndex].Spec.MySqlResponses[matchedReqIndex+1:]...)
@@ -541,10 +604,15 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 				return
 			}
 			if prevRequest == "MYSQLHANDSHAKE" {
-				expectingHandshakeResponseTest = true
+				state.expectingHandshakeResponse = true
+			}
+			// the client answers the replayed auth switch, whose response
+			// may start with any byte or be empty
+			if prevRequest == "AUTH_SWITCH_REQUEST" {
+				state.expectingAuthSwitchResponse = true
 			}
 
-			oprRequest, requestHeader, decodedRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(requestBuffer), logger, destConn)
+			oprRequest, requestHeader, decodedRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(requestBuffer), logger, destConn, state)
 			if err != nil {
 				logger.Error("Failed to decode MySQL packet", zap.Error(err))
 				return
@@ -552,10 +620,13 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 			if oprRequest == "COM_QUIT" {
 				return
 			}
-			if expectingHandshakeResponseTest {
+			if state.expectingHandshakeResponse {
 				// configMocks = configMocks[1:]
 				// h.SetConfigMocks(configMocks)
-				expectingHandshakeResponseTest = false
+				state.expectingHandshakeResponse = false
+			}
+			if response, ok := decodedRequest.(*HandshakeResponse); ok {
+				capabilities = negotiatedCapabilities(greeting, response)
 			}
 
 			prevRequest = ""
@@ -574,13 +645,32 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 			if oprRequest == "COM_STMT_CLOSE" {
 				return
 			}
-			matchedResponse, matchedIndex, _, err := matchRequestWithMock(mysqlRequest, configMocks, tcsMocks, h)
+			// the requests carrying the id generated by the connection match
+			// the mocks recorded with the id of the recording
+			var lastInsertID uint64
+			if okNoise.LastInsertID {
+				lastInsertID = state.lastInsertID
+			}
+			matchedResponse, matchedIndex, _, err := matchRequestWithMock(mysqlRequest, configMocks, tcsMocks, h, lastInsertID)
 			if err != nil {
 				logger.Error("Failed to match request with mock", zap.Error(err))
 				return
 			}
 			if matchedIndex != -1 {
-				responseBinary, err := encodeToBinary(&matchedResponse.Message, matchedResponse.Header, matchedResponse.Header.PacketType, 1)
+				h.RecordRoundTrip(models.SQL)
+				// the parameters of the following executions are decoded
+				// with the count of the replayed preparation
+				if prepareOk, ok := matchedResponse.Message.(*models.MySQLStmtPrepareOk); ok {
+					state.rememberPreparedStatement(prepareOk.StatementID, prepareOk.NumParams)
+				}
+				state.noteOK(matchedResponse.Message)
+				// the result sets end as the client negotiated, whatever the
+				// recorded client did
+				message := matchedResponse.Message
+				if resultSet, ok := message.(*models.MySQLResultSet); ok && capabilities != 0 {
+					message = adaptResultSet(resultSet, capabilities)
+				}
+				responseBinary, err := encodeToBinary(&message, matchedResponse.Header, matchedResponse.Header.PacketType, 1)
 				logger.Debug("Response binary",
 					zap.ByteString("responseBinary", responseBinary),
 					zap.String("packetType", matchedResponse.Header.PacketType))
@@ -589,18 +679,32 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 					logger.Error("Failed to encode response to binary", zap.Error(err))
 					return
 				}
+				// the response continues the sequence of the request, which
+				// is reset to zero at every command
+				responseBinary = resequencePackets(responseBinary, requestHeader.SequenceID+1)
 
 				_, err = clientConn.Write(responseBinary)
 				if err != nil {
 					logger.Error("Failed to write response to clientConn", zap.Error(err))
 					return
 				}
+				if matchedResponse.Header.PacketType == "AUTH_SWITCH_REQUEST" {
+					prevRequest = "AUTH_SWITCH_REQUEST"
+				}
 
 			} else {
+				h.RecordDivergence()
 				responseBuffer, err := util.Passthrough(clientConn, destConn, requestBuffers, h.Recover, logger)
 				if err != nil {
 					return
 				}
+				// the server generates ids of its own for the passed through
+				// INSERTs
+				if (oprRequest == "MySQLQuery" || oprRequest == "COM_STMT_EXECUTE") && len(responseBuffer) > 4 && responseBuffer[4] == 0x00 {
+					if okPacket, err := decodeMySQLOK(responseBuffer[4:]); err == nil {
+						state.noteOK(okPacket)
+					}
+				}
 				_, err = clientConn.Write(responseBuffer)
 				if err != nil {
 					logger.Error("Failed to write response to clientConn", zap.Error(err))
@@ -611,7 +715,10 @@ func decodeOutgoingMySQL(requestBuffer []byte, clientConn, destConn net.Conn, h
 	}
 }
 
-func matchRequestWithMock(mysqlRequest models.MySQLRequest, configMocks, tcsMocks []*models.Mock, h *hooks.Hook) (*models.MySQLResponse, int, string, error) {
+// matchRequestWithMock finds the mock of the request and consumes it. A
+// non-zero lastInsertID is the id generated last over the connection, which
+// the request is compared with as the id recorded with the mocks instead.
+func matchRequestWithMock(mysqlRequest models.MySQLRequest, configMocks, tcsMocks []*models.Mock, h *hooks.Hook, lastInsertID uint64) (*models.MySQLResponse, int, string, error) {
 	allMocks := append([]*models.Mock(nil), configMocks...)
 	allMocks = append(allMocks, tcsMocks...)
 	var bestMatch *models.MySQLResponse
@@ -620,9 +727,11 @@ func matchRequestWithMock(mysqlRequest models.MySQLRequest, configMocks, tcsMock
 	var mockType string
 	maxMatchCount := 0
 
+	substitution := newInsertIDSubstitution(mysqlRequest, lastInsertID)
 	for i, mock := range allMocks {
 		for j, mockReq := range mock.Spec.MySqlRequests {
-			matchCount := compareMySQLRequests(mysqlRequest, mockReq)
+			request := substitution.apply(mysqlRequest, mockReq, mock.Spec.Metadata["lastInsertId"])
+			matchCount := compareMySQLRequests(request, mockReq)
 			if matchCount > maxMatchCount {
 				maxMatchCount = matchCount
 				matchedIndex = i
@@ -692,6 +801,53 @@ func compareMySQLRequests(req1, req2 models.MySQLRequest) int {
 			matchCount += 5
 		}
 	}
+	if req1.Header.PacketType == "COM_STMT_EXECUTE" && req2.Header.PacketType == "COM_STMT_EXECUTE" {
+		packet, ok := req1.Message.(ComStmtExecute)
+		if !ok {
+			return 0
+		}
+		recorded, ok := req2.Message.(*models.MySQLComStmtExecute)
+		if !ok {
+			return 0
+		}
+		// the statement IDs are assigned by the server per connection, so
+		// the executions are matched by the query of their statement when
+		// its preparation has been seen
+		sameStatement := packet.StatementID == recorded.StatementID
+		if packet.Query != "" && recorded.Query != "" {
+			sameStatement = packet.Query == recorded.Query
+		}
+		if sameStatement && stmtExecuteParametersEqual(packet.Parameters, recorded.Parameters) {
+			matchCount += 5
+		}
+	}
+	if req1.Header.PacketType == "COM_SET_OPTION" && req2.Header.PacketType == "COM_SET_OPTION" {
+		packet, ok := req1.Message.(*ComSetOptionPacket)
+		if !ok {
+			return 0
+		}
+		recorded, ok := req2.Message.(*models.MySQLComSetOptionPacket)
+		if !ok {
+			return 0
+		}
+		if packet.Option == recorded.Option {
+			matchCount += 5
+		}
+	}
+	if req1.Header.PacketType == "HANDSHAKE_RESPONSE" && req2.Header.PacketType == "HANDSHAKE_RESPONSE" {
+		packet, ok := req1.Message.(*HandshakeResponse)
+		if !ok {
+			return 0
+		}
+		recorded, ok := req2.Message.(*models.MySQLHandshakeResponse)
+		if !ok {
+			return 0
+		}
+		// the plugin decides the auth flow replayed for the client
+		if packet.AuthPluginName == recorded.AuthPluginName {
+			matchCount += 5
+		}
+	}
 	if req1.Header.PacketLength == req2.Header.PacketLength {
 		matchCount++
 	}
@@ -723,7 +879,7 @@ func ReadFirstBuffer(clientConn, destConn net.Conn) ([]byte, string, error) {
 	// Return any other error from reading destConn
 	return nil, "", err
 }
-func handleClientQueries(h *hooks.Hook, initialBuffer []byte, clientConn, destConn net.Conn, logger *zap.Logger, ctx context.Context) ([]*models.Mock, error) {
+func handleClientQueries(h *hooks.Hook, initialBuffer []byte, clientConn, destConn net.Conn, logger *zap.Logger, ctx context.Context, state *connectionState) ([]*models.Mock, error) {
 	firstIteration := true
 	var (
 		mysqlRequests  []models.MySQLRequest
@@ -747,7 +903,7 @@ func handleClientQueries(h *hooks.Hook, initialBuffer []byte, clientConn, destCo
 		if len(queryBuffer) == 0 {
 			break
 		}
-		operation, requestHeader, mysqlRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(queryBuffer), logger, destConn)
+		operation, requestHeader, mysqlRequest, err := DecodeMySQLPacket(bytesToMySQLPacket(queryBuffer), logger, destConn, state)
 		mysqlRequests = append([]models.MySQLRequest{}, models.MySQLRequest{
 			Header: &models.MySQLPacketHeader{
 				PacketLength: requestHeader.PayloadLength,
@@ -777,7 +933,7 @@ func handleClientQueries(h *hooks.Hook, initialBuffer []byte, clientConn, destCo
 		if len(queryResponse) == 0 {
 			break
 		}
-		responseOperation, responseHeader, mysqlResp, err := DecodeMySQLPacket(bytesToMySQLPacket(queryResponse), logger, destConn)
+		responseOperation, responseHeader, mysqlResp, err := DecodeMySQLPacket(bytesToMySQLPacket(queryResponse), logger, destConn, state)
 		if err != nil {
 			logger.Error("Failed to decode the MySQL packet from the destination server", zap.Error(err))
 			continue
@@ -793,11 +949,12 @@ func handleClientQueries(h *hooks.Hook, initialBuffer []byte, clientConn, destCo
 			},
 			Message: mysqlResp,
 		})
-		recordMySQLMessage(h, mysqlRequests, mysqlResponses, operation, responseOperation, "mocks", ctx)
+		recordMySQLMessage(h, mysqlRequests, mysqlResponses, operation, responseOperation, "mocks", state.lastInsertID, ctx)
+		state.noteOK(mysqlResp)
 	}
 	return nil, nil
 }
-func recordMySQLMessage(h *hooks.Hook, mysqlRequests []models.MySQLRequest, mysqlResponses []models.MySQLResponse, operation string, responseOperation string, name string, ctx context.Context) {
+func recordMySQLMessage(h *hooks.Hook, mysqlRequests []models.MySQLRequest, mysqlResponses []models.MySQLResponse, operation string, responseOperation string, name string, lastInsertID uint64, ctx context.Context) {
 	shouldRecordCalls := true
 	if shouldRecordCalls {
 		meta := map[string]string{
@@ -805,6 +962,15 @@ func recordMySQLMessage(h *hooks.Hook, mysqlRequests []models.MySQLRequest, mysq
 			"operation":         operation,
 			"responseOperation": responseOperation,
 		}
+		// the greeting, and the connection id in it, is replayed as recorded
+		if connectionID, ok := greetingConnectionID(mysqlResponses); ok {
+			meta[models.ConnectionIDMetadata] = strconv.FormatUint(uint64(connectionID), 10)
+		}
+		// the id generated last over the connection, which the request may
+		// carry, is told apart from the ids generated during the replay
+		if lastInsertID != 0 {
+			meta["lastInsertId"] = strconv.FormatUint(lastInsertID, 10)
+		}
 		mysqlMock := &models.Mock{
 			Version: models.GetVersion(),
 			Kind:    models.SQL,
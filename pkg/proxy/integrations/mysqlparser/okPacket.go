@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"go.keploy.io/server/pkg/models"
 )
@@ -53,6 +55,189 @@ func decodeMySQLOK(data []byte) (*OKPacket, error) {
 	return packet, nil
 }
 
+// noteOK keeps the last insert id of an OK packet of the connection. The
+// statements which do not insert a row leave it at zero, while the id stays
+// the last one generated.
+func (state *connectionState) noteOK(packet interface{}) {
+	var lastInsertID uint64
+	switch p := packet.(type) {
+	case *OKPacket:
+		if p != nil {
+			lastInsertID = p.LastInsertID
+		}
+	case *models.MySQLOKPacket:
+		if p != nil {
+			lastInsertID = p.LastInsertID
+		}
+	}
+	if lastInsertID != 0 {
+		state.lastInsertID = lastInsertID
+	}
+}
+
+// insertIDSubstitution puts the id recorded with a mock in place of the last
+// insert id generated over the connection, at the positions of the live
+// request which carry the recorded id in the recorded request. The other
+// numbers equal to the id, such as a LIMIT, are left as they are.
+type insertIDSubstitution struct {
+	live string
+	// literals are the spans of the integer literals of the live query.
+	literals [][2]int
+}
+
+// newInsertIDSubstitution prepares the substitution of the id live in the
+// request, which is compared with every recorded request. It returns nil
+// when the id is unknown.
+func newInsertIDSubstitution(request models.MySQLRequest, live uint64) *insertIDSubstitution {
+	if live == 0 {
+		return nil
+	}
+	substitution := &insertIDSubstitution{live: strconv.FormatUint(live, 10)}
+	if packet, ok := request.Message.(*QueryPacket); ok && packet != nil {
+		substitution.literals = integerLiterals(packet.Query)
+	}
+	return substitution
+}
+
+// apply returns the request to compare with a request recorded after the id
+// recordedID was generated. The request is returned as is when the ids are
+// the same, or when the recorded request does not carry the id where the
+// live one carries its own.
+func (s *insertIDSubstitution) apply(request, recorded models.MySQLRequest, recordedID string) models.MySQLRequest {
+	if s == nil || recordedID == "" || recordedID == s.live {
+		return request
+	}
+	switch packet := request.Message.(type) {
+	case *QueryPacket:
+		mock, ok := recorded.Message.(*models.MySQLQueryPacket)
+		if !ok || packet == nil {
+			return request
+		}
+		recordedLiterals := integerLiterals(mock.Query)
+		var query strings.Builder
+		substituted, last := false, 0
+		for k, span := range s.literals {
+			if k >= len(recordedLiterals) {
+				break
+			}
+			if packet.Query[span[0]:span[1]] != s.live || mock.Query[recordedLiterals[k][0]:recordedLiterals[k][1]] != recordedID {
+				continue
+			}
+			query.WriteString(packet.Query[last:span[0]])
+			query.WriteString(recordedID)
+			substituted, last = true, span[1]
+		}
+		if !substituted {
+			return request
+		}
+		query.WriteString(packet.Query[last:])
+		replaced := *packet
+		replaced.Query = query.String()
+		request.Message = &replaced
+	case ComStmtExecute:
+		mock, ok := recorded.Message.(*models.MySQLComStmtExecute)
+		if !ok || len(mock.Parameters) != len(packet.Parameters) {
+			return request
+		}
+		id, err := strconv.ParseUint(recordedID, 10, 64)
+		if err != nil {
+			return request
+		}
+		var parameters []BoundParameter
+		for i, param := range packet.Parameters {
+			if param.Null || param.Text != s.live || mock.Parameters[i].Text != recordedID {
+				continue
+			}
+			value, ok := integerValue(models.FieldType(param.Type), id)
+			if !ok {
+				continue
+			}
+			if parameters == nil {
+				parameters = append([]BoundParameter(nil), packet.Parameters...)
+			}
+			parameters[i].Value = value
+			parameters[i].Text = recordedID
+		}
+		if parameters == nil {
+			return request
+		}
+		packet.Parameters = parameters
+		request.Message = packet
+	}
+	return request
+}
+
+// integerLiterals returns the spans of the integer literals of a query, in
+// order. The digits of the quoted strings and identifiers, and of the
+// decimal numbers, are left out.
+func integerLiterals(query string) [][2]int {
+	var spans [][2]int
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			i++
+			for i < len(query) {
+				if query[i] == '\\' {
+					i += 2
+					continue
+				}
+				if query[i] == c {
+					// a doubled quote is part of the string
+					if i+1 < len(query) && query[i+1] == c {
+						i += 2
+						continue
+					}
+					break
+				}
+				i++
+			}
+			i++
+		case isIdentifierByte(c):
+			start, digits := i, true
+			for i < len(query) && isIdentifierByte(query[i]) {
+				digits = digits && query[i] >= '0' && query[i] <= '9'
+				i++
+			}
+			decimal := (start > 0 && query[start-1] == '.') || (i < len(query) && query[i] == '.')
+			if digits && !decimal {
+				spans = append(spans, [2]int{start, i})
+			}
+		default:
+			i++
+		}
+	}
+	return spans
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// integerValue encodes v as a value of the binary protocol of an integer type.
+// It fails for the other types and when v does not fit in the type.
+func integerValue(fieldType models.FieldType, v uint64) ([]byte, bool) {
+	var size int
+	switch fieldType {
+	case models.FieldTypeTiny:
+		size = 1
+	case models.FieldTypeShort, models.FieldTypeYear:
+		size = 2
+	case models.FieldTypeLong, models.FieldTypeInt24:
+		size = 4
+	case models.FieldTypeLongLong:
+		size = 8
+	default:
+		return nil, false
+	}
+	if size < 8 && v >= 1<<(8*uint(size)) {
+		return nil, false
+	}
+	value := make([]byte, 8)
+	binary.LittleEndian.PutUint64(value, v)
+	return value[:size], true
+}
+
 func encodeMySQLOK(packet *models.MySQLOKPacket, header *models.MySQLPacketHeader) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	// payload (without the header)
@@ -0,0 +1,209 @@
+package mysqlparser
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// recordedMock is a mock recorded after an INSERT of the connection generated
+// the id 41. It replays an OK packet with the id inserted by the request.
+func recordedMock(request models.MySQLRequest, insertedID uint64) *models.Mock {
+	return &models.Mock{
+		Kind: models.SQL,
+		Spec: models.MockSpec{
+			Metadata: map[string]string{
+				"type":         "mocks",
+				"lastInsertId": "41",
+			},
+			MySqlRequests: []models.MySQLRequest{request},
+			MySqlResponses: []models.MySQLResponse{{
+				Header:  &models.MySQLPacketHeader{PacketType: "MySQLOK"},
+				Message: &models.MySQLOKPacket{AffectedRows: 1, LastInsertID: insertedID},
+			}},
+		},
+	}
+}
+
+func longLong(v uint64) []byte {
+	value := make([]byte, 8)
+	binary.LittleEndian.PutUint64(value, v)
+	return value
+}
+
+func TestMatchRequestWithMockLastInsertIDNoise(t *testing.T) {
+	tests := []struct {
+		name string
+		// other inserts an item of another order, recorded over the same
+		// connection as the one carrying the id 41 of the recording
+		other    models.MySQLRequest
+		recorded models.MySQLRequest
+		live     models.MySQLRequest
+	}{
+		{
+			name: "query",
+			other: models.MySQLRequest{
+				Header:  &models.MySQLPacketHeader{PacketType: "MySQLQuery"},
+				Message: &models.MySQLQueryPacket{Query: "INSERT INTO items (order_id, sku) VALUES (40, 'sku-410')"},
+			},
+			recorded: models.MySQLRequest{
+				Header:  &models.MySQLPacketHeader{PacketType: "MySQLQuery"},
+				Message: &models.MySQLQueryPacket{Query: "INSERT INTO items (order_id, sku) VALUES (41, 'sku-410')"},
+			},
+			live: models.MySQLRequest{
+				Header:  &models.MySQLPacketHeader{PacketType: "MySQLQuery"},
+				Message: &QueryPacket{Query: "INSERT INTO items (order_id, sku) VALUES (57, 'sku-410')"},
+			},
+		},
+		{
+			name: "execute",
+			other: models.MySQLRequest{
+				Header: &models.MySQLPacketHeader{PacketType: "COM_STMT_EXECUTE"},
+				Message: &models.MySQLComStmtExecute{
+					Query: "INSERT INTO items (order_id, sku) VALUES (?, ?)",
+					Parameters: []models.BoundParameter{
+						{Type: byte(models.FieldTypeLongLong), Value: longLong(40), Text: "40"},
+						{Type: byte(models.FieldTypeVarString), Value: []byte("\x07sku-410"), Text: "sku-410"},
+					},
+				},
+			},
+			recorded: models.MySQLRequest{
+				Header: &models.MySQLPacketHeader{PacketType: "COM_STMT_EXECUTE"},
+				Message: &models.MySQLComStmtExecute{
+					Query: "INSERT INTO items (order_id, sku) VALUES (?, ?)",
+					Parameters: []models.BoundParameter{
+						{Type: byte(models.FieldTypeLongLong), Value: longLong(41), Text: "41"},
+						{Type: byte(models.FieldTypeVarString), Value: []byte("\x07sku-410"), Text: "sku-410"},
+					},
+				},
+			},
+			live: models.MySQLRequest{
+				Header: &models.MySQLPacketHeader{PacketType: "COM_STMT_EXECUTE"},
+				Message: ComStmtExecute{
+					Query: "INSERT INTO items (order_id, sku) VALUES (?, ?)",
+					Parameters: []BoundParameter{
+						{Type: byte(models.FieldTypeLongLong), Value: longLong(57), Text: "57"},
+						{Type: byte(models.FieldTypeVarString), Value: []byte("\x07sku-410"), Text: "sku-410"},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the INSERT of the replay was passed through, and the server
+			// generated another id than during the recording
+			state := newConnectionState()
+			state.noteOK(&OKPacket{AffectedRows: 1, LastInsertID: 57})
+
+			mocks := func() []*models.Mock {
+				return []*models.Mock{recordedMock(tt.other, 40), recordedMock(tt.recorded, 41)}
+			}
+
+			_, matchedIndex, _, _ := matchRequestWithMock(tt.live, nil, mocks(), nil, 0)
+			if matchedIndex == 1 {
+				t.Fatalf("request carrying the id 57 matched the mock recorded with 41 without the noise")
+			}
+
+			response, matchedIndex, _, err := matchRequestWithMock(tt.live, nil, mocks(), nil, state.lastInsertID)
+			if err != nil || matchedIndex != 1 {
+				t.Fatalf("request carrying the id 57 matched mock %d instead of the one recorded with 41: %v", matchedIndex, err)
+			}
+			ok, isOK := response.Message.(*models.MySQLOKPacket)
+			if !isOK || ok.LastInsertID != 41 || ok.AffectedRows != 1 {
+				t.Fatalf("replayed %+v, want the recorded OK packet", response.Message)
+			}
+		})
+	}
+}
+
+func TestInsertIDSubstitutionOnlyReplacesTheRecordedPositions(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     string
+		recorded string
+		want     string
+	}{
+		{
+			name:     "limit equal to the id",
+			live:     "SELECT * FROM items WHERE order_id = 57 LIMIT 57",
+			recorded: "SELECT * FROM items WHERE order_id = 41 LIMIT 57",
+			want:     "SELECT * FROM items WHERE order_id = 41 LIMIT 57",
+		},
+		{
+			name:     "column value equal to the id",
+			live:     "INSERT INTO items (order_id, quantity, sku) VALUES (57, 57, 'sku-57')",
+			recorded: "INSERT INTO items (order_id, quantity, sku) VALUES (41, 57, 'sku-57')",
+			want:     "INSERT INTO items (order_id, quantity, sku) VALUES (41, 57, 'sku-57')",
+		},
+		{
+			name:     "strings, identifiers and decimals",
+			live:     "SELECT t57.price FROM t57 WHERE note = 'it''s 57' AND price > 57.57 AND id = 57",
+			recorded: "SELECT t57.price FROM t57 WHERE note = 'it''s 57' AND price > 57.57 AND id = 41",
+			want:     "SELECT t57.price FROM t57 WHERE note = 'it''s 57' AND price > 57.57 AND id = 41",
+		},
+		{
+			name:     "recorded without the id",
+			live:     "SELECT * FROM items WHERE order_id = 57",
+			recorded: "SELECT * FROM items WHERE order_id = 40",
+			want:     "SELECT * FROM items WHERE order_id = 57",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			live := models.MySQLRequest{
+				Header:  &models.MySQLPacketHeader{PacketType: "MySQLQuery"},
+				Message: &QueryPacket{Query: tt.live},
+			}
+			recorded := models.MySQLRequest{
+				Header:  &models.MySQLPacketHeader{PacketType: "MySQLQuery"},
+				Message: &models.MySQLQueryPacket{Query: tt.recorded},
+			}
+			request := newInsertIDSubstitution(live, 57).apply(live, recorded, "41")
+			if got := request.Message.(*QueryPacket).Query; got != tt.want {
+				t.Fatalf("compared %q, want %q", got, tt.want)
+			}
+			if got := live.Message.(*QueryPacket).Query; got != tt.live {
+				t.Fatalf("live query modified to %q", got)
+			}
+		})
+	}
+}
+
+func TestInsertIDSubstitutionOnlyReplacesTheRecordedParameters(t *testing.T) {
+	live := models.MySQLRequest{
+		Header: &models.MySQLPacketHeader{PacketType: "COM_STMT_EXECUTE"},
+		Message: ComStmtExecute{
+			Query: "INSERT INTO items (order_id, quantity) VALUES (?, ?)",
+			Parameters: []BoundParameter{
+				{Type: byte(models.FieldTypeLongLong), Value: longLong(57), Text: "57"},
+				{Type: byte(models.FieldTypeLongLong), Value: longLong(57), Text: "57"},
+			},
+		},
+	}
+	recorded := models.MySQLRequest{
+		Header: &models.MySQLPacketHeader{PacketType: "COM_STMT_EXECUTE"},
+		Message: &models.MySQLComStmtExecute{
+			Query: "INSERT INTO items (order_id, quantity) VALUES (?, ?)",
+			Parameters: []models.BoundParameter{
+				{Type: byte(models.FieldTypeLongLong), Value: longLong(41), Text: "41"},
+				{Type: byte(models.FieldTypeLongLong), Value: longLong(57), Text: "57"},
+			},
+		},
+	}
+	request := newInsertIDSubstitution(live, 57).apply(live, recorded, "41")
+	parameters := request.Message.(ComStmtExecute).Parameters
+	if parameters[0].Text != "41" || binary.LittleEndian.Uint64(parameters[0].Value) != 41 {
+		t.Fatalf("compared the order id %+v, want the recorded 41", parameters[0])
+	}
+	if parameters[1].Text != "57" || binary.LittleEndian.Uint64(parameters[1].Value) != 57 {
+		t.Fatalf("compared the quantity %+v, want the live 57", parameters[1])
+	}
+	if compareMySQLRequests(request, recorded) < 5 {
+		t.Fatalf("substituted execution does not match the recorded one")
+	}
+	if got := live.Message.(ComStmtExecute).Parameters[0].Text; got != "57" {
+		t.Fatalf("live parameter modified to %q", got)
+	}
+}
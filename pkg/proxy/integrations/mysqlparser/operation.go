@@ -56,8 +56,6 @@ type PluginDetails struct {
 
 type CapabilityFlags uint32
 
-var handshakePluginName string
-
 func encodeToBinary(packet interface{}, header *models.MySQLPacketHeader, operation string, sequence int) ([]byte, error) {
 	var data []byte
 	var err error
@@ -107,6 +105,12 @@ func encodeToBinary(packet interface{}, header *models.MySQLPacketHeader, operat
 		}
 		data, err = encodeStmtPrepareOk(p)
 		bypassHeader = true
+	case "MySQLEOF":
+		p, ok := packet.(*models.MySQLEOFPacket)
+		if !ok {
+			return nil, fmt.Errorf("invalid packet type for EOF: expected *MySQLEOFPacket, got %T", packet)
+		}
+		data, err = encodeMySQLEOF(p)
 	case "RESULT_SET_PACKET":
 		p, ok := packet.(*models.MySQLResultSet)
 		if !ok {
@@ -131,134 +135,187 @@ func encodeToBinary(packet interface{}, header *models.MySQLPacketHeader, operat
 	}
 }
 
-func DecodeMySQLPacket(packet MySQLPacket, logger *zap.Logger, destConn net.Conn) (string, MySQLPacketHeader, interface{}, error) {
+func DecodeMySQLPacket(packet MySQLPacket, logger *zap.Logger, destConn net.Conn, state *connectionState) (string, MySQLPacketHeader, interface{}, error) {
 	data := packet.Payload
 	header := packet.Header
 	var packetData interface{}
 	var packetType string
 	var err error
 
-	if len(data) < 1 {
+	// the clients answer an auth switch with an empty response when they
+	// have no password
+	if len(data) < 1 && !state.expectingAuthSwitchResponse {
 		return "", MySQLPacketHeader{}, nil, fmt.Errorf("Invalid packet: Payload is empty")
 	}
 
 	switch {
-	case lastCommand == 0x03:
+	case state.expectingAuthSwitchResponse:
+		packetType = "AUTH_SWITCH_RESPONSE"
+		packetData, err = decodeAuthSwitchResponse(data)
+		state.expectingAuthSwitchResponse = false
+	case state.lastCommand == 0x03:
 		switch {
 		case data[0] == 0x00: // OK Packet
 			packetType = "MySQLOK"
-			packetData, err = decodeMySQLOK(data)
-			lastCommand = 0x00 // Reset the last command
+			var okPacket *OKPacket
+			okPacket, err = decodeMySQLOK(data)
+			packetData = okPacket
+			// the results of the next statements of a multi-statement query follow
+			if !state.multiStatementsEnabled || err != nil || okPacket.StatusFlags&SERVER_MORE_RESULTS_EXISTS == 0 {
+				state.lastCommand = 0x00 // Reset the last command
+			}
 
 		case data[0] == 0xFF: // Error Packet
 			packetType = "MySQLErr"
 			packetData, err = decodeMySQLErr(data)
-			lastCommand = 0x00 // Reset the last command
+			state.lastCommand = 0x00 // Reset the last command
 
 		case isLengthEncodedInteger(data[0]): // ResultSet Packet
 			packetType = "RESULT_SET_PACKET"
-			packetData, err = parseResultSet(data)
-			lastCommand = 0x00 // Reset the last command
+			packetData, err = decodeResultSet(data, false)
+			state.lastCommand = 0x00 // Reset the last command
 
 		default:
 			packetType = "Unknown"
 			packetData = data
 			logger.Debug("unknown packet type after COM_QUERY", zap.Int("unknownPacketTypeInt", int(data[0])))
 		}
+	case state.lastCommand == 0x17 && isLengthEncodedInteger(data[0]) && data[0] != 0xFE: // COM_STMT_EXECUTE result set
+		packetType = "RESULT_SET_PACKET"
+		packetData, err = decodeResultSet(data, true)
+		state.lastCommand = 0x00 // Reset the last command
+	case state.lastCommand == 0x1b && (data[0] == 0xFE || data[0] == 0x00 || data[0] == 0xFF): // COM_SET_OPTION response
+		switch {
+		case data[0] == 0xFE: // EOF Packet
+			packetType = "MySQLEOF"
+			packetData, err = decodeMYSQLEOF(data)
+		case data[0] == 0x00: // OK Packet
+			packetType = "MySQLOK"
+			packetData, err = decodeMySQLOK(data)
+		default: // Error Packet
+			packetType = "MySQLErr"
+			packetData, err = decodeMySQLErr(data)
+		}
+		state.lastCommand = 0x00 // Reset the last command
+	case data[0] == 0x1b: // COM_SET_OPTION
+		packetType = "COM_SET_OPTION"
+		packetData, err = decodeComSetOption(data, state)
+		state.lastCommand = 0x1b
 	case data[0] == 0x0e: // COM_PING
 		packetType = "COM_PING"
 		packetData, err = decodeComPing(data)
-		lastCommand = 0x0e
+		state.lastCommand = 0x0e
 	case data[0] == 0x17: // COM_STMT_EXECUTE
 		packetType = "COM_STMT_EXECUTE"
-		packetData, err = decodeComStmtExecute(data)
-		lastCommand = 0x17
+		packetData, err = decodeComStmtExecute(data, state)
+		state.lastCommand = 0x17
 	case data[0] == 0x1c: // COM_STMT_FETCH
 		packetType = "COM_STMT_FETCH"
 		packetData, err = decodeComStmtFetch(data)
-		lastCommand = 0x1c
+		state.lastCommand = 0x1c
 	case data[0] == 0x16: // COM_STMT_PREPARE
 		packetType = "COM_STMT_PREPARE"
-		packetData, err = decodeComStmtPrepare(data)
-		lastCommand = 0x16
+		var prepare *ComStmtPreparePacket
+		prepare, err = decodeComStmtPrepare(data)
+		if err == nil {
+			state.notePreparedQuery(prepare.Query)
+		}
+		packetData = prepare
+		state.lastCommand = 0x16
 	case data[0] == 0x19: // COM_STMT_CLOSE
 		if len(data) > 11 {
 
 			packetType = "COM_STMT_CLOSE_WITH_PREPARE"
-			packetData, err = decodeComStmtCloseMoreData(data)
-			lastCommand = 0x16
+			var closeAndPrepare *ComStmtCloseAndPrepare
+			closeAndPrepare, err = decodeComStmtCloseMoreData(data)
+			if err == nil {
+				state.closePreparedStatement(closeAndPrepare.StmtClose.StatementID)
+				state.notePreparedQuery(closeAndPrepare.StmtPrepare.Query)
+			}
+			packetData = closeAndPrepare
+			state.lastCommand = 0x16
 		} else {
 			packetType = "COM_STMT_CLOSE"
-			packetData, err = decodeComStmtClose(data)
-			lastCommand = 0x19
+			var stmtClose *ComStmtClosePacket
+			stmtClose, err = decodeComStmtClose(data)
+			if err == nil {
+				state.closePreparedStatement(stmtClose.StatementID)
+			}
+			packetData = stmtClose
+			state.lastCommand = 0x19
 		}
 	case data[0] == 0x11: // COM_CHANGE_USER
 		packetType = "COM_CHANGE_USER"
 		packetData, err = decodeComChangeUser(data)
-		lastCommand = 0x11
+		state.lastCommand = 0x11
 
 	case data[0] == 0x04: // Result Set Packet
 		packetType = "RESULT_SET_PACKET"
-		packetData, err = parseResultSet(data)
-		lastCommand = 0x04
+		packetData, err = decodeResultSet(data, false)
+		state.lastCommand = 0x04
 	case data[0] == 0x0A: // MySQLHandshakeV10
 		packetType = "MySQLHandshakeV10"
 		packetData, err = decodeMySQLHandshakeV10(data)
 		handshakePacket, _ := packetData.(*HandshakeV10Packet)
-		handshakePluginName = handshakePacket.AuthPluginName
-		lastCommand = 0x0A
+		state.pluginName = handshakePacket.AuthPluginName
+		state.lastCommand = 0x0A
 	case data[0] == 0x03: // MySQLQuery
 		packetType = "MySQLQuery"
 		packetData, err = decodeMySQLQuery(data)
-		lastCommand = 0x03
+		state.lastCommand = 0x03
 	case data[0] == 0x00: // MySQLOK or COM_STMT_PREPARE_OK
-		if lastCommand == 0x16 {
+		if state.lastCommand == 0x16 {
 			packetType = "COM_STMT_PREPARE_OK"
-			packetData, err = decodeComStmtPrepareOk(data)
+			var prepareOk *StmtPrepareOk
+			prepareOk, err = decodeComStmtPrepareOk(data)
+			if err == nil {
+				state.rememberPreparedStatement(prepareOk.StatementID, prepareOk.NumParams)
+			}
+			packetData = prepareOk
 		} else {
 			packetType = "MySQLOK"
 			packetData, err = decodeMySQLOK(data)
 		}
-		lastCommand = 0x00
+		state.lastCommand = 0x00
 	case data[0] == 0xFF: // MySQLErr
 		packetType = "MySQLErr"
 		packetData, err = decodeMySQLErr(data)
-		lastCommand = 0xFF
-	case data[0] == 0xFE && len(data) > 1: // Auth Switch Packet
+		state.lastCommand = 0xFF
+	case data[0] == 0xFE: // Auth Switch Packet, possibly without any plugin data
 		packetType = "AUTH_SWITCH_REQUEST"
 		packetData, err = decodeAuthSwitchRequest(data)
-		lastCommand = 0xFE
-	case data[0] == 0xFE || expectingAuthSwitchResponse:
-		packetType = "AUTH_SWITCH_RESPONSE"
-		packetData, err = decodeAuthSwitchResponse(data)
-		expectingAuthSwitchResponse = false
+		// the auth-more-data which follows is sent by the switched plugin
+		if authSwitch, ok := packetData.(*AuthSwitchRequestPacket); ok && err == nil && authSwitch.PluginName != "" {
+			state.pluginName = authSwitch.PluginName
+		}
+		state.lastCommand = 0xFE
 	case data[0] == 0xFE: // EOF packet
 		packetType = "MySQLEOF"
 		packetData, err = decodeMYSQLEOF(data)
-		lastCommand = 0xFE
+		state.lastCommand = 0xFE
 	case data[0] == 0x02: // New packet type
 		packetType = "AUTH_MORE_DATA"
 		packetData, err = decodeAuthMoreData(data)
-		lastCommand = 0x02
+		state.lastCommand = 0x02
 	case data[0] == 0x18: // SEND_LONG_DATA Packet
 		packetType = "COM_STMT_SEND_LONG_DATA"
 		packetData, err = decodeComStmtSendLongData(data)
-		lastCommand = 0x18
+		state.lastCommand = 0x18
 	case data[0] == 0x1a: // STMT_RESET Packet
 		packetType = "COM_STMT_RESET"
 		packetData, err = decodeComStmtReset(data)
-		lastCommand = 0x1a
-	case data[0] == 0x8d || expectingHandshakeResponse || expectingHandshakeResponseTest: // Handshake Response packet
+		state.lastCommand = 0x1a
+	case data[0] == 0x8d || state.expectingHandshakeResponse: // Handshake Response packet
 		packetType = "HANDSHAKE_RESPONSE"
 		packetData, err = decodeHandshakeResponse(data)
-		lastCommand = 0x8d // This value may differ depending on the handshake response protocol version
+		state.lastCommand = 0x8d // This value may differ depending on the handshake response protocol version
 	case data[0] == 0x01: // Handshake Response packet
 		if len(data) == 1 {
 			packetType = "COM_QUIT"
 			packetData = nil
 		} else {
 			packetType = "HANDSHAKE_RESPONSE_OK"
-			packetData, err = decodeHandshakeResponseOk(data)
+			packetData, err = decodeHandshakeResponseOk(data, state)
 		}
 	default:
 		packetType = "Unknown"
@@ -275,7 +332,7 @@ func DecodeMySQLPacket(packet MySQLPacket, logger *zap.Logger, destConn net.Conn
 			zap.ByteString("Data", data))
 	}
 	if (models.GetMode()) == "test" {
-		lastCommand = 0x00
+		state.lastCommand = 0x00
 	}
 	return packetType, header, packetData, nil
 }
@@ -304,8 +361,6 @@ func (p *MySQLPacket) Encode() ([]byte, error) {
 	return packet, nil
 }
 
-var lastCommand byte // This is global and will remember the last command
-
 func encodeLengthEncodedInteger(n uint64) []byte {
 	var buf []byte
 
@@ -332,7 +387,7 @@ func writeLengthEncodedString(buf *bytes.Buffer, s string) {
 		binary.Write(buf, binary.LittleEndian, uint16(length))
 	case length <= 0xFFFFFF:
 		buf.WriteByte(0xFD)
-		binary.Write(buf, binary.LittleEndian, uint32(length)&0xFFFFFF)
+		buf.Write([]byte{byte(length), byte(length >> 8), byte(length >> 16)})
 	default:
 		buf.WriteByte(0xFE)
 		binary.Write(buf, binary.LittleEndian, uint64(length))
@@ -355,7 +410,7 @@ func writeLengthEncodedInteger(buf *bytes.Buffer, val *uint64) {
 		binary.Write(buf, binary.LittleEndian, uint16(*val))
 	case *val <= 0xFFFFFF:
 		buf.WriteByte(0xFD)
-		binary.Write(buf, binary.LittleEndian, uint32(*val)&0xFFFFFF)
+		buf.Write([]byte{byte(*val), byte(*val >> 8), byte(*val >> 16)})
 	default:
 		buf.WriteByte(0xFE)
 		binary.Write(buf, binary.LittleEndian, *val)
@@ -425,6 +480,9 @@ func readLengthEncodedString(data []byte, offset *int) (string, error) {
 		length = int(binary.LittleEndian.Uint64(data[*offset+1 : *offset+9]))
 		*offset += 9
 	}
+	if length < 0 || *offset+length > len(data) {
+		return "", errors.New("data length is not enough for the string")
+	}
 	result := string(data[*offset : *offset+length])
 	*offset += length
 	return result, nil
@@ -527,7 +585,7 @@ func readLengthEncodedStrings(b []byte) (string, int) {
 }
 
 func (packet *HandshakeV10Packet) ShouldUseSSL() bool {
-	return (packet.CapabilityFlags & models.CLIENT_SSL) != 0
+	return (packet.CapabilityFlags & uint32(models.CLIENT_SSL)) != 0
 }
 
 func (packet *HandshakeV10Packet) GetAuthMethod() string {
@@ -607,6 +665,9 @@ func readLengthEncodedStringOff(data []byte, offset *int) (string, error) {
 		length = int(binary.LittleEndian.Uint64(data[*offset+1 : *offset+9]))
 		*offset += 9
 	}
+	if length < 0 || *offset+length > len(data) {
+		return "", errors.New("data length is not enough for the string")
+	}
 	result := string(data[*offset : *offset+length])
 	*offset += length
 	return result, nil
@@ -7,7 +7,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"time"
+	"strconv"
+	"strings"
 
 	"go.keploy.io/server/pkg/models"
 )
@@ -22,262 +23,262 @@ type ResultSet struct {
 	OptionalPadding     bool                `json:"optionalPadding,omitempty" yaml:"optionalPadding,omitempty,flow"`
 	OptionalEOFBytes    string              `json:"optionalEOFBytes,omitempty" yaml:"optionalEOFBytes,omitempty,flow"`
 	EOFAfterColumns     string              `json:"eofAfterColumns,omitempty" yaml:"eofAfterColumns,omitempty,flow"`
+	// Binary is set for the rows of the binary protocol, which answer the
+	// COM_STMT_EXECUTE.
+	Binary bool `json:"binary,omitempty" yaml:"binary,omitempty,flow"`
 }
 
 type Row struct {
 	Header  RowHeader             `json:"header,omitempty" yaml:"header,omitempty,flow"`
-	Columns []RowColumnDefinition `json:"columns,omitempty" yaml:"row_column_definition,omitempty,flow"`
+	Columns []RowColumnDefinition `json:"columns,omitempty" yaml:"columns,omitempty,flow"`
 }
 
+// RowColumnDefinition is a value of a row. The values are kept in their text
+// form, whichever protocol the row is in.
 type RowColumnDefinition struct {
 	Type  models.FieldType `json:"type,omitempty" yaml:"type,omitempty,flow"`
 	Name  string           `json:"name,omitempty" yaml:"name,omitempty,flow"`
 	Value interface{}      `json:"value,omitempty" yaml:"value,omitempty,flow"`
+	Null  bool             `json:"null,omitempty" yaml:"null,omitempty,flow"`
 }
 
 type RowHeader struct {
-	PacketLength int   `json:"packet_length,omitempty" yaml:"packet_length,omitempty,flow"`
-	SequenceID   uint8 `json:"sequence_id,omitempty" yaml:"sequence_id,omitempty,flow"`
+	PacketLength     uint32 `json:"packet_length,omitempty" yaml:"packet_length,omitempty,flow"`
+	PacketSequenceID uint8  `json:"packet_sequence_id,omitempty" yaml:"packet_sequence_id,omitempty,flow"`
 }
 
-func parseResultSet(b []byte) (*ResultSet, error) {
-	columns := make([]*ColumnDefinition, 0)
-	rows := make([]*Row, 0)
-	var err error
-	var eofPresent, paddingPresent, eofFinal, paddingFinal, optionalPadding bool
-	var optionalEOFBytes []byte
-	var eofAfterColumns []byte
-	// Parse the column count packet
-	columnCount, _, n := readLengthEncodedInteger(b)
+// unsignedColumnFlag marks the unsigned columns in the flags of their
+// definition.
+const unsignedColumnFlag = 0x20
+
+// decodeResultSet decodes a result set: the column count, the column
+// definitions, the EOF packet following them unless the client set
+// CLIENT_DEPRECATE_EOF, the rows and the EOF or OK packet ending them. The
+// buffer starts with the payload of the column count packet, the packets
+// which follow keep their header. The rows are in the text protocol, or in
+// the binary one for the responses of COM_STMT_EXECUTE.
+//
+// The packets following the end of the rows, such as the next result of a
+// multi-statement query, and the rows which can't be decoded are kept raw
+// along with it.
+func decodeResultSet(b []byte, binaryRows bool) (*ResultSet, error) {
+	columnCount, isNull, n := readLengthEncodedInteger(b)
+	if isNull || n > len(b) {
+		return nil, errors.New("invalid column count of the result set")
+	}
 	b = b[n:]
 
-	// Parse the columns
+	resultSet := &ResultSet{
+		Columns: []*ColumnDefinition{},
+		Rows:    []*Row{},
+		Binary:  binaryRows,
+	}
 	for i := uint64(0); i < columnCount; i++ {
-		var columnPacket *ColumnDefinition
-		columnPacket, b, err = parseColumnDefinitionPacket(b)
+		packet, rest, err := nextResultSetPacket(b)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to read the definition of column %d: %v", i, err)
 		}
-		columns = append(columns, columnPacket)
+		column, err := decodeColumnDefinition(packet)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode the definition of column %d: %v", i, err)
+		}
+		resultSet.Columns = append(resultSet.Columns, column)
+		b = rest
 	}
 
-	// Check for EOF packet after columns
-	if len(b) > 4 && bytes.Contains(b[4:9], []byte{0xfe, 0x00, 0x00}) {
-		eofPresent = true
-		eofAfterColumns = b[:9]
-		b = b[9:] // Skip the EOF packet
-		if len(b) >= 2 && b[0] == 0x00 && b[1] == 0x00 {
-			paddingPresent = true
-			b = b[2:] // Skip padding
-		}
+	// the EOF packet is 5 bytes long, unlike the OK packet ending the rows
+	// for the clients with CLIENT_DEPRECATE_EOF
+	if packet, rest, err := nextResultSetPacket(b); err == nil && len(packet) == 9 && packet[4] == 0xfe {
+		resultSet.EOFPresent = true
+		resultSet.EOFAfterColumns = base64.StdEncoding.EncodeToString(packet)
+		b = rest
 	}
 
-	// Parse the rows
-	// fmt.Println(!bytes.Equal(b[:4], []byte{0xfe, 0x00, 0x00, 0x02, 0x00}))
-	for len(b) > 5 {
-		// fmt.Println(b)
+	for len(b) > 0 {
+		packet, rest, err := nextResultSetPacket(b)
+		if err != nil {
+			break
+		}
+		payload := packet[4:]
+		if isResultSetEnd(payload) {
+			resultSet.EOFPresentFinal = true
+			break
+		}
 		var row *Row
-		row, b, eofFinal, paddingFinal, optionalPadding, optionalEOFBytes, err = parseRow(b, columns)
+		if binaryRows {
+			row, err = decodeBinaryRow(payload, resultSet.Columns)
+		} else {
+			row, err = decodeTextRow(payload, resultSet.Columns)
+		}
 		if err != nil {
-			return nil, err
+			break
 		}
-		if row != nil {
-			rows = append(rows, row)
+		row.Header = RowHeader{
+			PacketLength:     uint32(len(payload)),
+			PacketSequenceID: packet[3],
 		}
+		resultSet.Rows = append(resultSet.Rows, row)
+		b = rest
 	}
-
-	// Remove EOF packet of the rows
-	// b = b[9:]
-
-	resultSet := &ResultSet{
-		Columns:             columns,
-		Rows:                rows,
-		EOFPresent:          eofPresent,
-		PaddingPresent:      paddingPresent,
-		EOFPresentFinal:     eofFinal,
-		PaddingPresentFinal: paddingFinal,
-		OptionalPadding:     optionalPadding,
-		OptionalEOFBytes:    base64.StdEncoding.EncodeToString(optionalEOFBytes),
-		EOFAfterColumns:     base64.StdEncoding.EncodeToString(eofAfterColumns),
-	}
-
-	return resultSet, err
+	if len(b) > 0 {
+		resultSet.OptionalEOFBytes = base64.StdEncoding.EncodeToString(b)
+	}
+	return resultSet, nil
 }
 
-func parseColumnDefinitionPacket(b []byte) (*ColumnDefinition, []byte, error) {
-	packet := &ColumnDefinition{}
-	var n int
-	var m int
-
-	// Read packet header
-	packet.PacketHeader.PacketLength = uint8(readUint24(b[:3]))
-	packet.PacketHeader.PacketSequenceID = uint8(b[3])
-	b = b[4:]
-
-	packet.Catalog, n = readLengthEncodedStrings(b)
-	b = b[n:]
-	packet.Schema, n = readLengthEncodedStrings(b)
-	b = b[n:]
-	packet.Table, n = readLengthEncodedStrings(b)
-	b = b[n:]
-	packet.OrgTable, n = readLengthEncodedStrings(b)
-	b = b[n:]
-	packet.Name, n = readLengthEncodedStrings(b)
-	b = b[n:]
-	packet.OrgName, n = readLengthEncodedStrings(b)
-	b = b[n:]
-	b = b[1:] // Skip the next byte (length of the fixed-length fields)
-	packet.CharacterSet = binary.LittleEndian.Uint16(b)
-	b = b[2:]
-	packet.ColumnLength = binary.LittleEndian.Uint32(b)
-	b = b[4:]
-	packet.ColumnType = b[0]
-	b = b[1:]
-	packet.Flags = binary.LittleEndian.Uint16(b)
-	b = b[2:]
-	packet.Decimals = b[0]
-	b = b[2:] // Skip filler
-	if len(b) > 0 {
-		packet.DefaultValue, m = readLengthEncodedStrings(b)
-		b = b[m:]
+// nextResultSetPacket returns the packet at the start of the buffer, along
+// with its header, and the bytes following it.
+func nextResultSetPacket(b []byte) ([]byte, []byte, error) {
+	if len(b) < 4 {
+		return nil, nil, errors.New("the packet header is incomplete")
 	}
+	length := int(readUint24(b[:3]))
+	if 4+length > len(b) {
+		return nil, nil, errors.New("the packet is incomplete")
+	}
+	return b[:4+length], b[4+length:], nil
+}
 
-	return packet, b, nil
+// isResultSetEnd reports whether the payload is the EOF or the OK packet
+// ending the rows, or an error interrupting them. A text row only starts with
+// 0xfe when it is longer than a packet can be.
+func isResultSetEnd(payload []byte) bool {
+	if len(payload) == 0 {
+		return false
+	}
+	return (payload[0] == 0xfe && len(payload) < 0xffffff) || payload[0] == 0xff
 }
 
-var optionalPadding bool
+func decodeColumnDefinition(packet []byte) (*ColumnDefinition, error) {
+	column := &ColumnDefinition{}
+	column.PacketHeader.PacketLength = uint8(readUint24(packet[:3]))
+	column.PacketHeader.PacketSequenceID = packet[3]
+	payload := packet[4:]
 
-func parseRow(b []byte, columnDefinitions []*ColumnDefinition) (*Row, []byte, bool, bool, bool, []byte, error) {
-	var eofFinal, paddingFinal bool
-	var optionalEOFBytes []byte
+	offset := 0
+	for _, field := range []*string{&column.Catalog, &column.Schema, &column.Table, &column.OrgTable, &column.Name, &column.OrgName} {
+		value, err := readLengthEncodedString(payload, &offset)
+		if err != nil {
+			return nil, err
+		}
+		*field = value
+	}
+	nextLength, err := readLengthEncodedIntegerOff(payload, &offset)
+	if err != nil {
+		return nil, err
+	}
+	column.NextLength = nextLength
+	if offset+12 > len(payload) {
+		return nil, errors.New("the fixed length fields are incomplete")
+	}
+	column.CharacterSet = binary.LittleEndian.Uint16(payload[offset:])
+	column.ColumnLength = binary.LittleEndian.Uint32(payload[offset+2:])
+	column.ColumnType = payload[offset+6]
+	column.Flags = binary.LittleEndian.Uint16(payload[offset+7:])
+	column.Decimals = payload[offset+9]
+	offset += 12
+	// the default values are only sent in response to COM_FIELD_LIST
+	if offset < len(payload) {
+		column.DefaultValue, err = readLengthEncodedString(payload, &offset)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return column, nil
+}
 
+// decodeTextRow decodes a row of the text protocol, where every value is a
+// length encoded string and NULL is 0xfb.
+func decodeTextRow(payload []byte, columns []*ColumnDefinition) (*Row, error) {
 	row := &Row{}
-	if b[4] == 0xfe {
-		eofFinal = true
-		optionalEOFBytes = b[:9]
-		b = b[9:]
-		if len(b) >= 2 && b[0] == 0x00 && b[1] == 0x00 {
-			paddingFinal = true
-			b = b[2:] // Skip padding
+	offset := 0
+	for _, column := range columns {
+		value := RowColumnDefinition{
+			Type: models.FieldType(column.ColumnType),
+			Name: column.Name,
 		}
-		if len(b) < 14 {
-			return nil, nil, eofFinal, paddingFinal, optionalPadding, optionalEOFBytes, nil
-
+		if offset >= len(payload) {
+			return nil, errors.New("the row has fewer values than columns")
 		}
-	}
-	packetLength := int(b[0])
-	sequenceID := b[3]
-	rowHeader := RowHeader{
-		PacketLength: packetLength,
-		SequenceID:   sequenceID,
-	}
-	b = b[4:]
-	if len(b) >= 2 && b[0] == 0x00 && b[1] == 0x00 {
-		optionalPadding = true
-		b = b[2:] // Skip padding
-	}
-	// b = b[2:]
-	for _, columnDef := range columnDefinitions {
-		var colValue RowColumnDefinition
-		var length int
-		// if b[0] == 0x00 {
-		// 	b = b[1:]
-		// }
-		dataLength := int(b[0])
-
-		// Check the column type
-		switch models.FieldType(columnDef.ColumnType) {
-		case models.FieldTypeTimestamp:
-			b = b[1:] // Advance the buffer to the start of the encoded timestamp data
-
-			if dataLength < 4 || len(b) < dataLength {
-				return nil, nil, eofFinal, paddingFinal, optionalPadding, optionalEOFBytes, fmt.Errorf("invalid timestamp data length")
+		if payload[offset] == 0xfb {
+			value.Null = true
+			offset++
+		} else {
+			text, err := readLengthEncodedString(payload, &offset)
+			if err != nil {
+				return nil, err
 			}
-
-			// Decode the year, month, day, hour, minute, second
-			year := binary.LittleEndian.Uint16(b[:2])
-			month := uint8(b[2])
-			day := uint8(b[3])
-			hour := uint8(b[4])
-			minute := uint8(b[5])
-			second := uint8(b[6])
-
-			colValue.Type = models.FieldTypeTimestamp
-			colValue.Value = fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
-			length = dataLength // Including the initial byte for dataLength
-
-		// case models.FieldTypeInt24, models.FieldTypeLong:
-		// 	colValue.Type = models.FieldType(columnDef.ColumnType)
-		// 	colValue.Value = int32(binary.LittleEndian.Uint32(b[:4]))
-		// 	length = 4
-		// case models.FieldTypeLongLong:
-		// 	colValue.Type = models.FieldTypeLongLong
-		// 	var longLongBytes []byte = b[1 : dataLength+1]
-		// 	colValue.Value = longLongBytes
-		// 	length = dataLength
-		// 	b = b[1:]
-		// case models.FieldTypeFloat:
-		// 	colValue.Type = models.FieldTypeFloat
-		// 	colValue.Value = math.Float32frombits(binary.LittleEndian.Uint32(b[:4]))
-		// 	length = 4
-		// case models.FieldTypeDouble:
-		// 	colValue.Type = models.FieldTypeDouble
-		// 	colValue.Value = math.Float64frombits(binary.LittleEndian.Uint64(b[:8]))
-		// 	length = 8
-		default:
-			// Read a length-encoded integer
-			stringLength, _, n := readLengthEncodedInteger(b)
-			length = int(stringLength) + n
-
-			// Extract the string
-			str := string(b[n : n+int(stringLength)])
-
-			// Remove non-printable characters
-			// re := regexp.MustCompile(`[^[:print:]\t\r\n]`)
-			// cleanedStr := re.ReplaceAllString(str, "")
-
-			colValue.Type = models.FieldType(columnDef.ColumnType)
-			colValue.Value = str
+			value.Value = text
 		}
+		row.Columns = append(row.Columns, value)
+	}
+	if offset != len(payload) {
+		return nil, errors.New("the row has more values than columns")
+	}
+	return row, nil
+}
 
-		colValue.Name = columnDef.Name
-		row.Columns = append(row.Columns, colValue)
-		b = b[length:]
+// decodeBinaryRow decodes a row of the binary protocol: a 0x00 header, the
+// bitmap of the NULL values, offset by 2 bits, and the values of the other
+// columns encoded after their type.
+func decodeBinaryRow(payload []byte, columns []*ColumnDefinition) (*Row, error) {
+	nullBitmapLength := (len(columns) + 7 + 2) / 8
+	if len(payload) < 1+nullBitmapLength || payload[0] != 0x00 {
+		return nil, errors.New("invalid header of the binary row")
 	}
-	row.Header = rowHeader
-	return row, b, eofFinal, paddingFinal, optionalPadding, optionalEOFBytes, nil
+	nullBitmap := payload[1 : 1+nullBitmapLength]
+	offset := 1 + nullBitmapLength
+
+	row := &Row{}
+	for i, column := range columns {
+		value := RowColumnDefinition{
+			Type: models.FieldType(column.ColumnType),
+			Name: column.Name,
+		}
+		if nullBitmap[(i+2)/8]&(1<<(uint(i+2)%8)) != 0 {
+			value.Null = true
+			row.Columns = append(row.Columns, value)
+			continue
+		}
+		length, err := binaryValueLength(value.Type, payload[offset:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the value of column %s: %v", column.Name, err)
+		}
+		if offset+length > len(payload) {
+			return nil, fmt.Errorf("the value of column %s is incomplete", column.Name)
+		}
+		value.Value = binaryValueText(value.Type, column.Flags&unsignedColumnFlag != 0, payload[offset:offset+length])
+		offset += length
+		row.Columns = append(row.Columns, value)
+	}
+	if offset != len(payload) {
+		return nil, errors.New("the row has more values than columns")
+	}
+	return row, nil
 }
 
 func encodeMySQLResultSet(resultSet *models.MySQLResultSet) ([]byte, error) {
 	buf := new(bytes.Buffer)
 	sequenceID := byte(1)
-	buf.Write([]byte{0x01, 0x00, 0x00, 0x01})
-	// Write column count
-	lengthColumns := uint64(len(resultSet.Columns))
-	writeLengthEncodedInteger(buf, &lengthColumns)
-
-	if len(resultSet.Columns) > 0 {
-		for _, column := range resultSet.Columns {
-			sequenceID++
-			buf.WriteByte(byte(column.PacketHeader.PacketLength))
-			buf.WriteByte(byte(column.PacketHeader.PacketLength >> 8))
-			buf.WriteByte(byte(column.PacketHeader.PacketLength >> 16))
-			buf.WriteByte(sequenceID)
-
-			writeLengthEncodedString(buf, column.Catalog)
-			writeLengthEncodedString(buf, column.Schema)
-			writeLengthEncodedString(buf, column.Table)
-			writeLengthEncodedString(buf, column.OrgTable)
-			writeLengthEncodedString(buf, column.Name)
-			writeLengthEncodedString(buf, column.OrgName)
-			buf.WriteByte(0x0c) // Length of the fixed-length fields (12 bytes)
-			binary.Write(buf, binary.LittleEndian, column.CharacterSet)
-			binary.Write(buf, binary.LittleEndian, column.ColumnLength)
-			buf.WriteByte(column.ColumnType)
-			binary.Write(buf, binary.LittleEndian, column.Flags)
-			buf.WriteByte(column.Decimals)
-			buf.Write([]byte{0x00, 0x00}) // Filler
-		}
+	writeResultSetPacket(buf, sequenceID, encodeLengthEncodedInteger(uint64(len(resultSet.Columns))))
+
+	for _, column := range resultSet.Columns {
+		sequenceID++
+		payload := new(bytes.Buffer)
+		writeLengthEncodedString(payload, column.Catalog)
+		writeLengthEncodedString(payload, column.Schema)
+		writeLengthEncodedString(payload, column.Table)
+		writeLengthEncodedString(payload, column.OrgTable)
+		writeLengthEncodedString(payload, column.Name)
+		writeLengthEncodedString(payload, column.OrgName)
+		payload.WriteByte(0x0c) // Length of the fixed-length fields (12 bytes)
+		binary.Write(payload, binary.LittleEndian, column.CharacterSet)
+		binary.Write(payload, binary.LittleEndian, column.ColumnLength)
+		payload.WriteByte(column.ColumnType)
+		binary.Write(payload, binary.LittleEndian, column.Flags)
+		payload.WriteByte(column.Decimals)
+		payload.Write([]byte{0x00, 0x00}) // Filler
+		writeResultSetPacket(buf, sequenceID, payload.Bytes())
 	}
 
 	// Write EOF packet header
@@ -293,19 +294,22 @@ func encodeMySQLResultSet(resultSet *models.MySQLResultSet) ([]byte, error) {
 	// Write rows
 	for _, row := range resultSet.Rows {
 		sequenceID++
-		//buf.WriteByte(byte(row.Header.PacketLength))
-		buf.WriteByte(row.Header.PacketLength)
-		buf.Write([]byte{0x00, 0x00}) // two extra bytes after header
-		buf.WriteByte(sequenceID)
+		var payload []byte
+		var err error
+		if resultSet.Binary {
+			payload, err = encodeBinaryRow(row, resultSet.Columns)
+		} else {
+			payload, err = encodeTextRow(row)
+		}
+		if err != nil {
+			return nil, err
+		}
 		if resultSet.OptionalPadding {
-			buf.Write([]byte{0x00, 0x00}) // Add padding bytes
+			payload = append([]byte{0x00, 0x00}, payload...) // Add padding bytes
 		}
-		bytes, _ := encodeRow(row, row.Columns)
-		buf.Write(bytes)
+		writeResultSetPacket(buf, sequenceID, payload)
 	}
-	sequenceID++
-	// Write EOF packet header again
-	// buf.Write([]byte{5, 0, 0, sequenceID})
+	// Write the EOF or OK packet ending the rows
 	OptionalEOFBytesValue, _ := base64.StdEncoding.DecodeString(resultSet.OptionalEOFBytes)
 	buf.Write(OptionalEOFBytesValue)
 	if resultSet.PaddingPresentFinal {
@@ -314,78 +318,170 @@ func encodeMySQLResultSet(resultSet *models.MySQLResultSet) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func encodeRow(row *models.Row, columnValues []models.RowColumnDefinition) ([]byte, error) {
+// writeResultSetPacket writes the payload as a packet with its header.
+func writeResultSetPacket(buf *bytes.Buffer, sequenceID byte, payload []byte) {
+	length := len(payload)
+	buf.Write([]byte{byte(length), byte(length >> 8), byte(length >> 16), sequenceID})
+	buf.Write(payload)
+}
+
+// encodeTextRow encodes a row of the text protocol.
+func encodeTextRow(row *models.Row) ([]byte, error) {
 	var buf bytes.Buffer
+	for _, column := range row.Columns {
+		if column.Null {
+			buf.WriteByte(0xfb)
+			continue
+		}
+		writeLengthEncodedString(&buf, valueText(column.Value))
+	}
+	return buf.Bytes(), nil
+}
 
-	// Write the header
-	//binary.Write(&buf, binary.LittleEndian, uint32(row.Header.PacketLength))
-	//buf.WriteByte(row.Header.PacketSequenceId)
-
-	for _, column := range columnValues {
-		value := column.Value
-		switch models.FieldType(column.Type) {
-		case models.FieldTypeTimestamp:
-			timestamp, ok := value.(string)
-			if !ok {
-				return nil, errors.New("could not convert value to string")
-			}
-			t, err := time.Parse("2006-01-02 15:04:05", timestamp)
-			if err != nil {
-				return nil, errors.New("could not parse timestamp value")
-			}
+// encodeBinaryRow encodes a row of the binary protocol, converting the values
+// back from their text form to the type of their column.
+func encodeBinaryRow(row *models.Row, columns []*models.ColumnDefinition) ([]byte, error) {
+	if len(row.Columns) != len(columns) {
+		return nil, fmt.Errorf("the row has %d values for %d columns", len(row.Columns), len(columns))
+	}
+	nullBitmap := make([]byte, (len(columns)+7+2)/8)
+	var values []byte
+	for i, column := range row.Columns {
+		if column.Null {
+			nullBitmap[(i+2)/8] |= 1 << (uint(i+2) % 8)
+			continue
+		}
+		value, err := binaryValueFromText(column.Type, columns[i].Flags&unsignedColumnFlag != 0, valueText(column.Value))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode the value of column %s: %v", column.Name, err)
+		}
+		values = append(values, value...)
+	}
+	payload := append([]byte{0x00}, nullBitmap...)
+	return append(payload, values...), nil
+}
 
-			buf.WriteByte(7) // Length of the following encoded data
-			yearBytes := make([]byte, 2)
-			binary.LittleEndian.PutUint16(yearBytes, uint16(t.Year()))
-			buf.Write(yearBytes)            // Year
-			buf.WriteByte(byte(t.Month()))  // Month
-			buf.WriteByte(byte(t.Day()))    // Day
-			buf.WriteByte(byte(t.Hour()))   // Hour
-			buf.WriteByte(byte(t.Minute())) // Minute
-			buf.WriteByte(byte(t.Second())) // Second
-			// case models.FieldTypeLongLong:
-			// 	longLongSlice, ok := column.Value.([]interface{})
-			// 	numElements := len(longLongSlice)
-			// 	buf.WriteByte(byte(numElements))
-			// 	if !ok {
-			// 		return nil, errors.New("invalid type for FieldTypeLongLong, expected []interface{}")
-			// 	}
-
-			// 	for _, v := range longLongSlice {
-			// 		intVal, ok := v.(int)
-			// 		if !ok {
-			// 			return nil, errors.New("invalid int value for FieldTypeLongLong in slice")
-			// 		}
-
-			// 		// Check that the int value is within the range of a single byte
-			// 		if intVal < 0 || intVal > 255 {
-			// 			return nil, errors.New("int value for FieldTypeLongLong out of byte range")
-			// 		}
-
-			// 		// Convert int to a single byte
-			// 		buf.WriteByte(byte(intVal))
-			// 	}
-		default:
-			strValue, ok := value.(string)
-			if !ok {
-				return nil, errors.New("could not convert value to string")
-			}
+// valueText returns the text form of a recorded value, which the mocks may
+// decode as a number.
+func valueText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
 
-			if strValue == "" {
-				// Write 0xFB to represent NULL
-				buf.WriteByte(0xFB)
-			} else {
-				length := uint64(len(strValue))
-				// Now pass a pointer to length
-				writeLengthEncodedInteger(&buf, &length)
-				// Write the string value
-				buf.WriteString(strValue)
-			}
+// binaryValueFromText encodes a value of the binary protocol from its text
+// form, as rendered by binaryValueText.
+func binaryValueFromText(fieldType models.FieldType, unsigned bool, text string) ([]byte, error) {
+	switch fieldType {
+	case models.FieldTypeNULL:
+		return nil, nil
+	case models.FieldTypeTiny:
+		v, err := parseInteger(text, unsigned, 8)
+		return []byte{byte(v)}, err
+	case models.FieldTypeShort, models.FieldTypeYear:
+		v, err := parseInteger(text, unsigned, 16)
+		return binary.LittleEndian.AppendUint16(nil, uint16(v)), err
+	case models.FieldTypeLong, models.FieldTypeInt24:
+		v, err := parseInteger(text, unsigned, 32)
+		return binary.LittleEndian.AppendUint32(nil, uint32(v)), err
+	case models.FieldTypeLongLong:
+		v, err := parseInteger(text, unsigned, 64)
+		return binary.LittleEndian.AppendUint64(nil, v), err
+	case models.FieldTypeFloat:
+		f, err := strconv.ParseFloat(text, 32)
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(f))), err
+	case models.FieldTypeDouble:
+		f, err := strconv.ParseFloat(text, 64)
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)), err
+	case models.FieldTypeDate, models.FieldTypeDateTime, models.FieldTypeTimestamp:
+		return binaryDateTimeFromText(text)
+	case models.FieldTypeTime:
+		return binaryTimeFromText(text)
+	default:
+		var buf bytes.Buffer
+		writeLengthEncodedString(&buf, text)
+		return buf.Bytes(), nil
+	}
+}
+
+func parseInteger(text string, unsigned bool, bitSize int) (uint64, error) {
+	if unsigned {
+		return strconv.ParseUint(text, 10, bitSize)
+	}
+	v, err := strconv.ParseInt(text, 10, bitSize)
+	return uint64(v), err
+}
+
+// numericFields returns the numbers of a temporal value in its text form.
+func numericFields(text string) ([]int, error) {
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r < '0' || r > '9'
+	})
+	numbers := make([]int, 0, len(fields))
+	for _, field := range fields {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return nil, err
 		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
 
+// binaryDateTimeFromText is the reverse of binaryDateTimeText: the values are
+// encoded with the length they were rendered from.
+func binaryDateTimeFromText(text string) ([]byte, error) {
+	n, err := numericFields(text)
+	if err != nil {
+		return nil, err
+	}
+	value := []byte{}
+	switch {
+	case len(n) == 6 && text == "0000-00-00 00:00:00":
+	case len(n) == 3:
+		value = binary.LittleEndian.AppendUint16(value, uint16(n[0]))
+		value = append(value, byte(n[1]), byte(n[2]))
+	case len(n) == 6 || len(n) == 7:
+		value = binary.LittleEndian.AppendUint16(value, uint16(n[0]))
+		value = append(value, byte(n[1]), byte(n[2]), byte(n[3]), byte(n[4]), byte(n[5]))
+		if len(n) == 7 {
+			value = binary.LittleEndian.AppendUint32(value, uint32(n[6]))
+		}
+	default:
+		return nil, fmt.Errorf("invalid date %q", text)
 	}
+	return append([]byte{byte(len(value))}, value...), nil
+}
 
-	return buf.Bytes(), nil
+// binaryTimeFromText is the reverse of binaryTimeText.
+func binaryTimeFromText(text string) ([]byte, error) {
+	n, err := numericFields(text)
+	if err != nil {
+		return nil, err
+	}
+	value := []byte{}
+	switch {
+	case len(n) == 3 && text == "00:00:00":
+	case len(n) == 3 || len(n) == 4:
+		negative := byte(0)
+		if strings.HasPrefix(text, "-") {
+			negative = 1
+		}
+		value = append(value, negative)
+		value = binary.LittleEndian.AppendUint32(value, uint32(n[0]/24))
+		value = append(value, byte(n[0]%24), byte(n[1]), byte(n[2]))
+		if len(n) == 4 {
+			value = binary.LittleEndian.AppendUint32(value, uint32(n[3]))
+		}
+	default:
+		return nil, fmt.Errorf("invalid time %q", text)
+	}
+	return append([]byte{byte(len(value))}, value...), nil
 }
 
 func encodeInt32(val int32) []byte {
@@ -0,0 +1,98 @@
+package mysqlparser
+
+import (
+	"fmt"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBinaryRowNullBitmap(t *testing.T) {
+	// ten BIGINT columns, the last one unsigned: their NULL bits start at the
+	// offset 2 of the bitmap, so that the columns 5 and 6 are split between
+	// its two bytes
+	var columns []*models.ColumnDefinition
+	var decodeColumns []*ColumnDefinition
+	for i := 0; i < 10; i++ {
+		column := &models.ColumnDefinition{Name: fmt.Sprintf("c%d", i), ColumnType: byte(models.FieldTypeLongLong)}
+		if i == 9 {
+			column.Flags = unsignedColumnFlag
+		}
+		columns = append(columns, column)
+		decodeColumns = append(decodeColumns, &ColumnDefinition{Name: column.Name, ColumnType: column.ColumnType, Flags: column.Flags})
+	}
+	nulls := map[int]bool{0: true, 5: true, 6: true}
+	row := &models.Row{}
+	for i, column := range columns {
+		value := models.RowColumnDefinition{Type: models.FieldTypeLongLong, Name: column.Name, Null: nulls[i]}
+		if !value.Null {
+			value.Value = fmt.Sprint(-i)
+		}
+		row.Columns = append(row.Columns, value)
+	}
+	row.Columns[9].Value = "18446744073709551615"
+
+	payload, err := encodeBinaryRow(row, columns)
+	if err != nil {
+		t.Fatalf("failed to encode the row: %v", err)
+	}
+	// the bits 2, 7 and 8 of the bitmap for the columns 0, 5 and 6
+	if payload[0] != 0x00 || payload[1] != 0x84 || payload[2] != 0x01 {
+		t.Fatalf("encoded the row header and NULL bitmap % x, want 00 84 01", payload[:3])
+	}
+	if want := 3 + 7*8; len(payload) != want {
+		t.Fatalf("encoded %d bytes, want %d for the 7 values", len(payload), want)
+	}
+
+	decoded, err := decodeBinaryRow(payload, decodeColumns)
+	if err != nil {
+		t.Fatalf("failed to decode the row: %v", err)
+	}
+	for i, value := range decoded.Columns {
+		if value.Null != nulls[i] {
+			t.Errorf("decoded the column %d as NULL %v, want %v", i, value.Null, nulls[i])
+		}
+		if !value.Null && value.Value != row.Columns[i].Value {
+			t.Errorf("decoded the column %d as %v, want %v", i, value.Value, row.Columns[i].Value)
+		}
+	}
+}
+
+func TestBinaryRowTruncatedBitmap(t *testing.T) {
+	columns := make([]*ColumnDefinition, 10)
+	for i := range columns {
+		columns[i] = &ColumnDefinition{ColumnType: byte(models.FieldTypeLongLong)}
+	}
+	// the bitmap of ten columns takes two bytes
+	if _, err := decodeBinaryRow([]byte{0x00, 0xff}, columns); err == nil {
+		t.Fatalf("decoded a row without the second byte of its NULL bitmap")
+	}
+}
+
+func TestRowHeaderReadsTheRecordedPacketLengths(t *testing.T) {
+	// the mocks recorded while the packet length was a uint8, and the rows
+	// longer than 255 bytes recorded since
+	recorded := `
+header: {packet_length: 200, packet_sequence_id: 4}
+columns:
+  - {type: 8, name: id, value: "7"}
+`
+	var row models.Row
+	if err := yaml.Unmarshal([]byte(recorded), &row); err != nil {
+		t.Fatalf("failed to read the recorded row: %v", err)
+	}
+	if row.Header.PacketLength != 200 || row.Header.PacketSequenceId != 4 || len(row.Columns) != 1 {
+		t.Fatalf("read the recorded row %+v", row)
+	}
+
+	long := models.Row{Header: models.RowHeader{PacketLength: 70000, PacketSequenceId: 5}}
+	stored, err := yaml.Marshal(long)
+	if err != nil {
+		t.Fatalf("failed to store the row: %v", err)
+	}
+	var read models.Row
+	if err := yaml.Unmarshal(stored, &read); err != nil || read.Header.PacketLength != 70000 {
+		t.Fatalf("read the packet length %d back, want 70000: %v", read.Header.PacketLength, err)
+	}
+}
@@ -0,0 +1,33 @@
+package mysqlparser
+
+// resequencePackets rewrites the sequence ids of the packets in the buffer so
+// that they continue from first, one packet after the other, as the client
+// libraries validate them. The sequence id wraps around after 255 like on the
+// wire. The buffer is returned unchanged when it is not made of whole packets.
+func resequencePackets(buffer []byte, first byte) []byte {
+	for offset := 0; offset < len(buffer); {
+		if offset+4 > len(buffer) {
+			return buffer
+		}
+		payloadLength := int(uint32(buffer[offset]) | uint32(buffer[offset+1])<<8 | uint32(buffer[offset+2])<<16)
+		offset += 4 + payloadLength
+		// the payload of the last packet is cut short
+		if offset > len(buffer) {
+			return buffer
+		}
+	}
+	if len(buffer) == 0 {
+		return buffer
+	}
+
+	resequenced := make([]byte, len(buffer))
+	copy(resequenced, buffer)
+	sequenceID := first
+	for offset := 0; offset < len(resequenced); {
+		payloadLength := int(uint32(resequenced[offset]) | uint32(resequenced[offset+1])<<8 | uint32(resequenced[offset+2])<<16)
+		resequenced[offset+3] = sequenceID
+		sequenceID++
+		offset += 4 + payloadLength
+	}
+	return resequenced
+}
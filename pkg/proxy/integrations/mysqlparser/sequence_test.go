@@ -0,0 +1,50 @@
+package mysqlparser
+
+import (
+	"bytes"
+	"testing"
+)
+
+// packet returns the wire bytes of a packet of the payload with the sequence
+// id.
+func packet(sequenceID byte, payload ...byte) []byte {
+	return append([]byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), sequenceID}, payload...)
+}
+
+func TestResequencePackets(t *testing.T) {
+	columnCount := packet(7, 0x01)
+	column := packet(9, 0x03, 'd', 'e', 'f')
+	eof := packet(3, 0xfe, 0x00, 0x00, 0x02, 0x00)
+	buffer := append(append(append([]byte{}, columnCount...), column...), eof...)
+
+	got := resequencePackets(buffer, 1)
+	want := append(append(packet(1, 0x01), packet(2, 0x03, 'd', 'e', 'f')...), packet(3, 0xfe, 0x00, 0x00, 0x02, 0x00)...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("got the packets %v, want %v", got, want)
+	}
+	if buffer[3] != 7 {
+		t.Errorf("rewrote the sequence ids of the buffer in place")
+	}
+}
+
+func TestResequencePacketsWrapsAround(t *testing.T) {
+	buffer := append(append(packet(0, 0x01), packet(0, 0x02)...), packet(0, 0x03)...)
+	got := resequencePackets(buffer, 254)
+	for i, want := range []byte{254, 255, 0} {
+		if sequenceID := got[i*5+3]; sequenceID != want {
+			t.Errorf("packet %d: got the sequence id %d, want %d", i, sequenceID, want)
+		}
+	}
+}
+
+func TestResequencePacketsLeavesPartialPacketsUnchanged(t *testing.T) {
+	for _, buffer := range [][]byte{
+		{},
+		{0x05, 0x00},
+		append(packet(4, 0x01), 0x09, 0x00, 0x00, 0x05, 0x01),
+	} {
+		if got := resequencePackets(buffer, 1); !bytes.Equal(got, buffer) {
+			t.Errorf("got the packets %v, want the buffer %v unchanged", got, buffer)
+		}
+	}
+}
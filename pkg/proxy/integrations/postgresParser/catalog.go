@@ -0,0 +1,36 @@
+package postgresparser
+
+import (
+	"regexp"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// catalogQueryRe matches the statements run by the ORMs and drivers to learn
+// the schema and the server: the queries of the system catalogs and of the
+// information schema, the server functions, and the SHOW and SET commands.
+var catalogQueryRe = regexp.MustCompile(`(?is)\b(?:pg_catalog|information_schema)\.|\bpg_[a-z_]+\b|^\s*(?:show|set)\s|\b(?:version|current_schema|current_schemas|current_database|current_setting|current_user|session_user|format_type|has_table_privilege|has_schema_privilege)\s*\(`)
+
+// isCatalogQuery reports whether the statement introspects the schema or the
+// server.
+func isCatalogQuery(query string) bool {
+	return catalogQueryRe.MatchString(query)
+}
+
+// isIntrospectionExchange reports whether every statement of the requests is
+// a catalog query. The requests without any statement, such as the startup,
+// the authentication or a lone Sync, are part of every session and are
+// reported as introspection as well.
+func isIntrospectionExchange(requests []models.Backend) bool {
+	for _, request := range requests {
+		if request.Query.String != "" && !isCatalogQuery(request.Query.String) {
+			return false
+		}
+		for _, parse := range request.Parses {
+			if parse.Query != "" && !isCatalogQuery(parse.Query) {
+				return false
+			}
+		}
+	}
+	return true
+}
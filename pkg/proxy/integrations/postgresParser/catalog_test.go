@@ -0,0 +1,101 @@
+package postgresparser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// mockDB keeps the mocks recorded through the hooks.
+type mockDB struct {
+	platform.TestCaseDB
+	mocks []*models.Mock
+}
+
+func (db *mockDB) WriteMock(mock platform.KindSpecifier, ctx context.Context) error {
+	db.mocks = append(db.mocks, mock.(*models.Mock))
+	return nil
+}
+
+func simpleQuery(query string) []models.Backend {
+	return []models.Backend{{PacketTypes: []string{"Q"}, Query: pgproto3.Query{String: query}}}
+}
+
+func extendedQuery(queries ...string) []models.Backend {
+	request := models.Backend{}
+	for _, query := range queries {
+		request.PacketTypes = append(request.PacketTypes, "P", "B", "E")
+		request.Parses = append(request.Parses, pgproto3.Parse{Query: query})
+		request.Binds = append(request.Binds, pgproto3.Bind{})
+		request.Executes = append(request.Executes, pgproto3.Execute{})
+	}
+	request.PacketTypes = append(request.PacketTypes, "S")
+	return []models.Backend{request}
+}
+
+func TestIsIntrospectionExchange(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		requests []models.Backend
+		want     bool
+	}{
+		{name: "catalog", requests: simpleQuery("SELECT oid, typname FROM pg_catalog.pg_type"), want: true},
+		{name: "information schema", requests: simpleQuery("SELECT column_name FROM information_schema.columns WHERE table_name = 'users'"), want: true},
+		{name: "server function", requests: simpleQuery("SELECT version()"), want: true},
+		{name: "show", requests: simpleQuery("SHOW server_version"), want: true},
+		{name: "set", requests: simpleQuery("SET search_path TO public"), want: true},
+		{name: "sync", requests: []models.Backend{{PacketTypes: []string{"S"}}}, want: true},
+		{name: "table", requests: simpleQuery("SELECT * FROM users"), want: false},
+		{name: "column named like a setting", requests: simpleQuery("SELECT settings FROM users"), want: false},
+		{name: "extended catalog", requests: extendedQuery("SELECT typname FROM pg_type WHERE oid = $1"), want: true},
+		{name: "extended mixed", requests: extendedQuery("SELECT typname FROM pg_type WHERE oid = $1", "INSERT INTO users (name) VALUES ($1)"), want: false},
+	} {
+		if got := isIntrospectionExchange(tt.requests); got != tt.want {
+			t.Errorf("%s: got introspection %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestOnlyIntrospectionIsRecorded records the exchanges of a session into the
+// fixture of the schema introspection.
+func TestOnlyIntrospectionIsRecorded(t *testing.T) {
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+	conn := p.newConnection(context.Background(), zap.NewNop())
+
+	responses := []models.Frontend{{PacketTypes: []string{"C", "Z"}, CommandCompletes: []pgproto3.CommandComplete{{CommandTag: []byte("SELECT 1")}}}}
+	for _, requests := range [][]models.Backend{
+		simpleQuery("SELECT oid, typname FROM pg_catalog.pg_type"),
+		simpleQuery("SELECT * FROM users"),
+		extendedQuery("SELECT current_schema()"),
+		extendedQuery("INSERT INTO users (name) VALUES ($1)"),
+	} {
+		conn.appendMock(requests, responses, time.Now(), time.Now(), mockScope{}, true)
+	}
+
+	if len(db.mocks) != 2 {
+		t.Fatalf("recorded %d mocks, want the 2 introspection ones", len(db.mocks))
+	}
+	if got := db.mocks[0].Spec.PostgresRequests[0].Query.String; got != "SELECT oid, typname FROM pg_catalog.pg_type" {
+		t.Errorf("recorded the query %q, want the catalog query", got)
+	}
+	if got := db.mocks[1].Spec.PostgresRequests[0].Parses[0].Query; got != "SELECT current_schema()" {
+		t.Errorf("recorded the query %q, want the server function", got)
+	}
+
+	// every exchange is recorded otherwise
+	conn.appendMock(simpleQuery("SELECT * FROM users"), responses, time.Now(), time.Now(), mockScope{}, false)
+	if len(db.mocks) != 3 {
+		t.Errorf("recorded %d mocks, want the query of the table as well", len(db.mocks))
+	}
+}
@@ -0,0 +1,63 @@
+package postgresparser
+
+import (
+	"bytes"
+)
+
+// binaryCopySignature starts the header of every binary COPY stream. The client
+// rejects the stream unless the signature, flags and header extension are
+// replayed exactly as the server sent them.
+var binaryCopySignature = []byte("PGCOPY\n\377\r\n\000")
+
+// containsBinaryCopyHeader reports whether one of the CopyData messages of the
+// buffer carries the header of a binary COPY stream.
+func containsBinaryCopyHeader(buffer []byte) bool {
+	for i := 0; i < len(buffer); {
+		bodyLen, err := readMessageBodyLen(buffer, i)
+		if err != nil {
+			return false
+		}
+		if buffer[i] == 'd' && bytes.HasPrefix(buffer[i+5:i+5+bodyLen], binaryCopySignature) {
+			return true
+		}
+		i += 5 + bodyLen
+	}
+	return false
+}
+
+// copyDataRoundTrips reports whether the encoded form of the readable response
+// reproduces the CopyData messages of the buffer byte for byte. The rows of a
+// COPY TO stream are opaque to the client, which may split or parse them at
+// any byte, so a mismatch of the same length is a mismatch as well.
+func copyDataRoundTrips(buffer, encoded []byte) bool {
+	for i := 0; i < len(buffer); {
+		bodyLen, err := readMessageBodyLen(buffer, i)
+		if err != nil {
+			return false
+		}
+		if buffer[i] == 'd' {
+			return bytes.Equal(buffer, encoded)
+		}
+		i += 5 + bodyLen
+	}
+	return true
+}
+
+// startsCopyIn reports whether the server responses hold a CopyInResponse,
+// after which the client streams the rows of a COPY FROM STDIN.
+func startsCopyIn(buffer []byte) bool {
+	return containsMessage(buffer, 'G')
+}
+
+func containsMessage(buffer []byte, msgType byte) bool {
+	msgs, ok := splitMessages(buffer)
+	if !ok {
+		return false
+	}
+	for _, msg := range msgs {
+		if msg[0] == msgType {
+			return true
+		}
+	}
+	return false
+}
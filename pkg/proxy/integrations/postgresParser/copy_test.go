@@ -0,0 +1,343 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// copyOutResponse returns the response of a COPY TO STDOUT streaming the
+// chunks.
+func copyOutResponse(format int8, chunks ...[]byte) []byte {
+	buffer := (&pgproto3.CopyOutResponse{OverallFormat: byte(format), ColumnFormatCodes: []uint16{uint16(format)}}).Encode(nil)
+	for _, chunk := range chunks {
+		buffer = (&pgproto3.CopyData{Data: chunk}).Encode(buffer)
+	}
+	buffer = (&pgproto3.CopyDone{}).Encode(buffer)
+	buffer = (&pgproto3.CommandComplete{CommandTag: []byte("COPY 2")}).Encode(buffer)
+	return (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(buffer)
+}
+
+// binaryCopyHeader is the header of a binary COPY stream, without flags nor
+// header extension.
+var binaryCopyHeader = append(append([]byte{}, binaryCopySignature...), 0, 0, 0, 0, 0, 0, 0, 0)
+
+func TestContainsBinaryCopyHeader(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		buffer []byte
+		want   bool
+	}{
+		{name: "binary", buffer: copyOutResponse(1, append(binaryCopyHeader, 0, 1, 0, 0, 0, 4, 0, 0, 0, 1)), want: true},
+		{name: "text", buffer: copyOutResponse(0, []byte("1\tkeploy\n"), []byte("2\tproxy\n"))},
+		{name: "signature in a later row", buffer: copyOutResponse(0, []byte("1\tkeploy\n"), binaryCopySignature), want: true},
+		{name: "truncated", buffer: copyOutResponse(1, binaryCopyHeader)[:10]},
+	} {
+		if got := containsBinaryCopyHeader(tt.buffer); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestCopyOutRecorded records the chunks of COPY TO STDOUT streams, which are
+// replayed byte for byte.
+func TestCopyOutRecorded(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		query   string
+		chunks  [][]byte
+		payload bool
+	}{
+		{name: "text", query: "COPY users TO STDOUT", chunks: [][]byte{[]byte("1\tkeploy\n"), []byte("2\tproxy\n")}},
+		{name: "binary", query: "COPY users TO STDOUT (FORMAT binary)", chunks: [][]byte{binaryCopyHeader, {0, 1, 0, 0, 0, 4, 0, 0, 0, 1}, {0, 1, 0, 0, 0, 4, 0, 0, 0, 2}, {0xff, 0xff}}, payload: true},
+	} {
+		format := int8(0)
+		if tt.payload {
+			format = 1
+		}
+		response := copyOutResponse(format, tt.chunks...)
+		mock := queryMock(t, recordSession(t, PostgresOptions{}, (&pgproto3.Query{String: tt.query}).Encode(nil), response), tt.query)
+
+		chunks := 0
+		payload := false
+		for _, recorded := range mock.Spec.PostgresResponses {
+			chunks += len(recorded.CopyDatas)
+			payload = payload || recorded.Payload != ""
+		}
+		if chunks != len(tt.chunks) {
+			t.Errorf("%s: recorded %d chunks, want %d", tt.name, chunks, len(tt.chunks))
+		}
+		if payload != tt.payload {
+			t.Errorf("%s: recorded the raw payload %v, want %v", tt.name, payload, tt.payload)
+		}
+		if replayed := replayedBytes(t, mock); !bytes.Equal(replayed, response) {
+			t.Errorf("%s: replayed %q, want %q", tt.name, replayed, response)
+		}
+	}
+}
+
+func TestCopyDataRoundTrips(t *testing.T) {
+	response := copyOutResponse(0, []byte("1\tkeploy\n"), []byte("2\tproxy\n"))
+	altered := copyOutResponse(0, []byte("1\tkeploy\n"), []byte("2\tPROXY\n"))
+	for _, tt := range []struct {
+		name            string
+		buffer, encoded []byte
+		want            bool
+	}{
+		{name: "identical rows", buffer: response, encoded: response, want: true},
+		{name: "rows of the same length", buffer: response, encoded: altered},
+		{name: "rows lost", buffer: response, encoded: response[:len(response)-6]},
+		{name: "no rows", buffer: rowsResponse(1), encoded: rowsResponse(2), want: true},
+		{name: "malformed", buffer: []byte{'d', 0, 0}, encoded: []byte{'d', 0, 0}},
+	} {
+		if got := copyDataRoundTrips(tt.buffer, tt.encoded); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestStartsCopyIn(t *testing.T) {
+	copyIn := encodeMessages(&pgproto3.CopyInResponse{ColumnFormatCodes: []uint16{0, 0}})
+	if !startsCopyIn(copyIn) {
+		t.Errorf("missed the CopyInResponse")
+	}
+	if startsCopyIn(copyOutResponse(0, []byte("1\tkeploy\n"))) || startsCopyIn(rowsResponse(1)) {
+		t.Errorf("started a COPY FROM STDIN on the responses of another query")
+	}
+}
+
+// copyInSession is a COPY FROM STDIN whose client streams the rows in chunks
+// split within the messages.
+type copyInSession struct {
+	query, started, ended []byte
+	chunks                [][]byte
+}
+
+func newCopyInSession() copyInSession {
+	stream := encodeMessages(
+		&pgproto3.CopyData{Data: []byte("1\tkeploy\n")},
+		&pgproto3.CopyData{Data: []byte("2\tproxy\n")},
+		&pgproto3.CopyData{Data: []byte("3\tpostgres\n")},
+		&pgproto3.CopyDone{},
+	)
+	return copyInSession{
+		query:   (&pgproto3.Query{String: "COPY users FROM STDIN"}).Encode(nil),
+		started: encodeMessages(&pgproto3.CopyInResponse{ColumnFormatCodes: []uint16{0, 0}}),
+		ended:   encodeMessages(&pgproto3.CommandComplete{CommandTag: []byte("COPY 3")}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		chunks:  [][]byte{stream[:10], stream[10:30], stream[30:]},
+	}
+}
+
+// stream writes the query and the rows of the COPY FROM STDIN to the
+// connection, reading the answers of the server, and returns the bytes read
+// once the stream is ended.
+func (s copyInSession) stream(conn net.Conn) ([]byte, error) {
+	if _, err := conn.Write(s.query); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, make([]byte, len(s.started))); err != nil {
+		return nil, err
+	}
+	for _, chunk := range s.chunks {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := conn.Write(chunk); err != nil {
+			return nil, err
+		}
+	}
+	ended := make([]byte, len(s.ended))
+	_, err := io.ReadFull(conn, ended)
+	return ended, err
+}
+
+// record records the session and returns the recorded mocks.
+func (s copyInSession) record(t *testing.T) []*models.Mock {
+	t.Helper()
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+
+	startup := startupRequest("")
+	started := encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		if _, err := io.ReadFull(server, make([]byte, len(startup))); err != nil {
+			return
+		}
+		server.Write(started)
+		if _, err := io.ReadFull(server, make([]byte, len(s.query))); err != nil {
+			return
+		}
+		server.Write(s.started)
+		if _, err := io.ReadFull(server, make([]byte, len(bytes.Join(s.chunks, nil)))); err != nil {
+			return
+		}
+		server.Write(s.ended)
+		io.Copy(io.Discard, server)
+	}()
+	go func() {
+		defer app.Close()
+		if _, err := io.ReadFull(app, make([]byte, len(started))); err != nil {
+			return
+		}
+		s.stream(app)
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		encodePostgresOutgoing(startup, clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the recording of the session did not end")
+	}
+	return db.mocks
+}
+
+// TestCopyInRecorded records the rows of a COPY FROM STDIN, however the client
+// splits them, as a single request keeping their order.
+func TestCopyInRecorded(t *testing.T) {
+	session := newCopyInSession()
+	mocks := session.record(t)
+
+	var stream *models.Backend
+	for _, mock := range mocks {
+		for i, request := range mock.Spec.PostgresRequests {
+			if len(request.CopyDatas) > 0 {
+				if stream != nil {
+					t.Fatalf("recorded the rows in several requests")
+				}
+				stream = &mock.Spec.PostgresRequests[i]
+			}
+		}
+	}
+	if stream == nil {
+		t.Fatalf("recorded no request of the rows")
+	}
+	var rows []string
+	for _, row := range stream.CopyDatas {
+		rows = append(rows, string(row.Data))
+	}
+	if len(rows) != 3 || rows[0] != "1\tkeploy\n" || rows[1] != "2\tproxy\n" || rows[2] != "3\tpostgres\n" {
+		t.Errorf("recorded the rows %q, want the three rows in order", rows)
+	}
+	encoded, err := PostgresDecoderBackend(*stream)
+	if err != nil {
+		t.Fatalf("failed to encode the recorded request: %v", err)
+	}
+	if want := bytes.Join(session.chunks, nil); !bytes.Equal(encoded, want) {
+		t.Errorf("encoded the request %q, want %q", encoded, want)
+	}
+}
+
+// TestBinaryCopyInResponseRecorded records the CopyInResponse of a binary
+// COPY FROM STDIN, whose format codes outlive the mock file.
+func TestBinaryCopyInResponseRecorded(t *testing.T) {
+	session := newCopyInSession()
+	session.started = encodeMessages(&pgproto3.CopyInResponse{OverallFormat: 1, ColumnFormatCodes: []uint16{1, 1}})
+	mocks := session.record(t)
+
+	var started *models.Frontend
+	for _, mock := range mocks {
+		for i, response := range mock.Spec.PostgresResponses {
+			if len(response.PacketTypes) > 0 && response.PacketTypes[0] == "G" {
+				started = &mock.Spec.PostgresResponses[i]
+			}
+		}
+	}
+	if started == nil {
+		t.Fatalf("recorded no CopyInResponse")
+	}
+	file, err := yaml.Marshal(started)
+	if err != nil {
+		t.Fatalf("failed to marshal the recorded response: %v", err)
+	}
+	var read models.Frontend
+	if err := yaml.Unmarshal(file, &read); err != nil {
+		t.Fatalf("failed to unmarshal the recorded response: %v", err)
+	}
+	encoded, err := PostgresDecoderFrontend(read)
+	if err != nil {
+		t.Fatalf("failed to encode the recorded response: %v", err)
+	}
+	if !bytes.Equal(encoded, session.started) {
+		t.Errorf("replayed %q, want %q", encoded, session.started)
+	}
+}
+
+// TestCopyInReplayed replays the recorded COPY FROM STDIN to a client which
+// splits its rows otherwise, and closes the connection whose stream outgrows
+// the limit of the pending requests.
+func TestCopyInReplayed(t *testing.T) {
+	session := newCopyInSession()
+	mocks := session.record(t)
+	// the client streams the rows in other chunks
+	stream := bytes.Join(session.chunks, nil)
+	session.chunks = [][]byte{stream[:3], stream[3:21], stream[21:40], stream[40:]}
+
+	for _, tt := range []struct {
+		name            string
+		maxPendingBytes int
+		wantErr         error
+	}{
+		{name: "replayed"},
+		{name: "outgrowing the limit", maxPendingBytes: 40, wantErr: errTooManyPendingRequests},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := hooks.NewHook(nil, 0, zap.NewNop())
+			if err != nil {
+				t.Fatalf("failed to create the hooks: %v", err)
+			}
+			h.SetConfigMocks(mocks)
+			p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{MaxPendingBytes: tt.maxPendingBytes})
+
+			client, proxied := net.Pipe()
+			defer client.Close()
+			done := make(chan error, 1)
+			go func() {
+				done <- decodePostgresOutgoing(startupRequest(""), proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+			}()
+			if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				t.Fatalf("failed to set the deadline: %v", err)
+			}
+			started := encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+			if _, err := io.ReadFull(client, make([]byte, len(started))); err != nil {
+				t.Fatalf("failed to read the replayed startup: %v", err)
+			}
+
+			if tt.wantErr != nil {
+				// the replay gives up before the end of the stream
+				go session.stream(client)
+				if err := <-done; !errors.Is(err, tt.wantErr) {
+					t.Errorf("got the error %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			ended, err := session.stream(client)
+			if err != nil {
+				t.Fatalf("failed to stream the rows: %v", err)
+			}
+			if !bytes.Equal(ended, session.ended) {
+				t.Errorf("replayed %q, want %q", ended, session.ended)
+			}
+			client.Close()
+			<-done
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package postgresparser
+
+import (
+	"net"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+)
+
+// hasCopyDataDelays reports whether the delays between the chunks of a COPY
+// OUT stream were recorded for the responses.
+func hasCopyDataDelays(responses []models.Frontend) bool {
+	for _, response := range responses {
+		if response.CopyDataDelay > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// writePacedResponses writes the wire bytes of each response once the delay
+// recorded before it has elapsed, so that the COPY OUT stream and the
+// notifications are replayed at the pace they were read from the server.
+func writePacedResponses(conn net.Conn, segments [][]byte, delays []time.Duration, chunkSize int) error {
+	for i, segment := range segments {
+		if i < len(delays) && delays[i] > 0 {
+			time.Sleep(delays[i])
+		}
+		err := util.WriteChunked(conn, segment, chunkSize)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
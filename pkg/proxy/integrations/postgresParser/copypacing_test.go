@@ -0,0 +1,138 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestHasCopyDataDelays(t *testing.T) {
+	if hasCopyDataDelays([]models.Frontend{{PacketTypes: []string{"H"}}, {PacketTypes: []string{"d"}}}) {
+		t.Errorf("reported the delays of responses recorded without any")
+	}
+	if !hasCopyDataDelays([]models.Frontend{{PacketTypes: []string{"H"}}, {PacketTypes: []string{"d"}, CopyDataDelay: time.Millisecond}}) {
+		t.Errorf("missed the delay of the second response")
+	}
+}
+
+func TestWritePacedResponses(t *testing.T) {
+	client, proxied := net.Pipe()
+	defer client.Close()
+	segments := [][]byte{[]byte("header"), []byte("first chunk"), []byte("second chunk")}
+	delays := []time.Duration{0, 100 * time.Millisecond, 0}
+
+	written := make(chan error, 1)
+	go func() {
+		written <- writePacedResponses(proxied, segments, delays, 4)
+		proxied.Close()
+	}()
+	start := time.Now()
+	first := make([]byte, len(segments[0]))
+	if _, err := io.ReadFull(client, first); err != nil {
+		t.Fatalf("failed to read the first response: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 100*time.Millisecond {
+		t.Errorf("wrote the first response after %v, want it at once", elapsed)
+	}
+	rest, err := io.ReadAll(client)
+	if err != nil {
+		t.Fatalf("failed to read the responses: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("wrote the responses within %v, want the recorded delay", elapsed)
+	}
+	if got, want := append(first, rest...), bytes.Join(segments, nil); !bytes.Equal(got, want) {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+	if err := <-written; err != nil {
+		t.Errorf("failed to write the responses: %v", err)
+	}
+}
+
+// TestCopyDataDelaysRecorded records a COPY OUT stream whose chunks the server
+// sends apart, with the delay each of them was read after.
+func TestCopyDataDelaysRecorded(t *testing.T) {
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+
+	startup := startupRequest("")
+	started := encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	query := (&pgproto3.Query{String: "COPY users TO STDOUT"}).Encode(nil)
+	pause := 150 * time.Millisecond
+	chunks := [][]byte{
+		encodeMessages(&pgproto3.CopyOutResponse{ColumnFormatCodes: []uint16{0}}),
+		encodeMessages(&pgproto3.CopyData{Data: []byte("1\tkeploy\n")}),
+		encodeMessages(&pgproto3.CopyData{Data: []byte("2\tproxy\n")}, &pgproto3.CopyDone{}, &pgproto3.CommandComplete{CommandTag: []byte("COPY 2")}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+	}
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		if _, err := io.ReadFull(server, make([]byte, len(startup))); err != nil {
+			return
+		}
+		server.Write(started)
+		if _, err := io.ReadFull(server, make([]byte, len(query))); err != nil {
+			return
+		}
+		for i, chunk := range chunks {
+			if i > 0 {
+				time.Sleep(pause)
+			}
+			if _, err := server.Write(chunk); err != nil {
+				return
+			}
+		}
+		io.Copy(io.Discard, server)
+	}()
+	go func() {
+		defer app.Close()
+		if _, err := io.ReadFull(app, make([]byte, len(started))); err != nil {
+			return
+		}
+		app.Write(query)
+		io.ReadFull(app, make([]byte, len(bytes.Join(chunks, nil))))
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		encodePostgresOutgoing(startup, clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the recording of the session did not end")
+	}
+
+	mock := queryMock(t, db.mocks, "COPY users TO STDOUT")
+	var delays []time.Duration
+	for _, response := range mock.Spec.PostgresResponses {
+		if len(response.CopyDatas) > 0 {
+			delays = append(delays, response.CopyDataDelay)
+		}
+	}
+	if len(delays) != 2 {
+		t.Fatalf("recorded the chunks in %d responses, want 2", len(delays))
+	}
+	for i, delay := range delays {
+		if delay < pause*2/3 || delay > pause*10 {
+			t.Errorf("recorded the delay %v before the chunk %d, want about %v", delay, i, pause)
+		}
+	}
+	if replayed := replayedBytes(t, mock); !bytes.Equal(replayed, bytes.Join(chunks, nil)) {
+		t.Errorf("replayed %q, want %q", replayed, bytes.Join(chunks, nil))
+	}
+}
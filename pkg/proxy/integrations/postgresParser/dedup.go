@@ -0,0 +1,49 @@
+package postgresparser
+
+import (
+	"sync"
+)
+
+// mockDigests are the content hashes of the last mock recorded for each of
+// the requests by all the connections of a recording session, so that an
+// exchange repeated identically right after itself, such as the handshake of
+// every pooled connection or a polled query whose result didn't change, is
+// not appended again. The sessions are told apart by the test-set the mocks
+// are recorded into.
+type mockDigests struct {
+	mutex   sync.Mutex
+	session string
+	last    map[string]string
+}
+
+func newMockDigests() *mockDigests {
+	return &mockDigests{last: make(map[string]string)}
+}
+
+// add records the content hash of a mock recorded for the requests. It
+// reports false when the last mock recorded for the same requests in the
+// session has the same content. The mocks which couldn't be hashed are always
+// recorded.
+func (d *mockDigests) add(session, requests, hash string) bool {
+	if hash == "" || requests == "" {
+		return true
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if session != d.session {
+		d.session = session
+		d.last = make(map[string]string)
+	}
+	if d.last[requests] == hash {
+		return false
+	}
+	d.last[requests] = hash
+	return true
+}
+
+// reset forgets the recorded mocks, for a new recording session.
+func (d *mockDigests) reset() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.last = make(map[string]string)
+}
@@ -0,0 +1,101 @@
+package postgresparser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.uber.org/zap"
+)
+
+func TestMockDigests(t *testing.T) {
+	digests := newMockDigests()
+	for _, tt := range []struct {
+		session, requests, hash string
+		want                    bool
+	}{
+		{session: "test-set-0", requests: "polled", hash: "pending", want: true},
+		{session: "test-set-0", requests: "polled", hash: "pending", want: false},
+		{session: "test-set-0", requests: "other", hash: "pending", want: true},
+		{session: "test-set-0", requests: "polled", hash: "pending", want: false},
+		{session: "test-set-0", requests: "polled", hash: "done", want: true},
+		// only the duplicates right after one another are left out
+		{session: "test-set-0", requests: "polled", hash: "pending", want: true},
+		// a new test-set records its mocks afresh
+		{session: "test-set-1", requests: "polled", hash: "pending", want: true},
+		// the mocks which couldn't be hashed are always recorded
+		{session: "test-set-1", requests: "polled", hash: "", want: true},
+		{session: "test-set-1", requests: "", hash: "pending", want: true},
+		{session: "test-set-1", requests: "", hash: "pending", want: true},
+	} {
+		if got := digests.add(tt.session, tt.requests, tt.hash); got != tt.want {
+			t.Errorf("added %s of %q in %s: got %v, want %v", tt.hash, tt.requests, tt.session, got, tt.want)
+		}
+	}
+	digests.reset()
+	if !digests.add("test-set-1", "polled", "pending") {
+		t.Errorf("left out the mock recorded before the reset")
+	}
+}
+
+// TestDeduplicateMocks records a polled query whose result changes, and
+// checks the repeated results are recorded once per change, numbered so that
+// the replay moves on to the next change in order.
+func TestDeduplicateMocks(t *testing.T) {
+	polled := SimpleQueryRequest("SELECT id FROM jobs")[0]
+	exchanges := []pgExchange{
+		{request: polled, response: rowsResponse(1)},
+		{request: polled, response: rowsResponse(1)},
+		{request: polled, response: rowsResponse(1)},
+		{request: polled, response: rowsResponse(2)},
+		{request: polled, response: rowsResponse(1)},
+	}
+	for _, tt := range []struct {
+		name        string
+		dedup       bool
+		occurrences []string
+	}{
+		{name: "every exchange", occurrences: []string{"", "2", "3", "4", "5"}},
+		{name: "deduplicated", dedup: true, occurrences: []string{"", "4", "5"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			mocks := recordExchanges(t, PostgresOptions{DeduplicateMocks: tt.dedup}, exchanges...)
+			var occurrences []string
+			for _, mock := range mocks {
+				if len(mock.Spec.PostgresRequests) > 0 && mock.Spec.PostgresRequests[0].Query.String == "SELECT id FROM jobs" {
+					occurrences = append(occurrences, mock.Spec.Metadata[occurrenceMetadata])
+				}
+			}
+			if !reflect.DeepEqual(occurrences, tt.occurrences) {
+				t.Errorf("recorded the occurrences %q, want %q", occurrences, tt.occurrences)
+			}
+		})
+	}
+}
+
+// TestDeduplicatedMocksReplayedInSequence records a polled query answered
+// twice with the same result before it changes, and checks the result left
+// out as a duplicate is replayed in its place.
+func TestDeduplicatedMocksReplayedInSequence(t *testing.T) {
+	polled := SimpleQueryRequest("SELECT id FROM jobs")[0]
+	pending, done := rowsResponse(1), rowsResponse(2)
+	mocks := recordExchanges(t, PostgresOptions{DeduplicateMocks: true},
+		pgExchange{request: polled, response: pending},
+		pgExchange{request: polled, response: pending},
+		pgExchange{request: polled, response: done},
+	)
+
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetConfigMocks(mocks)
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+	// the final state keeps being replayed
+	for i, want := range [][]byte{pending, pending, done, done} {
+		if replayed := replayScenario(t, p, polled, len(want)); !bytes.Equal(replayed, want) {
+			t.Errorf("replay %d: replayed %q, want %q", i+1, replayed, want)
+		}
+	}
+}
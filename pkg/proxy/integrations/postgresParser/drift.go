@@ -0,0 +1,176 @@
+package postgresparser
+
+import (
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/utils"
+	"go.uber.org/zap"
+)
+
+// Drift describes an exchange passed through to the database whose live
+// responses diverged from the responses recorded for the same requests, or
+// whose requests matched none of the recorded mocks.
+type Drift struct {
+	Requests [][]byte
+	// Recorded are the responses of the matched mock, nil when no mock
+	// matched the requests.
+	Recorded []byte
+	// Live are the responses of the database.
+	Live []byte
+}
+
+// Matched reports whether the requests matched one of the recorded mocks.
+func (d *Drift) Matched() bool {
+	return d.Recorded != nil
+}
+
+func reportDrift(drift Drift, logger *zap.Logger, reporter func(Drift)) {
+	if drift.Matched() {
+		logger.Warn("the live postgres responses diverged from the recorded ones, the database may have drifted since the recording", zap.Int("recorded", len(drift.Recorded)), zap.Int("live", len(drift.Live)))
+	} else {
+		logger.Warn("the live postgres requests matched none of the recorded mocks, the queries may have drifted since the recording")
+	}
+	reporter(drift)
+}
+
+// driftQueueSize is the number of exchanges of a connection waiting to be
+// compared with the mocks, beyond which they are left out.
+const driftQueueSize = 64
+
+// driftExchange is an exchange passed through in record mode, along with the
+// state of its connection.
+type driftExchange struct {
+	requests  []models.Backend
+	responses []models.Frontend
+	conn      connectionState
+}
+
+// driftDetector compares the exchanges of a connection with the drift mocks
+// in the background, so that the detection never delays the traffic. The
+// exchanges arriving while its queue is full are left out.
+type driftDetector struct {
+	exchanges chan driftExchange
+	logger    *zap.Logger
+}
+
+// newDriftDetector starts the detection of the drift of a connection, nil
+// when it is off.
+func newDriftDetector(opts PostgresOptions, h *hooks.Hook, logger *zap.Logger) *driftDetector {
+	if opts.DriftReporter == nil || len(opts.DriftMocks) == 0 {
+		return nil
+	}
+	d := &driftDetector{exchanges: make(chan driftExchange, driftQueueSize), logger: logger}
+	go func() {
+		// Recover from panic and gracefully shutdown
+		defer h.Recover(pkg.GenerateRandomID())
+		defer utils.HandlePanic()
+		for exchange := range d.exchanges {
+			detectDrift(exchange.requests, exchange.responses, exchange.conn, logger, opts)
+		}
+	}()
+	return d
+}
+
+// observe queues the exchange to be compared with the mocks. The exchange
+// and the state of the connection are copied, as the connection goes on
+// meanwhile.
+func (d *driftDetector) observe(requests []models.Backend, responses []models.Frontend, conn connectionState) {
+	if d == nil {
+		return
+	}
+	requests = append([]models.Backend(nil), requests...)
+	responses = append([]models.Frontend(nil), responses...)
+	conn.statements = conn.statements.clone()
+	select {
+	case d.exchanges <- driftExchange{requests: requests, responses: responses, conn: conn}:
+	default:
+		d.logger.Debug("left out the postgres exchange from the drift detection, too many exchanges are waiting to be compared")
+	}
+}
+
+// stop ends the detection once the queued exchanges are compared.
+func (d *driftDetector) stop() {
+	if d == nil {
+		return
+	}
+	close(d.exchanges)
+}
+
+// detectDrift compares the responses of an exchange passed through in record
+// mode with those of the drift mock its requests match, the same way they
+// are matched in test mode. The exchanges of the startup and the
+// authentication, which differ on every connection, are left out. The
+// traffic, the recording and the mocks are not altered.
+func detectDrift(pgRequests []models.Backend, pgResponses []models.Frontend, conn connectionState, logger *zap.Logger, opts PostgresOptions) {
+	if len(pgRequests) == 0 || len(pgResponses) == 0 {
+		return
+	}
+	requestBuffers := make([][]byte, 0, len(pgRequests))
+	for _, request := range pgRequests {
+		if request.Identfier == "StartupRequest" {
+			return
+		}
+		buffer, err := BackendWireBytes(request)
+		if err != nil || len(buffer) == 0 {
+			logger.Debug("failed to encode the postgres request to detect the drift", zap.Error(err))
+			return
+		}
+		if isStartupPacket(buffer) || buffer[0] == 'p' {
+			return
+		}
+		requestBuffers = append(requestBuffers, buffer)
+	}
+	live, err := responsesWireBytes(pgResponses)
+	if err != nil {
+		logger.Debug("failed to encode the live postgres responses to detect the drift", zap.Error(err))
+		return
+	}
+
+	recordedResponses, matched := findDriftMatch(requestBuffers, conn, opts)
+	if !matched {
+		reportDrift(Drift{Requests: requestBuffers, Live: live}, logger, opts.DriftReporter)
+		return
+	}
+	recorded, err := responsesWireBytes(recordedResponses)
+	if err != nil {
+		logger.Debug("failed to encode the recorded postgres responses to detect the drift", zap.Error(err))
+		return
+	}
+	if responsesWithinTolerance(recorded, live, opts.StalenessTolerance, opts.IgnoredFields) {
+		return
+	}
+	reportDrift(Drift{Requests: requestBuffers, Recorded: recorded, Live: live}, logger, opts.DriftReporter)
+}
+
+// findDriftMatch returns the responses of the first drift mock whose requests
+// are the same as the request buffers, through the stages of the match mode.
+// Unlike the matching of test mode, it only reads the mocks: none of them is
+// consumed or reordered.
+func findDriftMatch(requestBuffers [][]byte, conn connectionState, opts PostgresOptions) ([]models.Frontend, bool) {
+	mocks := filterDisabledMocks(opts.DriftMocks)
+	mocks = filterMocksBySearchPath(mocks, conn.searchPath)
+	mocks = filterMocksByStartupOptions(mocks, conn.startupOptions)
+	mocks = filterMocksByPortal(mocks, conn.portalQuery)
+	mocks = filterMocksByStatements(mocks, requestBuffers, conn.statements)
+	for _, mode := range opts.MatchMode.stages() {
+		if matches := findExactMatches(mocks, requestBuffers, opts.requestNoise(conn.statements), mode); len(matches) > 0 {
+			return mocks[matches[0]].Spec.PostgresResponses, true
+		}
+	}
+	return nil, false
+}
+
+// responsesWireBytes returns the wire bytes of the responses of an exchange,
+// as they are written to the client.
+func responsesWireBytes(responses []models.Frontend) ([]byte, error) {
+	wire := []byte{}
+	for _, response := range responses {
+		encoded, err := FrontendWireBytes(response)
+		if err != nil {
+			return nil, err
+		}
+		wire = append(wire, encoded...)
+	}
+	return wire, nil
+}
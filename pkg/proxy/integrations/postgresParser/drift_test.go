@@ -0,0 +1,129 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func balanceQuery(id string) []byte {
+	return (&pgproto3.Query{String: "SELECT balance FROM accounts WHERE id = " + id}).Encode(nil)
+}
+
+func balanceMock(id, balance string) *models.Mock {
+	return &models.Mock{
+		Name: "mock-" + id,
+		Kind: models.Postgres,
+		Spec: models.MockSpec{
+			PostgresRequests:  []models.Backend{{Payload: base64.StdEncoding.EncodeToString(balanceQuery(id))}},
+			PostgresResponses: []models.Frontend{{Payload: base64.StdEncoding.EncodeToString(balanceResponse(balance))}},
+		},
+	}
+}
+
+// driftOptions returns the options detecting the drift from the mocks, with
+// the drifts sent to the channel.
+func driftOptions(drifts chan Drift, mocks ...*models.Mock) PostgresOptions {
+	opts := PostgresOptions{DriftMocks: mocks, DriftReporter: func(drift Drift) { drifts <- drift }}
+	return NewPostgresParserWithOptions(zap.NewNop(), nil, opts).opts
+}
+
+func liveExchange(id, balance string) ([]models.Backend, []models.Frontend) {
+	return []models.Backend{{Payload: base64.StdEncoding.EncodeToString(balanceQuery(id))}},
+		[]models.Frontend{{Payload: base64.StdEncoding.EncodeToString(balanceResponse(balance))}}
+}
+
+func TestDetectDrift(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		id, balance string
+		drifted     bool
+		matched     bool
+	}{
+		{name: "same responses", id: "1", balance: "100.50"},
+		{name: "drifted responses", id: "1", balance: "99.00", drifted: true, matched: true},
+		{name: "drifted requests", id: "2", balance: "100.50", drifted: true},
+	} {
+		drifts := make(chan Drift, 1)
+		opts := driftOptions(drifts, balanceMock("1", "100.50"))
+		requests, responses := liveExchange(tt.id, tt.balance)
+		detectDrift(requests, responses, connectionState{}, zap.NewNop(), opts)
+
+		select {
+		case drift := <-drifts:
+			if !tt.drifted {
+				t.Fatalf("%s: reported the drift %+v, want none", tt.name, drift)
+			}
+			if drift.Matched() != tt.matched {
+				t.Errorf("%s: got the drift matched %v, want %v", tt.name, drift.Matched(), tt.matched)
+			}
+			if !bytes.Equal(drift.Live, balanceResponse(tt.balance)) {
+				t.Errorf("%s: got the live responses %q, want the ones of the database", tt.name, drift.Live)
+			}
+			if tt.matched && !bytes.Equal(drift.Recorded, balanceResponse("100.50")) {
+				t.Errorf("%s: got the recorded responses %q, want the ones of the mock", tt.name, drift.Recorded)
+			}
+			if len(drift.Requests) != 1 || !bytes.Equal(drift.Requests[0], balanceQuery(tt.id)) {
+				t.Errorf("%s: got the requests %q, want the query", tt.name, drift.Requests)
+			}
+		default:
+			if tt.drifted {
+				t.Fatalf("%s: reported no drift", tt.name)
+			}
+		}
+	}
+}
+
+func TestDetectDriftLeavesTheStartupOut(t *testing.T) {
+	drifts := make(chan Drift, 1)
+	opts := driftOptions(drifts, balanceMock("1", "100.50"))
+	startup := (&pgproto3.StartupMessage{ProtocolVersion: pgproto3.ProtocolVersionNumber, Parameters: map[string]string{"user": "keploy"}}).Encode(nil)
+	requests := []models.Backend{{Payload: base64.StdEncoding.EncodeToString(startup)}}
+	responses := []models.Frontend{{Payload: base64.StdEncoding.EncodeToString((&pgproto3.AuthenticationOk{}).Encode(nil))}}
+	detectDrift(requests, responses, connectionState{}, zap.NewNop(), opts)
+	select {
+	case drift := <-drifts:
+		t.Fatalf("reported the drift %+v of the startup", drift)
+	default:
+	}
+}
+
+func TestDriftDetectorComparesInTheBackground(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	if d := newDriftDetector(PostgresOptions{}, h, zap.NewNop()); d != nil {
+		t.Fatalf("started the drift detection without drift mocks")
+	}
+
+	drifts := make(chan Drift, driftQueueSize)
+	d := newDriftDetector(driftOptions(drifts, balanceMock("1", "100.50")), h, zap.NewNop())
+	for _, balance := range []string{"100.50", "98.00", "100.50", "97.00"} {
+		requests, responses := liveExchange("1", balance)
+		d.observe(requests, responses, connectionState{})
+	}
+	d.stop()
+
+	for _, want := range []string{"98.00", "97.00"} {
+		select {
+		case drift := <-drifts:
+			if !bytes.Equal(drift.Live, balanceResponse(want)) {
+				t.Errorf("got the drift of %q, want the balance %s", drift.Live, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("reported no drift of the balance %s", want)
+		}
+	}
+	select {
+	case drift := <-drifts:
+		t.Errorf("reported the drift %+v of the same responses", drift)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,154 @@
+package postgresparser
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// MismatchCandidate is a mock recorded with requests similar to the ones
+// which matched no mock, along with the differences of its requests.
+type MismatchCandidate struct {
+	MockName   string
+	Similarity float64
+	Diff       []string
+}
+
+// MatchExplanation explains why requests matched no recorded mock: it holds
+// the requests in their normalized form and the most similar mocks.
+type MatchExplanation struct {
+	Requests   []string
+	Candidates []MismatchCandidate
+}
+
+// String returns the explanation in a readable form, one line per message
+// and per difference.
+func (e *MatchExplanation) String() string {
+	var b strings.Builder
+	b.WriteString("requests:\n")
+	for _, request := range e.Requests {
+		fmt.Fprintf(&b, "  %s\n", request)
+	}
+	if len(e.Candidates) == 0 {
+		b.WriteString("no mock was recorded with as many requests\n")
+	}
+	for _, candidate := range e.Candidates {
+		fmt.Fprintf(&b, "candidate %s (similarity %.2f):\n", candidate.MockName, candidate.Similarity)
+		for _, diff := range candidate.Diff {
+			fmt.Fprintf(&b, "  %s\n", diff)
+		}
+	}
+	return b.String()
+}
+
+// ExplainMismatchFromTestSet explains why the request buffers match none of
+// the postgres mocks of the test-set, see ExplainMismatch.
+func ExplainMismatchFromTestSet(db platform.TestCaseDB, testSet string, requestBuffers [][]byte, top int) (*MatchExplanation, error) {
+	read, err := db.ReadConfigMocks(testSet)
+	if err != nil {
+		return nil, err
+	}
+	mocks := make([]*models.Mock, 0, len(read))
+	for _, kind := range read {
+		if mock, ok := kind.(*models.Mock); ok && mock.Kind == models.Postgres {
+			mocks = append(mocks, mock)
+		}
+	}
+	return ExplainMismatch(filterDisabledMocks(mocks), requestBuffers, top), nil
+}
+
+// ExplainMismatch explains why the request buffers match none of the mocks.
+// It returns the normalized requests and the top mocks recorded with as many
+// requests, ranked by their similarity, with the messages and the fields,
+// such as the bind parameters, which differ.
+func ExplainMismatch(mocks []*models.Mock, requestBuffers [][]byte, top int) *MatchExplanation {
+	explanation := &MatchExplanation{Requests: []string{}, Candidates: []MismatchCandidate{}}
+	for i, buffer := range requestBuffers {
+		msgs, ok := splitMessages(normalizeQueries(buffer))
+		if !ok || isStartupPacket(buffer) {
+			explanation.Requests = append(explanation.Requests, fmt.Sprintf("request %d: %d bytes", i, len(buffer)))
+			continue
+		}
+		for j, msg := range msgs {
+			explanation.Requests = append(explanation.Requests, fmt.Sprintf("request %d message %d: %s", i, j, describeMessage(msg)))
+		}
+	}
+
+	for _, mock := range mocks {
+		if mock == nil || len(mock.Spec.PostgresRequests) != len(requestBuffers) {
+			continue
+		}
+		similarity := 0.0
+		for i, buffer := range requestBuffers {
+			recorded, err := BackendWireBytes(mock.Spec.PostgresRequests[i])
+			if err != nil || len(recorded) == 0 {
+				continue
+			}
+			similarity += FuzzyCheck(normalizeQueries(recorded), normalizeQueries(buffer))
+		}
+		if len(requestBuffers) > 0 {
+			similarity /= float64(len(requestBuffers))
+		}
+		explanation.Candidates = append(explanation.Candidates, MismatchCandidate{MockName: mock.Name, Similarity: similarity})
+	}
+	sort.SliceStable(explanation.Candidates, func(i, j int) bool {
+		return explanation.Candidates[i].Similarity > explanation.Candidates[j].Similarity
+	})
+	if top > 0 && len(explanation.Candidates) > top {
+		explanation.Candidates = explanation.Candidates[:top]
+	}
+	byName := make(map[string]*models.Mock, len(mocks))
+	for _, mock := range mocks {
+		if mock != nil {
+			byName[mock.Name] = mock
+		}
+	}
+	for i, candidate := range explanation.Candidates {
+		explanation.Candidates[i].Diff = diffRequests(byName[candidate.MockName].Spec.PostgresRequests, requestBuffers)
+	}
+	return explanation
+}
+
+// logMismatch logs why the requests matched none of the mocks.
+func logMismatch(mocks []*models.Mock, requestBuffers [][]byte, logger *zap.Logger) {
+	if !logger.Core().Enabled(zap.DebugLevel) {
+		return
+	}
+	logger.Debug("the postgres requests matched no mock\n" + ExplainMismatch(filterDisabledMocks(mocks), requestBuffers, 3).String())
+}
+
+// diffMessage describes the fields of a message which differ from the
+// recorded one: the statement of a Query or a Parse and the parameters of a
+// Bind.
+func diffMessage(recorded, got []byte) string {
+	if recorded[0] == 'B' && got[0] == 'B' {
+		want, have := &pgproto3.Bind{}, &pgproto3.Bind{}
+		if want.Decode(recorded[5:]) == nil && have.Decode(got[5:]) == nil {
+			diffs := []string{}
+			if want.PreparedStatement != have.PreparedStatement {
+				diffs = append(diffs, fmt.Sprintf("statement recorded %q, got %q", want.PreparedStatement, have.PreparedStatement))
+			}
+			if len(want.Parameters) != len(have.Parameters) {
+				diffs = append(diffs, fmt.Sprintf("recorded %d parameters, got %d", len(want.Parameters), len(have.Parameters)))
+			}
+			for i := 0; i < len(want.Parameters) && i < len(have.Parameters); i++ {
+				if !bytes.Equal(want.Parameters[i], have.Parameters[i]) || (want.Parameters[i] == nil) != (have.Parameters[i] == nil) {
+					diffs = append(diffs, fmt.Sprintf("parameter $%d recorded %q, got %q", i+1, want.Parameters[i], have.Parameters[i]))
+				}
+			}
+			if !sameResultFormats(want.ResultFormatCodes, have.ResultFormatCodes) {
+				diffs = append(diffs, fmt.Sprintf("result formats recorded %v, got %v", want.ResultFormatCodes, have.ResultFormatCodes))
+			}
+			if len(diffs) > 0 {
+				return "Bind " + strings.Join(diffs, ", ")
+			}
+		}
+	}
+	return fmt.Sprintf("recorded %s, got %s", describeMessage(recorded), describeMessage(got))
+}
@@ -0,0 +1,92 @@
+package postgresparser
+
+import (
+	"strings"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestExplainMismatchPinpointsTheBindParameter(t *testing.T) {
+	statement := "SELECT name FROM users WHERE id = $1"
+	mocks := []*models.Mock{
+		recordedMock("mock-0", SimpleQueryRequest("SELECT name FROM users WHERE id = 1")[0]),
+		recordedMock("mock-1", extendedQueryRequest(statement, "42", "active")),
+		recordedMock("mock-2", extendedQueryRequest("SELECT email FROM accounts WHERE owner = $1", "7", "closed")),
+		// recorded with another number of requests
+		recordedMock("mock-3", extendedQueryRequest(statement, "42", "active"), SimpleQueryRequest("COMMIT")[0]),
+	}
+
+	explanation := ExplainMismatch(mocks, [][]byte{extendedQueryRequest(statement, "43", "active")}, 2)
+	if len(explanation.Candidates) != 2 {
+		t.Fatalf("got %d candidates, want the top 2", len(explanation.Candidates))
+	}
+	closest := explanation.Candidates[0]
+	if closest.MockName != "mock-1" {
+		t.Fatalf("got the closest mock %s, want mock-1", closest.MockName)
+	}
+	want := `request 0 message 1: Bind parameter $1 recorded "42", got "43"`
+	if len(closest.Diff) != 1 || closest.Diff[0] != want {
+		t.Errorf("got the diff %q, want %q", closest.Diff, want)
+	}
+	for _, candidate := range explanation.Candidates {
+		if candidate.MockName == "mock-3" {
+			t.Errorf("ranked the mock recorded with another number of requests")
+		}
+	}
+	if got := explanation.Requests[1]; got != `request 0 message 1: Bind ["43" "active"]` {
+		t.Errorf("got the request %q, want the Bind of its parameters", got)
+	}
+	if !strings.Contains(explanation.String(), "candidate mock-1 (similarity ") || !strings.Contains(explanation.String(), "  "+want+"\n") {
+		t.Errorf("got the explanation\n%s\nwant the candidate and its diff", explanation)
+	}
+}
+
+func TestExplainMismatchFromTestSet(t *testing.T) {
+	explanation, err := ExplainMismatchFromTestSet(validationDB(), "test-set-0", [][]byte{SimpleQueryRequest("DELETE FROM users")[0]}, 0)
+	if err != nil {
+		t.Fatalf("failed to explain the mismatch: %v", err)
+	}
+	got := []string{}
+	for _, candidate := range explanation.Candidates {
+		got = append(got, candidate.MockName)
+	}
+	// the disabled and the HTTP mocks are left out, the rest ranked by
+	// their similarity
+	if strings.Join(got, ",") != "mock-0,mock-1" {
+		t.Errorf("got the candidates %v, want mock-0 and mock-1", got)
+	}
+	if explanation.Candidates[0].Similarity < explanation.Candidates[1].Similarity {
+		t.Errorf("got the candidates ranked %v, want the most similar first", explanation.Candidates)
+	}
+
+	explanation, err = ExplainMismatchFromTestSet(validationDB(), "test-set-1", [][]byte{SimpleQueryRequest("DELETE FROM users")[0]}, 0)
+	if err != nil {
+		t.Fatalf("failed to explain the mismatch: %v", err)
+	}
+	if len(explanation.Candidates) != 0 || !strings.Contains(explanation.String(), "no mock was recorded with as many requests\n") {
+		t.Errorf("got the explanation\n%s\nwant no candidate", explanation)
+	}
+}
+
+func TestLogMismatch(t *testing.T) {
+	mocks := []*models.Mock{recordedMock("mock-0", SimpleQueryRequest("SELECT name FROM users WHERE id = 1")[0])}
+	requests := [][]byte{SimpleQueryRequest("SELECT name FROM users WHERE id = 2")[0]}
+	for _, level := range []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel} {
+		core, logs := observer.New(level)
+		logMismatch(mocks, requests, zap.New(core))
+		entries := logs.FilterMessageSnippet("the postgres requests matched no mock").All()
+		if level != zapcore.DebugLevel {
+			if len(entries) != 0 {
+				t.Errorf("logged the mismatch at the %s level", level)
+			}
+			continue
+		}
+		if len(entries) != 1 || !strings.Contains(entries[0].Message, "candidate mock-0 (similarity ") {
+			t.Errorf("got the logs %v, want the explanation of the mismatch", logs.All())
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package postgresparser
+
+import (
+	"github.com/jackc/pgproto3/v2"
+)
+
+// hasFatalError reports whether the response carries an ErrorResponse of
+// FATAL or PANIC severity, after which the server closes the connection. This
+// is how a failed authentication ends the startup.
+func hasFatalError(response []byte) bool {
+	for i := 0; i < len(response); {
+		bodyLen, err := readMessageBodyLen(response, i)
+		if err != nil {
+			return false
+		}
+		if response[i] == 'E' {
+			errorResponse := &pgproto3.ErrorResponse{}
+			if errorResponse.Decode(response[i+5:i+5+bodyLen]) == nil {
+				// the servers before 9.6 only send the localized severity
+				severity := errorResponse.SeverityUnlocalized
+				if severity == "" {
+					severity = errorResponse.Severity
+				}
+				switch severity {
+				case "FATAL", "PANIC":
+					return true
+				}
+			}
+		}
+		i += 5 + bodyLen
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestHasFatalError(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		response []byte
+		want     bool
+	}{
+		{
+			name:     "failed authentication",
+			response: encodeMessages(&pgproto3.ErrorResponse{Severity: "FATAL", SeverityUnlocalized: "FATAL", Code: "28P01", Message: `password authentication failed for user "app"`}),
+			want:     true,
+		},
+		{
+			name:     "localized severity only",
+			response: encodeMessages(&pgproto3.ErrorResponse{Severity: "PANIC", Code: "XX000", Message: "could not write to file"}),
+			want:     true,
+		},
+		{
+			name:     "unlocalized severity preferred",
+			response: encodeMessages(&pgproto3.ErrorResponse{Severity: "FATAL", SeverityUnlocalized: "ERROR", Code: "42P01", Message: `relation "users" does not exist`}),
+		},
+		{
+			name:     "query error",
+			response: encodeMessages(&pgproto3.ErrorResponse{Severity: "ERROR", SeverityUnlocalized: "ERROR", Code: "42P01", Message: `relation "users" does not exist`}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		},
+		{
+			name:     "rows",
+			response: rowsResponse(2),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasFatalError(tt.response); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFatalStartupReplayClosesTheConnection(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	startup := startupWith(map[string]string{"user": "app", "database": "orders"})
+	failure := encodeMessages(&pgproto3.ErrorResponse{Severity: "FATAL", SeverityUnlocalized: "FATAL", Code: "28P01", Message: `password authentication failed for user "app"`})
+	h.SetConfigMocks([]*models.Mock{{
+		Name: "mock-0",
+		Kind: models.Postgres,
+		Spec: models.MockSpec{
+			PostgresRequests:  []models.Backend{{Identfier: "StartupRequest", Payload: base64.StdEncoding.EncodeToString(startup)}},
+			PostgresResponses: []models.Frontend{{Payload: base64.StdEncoding.EncodeToString(failure)}},
+		},
+	}})
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+
+	client, proxied := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- decodePostgresOutgoing(startup, proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+	}()
+
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set the read deadline: %v", err)
+	}
+	replayed := make([]byte, len(failure))
+	if _, err := io.ReadFull(client, replayed); err != nil {
+		t.Fatalf("failed to read the replayed failure: %v", err)
+	}
+	if !bytes.Equal(replayed, failure) {
+		t.Fatalf("replayed %q, want the recorded failure %q", replayed, failure)
+	}
+	// the connection is closed without waiting for the client to leave
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("failed to replay the failed startup: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("kept the connection open after the fatal error")
+	}
+}
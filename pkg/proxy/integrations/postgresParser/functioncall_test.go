@@ -0,0 +1,97 @@
+package postgresparser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestFunctionCallResponseDecode(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		body    []byte
+		want    []byte
+		null    bool
+		invalid bool
+	}{
+		{name: "null", body: []byte{0xff, 0xff, 0xff, 0xff}, null: true},
+		{name: "empty", body: []byte{0, 0, 0, 0}, want: []byte{}},
+		{name: "value", body: []byte{0, 0, 0, 2, 'o', 'k'}, want: []byte("ok")},
+		{name: "truncated value", body: []byte{0, 0, 0, 3, 'o', 'k'}, invalid: true},
+		{name: "negative length", body: []byte{0xff, 0xff, 0xff, 0xfe}, invalid: true},
+		{name: "no length", body: []byte{0, 0}, invalid: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			response := &pgproto3.FunctionCallResponse{Result: []byte("stale")}
+			err := functionCallResponse{response}.Decode(tt.body)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("decoded %q, want an error", response.Result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			if (response.Result == nil) != tt.null || !bytes.Equal(response.Result, tt.want) {
+				t.Errorf("got the result %q, null %v, want %q, null %v", response.Result, response.Result == nil, tt.want, tt.null)
+			}
+		})
+	}
+}
+
+func TestFunctionCallResultEncoded(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		response models.Frontend
+		want     []byte
+	}{
+		{
+			name:     "null",
+			response: models.Frontend{PacketTypes: []string{"V"}, FunctionCallResultNull: true},
+			want:     encodeMessages(&pgproto3.FunctionCallResponse{}),
+		},
+		{
+			// an empty result loses its empty slice once serialized
+			name:     "empty",
+			response: models.Frontend{PacketTypes: []string{"V"}},
+			want:     encodeMessages(&pgproto3.FunctionCallResponse{Result: []byte{}}),
+		},
+		{
+			name:     "value",
+			response: models.Frontend{PacketTypes: []string{"V"}, FunctionCallResponse: pgproto3.FunctionCallResponse{Result: []byte("ok")}},
+			want:     encodeMessages(&pgproto3.FunctionCallResponse{Result: []byte("ok")}),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PostgresDecoderFrontend(tt.response)
+			if err != nil {
+				t.Fatalf("failed to encode the response: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestNullFunctionCallResultRecorded records the null result of a function
+// call, which is replayed as null rather than empty.
+func TestNullFunctionCallResultRecorded(t *testing.T) {
+	request := encodeMessages(&pgproto3.FunctionCall{Function: 1598, ResultFormatCode: 0})
+	response := encodeMessages(&pgproto3.FunctionCallResponse{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+
+	mocks := recordSession(t, PostgresOptions{}, request, response)
+	if len(mocks) == 0 {
+		t.Fatalf("recorded no mock")
+	}
+	mock := mocks[len(mocks)-1]
+	if len(mock.Spec.PostgresResponses) != 1 || !mock.Spec.PostgresResponses[0].FunctionCallResultNull {
+		t.Errorf("recorded the responses %+v, want a null result", mock.Spec.PostgresResponses)
+	}
+	if replayed := replayedBytes(t, mock); !bytes.Equal(replayed, response) {
+		t.Errorf("replayed %q, want %q", replayed, response)
+	}
+}
@@ -0,0 +1,119 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.uber.org/zap"
+)
+
+func TestIsGSSEncRequest(t *testing.T) {
+	gssEncRequest := (&pgproto3.GSSEncRequest{}).Encode(nil)
+	for _, tt := range []struct {
+		name   string
+		buffer []byte
+		want   bool
+	}{
+		{name: "GSSENCRequest", buffer: gssEncRequest, want: true},
+		{name: "SSLRequest", buffer: (&pgproto3.SSLRequest{}).Encode(nil)},
+		{name: "startup", buffer: startupRequest("")},
+		{name: "followed by the startup", buffer: append(append([]byte{}, gssEncRequest...), startupRequest("")...)},
+	} {
+		if got := isGSSEncRequest(tt.buffer); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDeclinedGSSEncryptionRecorded(t *testing.T) {
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+
+	gssEncRequest := (&pgproto3.GSSEncRequest{}).Encode(nil)
+	startup := startupRequest("")
+	started := encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		if _, err := io.ReadFull(server, make([]byte, len(gssEncRequest))); err != nil {
+			return
+		}
+		server.Write([]byte{'N'})
+		if _, err := io.ReadFull(server, make([]byte, len(startup))); err != nil {
+			return
+		}
+		server.Write(started)
+		io.Copy(io.Discard, server)
+	}()
+	go func() {
+		defer app.Close()
+		// the GSSENCRequest is sent along with the connection
+		if _, err := io.ReadFull(app, make([]byte, 1)); err != nil {
+			return
+		}
+		app.Write(startup)
+		io.ReadFull(app, make([]byte, len(started)))
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		encodePostgresOutgoing(gssEncRequest, clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the recording of the session did not end")
+	}
+
+	if len(db.mocks) < 2 {
+		t.Fatalf("recorded %d mocks, want the declined negotiation and the startup", len(db.mocks))
+	}
+	declined := db.mocks[0].Spec
+	if len(declined.PostgresRequests) != 1 || declined.PostgresRequests[0].Payload != base64.StdEncoding.EncodeToString(gssEncRequest) {
+		t.Errorf("recorded the requests %+v, want the GSSENCRequest", declined.PostgresRequests)
+	}
+	if len(declined.PostgresResponses) != 1 || declined.PostgresResponses[0].Payload != base64.StdEncoding.EncodeToString([]byte{'N'}) {
+		t.Errorf("recorded the responses %+v, want the decline", declined.PostgresResponses)
+	}
+}
+
+func TestGSSEncryptionDeclinedOnReplay(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+
+	client, proxied := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- decodePostgresOutgoing((&pgproto3.GSSEncRequest{}).Encode(nil), proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+	}()
+
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set the read deadline: %v", err)
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(client, answer); err != nil {
+		t.Fatalf("failed to read the answer to the GSSENCRequest: %v", err)
+	}
+	if !bytes.Equal(answer, []byte{'N'}) {
+		t.Errorf("answered %q, want the decline", answer)
+	}
+	client.Close()
+	<-done
+}
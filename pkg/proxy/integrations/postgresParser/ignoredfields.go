@@ -0,0 +1,200 @@
+package postgresparser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// IgnoredFields are the values generated by the server, or by the client, on
+// every run, such as the timestamps, the sequence values and the process id
+// of the backend. They are left out when the requests are matched with the
+// recorded ones and when the live responses are compared with the recorded
+// ones. The recorded values are replayed anyway.
+type IgnoredFields struct {
+	// Columns are the names of the columns, as in the RowDescription, whose
+	// values are ignored in the DataRows. The names are case insensitive. The
+	// parameters of the Binds compared with these columns, or inserted into
+	// them, are ignored as well when the requests are matched.
+	Columns []string
+	// ColumnIndexes are the positions of the columns, from zero, whose values
+	// are ignored in the DataRows.
+	ColumnIndexes []int
+	// Parameters maps the queries, as prepared, to the positions of their
+	// parameters, from one as in $1, whose values are ignored in the Bind
+	// messages of these queries only. The queries are compared once their
+	// comments and whitespace are normalized.
+	Parameters map[string][]int
+	// MessageFields are the fields of the other messages ignored, among
+	// BackendKeyData.ProcessID, BackendKeyData.SecretKey,
+	// CommandComplete.CommandTag and ParameterStatus.<name>.
+	MessageFields []string
+}
+
+// requestNoise are the parts of the requests left out when they are matched
+// with the recorded ones.
+type requestNoise struct {
+	jsonPaths []string
+	ignored   IgnoredFields
+	// statements resolve the queries of the statements the Binds refer to
+	// when they were prepared by the earlier requests.
+	statements *statementRegistry
+}
+
+// remove returns the buffer without the noise.
+func (n requestNoise) remove(buffer []byte) []byte {
+	return n.removeIgnoredParameters(removeJSONNoise(buffer, n.jsonPaths))
+}
+
+var (
+	// comparedParameterRe matches the parameters compared with a column,
+	// such as created_at > $2.
+	comparedParameterRe = regexp.MustCompile(`(?i)"?([a-z_][a-z0-9_$]*)"?\s*(?:=|<>|!=|<=|>=|<|>)\s*\$(\d+)`)
+	// insertedParametersRe matches the column list and the values of an
+	// INSERT.
+	insertedParametersRe = regexp.MustCompile(`(?is)insert\s+into\s+\S+\s*\(([^)]*)\)\s*values\s*\(([^)]*)\)`)
+	parameterRe          = regexp.MustCompile(`^\$(\d+)$`)
+)
+
+// ignoredParameters returns the positions of the parameters of the query
+// whose values are ignored: the ones listed for the query and the ones
+// compared with, or inserted into, the ignored columns.
+func (f IgnoredFields) ignoredParameters(query string) []int {
+	var positions []int
+	if len(f.Parameters) > 0 {
+		normalized := normalizeQuery(query)
+		for ignoredQuery, ignored := range f.Parameters {
+			if normalizeQuery(ignoredQuery) == normalized {
+				positions = append(positions, ignored...)
+			}
+		}
+	}
+	if len(f.Columns) == 0 {
+		return positions
+	}
+	for _, match := range comparedParameterRe.FindAllStringSubmatch(query, -1) {
+		if position, err := strconv.Atoi(match[2]); err == nil && f.ignoresColumn(-1, match[1]) {
+			positions = append(positions, position)
+		}
+	}
+	for _, match := range insertedParametersRe.FindAllStringSubmatch(query, -1) {
+		columns, values := strings.Split(match[1], ","), strings.Split(match[2], ",")
+		for i := 0; i < len(columns) && i < len(values); i++ {
+			parameter := parameterRe.FindStringSubmatch(strings.TrimSpace(values[i]))
+			if parameter == nil || !f.ignoresColumn(-1, strings.Trim(strings.TrimSpace(columns[i]), `"`)) {
+				continue
+			}
+			if position, err := strconv.Atoi(parameter[1]); err == nil {
+				positions = append(positions, position)
+			}
+		}
+	}
+	return positions
+}
+
+// removeIgnoredParameters returns the buffer with the values of the ignored
+// parameters of its Bind messages emptied. The query of a Bind is the one of
+// its statement, prepared either earlier in the buffer or by the earlier
+// requests. The buffer is returned as is when it has no such parameter.
+func (n requestNoise) removeIgnoredParameters(buffer []byte) []byte {
+	if (len(n.ignored.Parameters) == 0 && len(n.ignored.Columns) == 0) || len(buffer) < 5 || (len(buffer) >= 8 && isStartupPacket(buffer)) {
+		return buffer
+	}
+	// the statements prepared in the buffer, before the Binds
+	prepared := map[string]string{}
+	var normalized []byte
+	changed := false
+	for i := 0; i+5 <= len(buffer); {
+		bodyLen, err := readMessageBodyLen(buffer, i)
+		if err != nil {
+			return buffer
+		}
+		msg := buffer[i : i+5+bodyLen]
+		i += bodyLen + 5
+		if msg[0] == 'P' {
+			parse := &pgproto3.Parse{}
+			if parse.Decode(msg[5:]) == nil {
+				prepared[parse.Name] = parse.Query
+			}
+		}
+		if msg[0] != 'B' {
+			normalized = append(normalized, msg...)
+			continue
+		}
+		bind := &pgproto3.Bind{}
+		if err := bind.Decode(msg[5:]); err != nil {
+			normalized = append(normalized, msg...)
+			continue
+		}
+		query, ok := prepared[bind.PreparedStatement]
+		if !ok && n.statements != nil {
+			query = n.statements.names[bind.PreparedStatement]
+		}
+		for _, position := range n.ignored.ignoredParameters(query) {
+			if position >= 1 && position <= len(bind.Parameters) {
+				bind.Parameters[position-1] = []byte{}
+				changed = true
+			}
+		}
+		normalized = bind.Encode(normalized)
+	}
+	if !changed {
+		return buffer
+	}
+	return normalized
+}
+
+// ignoresColumn reports whether the values of the column are ignored.
+func (f IgnoredFields) ignoresColumn(index int, name string) bool {
+	for _, ignored := range f.ColumnIndexes {
+		if ignored == index {
+			return true
+		}
+	}
+	for _, ignored := range f.Columns {
+		if strings.EqualFold(ignored, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoresMessageField reports whether the field of the message is ignored.
+func (f IgnoredFields) ignoresMessageField(field string) bool {
+	for _, ignored := range f.MessageFields {
+		if strings.EqualFold(ignored, field) {
+			return true
+		}
+	}
+	return false
+}
+
+// messagesEqualIgnoringFields reports whether a recorded message and a live
+// one of the same type only differ by their ignored fields.
+func messagesEqualIgnoringFields(recorded, live []byte, ignored IgnoredFields) bool {
+	switch recorded[0] {
+	case 'K':
+		recordedKey, liveKey := &pgproto3.BackendKeyData{}, &pgproto3.BackendKeyData{}
+		if recordedKey.Decode(recorded[5:]) != nil || liveKey.Decode(live[5:]) != nil {
+			return false
+		}
+		if ignored.ignoresMessageField("BackendKeyData.ProcessID") {
+			recordedKey.ProcessID, liveKey.ProcessID = 0, 0
+		}
+		if ignored.ignoresMessageField("BackendKeyData.SecretKey") {
+			recordedKey.SecretKey, liveKey.SecretKey = 0, 0
+		}
+		return *recordedKey == *liveKey
+	case 'C':
+		return ignored.ignoresMessageField("CommandComplete.CommandTag")
+	case 'S':
+		recordedStatus, liveStatus := &pgproto3.ParameterStatus{}, &pgproto3.ParameterStatus{}
+		if recordedStatus.Decode(recorded[5:]) != nil || liveStatus.Decode(live[5:]) != nil {
+			return false
+		}
+		return recordedStatus.Name == liveStatus.Name && ignored.ignoresMessageField("ParameterStatus."+recordedStatus.Name)
+	}
+	return false
+}
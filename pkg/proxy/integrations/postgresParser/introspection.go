@@ -0,0 +1,93 @@
+package postgresparser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// introspection queries report server specific values (version, encoding, ...)
+// which differ across the environments the mocks are recorded and replayed in.
+var (
+	showQueryRe          = regexp.MustCompile(`(?i)^\s*show\s+([a-z0-9_.]+)\s*;?\s*$`)
+	currentSettingRe     = regexp.MustCompile(`(?i)^\s*select\s+current_setting\s*\(\s*'([a-z0-9_.]+)'\s*\)\s*;?\s*$`)
+	pgSettingsQueryRe    = regexp.MustCompile(`(?i)^\s*select\s+setting\s+from\s+(?:pg_catalog\.)?pg_settings\s+where\s+name\s*=\s*'([a-z0-9_.]+)'\s*;?\s*$`)
+	introspectionQueries = []struct {
+		re     *regexp.Regexp
+		column string
+		tag    string
+	}{
+		{re: showQueryRe, tag: "SHOW"},
+		{re: currentSettingRe, column: "current_setting", tag: "SELECT 1"},
+		{re: pgSettingsQueryRe, column: "setting", tag: "SELECT 1"},
+	}
+)
+
+// introspectionResponse builds the response of a simple introspection query
+// when its setting is overridden, ending it with the transaction status the
+// connection is in. It returns nil when the requests are not a single simple
+// query for an overridden setting.
+func introspectionResponse(requestBuffers [][]byte, overrides map[string]string, txStatus byte) []byte {
+	if len(overrides) == 0 || len(requestBuffers) != 1 {
+		return nil
+	}
+	buffer := requestBuffers[0]
+	if len(buffer) < 5 || buffer[0] != 'Q' {
+		return nil
+	}
+	bodyLen, err := readMessageBodyLen(buffer, 0)
+	if err != nil || len(buffer) != bodyLen+5 {
+		return nil
+	}
+	query := &pgproto3.Query{}
+	if err := query.Decode(buffer[5:]); err != nil {
+		return nil
+	}
+
+	for _, q := range introspectionQueries {
+		match := q.re.FindStringSubmatch(query.String)
+		if match == nil {
+			continue
+		}
+		setting := strings.ToLower(match[1])
+		value, ok := overrides[setting]
+		if !ok {
+			return nil
+		}
+		column := q.column
+		if column == "" {
+			column = setting
+		}
+		var response []byte
+		response = (&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{
+			Name:         []byte(column),
+			DataTypeOID:  25,
+			DataTypeSize: -1,
+			TypeModifier: -1,
+		}}}).Encode(response)
+		response = (&pgproto3.DataRow{RowValues: []string{value}}).Encode(response)
+		response = (&pgproto3.CommandComplete{CommandTag: []byte(q.tag)}).Encode(response)
+		response = (&pgproto3.ReadyForQuery{TxStatus: txStatus}).Encode(response)
+		return response
+	}
+	return nil
+}
+
+// overrideParameterStatus replaces the values of the overridden settings
+// reported by the server in the ParameterStatus messages of the startup.
+func overrideParameterStatus(response *models.Frontend, overrides map[string]string) {
+	if len(overrides) == 0 || response.Payload != "" {
+		return
+	}
+	// copy the statuses to leave the stored mock untouched
+	statuses := make([]pgproto3.ParameterStatus, len(response.ParameterStatusCombined))
+	copy(statuses, response.ParameterStatusCombined)
+	for i, ps := range statuses {
+		if value, ok := overrides[strings.ToLower(ps.Name)]; ok {
+			statuses[i].Value = value
+		}
+	}
+	response.ParameterStatusCombined = statuses
+}
@@ -0,0 +1,72 @@
+package postgresparser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// settingResponse returns the wire bytes of the response reporting the value
+// of a setting in the column.
+func settingResponse(column, value, tag string, txStatus byte) []byte {
+	buffer := (&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte(column), DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1}}}).Encode(nil)
+	buffer = (&pgproto3.DataRow{RowValues: []string{value}}).Encode(buffer)
+	buffer = (&pgproto3.CommandComplete{CommandTag: []byte(tag)}).Encode(buffer)
+	return (&pgproto3.ReadyForQuery{TxStatus: txStatus}).Encode(buffer)
+}
+
+func TestIntrospectionResponse(t *testing.T) {
+	overrides := map[string]string{"server_version": "15.4", "server_encoding": "UTF8"}
+	for _, tt := range []struct {
+		name     string
+		requests [][]byte
+		want     []byte
+	}{
+		{name: "show", requests: SimpleQueryRequest("SHOW server_version"), want: settingResponse("server_version", "15.4", "SHOW", 'I')},
+		{name: "show in upper case", requests: SimpleQueryRequest("SHOW SERVER_ENCODING;"), want: settingResponse("server_encoding", "UTF8", "SHOW", 'I')},
+		{name: "current_setting", requests: SimpleQueryRequest("select current_setting('server_version')"), want: settingResponse("current_setting", "15.4", "SELECT 1", 'I')},
+		{name: "pg_settings", requests: SimpleQueryRequest("SELECT setting FROM pg_catalog.pg_settings WHERE name = 'server_version'"), want: settingResponse("setting", "15.4", "SELECT 1", 'I')},
+		{name: "unqualified pg_settings", requests: SimpleQueryRequest("SELECT setting FROM pg_settings WHERE name = 'server_encoding'"), want: settingResponse("setting", "UTF8", "SELECT 1", 'I')},
+		{name: "not overridden", requests: SimpleQueryRequest("SHOW timezone")},
+		{name: "not an introspection", requests: SimpleQueryRequest("SELECT version FROM releases")},
+		{name: "extended query", requests: [][]byte{extendedQueryRequest("SHOW server_version")}},
+		{name: "several queries", requests: [][]byte{append(SimpleQueryRequest("SHOW server_version")[0], SimpleQueryRequest("SHOW server_encoding")[0]...)}},
+	} {
+		if got := introspectionResponse(tt.requests, overrides, 'I'); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got the response %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// the response ends with the status of the transaction in progress
+	if got := introspectionResponse(SimpleQueryRequest("SHOW server_version"), overrides, 'T'); !bytes.Equal(got, settingResponse("server_version", "15.4", "SHOW", 'T')) {
+		t.Errorf("got the response %q, want the one in the transaction", got)
+	}
+	if got := introspectionResponse(SimpleQueryRequest("SHOW server_version"), nil, 'I'); got != nil {
+		t.Errorf("got the response %q without overrides", got)
+	}
+}
+
+func TestOverrideParameterStatus(t *testing.T) {
+	recorded := []pgproto3.ParameterStatus{{Name: "server_version", Value: "14.9"}, {Name: "TimeZone", Value: "UTC"}}
+	response := models.Frontend{PacketTypes: []string{"R", "S", "S", "Z"}, ParameterStatusCombined: recorded}
+	overrideParameterStatus(&response, map[string]string{"server_version": "15.4", "timezone": "Europe/Paris"})
+
+	want := []pgproto3.ParameterStatus{{Name: "server_version", Value: "15.4"}, {Name: "TimeZone", Value: "Europe/Paris"}}
+	for i, status := range response.ParameterStatusCombined {
+		if status != want[i] {
+			t.Errorf("got the parameter status %+v, want %+v", status, want[i])
+		}
+	}
+	if recorded[0].Value != "14.9" {
+		t.Errorf("changed the parameter status of the stored mock to %q", recorded[0].Value)
+	}
+
+	// the raw payload is replayed as recorded
+	raw := models.Frontend{Payload: "UwAAABk=", ParameterStatusCombined: []pgproto3.ParameterStatus{{Name: "server_version", Value: "14.9"}}}
+	overrideParameterStatus(&raw, map[string]string{"server_version": "15.4"})
+	if raw.ParameterStatusCombined[0].Value != "14.9" {
+		t.Errorf("overrode the parameter status of a response replayed from its payload")
+	}
+}
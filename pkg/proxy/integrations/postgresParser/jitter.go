@@ -0,0 +1,67 @@
+package postgresparser
+
+import (
+	"math/rand"
+	"time"
+)
+
+// JitterDistribution is the distribution the replay delays are drawn from.
+type JitterDistribution string
+
+const (
+	// JitterUniform draws the delays evenly between Min and Max.
+	JitterUniform JitterDistribution = "uniform"
+	// JitterNormal draws the delays around Mean with the standard deviation
+	// StdDev, bounded by Min and Max.
+	JitterNormal JitterDistribution = "normal"
+)
+
+// ReplayJitter delays the replayed responses by a random duration, to
+// simulate a dependency whose latency varies in the load and timing tests.
+// The zero value adds no delay.
+type ReplayJitter struct {
+	Distribution JitterDistribution
+	// Min and Max bound the delays. Max is unbounded for the normal
+	// distribution when zero.
+	Min time.Duration
+	Max time.Duration
+	// Mean and StdDev shape the normal distribution.
+	Mean   time.Duration
+	StdDev time.Duration
+}
+
+// enabled reports whether the jitter adds any delay.
+func (j ReplayJitter) enabled() bool {
+	switch j.Distribution {
+	case JitterUniform:
+		return j.Max > 0
+	case JitterNormal:
+		return j.Mean > 0 || j.StdDev > 0 || j.Min > 0
+	}
+	return false
+}
+
+// delay draws the delay of the next response.
+func (j ReplayJitter) delay() time.Duration {
+	lower := j.Min
+	if lower < 0 {
+		lower = 0
+	}
+	switch j.Distribution {
+	case JitterUniform:
+		if j.Max <= lower {
+			return lower
+		}
+		return lower + time.Duration(rand.Int63n(int64(j.Max-lower)+1))
+	case JitterNormal:
+		d := j.Mean + time.Duration(rand.NormFloat64()*float64(j.StdDev))
+		if d < lower {
+			d = lower
+		}
+		if j.Max > 0 && d > j.Max {
+			d = j.Max
+		}
+		return d
+	}
+	return 0
+}
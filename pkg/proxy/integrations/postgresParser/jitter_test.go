@@ -0,0 +1,78 @@
+package postgresparser
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReplayJitterEnabled(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		jitter ReplayJitter
+		want   bool
+	}{
+		{name: "zero value"},
+		{name: "bounds without distribution", jitter: ReplayJitter{Min: time.Millisecond, Max: time.Second}},
+		{name: "uniform", jitter: ReplayJitter{Distribution: JitterUniform, Max: time.Millisecond}, want: true},
+		{name: "uniform without max", jitter: ReplayJitter{Distribution: JitterUniform, Min: time.Millisecond}},
+		{name: "normal", jitter: ReplayJitter{Distribution: JitterNormal, Mean: time.Millisecond}, want: true},
+		{name: "normal around zero", jitter: ReplayJitter{Distribution: JitterNormal, StdDev: time.Millisecond}, want: true},
+		{name: "normal with a floor", jitter: ReplayJitter{Distribution: JitterNormal, Min: time.Millisecond}, want: true},
+		{name: "normal without shape", jitter: ReplayJitter{Distribution: JitterNormal}},
+		{name: "unknown distribution", jitter: ReplayJitter{Distribution: "pareto", Max: time.Second}},
+	} {
+		if got := tt.jitter.enabled(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestReplayJitterDelay(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		jitter   ReplayJitter
+		min, max time.Duration
+	}{
+		{name: "uniform", jitter: ReplayJitter{Distribution: JitterUniform, Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}, min: 10 * time.Millisecond, max: 20 * time.Millisecond},
+		{name: "uniform from zero", jitter: ReplayJitter{Distribution: JitterUniform, Max: 5 * time.Millisecond}, max: 5 * time.Millisecond},
+		{name: "uniform of a negative min", jitter: ReplayJitter{Distribution: JitterUniform, Min: -time.Second, Max: 5 * time.Millisecond}, max: 5 * time.Millisecond},
+		{name: "uniform of equal bounds", jitter: ReplayJitter{Distribution: JitterUniform, Min: 7 * time.Millisecond, Max: 7 * time.Millisecond}, min: 7 * time.Millisecond, max: 7 * time.Millisecond},
+		{name: "normal bounded", jitter: ReplayJitter{Distribution: JitterNormal, Min: 8 * time.Millisecond, Max: 12 * time.Millisecond, Mean: 10 * time.Millisecond, StdDev: 10 * time.Millisecond}, min: 8 * time.Millisecond, max: 12 * time.Millisecond},
+		{name: "normal never negative", jitter: ReplayJitter{Distribution: JitterNormal, StdDev: 10 * time.Millisecond}, max: time.Second},
+		{name: "normal without deviation", jitter: ReplayJitter{Distribution: JitterNormal, Mean: 3 * time.Millisecond}, min: 3 * time.Millisecond, max: 3 * time.Millisecond},
+		{name: "unknown distribution", jitter: ReplayJitter{Distribution: "pareto", Min: time.Second, Max: 2 * time.Second}},
+	} {
+		for i := 0; i < 1000; i++ {
+			if d := tt.jitter.delay(); d < tt.min || d > tt.max {
+				t.Errorf("%s: drew %v, want between %v and %v", tt.name, d, tt.min, tt.max)
+				break
+			}
+		}
+	}
+}
+
+func TestReplayJitterSpread(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		jitter ReplayJitter
+		mean   time.Duration
+	}{
+		{name: "uniform", jitter: ReplayJitter{Distribution: JitterUniform, Min: 10 * time.Millisecond, Max: 30 * time.Millisecond}, mean: 20 * time.Millisecond},
+		{name: "normal", jitter: ReplayJitter{Distribution: JitterNormal, Mean: 50 * time.Millisecond, StdDev: 5 * time.Millisecond}, mean: 50 * time.Millisecond},
+	} {
+		const draws = 10000
+		var sum time.Duration
+		distinct := make(map[time.Duration]bool)
+		for i := 0; i < draws; i++ {
+			d := tt.jitter.delay()
+			sum += d
+			distinct[d] = true
+		}
+		if mean := sum / draws; mean < tt.mean*9/10 || mean > tt.mean*11/10 {
+			t.Errorf("%s: drew the mean %v, want about %v", tt.name, mean, tt.mean)
+		}
+		if len(distinct) < draws/2 {
+			t.Errorf("%s: drew %d distinct delays of %d", tt.name, len(distinct), draws)
+		}
+	}
+}
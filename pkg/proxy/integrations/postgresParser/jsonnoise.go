@@ -0,0 +1,100 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// jsonbVersion prefixes the JSONB values sent in the binary format.
+const jsonbVersion = 1
+
+// removeJSONNoise returns the buffer with the noisy JSON paths removed from
+// the JSON and JSONB parameters of its Bind messages. Paths are dot separated
+// (`created_at`, `$.meta.created_at`) and apply to every element of the
+// arrays they traverse. The buffer is returned as is when it has no JSON
+// parameter.
+func removeJSONNoise(buffer []byte, paths []string) []byte {
+	if len(paths) == 0 || len(buffer) < 5 || (len(buffer) >= 8 && isStartupPacket(buffer)) {
+		return buffer
+	}
+	var normalized []byte
+	changed := false
+	for i := 0; i+5 <= len(buffer); {
+		bodyLen, err := readMessageBodyLen(buffer, i)
+		if err != nil {
+			return buffer
+		}
+		msg := buffer[i : i+5+bodyLen]
+		i += bodyLen + 5
+		if msg[0] != 'B' {
+			normalized = append(normalized, msg...)
+			continue
+		}
+		bind := &pgproto3.Bind{}
+		if err := bind.Decode(msg[5:]); err != nil {
+			normalized = append(normalized, msg...)
+			continue
+		}
+		for p, param := range bind.Parameters {
+			if value, ok := removeJSONPaths(param, paths); ok {
+				bind.Parameters[p] = value
+				changed = true
+			}
+		}
+		normalized = bind.Encode(normalized)
+	}
+	if !changed {
+		return buffer
+	}
+	return normalized
+}
+
+// removeJSONPaths removes the paths from a JSON (or binary JSONB) value,
+// reporting false when the value is not a JSON object or array.
+func removeJSONPaths(value []byte, paths []string) ([]byte, bool) {
+	var prefix []byte
+	doc := value
+	if len(doc) > 0 && doc[0] == jsonbVersion {
+		prefix, doc = doc[:1], doc[1:]
+	}
+	trimmed := bytes.TrimSpace(doc)
+	if len(trimmed) == 0 || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return nil, false
+	}
+	var parsed interface{}
+	if err := json.Unmarshal(trimmed, &parsed); err != nil {
+		return nil, false
+	}
+	for _, path := range paths {
+		path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+		if path == "" {
+			continue
+		}
+		deleteJSONPath(parsed, strings.Split(path, "."))
+	}
+	encoded, err := json.Marshal(parsed)
+	if err != nil {
+		return nil, false
+	}
+	return append(append([]byte{}, prefix...), encoded...), true
+}
+
+func deleteJSONPath(node interface{}, keys []string) {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if len(keys) == 1 {
+			delete(n, keys[0])
+			return
+		}
+		if child, ok := n[keys[0]]; ok {
+			deleteJSONPath(child, keys[1:])
+		}
+	case []interface{}:
+		for _, element := range n {
+			deleteJSONPath(element, keys)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// jsonBindRequest returns the buffer inserting the JSON document.
+func jsonBindRequest(doc []byte) []byte {
+	return encodeMessages(
+		&pgproto3.Parse{Query: "INSERT INTO events (payload) VALUES ($1)"},
+		&pgproto3.Bind{Parameters: [][]byte{doc}},
+		&pgproto3.Execute{},
+		&pgproto3.Sync{},
+	)
+}
+
+func TestRemoveJSONPaths(t *testing.T) {
+	paths := []string{"created_at", "$.meta.id", "items.updated_at"}
+	for _, tt := range []struct {
+		name  string
+		value string
+		want  string
+		ok    bool
+	}{
+		{
+			name:  "top level",
+			value: `{"name":"a","created_at":"2024-01-01"}`,
+			want:  `{"name":"a"}`,
+			ok:    true,
+		},
+		{
+			name:  "nested",
+			value: `{"meta":{"id":7,"source":"api"}}`,
+			want:  `{"meta":{"source":"api"}}`,
+			ok:    true,
+		},
+		{
+			name:  "every element of the arrays",
+			value: `{"items":[{"sku":1,"updated_at":"x"},{"sku":2,"updated_at":"y"}]}`,
+			want:  `{"items":[{"sku":1},{"sku":2}]}`,
+			ok:    true,
+		},
+		{
+			name:  "top level array",
+			value: ` [{"created_at":"x","n":1}] `,
+			want:  `[{"n":1}]`,
+			ok:    true,
+		},
+		{
+			name:  "binary jsonb",
+			value: "\x01" + `{"created_at":"x","n":1}`,
+			want:  "\x01" + `{"n":1}`,
+			ok:    true,
+		},
+		{
+			name:  "missing path",
+			value: `{"n":1,"meta":"flat"}`,
+			want:  `{"meta":"flat","n":1}`,
+			ok:    true,
+		},
+		{name: "text", value: "created_at"},
+		{name: "number", value: "42"},
+		{name: "invalid", value: `{"created_at":`},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := removeJSONPaths([]byte(tt.value), paths)
+			if ok != tt.ok || string(got) != tt.want {
+				t.Errorf("got %q, %v, want %q, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRemoveJSONNoise(t *testing.T) {
+	paths := []string{"created_at"}
+	recorded := jsonBindRequest([]byte(`{"id":1,"created_at":"2024-01-01T10:00:00Z"}`))
+	live := jsonBindRequest([]byte(`{"created_at":"2024-06-30T08:15:00Z","id":1}`))
+
+	if bytes.Equal(removeJSONNoise(recorded, nil), removeJSONNoise(live, nil)) {
+		t.Fatal("got the same buffers without noisy paths")
+	}
+	if !bytes.Equal(removeJSONNoise(recorded, paths), removeJSONNoise(live, paths)) {
+		t.Error("got different buffers once the noisy path is removed")
+	}
+	other := jsonBindRequest([]byte(`{"id":2,"created_at":"2024-01-01T10:00:00Z"}`))
+	if bytes.Equal(removeJSONNoise(recorded, paths), removeJSONNoise(other, paths)) {
+		t.Error("got the same buffers for documents differing outside the noisy path")
+	}
+
+	// the buffers without JSON parameters are returned as is
+	for _, buffer := range [][]byte{
+		jsonBindRequest([]byte("plain text")),
+		(&pgproto3.Query{String: `SELECT '{"created_at":1}'`}).Encode(nil),
+	} {
+		if got := removeJSONNoise(buffer, paths); !bytes.Equal(got, buffer) {
+			t.Errorf("got %q, want the buffer %q unchanged", got, buffer)
+		}
+	}
+}
+
+func TestRequestEqualsIgnoresJSONNoise(t *testing.T) {
+	recorded := models.Backend{Payload: base64.StdEncoding.EncodeToString(jsonBindRequest([]byte(`{"id":1,"meta":{"created_at":"x"}}`)))}
+	live := jsonBindRequest([]byte(`{"id":1,"meta":{"created_at":"y"}}`))
+
+	if requestEquals(recorded, live, requestNoise{}, MatchExact) {
+		t.Error("got equal requests without noisy paths")
+	}
+	if !requestEquals(recorded, live, requestNoise{jsonPaths: []string{"meta.created_at"}}, MatchExact) {
+		t.Error("got different requests once the noisy path is ignored")
+	}
+}
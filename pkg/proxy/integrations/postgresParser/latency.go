@@ -0,0 +1,33 @@
+package postgresparser
+
+import (
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// defaultMaxReplayLatency caps the replayed latency of a response unless
+// configured otherwise.
+const defaultMaxReplayLatency = 5 * time.Second
+
+// recordedLatency returns the time the server took to answer the requests of
+// the mock when it was recorded, zero when its timestamps are unknown.
+func recordedLatency(mock *models.Mock) time.Duration {
+	if mock.Spec.ReqTimestampMock.IsZero() || mock.Spec.ResTimestampMock.IsZero() {
+		return 0
+	}
+	latency := mock.Spec.ResTimestampMock.Sub(mock.Spec.ReqTimestampMock)
+	if latency < 0 {
+		return 0
+	}
+	return latency
+}
+
+// replayedLatency returns the delay of a response recorded with the latency,
+// bounded by the maximum.
+func replayedLatency(latency, max time.Duration) time.Duration {
+	if max > 0 && latency > max {
+		return max
+	}
+	return latency
+}
@@ -0,0 +1,21 @@
+package postgresparser
+
+import (
+	"go.uber.org/zap"
+)
+
+// FuzzyMatch describes a request which had no exact match among the recorded
+// mocks and fell back to the most similar one, i.e. the query drifted since
+// the recording.
+type FuzzyMatch struct {
+	MockName   string
+	Similarity float64
+	Requests   [][]byte
+}
+
+func reportFuzzyMatch(match FuzzyMatch, logger *zap.Logger, reporter func(FuzzyMatch)) {
+	logger.Warn("the postgres request had no exact match and was matched by similarity, the query may have drifted since the recording", zap.String("mock", match.MockName), zap.Float64("similarity", match.Similarity))
+	if reporter != nil {
+		reporter(match)
+	}
+}
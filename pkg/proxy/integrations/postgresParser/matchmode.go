@@ -0,0 +1,138 @@
+package postgresparser
+
+import (
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// MatchMode decides how strictly the statements of the requests are compared
+// with the recorded ones in test mode.
+type MatchMode string
+
+const (
+	// MatchExact compares the statements byte for byte. It is the default.
+	MatchExact MatchMode = "exact"
+	// MatchNormalized ignores the comments, the insignificant whitespace and
+	// the trailing semicolons of the statements.
+	MatchNormalized MatchMode = "normalized"
+	// MatchTemplate also ignores the values of the numeric and string
+	// literals and of the bind parameters, so that the statements built with
+	// other values match, as long as their structure is the same.
+	MatchTemplate MatchMode = "template"
+)
+
+// Valid reports whether the mode is one of the match modes, the empty mode
+// being MatchExact.
+func (m MatchMode) Valid() bool {
+	switch m {
+	case "", MatchExact, MatchNormalized, MatchTemplate:
+		return true
+	}
+	return false
+}
+
+// stages returns the modes the requests are matched with, in order. The
+// template mode falls back on the mocks recorded with other values only when
+// none was recorded with the same ones.
+func (m MatchMode) stages() []MatchMode {
+	if m == MatchTemplate {
+		return []MatchMode{MatchNormalized, MatchTemplate}
+	}
+	return []MatchMode{m}
+}
+
+// normalize returns the request buffer in the form compared by the mode.
+func (m MatchMode) normalize(buffer []byte) []byte {
+	switch m {
+	case MatchNormalized:
+		return normalizeQueries(buffer)
+	case MatchTemplate:
+		return templateBindParameters(rewriteQueries(buffer, templateQuery))
+	}
+	return buffer
+}
+
+// templateQuery normalizes the statement and replaces its numeric and string
+// literals with a placeholder. The quoted identifiers, the positional
+// parameters and the dollar-quoted bodies are kept verbatim.
+func templateQuery(query string) string {
+	query = normalizeQuery(query)
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"':
+			j := i + 1
+			for j < len(query) {
+				if query[j] == c {
+					if j+1 < len(query) && query[j+1] == c {
+						j += 2
+						continue
+					}
+					j++
+					break
+				}
+				j++
+			}
+			if c == '\'' {
+				b.WriteString("'?'")
+			} else {
+				b.WriteString(query[i:j])
+			}
+			i = j
+		case c == '$':
+			j := i + 1
+			if tag := dollarQuoteTag(query[i:]); tag != "" {
+				end := strings.Index(query[i+len(tag):], tag)
+				j = len(query)
+				if end >= 0 {
+					j = i + len(tag) + end + len(tag)
+				}
+			} else {
+				// the digits of a positional parameter are not a literal
+				for j < len(query) && query[j] >= '0' && query[j] <= '9' {
+					j++
+				}
+			}
+			b.WriteString(query[i:j])
+			i = j
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentifierByte(query[i-1])):
+			j := i
+			for j < len(query) && (isIdentifierByte(query[j]) || query[j] == '.' ||
+				((query[j] == '+' || query[j] == '-') && (query[j-1] == 'e' || query[j-1] == 'E'))) {
+				j++
+			}
+			b.WriteByte('?')
+			i = j
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c >= 0x80
+}
+
+// templateBindParameters returns the buffer with the values of the parameters
+// of its Bind messages left out, keeping their number and formats.
+func templateBindParameters(buffer []byte) []byte {
+	msgs, ok := splitMessages(buffer)
+	if !ok || isStartupPacket(buffer) {
+		return buffer
+	}
+	templated := make([]byte, 0, len(buffer))
+	for _, msg := range msgs {
+		bind := &pgproto3.Bind{}
+		if msg[0] != 'B' || bind.Decode(msg[5:]) != nil {
+			templated = append(templated, msg...)
+			continue
+		}
+		bind.Parameters = make([][]byte, len(bind.Parameters))
+		templated = bind.Encode(templated)
+	}
+	return templated
+}
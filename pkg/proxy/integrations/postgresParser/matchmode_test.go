@@ -0,0 +1,158 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestMatchModeValid(t *testing.T) {
+	for _, mode := range []MatchMode{"", MatchExact, MatchNormalized, MatchTemplate} {
+		if !mode.Valid() {
+			t.Errorf("got the mode %q invalid", mode)
+		}
+	}
+	if MatchMode("fuzzy").Valid() {
+		t.Errorf("got the unknown mode valid")
+	}
+}
+
+func TestTemplateQuery(t *testing.T) {
+	for _, tt := range []struct {
+		name, query, want string
+	}{
+		{name: "integer", query: "SELECT * FROM users WHERE id = 42", want: "SELECT * FROM users WHERE id = ?"},
+		{name: "decimal and exponent", query: "SELECT 1.5, 2e-3, 3E+4", want: "SELECT ?, ?, ?"},
+		{name: "string", query: "SELECT * FROM users WHERE name = 'keploy'", want: "SELECT * FROM users WHERE name = '?'"},
+		{name: "doubled quote", query: "SELECT 'it''s'", want: "SELECT '?'"},
+		{name: "quoted identifier", query: `SELECT "col 1" FROM t WHERE x = 3`, want: `SELECT "col 1" FROM t WHERE x = ?`},
+		{name: "identifier with digits", query: "SELECT col1 FROM t2 WHERE id = 7", want: "SELECT col1 FROM t2 WHERE id = ?"},
+		{name: "positional parameters", query: "SELECT $1, $12 LIMIT 10", want: "SELECT $1, $12 LIMIT ?"},
+		{name: "dollar quoted", query: "DO $fn$ SELECT 42 $fn$", want: "DO $fn$ SELECT 42 $fn$"},
+		{name: "normalized first", query: "SELECT  1 -- one\n;", want: "SELECT ?"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := templateQuery(tt.query); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateBindParameters(t *testing.T) {
+	bind := func(formats []int16, params ...string) []byte {
+		values := make([][]byte, len(params))
+		for i, param := range params {
+			values[i] = []byte(param)
+		}
+		return encodeMessages(&pgproto3.Parse{Query: "SELECT $1, $2"}, &pgproto3.Bind{ParameterFormatCodes: formats, Parameters: values}, &pgproto3.Execute{}, &pgproto3.Sync{})
+	}
+	if a, b := templateBindParameters(bind(nil, "1", "keploy")), templateBindParameters(bind(nil, "22", "proxy")); !bytes.Equal(a, b) {
+		t.Errorf("got the templates %q and %q of the same statement, want them equal", a, b)
+	}
+	if a, b := templateBindParameters(bind(nil, "1", "keploy")), templateBindParameters(bind(nil, "1")); bytes.Equal(a, b) {
+		t.Errorf("got the same template of binds of different parameter counts")
+	}
+	if a, b := templateBindParameters(bind([]int16{0}, "1", "keploy")), templateBindParameters(bind([]int16{1}, "1", "keploy")); bytes.Equal(a, b) {
+		t.Errorf("got the same template of binds of different parameter formats")
+	}
+	query := SimpleQueryRequest("SELECT 1")[0]
+	if got := templateBindParameters(query); !bytes.Equal(got, query) {
+		t.Errorf("got %q, want the buffer without a Bind unchanged", got)
+	}
+}
+
+func TestRequestEqualsByMatchMode(t *testing.T) {
+	recorded := models.Backend{Payload: base64.StdEncoding.EncodeToString(SimpleQueryRequest("SELECT name FROM users WHERE id = 42")[0])}
+	extended := models.Backend{Payload: base64.StdEncoding.EncodeToString(extendedQueryRequest("SELECT name FROM users WHERE id = $1", "42"))}
+	for _, tt := range []struct {
+		name     string
+		recorded models.Backend
+		buffer   []byte
+		want     map[MatchMode]bool
+	}{
+		{
+			name:     "identical",
+			recorded: recorded,
+			buffer:   SimpleQueryRequest("SELECT name FROM users WHERE id = 42")[0],
+			want:     map[MatchMode]bool{MatchExact: true, MatchNormalized: true, MatchTemplate: true},
+		},
+		{
+			name:     "formatted otherwise",
+			recorded: recorded,
+			buffer:   SimpleQueryRequest("SELECT name\n  FROM users WHERE id = 42;")[0],
+			want:     map[MatchMode]bool{MatchNormalized: true, MatchTemplate: true},
+		},
+		{
+			name:     "other literal",
+			recorded: recorded,
+			buffer:   SimpleQueryRequest("SELECT name FROM users WHERE id = 43")[0],
+			want:     map[MatchMode]bool{MatchTemplate: true},
+		},
+		{
+			name:     "other parameter",
+			recorded: extended,
+			buffer:   extendedQueryRequest("SELECT name FROM users WHERE id = $1", "43"),
+			want:     map[MatchMode]bool{MatchTemplate: true},
+		},
+		{
+			name:     "other structure",
+			recorded: recorded,
+			buffer:   SimpleQueryRequest("SELECT email FROM users WHERE id = 42")[0],
+			want:     map[MatchMode]bool{},
+		},
+	} {
+		for _, mode := range []MatchMode{MatchExact, MatchNormalized, MatchTemplate} {
+			if got := requestEquals(tt.recorded, tt.buffer, requestNoise{}, mode); got != tt.want[mode] {
+				t.Errorf("%s: got %v in the %s mode, want %v", tt.name, got, mode, tt.want[mode])
+			}
+		}
+		// the statements are compared exactly unless configured otherwise
+		if got := requestEquals(tt.recorded, tt.buffer, requestNoise{}, ""); got != tt.want[MatchExact] {
+			t.Errorf("%s: got %v in the default mode, want %v", tt.name, got, tt.want[MatchExact])
+		}
+	}
+}
+
+func TestTemplateMatchPrefersSameValues(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		mode MatchMode
+		id   string
+		want string
+		// whether the mock is only matched by similarity
+		wantFuzzy bool
+	}{
+		{name: "same values first recorded", mode: MatchTemplate, id: "1", want: "100.50"},
+		{name: "same values recorded later", mode: MatchTemplate, id: "2", want: "200.00"},
+		{name: "other values", mode: MatchTemplate, id: "3", want: "100.50"},
+		{name: "other values normalized", mode: MatchNormalized, id: "3", want: "100.50", wantFuzzy: true},
+	} {
+		h, err := hooks.NewHook(nil, 0, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create the hooks: %v", err)
+		}
+		h.SetConfigMocks([]*models.Mock{balanceMock("1", "100.50"), balanceMock("2", "200.00")})
+		fuzzy := false
+		opts := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{MatchMode: tt.mode, FuzzyMatchReporter: func(FuzzyMatch) { fuzzy = true }}).opts
+
+		matched, responses, _, _, err := matchingReadablePG([][]byte{balanceQuery(tt.id)}, connectionState{}, zap.NewNop(), h, opts)
+		if err != nil {
+			t.Fatalf("%s: failed to match the query: %v", tt.name, err)
+		}
+		if !matched || len(responses) != 1 {
+			t.Fatalf("%s: matched no mock", tt.name)
+		}
+		if got, _ := base64.StdEncoding.DecodeString(responses[0].Payload); !bytes.Equal(got, balanceResponse(tt.want)) {
+			t.Errorf("%s: got the responses %q, want the balance %s", tt.name, got, tt.want)
+		}
+		if fuzzy != tt.wantFuzzy {
+			t.Errorf("%s: got matched by similarity %v, want %v", tt.name, fuzzy, tt.wantFuzzy)
+		}
+	}
+}
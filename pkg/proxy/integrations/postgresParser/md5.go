@@ -0,0 +1,102 @@
+package postgresparser
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+)
+
+type md5State int
+
+const (
+	md5Idle md5State = iota
+	md5AwaitingPassword
+	md5Done
+)
+
+// md5Session replays the server side of the MD5 password authentication for
+// a client connection in test mode. The client hashes its password with the
+// salt sent by the server, so that a recorded PasswordMessage only matches
+// when the client is sent the recorded salt and uses the recorded password.
+// The hash can't be reversed to be checked against another salt, hence a
+// fresh salt is sent and the answer of the client is verified using the
+// configured password of the database user instead.
+type md5Session struct {
+	password string
+	state    md5State
+	user     string
+	salt     [4]byte
+}
+
+func newMD5Session(password string) *md5Session {
+	return &md5Session{password: password}
+}
+
+// startupAuthenticatesWithMD5 reports whether the recorded server asked the
+// client for its MD5 hashed password in response to the startup message.
+func startupAuthenticatesWithMD5(h *hooks.Hook) bool {
+	mocks, err := h.GetConfigMocks()
+	if err != nil {
+		return false
+	}
+	for _, mock := range mocks {
+		for i, req := range mock.Spec.PostgresRequests {
+			if req.Identfier != "StartupRequest" || i >= len(mock.Spec.PostgresResponses) {
+				continue
+			}
+			if mock.Spec.PostgresResponses[i].AuthType == AuthTypeMD5Password {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// respond answers the request when it is part of the MD5 exchange. It returns
+// false when the request should go through the mock matching.
+func (s *md5Session) respond(request []byte, h *hooks.Hook) ([]byte, bool, error) {
+	switch s.state {
+	case md5Idle:
+		startup := decodeStartupMessage(request)
+		if startup == nil || !startupAuthenticatesWithMD5(h) {
+			return nil, false, nil
+		}
+		if _, err := rand.Read(s.salt[:]); err != nil {
+			return nil, true, fmt.Errorf("failed to generate the MD5 salt: %v", err)
+		}
+		s.user = startup.Parameters["user"]
+		s.state = md5AwaitingPassword
+		return (&pgproto3.AuthenticationMD5Password{Salt: s.salt}).Encode(nil), true, nil
+	case md5AwaitingPassword:
+		if len(request) < 5 || request[0] != 'p' {
+			return nil, false, nil
+		}
+		msg := &pgproto3.PasswordMessage{}
+		if err := msg.Decode(request[5:]); err != nil {
+			return nil, true, fmt.Errorf("failed to decode the PasswordMessage: %v", err)
+		}
+		s.state = md5Done
+		expected := md5Password(s.password, s.user, s.salt)
+		if subtle.ConstantTimeCompare([]byte(msg.Password), []byte(expected)) != 1 {
+			err := errors.New("password authentication failed for user \"" + s.user + "\"")
+			return authenticationFailed(err), true, err
+		}
+		response := (&pgproto3.AuthenticationOk{}).Encode(nil)
+		return append(response, recordedStartupCompletion(h)...), true, nil
+	}
+	return nil, false, nil
+}
+
+// md5Password returns the answer of a client to an MD5 password request:
+// "md5" followed by md5(md5(password + user) + salt), in hexadecimal.
+func md5Password(password, user string, salt [4]byte) string {
+	inner := md5.Sum([]byte(password + user))
+	outer := md5.Sum(append([]byte(hex.EncodeToString(inner[:])), salt[:]...))
+	return "md5" + hex.EncodeToString(outer[:])
+}
@@ -0,0 +1,115 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// md5Hooks returns the hooks of a recording whose server asked the client
+// for its MD5 hashed password, then completed the startup.
+func md5Hooks(t *testing.T) *hooks.Hook {
+	t.Helper()
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetConfigMocks([]*models.Mock{
+		{
+			Name: "mock-0",
+			Kind: models.Postgres,
+			Spec: models.MockSpec{
+				PostgresRequests:  []models.Backend{{Identfier: "StartupRequest", Payload: base64.StdEncoding.EncodeToString(startupRequest(""))}},
+				PostgresResponses: []models.Frontend{{PacketTypes: []string{"R"}, AuthType: AuthTypeMD5Password}},
+			},
+		},
+		{
+			Name: "mock-1",
+			Kind: models.Postgres,
+			Spec: models.MockSpec{
+				PostgresRequests: []models.Backend{{PacketTypes: []string{"p"}}},
+				PostgresResponses: []models.Frontend{{
+					PacketTypes:             []string{"R", "S", "K", "Z"},
+					AuthType:                AuthTypeOk,
+					ParameterStatusCombined: []pgproto3.ParameterStatus{{Name: "server_version", Value: "15.4"}},
+					BackendKeyData:          pgproto3.BackendKeyData{ProcessID: 42, SecretKey: 7},
+					ReadyForQuery:           pgproto3.ReadyForQuery{TxStatus: 'I'},
+				}},
+			},
+		},
+	})
+	return h
+}
+
+func TestMD5Password(t *testing.T) {
+	// md5("postgrespostgres") is 3175bce1d3201d16594cebf9d7eb3f9d
+	if got := md5Password("postgres", "postgres", [4]byte{1, 2, 3, 4}); got != "md568be9ed08db75f318087ab337aaea044" {
+		t.Errorf("got the hashed password %s", got)
+	}
+}
+
+func TestMD5Session(t *testing.T) {
+	h := md5Hooks(t)
+	completion := (&pgproto3.AuthenticationOk{}).Encode(nil)
+	completion = (&pgproto3.ParameterStatus{Name: "server_version", Value: "15.4"}).Encode(completion)
+	completion = (&pgproto3.BackendKeyData{ProcessID: 42, SecretKey: 7}).Encode(completion)
+	completion = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(completion)
+
+	salts := map[[4]byte]bool{}
+	for _, tt := range []struct {
+		name     string
+		password string
+		fails    bool
+	}{
+		{name: "configured password", password: "secret"},
+		{name: "wrong password", password: "guess", fails: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			session := newMD5Session("secret")
+			response, handled, err := session.respond(startupRequest(""), h)
+			if err != nil || !handled {
+				t.Fatalf("failed to answer the startup message: %v", err)
+			}
+			request := &pgproto3.AuthenticationMD5Password{}
+			if response[0] != 'R' || request.Decode(response[5:]) != nil {
+				t.Fatalf("got the response %q, want an AuthenticationMD5Password", response)
+			}
+			salts[request.Salt] = true
+
+			password := (&pgproto3.PasswordMessage{Password: md5Password(tt.password, "keploy", request.Salt)}).Encode(nil)
+			response, handled, err = session.respond(password, h)
+			if !handled {
+				t.Fatalf("left the PasswordMessage to the mock matching")
+			}
+			if tt.fails {
+				if err == nil || len(response) == 0 || response[0] != 'E' || !bytes.Contains(response, []byte("28P01")) {
+					t.Errorf("got the response %q (%v), want the failed authentication", response, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to authenticate: %v", err)
+			}
+			if !bytes.Equal(response, completion) {
+				t.Errorf("got the response %q, want %q", response, completion)
+			}
+		})
+	}
+	if len(salts) != 2 {
+		t.Errorf("sent the same salt to both clients")
+	}
+}
+
+func TestMD5SessionLeavesOtherRecordingsToTheMocks(t *testing.T) {
+	if _, handled, _ := newMD5Session("secret").respond(startupRequest(""), scramHooks(t)); handled {
+		t.Errorf("answered the startup message of a scram recording")
+	}
+	if _, handled, _ := newMD5Session("secret").respond(SimpleQueryRequest("SELECT 1")[0], md5Hooks(t)); handled {
+		t.Errorf("answered a query before the startup message")
+	}
+}
@@ -0,0 +1,109 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestNegotiateProtocolVersionDecode(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		body    []byte
+		want    models.PostgresNegotiateProtocolVersion
+		invalid bool
+	}{
+		{name: "no options", body: []byte{0, 0, 0, 0, 0, 0, 0, 0}, want: models.PostgresNegotiateProtocolVersion{UnrecognizedOptions: []string{}}},
+		{
+			name: "unrecognized options",
+			body: append([]byte{0, 0, 0, 1, 0, 0, 0, 2}, "_pq_.a\x00_pq_.b\x00"...),
+			want: models.PostgresNegotiateProtocolVersion{NewestMinorProtocol: 1, UnrecognizedOptions: []string{"_pq_.a", "_pq_.b"}},
+		},
+		{name: "missing option", body: append([]byte{0, 0, 0, 0, 0, 0, 0, 2}, "_pq_.a\x00"...), invalid: true},
+		{name: "unterminated option", body: append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, "_pq_.a"...), invalid: true},
+		{name: "trailing bytes", body: append([]byte{0, 0, 0, 0, 0, 0, 0, 1}, "_pq_.a\x00x"...), invalid: true},
+		{name: "negative count", body: []byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff}, invalid: true},
+		{name: "truncated", body: []byte{0, 0, 0, 0}, invalid: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.PostgresNegotiateProtocolVersion
+			err := negotiateProtocolVersion{&got}.Decode(tt.body)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("decoded %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("failed to decode: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if encoded := (negotiateProtocolVersion{&got}).Encode(nil); !bytes.Equal(encoded[5:], tt.body) || int(encoded[4]) != len(encoded)-1 {
+				t.Errorf("encoded %q, want the body %q", encoded, tt.body)
+			}
+		})
+	}
+}
+
+// TestNegotiateProtocolVersionRecorded records a server answering a startup
+// message requesting a protocol option it doesn't support with a
+// NegotiateProtocolVersion, which is replayed as recorded.
+func TestNegotiateProtocolVersionRecorded(t *testing.T) {
+	startup := startupWith(map[string]string{"user": "keploy", "database": "bank", "_pq_.compression": "on"})
+	negotiation := models.PostgresNegotiateProtocolVersion{UnrecognizedOptions: []string{"_pq_.compression"}}
+	started := (negotiateProtocolVersion{&negotiation}).Encode(nil)
+	started = append(started, encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})...)
+
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		if _, err := io.ReadFull(server, make([]byte, len(startup))); err != nil {
+			return
+		}
+		server.Write(started)
+		io.Copy(io.Discard, server)
+	}()
+	go func() {
+		defer app.Close()
+		io.ReadFull(app, make([]byte, len(started)))
+	}()
+	done := make(chan struct{})
+	go func() {
+		encodePostgresOutgoing(startup, clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the recording of the session did not end")
+	}
+
+	if len(db.mocks) == 0 {
+		t.Fatalf("recorded no mock")
+	}
+	responses := db.mocks[0].Spec.PostgresResponses
+	if len(responses) != 1 || !reflect.DeepEqual(responses[0].NegotiateProtocolVersion, negotiation) {
+		t.Fatalf("recorded the responses %+v, want the negotiation %+v", responses, negotiation)
+	}
+	if replayed := replayedBytes(t, db.mocks[0]); !bytes.Equal(replayed, started) {
+		t.Errorf("replayed %q, want %q", replayed, started)
+	}
+}
@@ -0,0 +1,163 @@
+package postgresparser
+
+import (
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// normalizeQueries returns the buffer with the text of its Query and Parse
+// messages normalized by normalizeQuery, so that statements differing only by
+// comments, whitespace or trailing semicolons compare equal. The buffer is
+// returned as is when it has nothing to normalize.
+func normalizeQueries(buffer []byte) []byte {
+	return rewriteQueries(buffer, normalizeQuery)
+}
+
+// rewriteQueries returns the buffer with the text of its Query and Parse
+// messages rewritten, or the buffer as is when the text is unchanged.
+func rewriteQueries(buffer []byte, rewrite func(string) string) []byte {
+	if len(buffer) < 5 || (len(buffer) >= 8 && isStartupPacket(buffer)) {
+		return buffer
+	}
+	var normalized []byte
+	changed := false
+	for i := 0; i < len(buffer); {
+		bodyLen, err := readMessageBodyLen(buffer, i)
+		if err != nil {
+			return buffer
+		}
+		msg := buffer[i : i+5+bodyLen]
+		i += bodyLen + 5
+		switch msg[0] {
+		case 'Q':
+			query := &pgproto3.Query{}
+			if err := query.Decode(msg[5:]); err != nil {
+				normalized = append(normalized, msg...)
+				continue
+			}
+			if text := rewrite(query.String); text != query.String {
+				query.String = text
+				changed = true
+			}
+			normalized = query.Encode(normalized)
+		case 'P':
+			parse := &pgproto3.Parse{}
+			if err := parse.Decode(msg[5:]); err != nil {
+				normalized = append(normalized, msg...)
+				continue
+			}
+			if text := rewrite(parse.Query); text != parse.Query {
+				parse.Query = text
+				changed = true
+			}
+			normalized = parse.Encode(normalized)
+		default:
+			normalized = append(normalized, msg...)
+		}
+	}
+	if !changed {
+		return buffer
+	}
+	return normalized
+}
+
+// normalizeQuery strips the comments and trailing semicolons of a statement
+// and collapses its runs of whitespace into a single space. The string
+// literals, quoted identifiers and dollar-quoted bodies are kept verbatim.
+func normalizeQuery(query string) string {
+	var b strings.Builder
+	space := false
+	emit := func(s string) {
+		if space && b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		space = false
+		b.WriteString(s)
+	}
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+			i++
+		case c == '-' && strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			space = true
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			// block comments nest in postgres
+			depth, j := 0, i
+			for j < len(query) {
+				if strings.HasPrefix(query[j:], "/*") {
+					depth++
+					j += 2
+				} else if strings.HasPrefix(query[j:], "*/") {
+					depth--
+					j += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					j++
+				}
+			}
+			space = true
+			i = j
+		case c == '\'' || c == '"':
+			j := i + 1
+			for j < len(query) {
+				if query[j] == c {
+					// a doubled quote escapes itself
+					if j+1 < len(query) && query[j+1] == c {
+						j += 2
+						continue
+					}
+					j++
+					break
+				}
+				j++
+			}
+			emit(query[i:j])
+			i = j
+		case c == '$':
+			if tag := dollarQuoteTag(query[i:]); tag != "" {
+				end := strings.Index(query[i+len(tag):], tag)
+				j := len(query)
+				if end >= 0 {
+					j = i + len(tag) + end + len(tag)
+				}
+				emit(query[i:j])
+				i = j
+				continue
+			}
+			emit(query[i : i+1])
+			i++
+		default:
+			emit(query[i : i+1])
+			i++
+		}
+	}
+	return strings.TrimRight(b.String(), "; ")
+}
+
+// dollarQuoteTag returns the $tag$ opening a dollar-quoted string at the start
+// of s, or "" when s starts with a positional parameter or a lone $.
+func dollarQuoteTag(s string) string {
+	for j := 1; j < len(s); j++ {
+		c := s[j]
+		switch {
+		case c == '$':
+			return s[:j+1]
+		case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		case c >= '0' && c <= '9' && j > 1:
+		default:
+			return ""
+		}
+	}
+	return ""
+}
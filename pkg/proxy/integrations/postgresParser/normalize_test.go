@@ -0,0 +1,66 @@
+package postgresparser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	for _, tt := range []struct {
+		name, query, want string
+	}{
+		{name: "whitespace", query: "  SELECT\tname\r\n  FROM   users ", want: "SELECT name FROM users"},
+		{name: "trailing semicolons", query: "SELECT 1 ; ;", want: "SELECT 1"},
+		{name: "line comment", query: "SELECT 1 -- the answer\nFROM t", want: "SELECT 1 FROM t"},
+		{name: "trailing line comment", query: "SELECT 1; -- done", want: "SELECT 1"},
+		{name: "block comment", query: "/* app: orders */ SELECT/**/1", want: "SELECT 1"},
+		{name: "nested block comments", query: "SELECT /* outer /* inner */ still */ 1", want: "SELECT 1"},
+		{name: "string literal", query: "SELECT  'a  -- b;'", want: "SELECT 'a  -- b;'"},
+		{name: "doubled quote", query: "SELECT 'it''s  /* here */'", want: "SELECT 'it''s  /* here */'"},
+		{name: "quoted identifier", query: `SELECT "my  col" FROM t`, want: `SELECT "my  col" FROM t`},
+		{name: "dollar quoted", query: "SELECT $fn$ a  -- b $fn$;", want: "SELECT $fn$ a  -- b $fn$"},
+		{name: "anonymous dollar quoted", query: "DO $$ BEGIN  NULL; END $$", want: "DO $$ BEGIN  NULL; END $$"},
+		{name: "positional parameters", query: "SELECT  $1,  $2::int", want: "SELECT $1, $2::int"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeQuery(tt.query); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeQueries(t *testing.T) {
+	recorded := encodeMessages(
+		&pgproto3.Parse{Query: "SELECT name FROM users WHERE id = $1"},
+		&pgproto3.Bind{Parameters: [][]byte{[]byte("42")}},
+		&pgproto3.Sync{},
+	)
+	live := encodeMessages(
+		&pgproto3.Parse{Query: "/* req 7 */ SELECT name\n  FROM users\n WHERE id = $1;"},
+		&pgproto3.Bind{Parameters: [][]byte{[]byte("42")}},
+		&pgproto3.Sync{},
+	)
+	if got := normalizeQueries(live); !bytes.Equal(got, recorded) {
+		t.Errorf("got %q, want %q", got, recorded)
+	}
+	// the parameters are left alone
+	other := encodeMessages(
+		&pgproto3.Parse{Query: "SELECT name FROM users WHERE id = $1"},
+		&pgproto3.Bind{Parameters: [][]byte{[]byte("  43 ")}},
+		&pgproto3.Sync{},
+	)
+	if got := normalizeQueries(other); !bytes.Equal(got, other) {
+		t.Errorf("got %q, want the buffer %q unchanged", got, other)
+	}
+	query := SimpleQueryRequest("SELECT 1;")[0]
+	if got, want := normalizeQueries(query), SimpleQueryRequest("SELECT 1")[0]; !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	startup := startupRequest("")
+	if got := normalizeQueries(startup); !bytes.Equal(got, startup) {
+		t.Errorf("got %q, want the startup message unchanged", got)
+	}
+}
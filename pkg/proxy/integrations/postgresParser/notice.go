@@ -0,0 +1,30 @@
+package postgresparser
+
+// NoticePolicy decides what happens to the NoticeResponse messages sent by the
+// server, which are often emitted by triggers and carry volatile text.
+type NoticePolicy string
+
+const (
+	// NoticeKeep records and replays the notices like any other message.
+	NoticeKeep NoticePolicy = "keep"
+	// NoticeDrop leaves the notices out of the recorded mocks and of the
+	// replayed responses.
+	NoticeDrop NoticePolicy = "drop"
+)
+
+// dropNoticeResponses returns the buffer without its NoticeResponse messages.
+// An incomplete trailing message is kept as it is.
+func dropNoticeResponses(buffer []byte) []byte {
+	filtered := make([]byte, 0, len(buffer))
+	for i := 0; i < len(buffer); {
+		bodyLen, err := readMessageBodyLen(buffer, i)
+		if err != nil {
+			return append(filtered, buffer[i:]...)
+		}
+		if buffer[i] != 'N' {
+			filtered = append(filtered, buffer[i:i+5+bodyLen]...)
+		}
+		i += 5 + bodyLen
+	}
+	return filtered
+}
@@ -0,0 +1,52 @@
+package postgresparser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func noticeResponse(message string) []byte {
+	return (&pgproto3.NoticeResponse{Severity: "NOTICE", Code: "00000", Message: message}).Encode(nil)
+}
+
+func TestDropNoticeResponses(t *testing.T) {
+	complete := (&pgproto3.CommandComplete{CommandTag: []byte("INSERT 0 1")}).Encode(nil)
+	ready := (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(nil)
+	join := func(msgs ...[]byte) []byte { return bytes.Join(msgs, nil) }
+	for _, tt := range []struct {
+		name   string
+		buffer []byte
+		want   []byte
+	}{
+		{name: "no notice", buffer: join(complete, ready), want: join(complete, ready)},
+		{name: "notices", buffer: join(noticeResponse("audit row 1"), complete, noticeResponse("audit row 2"), ready), want: join(complete, ready)},
+		{name: "notices only", buffer: join(noticeResponse("audit row 1")), want: []byte{}},
+		{name: "incomplete trailing message", buffer: join(noticeResponse("audit row 1"), complete, ready[:3]), want: join(complete, ready[:3])},
+	} {
+		if got := dropNoticeResponses(tt.buffer); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNoticesDroppedFromTheRecording(t *testing.T) {
+	query := "INSERT INTO users (name) VALUES ('keploy')"
+	response := noticeResponse("audit trigger fired at 10:00:00")
+	response = (&pgproto3.CommandComplete{CommandTag: []byte("INSERT 0 1")}).Encode(response)
+	response = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(response)
+
+	for _, tt := range []struct {
+		policy NoticePolicy
+		want   []byte
+	}{
+		{policy: NoticeKeep, want: response},
+		{policy: NoticeDrop, want: dropNoticeResponses(response)},
+	} {
+		mock := queryMock(t, recordSession(t, PostgresOptions{NoticePolicy: tt.policy}, (&pgproto3.Query{String: query}).Encode(nil), response), query)
+		if replayed := replayedBytes(t, mock); !bytes.Equal(replayed, tt.want) {
+			t.Errorf("%s: recorded the response %q, want %q", tt.policy, replayed, tt.want)
+		}
+	}
+}
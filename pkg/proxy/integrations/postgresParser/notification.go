@@ -0,0 +1,173 @@
+package postgresparser
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// isAsynchronousMessage reports whether the server may send the message at
+// any time, outside of the responses to the requests.
+func isAsynchronousMessage(msgType byte) bool {
+	return msgType == 'A' || msgType == 'N' || msgType == 'S'
+}
+
+// isAsynchronousResponse reports whether the response only holds the
+// asynchronous messages, with at least one NotificationResponse, as pushed by
+// the server to a client listening to a channel.
+func isAsynchronousResponse(buffer []byte) bool {
+	msgs, ok := splitMessages(buffer)
+	if !ok {
+		return false
+	}
+	notified := false
+	for _, msg := range msgs {
+		if !isAsynchronousMessage(msg[0]) {
+			return false
+		}
+		notified = notified || msg[0] == 'A'
+	}
+	return notified
+}
+
+// asynchronousPrefixLen returns the length of the asynchronous messages at the
+// start of the response.
+func asynchronousPrefixLen(response []byte) int {
+	i := 0
+	for i+5 <= len(response) && isAsynchronousMessage(response[i]) {
+		bodyLen, err := readMessageBodyLen(response, i)
+		if err != nil {
+			break
+		}
+		i += 5 + bodyLen
+	}
+	return i
+}
+
+// hasNotificationDelays reports whether the responses hold notifications
+// recorded with the delay they were pushed after.
+func hasNotificationDelays(responses []models.Frontend) bool {
+	for _, response := range responses {
+		if response.NotificationDelay > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// responseDelays returns the delay to wait for before writing each response,
+// with the pacing of the COPY OUT streams and of the notifications chosen.
+func responseDelays(responses []models.Frontend, copyOut, notifications bool) []time.Duration {
+	delays := make([]time.Duration, len(responses))
+	for i, response := range responses {
+		if copyOut {
+			delays[i] += response.CopyDataDelay
+		}
+		if notifications {
+			delays[i] += response.NotificationDelay
+		}
+	}
+	return delays
+}
+
+// splitIdleNotifications separates the notifications the server pushed while
+// the client was idle, recorded at the end of the exchange with the delay they
+// were pushed after, from the responses to the requests.
+func splitIdleNotifications(responses []models.Frontend) ([]models.Frontend, []models.Frontend) {
+	i := len(responses)
+	for i > 1 && responses[i-1].NotificationDelay > 0 {
+		i--
+	}
+	return responses[:i], responses[i:]
+}
+
+// queuedNotification is a notification waiting for its time to be replayed.
+type queuedNotification struct {
+	msg []byte
+	due time.Time
+}
+
+// notificationQueue replays the notifications pushed while the client was
+// idle on their own, after the response they followed, rather than within the
+// next response. They are written at the recorded pace when paced, right away
+// otherwise, and always before the response to the next request as they were
+// pushed before it.
+type notificationQueue struct {
+	mutex   sync.Mutex
+	conn    net.Conn
+	pending []queuedNotification
+	timer   *time.Timer
+	logger  *zap.Logger
+}
+
+func newNotificationQueue(logger *zap.Logger) *notificationQueue {
+	return &notificationQueue{logger: logger}
+}
+
+// schedule queues the notifications, each due after its delay from the
+// previous one.
+func (q *notificationQueue) schedule(conn net.Conn, msgs [][]byte, delays []time.Duration) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.conn = conn
+	due := time.Now()
+	for i, msg := range msgs {
+		if i < len(delays) {
+			due = due.Add(delays[i])
+		}
+		q.pending = append(q.pending, queuedNotification{msg: msg, due: due})
+	}
+	q.arm()
+}
+
+// arm sets the timer of the next notification due, the mutex being held.
+func (q *notificationQueue) arm() {
+	if q.timer != nil {
+		q.timer.Stop()
+		q.timer = nil
+	}
+	if len(q.pending) > 0 {
+		q.timer = time.AfterFunc(time.Until(q.pending[0].due), q.deliver)
+	}
+}
+
+// deliver writes the notifications which are due.
+func (q *notificationQueue) deliver() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	now := time.Now()
+	for len(q.pending) > 0 && !q.pending[0].due.After(now) {
+		q.write(q.pending[0].msg)
+		q.pending = q.pending[1:]
+	}
+	q.arm()
+}
+
+// flush writes all the queued notifications right away.
+func (q *notificationQueue) flush() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for _, notification := range q.pending {
+		q.write(notification.msg)
+	}
+	q.pending = nil
+	q.arm()
+}
+
+// stop drops the queued notifications once the connection is over.
+func (q *notificationQueue) stop() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.pending = nil
+	q.arm()
+}
+
+func (q *notificationQueue) write(msg []byte) {
+	_, err := q.conn.Write(msg)
+	if err != nil {
+		q.logger.Debug("failed to replay a postgres notification to the client", zap.Error(err))
+	}
+}
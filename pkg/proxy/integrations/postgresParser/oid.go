@@ -0,0 +1,119 @@
+package postgresparser
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// typeNames maps the OIDs of the built-in types of pg_type to their names.
+var typeNames = map[uint32]string{
+	16:   "bool",
+	17:   "bytea",
+	18:   "char",
+	19:   "name",
+	20:   "int8",
+	21:   "int2",
+	22:   "int2vector",
+	23:   "int4",
+	24:   "regproc",
+	25:   "text",
+	26:   "oid",
+	27:   "tid",
+	28:   "xid",
+	29:   "cid",
+	114:  "json",
+	142:  "xml",
+	600:  "point",
+	601:  "lseg",
+	602:  "path",
+	603:  "box",
+	604:  "polygon",
+	628:  "line",
+	650:  "cidr",
+	700:  "float4",
+	701:  "float8",
+	718:  "circle",
+	774:  "macaddr8",
+	790:  "money",
+	829:  "macaddr",
+	869:  "inet",
+	1000: "_bool",
+	1001: "_bytea",
+	1005: "_int2",
+	1007: "_int4",
+	1009: "_text",
+	1014: "_bpchar",
+	1015: "_varchar",
+	1016: "_int8",
+	1021: "_float4",
+	1022: "_float8",
+	1033: "aclitem",
+	1042: "bpchar",
+	1043: "varchar",
+	1082: "date",
+	1083: "time",
+	1114: "timestamp",
+	1115: "_timestamp",
+	1182: "_date",
+	1184: "timestamptz",
+	1185: "_timestamptz",
+	1186: "interval",
+	1231: "_numeric",
+	1266: "timetz",
+	1560: "bit",
+	1562: "varbit",
+	1700: "numeric",
+	2205: "regclass",
+	2206: "regtype",
+	2249: "record",
+	2275: "cstring",
+	2278: "void",
+	2950: "uuid",
+	2951: "_uuid",
+	3614: "tsvector",
+	3615: "tsquery",
+	3802: "jsonb",
+	3807: "_jsonb",
+	3904: "int4range",
+	3906: "numrange",
+	3908: "tsrange",
+	3910: "tstzrange",
+	3912: "daterange",
+	3926: "int8range",
+	4089: "regnamespace",
+}
+
+var typeNamesMu sync.RWMutex
+
+// RegisterTypeName adds the name of a custom type (enum, domain, extension
+// type, ...) used to annotate the recorded RowDescriptions.
+func RegisterTypeName(oid uint32, name string) {
+	typeNamesMu.Lock()
+	defer typeNamesMu.Unlock()
+	typeNames[oid] = name
+}
+
+// TypeName returns the name of the type having the OID. Unknown OIDs are
+// returned as is.
+func TypeName(oid uint32) string {
+	typeNamesMu.RLock()
+	defer typeNamesMu.RUnlock()
+	if name, ok := typeNames[oid]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(oid), 10)
+}
+
+// columnTypeNames returns the type names of the columns of the RowDescription.
+func columnTypeNames(rd pgproto3.RowDescription) []string {
+	if len(rd.Fields) == 0 {
+		return nil
+	}
+	names := make([]string, len(rd.Fields))
+	for i, field := range rd.Fields {
+		names[i] = TypeName(field.DataTypeOID)
+	}
+	return names
+}
@@ -0,0 +1,44 @@
+package postgresparser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestColumnTypeNames(t *testing.T) {
+	// the OID of a custom enum, unknown until it is registered
+	const mood = 16385
+	rd := pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{
+		{Name: []byte("id"), DataTypeOID: 23},
+		{Name: []byte("balance"), DataTypeOID: 1700},
+		{Name: []byte("tags"), DataTypeOID: 1007},
+		{Name: []byte("profile"), DataTypeOID: 3802},
+		{Name: []byte("mood"), DataTypeOID: mood},
+	}}
+	if got, want := columnTypeNames(rd), []string{"int4", "numeric", "_int4", "jsonb", "16385"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the type names %v, want %v", got, want)
+	}
+
+	RegisterTypeName(mood, "mood")
+	defer func() {
+		typeNamesMu.Lock()
+		delete(typeNames, mood)
+		typeNamesMu.Unlock()
+	}()
+	if got := columnTypeNames(rd)[4]; got != "mood" {
+		t.Errorf("got the type name %q of the registered type, want mood", got)
+	}
+	if got := columnTypeNames(pgproto3.RowDescription{}); got != nil {
+		t.Errorf("got the type names %v without columns", got)
+	}
+}
+
+func TestRecordedRowDescriptionTypes(t *testing.T) {
+	query := "SELECT id FROM events"
+	mock := queryMock(t, recordSession(t, PostgresOptions{}, (&pgproto3.Query{String: query}).Encode(nil), rowsResponse(1)), query)
+	if got := mock.Spec.PostgresResponses[0].RowDescriptionTypes; !reflect.DeepEqual(got, []string{"int4"}) {
+		t.Errorf("recorded the column types %v, want int4", got)
+	}
+}
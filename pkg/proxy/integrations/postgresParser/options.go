@@ -0,0 +1,155 @@
+package postgresparser
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.uber.org/zap"
+)
+
+// PostgresOptions configures a PostgresParser at once. The zero value of
+// every field keeps the default behaviour of the parser, so that only the
+// options of interest have to be set.
+type PostgresOptions struct {
+	// TLSDecryption holds the keys used to decrypt the SSL sessions in record
+	// mode. The sessions are passed through encrypted when nil.
+	TLSDecryption *util.TLSDecryptConfig
+	// IntrospectionOverrides maps the server settings, such as
+	// server_version, to the values replayed for the introspection queries.
+	IntrospectionOverrides map[string]string
+	// Password is the password of the database user, needed to replay the
+	// SCRAM-SHA-256 authentication and the MD5 password authentication with
+	// a fresh salt. The recorded authentication is replayed when empty.
+	Password string
+	// NoticePolicy decides whether the notices are recorded and replayed,
+	// NoticeKeep by default.
+	NoticePolicy NoticePolicy
+	// FuzzyMatchReporter is notified of the requests which only matched a
+	// mock by similarity.
+	FuzzyMatchReporter func(FuzzyMatch)
+	// MinSimilarity is the lowest similarity accepted for the requests
+	// without an exact match, 0.5 when zero.
+	MinSimilarity float64
+	// MaxSessionDuration flushes the messages of a recorded session as a mock
+	// once it elapses, unbounded when zero. The rest of the session is not
+	// recorded then, unless ContinueSession is set.
+	MaxSessionDuration time.Duration
+	ContinueSession    bool
+	// IgnoredStartupParameters are left out when matching the startup
+	// message, along with application_name.
+	IgnoredStartupParameters []string
+	// VirtualMockHandler computes the responses of the unmatched requests.
+	VirtualMockHandler VirtualMockHandler
+	// JSONNoise are the JSON paths ignored in the JSON parameters of the
+	// requests.
+	JSONNoise []string
+	// WriteChunkSize bounds the writes of the replayed responses, 64KB when
+	// zero.
+	WriteChunkSize int
+	// StalenessTolerance maps the columns whose live values may drift from
+	// the recorded ones to the largest accepted difference.
+	StalenessTolerance map[string]float64
+	// QueryTransform rewrites the recorded queries before they are stored.
+	QueryTransform QueryTransform
+	// MaxDataRows caps the rows of a response recorded in a readable form,
+	// unbounded when zero.
+	MaxDataRows int
+	// IntrospectionOnly records only the schema introspection.
+	IntrospectionOnly bool
+	// RecordUnmatched records, in test mode, the connections without any
+	// recorded session.
+	RecordUnmatched bool
+	// PaceCopyOut replays the chunks of the COPY OUT streams with the delays
+	// recorded between them.
+	PaceCopyOut bool
+	// ReadTimeout is how long the requests of a client are read in test mode
+	// before they are matched, 10ms when zero. It can be overridden per
+	// connection with WithReadTimeout.
+	ReadTimeout time.Duration
+	// ReplayJitter delays the replayed responses by a random duration.
+	ReplayJitter ReplayJitter
+	// MatchMode decides how strictly the statements are compared with the
+	// recorded ones, MatchExact when empty.
+	MatchMode MatchMode
+	// PaceNotifications replays the notifications pushed by the server with
+	// the delays they were recorded after.
+	PaceNotifications bool
+	// SkipAuthentication answers the startup messages with an
+	// AuthenticationOk in test mode, unless Password is set.
+	SkipAuthentication bool
+	// MaxPendingBytes caps the size of the incomplete messages of a
+	// connection, in both modes, 64MB when zero.
+	MaxPendingBytes int
+	// RecordingWarmup leaves out the exchanges of every connection until it
+	// is over, recording from the start when zero.
+	RecordingWarmup RecordingWarmup
+	// IgnoredFields are the values left out when matching the requests and
+	// comparing the responses, replayed as recorded.
+	IgnoredFields IgnoredFields
+	// ReplayLatency delays the replayed responses by the latency they were
+	// recorded with, capped by MaxReplayLatency, 5s when zero.
+	ReplayLatency    bool
+	MaxReplayLatency time.Duration
+	// RecordRawPayloads keeps the raw payload of every recorded message along
+	// with its readable form.
+	RecordRawPayloads bool
+	// DriftReporter is notified, in record mode, of the exchanges whose live
+	// responses diverged from the DriftMocks.
+	DriftReporter func(Drift)
+	DriftMocks    []*models.Mock
+	// DeduplicateMocks records the exchanges repeated identically during a
+	// recording as a single mock.
+	DeduplicateMocks bool
+}
+
+// NewPostgresParserWithOptions returns a parser configured with the options.
+func NewPostgresParserWithOptions(logger *zap.Logger, h *hooks.Hook, opts PostgresOptions) *PostgresParser {
+	return &PostgresParser{
+		logger:          logger,
+		hooks:           h,
+		opts:            opts.withDefaults(),
+		statementNames:  newStatementNames(),
+		mockDigests:     newMockDigests(),
+		mockOccurrences: newMockOccurrences(),
+	}
+}
+
+// withDefaults returns the options with the defaults of the parser in place
+// of the zero values. The names of the server settings and of the columns
+// are lower cased, as they are compared case-insensitively.
+func (o PostgresOptions) withDefaults() PostgresOptions {
+	if o.MinSimilarity <= 0 {
+		o.MinSimilarity = defaultMinSimilarity
+	}
+	if o.WriteChunkSize <= 0 {
+		o.WriteChunkSize = defaultWriteChunkSize
+	}
+	if o.ReadTimeout <= 0 {
+		o.ReadTimeout = defaultReadTimeout
+	}
+	if o.MaxPendingBytes <= 0 {
+		o.MaxPendingBytes = defaultMaxPendingBytes
+	}
+	if o.MaxReplayLatency <= 0 {
+		o.MaxReplayLatency = defaultMaxReplayLatency
+	}
+	if o.IntrospectionOverrides != nil {
+		overrides := make(map[string]string, len(o.IntrospectionOverrides))
+		for name, value := range o.IntrospectionOverrides {
+			overrides[strings.ToLower(name)] = value
+		}
+		o.IntrospectionOverrides = overrides
+	}
+	if o.StalenessTolerance != nil {
+		tolerances := make(map[string]float64, len(o.StalenessTolerance))
+		for column, delta := range o.StalenessTolerance {
+			tolerances[strings.ToLower(column)] = math.Abs(delta)
+		}
+		o.StalenessTolerance = tolerances
+	}
+	return o
+}
@@ -0,0 +1,92 @@
+package postgresparser
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestNewPostgresParserWithOptionsDefaults(t *testing.T) {
+	p := NewPostgresParserWithOptions(zap.NewNop(), nil, PostgresOptions{})
+	if p.opts.WriteChunkSize != defaultWriteChunkSize {
+		t.Errorf("writes the responses in chunks of %d bytes, want %d", p.opts.WriteChunkSize, defaultWriteChunkSize)
+	}
+	if p.opts.ReadTimeout != defaultReadTimeout {
+		t.Errorf("reads the requests for %s, want %s", p.opts.ReadTimeout, defaultReadTimeout)
+	}
+	if p.opts.MaxPendingBytes != defaultMaxPendingBytes {
+		t.Errorf("caps the pending messages at %d bytes, want %d", p.opts.MaxPendingBytes, defaultMaxPendingBytes)
+	}
+	if p.opts.MinSimilarity != defaultMinSimilarity {
+		t.Errorf("accepts a similarity of %v, want %v", p.opts.MinSimilarity, defaultMinSimilarity)
+	}
+	if p.opts.MaxReplayLatency != defaultMaxReplayLatency {
+		t.Errorf("caps the replayed latency at %s, want %s", p.opts.MaxReplayLatency, defaultMaxReplayLatency)
+	}
+	if conn := p.newConnection(context.Background(), zap.NewNop()); conn.digests != nil {
+		t.Errorf("leaves out the duplicate exchanges without DeduplicateMocks")
+	}
+}
+
+func TestNewPostgresParserWithOptions(t *testing.T) {
+	overrides := map[string]string{"Server_Version": "15.4"}
+	tolerances := map[string]float64{"Updated_At": -5}
+	p := NewPostgresParserWithOptions(zap.NewNop(), nil, PostgresOptions{
+		Password:               "secret",
+		IntrospectionOverrides: overrides,
+		StalenessTolerance:     tolerances,
+		WriteChunkSize:         512,
+		ReadTimeout:            time.Second,
+		MaxPendingBytes:        1024,
+		MinSimilarity:          0.8,
+		ReplayLatency:          true,
+		MaxReplayLatency:       time.Millisecond,
+		MatchMode:              MatchTemplate,
+		DeduplicateMocks:       true,
+	})
+	if p.opts.Password != "secret" {
+		t.Errorf("replays the authentication with the password %q", p.opts.Password)
+	}
+	if p.opts.WriteChunkSize != 512 || p.opts.ReadTimeout != time.Second || p.opts.MaxPendingBytes != 1024 || p.opts.MinSimilarity != 0.8 {
+		t.Errorf("replaced the configured sizes and durations: %+v", p.opts)
+	}
+	if !p.opts.ReplayLatency || p.opts.MaxReplayLatency != time.Millisecond || p.opts.MatchMode != MatchTemplate {
+		t.Errorf("replaced the configured replay: %+v", p.opts)
+	}
+	if got := p.opts.IntrospectionOverrides["server_version"]; got != "15.4" {
+		t.Errorf("replays the server_version %q, want the lower cased setting", got)
+	}
+	if got := p.opts.StalenessTolerance["updated_at"]; got != 5 {
+		t.Errorf("tolerates a drift of %v of updated_at, want the lower cased column and 5", got)
+	}
+	if _, ok := overrides["server_version"]; ok || tolerances["Updated_At"] != -5 {
+		t.Errorf("modified the maps of the caller: %v %v", overrides, tolerances)
+	}
+	if conn := p.newConnection(context.Background(), zap.NewNop()); conn.digests != p.mockDigests || conn.names != p.statementNames || conn.occurrences != p.mockOccurrences {
+		t.Errorf("connection does not share the state of the parser")
+	}
+}
+
+func TestWithReadTimeout(t *testing.T) {
+	p := NewPostgresParserWithOptions(zap.NewNop(), nil, PostgresOptions{ReadTimeout: time.Second})
+	for _, tt := range []struct {
+		name string
+		ctx  context.Context
+		want time.Duration
+	}{
+		{name: "parser", ctx: context.Background(), want: time.Second},
+		{name: "context", ctx: WithReadTimeout(context.Background(), 50*time.Millisecond), want: 50 * time.Millisecond},
+		{name: "zero", ctx: WithReadTimeout(context.Background(), 0), want: time.Second},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.contextOptions(tt.ctx).ReadTimeout; got != tt.want {
+				t.Errorf("reads the requests for %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if p.opts.ReadTimeout != time.Second {
+		t.Errorf("changed the read timeout of the parser to %s", p.opts.ReadTimeout)
+	}
+}
@@ -0,0 +1,50 @@
+package postgresparser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// TestParameterDescriptionsRecorded records the pipelined descriptions of two
+// statements, whose parameter types are replayed in order.
+func TestParameterDescriptionsRecorded(t *testing.T) {
+	request := (&pgproto3.Parse{Name: "by_id", Query: "SELECT name FROM users WHERE id = $1"}).Encode(nil)
+	request = (&pgproto3.Describe{ObjectType: 'S', Name: "by_id"}).Encode(request)
+	request = (&pgproto3.Parse{Name: "credit", Query: "UPDATE accounts SET balance = balance + $2 WHERE owner = $1"}).Encode(request)
+	request = (&pgproto3.Describe{ObjectType: 'S', Name: "credit"}).Encode(request)
+	request = (&pgproto3.Sync{}).Encode(request)
+
+	response := (&pgproto3.ParseComplete{}).Encode(nil)
+	response = (&pgproto3.ParameterDescription{ParameterOIDs: []uint32{23}}).Encode(response)
+	response = (&pgproto3.NoData{}).Encode(response)
+	response = (&pgproto3.ParseComplete{}).Encode(response)
+	response = (&pgproto3.ParameterDescription{ParameterOIDs: []uint32{25, 1700}}).Encode(response)
+	response = (&pgproto3.NoData{}).Encode(response)
+	response = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(response)
+
+	mocks := recordSession(t, PostgresOptions{}, request, response)
+	if len(mocks) == 0 {
+		t.Fatalf("recorded no mock")
+	}
+	mock := mocks[len(mocks)-1]
+	if len(mock.Spec.PostgresResponses) != 1 {
+		t.Fatalf("recorded %d responses, want 1", len(mock.Spec.PostgresResponses))
+	}
+	recorded := mock.Spec.PostgresResponses[0]
+	var oids [][]uint32
+	for _, description := range recorded.ParameterDescriptions {
+		oids = append(oids, description.ParameterOIDs)
+	}
+	if want := [][]uint32{{23}, {25, 1700}}; !reflect.DeepEqual(oids, want) {
+		t.Errorf("recorded the parameter types %v, want %v", oids, want)
+	}
+	if recorded.Payload != "" {
+		t.Errorf("recorded the raw payload of a response its readable form reproduces")
+	}
+	if replayed := replayedBytes(t, mock); !bytes.Equal(replayed, response) {
+		t.Errorf("replayed %q, want %q", replayed, response)
+	}
+}
@@ -0,0 +1,95 @@
+package postgresparser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// TestRepeatedMessagesRecorded records the responses of three pipelines sent
+// at once, which repeat their row descriptions, errors and ReadyForQuery in a
+// single buffer, and replays them in wire order.
+func TestRepeatedMessagesRecorded(t *testing.T) {
+	request := encodeMessages(
+		&pgproto3.Parse{Name: "users", Query: "SELECT name FROM users WHERE id = $1"},
+		&pgproto3.Describe{ObjectType: 'S', Name: "users"},
+		&pgproto3.Sync{},
+		&pgproto3.Parse{Name: "orders", Query: "SELECT id, total FROM orders WHERE owner = $1"},
+		&pgproto3.Describe{ObjectType: 'S', Name: "orders"},
+		&pgproto3.Sync{},
+		&pgproto3.Parse{Name: "audit", Query: "SELECT * FROM audit"},
+		&pgproto3.Sync{},
+	)
+	users := pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte("name"), DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1}}}
+	orders := pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{
+		{Name: []byte("id"), DataTypeOID: 23, DataTypeSize: 4, TypeModifier: -1},
+		{Name: []byte("total"), DataTypeOID: 1700, DataTypeSize: -1, TypeModifier: -1},
+	}}
+	response := encodeMessages(
+		&pgproto3.ParseComplete{},
+		&pgproto3.ParameterDescription{ParameterOIDs: []uint32{23}},
+		&users,
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+		&pgproto3.ParseComplete{},
+		&pgproto3.ParameterDescription{ParameterOIDs: []uint32{25}},
+		&orders,
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+		&pgproto3.ErrorResponse{Severity: "ERROR", SeverityUnlocalized: "ERROR", Code: "42P01", Message: `relation "audit" does not exist`},
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+	)
+
+	mocks := recordSession(t, PostgresOptions{}, request, response)
+	if len(mocks) == 0 {
+		t.Fatalf("recorded no mock")
+	}
+	mock := mocks[len(mocks)-1]
+	if len(mock.Spec.PostgresResponses) != 1 {
+		t.Fatalf("recorded %d responses, want 1", len(mock.Spec.PostgresResponses))
+	}
+	recorded := mock.Spec.PostgresResponses[0]
+	if len(recorded.RowDescriptions) != 2 || string(recorded.RowDescriptions[1].Fields[1].Name) != "total" {
+		t.Errorf("recorded the row descriptions %+v, want those of users and orders", recorded.RowDescriptions)
+	}
+	if len(recorded.ReadyForQueries) != 3 {
+		t.Errorf("recorded %d ReadyForQuery, want 3", len(recorded.ReadyForQueries))
+	}
+	if recorded.Payload != "" {
+		t.Errorf("recorded the raw payload of a response its readable form reproduces")
+	}
+	if replayed := replayedBytes(t, mock); !bytes.Equal(replayed, response) {
+		t.Errorf("replayed %q, want %q", replayed, response)
+	}
+}
+
+func TestRepeatedMessagesDecoded(t *testing.T) {
+	response := models.Frontend{
+		PacketTypes:    []string{"N", "E", "Z", "N", "E", "Z"},
+		NoticeResponse: pgproto3.NoticeResponse{Severity: "NOTICE", Message: "singular"},
+		NoticeResponses: []pgproto3.NoticeResponse{
+			{Severity: "NOTICE", Message: "first notice"},
+			{Severity: "NOTICE", Message: "second notice"},
+		},
+		ErrorResponses: []pgproto3.ErrorResponse{
+			{Severity: "ERROR", Code: "23505", Message: "first error"},
+			{Severity: "ERROR", Code: "23503", Message: "second error"},
+		},
+		ReadyForQueries: []pgproto3.ReadyForQuery{{TxStatus: 'E'}, {TxStatus: 'I'}},
+	}
+	want := encodeMessages(
+		&pgproto3.NoticeResponse{Severity: "NOTICE", Message: "first notice"},
+		&pgproto3.ErrorResponse{Severity: "ERROR", Code: "23505", Message: "first error"},
+		&pgproto3.ReadyForQuery{TxStatus: 'E'},
+		&pgproto3.NoticeResponse{Severity: "NOTICE", Message: "second notice"},
+		&pgproto3.ErrorResponse{Severity: "ERROR", Code: "23503", Message: "second error"},
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+	)
+	got, err := PostgresDecoderFrontend(response)
+	if err != nil {
+		t.Fatalf("failed to encode the response: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
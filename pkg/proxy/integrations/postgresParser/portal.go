@@ -0,0 +1,127 @@
+package postgresparser
+
+import (
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// executeRowLimits returns the row limits of the Execute messages sent by the
+// client, in order. A limit of zero means all the rows are requested.
+func executeRowLimits(requestBuffers [][]byte) []uint32 {
+	limits := []uint32{}
+	for _, buffer := range requestBuffers {
+		for i := 0; i+5 <= len(buffer); {
+			bodyLen, err := readMessageBodyLen(buffer, i)
+			if err != nil {
+				break
+			}
+			if buffer[i] == 'E' {
+				execute := &pgproto3.Execute{}
+				if err := execute.Decode(buffer[i+5 : i+5+bodyLen]); err == nil {
+					limits = append(limits, execute.MaxRows)
+				}
+			}
+			i += 5 + bodyLen
+		}
+	}
+	return limits
+}
+
+// enforceRowLimits rewrites the replayed response so that every Execute returns
+// at most the rows requested by the client. When rows are dropped, the portal is
+// reported as suspended in place of the CommandComplete, as the server does.
+func enforceRowLimits(response []byte, limits []uint32) []byte {
+	limited := false
+	for _, limit := range limits {
+		if limit > 0 {
+			limited = true
+		}
+	}
+	if !limited {
+		return response
+	}
+
+	rewritten := make([]byte, 0, len(response))
+	execute, rows, dropped := 0, uint32(0), false
+	for i := 0; i < len(response); {
+		bodyLen, err := readMessageBodyLen(response, i)
+		if err != nil {
+			// keep the undecodable tail as it was recorded
+			return append(rewritten, response[i:]...)
+		}
+		msg := response[i : i+5+bodyLen]
+		i += 5 + bodyLen
+
+		var limit uint32
+		if execute < len(limits) {
+			limit = limits[execute]
+		}
+		switch msg[0] {
+		case 'D':
+			if limit > 0 && rows >= limit {
+				dropped = true
+				continue
+			}
+			rows++
+		case 'C':
+			if dropped {
+				msg = (&pgproto3.PortalSuspended{}).Encode(nil)
+			}
+			fallthrough
+		case 's', 'I', 'E':
+			execute++
+			rows, dropped = 0, false
+		}
+		rewritten = append(rewritten, msg...)
+	}
+	return rewritten
+}
+
+// portalQueryMetadata is the metadata key of the query bound to the unnamed
+// portal which the requests of a mock describe without binding it.
+const portalQueryMetadata = "portal_query"
+
+// describedPortal returns the query bound to the unnamed portal when the
+// requests describe it before binding it themselves. Drivers bind the unnamed
+// portal and describe it in separate round trips, so the RowDescription of
+// such requests depends on the Bind which preceded them.
+func (r *statementRegistry) describedPortal(requestBuffers [][]byte) (string, bool) {
+	bound, described := false, false
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		switch msgType {
+		case 'B':
+			bind := &pgproto3.Bind{}
+			if bind.Decode(body) == nil && bind.DestinationPortal == "" {
+				bound = true
+			}
+		case 'D':
+			describe := &pgproto3.Describe{}
+			if describe.Decode(body) == nil && describe.ObjectType == 'P' && describe.Name == "" && !bound {
+				described = true
+			}
+		}
+	})
+	if !described || r.unnamedPortal == "" {
+		return "", false
+	}
+	return r.unnamedPortal, true
+}
+
+// filterMocksByPortal leaves out the mocks which described the unnamed portal
+// bound to another query than the active one.
+func filterMocksByPortal(mocks []*models.Mock, portalQuery string) []*models.Mock {
+	if portalQuery == "" {
+		return mocks
+	}
+	filtered := make([]*models.Mock, 0, len(mocks))
+	for _, mock := range mocks {
+		if mock == nil {
+			continue
+		}
+		if recorded := mock.Spec.Metadata[portalQueryMetadata]; recorded != "" && normalizeQuery(recorded) != normalizeQuery(portalQuery) {
+			continue
+		}
+		filtered = append(filtered, mock)
+	}
+	return filtered
+}
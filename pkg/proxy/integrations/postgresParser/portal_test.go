@@ -0,0 +1,200 @@
+package postgresparser
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// executeRequest returns the buffer binding the statement to the portal and
+// executing it with the row limit.
+func executeRequest(maxRows ...uint32) []byte {
+	buffer := (&pgproto3.Parse{Query: "SELECT id FROM events"}).Encode(nil)
+	for _, limit := range maxRows {
+		buffer = (&pgproto3.Bind{}).Encode(buffer)
+		buffer = (&pgproto3.Execute{MaxRows: limit}).Encode(buffer)
+	}
+	return (&pgproto3.Sync{}).Encode(buffer)
+}
+
+// portalResult returns the response of an Execute of the rows, ended by its
+// CommandComplete, or its PortalSuspended when suspended.
+func portalResult(rows int, suspended bool) []byte {
+	buffer := (&pgproto3.BindComplete{}).Encode(nil)
+	for i := 0; i < rows; i++ {
+		buffer = (&pgproto3.DataRow{RowValues: []string{strconv.Itoa(i)}}).Encode(buffer)
+	}
+	if suspended {
+		return (&pgproto3.PortalSuspended{}).Encode(buffer)
+	}
+	return (&pgproto3.CommandComplete{CommandTag: []byte("SELECT " + strconv.Itoa(rows))}).Encode(buffer)
+}
+
+// executeResponse returns the response of the pipeline of the results.
+func executeResponse(results ...[]byte) []byte {
+	buffer := (&pgproto3.ParseComplete{}).Encode(nil)
+	for _, result := range results {
+		buffer = append(buffer, result...)
+	}
+	return (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(buffer)
+}
+
+func TestExecuteRowLimits(t *testing.T) {
+	got := executeRowLimits([][]byte{executeRequest(2, 0), executeRequest(5)})
+	if want := []uint32{2, 0, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got the row limits %v, want %v", got, want)
+	}
+	if got := executeRowLimits(SimpleQueryRequest("SELECT 1")); len(got) != 0 {
+		t.Errorf("got the row limits %v of a simple query", got)
+	}
+}
+
+func TestEnforceRowLimits(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		recorded []byte
+		limits   []uint32
+		want     []byte
+	}{
+		{name: "no limit", recorded: executeResponse(portalResult(5, false)), limits: []uint32{0}, want: executeResponse(portalResult(5, false))},
+		{name: "rows within the limit", recorded: executeResponse(portalResult(2, false)), limits: []uint32{3}, want: executeResponse(portalResult(2, false))},
+		{name: "rows of the limit", recorded: executeResponse(portalResult(2, false)), limits: []uint32{2}, want: executeResponse(portalResult(2, false))},
+		{name: "rows beyond the limit", recorded: executeResponse(portalResult(5, false)), limits: []uint32{2}, want: executeResponse(portalResult(2, true))},
+		{name: "limit per execute", recorded: executeResponse(portalResult(4, false), portalResult(4, false)), limits: []uint32{0, 1}, want: executeResponse(portalResult(4, false), portalResult(1, true))},
+	} {
+		if got := enforceRowLimits(tt.recorded, tt.limits); !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got the response %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// the undecodable tail is kept as recorded
+	truncated := append(executeResponse(portalResult(3, false)), 'D', 0, 0)
+	want := append(executeResponse(portalResult(1, true)), 'D', 0, 0)
+	if got := enforceRowLimits(truncated, []uint32{1}); !bytes.Equal(got, want) {
+		t.Errorf("got the response %q, want %q", got, want)
+	}
+}
+
+// describePortalRequest returns the buffer describing the unnamed portal
+// without binding it.
+func describePortalRequest() []byte {
+	return encodeMessages(&pgproto3.Describe{ObjectType: 'P'}, &pgproto3.Sync{})
+}
+
+func TestDescribedPortal(t *testing.T) {
+	bind := encodeMessages(&pgproto3.Bind{PreparedStatement: "events"}, &pgproto3.Sync{})
+	for _, tt := range []struct {
+		name     string
+		observed [][]byte
+		request  []byte
+		want     string
+	}{
+		{name: "bound earlier", observed: [][]byte{prepareRequest("events", "SELECT id FROM events"), bind}, request: describePortalRequest(), want: "SELECT id FROM events"},
+		{name: "never bound", observed: [][]byte{prepareRequest("events", "SELECT id FROM events")}, request: describePortalRequest()},
+		{
+			name:     "bound by the request",
+			observed: [][]byte{prepareRequest("events", "SELECT id FROM events"), bind},
+			request:  encodeMessages(&pgproto3.Bind{PreparedStatement: "events"}, &pgproto3.Describe{ObjectType: 'P'}, &pgproto3.Sync{}),
+		},
+		{
+			name:     "named portal",
+			observed: [][]byte{prepareRequest("events", "SELECT id FROM events"), bind},
+			request:  encodeMessages(&pgproto3.Describe{ObjectType: 'P', Name: "cursor"}, &pgproto3.Sync{}),
+		},
+		{
+			name:     "statement described",
+			observed: [][]byte{prepareRequest("events", "SELECT id FROM events"), bind},
+			request:  encodeMessages(&pgproto3.Describe{ObjectType: 'S', Name: "events"}, &pgproto3.Sync{}),
+		},
+		{
+			name:     "portal closed",
+			observed: [][]byte{prepareRequest("events", "SELECT id FROM events"), bind, encodeMessages(&pgproto3.Close{Object_Type: 'P'}, &pgproto3.Sync{})},
+			request:  describePortalRequest(),
+		},
+		{
+			name:     "destroyed by a simple query",
+			observed: [][]byte{prepareRequest("events", "SELECT id FROM events"), bind, SimpleQueryRequest("SELECT 1")[0]},
+			request:  describePortalRequest(),
+		},
+		{
+			name:     "bound again",
+			observed: [][]byte{prepareRequest("events", "SELECT id FROM events", "orders", "SELECT id FROM orders"), bind, encodeMessages(&pgproto3.Bind{PreparedStatement: "orders"}, &pgproto3.Sync{})},
+			request:  describePortalRequest(),
+			want:     "SELECT id FROM orders",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newStatementRegistry()
+			registry.observe(tt.observed)
+			got, ok := registry.describedPortal([][]byte{tt.request})
+			if got != tt.want || ok != (tt.want != "") {
+				t.Errorf("got the portal query %q, %v, want %q", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterMocksByPortal(t *testing.T) {
+	mocks := []*models.Mock{
+		{Name: "events", Spec: models.MockSpec{Metadata: map[string]string{portalQueryMetadata: "SELECT id FROM events"}}},
+		{Name: "orders", Spec: models.MockSpec{Metadata: map[string]string{portalQueryMetadata: "SELECT id FROM orders"}}},
+		{Name: "unscoped", Spec: models.MockSpec{Metadata: map[string]string{}}},
+		nil,
+	}
+	for _, tt := range []struct {
+		name        string
+		portalQuery string
+		want        []string
+	}{
+		{name: "no portal described", want: []string{"events", "orders", "unscoped", ""}},
+		{name: "portal of events", portalQuery: "SELECT id FROM events", want: []string{"events", "unscoped"}},
+		{name: "normalized query", portalQuery: "SELECT id\n  FROM orders;", want: []string{"orders", "unscoped"}},
+		{name: "portal of another query", portalQuery: "SELECT id FROM users", want: []string{"unscoped"}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, mock := range filterMocksByPortal(mocks, tt.portalQuery) {
+				name := ""
+				if mock != nil {
+					name = mock.Name
+				}
+				got = append(got, name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got the mocks %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPortalQueryRecorded records the Describe of the unnamed portal sent in
+// another round trip than its Bind, scoped to the query bound to the portal.
+func TestPortalQueryRecorded(t *testing.T) {
+	bind := encodeMessages(
+		&pgproto3.Parse{Name: "events", Query: "SELECT id FROM events"},
+		&pgproto3.Bind{PreparedStatement: "events"},
+		&pgproto3.Sync{},
+	)
+	bound := encodeMessages(&pgproto3.ParseComplete{}, &pgproto3.BindComplete{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	described := encodeMessages(
+		&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte("id"), DataTypeOID: 23, DataTypeSize: 4, TypeModifier: -1}}},
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+	)
+	mocks := recordExchanges(t, PostgresOptions{},
+		pgExchange{request: bind, response: bound},
+		pgExchange{request: describePortalRequest(), response: described},
+	)
+	if len(mocks) < 2 {
+		t.Fatalf("recorded %d mocks, want the Bind and the Describe", len(mocks))
+	}
+	if got := mocks[len(mocks)-2].Spec.Metadata[portalQueryMetadata]; got != "" {
+		t.Errorf("scoped the Bind to the portal query %q", got)
+	}
+	if got := mocks[len(mocks)-1].Spec.Metadata[portalQueryMetadata]; got != "SELECT id FROM events" {
+		t.Errorf("scoped the Describe to the portal query %q, want %q", got, "SELECT id FROM events")
+	}
+}
@@ -2,9 +2,11 @@
 package postgresparser
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -31,18 +33,90 @@ var Emoji = "\U0001F430" + " Keploy:"
 type PostgresParser struct {
 	logger *zap.Logger
 	hooks  *hooks.Hook
+	// opts are the options of the parser, with the defaults in place of
+	// the zero values.
+	opts PostgresOptions
+	// statementNames are the statements prepared by all the connections
+	// recorded by the parser.
+	statementNames *statementNames
+	// mockDigests are the content hashes of the mocks recorded by all the
+	// connections, to leave out the duplicates.
+	mockDigests *mockDigests
+	// mockOccurrences number the mocks recorded by all the connections for
+	// the same requests, and count their replays in test mode.
+	mockOccurrences *mockOccurrences
 }
 
-func NewPostgresParser(logger *zap.Logger, h *hooks.Hook) *PostgresParser {
-	return &PostgresParser{
-		logger: logger,
-		hooks:  h,
+// requestNoise returns the parts of the requests left out when matching them
+// with the mocks, on a connection with the statements prepared.
+func (o PostgresOptions) requestNoise(statements *statementRegistry) requestNoise {
+	return requestNoise{jsonPaths: o.JSONNoise, ignored: o.IgnoredFields, statements: statements}
+}
+
+// defaultWriteChunkSize is the size of the writes of the replayed responses
+// unless configured otherwise.
+const defaultWriteChunkSize = 64 * 1024
+
+// defaultMaxPendingBytes caps the partial messages of a connection unless
+// configured otherwise.
+const defaultMaxPendingBytes = 64 * 1024 * 1024
+
+// sessionFlushRetry is how long the flush of a session reaching its maximum
+// duration is put off while an exchange is in progress.
+const sessionFlushRetry = 100 * time.Millisecond
+
+// defaultMinSimilarity is the lowest similarity accepted for a fuzzy match
+// unless configured otherwise.
+const defaultMinSimilarity = 0.5
+
+// defaultReadTimeout is the time waited in test mode for the next packets of
+// a request unless configured otherwise.
+const defaultReadTimeout = 10 * time.Millisecond
+
+type readTimeoutKey struct{}
+
+// WithReadTimeout overrides the read timeout of the parser, see
+// PostgresOptions.ReadTimeout, for the connections processed with the context.
+func WithReadTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, readTimeoutKey{}, timeout)
+}
+
+// contextOptions returns the options of the parser overridden by those of
+// the context.
+func (p *PostgresParser) contextOptions(ctx context.Context) PostgresOptions {
+	opts := p.opts
+	if timeout, ok := ctx.Value(readTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		opts.ReadTimeout = timeout
 	}
+	return opts
+}
+
+// NewPostgresParser returns a parser with the default options, see
+// NewPostgresParserWithOptions.
+func NewPostgresParser(logger *zap.Logger, h *hooks.Hook) *PostgresParser {
+	return NewPostgresParserWithOptions(logger, h, PostgresOptions{})
+}
+
+// WithinStalenessTolerance reports whether the live response to a request
+// matches its recorded response, allowing the configured columns to drift
+// and leaving out the ignored fields.
+func (p *PostgresParser) WithinStalenessTolerance(recorded, live []byte) bool {
+	return responsesWithinTolerance(recorded, live, p.opts.StalenessTolerance, p.opts.IgnoredFields)
+}
+
+// ResetState forgets the statements prepared by the former test-set along
+// with the mocks it recorded, the consumed mocks are restored by the hooks.
+// The open connections are left untouched.
+func (p *PostgresParser) ResetState() {
+	p.statementNames.reset()
+	p.mockDigests.reset()
+	p.mockOccurrences.reset()
 }
 
 func (p *PostgresParser) OutgoingType(buffer []byte) bool {
 	const ProtocolVersion = 0x00030000 // Protocol version 3.0
 
+	buffer, _ = stripProxyProtocolHeader(buffer)
 	if len(buffer) < 8 {
 		// Not enough data for a complete header
 		return false
@@ -59,16 +133,39 @@ func (p *PostgresParser) OutgoingType(buffer []byte) bool {
 	return version == ProtocolVersion
 }
 
+// pgConnection is the state of a connection handed down, along with the
+// options of the parser, to its encoding and decoding. The statements and
+// the mocks it records are numbered and deduplicated along with those of
+// the other connections of the parser.
+type pgConnection struct {
+	hooks  *hooks.Hook
+	logger *zap.Logger
+	ctx    context.Context
+	names  *statementNames
+	// digests is nil when the duplicate exchanges are recorded.
+	digests     *mockDigests
+	occurrences *mockOccurrences
+}
+
+// newConnection returns the state of a connection processed with the context.
+func (p *PostgresParser) newConnection(ctx context.Context, logger *zap.Logger) *pgConnection {
+	conn := &pgConnection{hooks: p.hooks, logger: logger, ctx: ctx, names: p.statementNames, occurrences: p.mockOccurrences}
+	if p.opts.DeduplicateMocks {
+		conn.digests = p.mockDigests
+	}
+	return conn
+}
+
 func (p *PostgresParser) ProcessOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, ctx context.Context) {
-	switch models.GetMode() {
+	switch p.connectionMode(requestBuffer, destConn) {
 	case models.MODE_RECORD:
-		err := encodePostgresOutgoing(requestBuffer, clientConn, destConn, p.hooks, p.logger, ctx)
+		err := encodePostgresOutgoing(requestBuffer, clientConn, destConn, p.opts, p.newConnection(ctx, p.logger))
 		if err != nil {
 			p.logger.Debug("failed to encode the outgoing postgres call", zap.Error(err))
 		}
 	case models.MODE_TEST:
 		logger := p.logger.With(zap.Any("Client IP Address", clientConn.RemoteAddr().String()), zap.Any("Client ConnectionID", util.GetNextID()), zap.Any("Destination ConnectionID", util.GetNextID()))
-		err := decodePostgresOutgoing(requestBuffer, clientConn, destConn, p.hooks, logger, ctx)
+		err := decodePostgresOutgoing(requestBuffer, clientConn, destConn, p.contextOptions(ctx), p.newConnection(ctx, logger))
 		if err != nil && !p.hooks.IsUserAppTerminateInitiated() {
 			logger.Debug("failed to decode the outgoing postgres call", zap.Error(err))
 		}
@@ -78,15 +175,131 @@ func (p *PostgresParser) ProcessOutgoing(requestBuffer []byte, clientConn, destC
 
 }
 
+// connectionMode resolves the mode of a connection from its first message. In
+// test mode, with recordUnmatched, a connection is recorded when none of the
+// loaded mocks records its session: its startup message, or any postgres
+// session for the connections starting with an SSLRequest.
+func (p *PostgresParser) connectionMode(requestBuffer []byte, destConn net.Conn) models.Mode {
+	mode := models.GetMode()
+	if mode != models.MODE_TEST || !p.opts.RecordUnmatched || destConn == nil {
+		return mode
+	}
+	configMocks, err := p.hooks.GetConfigMocks()
+	if err != nil {
+		return mode
+	}
+	tcsMocks, err := p.hooks.GetTcsMocks()
+	if err != nil {
+		return mode
+	}
+	mocks := []*models.Mock{}
+	for _, mock := range append(configMocks, tcsMocks...) {
+		if mock.Kind == models.Postgres {
+			mocks = append(mocks, mock)
+		}
+	}
+	if decodeStartupMessage(requestBuffer) == nil {
+		if len(mocks) > 0 {
+			return mode
+		}
+	} else if findStartupMatch(mocks, [][]byte{requestBuffer}, p.opts.IgnoredStartupParameters) != -1 {
+		return mode
+	}
+	p.logger.Info("no postgres session was recorded for the connection, recording it", zap.String("destination", destConn.RemoteAddr().String()))
+	return models.MODE_RECORD
+}
+
 // This is the encoding function for the streaming postgres wiremessage
-func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) error {
+func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, opts PostgresOptions, pgConn *pgConnection) error {
+	h, logger := pgConn.hooks, pgConn.logger
 	logger.Debug("Inside the encodePostgresOutgoing function")
+	if util.IsSelfReferential(clientConn, destConn) {
+		logger.Error("the postgres destination is the proxy itself", zap.String("destination", destConn.RemoteAddr().String()))
+		return util.ErrSelfReferentialDestination
+	}
 	pgRequests := []models.Backend{}
 
+	requestBuffer, proxyHeader := stripProxyProtocolHeader(requestBuffer)
+	if proxyHeader != nil {
+		logger.Debug("forwarding the PROXY protocol header of the postgres connection", zap.String("header", string(proxyHeader)))
+		_, err := destConn.Write(proxyHeader)
+		if err != nil {
+			logger.Error("failed to write the PROXY protocol header to the destination server", zap.Error(err))
+			return err
+		}
+	}
+
+	if isGSSEncRequest(requestBuffer) {
+		// the declined negotiation is recorded on its own, ahead of the
+		// SSLRequest or the startup message which follows it
+		reqTimestamp := time.Now()
+		answer, next, err := negotiateGSSEncryption(requestBuffer, clientConn, destConn)
+		if err != nil {
+			logger.Error("failed to negotiate the GSSAPI encryption of the postgres client", zap.Error(err))
+			return err
+		}
+		pgConn.appendMock([]models.Backend{{
+			Identfier: "StartupRequest",
+			Length:    uint32(len(requestBuffer)),
+			Payload:   base64.StdEncoding.EncodeToString(requestBuffer),
+		}}, []models.Frontend{{
+			Payload: base64.StdEncoding.EncodeToString(answer),
+		}}, reqTimestamp, time.Now(), mockScope{}, opts.IntrospectionOnly)
+		requestBuffer = next
+	}
+
+	if isSSLRequest(requestBuffer) {
+		reqTimestamp := time.Now()
+		var answer, startup []byte
+		var err error
+		clientConn, destConn, answer, startup, err = negotiateSSLSession(requestBuffer, clientConn, destConn, opts.TLSDecryption, logger)
+		probe, encrypted := errors.Is(err, errSSLProbe), errors.Is(err, errSSLEncrypted)
+		if err != nil && !probe && !encrypted {
+			logger.Error("failed to negotiate the SSL session of the postgres client", zap.Error(err))
+			return err
+		}
+		// the negotiation is recorded on its own, replayed by matching when
+		// the session isn't decrypted and read back for the answer to give
+		// the client when it is
+		pgConn.appendMock([]models.Backend{{
+			Identfier: "StartupRequest",
+			Length:    uint32(len(requestBuffer)),
+			Payload:   base64.StdEncoding.EncodeToString(requestBuffer),
+		}}, []models.Frontend{{
+			Payload: base64.StdEncoding.EncodeToString(answer),
+		}}, reqTimestamp, time.Now(), mockScope{}, opts.IntrospectionOnly)
+		if probe {
+			logger.Debug("recorded the SSLRequest of a client which closed the connection after its answer")
+			clientConn.Close()
+			destConn.Close()
+			return nil
+		}
+		if encrypted {
+			logger.Warn("the postgres session is encrypted and passed through unrecorded, set the TLS decryption up to record it")
+			return relay(clientConn, destConn)
+		}
+		requestBuffer = startup
+	}
+
+	requestBuffer, err := readWholeMessages(clientConn, requestBuffer, opts.MaxPendingBytes)
+	if errors.Is(err, errTooManyPendingRequests) {
+		// like the partial messages of the record loop, the startup packet
+		// outgrowing maxPendingBytes passes the connection through unrecorded
+		logger.Warn("the startup message of the postgres client exceeds the limit, passing the connection through unrecorded", zap.Int("size", len(requestBuffer)), zap.Int("limit", opts.MaxPendingBytes))
+		if _, err := destConn.Write(requestBuffer); err != nil {
+			logger.Error("failed to write the startup message to the destination server", zap.Error(err))
+			return err
+		}
+		return relay(clientConn, destConn)
+	}
+	if err != nil {
+		logger.Error("failed to read the rest of the startup message of the postgres client", zap.Error(err))
+		return err
+	}
 	bufStr := base64.StdEncoding.EncodeToString(requestBuffer)
 	logger.Debug("bufStr is ", zap.String("bufStr", bufStr))
 	pg := NewBackend()
-	_, err := pg.DecodeStartupMessage(requestBuffer)
+	_, err = pg.DecodeStartupMessage(requestBuffer)
 	if err != nil {
 		logger.Error("failed to decode startup message server", zap.Error(err))
 	}
@@ -134,6 +347,14 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 	clientBufferChannel := make(chan []byte)
 	destBufferChannel := make(chan []byte)
 	errChannel := make(chan error)
+	// once either side is gone, both connections are closed so that the
+	// reader of the other side is unblocked and terminates as well
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		clientConn.Close()
+		destConn.Close()
+	}()
 	// read requests from client
 	go func() {
 		// Recover from panic and gracefully shutdown
@@ -141,9 +362,9 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 
 		defer utils.HandlePanic()
 
-		err := ReadBuffConn(clientConn, clientBufferChannel, errChannel, logger, h)
-		if err != nil {
-			logger.Error("failed to read the packet message in proxy for pg dependency", zap.Error(err))
+		err := ReadBuffConn(clientConn, clientBufferChannel, errChannel, done, logger, h)
+		if err != nil && err != io.EOF {
+			logger.Debug("stopped reading the requests of the pg client", zap.Error(err))
 		}
 	}()
 	// read response from destination
@@ -153,9 +374,9 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 
 		defer utils.HandlePanic()
 
-		err := ReadBuffConn(destConn, destBufferChannel, errChannel, logger, h)
-		if err != nil {
-			logger.Error("failed to read the packet message in proxy for pg dependency", zap.Error(err))
+		err := ReadBuffConn(destConn, destBufferChannel, errChannel, done, logger, h)
+		if err != nil && err != io.EOF {
+			logger.Debug("stopped reading the responses of the pg server", zap.Error(err))
 		}
 	}()
 
@@ -165,6 +386,77 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 	reqTimestampMock := time.Now()
 	var resTimestampMock time.Time
 
+	// the mocks are partitioned by the search_path they are recorded with
+	searchPath, _ := searchPathFromRequests([][]byte{requestBuffer})
+	batchSearchPath := ""
+	// and by the settings passed in the options of the startup message
+	startupOptions, _ := startupOptionsFromRequests([][]byte{requestBuffer})
+	// the statements prepared on the connection tell the query of the
+	// unnamed portal described apart from its Bind
+	statements := newStatementRegistry()
+	// the statements of the connection are gone once it is closed
+	defer pgConn.names.forget(statements)
+	batchPortalQuery := ""
+	batchScope := func() mockScope {
+		return mockScope{searchPath: batchSearchPath, startupOptions: startupOptions, portalQuery: batchPortalQuery}
+	}
+
+	// the long lived sessions are flushed at every maxSessionDuration so that
+	// the pending messages do not pile up in memory
+	var sessionTimeout <-chan time.Time
+	// the session is no longer recorded once maxSessionDuration elapsed,
+	// unless continueAfterMaxDuration is set
+	recording := true
+	// the partial messages read from the connections, completed by the next reads
+	var pendingRequest, pendingResponse messageBuffer
+	// the delays between the chunks of the COPY OUT streams are recorded
+	var lastResponseAt time.Time
+	// the server awaits the rows of a COPY FROM STDIN
+	copyIn := false
+	// the replication connections stream the changes in CopyBoth mode, which
+	// is recorded as the response of the START_REPLICATION
+	replication := isReplicationStartup(requestBuffer)
+	copyBoth := false
+	// the size of the replication stream recorded so far, capped to
+	// maxPendingBytes
+	streamed := 0
+	if replication {
+		logger.Debug("recording a postgres replication connection")
+	}
+	// the exchanges are left out until the connection is warmed up
+	warmup := newWarmupGate(opts.RecordingWarmup, reqTimestampMock)
+	// the connection is passed through unrecorded once the partial messages
+	// outgrow maxPendingBytes, rather than buffering them without a bound
+	exceedsPending := func(pending []byte, direction string) bool {
+		if len(pending) <= opts.MaxPendingBytes {
+			return false
+		}
+		logger.Warn("the partial postgres messages exceed the limit, passing the connection through unrecorded, raise postgresMaxPendingBytes to record it", zap.String("direction", direction), zap.Int("size", len(pending)), zap.Int("limit", opts.MaxPendingBytes))
+		recording = false
+		pendingRequest.reset()
+		pendingResponse.reset()
+		pgRequests = []models.Backend{}
+		pgResponses = []models.Frontend{}
+		sessionTimeout = nil
+		return true
+	}
+	// the exchanges are compared with the drift mocks in the background
+	drifts := newDriftDetector(opts, h, logger)
+	defer drifts.stop()
+	recordExchange := func() {
+		drifts.observe(pgRequests, pgResponses, connectionState{searchPath: batchSearchPath, startupOptions: startupOptions, portalQuery: batchPortalQuery, statements: statements})
+		if !warmup.admits(pgRequests) {
+			logger.Debug("skipped recording the postgres exchange during the warmup of the connection", zap.Any("pgReqs", len(pgRequests)))
+			return
+		}
+		pgConn.appendMock(pgRequests, pgResponses, reqTimestampMock, resTimestampMock, batchScope(), opts.IntrospectionOnly)
+	}
+	if opts.MaxSessionDuration > 0 {
+		sessionTimer := time.NewTimer(opts.MaxSessionDuration)
+		defer sessionTimer.Stop()
+		sessionTimeout = sessionTimer.C
+	}
+
 	for {
 
 		sigChan := make(chan os.Signal, 1)
@@ -172,23 +464,7 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 		select {
 		case <-sigChan:
 			if !isPreviousChunkRequest && len(pgRequests) > 0 && len(pgResponses) > 0 {
-				metadata := make(map[string]string)
-				metadata["type"] = "config"
-				err := h.AppendMocks(&models.Mock{
-					Version: models.GetVersion(),
-					Name:    "mocks",
-					Kind:    models.Postgres,
-					Spec: models.MockSpec{
-						PostgresRequests:  pgRequests,
-						PostgresResponses: pgResponses,
-						ReqTimestampMock:  reqTimestampMock,
-						ResTimestampMock:  resTimestampMock,
-						Metadata:          metadata,
-					},
-				}, ctx)
-				if err != nil {
-					logger.Error("failed to append the mocks", zap.Error(err))
-				}
+				recordExchange()
 				pgRequests = []models.Backend{}
 				pgResponses = []models.Frontend{}
 
@@ -210,29 +486,68 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 				logger.Error("failed to write request message to the destination server", zap.Error(err))
 				return err
 			}
+			if !recording {
+				continue
+			}
+
+			// parse the messages once they are read whole
+			pendingRequest.write(buffer)
+			buffer = pendingRequest.bytes
+			if exceedsPending(buffer, "request") {
+				continue
+			}
+			if !pendingRequest.whole() {
+				continue
+			}
+			// the COPY FROM STDIN stream is recorded as a single request,
+			// however the client splits it
+			if copyIn && !pendingRequest.endsCopyIn() {
+				continue
+			}
+			// so are the statements pipelined before a Sync, which the
+			// server answers as a unit
+			if !copyIn && !pendingRequest.endsPipeline() {
+				continue
+			}
+			copyIn = false
+			pendingRequest.reset()
+			// the feedback of the client is forwarded only, the server
+			// doesn't answer it, while its CopyDone ends the stream and is
+			// recorded with the end of the server's
+			if copyBoth && isCopyBothFeedback(buffer) {
+				continue
+			}
+			copyBoth = false
 
 			logger.Debug("the iteration for the pg request ends with no of pgReqs:" + strconv.Itoa(len(pgRequests)) + " and pgResps: " + strconv.Itoa(len(pgResponses)))
 			if !isPreviousChunkRequest && len(pgRequests) > 0 && len(pgResponses) > 0 {
-				metadata := make(map[string]string)
-				metadata["type"] = "config"
-				err := h.AppendMocks(&models.Mock{
-					Version: models.GetVersion(),
-					Name:    "mocks",
-					Kind:    models.Postgres,
-					Spec: models.MockSpec{
-						PostgresRequests:  pgRequests,
-						PostgresResponses: pgResponses,
-						ReqTimestampMock:  reqTimestampMock,
-						ResTimestampMock:  resTimestampMock,
-						Metadata:          metadata,
-					},
-				}, ctx)
-				if err != nil {
-					logger.Error("failed to append the mocks", zap.Error(err))
-				}
+				recordExchange()
 				pgRequests = []models.Backend{}
 				pgResponses = []models.Frontend{}
 			}
+			if len(pgRequests) == 0 {
+				batchSearchPath = searchPath
+				batchPortalQuery = ""
+				// store the request timestamp, the latency of the server is
+				// replayed from it
+				reqTimestampMock = time.Now()
+			}
+			if path, ok := searchPathFromRequests([][]byte{buffer}); ok {
+				searchPath = path
+			}
+			if options, ok := startupOptionsFromRequests([][]byte{buffer}); ok {
+				startupOptions = options
+			}
+
+			// the server received the original queries, the mock stores the
+			// transformed ones
+			var originalQueries []string
+			buffer, originalQueries = transformQueries(buffer, opts.QueryTransform)
+			if query, ok := statements.describedPortal([][]byte{buffer}); ok {
+				batchPortalQuery = query
+			}
+			statements.observe([][]byte{buffer})
+			pgConn.names.observe([][]byte{buffer}, statements, logger)
 
 			bufStr := base64.StdEncoding.EncodeToString(buffer)
 			if bufStr != "" {
@@ -240,14 +555,18 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 				pg := NewBackend()
 				var msg pgproto3.FrontendMessage
 
-				if !isStartupPacket(buffer) && len(buffer) > 5 {
+				// the CopyDone the client ends a replication stream or an
+				// empty COPY FROM STDIN with is a request of its own
+				if !isStartupPacket(buffer) && (len(buffer) > 5 || len(buffer) == 5 && buffer[0] == 'c') {
 					bufferCopy := buffer
-					for i := 0; i < len(bufferCopy)-5; {
+					// the CopyDone ending a COPY FROM STDIN stream may be the
+					// last five bytes of the buffer
+					for i := 0; i+5 <= len(bufferCopy); {
 						logger.Debug("Inside the if condition")
 						pg.BackendWrapper.MsgType = buffer[i]
-						pg.BackendWrapper.BodyLen = int(binary.BigEndian.Uint32(buffer[i+1:])) - 4
-						if len(buffer) < (i + pg.BackendWrapper.BodyLen + 5) {
-							logger.Error("failed to translate the postgres request message due to shorter network packet buffer")
+						pg.BackendWrapper.BodyLen, err = readMessageBodyLen(buffer, i)
+						if err != nil {
+							logger.Error("failed to translate the postgres request message due to shorter network packet buffer", zap.Error(err))
 							break
 						}
 						msg, err = pg.TranslateToReadableBackend(buffer[i:(i + pg.BackendWrapper.BodyLen + 5)])
@@ -273,10 +592,32 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 							pg.BackendWrapper.Executes = append(pg.BackendWrapper.Executes, pg.BackendWrapper.Execute)
 						}
 
+						if pg.BackendWrapper.MsgType == 'D' {
+							pg.BackendWrapper.Describes = append(pg.BackendWrapper.Describes, pg.BackendWrapper.Describe)
+						}
+
+						if pg.BackendWrapper.MsgType == 'C' {
+							pg.BackendWrapper.Closes = append(pg.BackendWrapper.Closes, pg.BackendWrapper.Close)
+						}
+
+						if pg.BackendWrapper.MsgType == 'd' {
+							data := make([]byte, len(pg.BackendWrapper.CopyData.Data))
+							copy(data, pg.BackendWrapper.CopyData.Data)
+							pg.BackendWrapper.CopyDatas = append(pg.BackendWrapper.CopyDatas, pgproto3.CopyData{Data: data})
+						}
+
 						pg.BackendWrapper.PacketTypes = append(pg.BackendWrapper.PacketTypes, string(pg.BackendWrapper.MsgType))
 
 						i += (5 + pg.BackendWrapper.BodyLen)
 					}
+					// the lists are kept only when the singular field can't
+					// replay every statement of the pipeline
+					if len(pg.BackendWrapper.Describes) < 2 {
+						pg.BackendWrapper.Describes = nil
+					}
+					if len(pg.BackendWrapper.Closes) < 2 {
+						pg.BackendWrapper.Closes = nil
+					}
 
 					pgMock := &models.Backend{
 						PacketTypes: pg.BackendWrapper.PacketTypes,
@@ -294,6 +635,9 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 						Describe:            pg.BackendWrapper.Describe,
 						Execute:             pg.BackendWrapper.Execute,
 						Executes:            pg.BackendWrapper.Executes,
+						CopyDatas:           pg.BackendWrapper.CopyDatas,
+						Describes:           pg.BackendWrapper.Describes,
+						Closes:              pg.BackendWrapper.Closes,
 						Flush:               pg.BackendWrapper.Flush,
 						FunctionCall:        pg.BackendWrapper.FunctionCall,
 						GssEncRequest:       pg.BackendWrapper.GssEncRequest,
@@ -308,16 +652,20 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 						Terminate:           pg.BackendWrapper.Terminate,
 						MsgType:             pg.BackendWrapper.MsgType,
 						AuthType:            pg.BackendWrapper.AuthType,
+						OriginalQueries:     originalQueries,
 					}
 					afterEncoded, err := PostgresDecoderBackend(*pgMock)
 					if err != nil {
 						logger.Debug("failed to decode the response message in proxy for postgres dependency", zap.Error(err))
 					}
 
-					if len(afterEncoded) != len(buffer) && pgMock.PacketTypes[0] != "p" {
+					if (len(afterEncoded) != len(buffer) && pgMock.PacketTypes[0] != "p") || containsBinaryCopyHeader(buffer) || !copyDataRoundTrips(buffer, afterEncoded) {
 						logger.Debug("the length of the encoded buffer is not equal to the length of the original buffer", zap.Any("afterEncoded", len(afterEncoded)), zap.Any("buffer", len(buffer)))
 						pgMock.Payload = bufStr
 					}
+					if opts.RecordRawPayloads && pgMock.Payload == "" {
+						pgMock.RawPayload = bufStr
+					}
 					pgRequests = append(pgRequests, *pgMock)
 
 				}
@@ -332,17 +680,62 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			}
 			isPreviousChunkRequest = true
 		case buffer := <-destBufferChannel:
-			if isPreviousChunkRequest {
-				// store the request timestamp
-				reqTimestampMock = time.Now()
-			}
-
 			// Write the response message to the client
 			_, err := clientConn.Write(buffer)
 			if err != nil {
 				logger.Error("failed to write response to the client", zap.Error(err))
 				return err
 			}
+			if !recording {
+				continue
+			}
+
+			// the single byte answer of the SSLRequest is not a message
+			isSSLAnswer := len(buffer) == 1 && len(pendingResponse.bytes) == 0 && (buffer[0] == 'N' || buffer[0] == 'S')
+			pendingResponse.write(buffer)
+			buffer = pendingResponse.bytes
+			if exceedsPending(buffer, "response") {
+				continue
+			}
+			if !isSSLAnswer && !pendingResponse.whole() {
+				isPreviousChunkRequest = false
+				continue
+			}
+			pendingResponse.reset()
+
+			if startsCopyIn(buffer) {
+				copyIn = true
+			}
+			if replication && startsCopyBoth(buffer) {
+				copyBoth = true
+				streamed = 0
+			}
+			if copyBoth && endsCopyBoth(buffer) {
+				copyBoth = false
+			}
+			// the replication stream is recorded up to the limit, the rest
+			// of the connection is passed through unrecorded
+			if copyBoth {
+				streamed += len(buffer)
+				if streamed > opts.MaxPendingBytes {
+					logger.Warn("the postgres replication stream exceeds the limit, passing the rest of the connection through unrecorded", zap.Int("size", streamed), zap.Int("limit", opts.MaxPendingBytes))
+					if len(pgRequests) > 0 && len(pgResponses) > 0 {
+						recordExchange()
+					}
+					recording = false
+					pgRequests = []models.Backend{}
+					pgResponses = []models.Frontend{}
+					sessionTimeout = nil
+					continue
+				}
+			}
+
+			if opts.NoticePolicy == NoticeDrop {
+				buffer = dropNoticeResponses(buffer)
+				if len(buffer) == 0 {
+					continue
+				}
+			}
 
 			bufStr := base64.StdEncoding.EncodeToString(buffer)
 
@@ -354,10 +747,24 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 					//Saving list of packets in case of multiple packets in a single buffer steam
 					ps := make([]pgproto3.ParameterStatus, 0)
 					dataRows := []pgproto3.DataRow{}
-
-					for i := 0; i < len(bufferCopy)-5; {
+					// the responses of pipelined statements repeat these
+					// messages in a single buffer, interleaved with the rows
+					// and the command completes
+					rowDescriptions := []pgproto3.RowDescription{}
+					errorResponses := []pgproto3.ErrorResponse{}
+					noticeResponses := []pgproto3.NoticeResponse{}
+					notificationResponses := []pgproto3.NotificationResponse{}
+					readyForQueries := []pgproto3.ReadyForQuery{}
+
+					// the bodyless messages, such as the CopyDone ending a COPY TO
+					// stream, may be the last five bytes of the buffer
+					for i := 0; i+5 <= len(bufferCopy); {
 						pg.FrontendWrapper.MsgType = buffer[i]
-						pg.FrontendWrapper.BodyLen = int(binary.BigEndian.Uint32(buffer[i+1:])) - 4
+						pg.FrontendWrapper.BodyLen, err = readMessageBodyLen(buffer, i)
+						if err != nil {
+							logger.Error("failed to translate the postgres response message due to shorter network packet buffer", zap.Error(err))
+							break
+						}
 						msg, err := pg.TranslateToReadableResponse(buffer[i:(i+pg.FrontendWrapper.BodyLen+5)], logger)
 						if err != nil {
 							logger.Error("failed to translate the response message to readable", zap.Error(err))
@@ -373,6 +780,35 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 							pg.FrontendWrapper.CommandComplete = *msg.(*pgproto3.CommandComplete)
 							pg.FrontendWrapper.CommandCompletes = append(pg.FrontendWrapper.CommandCompletes, pg.FrontendWrapper.CommandComplete)
 						}
+						if pg.FrontendWrapper.MsgType == 'd' {
+							data := make([]byte, len(pg.FrontendWrapper.CopyData.Data))
+							copy(data, pg.FrontendWrapper.CopyData.Data)
+							pg.FrontendWrapper.CopyDatas = append(pg.FrontendWrapper.CopyDatas, pgproto3.CopyData{Data: data})
+						}
+						if pg.FrontendWrapper.MsgType == 't' {
+							// the parameter types inferred by the server for the statement
+							oids := make([]uint32, len(pg.FrontendWrapper.ParameterDescription.ParameterOIDs))
+							copy(oids, pg.FrontendWrapper.ParameterDescription.ParameterOIDs)
+							pg.FrontendWrapper.ParameterDescriptions = append(pg.FrontendWrapper.ParameterDescriptions, pgproto3.ParameterDescription{ParameterOIDs: oids})
+						}
+						switch pg.FrontendWrapper.MsgType {
+						case 'T':
+							fields := make([]pgproto3.FieldDescription, len(pg.FrontendWrapper.RowDescription.Fields))
+							copy(fields, pg.FrontendWrapper.RowDescription.Fields)
+							rowDescriptions = append(rowDescriptions, pgproto3.RowDescription{Fields: fields})
+						case 'E':
+							errorResponses = append(errorResponses, pg.FrontendWrapper.ErrorResponse)
+						case 'N':
+							noticeResponses = append(noticeResponses, pg.FrontendWrapper.NoticeResponse)
+						case 'A':
+							notificationResponses = append(notificationResponses, pg.FrontendWrapper.NotificationResponse)
+						case 'Z':
+							readyForQueries = append(readyForQueries, pg.FrontendWrapper.ReadyForQuery)
+						case 'V':
+							// an empty result and a null one are both omitted
+							// from the readable form
+							pg.FrontendWrapper.FunctionCallResultNull = pg.FrontendWrapper.FunctionCallResponse.Result == nil
+						}
 						if pg.FrontendWrapper.MsgType == 'D' && pg.FrontendWrapper.DataRow.RowValues != nil {
 							// Create a new slice for each DataRow
 							valuesCopy := make([]string, len(pg.FrontendWrapper.DataRow.RowValues))
@@ -391,6 +827,23 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 					if len(dataRows) > 0 {
 						pg.FrontendWrapper.DataRows = dataRows
 					}
+					// the lists are kept only when the singular field can't
+					// replay every occurrence
+					if len(rowDescriptions) > 1 {
+						pg.FrontendWrapper.RowDescriptions = rowDescriptions
+					}
+					if len(errorResponses) > 1 {
+						pg.FrontendWrapper.ErrorResponses = errorResponses
+					}
+					if len(noticeResponses) > 1 {
+						pg.FrontendWrapper.NoticeResponses = noticeResponses
+					}
+					if len(notificationResponses) > 1 {
+						pg.FrontendWrapper.NotificationResponses = notificationResponses
+					}
+					if len(readyForQueries) > 1 {
+						pg.FrontendWrapper.ReadyForQueries = readyForQueries
+					}
 
 					// from here take the msg and append its readabable form to the pgResponses
 					pgMock := &models.Frontend{
@@ -412,23 +865,34 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 						CommandComplete:                 pg.FrontendWrapper.CommandComplete,
 						CommandCompletes:                pg.FrontendWrapper.CommandCompletes,
 						CopyData:                        pg.FrontendWrapper.CopyData,
+						CopyDatas:                       pg.FrontendWrapper.CopyDatas,
 						CopyDone:                        pg.FrontendWrapper.CopyDone,
 						CopyInResponse:                  pg.FrontendWrapper.CopyInResponse,
+						CopyBothResponse:                pg.FrontendWrapper.CopyBothResponse,
 						CopyOutResponse:                 pg.FrontendWrapper.CopyOutResponse,
 						DataRow:                         pg.FrontendWrapper.DataRow,
 						DataRows:                        pg.FrontendWrapper.DataRows,
 						EmptyQueryResponse:              pg.FrontendWrapper.EmptyQueryResponse,
 						ErrorResponse:                   pg.FrontendWrapper.ErrorResponse,
+						ErrorResponses:                  pg.FrontendWrapper.ErrorResponses,
 						FunctionCallResponse:            pg.FrontendWrapper.FunctionCallResponse,
+						FunctionCallResultNull:          pg.FrontendWrapper.FunctionCallResultNull,
 						NoData:                          pg.FrontendWrapper.NoData,
 						NoticeResponse:                  pg.FrontendWrapper.NoticeResponse,
+						NoticeResponses:                 pg.FrontendWrapper.NoticeResponses,
 						NotificationResponse:            pg.FrontendWrapper.NotificationResponse,
+						NotificationResponses:           pg.FrontendWrapper.NotificationResponses,
+						NegotiateProtocolVersion:        pg.FrontendWrapper.NegotiateProtocolVersion,
 						ParameterDescription:            pg.FrontendWrapper.ParameterDescription,
+						ParameterDescriptions:           pg.FrontendWrapper.ParameterDescriptions,
 						ParameterStatusCombined:         pg.FrontendWrapper.ParameterStatusCombined,
 						ParseComplete:                   pg.FrontendWrapper.ParseComplete,
 						PortalSuspended:                 pg.FrontendWrapper.PortalSuspended,
 						ReadyForQuery:                   pg.FrontendWrapper.ReadyForQuery,
+						ReadyForQueries:                 pg.FrontendWrapper.ReadyForQueries,
 						RowDescription:                  pg.FrontendWrapper.RowDescription,
+						RowDescriptions:                 pg.FrontendWrapper.RowDescriptions,
+						RowDescriptionTypes:             columnTypeNames(pg.FrontendWrapper.RowDescription),
 						MsgType:                         pg.FrontendWrapper.MsgType,
 						AuthType:                        pg.FrontendWrapper.AuthType,
 					}
@@ -438,10 +902,29 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 						logger.Debug("failed to decode the response message in proxy for postgres dependency", zap.Error(err))
 					}
 
-					if (len(afterEncoded) != len(buffer) && pgMock.PacketTypes[0] != "R") || len(pgMock.DataRows) > 0 {
+					// the readable form must reproduce the exact bytes, in their
+					// order on the wire, to be replayed without the payload
+					if (!bytes.Equal(afterEncoded, buffer) && pgMock.PacketTypes[0] != "R") || len(pgMock.DataRows) > 0 || containsBinaryCopyHeader(buffer) || !copyDataRoundTrips(buffer, afterEncoded) {
 						logger.Debug("the length of the encoded buffer is not equal to the length of the original buffer", zap.Any("afterEncoded", len(afterEncoded)), zap.Any("buffer", len(buffer)))
 						pgMock.Payload = bufStr
 					}
+					// the rows of a response read in several chunks count together
+					if rows := len(pgMock.DataRows) + recordedDataRows(pgResponses); opts.MaxDataRows > 0 && len(pgMock.DataRows) > 0 && rows > opts.MaxDataRows {
+						logger.Warn("the postgres response has more rows than the recorded limit, storing its raw payload only", zap.Int("rows", rows), zap.Int("limit", opts.MaxDataRows))
+						pgMock.Payload = bufStr
+						pgMock.DataRows = nil
+					}
+					if len(pgMock.CopyDatas) > 0 && !isPreviousChunkRequest && !lastResponseAt.IsZero() {
+						pgMock.CopyDataDelay = time.Since(lastResponseAt)
+					}
+					// the notifications pushed while the client was idle are
+					// kept in order with the responses, along with their delay
+					if isAsynchronousResponse(buffer) && !isPreviousChunkRequest && !lastResponseAt.IsZero() {
+						pgMock.NotificationDelay = time.Since(lastResponseAt)
+					}
+					if opts.RecordRawPayloads && pgMock.Payload == "" {
+						pgMock.RawPayload = bufStr
+					}
 					pgResponses = append(pgResponses, *pgMock)
 				}
 
@@ -455,44 +938,194 @@ func encodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			}
 
 			resTimestampMock = time.Now()
+			lastResponseAt = resTimestampMock
 
 			logger.Debug("the iteration for the postgres response ends with no of postgresReqs:" + strconv.Itoa(len(pgRequests)) + " and pgResps: " + strconv.Itoa(len(pgResponses)))
 			isPreviousChunkRequest = false
+		case <-sessionTimeout:
+			// the session is flushed between two exchanges only, never in
+			// the middle of a response
+			if isPreviousChunkRequest || len(pendingRequest.bytes) > 0 || len(pendingResponse.bytes) > 0 {
+				sessionTimeout = time.After(sessionFlushRetry)
+				continue
+			}
+			logger.Debug("the postgres session reached the maximum recording duration", zap.Duration("duration", opts.MaxSessionDuration), zap.Any("pgReqs", len(pgRequests)), zap.Any("pgResps", len(pgResponses)))
+			if len(pgRequests) > 0 && len(pgResponses) > 0 {
+				recordExchange()
+			}
+			pgRequests = []models.Backend{}
+			pgResponses = []models.Frontend{}
+			reqTimestampMock = time.Now()
+			if !opts.ContinueSession {
+				// the connection of the application is passed through from
+				// now on, unrecorded
+				logger.Debug("stopped recording the postgres session")
+				recording = false
+				sessionTimeout = nil
+				continue
+			}
+			sessionTimeout = time.After(opts.MaxSessionDuration)
 		case err := <-errChannel:
+			// the server closes the connection right after a fatal error,
+			// such as a failed authentication, which is recorded as well
+			if len(pgRequests) > 0 && len(pgResponses) > 0 {
+				recordExchange()
+			}
 			return err
 		}
 
 	}
 }
 
-func ReadBuffConn(conn net.Conn, bufferChannel chan []byte, errChannel chan error, logger *zap.Logger, h *hooks.Hook) error {
+// recordedDataRows counts the DataRows recorded in a readable form for the
+// responses.
+func recordedDataRows(responses []models.Frontend) int {
+	rows := 0
+	for _, response := range responses {
+		rows += len(response.DataRows)
+	}
+	return rows
+}
+
+// mockScope is the state of the connection which the responses of an
+// exchange depend on, stored in the metadata of its mock.
+type mockScope struct {
+	// searchPath is the search_path in effect when the requests were sent,
+	// if known.
+	searchPath string
+	// startupOptions are the settings the connection was started with.
+	startupOptions string
+	// portalQuery is the query bound to the unnamed portal the requests
+	// describe without binding it.
+	portalQuery string
+}
+
+// appendMock records the requests and responses of an exchange as a postgres
+// mock, scoped to the state of its connection. The exchanges other than the
+// schema introspection are dropped when introspectionOnly is set, and the
+// exchanges recorded identically before when the digests are kept.
+func (c *pgConnection) appendMock(pgRequests []models.Backend, pgResponses []models.Frontend, reqTimestampMock, resTimestampMock time.Time, scope mockScope, introspectionOnly bool) {
+	h, logger := c.hooks, c.logger
+	if introspectionOnly && !isIntrospectionExchange(pgRequests) {
+		logger.Debug("skipped recording the postgres exchange which is not a schema introspection")
+		return
+	}
+	metadata := make(map[string]string)
+	metadata["type"] = "config"
+	if scope.searchPath != "" {
+		metadata[searchPathMetadata] = scope.searchPath
+	}
+	if scope.startupOptions != "" {
+		metadata[startupOptionsMetadata] = scope.startupOptions
+	}
+	if scope.portalQuery != "" {
+		metadata[portalQueryMetadata] = scope.portalQuery
+	}
+	recordPipelines(pgRequests, pgResponses)
+	mock := &models.Mock{
+		Version: models.GetVersion(),
+		Name:    "mocks",
+		Kind:    models.Postgres,
+		Spec: models.MockSpec{
+			PostgresRequests:  pgRequests,
+			PostgresResponses: pgResponses,
+			ReqTimestampMock:  reqTimestampMock,
+			ResTimestampMock:  resTimestampMock,
+			Metadata:          metadata,
+		},
+	}
+	// the occurrence is numbered before the duplicates are left out, so that
+	// the mock recorded before them is replayed in their place
+	session, requests := h.GetCurrentTestSet(), requestsKey(pgRequests)
+	occurrence := c.occurrences.next(session, requests)
+	if c.digests != nil && !c.digests.add(session, requests, mock.ContentHash()) {
+		logger.Debug("skipped recording the postgres exchange recorded identically right before")
+		return
+	}
+	if occurrence > 1 {
+		metadata[occurrenceMetadata] = strconv.Itoa(occurrence)
+	}
+	err := h.AppendMocks(mock, c.ctx)
+	if err != nil {
+		logger.Error("failed to append the mocks", zap.Error(err))
+	}
+}
+
+// ReadBuffConn forwards the buffers read from the connection to bufferChannel
+// until the connection fails or closes, which is reported on errChannel. It
+// returns as soon as done is closed, so that the reader of a connection does
+// not outlive the exchange once the other side is gone.
+func ReadBuffConn(conn net.Conn, bufferChannel chan []byte, errChannel chan error, done <-chan struct{}, logger *zap.Logger, h *hooks.Hook) error {
 	for {
 		buffer, err := util.ReadBytes(conn)
-		if err != nil {
-			if !h.IsUserAppTerminateInitiated() {
-				if err == io.EOF {
-					logger.Debug("EOF error received from client. Closing connection in postgres !!")
-					return err
-				}
-				if !strings.Contains(err.Error(), "use of closed network connection") {
-					logger.Error("failed to read the packet message in proxy for pg dependency", zap.Error(err))
-				}
-				errChannel <- err
-				return err
+		if len(buffer) > 0 {
+			select {
+			case bufferChannel <- buffer:
+			case <-done:
+				return nil
 			}
 		}
-		bufferChannel <- buffer
+		if err == nil {
+			continue
+		}
+		if h.IsUserAppTerminateInitiated() {
+			return nil
+		}
+		if err == io.EOF {
+			logger.Debug("EOF error received from the connection. Closing connection in postgres !!")
+		} else if !strings.Contains(err.Error(), "use of closed network connection") {
+			logger.Error("failed to read the packet message in proxy for pg dependency", zap.Error(err))
+		}
+		select {
+		case errChannel <- err:
+		case <-done:
+		}
+		return err
 	}
 }
 
 // This is the decoding function for the postgres wiremessage
-func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) error {
+func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, opts PostgresOptions, pgConn *pgConnection) error {
+	h, logger := pgConn.hooks, pgConn.logger
+	if util.IsSelfReferential(clientConn, destConn) {
+		logger.Error("the postgres destination is the proxy itself", zap.String("destination", destConn.RemoteAddr().String()))
+		return util.ErrSelfReferentialDestination
+	}
+	requestBuffer, _ = stripProxyProtocolHeader(requestBuffer)
 	pgRequests := [][]byte{requestBuffer}
 
+	var scram *scramSession
+	if opts.Password != "" {
+		scram = newScramSession(opts.Password, nil)
+	}
+	var md5Auth *md5Session
+	if opts.Password != "" {
+		md5Auth = newMD5Session(opts.Password)
+	}
+	statements := newStatementRegistry()
+	searchPath := ""
+	startupOptions := ""
+	// the client streams the rows of a COPY FROM STDIN
+	copyIn := false
+	// the partial message read from the client, completed by the next reads
+	var incoming messageBuffer
+	// the rows of the COPY FROM STDIN read so far
+	var copyStream messageBuffer
+	// the client sends its feedback on a replayed replication stream
+	copyBoth := false
+	// the columns of the prepared statements described by the replayed
+	// responses, to convert their rows to the result formats of the client
+	descriptions := make(map[string][]pgproto3.FieldDescription)
+	// the transaction status of the last ReadyForQuery replayed to the client
+	txStatus := byte('I')
+	// the notifications pushed while the client was idle
+	notifications := newNotificationQueue(logger)
+	defer notifications.stop()
+
 	for {
 		// Since protocol packets have to be parsed for checking stream end,
 		// clientConnection have deadline for read to determine the end of stream.
-		err := clientConn.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		err := clientConn.SetReadDeadline(time.Now().Add(opts.ReadTimeout))
 		if err != nil {
 			logger.Error(hooks.Emoji+"failed to set the read deadline for the pg client connection", zap.Error(err))
 			return err
@@ -512,22 +1145,258 @@ func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			}
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				logger.Debug("the timeout for the client read in pg")
+				// keep the bytes read before the deadline
+				if len(buffer) > 0 {
+					incoming.write(buffer)
+				}
 				break
 			}
-			pgRequests = append(pgRequests, buffer)
+			// the chunks are joined until they end at a message boundary
+			incoming.write(buffer)
+			if incoming.whole() {
+				pgRequests = append(pgRequests, incoming.bytes)
+				incoming.reset()
+			}
+		}
+		if incoming.whole() {
+			pgRequests = append(pgRequests, incoming.bytes)
+			incoming.reset()
+		}
+
+		if size := pendingSize(pgRequests) + len(incoming.bytes) + len(copyStream.bytes); size > opts.MaxPendingBytes {
+			logger.Error("the postgres requests waiting to be matched exceed the limit, closing the connection, raise postgresMaxPendingBytes to replay larger COPY FROM STDIN streams", zap.Int("size", size), zap.Int("limit", opts.MaxPendingBytes))
+			return errTooManyPendingRequests
+		}
+
+		// wait for the rest of the messages split across the reads
+		if len(incoming.bytes) > 0 {
+			logger.Debug("waiting for the rest of a partially read postgres message")
+			continue
+		}
+
+		// the rows of a COPY FROM STDIN are matched at once, as recorded
+		if copyIn && len(pgRequests) > 0 {
+			for _, request := range pgRequests {
+				copyStream.write(request)
+			}
+			pgRequests = [][]byte{}
+			if !copyStream.endsCopyIn() {
+				logger.Debug("waiting for the rest of the COPY FROM STDIN stream")
+				continue
+			}
+			pgRequests = [][]byte{copyStream.bytes}
+			copyStream.reset()
+			copyIn = false
+		}
+
+		// the statements pipelined before a Sync are matched as a unit, as
+		// recorded
+		var complete bool
+		pgRequests, complete = joinPipelines(pgRequests)
+		if !complete {
+			logger.Debug("waiting for the Sync of the pipelined postgres statements")
+			continue
 		}
 
 		if len(pgRequests) == 0 {
 			logger.Debug("the postgres request buffer is empty")
 			continue
 		}
+		// the notifications still due were pushed before the next request
+		notifications.flush()
+
+		// the server doesn't answer the feedback on a replication stream,
+		// which was recorded along with the response of its START_REPLICATION
+		if copyBoth && isCopyBothFeedback(bytes.Join(pgRequests, nil)) {
+			logger.Debug("dropping the feedback of the client on the replayed replication stream")
+			pgRequests = [][]byte{}
+			continue
+		}
+		copyBoth = false
+
+		if len(pgRequests) == 1 && isSSLRequest(pgRequests[0]) && opts.TLSDecryption != nil {
+			// the sessions are recorded decrypted, the client is served over
+			// SSL with the same keys unless the server refused it
+			if recordedSSLAnswer(h, pgRequests[0]) == 'N' {
+				logger.Debug("refusing the SSLRequest of the client as the recorded server did")
+				if _, err := clientConn.Write([]byte{'N'}); err != nil {
+					logger.Error("failed to refuse the SSLRequest of the client application", zap.Error(err))
+					return err
+				}
+				pgRequests = [][]byte{}
+				continue
+			}
+			logger.Debug("accepting the SSLRequest of the client")
+			var channelBinding []byte
+			clientConn, channelBinding, err = acceptSSLSession(clientConn, opts.TLSDecryption)
+			if errors.Is(err, errSSLProbe) {
+				logger.Debug("the client closed the connection after the SSLRequest")
+				return nil
+			}
+			if err != nil {
+				logger.Error("failed to accept the SSL session of the client application", zap.Error(err))
+				return err
+			}
+			if scram != nil {
+				scram = newScramSession(opts.Password, channelBinding)
+			}
+			pgRequests = [][]byte{}
+			continue
+		}
+
+		if scram != nil && len(pgRequests) == 1 {
+			response, handled, err := scram.respond(pgRequests[0], h)
+			if handled {
+				if len(response) > 0 {
+					_, writeErr := clientConn.Write(response)
+					if writeErr != nil {
+						logger.Error("failed to write the SCRAM authentication response to the client application", zap.Error(writeErr))
+						return writeErr
+					}
+				}
+				if err != nil {
+					logger.Error("failed to replay the SCRAM authentication", zap.Error(err))
+					return err
+				}
+				pgRequests = [][]byte{}
+				continue
+			}
+		}
 
-		matched, pgResponses, err := matchingReadablePG(pgRequests, logger, h)
+		if md5Auth != nil && len(pgRequests) == 1 {
+			response, handled, err := md5Auth.respond(pgRequests[0], h)
+			if handled {
+				if len(response) > 0 {
+					_, writeErr := clientConn.Write(response)
+					if writeErr != nil {
+						logger.Error("failed to write the MD5 authentication response to the client application", zap.Error(writeErr))
+						return writeErr
+					}
+				}
+				if err != nil {
+					logger.Error("failed to replay the MD5 authentication", zap.Error(err))
+					return err
+				}
+				if options, ok := startupOptionsFromRequests(pgRequests); ok {
+					startupOptions = options
+				}
+				if path, ok := searchPathFromRequests(pgRequests); ok {
+					searchPath = path
+				}
+				pgRequests = [][]byte{}
+				continue
+			}
+		}
+
+		if scram == nil && md5Auth == nil && opts.SkipAuthentication && len(pgRequests) == 1 && isStartupPacket(pgRequests[0]) && startupRequestsAuthentication(h) {
+			// the recorded nonces, salts and proofs can't authenticate the
+			// client, which is let in without any
+			logger.Debug("skipping the authentication of the postgres client")
+			if options, ok := startupOptionsFromRequests(pgRequests); ok {
+				startupOptions = options
+			}
+			if path, ok := searchPathFromRequests(pgRequests); ok {
+				searchPath = path
+			}
+			_, err = clientConn.Write(skippedAuthentication(h))
+			if err != nil {
+				logger.Error("failed to write the authentication response to the client application", zap.Error(err))
+				return err
+			}
+			pgRequests = [][]byte{}
+			continue
+		}
+
+		if len(pgRequests) == 1 && isGSSEncRequest(pgRequests[0]) {
+			// GSSAPI encryption is never replayed, the client goes on with
+			// the plain negotiation as it did when the decline was recorded
+			logger.Debug("declining the GSSAPI encryption requested by the client")
+			_, err = clientConn.Write([]byte{'N'})
+			if err != nil {
+				logger.Error("failed to decline the GSSAPI encryption of the client application", zap.Error(err))
+				return err
+			}
+			pgRequests = [][]byte{}
+			continue
+		}
+
+		if response := introspectionResponse(pgRequests, opts.IntrospectionOverrides, txStatus); response != nil {
+			logger.Debug("replaying the overridden value for the introspection query")
+			_, err = clientConn.Write(response)
+			if err != nil {
+				logger.Error("failed to write the introspection response to the client application", zap.Error(err))
+				return err
+			}
+			pgRequests = [][]byte{}
+			continue
+		}
+
+		if options, ok := startupOptionsFromRequests(pgRequests); ok {
+			startupOptions = options
+		}
+		conn := connectionState{searchPath: searchPath, startupOptions: startupOptions, statements: statements, replays: pgConn.occurrences}
+		if path, ok := searchPathFromRequests(pgRequests); ok {
+			searchPath = path
+		}
+		matchRequests := pgRequests
+		if opts.QueryTransform != nil {
+			matchRequests = make([][]byte, len(pgRequests))
+			for i, request := range pgRequests {
+				matchRequests[i], _ = transformQueries(request, opts.QueryTransform)
+			}
+		}
+		conn.portalQuery, _ = statements.describedPortal(matchRequests)
+		matched, pgResponses, recordedRequests, latency, err := matchingReadablePG(matchRequests, conn, logger, h, opts)
 		if err != nil {
 			return fmt.Errorf("error while matching tcs mocks %v", err)
 		}
 
+		// the deallocated statements are checked before the registry forgets them
+		var deallocation []byte
 		if !matched {
+			deallocation = statements.deallocationResponse(matchRequests, txStatus)
+		}
+		statements.observe(matchRequests)
+
+		if !matched {
+			// drivers expect their deallocations to be acknowledged
+			if deallocation != nil {
+				logger.Debug("acknowledging the unrecorded deallocation of the prepared statements", zap.Any("open statements", len(statements.names)))
+				_, err = clientConn.Write(deallocation)
+				if err != nil {
+					logger.Error("failed to write the deallocation response to the client application", zap.Error(err))
+					return err
+				}
+				pgRequests = [][]byte{}
+				continue
+			}
+		}
+
+		if !matched && opts.VirtualMockHandler != nil {
+			if response := opts.VirtualMockHandler(decodeBackendRequests(pgRequests)); response != nil {
+				logger.Debug("answering the unmatched postgres requests with a virtual mock", zap.Any("request packets", len(pgRequests)))
+				matched, pgResponses = true, []models.Frontend{*response}
+			}
+		}
+
+		if !matched {
+			if mocks, err := h.GetConfigMocks(); err == nil {
+				logMismatch(mocks, matchRequests, logger)
+			}
+			if len(opts.StalenessTolerance) > 0 {
+				// the rows read from a replica may have drifted since the
+				// recording, such responses are not a divergence
+				withinTolerance, err := passThroughWithinTolerance(clientConn, destConn, pgRequests, matchRequests, conn, h, logger, opts)
+				if err != nil {
+					logger.Error("failed to match the dependency call from user application", zap.Any("request packets", len(pgRequests)))
+					return err
+				}
+				if !withinTolerance {
+					h.RecordDivergence()
+				}
+				continue
+			}
+			h.RecordDivergence()
 			_, err = util.Passthrough(clientConn, destConn, pgRequests, h.Recover, logger)
 			if err != nil {
 				logger.Error("failed to match the dependency call from user application", zap.Any("request packets", len(pgRequests)))
@@ -535,20 +1404,71 @@ func decodePostgresOutgoing(requestBuffer []byte, clientConn, destConn net.Conn,
 			}
 			continue
 		}
+		h.RecordRoundTrip(models.Postgres)
+		var idleNotifications []models.Frontend
+		pgResponses, idleNotifications = splitIdleNotifications(pgResponses)
+		var responseBuffer []byte
+		segments := make([][]byte, 0, len(pgResponses))
 		for _, pgResponse := range pgResponses {
-			encoded, err := PostgresDecoder(pgResponse.Payload)
-			if len(pgResponse.PacketTypes) > 0 && len(pgResponse.Payload) == 0 {
-				encoded, err = PostgresDecoderFrontend(pgResponse)
-			}
+			overrideParameterStatus(&pgResponse, opts.IntrospectionOverrides)
+			encoded, err := FrontendWireBytes(pgResponse)
 			if err != nil {
 				logger.Error("failed to decode the response message in proxy for postgres dependency", zap.Error(err))
 				return err
 			}
-			_, err = clientConn.Write([]byte(encoded))
-			if err != nil {
-				logger.Error("failed to write request message to the client application", zap.Error(err))
-				return err
+			responseBuffer = append(responseBuffer, encoded...)
+			segments = append(segments, encoded)
+		}
+		// end every pipeline of the current requests at its Sync, before the
+		// messages of the recorded pipelines are altered
+		responseBuffer = alignReadyForQuery(responseBuffer, syncBoundaries(pgRequests), recordedPipelines(pgResponses))
+		// honour the row limits of the Execute messages of the current requests
+		responseBuffer = enforceRowLimits(responseBuffer, executeRowLimits(pgRequests))
+		if opts.NoticePolicy == NoticeDrop {
+			responseBuffer = dropNoticeResponses(responseBuffer)
+		}
+		// send the rows in the result formats the client bound them with
+		if executes := boundExecutes(pgRequests); len(executes) > 0 {
+			responseBuffer = applyResultFormats(responseBuffer, recordedExecutes(recordedRequests), executes, descriptions)
+		}
+		observeStatementDescriptions(pgRequests, responseBuffer, descriptions)
+		copyIn = startsCopyIn(responseBuffer)
+		copyBoth = startsCopyBoth(responseBuffer)
+		if opts.ReplayLatency && latency > 0 {
+			time.Sleep(replayedLatency(latency, opts.MaxReplayLatency))
+		}
+		if opts.ReplayJitter.enabled() {
+			time.Sleep(opts.ReplayJitter.delay())
+		}
+		paceCopyOut := opts.PaceCopyOut && hasCopyDataDelays(pgResponses)
+		paceNotifications := opts.PaceNotifications && hasNotificationDelays(pgResponses)
+		if (paceCopyOut || paceNotifications) && bytes.Equal(responseBuffer, bytes.Join(segments, nil)) {
+			logger.Debug("replaying the responses at their recorded pace", zap.Bool("copy out", paceCopyOut), zap.Bool("notifications", paceNotifications))
+			err = writePacedResponses(clientConn, segments, responseDelays(pgResponses, paceCopyOut, paceNotifications), opts.WriteChunkSize)
+		} else {
+			err = util.WriteChunked(clientConn, responseBuffer, opts.WriteChunkSize)
+		}
+		if err != nil {
+			logger.Error("failed to write request message to the client application", zap.Error(err))
+			return err
+		}
+		if len(idleNotifications) > 0 {
+			msgs := make([][]byte, 0, len(idleNotifications))
+			for _, notification := range idleNotifications {
+				encoded, err := FrontendWireBytes(notification)
+				if err != nil {
+					logger.Error("failed to decode the notification in proxy for postgres dependency", zap.Error(err))
+					return err
+				}
+				msgs = append(msgs, encoded)
 			}
+			notifications.schedule(clientConn, msgs, responseDelays(idleNotifications, false, opts.PaceNotifications))
+		}
+		txStatus = lastTxStatus(responseBuffer, txStatus)
+		// close the connection after a fatal error as the server did
+		if hasFatalError(responseBuffer) {
+			logger.Debug("replayed a fatal error response, closing the postgres connection")
+			return nil
 		}
 		// update for the next dependency call
 		pgRequests = [][]byte{}
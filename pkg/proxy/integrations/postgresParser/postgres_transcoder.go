@@ -49,6 +49,9 @@ func NewFrontend() *FrontendWrapper {
 // }
 
 func isStartupPacket(packet []byte) bool {
+	if len(packet) < 8 {
+		return false
+	}
 	protocolVersion := binary.BigEndian.Uint32(packet[4:8])
 	// printStartupPacketDetails(packet)
 	return protocolVersion == 196608 // 3.0 in PostgreSQL
@@ -159,7 +162,7 @@ func (f *FrontendWrapper) TranslateToReadableResponse(msgBody []byte, logger *za
 	case 'E':
 		msg = &f.FrontendWrapper.ErrorResponse
 	case 'G':
-		msg = &f.FrontendWrapper.CopyInResponse
+		msg = copyInResponse{&f.FrontendWrapper.CopyInResponse}
 	case 'H':
 		msg = &f.FrontendWrapper.CopyOutResponse
 	case 'I':
@@ -185,7 +188,9 @@ func (f *FrontendWrapper) TranslateToReadableResponse(msgBody []byte, logger *za
 	case 'T':
 		msg = &f.FrontendWrapper.RowDescription
 	case 'V':
-		msg = &f.FrontendWrapper.FunctionCallResponse
+		msg = functionCallResponse{&f.FrontendWrapper.FunctionCallResponse}
+	case 'v':
+		msg = negotiateProtocolVersion{&f.FrontendWrapper.NegotiateProtocolVersion}
 	case 'W':
 		msg = &f.FrontendWrapper.CopyBothResponse
 	case 'Z':
@@ -333,3 +338,103 @@ func (b *BackendWrapper) DecodeStartupMessage(buf []byte) (pgproto3.FrontendMess
 		return nil, fmt.Errorf("unknown startup message code: %d", code)
 	}
 }
+
+// functionCallResponse decodes the length of the function result as the
+// signed int32 it is, so that the -1 of a null result isn't read as a length
+// of 4GB. The rest of the message is handled by pgproto3.
+type functionCallResponse struct {
+	*pgproto3.FunctionCallResponse
+}
+
+func (r functionCallResponse) Decode(src []byte) error {
+	if len(src) < 4 {
+		return errors.New("invalid FunctionCallResponse message format")
+	}
+	resultSize := int32(binary.BigEndian.Uint32(src))
+	if resultSize == -1 {
+		r.Result = nil
+		return nil
+	}
+	if resultSize < 0 || len(src[4:]) != int(resultSize) {
+		return errors.New("invalid FunctionCallResponse message format")
+	}
+	r.Result = src[4:]
+	return nil
+}
+
+// copyInResponse decodes and encodes the CopyInResponse with pgproto3, its
+// format codes being kept in the tagged form of the mocks.
+type copyInResponse struct {
+	*models.PostgresCopyInResponse
+}
+
+// Backend identifies the message as sent by the server.
+func (copyInResponse) Backend() {}
+
+func (r copyInResponse) Decode(src []byte) error {
+	msg := &pgproto3.CopyInResponse{}
+	if err := msg.Decode(src); err != nil {
+		return err
+	}
+	r.OverallFormat, r.ColumnFormatCodes = msg.OverallFormat, msg.ColumnFormatCodes
+	return nil
+}
+
+func (r copyInResponse) Encode(dst []byte) []byte {
+	return (&pgproto3.CopyInResponse{
+		OverallFormat:     r.OverallFormat,
+		ColumnFormatCodes: r.ColumnFormatCodes,
+	}).Encode(dst)
+}
+
+// negotiateProtocolVersion decodes and encodes the NegotiateProtocolVersion
+// message sent by the server in response to a startup message it can't fully
+// honour, before it goes on with the authentication.
+type negotiateProtocolVersion struct {
+	*models.PostgresNegotiateProtocolVersion
+}
+
+// Backend identifies the message as sent by the server.
+func (negotiateProtocolVersion) Backend() {}
+
+func (m negotiateProtocolVersion) Decode(src []byte) error {
+	if len(src) < 8 {
+		return errors.New("invalid NegotiateProtocolVersion message format")
+	}
+	m.NewestMinorProtocol = binary.BigEndian.Uint32(src)
+	count := int(int32(binary.BigEndian.Uint32(src[4:])))
+	if count < 0 {
+		return errors.New("invalid NegotiateProtocolVersion message format")
+	}
+	options := make([]string, 0, count)
+	rest := src[8:]
+	for i := 0; i < count; i++ {
+		end := bytes.IndexByte(rest, 0)
+		if end < 0 {
+			return errors.New("invalid NegotiateProtocolVersion message format")
+		}
+		options = append(options, string(rest[:end]))
+		rest = rest[end+1:]
+	}
+	if len(rest) != 0 {
+		return errors.New("invalid NegotiateProtocolVersion message format")
+	}
+	m.UnrecognizedOptions = options
+	return nil
+}
+
+func (m negotiateProtocolVersion) Encode(dst []byte) []byte {
+	length := 12
+	for _, option := range m.UnrecognizedOptions {
+		length += len(option) + 1
+	}
+	dst = append(dst, 'v')
+	dst = binary.BigEndian.AppendUint32(dst, uint32(length))
+	dst = binary.BigEndian.AppendUint32(dst, m.NewestMinorProtocol)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(m.UnrecognizedOptions)))
+	for _, option := range m.UnrecognizedOptions {
+		dst = append(dst, option...)
+		dst = append(dst, 0)
+	}
+	return dst
+}
@@ -0,0 +1,45 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Connection multiplexers and load balancers may prefix the connection with a
+// PROXY protocol header (https://www.haproxy.org/download/2.9/doc/proxy-protocol.txt)
+// carrying the address of the original client, ahead of the startup message.
+var (
+	proxyProtocolV1Prefix    = []byte("PROXY ")
+	proxyProtocolV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+)
+
+const (
+	// proxyProtocolV1MaxLen is the maximum length of a v1 header including the CRLF.
+	proxyProtocolV1MaxLen = 107
+	// proxyProtocolV2HeaderLen is the length of the fixed part of a v2 header.
+	proxyProtocolV2HeaderLen = 16
+)
+
+// stripProxyProtocolHeader returns the buffer without its leading PROXY
+// protocol header along with the header. The buffer is returned as is when it
+// doesn't start with a complete header.
+func stripProxyProtocolHeader(buffer []byte) ([]byte, []byte) {
+	switch {
+	case bytes.HasPrefix(buffer, proxyProtocolV2Signature):
+		if len(buffer) < proxyProtocolV2HeaderLen || buffer[12]>>4 != 2 {
+			return buffer, nil
+		}
+		headerLen := proxyProtocolV2HeaderLen + int(binary.BigEndian.Uint16(buffer[14:16]))
+		if len(buffer) < headerLen {
+			return buffer, nil
+		}
+		return buffer[headerLen:], buffer[:headerLen]
+	case bytes.HasPrefix(buffer, proxyProtocolV1Prefix):
+		end := bytes.Index(buffer, []byte("\r\n"))
+		if end < 0 || end+2 > proxyProtocolV1MaxLen {
+			return buffer, nil
+		}
+		return buffer[end+2:], buffer[:end+2]
+	}
+	return buffer, nil
+}
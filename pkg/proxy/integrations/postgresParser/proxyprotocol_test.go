@@ -0,0 +1,55 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.uber.org/zap"
+)
+
+// proxyProtocolV2Header returns a PROXY protocol v2 header carrying the TCP
+// over IPv4 addresses of a client.
+func proxyProtocolV2Header() []byte {
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	// version 2 PROXY command, TCP over IPv4
+	header = append(header, 0x21, 0x11)
+	header = binary.BigEndian.AppendUint16(header, 12)
+	header = append(header, 10, 0, 0, 1, 10, 0, 0, 2)
+	return binary.BigEndian.AppendUint16(binary.BigEndian.AppendUint16(header, 51234), 5432)
+}
+
+func TestStripProxyProtocolHeader(t *testing.T) {
+	startup := (&pgproto3.StartupMessage{ProtocolVersion: pgproto3.ProtocolVersionNumber, Parameters: map[string]string{"user": "postgres"}}).Encode(nil)
+	v1 := []byte("PROXY TCP4 10.0.0.1 10.0.0.2 51234 5432\r\n")
+	v2 := proxyProtocolV2Header()
+
+	for name, header := range map[string][]byte{"v1": v1, "v2": v2} {
+		t.Run(name, func(t *testing.T) {
+			buffer := append(append([]byte{}, header...), startup...)
+			stripped, stripHeader := stripProxyProtocolHeader(buffer)
+			if !bytes.Equal(stripped, startup) || !bytes.Equal(stripHeader, header) {
+				t.Fatalf("split the buffer into the header % x and % x", stripHeader, stripped)
+			}
+			p := NewPostgresParserWithOptions(zap.NewNop(), nil, PostgresOptions{})
+			if !p.OutgoingType(buffer) {
+				t.Fatalf("doesn't detect the startup message following the PROXY protocol header")
+			}
+		})
+	}
+
+	for name, buffer := range map[string][]byte{
+		"no header":         startup,
+		"truncated v2":      proxyProtocolV2Header()[:20],
+		"v2 of version 1":   append(append(append([]byte{}, proxyProtocolV2Signature...), 0x11, 0x11, 0, 0), startup...),
+		"v1 without a CRLF": []byte("PROXY TCP4 10.0.0.1 10.0.0.2 51234 5432"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			stripped, header := stripProxyProtocolHeader(buffer)
+			if header != nil || !bytes.Equal(stripped, buffer) {
+				t.Fatalf("stripped the header % x off % x", header, buffer)
+			}
+		})
+	}
+}
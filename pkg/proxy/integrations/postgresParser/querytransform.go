@@ -0,0 +1,100 @@
+package postgresparser
+
+import (
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+// QueryTransform rewrites the text of a query (stripping schema qualifiers,
+// redacting literals, ...) before it is stored in a mock. The same transform
+// is applied to the queries of the application in test mode so that they
+// match the stored mocks.
+type QueryTransform func(query string) string
+
+// transformQueries rewrites the Query and Parse messages of the buffer with
+// the transform. It returns the rewritten buffer along with the original text
+// of the rewritten queries, in order. The buffer is returned unchanged when it
+// does not hold whole messages.
+func transformQueries(buffer []byte, transform QueryTransform) ([]byte, []string) {
+	if transform == nil || isStartupPacket(buffer) {
+		return buffer, nil
+	}
+	msgs, ok := splitMessages(buffer)
+	if !ok {
+		return buffer, nil
+	}
+
+	var originals []string
+	rewritten := make([]byte, 0, len(buffer))
+	for _, msg := range msgs {
+		switch msg[0] {
+		case 'Q':
+			query := &pgproto3.Query{}
+			if query.Decode(msg[5:]) == nil {
+				if transformed := transform(query.String); transformed != query.String {
+					originals = append(originals, query.String)
+					rewritten = (&pgproto3.Query{String: transformed}).Encode(rewritten)
+					continue
+				}
+			}
+		case 'P':
+			parse := &pgproto3.Parse{}
+			if parse.Decode(msg[5:]) == nil {
+				if transformed := transform(parse.Query); transformed != parse.Query {
+					originals = append(originals, parse.Query)
+					parse.Query = transformed
+					rewritten = parse.Encode(rewritten)
+					continue
+				}
+			}
+		}
+		rewritten = append(rewritten, msg...)
+	}
+	return rewritten, originals
+}
+
+// StripSchemaQualifiers returns the transform leaving out the qualifiers of
+// the schemas from the names they qualify, so that the queries recorded
+// against app.users replay in the environments where the tables are reached
+// through the search_path. The schema names are case insensitive and the
+// string literals are left as they are. It returns nil without any schema.
+func StripSchemaQualifiers(schemas []string) QueryTransform {
+	if len(schemas) == 0 {
+		return nil
+	}
+	qualifiers := make([]string, 0, 2*len(schemas))
+	for _, schema := range schemas {
+		qualifiers = append(qualifiers, schema+".", `"`+schema+`".`)
+	}
+	return func(query string) string {
+		var b strings.Builder
+		inString := false
+		for i := 0; i < len(query); {
+			c := query[i]
+			if c == '\'' {
+				inString = !inString
+			}
+			if !inString && (i == 0 || (!isIdentifierByte(query[i-1]) && query[i-1] != '.' && query[i-1] != '"')) {
+				if n := qualifierLen(query[i:], qualifiers); n > 0 {
+					i += n
+					continue
+				}
+			}
+			b.WriteByte(c)
+			i++
+		}
+		return b.String()
+	}
+}
+
+// qualifierLen returns the length of the qualifier query starts with, 0 when
+// it starts with none of them.
+func qualifierLen(query string, qualifiers []string) int {
+	for _, qualifier := range qualifiers {
+		if len(query) > len(qualifier) && strings.EqualFold(query[:len(qualifier)], qualifier) {
+			return len(qualifier)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package postgresparser
+
+import (
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestStripSchemaQualifiers(t *testing.T) {
+	transform := StripSchemaQualifiers([]string{"app"})
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT * FROM app.users WHERE id = $1", want: "SELECT * FROM users WHERE id = $1"},
+		{query: `SELECT u.name FROM "app".users u JOIN APP.orders o ON o.user_id = u.id`, want: "SELECT u.name FROM users u JOIN orders o ON o.user_id = u.id"},
+		{query: "SELECT * FROM myapp.users, tenant.app.users", want: "SELECT * FROM myapp.users, tenant.app.users"},
+		{query: "INSERT INTO app.notes (body) VALUES ('see app.users, it''s app.orders')", want: "INSERT INTO notes (body) VALUES ('see app.users, it''s app.orders')"},
+		{query: "SELECT app FROM settings", want: "SELECT app FROM settings"},
+	}
+	for _, tt := range tests {
+		if got := transform(tt.query); got != tt.want {
+			t.Errorf("transformed %q into %q, want %q", tt.query, got, tt.want)
+		}
+	}
+	if StripSchemaQualifiers(nil) != nil {
+		t.Errorf("transforms the queries without any schema to strip")
+	}
+}
+
+func TestTransformQueriesStripsTheSchemaPrefix(t *testing.T) {
+	buffer := (&pgproto3.Parse{Name: "users", Query: "SELECT * FROM app.users WHERE id = $1"}).Encode(nil)
+	buffer = (&pgproto3.Sync{}).Encode(buffer)
+	buffer = (&pgproto3.Query{String: "DELETE FROM app.sessions"}).Encode(buffer)
+
+	transformed, originals := transformQueries(buffer, StripSchemaQualifiers([]string{"app"}))
+	msgs, ok := splitMessages(transformed)
+	if !ok || len(msgs) != 3 {
+		t.Fatalf("transformed the buffer into %d messages, want 3", len(msgs))
+	}
+	parse := &pgproto3.Parse{}
+	if err := parse.Decode(msgs[0][5:]); err != nil || parse.Query != "SELECT * FROM users WHERE id = $1" || parse.Name != "users" {
+		t.Fatalf("recorded the Parse %+v, want the query without the schema", parse)
+	}
+	if msgs[1][0] != 'S' {
+		t.Fatalf("altered the Sync between the queries")
+	}
+	query := &pgproto3.Query{}
+	if err := query.Decode(msgs[2][5:]); err != nil || query.String != "DELETE FROM sessions" {
+		t.Fatalf("recorded the Query %q, want the query without the schema", query.String)
+	}
+	// the original queries are kept for the replay
+	if len(originals) != 2 || originals[0] != "SELECT * FROM app.users WHERE id = $1" || originals[1] != "DELETE FROM app.sessions" {
+		t.Fatalf("kept the original queries %q", originals)
+	}
+}
@@ -0,0 +1,211 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// rowsResponse returns the wire bytes of the response to a query returning
+// the rows.
+func rowsResponse(rows int) []byte {
+	buffer := (&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte("id"), DataTypeOID: 23, DataTypeSize: 4, TypeModifier: -1}}}).Encode(nil)
+	for i := 0; i < rows; i++ {
+		buffer = (&pgproto3.DataRow{RowValues: []string{strconv.Itoa(i)}}).Encode(buffer)
+	}
+	buffer = (&pgproto3.CommandComplete{CommandTag: []byte("SELECT " + strconv.Itoa(rows))}).Encode(buffer)
+	return (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(buffer)
+}
+
+// pgExchange is a request of the client, sent after the pause, and the
+// response of the server, empty when the server doesn't answer it yet.
+type pgExchange struct {
+	request, response []byte
+	pause             time.Duration
+}
+
+// recordSession records a session whose client sends the query once started,
+// answered by the server with the response, and returns the recorded mocks.
+func recordSession(t *testing.T, opts PostgresOptions, query, response []byte) []*models.Mock {
+	t.Helper()
+	return recordExchanges(t, opts, pgExchange{request: query, response: response})
+}
+
+// recordExchanges records a session whose client sends the requests of the
+// exchanges in turn once started, and returns the recorded mocks.
+func recordExchanges(t *testing.T, opts PostgresOptions, exchanges ...pgExchange) []*models.Mock {
+	t.Helper()
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, opts)
+
+	startup := startupRequest("")
+	started := (&pgproto3.AuthenticationOk{}).Encode(nil)
+	started = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(started)
+	exchanges = append([]pgExchange{{request: startup, response: started}}, exchanges...)
+
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		for _, exchange := range exchanges {
+			if _, err := io.ReadFull(server, make([]byte, len(exchange.request))); err != nil {
+				return
+			}
+			if len(exchange.response) == 0 {
+				continue
+			}
+			if _, err := server.Write(exchange.response); err != nil {
+				return
+			}
+		}
+		// the session lasts until the client leaves
+		io.Copy(io.Discard, server)
+	}()
+	go func() {
+		defer app.Close()
+		for i, exchange := range exchanges {
+			// the startup message is sent along with the connection
+			if i > 0 {
+				time.Sleep(exchange.pause)
+				if _, err := app.Write(exchange.request); err != nil {
+					return
+				}
+			}
+			if _, err := io.ReadFull(app, make([]byte, len(exchange.response))); err != nil {
+				return
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		encodePostgresOutgoing(startup, clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the recording of the session did not end")
+	}
+	return db.mocks
+}
+
+// queryMock returns the mock recorded for the query.
+func queryMock(t *testing.T, mocks []*models.Mock, query string) *models.Mock {
+	t.Helper()
+	for _, mock := range mocks {
+		for _, request := range mock.Spec.PostgresRequests {
+			if request.Query.String == query {
+				return mock
+			}
+		}
+	}
+	t.Fatalf("recorded no mock of the query %q", query)
+	return nil
+}
+
+// replayedBytes returns the wire bytes the responses of the mock replay.
+func replayedBytes(t *testing.T, mock *models.Mock) []byte {
+	t.Helper()
+	var replayed []byte
+	for _, response := range mock.Spec.PostgresResponses {
+		encoded, err := FrontendWireBytes(response)
+		if err != nil {
+			t.Fatalf("failed to encode the recorded response: %v", err)
+		}
+		replayed = append(replayed, encoded...)
+	}
+	return replayed
+}
+
+// TestMaxDataRows records responses on both sides of the cap of the rows
+// recorded in a readable form.
+func TestMaxDataRows(t *testing.T) {
+	query := "SELECT id FROM events"
+	for _, tt := range []struct {
+		rows     int
+		readable bool
+	}{
+		{rows: 3, readable: true},
+		{rows: 4, readable: false},
+		{rows: 200, readable: false},
+	} {
+		response := rowsResponse(tt.rows)
+		mocks := recordSession(t, PostgresOptions{MaxDataRows: 3}, (&pgproto3.Query{String: query}).Encode(nil), response)
+		mock := queryMock(t, mocks, query)
+
+		rows := 0
+		for _, recorded := range mock.Spec.PostgresResponses {
+			rows += len(recorded.DataRows)
+			if recorded.Payload == "" {
+				t.Errorf("%d rows: recorded a response without its raw payload", tt.rows)
+			}
+		}
+		if tt.readable && rows != tt.rows {
+			t.Errorf("%d rows: recorded %d rows in a readable form, want all of them", tt.rows, rows)
+		}
+		if !tt.readable && rows != 0 {
+			t.Errorf("%d rows: recorded %d rows in a readable form beyond the cap of 3", tt.rows, rows)
+		}
+		// the replay returns the full response either way
+		if replayed := replayedBytes(t, mock); !bytes.Equal(replayed, response) {
+			t.Errorf("%d rows: replayed %d bytes, want the %d bytes of the response", tt.rows, len(replayed), len(response))
+		}
+	}
+}
+
+// TestRecordRawPayloads records a statement whose readable form reproduces
+// its bytes, which keeps its raw payload in the verbose mode only.
+func TestRecordRawPayloads(t *testing.T) {
+	query := "INSERT INTO events (name) VALUES ('signup')"
+	request := (&pgproto3.Query{String: query}).Encode(nil)
+	response := (&pgproto3.CommandComplete{CommandTag: []byte("INSERT 0 1")}).Encode(nil)
+	response = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(response)
+
+	for _, verbose := range []bool{false, true} {
+		mock := queryMock(t, recordSession(t, PostgresOptions{RecordRawPayloads: verbose}, request, response), query)
+		if len(mock.Spec.PostgresRequests) != 1 || len(mock.Spec.PostgresResponses) != 1 {
+			t.Fatalf("verbose %v: recorded %d requests and %d responses, want one of each", verbose, len(mock.Spec.PostgresRequests), len(mock.Spec.PostgresResponses))
+		}
+		recordedRequest, recordedResponse := mock.Spec.PostgresRequests[0], mock.Spec.PostgresResponses[0]
+
+		// the readable form is recorded in both modes, and replayed
+		if recordedRequest.Payload != "" || recordedResponse.Payload != "" {
+			t.Errorf("verbose %v: replaced the readable form with the payload", verbose)
+		}
+		if len(recordedResponse.CommandCompletes) != 1 || string(recordedResponse.CommandCompletes[0].CommandTag) != "INSERT 0 1" {
+			t.Errorf("verbose %v: got the command completes %+v, want the insert", verbose, recordedResponse.CommandCompletes)
+		}
+		if replayed := replayedBytes(t, mock); !bytes.Equal(replayed, response) {
+			t.Errorf("verbose %v: replayed %q, want %q", verbose, replayed, response)
+		}
+
+		for _, raw := range []struct {
+			payload string
+			want    []byte
+		}{{recordedRequest.RawPayload, request}, {recordedResponse.RawPayload, response}} {
+			if !verbose {
+				if raw.payload != "" {
+					t.Errorf("recorded the raw payload %q out of the verbose mode", raw.payload)
+				}
+				continue
+			}
+			if got, err := PayloadWireBytes(raw.payload); err != nil || !bytes.Equal(got, raw.want) {
+				t.Errorf("got the raw payload %q, want %q (%v)", got, raw.want, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package postgresparser
+
+import (
+	"net"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestConnectionMode(t *testing.T) {
+	previous := models.GetMode()
+	t.Cleanup(func() { models.SetMode(previous) })
+
+	recorded := map[string]string{"user": "app", "database": "orders"}
+	destConn, server := net.Pipe()
+	defer destConn.Close()
+	defer server.Close()
+	sslRequest := (&pgproto3.SSLRequest{}).Encode(nil)
+
+	for _, tt := range []struct {
+		name            string
+		mode            models.Mode
+		recordUnmatched bool
+		mocks           []*models.Mock
+		request         []byte
+		destConn        net.Conn
+		want            models.Mode
+	}{
+		{
+			name:            "recorded session",
+			mode:            models.MODE_TEST,
+			recordUnmatched: true,
+			mocks:           []*models.Mock{startupMock("mock-0", recorded)},
+			request:         startupWith(recorded),
+			destConn:        destConn,
+			want:            models.MODE_TEST,
+		},
+		{
+			name:            "unrecorded session",
+			mode:            models.MODE_TEST,
+			recordUnmatched: true,
+			mocks:           []*models.Mock{startupMock("mock-0", recorded)},
+			request:         startupWith(map[string]string{"user": "app", "database": "billing"}),
+			destConn:        destConn,
+			want:            models.MODE_RECORD,
+		},
+		{
+			name:            "SSLRequest with postgres mocks",
+			mode:            models.MODE_TEST,
+			recordUnmatched: true,
+			mocks:           []*models.Mock{startupMock("mock-0", recorded)},
+			request:         sslRequest,
+			destConn:        destConn,
+			want:            models.MODE_TEST,
+		},
+		{
+			name:            "SSLRequest without postgres mocks",
+			mode:            models.MODE_TEST,
+			recordUnmatched: true,
+			mocks:           []*models.Mock{{Name: "mock-0", Kind: models.HTTP}},
+			request:         sslRequest,
+			destConn:        destConn,
+			want:            models.MODE_RECORD,
+		},
+		{
+			name:     "disabled",
+			mode:     models.MODE_TEST,
+			request:  startupWith(map[string]string{"user": "app", "database": "billing"}),
+			destConn: destConn,
+			want:     models.MODE_TEST,
+		},
+		{
+			name:            "no destination to record",
+			mode:            models.MODE_TEST,
+			recordUnmatched: true,
+			request:         startupWith(map[string]string{"user": "app", "database": "billing"}),
+			want:            models.MODE_TEST,
+		},
+		{
+			name:            "recording",
+			mode:            models.MODE_RECORD,
+			recordUnmatched: true,
+			request:         startupWith(recorded),
+			destConn:        destConn,
+			want:            models.MODE_RECORD,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			models.SetMode(tt.mode)
+			h, err := hooks.NewHook(nil, 0, zap.NewNop())
+			if err != nil {
+				t.Fatalf("failed to create the hooks: %v", err)
+			}
+			h.SetConfigMocks(tt.mocks)
+			p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{RecordUnmatched: tt.recordUnmatched})
+			if got := p.connectionMode(tt.request, tt.destConn); got != tt.want {
+				t.Errorf("got the mode %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package postgresparser
+
+import (
+	"strings"
+)
+
+// isReplicationStartup reports whether the startup message opens a
+// replication connection: its replication parameter is database for the
+// logical replication and true for the physical one. These connections run
+// the replication commands, such as IDENTIFY_SYSTEM, as simple queries and
+// stream the changes with START_REPLICATION.
+func isReplicationStartup(buffer []byte) bool {
+	msg := decodeStartupMessage(buffer)
+	if msg == nil {
+		return false
+	}
+	switch strings.ToLower(msg.Parameters["replication"]) {
+	case "", "false", "off", "no", "0":
+		return false
+	}
+	return true
+}
+
+// startsCopyBoth reports whether the server responses hold the
+// CopyBothResponse of a START_REPLICATION, after which both sides stream
+// CopyData messages.
+func startsCopyBoth(buffer []byte) bool {
+	return containsMessage(buffer, 'W')
+}
+
+// endsCopyBoth reports whether the server responses hold the ReadyForQuery
+// following the end of a replication stream.
+func endsCopyBoth(buffer []byte) bool {
+	return containsMessage(buffer, 'Z')
+}
+
+// isCopyBothFeedback reports whether the client requests are only CopyData
+// messages, such as the standby status updates sent back to the server during
+// a replication stream. The CopyDone of the client ends the stream, which the
+// server answers.
+func isCopyBothFeedback(buffer []byte) bool {
+	msgs, ok := splitMessages(buffer)
+	if !ok || len(msgs) == 0 {
+		return false
+	}
+	for _, msg := range msgs {
+		if msg[0] != 'd' {
+			return false
+		}
+	}
+	return true
+}
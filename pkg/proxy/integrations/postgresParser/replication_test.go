@@ -0,0 +1,259 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestIsReplicationStartup(t *testing.T) {
+	for _, tt := range []struct {
+		replication string
+		want        bool
+	}{
+		{replication: "database", want: true},
+		{replication: "true", want: true},
+		{replication: "on", want: true},
+		{replication: ""},
+		{replication: "false"},
+		{replication: "OFF"},
+	} {
+		parameters := map[string]string{"user": "keploy", "database": "bank"}
+		if tt.replication != "" {
+			parameters["replication"] = tt.replication
+		}
+		if got := isReplicationStartup(startupWith(parameters)); got != tt.want {
+			t.Errorf("replication %q: got %v, want %v", tt.replication, got, tt.want)
+		}
+	}
+	if isReplicationStartup(SimpleQueryRequest("IDENTIFY_SYSTEM")[0]) {
+		t.Errorf("took a query for a replication startup")
+	}
+}
+
+func TestIsCopyBothFeedback(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		buffer []byte
+		want   bool
+	}{
+		{name: "standby status update", buffer: encodeMessages(&pgproto3.CopyData{Data: []byte("r")}), want: true},
+		{name: "several updates", buffer: encodeMessages(&pgproto3.CopyData{Data: []byte("r")}, &pgproto3.CopyData{Data: []byte("h")}), want: true},
+		{name: "end of the stream", buffer: encodeMessages(&pgproto3.CopyData{Data: []byte("r")}, &pgproto3.CopyDone{})},
+		{name: "query", buffer: SimpleQueryRequest("IDENTIFY_SYSTEM")[0]},
+		{name: "partial message", buffer: encodeMessages(&pgproto3.CopyData{Data: []byte("r")})[:3]},
+	} {
+		if got := isCopyBothFeedback(tt.buffer); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// replicationSession is a logical replication connection streaming two
+// changes, the client sending its feedback in between, until the CopyDone of
+// the client.
+type replicationSession struct {
+	startup, started []byte
+	// the client's START_REPLICATION, feedback and CopyDone
+	query, feedback, done []byte
+	// the server's changes before and after the feedback, and the end of the
+	// stream
+	changes, moreChanges, ended []byte
+}
+
+func newReplicationSession() replicationSession {
+	return replicationSession{
+		startup:     startupWith(map[string]string{"user": "keploy", "database": "bank", "replication": "database"}),
+		started:     encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		query:       SimpleQueryRequest("START_REPLICATION SLOT keploy LOGICAL 0/0")[0],
+		feedback:    encodeMessages(&pgproto3.CopyData{Data: []byte("r status")}),
+		done:        encodeMessages(&pgproto3.CopyDone{}),
+		changes:     encodeMessages(&pgproto3.CopyBothResponse{}, &pgproto3.CopyData{Data: []byte("w BEGIN 1")}, &pgproto3.CopyData{Data: []byte("w INSERT accounts " + strings.Repeat("x", 100))}),
+		moreChanges: encodeMessages(&pgproto3.CopyData{Data: []byte("w COMMIT 1")}),
+		ended:       encodeMessages(&pgproto3.CopyDone{}, &pgproto3.CommandComplete{CommandTag: []byte("START_REPLICATION")}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+	}
+}
+
+// client runs the client side of the session once started, and returns what
+// the server streamed.
+func (s replicationSession) client(conn net.Conn) ([]byte, error) {
+	streamed := make([]byte, len(s.changes)+len(s.moreChanges)+len(s.ended))
+	if _, err := conn.Write(s.query); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, streamed[:len(s.changes)]); err != nil {
+		return nil, err
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := conn.Write(s.feedback); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, streamed[len(s.changes):len(s.changes)+len(s.moreChanges)]); err != nil {
+		return nil, err
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := conn.Write(s.done); err != nil {
+		return nil, err
+	}
+	_, err := io.ReadFull(conn, streamed[len(s.changes)+len(s.moreChanges):])
+	return streamed, err
+}
+
+// record records the session with the options and returns the mocks and what
+// the client was streamed.
+func (s replicationSession) record(t *testing.T, opts PostgresOptions) ([]*models.Mock, []byte) {
+	t.Helper()
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, opts)
+
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		for _, exchange := range []struct{ request, response []byte }{
+			{s.startup, s.started},
+			{s.query, s.changes},
+			{s.feedback, s.moreChanges},
+			{s.done, s.ended},
+		} {
+			if _, err := io.ReadFull(server, make([]byte, len(exchange.request))); err != nil {
+				return
+			}
+			if _, err := server.Write(exchange.response); err != nil {
+				return
+			}
+		}
+		io.Copy(io.Discard, server)
+	}()
+	streamed := make(chan []byte, 1)
+	go func() {
+		defer app.Close()
+		if _, err := io.ReadFull(app, make([]byte, len(s.started))); err != nil {
+			streamed <- nil
+			return
+		}
+		got, _ := s.client(app)
+		streamed <- got
+	}()
+	done := make(chan struct{})
+	go func() {
+		encodePostgresOutgoing(s.startup, clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the recording of the session did not end")
+	}
+	return db.mocks, <-streamed
+}
+
+// mockOf returns the mock recorded for the request, or nil.
+func mockOf(t *testing.T, mocks []*models.Mock, request []byte) *models.Mock {
+	t.Helper()
+	for _, mock := range mocks {
+		for _, recorded := range mock.Spec.PostgresRequests {
+			if buffer, err := BackendWireBytes(recorded); err == nil && bytes.Equal(buffer, request) {
+				return mock
+			}
+		}
+	}
+	return nil
+}
+
+func TestReplicationStreamRecordedAndReplayed(t *testing.T) {
+	session := newReplicationSession()
+	stream := bytes.Join([][]byte{session.changes, session.moreChanges, session.ended}, nil)
+	mocks, streamed := session.record(t, PostgresOptions{})
+	if !bytes.Equal(streamed, stream) {
+		t.Fatalf("streamed %q to the client, want %q", streamed, stream)
+	}
+
+	started := mockOf(t, mocks, session.query)
+	if started == nil {
+		t.Fatalf("recorded no mock of the START_REPLICATION")
+	}
+	if got := replayedBytes(t, started); !bytes.Equal(got, append(append([]byte{}, session.changes...), session.moreChanges...)) {
+		t.Errorf("recorded the stream %q, want the changes before and after the feedback", got)
+	}
+	if mockOf(t, mocks, session.feedback) != nil {
+		t.Errorf("recorded the feedback of the client, which the server doesn't answer")
+	}
+	ended := mockOf(t, mocks, session.done)
+	if ended == nil || !bytes.Equal(replayedBytes(t, ended), session.ended) {
+		t.Fatalf("recorded the mocks %+v, want the CopyDone of the client answered by the end of the stream", mocks)
+	}
+
+	// the stream is replayed as a whole and the feedback dropped
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetConfigMocks(mocks)
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+	client, proxied := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- decodePostgresOutgoing(session.startup, proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+	}()
+	if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set the deadline: %v", err)
+	}
+	if _, err := io.ReadFull(client, make([]byte, len(session.started))); err != nil {
+		t.Fatalf("failed to read the replayed startup: %v", err)
+	}
+	// the recorded changes are replayed at once, before the feedback
+	if _, err := client.Write(session.query); err != nil {
+		t.Fatalf("failed to write the START_REPLICATION: %v", err)
+	}
+	replayed := make([]byte, len(stream))
+	changes := len(session.changes) + len(session.moreChanges)
+	if _, err := io.ReadFull(client, replayed[:changes]); err != nil {
+		t.Fatalf("failed to read the replayed changes: %v", err)
+	}
+	for _, request := range [][]byte{session.feedback, session.done} {
+		time.Sleep(20 * time.Millisecond)
+		if _, err := client.Write(request); err != nil {
+			t.Fatalf("failed to write %q: %v", request, err)
+		}
+	}
+	if _, err := io.ReadFull(client, replayed[changes:]); err != nil {
+		t.Fatalf("failed to read the replayed end of the stream: %v", err)
+	}
+	if !bytes.Equal(replayed, stream) {
+		t.Errorf("replayed %q, want %q", replayed, stream)
+	}
+	client.Close()
+	<-done
+}
+
+func TestReplicationStreamRecordingCapped(t *testing.T) {
+	session := newReplicationSession()
+	stream := bytes.Join([][]byte{session.changes, session.moreChanges, session.ended}, nil)
+	// the changes fit in the limit, as do the startup and the query, the rest
+	// of the stream doesn't
+	mocks, streamed := session.record(t, PostgresOptions{MaxPendingBytes: len(session.changes) + 1})
+	if !bytes.Equal(streamed, stream) {
+		t.Fatalf("streamed %q to the client, want %q", streamed, stream)
+	}
+	if started := mockOf(t, mocks, session.query); started == nil || !bytes.Equal(replayedBytes(t, started), session.changes) {
+		t.Errorf("recorded the mocks %+v, want the changes recorded up to the limit", mocks)
+	}
+	if mockOf(t, mocks, session.done) != nil {
+		t.Errorf("recorded the end of the stream past the limit")
+	}
+}
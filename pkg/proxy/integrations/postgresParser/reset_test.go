@@ -0,0 +1,76 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.uber.org/zap"
+)
+
+// replayScenario starts a session replayed from the mocks of h, sends the
+// query and returns the replayed response.
+func replayScenario(t *testing.T, p *PostgresParser, query []byte, size int) []byte {
+	t.Helper()
+	client, proxied := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- decodePostgresOutgoing(startupRequest(""), proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+	}()
+	if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set the deadline: %v", err)
+	}
+	started := encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if _, err := io.ReadFull(client, make([]byte, len(started))); err != nil {
+		t.Fatalf("failed to read the replayed startup: %v", err)
+	}
+	if _, err := client.Write(query); err != nil {
+		t.Fatalf("failed to write the query: %v", err)
+	}
+	replayed := make([]byte, size)
+	if _, err := io.ReadFull(client, replayed); err != nil {
+		t.Fatalf("failed to read the replayed response: %v", err)
+	}
+	client.Close()
+	<-done
+	return replayed
+}
+
+// TestScenarioReplayedAfterReset replays a scenario from the filtered mocks,
+// which the matcher unfilters as it consumes them, and checks the reset of
+// the hooks restores them as they were loaded for the same scenario again.
+func TestScenarioReplayedAfterReset(t *testing.T) {
+	query, response := SimpleQueryRequest("SELECT id FROM jobs")[0], rowsResponse(2)
+	mocks := recordSession(t, PostgresOptions{}, query, response)
+	for _, mock := range mocks {
+		mock.TestModeInfo.IsFiltered = true
+	}
+
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetConfigMocks(mocks)
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+	for scenario := 0; scenario < 2; scenario++ {
+		if replayed := replayScenario(t, p, query, len(response)); !bytes.Equal(replayed, response) {
+			t.Errorf("scenario %d: replayed %q, want %q", scenario, replayed, response)
+		}
+		h.ResetState()
+		config, _ := h.GetConfigMocks()
+		if len(config) != len(mocks) {
+			t.Fatalf("scenario %d: restored %d mocks, want %d", scenario, len(config), len(mocks))
+		}
+		for _, mock := range config {
+			if !mock.TestModeInfo.IsFiltered {
+				t.Errorf("scenario %d: restored the mock %s consumed by the replay", scenario, mock.Name)
+			}
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package postgresparser
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// boundExecute is the statement and the result format codes of the Bind an
+// Execute runs.
+type boundExecute struct {
+	statement string
+	formats   []int16
+}
+
+// boundExecutes returns the Bind of every Execute of the requests, in order.
+func boundExecutes(requestBuffers [][]byte) []boundExecute {
+	executes := []boundExecute{}
+	var current boundExecute
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		switch msgType {
+		case 'B':
+			bind := &pgproto3.Bind{}
+			if bind.Decode(body) == nil {
+				current = boundExecute{statement: bind.PreparedStatement, formats: append([]int16{}, bind.ResultFormatCodes...)}
+			}
+		case 'E':
+			executes = append(executes, current)
+		}
+	})
+	return executes
+}
+
+// recordedExecutes returns the Bind of every Execute of the recorded requests.
+func recordedExecutes(requests []models.Backend) []boundExecute {
+	buffers := make([][]byte, 0, len(requests))
+	for _, request := range requests {
+		buffer, err := BackendWireBytes(request)
+		if err != nil {
+			return nil
+		}
+		buffers = append(buffers, buffer)
+	}
+	return boundExecutes(buffers)
+}
+
+// resultFormat returns the format of the column given the result format codes
+// of a Bind: none means all the columns are in text, a single one applies to
+// all the columns.
+func resultFormat(formats []int16, column int) int16 {
+	switch {
+	case len(formats) == 0:
+		return 0
+	case len(formats) == 1:
+		return formats[0]
+	case column < len(formats):
+		return formats[column]
+	}
+	return 0
+}
+
+// observeStatementDescriptions keeps the columns described for the prepared
+// statements by the response, so that the rows of their later Executes can be
+// converted without a RowDescription of their own.
+func observeStatementDescriptions(requestBuffers [][]byte, response []byte, descriptions map[string][]pgproto3.FieldDescription) {
+	names := []string{}
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		describe := &pgproto3.Describe{}
+		if msgType == 'D' && describe.Decode(body) == nil && describe.ObjectType == 'S' {
+			names = append(names, describe.Name)
+		}
+	})
+	msgs, ok := splitMessages(response)
+	if len(names) == 0 || !ok {
+		return
+	}
+	// the ParameterDescription answering a Describe of a statement is
+	// followed by its RowDescription or its NoData
+	described := false
+	for _, msg := range msgs {
+		switch msg[0] {
+		case 't':
+			described = true
+		case 'T', 'n':
+			if !described || len(names) == 0 {
+				continue
+			}
+			described = false
+			name := names[0]
+			names = names[1:]
+			description := &pgproto3.RowDescription{}
+			if msg[0] == 'n' || description.Decode(msg[5:]) != nil {
+				delete(descriptions, name)
+				continue
+			}
+			fields := make([]pgproto3.FieldDescription, len(description.Fields))
+			copy(fields, description.Fields)
+			descriptions[name] = fields
+		}
+	}
+}
+
+// applyResultFormats rewrites the rows of the replayed response in the result
+// formats requested by the Binds of the client, when they differ from the
+// formats the rows were recorded in. The columns are typed by the
+// RowDescription of the response or, without one, by the description of the
+// prepared statement. A column is converted, and its format changed in the
+// RowDescription, only when all its values in the result can be converted, the
+// others are replayed as recorded.
+func applyResultFormats(response []byte, recorded, live []boundExecute, descriptions map[string][]pgproto3.FieldDescription) []byte {
+	differ := false
+	for i := range live {
+		if i < len(recorded) && !sameResultFormats(recorded[i].formats, live[i].formats) {
+			differ = true
+		}
+	}
+	msgs, ok := splitMessages(response)
+	if !differ || !ok {
+		return response
+	}
+
+	// the columns of every result whose values can't all be converted
+	unconverted := map[int]map[int]bool{}
+	walkResults(msgs, recorded, live, descriptions, func(msg []byte, result int, columns []pgproto3.FieldDescription, got, want []int16) {
+		row := &pgproto3.DataRow{}
+		if msg[0] != 'D' || columns == nil || row.Decode(msg[5:]) != nil || len(row.Values) != len(columns) {
+			return
+		}
+		for i, value := range row.Values {
+			from, to := resultFormat(got, i), resultFormat(want, i)
+			if value == nil || from == to {
+				continue
+			}
+			if _, ok := convertResultValue(columns[i].DataTypeOID, value, to); !ok {
+				if unconverted[result] == nil {
+					unconverted[result] = map[int]bool{}
+				}
+				unconverted[result][i] = true
+			}
+		}
+	})
+
+	rewritten := make([]byte, 0, len(response))
+	walkResults(msgs, recorded, live, descriptions, func(msg []byte, result int, columns []pgproto3.FieldDescription, got, want []int16) {
+		if columns == nil {
+			rewritten = append(rewritten, msg...)
+			return
+		}
+		switch msg[0] {
+		case 'T':
+			description := &pgproto3.RowDescription{}
+			if description.Decode(msg[5:]) != nil {
+				break
+			}
+			for i := range description.Fields {
+				if !unconverted[result][i] {
+					description.Fields[i].Format = resultFormat(want, i)
+				}
+			}
+			msg = description.Encode(nil)
+		case 'D':
+			row := &pgproto3.DataRow{}
+			if row.Decode(msg[5:]) != nil || len(row.Values) != len(columns) {
+				break
+			}
+			changed := false
+			for i, value := range row.Values {
+				from, to := resultFormat(got, i), resultFormat(want, i)
+				if value == nil || from == to || unconverted[result][i] {
+					continue
+				}
+				if converted, ok := convertResultValue(columns[i].DataTypeOID, value, to); ok {
+					row.Values[i] = converted
+					changed = true
+				}
+			}
+			if changed {
+				msg = encodeDataRow(row.Values)
+			}
+		}
+		rewritten = append(rewritten, msg...)
+	})
+	return rewritten
+}
+
+// walkResults calls visit for every message of the response, along with the
+// index of the result it belongs to, its columns and the formats its rows were
+// recorded in and are requested in. The messages which aren't part of a result
+// to convert, such as the RowDescriptions of the statements, are visited with
+// no columns and are replayed as recorded.
+func walkResults(msgs [][]byte, recorded, live []boundExecute, descriptions map[string][]pgproto3.FieldDescription, visit func(msg []byte, result int, columns []pgproto3.FieldDescription, got, want []int16)) {
+	execute := 0
+	var fields []pgproto3.FieldDescription
+	statementDescribed := false
+	for _, msg := range msgs {
+		convert := execute < len(live) && execute < len(recorded)
+		var columns []pgproto3.FieldDescription
+		var got, want []int16
+		if convert {
+			got, want = recorded[execute].formats, live[execute].formats
+		}
+		switch msg[0] {
+		case 't':
+			statementDescribed = true
+		case 'n':
+			statementDescribed = false
+		case 'T':
+			description := &pgproto3.RowDescription{}
+			if statementDescribed || description.Decode(msg[5:]) != nil {
+				// the statements are described with the text format
+				statementDescribed = false
+				convert = false
+				break
+			}
+			fields = description.Fields
+			columns = fields
+		case 'D':
+			columns = fields
+			if columns == nil && convert {
+				columns = descriptions[live[execute].statement]
+			}
+		case 'C', 's', 'I', 'E':
+			execute++
+			fields = nil
+		}
+		if !convert || columns == nil {
+			visit(msg, execute, nil, nil, nil)
+			continue
+		}
+		visit(msg, execute, columns, got, want)
+	}
+}
+
+func sameResultFormats(a, b []int16) bool {
+	n := len(a)
+	if len(b) > n {
+		n = len(b)
+	}
+	if n == 0 {
+		return true
+	}
+	for i := 0; i < n; i++ {
+		if resultFormat(a, i) != resultFormat(b, i) {
+			return false
+		}
+	}
+	return true
+}
+
+// encodeDataRow encodes the values of a DataRow. The fork of pgproto3 encodes
+// the readable row values only.
+func encodeDataRow(values [][]byte) []byte {
+	size := 6
+	for _, value := range values {
+		size += 4 + len(value)
+	}
+	msg := make([]byte, 0, size+1)
+	msg = append(msg, 'D')
+	msg = binary.BigEndian.AppendUint32(msg, uint32(size))
+	msg = binary.BigEndian.AppendUint16(msg, uint16(len(values)))
+	for _, value := range values {
+		if value == nil {
+			msg = binary.BigEndian.AppendUint32(msg, math.MaxUint32)
+			continue
+		}
+		msg = binary.BigEndian.AppendUint32(msg, uint32(len(value)))
+		msg = append(msg, value...)
+	}
+	return msg
+}
+
+// convertResultValue converts a value of the type to the text (0) or the
+// binary (1) format. It reports false for the types it doesn't convert.
+func convertResultValue(oid uint32, value []byte, format int16) ([]byte, bool) {
+	if format == 1 {
+		return textToBinary(oid, value)
+	}
+	return binaryToText(oid, value)
+}
+
+func textToBinary(oid uint32, value []byte) ([]byte, bool) {
+	text := string(value)
+	switch oid {
+	case 25, 1043, 1042, 19, 114, 705: // text, varchar, bpchar, name, json, unknown
+		return value, true
+	case 3802: // jsonb
+		return append([]byte{1}, value...), true
+	case 16: // bool
+		switch text {
+		case "t":
+			return []byte{1}, true
+		case "f":
+			return []byte{0}, true
+		}
+	case 21: // int2
+		if n, err := strconv.ParseInt(text, 10, 16); err == nil {
+			return binary.BigEndian.AppendUint16(nil, uint16(n)), true
+		}
+	case 23: // int4
+		if n, err := strconv.ParseInt(text, 10, 32); err == nil {
+			return binary.BigEndian.AppendUint32(nil, uint32(n)), true
+		}
+	case 26: // oid
+		if n, err := strconv.ParseUint(text, 10, 32); err == nil {
+			return binary.BigEndian.AppendUint32(nil, uint32(n)), true
+		}
+	case 20: // int8
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return binary.BigEndian.AppendUint64(nil, uint64(n)), true
+		}
+	case 700: // float4
+		if f, err := strconv.ParseFloat(text, 32); err == nil {
+			return binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f))), true
+		}
+	case 701: // float8
+		if f, err := strconv.ParseFloat(text, 64); err == nil {
+			return binary.BigEndian.AppendUint64(nil, math.Float64bits(f)), true
+		}
+	case 17: // bytea
+		if b, err := hex.DecodeString(strings.TrimPrefix(text, `\x`)); err == nil && strings.HasPrefix(text, `\x`) {
+			return b, true
+		}
+	case 2950: // uuid
+		if b, err := hex.DecodeString(strings.ReplaceAll(text, "-", "")); err == nil && len(b) == 16 {
+			return b, true
+		}
+	}
+	return nil, false
+}
+
+func binaryToText(oid uint32, value []byte) ([]byte, bool) {
+	switch oid {
+	case 25, 1043, 1042, 19, 114, 705:
+		return value, true
+	case 3802:
+		if len(value) > 0 && value[0] == 1 {
+			return value[1:], true
+		}
+	case 16:
+		if len(value) == 1 {
+			if value[0] != 0 {
+				return []byte("t"), true
+			}
+			return []byte("f"), true
+		}
+	case 21:
+		if len(value) == 2 {
+			return []byte(strconv.FormatInt(int64(int16(binary.BigEndian.Uint16(value))), 10)), true
+		}
+	case 23:
+		if len(value) == 4 {
+			return []byte(strconv.FormatInt(int64(int32(binary.BigEndian.Uint32(value))), 10)), true
+		}
+	case 26:
+		if len(value) == 4 {
+			return []byte(strconv.FormatUint(uint64(binary.BigEndian.Uint32(value)), 10)), true
+		}
+	case 20:
+		if len(value) == 8 {
+			return []byte(strconv.FormatInt(int64(binary.BigEndian.Uint64(value)), 10)), true
+		}
+	case 700:
+		if len(value) == 4 {
+			return []byte(formatFloat(float64(math.Float32frombits(binary.BigEndian.Uint32(value))), 32)), true
+		}
+	case 701:
+		if len(value) == 8 {
+			return []byte(formatFloat(math.Float64frombits(binary.BigEndian.Uint64(value)), 64)), true
+		}
+	case 17:
+		return []byte(`\x` + hex.EncodeToString(value)), true
+	case 2950:
+		if len(value) == 16 {
+			h := hex.EncodeToString(value)
+			return []byte(h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]), true
+		}
+	}
+	return nil, false
+}
+
+// formatFloat formats a float as the server does in the text format.
+func formatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
@@ -0,0 +1,173 @@
+package postgresparser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestConvertResultValue(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		oid    uint32
+		text   string
+		binary []byte
+	}{
+		{name: "text", oid: 25, text: "keploy", binary: []byte("keploy")},
+		{name: "bool", oid: 16, text: "t", binary: []byte{1}},
+		{name: "int2", oid: 21, text: "-2", binary: []byte{0xff, 0xfe}},
+		{name: "int4", oid: 23, text: "42", binary: []byte{0, 0, 0, 42}},
+		{name: "int8", oid: 20, text: "258", binary: []byte{0, 0, 0, 0, 0, 0, 1, 2}},
+		{name: "oid", oid: 26, text: "4294967295", binary: []byte{0xff, 0xff, 0xff, 0xff}},
+		{name: "float8", oid: 701, text: "1.5", binary: []byte{0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+		{name: "jsonb", oid: 3802, text: `{"a":1}`, binary: append([]byte{1}, `{"a":1}`...)},
+		{name: "bytea", oid: 17, text: `\xdead`, binary: []byte{0xde, 0xad}},
+		{name: "uuid", oid: 2950, text: "123e4567-e89b-12d3-a456-426614174000", binary: []byte{0x12, 0x3e, 0x45, 0x67, 0xe8, 0x9b, 0x12, 0xd3, 0xa4, 0x56, 0x42, 0x66, 0x14, 0x17, 0x40, 0x00}},
+	} {
+		if got, ok := convertResultValue(tt.oid, []byte(tt.text), 1); !ok || !bytes.Equal(got, tt.binary) {
+			t.Errorf("%s: got the binary %v (%v), want %v", tt.name, got, ok, tt.binary)
+		}
+		if got, ok := convertResultValue(tt.oid, tt.binary, 0); !ok || string(got) != tt.text {
+			t.Errorf("%s: got the text %q (%v), want %q", tt.name, got, ok, tt.text)
+		}
+	}
+
+	for _, tt := range []struct {
+		name   string
+		oid    uint32
+		value  string
+		format int16
+	}{
+		{name: "numeric", oid: 1700, value: "1.50", format: 1},
+		{name: "int4 out of range", oid: 23, value: "4294967296", format: 1},
+		{name: "bool spelled out", oid: 16, value: "true", format: 1},
+		{name: "truncated int4", oid: 23, value: "\x00\x01", format: 0},
+		{name: "jsonb of another version", oid: 3802, value: "\x02{}", format: 0},
+	} {
+		if got, ok := convertResultValue(tt.oid, []byte(tt.value), tt.format); ok {
+			t.Errorf("%s: converted to %q, want it left as recorded", tt.name, got)
+		}
+	}
+}
+
+func TestBoundExecutes(t *testing.T) {
+	requests := [][]byte{encodeMessages(
+		&pgproto3.Parse{Name: "users", Query: "SELECT id FROM users"},
+		&pgproto3.Bind{PreparedStatement: "users", ResultFormatCodes: []int16{1}},
+		&pgproto3.Execute{},
+		&pgproto3.Execute{},
+		&pgproto3.Bind{PreparedStatement: "orders"},
+		&pgproto3.Execute{},
+		&pgproto3.Sync{},
+	)}
+	want := []boundExecute{{statement: "users", formats: []int16{1}}, {statement: "users", formats: []int16{1}}, {statement: "orders", formats: []int16{}}}
+	if got := boundExecutes(requests); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestSameResultFormats(t *testing.T) {
+	for _, tt := range []struct {
+		a, b []int16
+		want bool
+	}{
+		{want: true},
+		{a: []int16{0}, want: true},
+		{a: []int16{1}, b: []int16{1, 1, 1}, want: true},
+		{a: []int16{1}, b: []int16{1, 0}},
+		{b: []int16{1}},
+	} {
+		if got := sameResultFormats(tt.a, tt.b); got != tt.want {
+			t.Errorf("%v and %v: got the same formats %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+// accountsDescription describes an int4 id, a text name and a numeric
+// balance in the format.
+func accountsDescription(format int16) *pgproto3.RowDescription {
+	return &pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{
+		{Name: []byte("id"), DataTypeOID: 23, DataTypeSize: 4, TypeModifier: -1, Format: format},
+		{Name: []byte("name"), DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1, Format: format},
+		{Name: []byte("balance"), DataTypeOID: 1700, DataTypeSize: -1, TypeModifier: -1, Format: format},
+	}}
+}
+
+func TestApplyResultFormats(t *testing.T) {
+	recorded := encodeMessages(accountsDescription(0))
+	recorded = append(recorded, encodeDataRow([][]byte{[]byte("1"), []byte("Ada"), []byte("100.50")})...)
+	recorded = append(recorded, encodeDataRow([][]byte{[]byte("2"), nil, nil})...)
+	recorded = append(recorded, encodeMessages(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 2")}, &pgproto3.ReadyForQuery{TxStatus: 'I'})...)
+
+	// the numeric balance isn't converted, its column stays in text
+	description := accountsDescription(1)
+	description.Fields[2].Format = 0
+	want := encodeMessages(description)
+	want = append(want, encodeDataRow([][]byte{{0, 0, 0, 1}, []byte("Ada"), []byte("100.50")})...)
+	want = append(want, encodeDataRow([][]byte{{0, 0, 0, 2}, nil, nil})...)
+	want = append(want, encodeMessages(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 2")}, &pgproto3.ReadyForQuery{TxStatus: 'I'})...)
+
+	textExecute := []boundExecute{{statement: "accounts"}}
+	binaryExecute := []boundExecute{{statement: "accounts", formats: []int16{1}}}
+	if got := applyResultFormats(recorded, textExecute, binaryExecute, nil); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := applyResultFormats(recorded, textExecute, textExecute, nil); !bytes.Equal(got, recorded) {
+		t.Errorf("got %q, want the response bound in the recorded formats unchanged", got)
+	}
+
+	// an id out of the range of its type keeps the whole column in text
+	unconvertible := encodeMessages(accountsDescription(0))
+	unconvertible = append(unconvertible, encodeDataRow([][]byte{[]byte("1"), []byte("Ada"), nil})...)
+	unconvertible = append(unconvertible, encodeDataRow([][]byte{[]byte("4294967296"), []byte("Bob"), nil})...)
+	got := applyResultFormats(unconvertible, textExecute, binaryExecute, nil)
+	msgs, ok := splitMessages(got)
+	if !ok || len(msgs) != 3 {
+		t.Fatalf("got the messages %q, want the description and the rows", got)
+	}
+	converted := &pgproto3.RowDescription{}
+	if err := converted.Decode(msgs[0][5:]); err != nil || converted.Fields[0].Format != 0 || converted.Fields[1].Format != 1 {
+		t.Errorf("got the description %+v, want the id in text and the name in binary", converted)
+	}
+	if !bytes.Equal(msgs[1], encodeDataRow([][]byte{[]byte("1"), []byte("Ada"), nil})) {
+		t.Errorf("got the row %q, want its id in text", msgs[1])
+	}
+}
+
+func TestResultFormatsOfDescribedStatement(t *testing.T) {
+	describe := [][]byte{encodeMessages(
+		&pgproto3.Parse{Name: "accounts", Query: "SELECT id, name, balance FROM accounts"},
+		&pgproto3.Describe{ObjectType: 'S', Name: "accounts"},
+		&pgproto3.Sync{},
+	)}
+	described := encodeMessages(&pgproto3.ParseComplete{}, &pgproto3.ParameterDescription{}, accountsDescription(0), &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	descriptions := map[string][]pgproto3.FieldDescription{}
+	observeStatementDescriptions(describe, described, descriptions)
+	if len(descriptions["accounts"]) != 3 {
+		t.Fatalf("got the descriptions %+v, want the columns of accounts", descriptions)
+	}
+	// the RowDescription of a statement is never converted
+	statement := []boundExecute{{statement: "accounts", formats: []int16{1}}}
+	if got := applyResultFormats(described, []boundExecute{{statement: "accounts"}}, statement, descriptions); !bytes.Equal(got, described) {
+		t.Errorf("got %q, want the description of the statement unchanged", got)
+	}
+
+	// the rows of its Execute are typed by the description
+	response := encodeMessages(&pgproto3.BindComplete{})
+	response = append(response, encodeDataRow([][]byte{[]byte("7"), []byte("Ada"), []byte("1.00")})...)
+	response = append(response, encodeMessages(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")}, &pgproto3.ReadyForQuery{TxStatus: 'I'})...)
+	want := encodeMessages(&pgproto3.BindComplete{})
+	want = append(want, encodeDataRow([][]byte{{0, 0, 0, 7}, []byte("Ada"), []byte("1.00")})...)
+	want = append(want, encodeMessages(&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")}, &pgproto3.ReadyForQuery{TxStatus: 'I'})...)
+	if got := applyResultFormats(response, []boundExecute{{statement: "accounts"}}, statement, descriptions); !bytes.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// the statement described without rows is forgotten
+	observeStatementDescriptions(describe, encodeMessages(&pgproto3.ParseComplete{}, &pgproto3.ParameterDescription{}, &pgproto3.NoData{}, &pgproto3.ReadyForQuery{TxStatus: 'I'}), descriptions)
+	if _, ok := descriptions["accounts"]; ok {
+		t.Errorf("kept the description of a statement returning no rows")
+	}
+}
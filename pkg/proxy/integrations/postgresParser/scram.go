@@ -0,0 +1,365 @@
+package postgresparser
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/xdg-go/pbkdf2"
+	"github.com/xdg-go/stringprep"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+)
+
+const (
+	scramSHA256     = "SCRAM-SHA-256"
+	scramSHA256Plus = "SCRAM-SHA-256-PLUS"
+	// scramIterations is the iteration count advertised in the replayed
+	// server-first-message, same as the postgres default.
+	scramIterations = 4096
+	// channelBindingType is the only channel binding type supported by postgres.
+	channelBindingType = "tls-server-end-point"
+)
+
+type scramState int
+
+const (
+	scramIdle scramState = iota
+	scramAwaitingInitialResponse
+	scramAwaitingResponse
+	scramDone
+)
+
+// scramSession replays the server side of a SCRAM-SHA-256 exchange for a client
+// connection in test mode. The recorded nonces and proofs can not be reused as
+// the client generates a fresh nonce on every connection, hence the messages are
+// computed using the configured password of the database user.
+//
+// When the replay connection is secured by TLS, SCRAM-SHA-256-PLUS is offered as
+// well and the channel binding data of the replay connection is verified in
+// place of the one captured while recording.
+type scramSession struct {
+	password       string
+	state          scramState
+	channelBinding []byte
+
+	mechanism       string
+	gs2Header       string
+	clientFirstBare string
+	serverFirst     string
+	serverNonce     string
+	salt            []byte
+}
+
+func newScramSession(password string, channelBinding []byte) *scramSession {
+	return &scramSession{
+		password:       password,
+		channelBinding: channelBinding,
+	}
+}
+
+// channelBindingData returns the tls-server-end-point binding data of the
+// certificate served to the client (RFC 5929), or nil without one.
+func channelBindingData(cert *tls.Certificate) []byte {
+	if cert == nil || len(cert.Certificate) == 0 {
+		return nil
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		leaf, err = x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			return nil
+		}
+	}
+	return tlsServerEndPoint(leaf)
+}
+
+// tlsServerEndPoint hashes the certificate with the hash of its signature
+// algorithm, upgrading MD5 and SHA-1 to SHA-256 as mandated by RFC 5929.
+func tlsServerEndPoint(cert *x509.Certificate) []byte {
+	switch cert.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.ECDSAWithSHA384, x509.SHA384WithRSAPSS:
+		sum := sha512.Sum384(cert.Raw)
+		return sum[:]
+	case x509.SHA512WithRSA, x509.ECDSAWithSHA512, x509.SHA512WithRSAPSS:
+		sum := sha512.Sum512(cert.Raw)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(cert.Raw)
+		return sum[:]
+	}
+}
+
+// mechanisms returns the SASL mechanisms offered to the client.
+func (s *scramSession) mechanisms() []string {
+	if s.channelBinding != nil {
+		return []string{scramSHA256Plus, scramSHA256}
+	}
+	return []string{scramSHA256}
+}
+
+// startupAuthenticatesWithSASL reports whether the recorded server asked the
+// client to authenticate with SASL in response to the startup message.
+func startupAuthenticatesWithSASL(h *hooks.Hook) bool {
+	mocks, err := h.GetConfigMocks()
+	if err != nil {
+		return false
+	}
+	for _, mock := range mocks {
+		for i, req := range mock.Spec.PostgresRequests {
+			if req.Identfier != "StartupRequest" || i >= len(mock.Spec.PostgresResponses) {
+				continue
+			}
+			if mock.Spec.PostgresResponses[i].AuthType == AuthTypeSASL {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// startupRequestsAuthentication reports whether the recorded server asked the
+// client to authenticate, by any method, in response to the startup message.
+func startupRequestsAuthentication(h *hooks.Hook) bool {
+	mocks, err := h.GetConfigMocks()
+	if err != nil {
+		return false
+	}
+	for _, mock := range mocks {
+		for i, req := range mock.Spec.PostgresRequests {
+			if req.Identfier != "StartupRequest" || i >= len(mock.Spec.PostgresResponses) {
+				continue
+			}
+			if containsPacketType(mock.Spec.PostgresResponses[i], "R") && !containsPacketType(mock.Spec.PostgresResponses[i], "K") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// skippedAuthentication returns the response accepting the client right away:
+// an AuthenticationOk followed by the recorded ParameterStatus, BackendKeyData
+// and ReadyForQuery.
+func skippedAuthentication(h *hooks.Hook) []byte {
+	return append((&pgproto3.AuthenticationOk{}).Encode(nil), recordedStartupCompletion(h)...)
+}
+
+// respond answers the request when it is part of the SCRAM exchange. It
+// returns false when the request should go through the mock matching.
+func (s *scramSession) respond(request []byte, h *hooks.Hook) ([]byte, bool, error) {
+	switch s.state {
+	case scramIdle:
+		if len(request) < 8 || !isStartupPacket(request) || !startupAuthenticatesWithSASL(h) {
+			return nil, false, nil
+		}
+		s.state = scramAwaitingInitialResponse
+		return (&pgproto3.AuthenticationSASL{AuthMechanisms: s.mechanisms()}).Encode(nil), true, nil
+	case scramAwaitingInitialResponse:
+		if len(request) < 5 || request[0] != 'p' {
+			return nil, false, nil
+		}
+		msg := &pgproto3.SASLInitialResponse{}
+		if err := msg.Decode(request[5:]); err != nil {
+			return nil, true, fmt.Errorf("failed to decode the SASLInitialResponse: %v", err)
+		}
+		serverFirst, err := s.handleClientFirst(msg.AuthMechanism, string(msg.Data))
+		if err != nil {
+			return authenticationFailed(err), true, err
+		}
+		s.state = scramAwaitingResponse
+		return (&pgproto3.AuthenticationSASLContinue{Data: []byte(serverFirst)}).Encode(nil), true, nil
+	case scramAwaitingResponse:
+		if len(request) < 5 || request[0] != 'p' {
+			return nil, false, nil
+		}
+		msg := &pgproto3.SASLResponse{}
+		if err := msg.Decode(request[5:]); err != nil {
+			return nil, true, fmt.Errorf("failed to decode the SASLResponse: %v", err)
+		}
+		serverFinal, err := s.handleClientFinal(string(msg.Data))
+		if err != nil {
+			return authenticationFailed(err), true, err
+		}
+		s.state = scramDone
+		response := (&pgproto3.AuthenticationSASLFinal{Data: []byte(serverFinal)}).Encode(nil)
+		response = (&pgproto3.AuthenticationOk{}).Encode(response)
+		return append(response, recordedStartupCompletion(h)...), true, nil
+	}
+	return nil, false, nil
+}
+
+// handleClientFirst parses the client-first-message and returns the
+// server-first-message with a fresh nonce and salt.
+func (s *scramSession) handleClientFirst(mechanism, clientFirst string) (string, error) {
+	if mechanism != scramSHA256 && !(mechanism == scramSHA256Plus && s.channelBinding != nil) {
+		return "", fmt.Errorf("unsupported SASL mechanism %q", mechanism)
+	}
+	// gs2-header: gs2-cbind-flag "," [authzid] ","
+	parts := strings.SplitN(clientFirst, ",", 3)
+	if len(parts) != 3 {
+		return "", errors.New("malformed SCRAM client-first-message")
+	}
+	cbindFlag := parts[0]
+	switch {
+	case mechanism == scramSHA256Plus && cbindFlag != "p="+channelBindingType:
+		return "", fmt.Errorf("unsupported channel binding %q", cbindFlag)
+	case mechanism == scramSHA256 && strings.HasPrefix(cbindFlag, "p="):
+		return "", errors.New("channel binding requested without SCRAM-SHA-256-PLUS")
+	case mechanism == scramSHA256 && cbindFlag == "y" && s.channelBinding != nil:
+		// the client supports channel binding and thinks the server doesn't
+		return "", errors.New("channel binding downgrade detected")
+	}
+
+	s.mechanism = mechanism
+	s.gs2Header = parts[0] + "," + parts[1] + ","
+	s.clientFirstBare = parts[2]
+	clientNonce := scramAttribute(s.clientFirstBare, 'r')
+	if clientNonce == "" {
+		return "", errors.New("client nonce not found in the SCRAM client-first-message")
+	}
+
+	s.salt = make([]byte, 16)
+	nonce := make([]byte, 18)
+	if _, err := rand.Read(s.salt); err != nil {
+		return "", err
+	}
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	s.serverNonce = clientNonce + base64.StdEncoding.EncodeToString(nonce)
+	s.serverFirst = fmt.Sprintf("r=%s,s=%s,i=%d", s.serverNonce, base64.StdEncoding.EncodeToString(s.salt), scramIterations)
+	return s.serverFirst, nil
+}
+
+// handleClientFinal verifies the channel binding and the proof of the
+// client-final-message and returns the server-final-message.
+func (s *scramSession) handleClientFinal(clientFinal string) (string, error) {
+	idx := strings.LastIndex(clientFinal, ",p=")
+	if idx < 0 {
+		return "", errors.New("client proof not found in the SCRAM client-final-message")
+	}
+	clientFinalWithoutProof := clientFinal[:idx]
+	proof, err := base64.StdEncoding.DecodeString(clientFinal[idx+len(",p="):])
+	if err != nil {
+		return "", fmt.Errorf("malformed SCRAM client proof: %v", err)
+	}
+
+	cbind := []byte(s.gs2Header)
+	if s.mechanism == scramSHA256Plus {
+		cbind = append(cbind, s.channelBinding...)
+	}
+	if scramAttribute(clientFinalWithoutProof, 'c') != base64.StdEncoding.EncodeToString(cbind) {
+		return "", errors.New("SCRAM channel binding check failed")
+	}
+	if scramAttribute(clientFinalWithoutProof, 'r') != s.serverNonce {
+		return "", errors.New("SCRAM nonce mismatch")
+	}
+
+	password, err := stringprep.SASLprep.Prepare(s.password)
+	if err != nil {
+		password = s.password
+	}
+	saltedPassword := pbkdf2.Key([]byte(password), s.salt, scramIterations, sha256.Size, sha256.New)
+	clientKey := computeHMAC(saltedPassword, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	serverKey := computeHMAC(saltedPassword, []byte("Server Key"))
+	authMessage := s.clientFirstBare + "," + s.serverFirst + "," + clientFinalWithoutProof
+
+	clientSignature := computeHMAC(storedKey[:], []byte(authMessage))
+	if len(proof) != len(clientSignature) {
+		return "", errors.New("invalid SCRAM client proof")
+	}
+	recoveredClientKey := make([]byte, len(proof))
+	for i := range proof {
+		recoveredClientKey[i] = proof[i] ^ clientSignature[i]
+	}
+	recoveredStoredKey := sha256.Sum256(recoveredClientKey)
+	if !hmac.Equal(recoveredStoredKey[:], storedKey[:]) {
+		return "", errors.New("password authentication failed")
+	}
+
+	serverSignature := computeHMAC(serverKey, []byte(authMessage))
+	return "v=" + base64.StdEncoding.EncodeToString(serverSignature), nil
+}
+
+// scramAttribute returns the value of the attribute in a SCRAM message.
+func scramAttribute(msg string, attr byte) string {
+	for _, part := range strings.Split(msg, ",") {
+		if len(part) > 1 && part[0] == attr && part[1] == '=' {
+			return part[2:]
+		}
+	}
+	return ""
+}
+
+func computeHMAC(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// authenticationFailed encodes the ErrorResponse sent by postgres for a failed
+// authentication.
+func authenticationFailed(err error) []byte {
+	return (&pgproto3.ErrorResponse{
+		Severity: "FATAL",
+		Code:     "28P01",
+		Message:  err.Error(),
+	}).Encode(nil)
+}
+
+// recordedStartupCompletion returns the messages sent by the recorded server
+// after a successful authentication (ParameterStatus, BackendKeyData and
+// ReadyForQuery), leaving out the authentication messages.
+func recordedStartupCompletion(h *hooks.Hook) []byte {
+	readyForQuery := (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(nil)
+	mocks, err := h.GetConfigMocks()
+	if err != nil {
+		return readyForQuery
+	}
+	for _, mock := range mocks {
+		for _, resp := range mock.Spec.PostgresResponses {
+			if !containsPacketType(resp, "K") {
+				continue
+			}
+			encoded, err := FrontendWireBytes(resp)
+			if err != nil {
+				continue
+			}
+			return stripAuthenticationMessages(encoded)
+		}
+	}
+	return readyForQuery
+}
+
+func containsPacketType(resp models.Frontend, packetType string) bool {
+	for _, t := range resp.PacketTypes {
+		if t == packetType {
+			return true
+		}
+	}
+	return false
+}
+
+// stripAuthenticationMessages drops the leading 'R' messages of the buffer.
+func stripAuthenticationMessages(buffer []byte) []byte {
+	i := 0
+	for i < len(buffer) && buffer[i] == 'R' {
+		bodyLen, err := readMessageBodyLen(buffer, i)
+		if err != nil {
+			break
+		}
+		i += 5 + bodyLen
+	}
+	return buffer[i:]
+}
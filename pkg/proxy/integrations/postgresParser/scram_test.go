@@ -0,0 +1,282 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"github.com/xdg-go/pbkdf2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// scramHooks returns the hooks of a recording whose server asked the client
+// to authenticate with SASL, then completed the startup.
+func scramHooks(t *testing.T) *hooks.Hook {
+	t.Helper()
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetConfigMocks([]*models.Mock{
+		{
+			Name: "mock-0",
+			Kind: models.Postgres,
+			Spec: models.MockSpec{
+				PostgresRequests:  []models.Backend{{Identfier: "StartupRequest", Payload: base64.StdEncoding.EncodeToString(startupRequest(""))}},
+				PostgresResponses: []models.Frontend{{PacketTypes: []string{"R"}, AuthType: AuthTypeSASL}},
+			},
+		},
+		{
+			Name: "mock-1",
+			Kind: models.Postgres,
+			Spec: models.MockSpec{
+				PostgresRequests: []models.Backend{{PacketTypes: []string{"p"}}},
+				PostgresResponses: []models.Frontend{{
+					PacketTypes:             []string{"R", "S", "K", "Z"},
+					AuthType:                AuthTypeOk,
+					ParameterStatusCombined: []pgproto3.ParameterStatus{{Name: "server_version", Value: "15.4"}},
+					BackendKeyData:          pgproto3.BackendKeyData{ProcessID: 42, SecretKey: 7},
+					ReadyForQuery:           pgproto3.ReadyForQuery{TxStatus: 'I'},
+				}},
+			},
+		},
+	})
+	return h
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// scramClient is the client side of a SCRAM exchange (RFC 5802).
+type scramClient struct {
+	password       string
+	gs2Header      string
+	channelBinding []byte
+	nonce          string
+	authMessage    string
+	saltedPassword []byte
+}
+
+func (c *scramClient) first() string {
+	return c.gs2Header + "n=,r=" + c.nonce
+}
+
+// final returns the client-final-message answering the server-first-message.
+func (c *scramClient) final(t *testing.T, serverFirst string) string {
+	t.Helper()
+	salt, err := base64.StdEncoding.DecodeString(scramAttribute(serverFirst, 's'))
+	if err != nil {
+		t.Fatalf("failed to decode the salt of %q: %v", serverFirst, err)
+	}
+	iterations, err := strconv.Atoi(scramAttribute(serverFirst, 'i'))
+	if err != nil {
+		t.Fatalf("failed to read the iterations of %q: %v", serverFirst, err)
+	}
+	serverNonce := scramAttribute(serverFirst, 'r')
+	if !strings.HasPrefix(serverNonce, c.nonce) || len(serverNonce) == len(c.nonce) {
+		t.Fatalf("got the server nonce %q, want one extending the client nonce %q", serverNonce, c.nonce)
+	}
+
+	c.saltedPassword = pbkdf2.Key([]byte(c.password), salt, iterations, sha256.Size, sha256.New)
+	clientKey := hmacSHA256(c.saltedPassword, "Client Key")
+	storedKey := sha256.Sum256(clientKey)
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString(append([]byte(c.gs2Header), c.channelBinding...)) + ",r=" + serverNonce
+	c.authMessage = strings.TrimPrefix(c.first(), c.gs2Header) + "," + serverFirst + "," + withoutProof
+	signature := hmacSHA256(storedKey[:], c.authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ signature[i]
+	}
+	return withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)
+}
+
+// serverFinal returns the server-final-message the server must send.
+func (c *scramClient) serverFinal() string {
+	return "v=" + base64.StdEncoding.EncodeToString(hmacSHA256(hmacSHA256(c.saltedPassword, "Server Key"), c.authMessage))
+}
+
+// authenticate runs the exchange of the client with the session, and returns
+// the last response of the session.
+func authenticate(t *testing.T, session *scramSession, client *scramClient, mechanism string) ([]byte, error) {
+	t.Helper()
+	h := scramHooks(t)
+	response, handled, err := session.respond(startupRequest(""), h)
+	if err != nil || !handled {
+		t.Fatalf("failed to answer the startup message: %v", err)
+	}
+	sasl := &pgproto3.AuthenticationSASL{}
+	if err := sasl.Decode(response[5:]); err != nil {
+		t.Fatalf("failed to decode the AuthenticationSASL %q: %v", response, err)
+	}
+	if got := strings.Join(sasl.AuthMechanisms, ","); got != strings.Join(session.mechanisms(), ",") {
+		t.Errorf("offered the mechanisms %s, want %v", got, session.mechanisms())
+	}
+
+	initial := (&pgproto3.SASLInitialResponse{AuthMechanism: mechanism, Data: []byte(client.first())}).Encode(nil)
+	response, handled, err = session.respond(initial, h)
+	if !handled || err != nil {
+		return response, err
+	}
+	serverFirst := &pgproto3.AuthenticationSASLContinue{}
+	if err := serverFirst.Decode(response[5:]); err != nil {
+		t.Fatalf("failed to decode the server-first-message %q: %v", response, err)
+	}
+
+	final := (&pgproto3.SASLResponse{Data: []byte(client.final(t, string(serverFirst.Data)))}).Encode(nil)
+	response, handled, err = session.respond(final, h)
+	if !handled {
+		t.Fatalf("left the client-final-message to the mock matching")
+	}
+	return response, err
+}
+
+func TestScramSession(t *testing.T) {
+	binding := bytes.Repeat([]byte{0xab}, sha256.Size)
+	for _, tt := range []struct {
+		name           string
+		channelBinding []byte
+		mechanism      string
+		client         scramClient
+		fails          bool
+	}{
+		{name: "scram", mechanism: scramSHA256, client: scramClient{password: "secret", gs2Header: "n,,"}},
+		{name: "scram of a client supporting channel binding", mechanism: scramSHA256, client: scramClient{password: "secret", gs2Header: "y,,"}},
+		{name: "wrong password", mechanism: scramSHA256, client: scramClient{password: "guess", gs2Header: "n,,"}, fails: true},
+		{name: "scram plus", channelBinding: binding, mechanism: scramSHA256Plus, client: scramClient{password: "secret", gs2Header: "p=tls-server-end-point,,", channelBinding: binding}},
+		{name: "scram plus of another connection", channelBinding: binding, mechanism: scramSHA256Plus, client: scramClient{password: "secret", gs2Header: "p=tls-server-end-point,,", channelBinding: make([]byte, sha256.Size)}, fails: true},
+		{name: "scram plus without tls", mechanism: scramSHA256Plus, client: scramClient{password: "secret", gs2Header: "p=tls-server-end-point,,", channelBinding: binding}, fails: true},
+		{name: "channel binding downgrade", channelBinding: binding, mechanism: scramSHA256, client: scramClient{password: "secret", gs2Header: "y,,"}, fails: true},
+	} {
+		tt.client.nonce = "fyko+d2lbbFgONRv9qkxdawL"
+		response, err := authenticate(t, newScramSession("secret", tt.channelBinding), &tt.client, tt.mechanism)
+		if tt.fails {
+			if err == nil || len(response) == 0 || response[0] != 'E' || !bytes.Contains(response, []byte("28P01")) {
+				t.Errorf("%s: got the response %q (%v), want the failed authentication", tt.name, response, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: failed to authenticate: %v", tt.name, err)
+		}
+		want := (&pgproto3.AuthenticationSASLFinal{Data: []byte(tt.client.serverFinal())}).Encode(nil)
+		want = (&pgproto3.AuthenticationOk{}).Encode(want)
+		want = (&pgproto3.ParameterStatus{Name: "server_version", Value: "15.4"}).Encode(want)
+		want = (&pgproto3.BackendKeyData{ProcessID: 42, SecretKey: 7}).Encode(want)
+		want = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(want)
+		if !bytes.Equal(response, want) {
+			t.Errorf("%s: got the response %q, want %q", tt.name, response, want)
+		}
+	}
+}
+
+func TestScramSessionLeavesOtherRecordingsToTheMocks(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	// the server of the recording authenticated the client with a password
+	h.SetConfigMocks([]*models.Mock{{
+		Name: "mock-0",
+		Kind: models.Postgres,
+		Spec: models.MockSpec{
+			PostgresRequests:  []models.Backend{{Identfier: "StartupRequest"}},
+			PostgresResponses: []models.Frontend{{PacketTypes: []string{"R"}, AuthType: AuthTypeMD5Password}},
+		},
+	}})
+	if _, handled, _ := newScramSession("secret", nil).respond(startupRequest(""), h); handled {
+		t.Errorf("answered the startup message of an md5 recording")
+	}
+	if _, handled, _ := newScramSession("secret", nil).respond(SimpleQueryRequest("SELECT 1")[0], scramHooks(t)); handled {
+		t.Errorf("answered a query before the startup message")
+	}
+}
+
+func TestStartupRequestsAuthentication(t *testing.T) {
+	startupAnswered := func(packetTypes ...string) *hooks.Hook {
+		h, err := hooks.NewHook(nil, 0, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create the hooks: %v", err)
+		}
+		h.SetConfigMocks([]*models.Mock{{
+			Name: "mock-0",
+			Kind: models.Postgres,
+			Spec: models.MockSpec{
+				PostgresRequests:  []models.Backend{{Identfier: "StartupRequest"}},
+				PostgresResponses: []models.Frontend{{PacketTypes: packetTypes}},
+			},
+		}})
+		return h
+	}
+	for _, tt := range []struct {
+		name string
+		h    *hooks.Hook
+		want bool
+	}{
+		{name: "scram", h: scramHooks(t), want: true},
+		{name: "password", h: startupAnswered("R"), want: true},
+		{name: "trusted", h: startupAnswered("R", "S", "K", "Z")},
+		{name: "no startup recorded", h: startupAnswered()},
+	} {
+		if got := startupRequestsAuthentication(tt.h); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAuthenticationSkippedOnReplay(t *testing.T) {
+	skipped := encodeMessages(
+		&pgproto3.AuthenticationOk{},
+		&pgproto3.ParameterStatus{Name: "server_version", Value: "15.4"},
+		&pgproto3.BackendKeyData{ProcessID: 42, SecretKey: 7},
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+	)
+	for _, tt := range []struct {
+		name string
+		opts PostgresOptions
+		want []byte
+	}{
+		{name: "skipped", opts: PostgresOptions{SkipAuthentication: true}, want: skipped},
+		{
+			// the exchange is computed when the password is known
+			name: "with the password",
+			opts: PostgresOptions{SkipAuthentication: true, Password: "secret"},
+			want: (&pgproto3.AuthenticationSASL{AuthMechanisms: []string{scramSHA256}}).Encode(nil),
+		},
+	} {
+		h := scramHooks(t)
+		p := NewPostgresParserWithOptions(zap.NewNop(), h, tt.opts)
+
+		client, proxied := net.Pipe()
+		done := make(chan error, 1)
+		go func() {
+			done <- decodePostgresOutgoing(startupRequest(""), proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+		}()
+
+		if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+			t.Fatalf("failed to set the read deadline: %v", err)
+		}
+		got := make([]byte, len(tt.want))
+		if _, err := io.ReadFull(client, got); err != nil {
+			t.Fatalf("%s: failed to read the answer to the startup message: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+		client.Close()
+		<-done
+	}
+}
@@ -0,0 +1,132 @@
+package postgresparser
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// searchPathMetadata is the metadata key of the search_path a mock was
+// recorded with.
+const searchPathMetadata = "search_path"
+
+var (
+	setSearchPathRe     = regexp.MustCompile(`(?i)^\s*set\s+(?:session\s+|local\s+)?search_path\s*(?:to|=)\s*(.+?)\s*;?\s*$`)
+	startupSearchPathRe = regexp.MustCompile(`(?i)search_path\s*=\s*(\S+)`)
+)
+
+// searchPathFromRequests returns the search_path set by the requests, either
+// in the options of the startup message or by a SET search_path query.
+func searchPathFromRequests(requestBuffers [][]byte) (string, bool) {
+	path, found := "", false
+	for _, buffer := range requestBuffers {
+		if startup := decodeStartupMessage(buffer); startup != nil {
+			if match := startupSearchPathRe.FindStringSubmatch(startup.Parameters["options"]); match != nil {
+				path, found = normalizeSearchPath(match[1]), true
+			}
+		}
+	}
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		var query string
+		switch msgType {
+		case 'Q':
+			q := &pgproto3.Query{}
+			if q.Decode(body) != nil {
+				return
+			}
+			query = q.String
+		case 'P':
+			p := &pgproto3.Parse{}
+			if p.Decode(body) != nil {
+				return
+			}
+			query = p.Query
+		default:
+			return
+		}
+		if match := setSearchPathRe.FindStringSubmatch(query); match != nil {
+			path, found = normalizeSearchPath(match[1]), true
+		}
+	})
+	return path, found
+}
+
+// normalizeSearchPath lower cases the unquoted schemas of a search_path and
+// drops the spaces around them.
+func normalizeSearchPath(path string) string {
+	schemas := strings.Split(path, ",")
+	for i, schema := range schemas {
+		schema = strings.TrimSpace(schema)
+		if strings.HasPrefix(schema, `"`) {
+			schema = unquoteIdentifier(schema)
+		} else {
+			schema = strings.ToLower(strings.Trim(schema, "'"))
+		}
+		schemas[i] = schema
+	}
+	return strings.Join(schemas, ",")
+}
+
+// connectionState is the state of a client connection in test mode which the
+// matching of its requests depends on.
+type connectionState struct {
+	// searchPath is the search_path in effect before the requests.
+	searchPath string
+	// startupOptions are the settings the connection was started with.
+	startupOptions string
+	// portalQuery is the query bound to the unnamed portal which the
+	// requests describe without binding it.
+	portalQuery string
+	// statements are the statements prepared before the requests.
+	statements *statementRegistry
+	// replays count the replays of the requests recorded several times, nil
+	// when the earliest of the mocks recorded for them is always replayed.
+	replays *mockOccurrences
+}
+
+// filterMocksByStatements leaves out the mocks which prepared the statements
+// bound by the requests with other queries than the connection did.
+func filterMocksByStatements(mocks []*models.Mock, requestBuffers [][]byte, statements *statementRegistry) []*models.Mock {
+	if statements == nil || len(statements.names) == 0 {
+		return mocks
+	}
+	filtered := make([]*models.Mock, 0, len(mocks))
+	for _, mock := range mocks {
+		if mock == nil {
+			continue
+		}
+		var recorded [][]byte
+		for _, request := range mock.Spec.PostgresRequests {
+			if buffer, err := BackendWireBytes(request); err == nil {
+				recorded = append(recorded, buffer)
+			}
+		}
+		if statements.conflicts(requestBuffers, recorded) {
+			continue
+		}
+		filtered = append(filtered, mock)
+	}
+	return filtered
+}
+
+// filterMocksBySearchPath leaves out the mocks recorded with a search_path
+// other than the active one. The mocks recorded without a known search_path
+// match in every schema.
+func filterMocksBySearchPath(mocks []*models.Mock, searchPath string) []*models.Mock {
+	if searchPath == "" {
+		return mocks
+	}
+	filtered := make([]*models.Mock, 0, len(mocks))
+	for _, mock := range mocks {
+		if mock == nil {
+			continue
+		}
+		if recorded := mock.Spec.Metadata[searchPathMetadata]; recorded != "" && recorded != searchPath {
+			continue
+		}
+		filtered = append(filtered, mock)
+	}
+	return filtered
+}
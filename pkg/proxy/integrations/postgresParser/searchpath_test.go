@@ -0,0 +1,99 @@
+package postgresparser
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestSearchPathFromRequests(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		requests [][]byte
+		want     string
+		found    bool
+	}{
+		{
+			name:     "startup options",
+			requests: [][]byte{startupRequest("-c search_path=Tenant_A,public")},
+			want:     "tenant_a,public",
+			found:    true,
+		},
+		{
+			name:     "simple query",
+			requests: SimpleQueryRequest(`SET search_path TO "Tenant_B", Public;`),
+			want:     "Tenant_B,public",
+			found:    true,
+		},
+		{
+			name:     "prepared query",
+			requests: [][]byte{encodeMessages(&pgproto3.Parse{Query: "set session search_path = 'audit'"}, &pgproto3.Sync{})},
+			want:     "audit",
+			found:    true,
+		},
+		{
+			name: "last one wins",
+			requests: [][]byte{
+				startupRequest("-c search_path=tenant_a"),
+				(&pgproto3.Query{String: "SET LOCAL search_path TO tenant_c"}).Encode(nil),
+			},
+			want:  "tenant_c",
+			found: true,
+		},
+		{
+			name:     "other setting",
+			requests: SimpleQueryRequest("SET statement_timeout TO 1000"),
+		},
+		{
+			name:     "startup without search_path",
+			requests: [][]byte{startupRequest("-c statement_timeout=1000")},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := searchPathFromRequests(tt.requests)
+			if got != tt.want || found != tt.found {
+				t.Errorf("got %q, %v, want %q, %v", got, found, tt.want, tt.found)
+			}
+		})
+	}
+}
+
+func TestFilterMocksBySearchPath(t *testing.T) {
+	tenantA := &models.Mock{Name: "tenant-a", Spec: models.MockSpec{Metadata: map[string]string{searchPathMetadata: "tenant_a"}}}
+	tenantB := &models.Mock{Name: "tenant-b", Spec: models.MockSpec{Metadata: map[string]string{searchPathMetadata: "tenant_b"}}}
+	unknown := &models.Mock{Name: "unknown"}
+	mocks := []*models.Mock{tenantA, nil, tenantB, unknown}
+
+	for _, tt := range []struct {
+		searchPath string
+		want       []*models.Mock
+	}{
+		{searchPath: "tenant_a", want: []*models.Mock{tenantA, unknown}},
+		{searchPath: "tenant_b", want: []*models.Mock{tenantB, unknown}},
+		{searchPath: "tenant_c", want: []*models.Mock{unknown}},
+		{searchPath: "", want: mocks},
+	} {
+		if got := filterMocksBySearchPath(mocks, tt.searchPath); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("got %d mocks for the search_path %q, want %d", len(got), tt.searchPath, len(tt.want))
+		}
+	}
+}
+
+func TestSearchPathRecorded(t *testing.T) {
+	set := (&pgproto3.CommandComplete{CommandTag: []byte("SET")}).Encode(nil)
+	set = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(set)
+	mocks := recordExchanges(t, PostgresOptions{},
+		pgExchange{request: (&pgproto3.Query{String: "SELECT id FROM events"}).Encode(nil), response: rowsResponse(1)},
+		pgExchange{request: (&pgproto3.Query{String: "SET search_path TO tenant_a"}).Encode(nil), response: set},
+		pgExchange{request: (&pgproto3.Query{String: "SELECT id FROM orders"}).Encode(nil), response: rowsResponse(2)},
+	)
+
+	if got := queryMock(t, mocks, "SELECT id FROM events").Spec.Metadata[searchPathMetadata]; got != "" {
+		t.Errorf("got the search_path %q before it was set", got)
+	}
+	if got := queryMock(t, mocks, "SELECT id FROM orders").Spec.Metadata[searchPathMetadata]; got != "tenant_a" {
+		t.Errorf("got the search_path %q, want %q", got, "tenant_a")
+	}
+}
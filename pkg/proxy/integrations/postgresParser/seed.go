@@ -0,0 +1,284 @@
+package postgresparser
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// SeedStatement inserts the rows returned by a recorded SELECT into its table.
+type SeedStatement struct {
+	// MockName is the mock the rows were recorded in.
+	MockName string
+	Table    string
+	// Columns are the columns of the table the rows were read from, the
+	// computed columns of the SELECT are left out.
+	Columns []SeedColumn
+	// Rows hold the values of the columns as SQL literals, which the server
+	// casts to the types of the columns.
+	Rows [][]string
+}
+
+// SeedColumn is a column of a seeded table, named as the SELECT returned it and
+// numbered as in the table.
+type SeedColumn struct {
+	Name      string
+	Attribute int16
+}
+
+// SQL returns the INSERT of the rows, which leaves out the rows already
+// present.
+func (s SeedStatement) SQL() string {
+	quoted := make([]string, len(s.Columns))
+	for i, column := range s.Columns {
+		quoted[i] = quoteIdentifier(column.Name)
+	}
+	values := make([]string, len(s.Rows))
+	for i, row := range s.Rows {
+		values[i] = "(" + strings.Join(row, ", ") + ")"
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s ON CONFLICT DO NOTHING", s.Table, strings.Join(quoted, ", "), strings.Join(values, ", "))
+}
+
+// seedSelectRe matches the SELECTs reading a single table, whose rows can be
+// inserted back into it.
+var seedSelectRe = regexp.MustCompile(`(?is)^\s*select\s.+?\sfrom\s+((?:"[^"]+"|[a-z_][\w$]*)(?:\.(?:"[^"]+"|[a-z_][\w$]*))?)(\s*,|\s+(?:[a-z_]\w*\s+)?(?:(?:natural|inner|left|right|full|cross)\s+)*join\b)?`)
+
+// SeedStatementsFromTestSet returns the INSERTs recreating the rows read by the
+// SELECTs recorded in the postgres mocks of the test-set, see SeedStatements.
+func SeedStatementsFromTestSet(db platform.TestCaseDB, testSet string, logger *zap.Logger) ([]SeedStatement, error) {
+	read, err := db.ReadConfigMocks(testSet)
+	if err != nil {
+		return nil, err
+	}
+	mocks := make([]*models.Mock, 0, len(read))
+	for _, kind := range read {
+		if mock, ok := kind.(*models.Mock); ok && mock.Kind == models.Postgres {
+			mocks = append(mocks, mock)
+		}
+	}
+	return SeedStatements(filterDisabledMocks(mocks), logger), nil
+}
+
+// SeedStatements returns the INSERTs recreating the rows read by the SELECTs
+// recorded in the mocks, so that a real database can be seeded from a
+// recording. Only the SELECTs of a single table returning its columns in the
+// text format are used, the joins and the catalog queries are left out, as are
+// the computed columns. The rows read more than once through the same columns
+// are inserted once.
+func SeedStatements(mocks []*models.Mock, logger *zap.Logger) []SeedStatement {
+	statements := []SeedStatement{}
+	seen := map[string]bool{}
+	for _, mock := range mocks {
+		if mock == nil || mock.Kind != models.Postgres {
+			continue
+		}
+		query, ok := seedQuery(mock.Spec.PostgresRequests)
+		if !ok {
+			continue
+		}
+		match := seedSelectRe.FindStringSubmatch(query)
+		if match == nil || match[2] != "" || isCatalogQuery(query) {
+			continue
+		}
+		table := match[1]
+		columns, rows, err := recordedRows(mock.Spec.PostgresResponses)
+		if err != nil {
+			logger.Debug("skipped seeding the rows of the mock", zap.String("mock", mock.Name), zap.Error(err))
+			continue
+		}
+		key := table + seedColumnsKey(columns)
+		literals := [][]string{}
+		for _, row := range rows {
+			tuple := seedLiterals(row)
+			rowKey := key + strings.Join(tuple, ",")
+			if seen[rowKey] {
+				continue
+			}
+			seen[rowKey] = true
+			literals = append(literals, tuple)
+		}
+		if len(literals) == 0 {
+			continue
+		}
+		statements = append(statements, SeedStatement{
+			MockName: mock.Name,
+			Table:    table,
+			Columns:  columns,
+			Rows:     literals,
+		})
+	}
+	return statements
+}
+
+// SeedDatabase runs the statements on the database in a single transaction.
+// The columns are named after the attributes of the tables in the database, as
+// the SELECTs may have renamed them. The database is opened by the caller with
+// the postgres driver of its choice.
+func SeedDatabase(ctx context.Context, db *sql.DB, statements []SeedStatement) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		err = resolveSeedColumns(ctx, tx, &statement)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to resolve the columns of %s recorded in %s: %v", statement.Table, statement.MockName, err)
+		}
+		_, err = tx.ExecContext(ctx, statement.SQL())
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to seed the rows of %s recorded in %s: %v", statement.Table, statement.MockName, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// resolveSeedColumns names the columns of the statement after the attributes
+// of its table.
+func resolveSeedColumns(ctx context.Context, tx *sql.Tx, statement *SeedStatement) error {
+	columns := make([]SeedColumn, len(statement.Columns))
+	for i, column := range statement.Columns {
+		var name string
+		err := tx.QueryRowContext(ctx, "SELECT attname FROM pg_attribute WHERE attrelid = $1::regclass AND attnum = $2 AND NOT attisdropped", statement.Table, column.Attribute).Scan(&name)
+		if err != nil {
+			return fmt.Errorf("the attribute %d of the column %s: %v", column.Attribute, column.Name, err)
+		}
+		columns[i] = SeedColumn{Name: name, Attribute: column.Attribute}
+	}
+	statement.Columns = columns
+	return nil
+}
+
+// seedQuery returns the only statement of the requests.
+func seedQuery(requests []models.Backend) (string, bool) {
+	queries := []string{}
+	for _, request := range requests {
+		if request.Query.String != "" {
+			queries = append(queries, request.Query.String)
+		}
+		for _, parse := range request.Parses {
+			if parse.Query != "" {
+				queries = append(queries, parse.Query)
+			}
+		}
+	}
+	if len(queries) != 1 || strings.Contains(strings.TrimRight(strings.TrimSpace(queries[0]), ";"), ";") {
+		return "", false
+	}
+	return queries[0], true
+}
+
+// recordedRows returns the columns of the table and the rows of the single
+// result of the responses. The columns are resolved by the table and the
+// attribute the server described them with, the computed columns, the binary
+// ones, the repeated ones and those of another table are left out.
+func recordedRows(responses []models.Frontend) ([]SeedColumn, [][][]byte, error) {
+	var columns []SeedColumn
+	// kept holds the positions of the columns in the rows
+	var kept []int
+	rows := [][][]byte{}
+	for _, response := range responses {
+		buffer, err := FrontendWireBytes(response)
+		if err != nil {
+			return nil, nil, err
+		}
+		msgs, ok := splitMessages(buffer)
+		if !ok {
+			return nil, nil, fmt.Errorf("the response does not hold whole messages")
+		}
+		for _, msg := range msgs {
+			switch msg[0] {
+			case 'T':
+				if columns != nil {
+					return nil, nil, fmt.Errorf("the responses hold more than one result")
+				}
+				description := &pgproto3.RowDescription{}
+				if err := description.Decode(msg[5:]); err != nil {
+					return nil, nil, err
+				}
+				columns = []SeedColumn{}
+				var table uint32
+				attributes := map[int16]bool{}
+				for i, field := range description.Fields {
+					attribute := int16(field.TableAttributeNumber)
+					if field.TableOID == 0 || attribute <= 0 || field.Format != 0 || attributes[attribute] {
+						continue
+					}
+					if table == 0 {
+						table = field.TableOID
+					}
+					if field.TableOID != table {
+						continue
+					}
+					attributes[attribute] = true
+					columns = append(columns, SeedColumn{Name: string(field.Name), Attribute: attribute})
+					kept = append(kept, i)
+				}
+				if len(columns) == 0 {
+					return nil, nil, fmt.Errorf("the result holds no column of the table")
+				}
+			case 'D':
+				if columns == nil {
+					return nil, nil, fmt.Errorf("the rows are not described")
+				}
+				row := &pgproto3.DataRow{}
+				if err := row.Decode(msg[5:]); err != nil {
+					return nil, nil, err
+				}
+				values := make([][]byte, len(kept))
+				for i, position := range kept {
+					if position >= len(row.Values) {
+						return nil, nil, fmt.Errorf("the row holds fewer values than the columns described")
+					}
+					if row.Values[position] != nil {
+						values[i] = append([]byte{}, row.Values[position]...)
+					}
+				}
+				rows = append(rows, values)
+			}
+		}
+	}
+	if columns == nil {
+		return nil, nil, fmt.Errorf("the responses hold no result")
+	}
+	return columns, rows, nil
+}
+
+// seedColumnsKey identifies the columns the rows of a table are read with, so
+// that the same values read through other columns aren't taken for the same
+// row.
+func seedColumnsKey(columns []SeedColumn) string {
+	attributes := make([]string, len(columns))
+	for i, column := range columns {
+		attributes[i] = strconv.Itoa(int(column.Attribute))
+	}
+	return "(" + strings.Join(attributes, ",") + ")"
+}
+
+// seedLiterals returns the values of the row as SQL literals, which the server
+// casts to the types of the columns.
+func seedLiterals(row [][]byte) []string {
+	literals := make([]string, len(row))
+	for i, value := range row {
+		if value == nil {
+			literals[i] = "NULL"
+			continue
+		}
+		literals[i] = "'" + strings.ReplaceAll(string(value), "'", "''") + "'"
+	}
+	return literals
+}
+
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
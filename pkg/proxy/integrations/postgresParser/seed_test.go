@@ -0,0 +1,95 @@
+package postgresparser
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// usersFields describe the id and the renamed name of the users table, then
+// a computed column.
+var usersFields = []pgproto3.FieldDescription{
+	{Name: []byte("id"), TableOID: 16384, TableAttributeNumber: 1, DataTypeOID: 23, DataTypeSize: 4, TypeModifier: -1},
+	{Name: []byte("label"), TableOID: 16384, TableAttributeNumber: 2, DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1},
+	{Name: []byte("upper"), DataTypeOID: 25, DataTypeSize: -1, TypeModifier: -1},
+}
+
+// seedMock returns the mock of the query recorded with the rows.
+func seedMock(name string, request models.Backend, fields []pgproto3.FieldDescription, rows ...[][]byte) *models.Mock {
+	response := (&pgproto3.RowDescription{Fields: fields}).Encode(nil)
+	for _, row := range rows {
+		response = append(response, encodeDataRow(row)...)
+	}
+	response = (&pgproto3.CommandComplete{CommandTag: []byte("SELECT")}).Encode(response)
+	response = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(response)
+	return &models.Mock{
+		Name: name,
+		Kind: models.Postgres,
+		Spec: models.MockSpec{
+			PostgresRequests:  []models.Backend{request},
+			PostgresResponses: []models.Frontend{{Payload: base64.StdEncoding.EncodeToString(response)}},
+		},
+	}
+}
+
+func queryRequest(query string) models.Backend {
+	return models.Backend{Query: pgproto3.Query{String: query}}
+}
+
+func TestSeedStatements(t *testing.T) {
+	users := []pgproto3.FieldDescription{usersFields[0], usersFields[1]}
+	binary := []pgproto3.FieldDescription{usersFields[0]}
+	binary[0].Format = 1
+	mocks := []*models.Mock{
+		seedMock("mock-0", queryRequest("SELECT id, name AS label, upper(name) FROM users WHERE id < 3"), usersFields,
+			[][]byte{[]byte("1"), []byte("O'Brien"), []byte("O'BRIEN")},
+			[][]byte{[]byte("2"), nil, nil}),
+		// the same row read again is inserted once
+		seedMock("mock-1", models.Backend{Parses: []pgproto3.Parse{{Query: "SELECT id, name FROM users WHERE id = $1"}}}, users,
+			[][]byte{[]byte("1"), []byte("O'Brien")},
+			[][]byte{[]byte("3"), []byte("Ada")}),
+		seedMock("mock-2", queryRequest("SELECT id, name FROM users WHERE id = 1"), users, [][]byte{[]byte("1"), []byte("O'Brien")}),
+		seedMock("mock-3", queryRequest("SELECT u.id, u.name FROM users u JOIN orders o ON o.user_id = u.id"), users, [][]byte{[]byte("4"), []byte("Bob")}),
+		seedMock("mock-4", queryRequest("SELECT id, name FROM users, orders"), users, [][]byte{[]byte("4"), []byte("Bob")}),
+		seedMock("mock-5", queryRequest("SELECT oid, typname FROM pg_type"), users, [][]byte{[]byte("23"), []byte("int4")}),
+		seedMock("mock-6", queryRequest("SELECT id FROM users; SELECT id FROM orders"), users, [][]byte{[]byte("5"), []byte("Eve")}),
+		seedMock("mock-7", queryRequest("SELECT id FROM users"), binary, [][]byte{{0, 0, 0, 6}}),
+		seedMock("mock-8", queryRequest("SELECT upper(name) FROM users"), usersFields[2:], [][]byte{[]byte("ADA")}),
+		{Name: "mock-9", Kind: models.HTTP},
+	}
+
+	got := SeedStatements(mocks, zap.NewNop())
+	columns := []SeedColumn{{Name: "id", Attribute: 1}, {Name: "label", Attribute: 2}}
+	want := []SeedStatement{
+		{MockName: "mock-0", Table: "users", Columns: columns, Rows: [][]string{{"'1'", "'O''Brien'"}, {"'2'", "NULL"}}},
+		{MockName: "mock-1", Table: "users", Columns: columns, Rows: [][]string{{"'3'", "'Ada'"}}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got the statements %+v, want %+v", got, want)
+	}
+	wantSQL := `INSERT INTO users ("id", "label") VALUES ('1', 'O''Brien'), ('2', NULL) ON CONFLICT DO NOTHING`
+	if sql := got[0].SQL(); sql != wantSQL {
+		t.Errorf("got the SQL %q, want %q", sql, wantSQL)
+	}
+}
+
+func TestSeedStatementsFromTestSet(t *testing.T) {
+	disabled := seedMock("mock-1", queryRequest(`SELECT id FROM "public"."Users"`), usersFields[:1], [][]byte{[]byte("2")})
+	disabled.Spec.Disabled = true
+	db := &configDB{mocks: map[string][]*models.Mock{"test-set-0": {
+		seedMock("mock-0", queryRequest(`SELECT id FROM "public"."Users"`), usersFields[:1], [][]byte{[]byte("1")}),
+		disabled,
+		{Name: "mock-2", Kind: models.HTTP},
+	}}}
+	got, err := SeedStatementsFromTestSet(db, "test-set-0", zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to read the seed statements: %v", err)
+	}
+	if len(got) != 1 || got[0].Table != `"public"."Users"` || !reflect.DeepEqual(got[0].Rows, [][]string{{"'1'"}}) {
+		t.Errorf("got the statements %+v, want the row of the enabled mock", got)
+	}
+}
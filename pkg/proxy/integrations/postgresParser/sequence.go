@@ -0,0 +1,106 @@
+package postgresparser
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"sync"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// occurrenceMetadata is the metadata key numbering the mocks recorded for the
+// same requests, so that the responses of a polled query are replayed in the
+// order they were recorded.
+const occurrenceMetadata = "occurrence"
+
+// mockOccurrences counts the mocks recorded so far for each request during a
+// recording session, or the requests replayed during a test-set, told apart
+// by the test-set.
+type mockOccurrences struct {
+	mutex   sync.Mutex
+	session string
+	counts  map[string]int
+}
+
+func newMockOccurrences() *mockOccurrences {
+	return &mockOccurrences{counts: make(map[string]int)}
+}
+
+// requestsKey identifies the requests of an exchange, empty when they can't
+// be encoded.
+func requestsKey(requests []models.Backend) string {
+	hash := sha256.New()
+	for _, request := range requests {
+		buffer, err := BackendWireBytes(request)
+		if err != nil {
+			return ""
+		}
+		hash.Write(buffer)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// next returns the position of the requests, identified by their key, among
+// the identical requests recorded so far in the session, starting at 1.
+func (o *mockOccurrences) next(session, requests string) int {
+	if requests == "" {
+		return 1
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if session != o.session {
+		o.session = session
+		o.counts = make(map[string]int)
+	}
+	o.counts[requests]++
+	return o.counts[requests]
+}
+
+// reset forgets the recorded requests, for a new recording session.
+func (o *mockOccurrences) reset() {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.counts = make(map[string]int)
+}
+
+// occurrenceOf returns the position of the mock among the mocks recorded for
+// the same requests. The mocks recorded without it come first.
+func occurrenceOf(mock *models.Mock) int {
+	occurrence, err := strconv.Atoi(mock.Spec.Metadata[occurrenceMetadata])
+	if err != nil {
+		return 1
+	}
+	return occurrence
+}
+
+// findSequencedMatch returns the index of the mock recorded with exactly the
+// same requests. When the requests were recorded several times, replayed
+// numbers their replay, from 1, and the latest occurrence up to it is
+// returned. The duplicates left out of the recording are replayed with the
+// occurrence recorded before them, and the last occurrence once all of them
+// are replayed so that the final state keeps being replayed.
+func findSequencedMatch(tcsMocks []*models.Mock, requestBuffers [][]byte, noise requestNoise, mode MatchMode, replayed func(requests string) int) int {
+	candidates := findExactMatches(tcsMocks, requestBuffers, noise, mode)
+	if len(candidates) == 0 {
+		return -1
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return occurrenceOf(tcsMocks[candidates[i]]) < occurrenceOf(tcsMocks[candidates[j]])
+	})
+	replay := replayed(requestsKey(tcsMocks[candidates[0]].Spec.PostgresRequests))
+	match := candidates[0]
+	for _, idx := range candidates[1:] {
+		occurrence := occurrenceOf(tcsMocks[idx])
+		if occurrence > replay {
+			break
+		}
+		// the earliest of the mocks of the same occurrence is kept
+		if occurrence > occurrenceOf(tcsMocks[match]) {
+			match = idx
+		}
+	}
+	return match
+}
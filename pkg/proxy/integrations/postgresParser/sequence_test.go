@@ -0,0 +1,104 @@
+package postgresparser
+
+import (
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestMockOccurrences(t *testing.T) {
+	occurrences := newMockOccurrences()
+	polled := requestsKey(simpleQuery("SELECT status FROM jobs WHERE id = 1"))
+	other := requestsKey(simpleQuery("SELECT status FROM jobs WHERE id = 2"))
+
+	for _, tt := range []struct {
+		session, requests string
+		want              int
+	}{
+		{session: "test-set-0", requests: polled, want: 1},
+		{session: "test-set-0", requests: polled, want: 2},
+		{session: "test-set-0", requests: other, want: 1},
+		{session: "test-set-0", requests: polled, want: 3},
+		// a new test-set numbers its mocks afresh
+		{session: "test-set-1", requests: polled, want: 1},
+		{session: "test-set-1", requests: "", want: 1},
+		{session: "test-set-1", requests: "", want: 1},
+	} {
+		if got := occurrences.next(tt.session, tt.requests); got != tt.want {
+			t.Errorf("got the occurrence %d in %s, want %d", got, tt.session, tt.want)
+		}
+	}
+	occurrences.reset()
+	if got := occurrences.next("test-set-1", polled); got != 1 {
+		t.Errorf("got the occurrence %d after the reset, want 1", got)
+	}
+}
+
+func TestFindSequencedMatch(t *testing.T) {
+	request := SimpleQueryRequest("SELECT status FROM jobs WHERE id = 1")
+	sequenced := func(name, occurrence string) *models.Mock {
+		mock := recordedMock(name, request[0])
+		if occurrence != "" {
+			mock.Spec.Metadata = map[string]string{occurrenceMetadata: occurrence}
+		}
+		return mock
+	}
+	// the mocks are stored out of the order they were recorded in
+	mocks := []*models.Mock{
+		recordedMock("other", SimpleQueryRequest("SELECT 1")[0]),
+		sequenced("done", "3"),
+		sequenced("pending", ""),
+		sequenced("running", "2"),
+	}
+
+	for _, tt := range []struct {
+		replay int
+		want   string
+	}{
+		{replay: 1, want: "pending"},
+		{replay: 2, want: "running"},
+		{replay: 3, want: "done"},
+		// the final state keeps being replayed
+		{replay: 4, want: "done"},
+	} {
+		idx := findSequencedMatch(mocks, request, requestNoise{}, MatchExact, func(string) int { return tt.replay })
+		if idx == -1 || mocks[idx].Name != tt.want {
+			t.Errorf("got the mock %d on the replay %d, want %s", idx, tt.replay, tt.want)
+		}
+	}
+	if idx := findSequencedMatch(mocks, SimpleQueryRequest("SELECT 2"), requestNoise{}, MatchExact, func(string) int { return 1 }); idx != -1 {
+		t.Errorf("got the mock %d of an unrecorded query", idx)
+	}
+
+	// the duplicates left out of the recording leave gaps in the occurrences,
+	// filled by the occurrence recorded before them
+	mocks = []*models.Mock{sequenced("done", "3"), sequenced("pending", "")}
+	for replay, want := range []string{"pending", "pending", "done", "done"} {
+		idx := findSequencedMatch(mocks, request, requestNoise{}, MatchExact, func(string) int { return replay + 1 })
+		if idx == -1 || mocks[idx].Name != want {
+			t.Errorf("got the mock %d on the replay %d of the deduplicated mocks, want %s", idx, replay+1, want)
+		}
+	}
+}
+
+func TestOccurrencesRecorded(t *testing.T) {
+	polled := (&pgproto3.Query{String: "SELECT id FROM events"}).Encode(nil)
+	mocks := recordExchanges(t, PostgresOptions{},
+		pgExchange{request: polled, response: rowsResponse(1)},
+		pgExchange{request: polled, response: rowsResponse(2)},
+		pgExchange{request: polled, response: rowsResponse(3)},
+	)
+
+	var occurrences []string
+	for _, mock := range mocks {
+		for _, request := range mock.Spec.PostgresRequests {
+			if request.Query.String == "SELECT id FROM events" {
+				occurrences = append(occurrences, mock.Spec.Metadata[occurrenceMetadata])
+			}
+		}
+	}
+	if len(occurrences) != 3 || occurrences[0] != "" || occurrences[1] != "2" || occurrences[2] != "3" {
+		t.Errorf("got the occurrences %q, want none, 2 and 3", occurrences)
+	}
+}
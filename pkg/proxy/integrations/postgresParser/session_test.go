@@ -0,0 +1,49 @@
+package postgresparser
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// recordedQueries returns the simple queries of the recorded mocks, in order.
+func recordedQueries(mocks []*models.Mock) []string {
+	queries := []string{}
+	for _, mock := range mocks {
+		for _, request := range mock.Spec.PostgresRequests {
+			if request.Query.String != "" {
+				queries = append(queries, request.Query.String)
+			}
+		}
+	}
+	return queries
+}
+
+// TestMaxSessionDuration records a session whose second query is sent once
+// the maximum duration of the recording elapsed.
+func TestMaxSessionDuration(t *testing.T) {
+	exchange := func(query string, pause time.Duration) pgExchange {
+		response := (&pgproto3.CommandComplete{CommandTag: []byte("UPDATE 1")}).Encode(nil)
+		response = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(response)
+		return pgExchange{request: (&pgproto3.Query{String: query}).Encode(nil), response: response, pause: pause}
+	}
+	first, second := "UPDATE users SET seen = true WHERE id = 1", "UPDATE users SET seen = true WHERE id = 2"
+
+	for _, tt := range []struct {
+		name string
+		opts PostgresOptions
+		want []string
+	}{
+		{name: "unbounded", opts: PostgresOptions{}, want: []string{first, second}},
+		{name: "flushed then passed through", opts: PostgresOptions{MaxSessionDuration: 200 * time.Millisecond}, want: []string{first}},
+		{name: "flushed then continued", opts: PostgresOptions{MaxSessionDuration: 200 * time.Millisecond, ContinueSession: true}, want: []string{first, second}},
+	} {
+		mocks := recordExchanges(t, tt.opts, exchange(first, 0), exchange(second, 600*time.Millisecond))
+		if got := recordedQueries(mocks); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: recorded the queries %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package postgresparser
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.uber.org/zap"
+)
+
+func TestReadBuffConnStopsOnceDone(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	client, proxied := net.Pipe()
+	defer client.Close()
+	defer proxied.Close()
+
+	done := make(chan struct{})
+	stopped := make(chan error, 1)
+	go func() {
+		// nobody drains the channels once the exchange is over
+		stopped <- ReadBuffConn(proxied, make(chan []byte), make(chan error), done, zap.NewNop(), h)
+	}()
+	go client.Write(SimpleQueryRequest("SELECT 1")[0])
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			t.Fatalf("got the error %v, want none", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the reader outlived the exchange")
+	}
+}
+
+func TestRecordingEndsWhenTheServerCloses(t *testing.T) {
+	h, err := hooks.NewHook(&mockDB{}, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+
+	startup := startupRequest("")
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	defer app.Close()
+	go func() {
+		io.ReadFull(server, make([]byte, len(startup)))
+		server.Write(encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'}))
+		// the server goes away while the client idles
+		server.Close()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		encodePostgresOutgoing(startup, clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+		close(done)
+	}()
+	go io.Copy(io.Discard, app)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the recording outlived the server")
+	}
+	// the client connection is closed along with the server one
+	if _, err := app.Write(SimpleQueryRequest("SELECT 1")[0]); err == nil {
+		t.Errorf("the client connection is still open")
+	}
+}
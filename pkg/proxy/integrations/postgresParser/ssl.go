@@ -0,0 +1,206 @@
+package postgresparser
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.uber.org/zap"
+)
+
+// sslRequestCode is the protocol version sent by the client in an SSLRequest.
+const sslRequestCode = 80877103
+
+func isSSLRequest(buffer []byte) bool {
+	return len(buffer) == 8 && binary.BigEndian.Uint32(buffer[4:8]) == sslRequestCode
+}
+
+// errSSLProbe reports a client which closed the connection once the SSLRequest
+// was answered, as the health checkers probing the SSL support of the server
+// do.
+var errSSLProbe = errors.New("the client closed the connection after the SSLRequest")
+
+// errSSLEncrypted reports a session the server accepted SSL for, which can't be
+// read without the key material to decrypt it.
+var errSSLEncrypted = errors.New("the server accepted the SSLRequest of the client")
+
+// negotiateSSLSession forwards the SSLRequest to the server and relays its
+// answer to the client. When the server accepts SSL, both connections are
+// upgraded using the provided key material so that the remaining messages can
+// be read in plaintext, or errSSLEncrypted is returned along with the answer
+// when cfg is nil. It returns the connections to use further along with the
+// answer of the server and the startup message sent by the client after the
+// negotiation, or errSSLProbe along with the answer when no startup message
+// follows.
+func negotiateSSLSession(requestBuffer []byte, clientConn, destConn net.Conn, cfg *util.TLSDecryptConfig, logger *zap.Logger) (net.Conn, net.Conn, []byte, []byte, error) {
+	_, err := destConn.Write(requestBuffer)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to write the SSLRequest to the destination server: %v", err)
+	}
+	resp, err := util.ReadRequiredBytes(destConn, 1)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to read the SSLRequest response from the destination server: %v", err)
+	}
+	_, err = clientConn.Write(resp)
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to write the SSLRequest response to the client: %v", err)
+	}
+
+	if resp[0] == 'S' && cfg == nil {
+		return clientConn, destConn, resp, nil, errSSLEncrypted
+	}
+	if resp[0] == 'S' {
+		upgradedClient, upgradedDest, err := util.UpgradeToTLS(clientConn, destConn, cfg)
+		if errors.Is(err, io.EOF) {
+			return clientConn, destConn, resp, nil, errSSLProbe
+		}
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+		clientConn, destConn = upgradedClient, upgradedDest
+		logger.Debug("decrypting the SSL session of the postgres client")
+	}
+
+	startup, err := util.ReadBytes(clientConn)
+	if errors.Is(err, io.EOF) && len(startup) == 0 {
+		return clientConn, destConn, resp, nil, errSSLProbe
+	}
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("failed to read the startup message from the client: %v", err)
+	}
+	return clientConn, destConn, resp, startup, nil
+}
+
+// relay copies the bytes of each connection to the other one until either is
+// closed, for the sessions which can't be recorded.
+func relay(clientConn, destConn net.Conn) error {
+	errs := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(destConn, clientConn)
+		errs <- err
+	}()
+	go func() {
+		_, err := io.Copy(clientConn, destConn)
+		errs <- err
+	}()
+	err := <-errs
+	clientConn.Close()
+	destConn.Close()
+	return err
+}
+
+// acceptSSLSession answers the SSLRequest of the client with 'S' and performs
+// the TLS handshake with it, so that the sessions recorded decrypted are
+// replayed to the clients requiring SSL. Along with the connection it returns
+// the channel binding data of the certificate served in the handshake, or
+// errSSLProbe when the client closes the connection instead.
+func acceptSSLSession(clientConn net.Conn, cfg *util.TLSDecryptConfig) (net.Conn, []byte, error) {
+	_, err := clientConn.Write([]byte{'S'})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to accept the SSLRequest of the client: %v", err)
+	}
+	var served *tls.Certificate
+	serverConfig := cfg.Server.Clone()
+	serverConfig.GetCertificate = func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, err := selectCertificate(cfg.Server, hello)
+		if err == nil {
+			served = cert
+		}
+		return cert, err
+	}
+	tlsConn := tls.Server(clientConn, serverConfig)
+	err = tlsConn.Handshake()
+	if errors.Is(err, io.EOF) {
+		return nil, nil, errSSLProbe
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to complete the tls handshake with the client: %v", err)
+	}
+	return tlsConn, channelBindingData(served), nil
+}
+
+// selectCertificate picks the certificate the server config would serve for
+// the hello, in the order crypto/tls does.
+func selectCertificate(config *tls.Config, hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if config.GetCertificate != nil {
+		cert, err := config.GetCertificate(hello)
+		if cert != nil || err != nil {
+			return cert, err
+		}
+	}
+	if len(config.Certificates) == 0 {
+		return nil, errors.New("no certificate configured to serve the client")
+	}
+	if len(config.Certificates) > 1 {
+		for i := range config.Certificates {
+			if hello.SupportsCertificate(&config.Certificates[i]) == nil {
+				return &config.Certificates[i], nil
+			}
+		}
+	}
+	return &config.Certificates[0], nil
+}
+
+// recordedSSLAnswer returns the answer recorded to the SSLRequest of the
+// client, 'S' when no negotiation was recorded.
+func recordedSSLAnswer(h *hooks.Hook, sslRequest []byte) byte {
+	mocks, err := h.GetConfigMocks()
+	if err != nil {
+		return 'S'
+	}
+	for _, mock := range mocks {
+		if mock.Kind != models.Postgres || len(mock.Spec.PostgresRequests) != 1 || len(mock.Spec.PostgresResponses) != 1 {
+			continue
+		}
+		request, err := base64.StdEncoding.DecodeString(mock.Spec.PostgresRequests[0].Payload)
+		if err != nil || !bytes.Equal(request, sslRequest) {
+			continue
+		}
+		answer, err := base64.StdEncoding.DecodeString(mock.Spec.PostgresResponses[0].Payload)
+		if err == nil && len(answer) == 1 {
+			return answer[0]
+		}
+	}
+	return 'S'
+}
+
+func isGSSEncRequest(buffer []byte) bool {
+	return len(buffer) == 8 && binary.BigEndian.Uint32(buffer[4:8]) == gssEncReqNumber
+}
+
+// negotiateGSSEncryption forwards the GSSENCRequest to the server and relays
+// its single byte answer to the client. The servers decline it with 'N' unless
+// they are set up for GSSAPI, after which the client goes on with an
+// SSLRequest or a startup message on the same connection. That next request
+// is returned along with the answer.
+func negotiateGSSEncryption(requestBuffer []byte, clientConn, destConn net.Conn) ([]byte, []byte, error) {
+	_, err := destConn.Write(requestBuffer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to write the GSSENCRequest to the destination server: %v", err)
+	}
+	answer, err := util.ReadRequiredBytes(destConn, 1)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the GSSENCRequest response from the destination server: %v", err)
+	}
+	_, err = clientConn.Write(answer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to write the GSSENCRequest response to the client: %v", err)
+	}
+	if answer[0] != 'N' {
+		return nil, nil, fmt.Errorf("the server accepted the GSSAPI encryption, whose messages cannot be recorded; disable gssencmode in the client")
+	}
+
+	next, err := util.ReadBytes(clientConn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read the request following the declined GSSENCRequest: %v", err)
+	}
+	return answer, next, nil
+}
@@ -0,0 +1,285 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.uber.org/zap"
+)
+
+// TestSSLProbeRecorded records the SSLRequest of a client which closes the
+// connection once answered, whatever the answer of the server.
+func TestSSLProbeRecorded(t *testing.T) {
+	sslRequest := (&pgproto3.SSLRequest{}).Encode(nil)
+	for _, tt := range []struct {
+		name   string
+		answer byte
+	}{
+		{name: "declined", answer: 'N'},
+		{name: "accepted", answer: 'S'},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDB{}
+			h, err := hooks.NewHook(db, 0, zap.NewNop())
+			if err != nil {
+				t.Fatalf("failed to create the hooks: %v", err)
+			}
+			// the probe ends before any handshake, which needs no keys
+			p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{
+				TLSDecryption: &util.TLSDecryptConfig{Server: &tls.Config{}, Client: &tls.Config{}},
+			})
+
+			app, clientConn := net.Pipe()
+			destConn, server := net.Pipe()
+			defer server.Close()
+			go func() {
+				if _, err := io.ReadFull(server, make([]byte, len(sslRequest))); err != nil {
+					return
+				}
+				server.Write([]byte{tt.answer})
+			}()
+			go func() {
+				defer app.Close()
+				io.ReadFull(app, make([]byte, 1))
+			}()
+
+			done := make(chan error, 1)
+			go func() {
+				done <- encodePostgresOutgoing(sslRequest, clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+			}()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("got the error %v, want none", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatalf("the recording of the probe did not end")
+			}
+
+			if len(db.mocks) != 1 {
+				t.Fatalf("recorded %d mocks, want the probe only", len(db.mocks))
+			}
+			probe := db.mocks[0].Spec
+			if len(probe.PostgresRequests) != 1 || probe.PostgresRequests[0].Payload != base64.StdEncoding.EncodeToString(sslRequest) {
+				t.Errorf("recorded the requests %+v, want the SSLRequest", probe.PostgresRequests)
+			}
+			if len(probe.PostgresResponses) != 1 || probe.PostgresResponses[0].Payload != base64.StdEncoding.EncodeToString([]byte{tt.answer}) {
+				t.Errorf("recorded the responses %+v, want the answer %q", probe.PostgresResponses, tt.answer)
+			}
+		})
+	}
+}
+
+func TestSSLProbeReplayed(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{
+		TLSDecryption: &util.TLSDecryptConfig{Server: &tls.Config{}, Client: &tls.Config{}},
+	})
+
+	client, proxied := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- decodePostgresOutgoing((&pgproto3.SSLRequest{}).Encode(nil), proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+	}()
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set the read deadline: %v", err)
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(client, answer); err != nil {
+		t.Fatalf("failed to read the answer to the SSLRequest: %v", err)
+	}
+	if !bytes.Equal(answer, []byte{'S'}) {
+		t.Errorf("answered %q, want the SSL support", answer)
+	}
+	// the client leaves without the handshake
+	client.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("got the error %v, want none", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the replay outlived the probe")
+	}
+}
+
+// selfSignedCertificate returns a certificate of the host signed by its own
+// key.
+func selfSignedCertificate(t *testing.T, host string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create the certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// TestAcceptSSLSessionBindsTheServedCertificate serves the certificate issued
+// for the host the client asks for, as the CA of keploy does, and binds the
+// SCRAM channel to that certificate.
+func TestAcceptSSLSessionBindsTheServedCertificate(t *testing.T) {
+	certificates := map[string]tls.Certificate{
+		"localhost": selfSignedCertificate(t, "localhost"),
+		"db":        selfSignedCertificate(t, "db"),
+	}
+	cfg := &util.TLSDecryptConfig{Server: &tls.Config{
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert := certificates[hello.ServerName]
+			return &cert, nil
+		},
+	}}
+
+	app, clientConn := net.Pipe()
+	defer app.Close()
+	served := make(chan *x509.Certificate, 1)
+	go func() {
+		if _, err := io.ReadFull(app, make([]byte, 1)); err != nil {
+			served <- nil
+			return
+		}
+		conn := tls.Client(app, &tls.Config{ServerName: "db", InsecureSkipVerify: true})
+		if err := conn.Handshake(); err != nil {
+			served <- nil
+			return
+		}
+		served <- conn.ConnectionState().PeerCertificates[0]
+		// the session tickets follow the handshake
+		io.Copy(io.Discard, conn)
+	}()
+
+	conn, channelBinding, err := acceptSSLSession(clientConn, cfg)
+	if err != nil {
+		t.Fatalf("failed to accept the SSL session: %v", err)
+	}
+	defer conn.Close()
+	leaf := <-served
+	if leaf == nil {
+		t.Fatalf("the client failed the handshake")
+	}
+	if leaf.Subject.CommonName != "db" {
+		t.Errorf("served the certificate of %q, want the one of db", leaf.Subject.CommonName)
+	}
+	if want := tlsServerEndPoint(leaf); !bytes.Equal(channelBinding, want) {
+		t.Errorf("got the channel binding %x, want %x", channelBinding, want)
+	}
+}
+
+func TestSelectCertificate(t *testing.T) {
+	first, second := selfSignedCertificate(t, "first"), selfSignedCertificate(t, "second")
+	hello := &tls.ClientHelloInfo{ServerName: "second"}
+	if _, err := selectCertificate(&tls.Config{}, hello); err == nil {
+		t.Errorf("selected a certificate of a config without any")
+	}
+	cert, err := selectCertificate(&tls.Config{Certificates: []tls.Certificate{first}}, hello)
+	if err != nil || !bytes.Equal(cert.Certificate[0], first.Certificate[0]) {
+		t.Errorf("got the certificate %v: %v, want the only one", cert, err)
+	}
+	cert, err = selectCertificate(&tls.Config{
+		Certificates:   []tls.Certificate{first},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) { return &second, nil },
+	}, hello)
+	if err != nil || !bytes.Equal(cert.Certificate[0], second.Certificate[0]) {
+		t.Errorf("got the certificate %v: %v, want the one of GetCertificate", cert, err)
+	}
+}
+
+// sslAnswerMock returns the mock of the SSLRequest answered by the server.
+func sslAnswerMock(answer byte) *models.Mock {
+	return &models.Mock{
+		Name: "mock-0",
+		Kind: models.Postgres,
+		Spec: models.MockSpec{
+			PostgresRequests:  []models.Backend{{Identfier: "StartupRequest", Payload: base64.StdEncoding.EncodeToString((&pgproto3.SSLRequest{}).Encode(nil))}},
+			PostgresResponses: []models.Frontend{{Payload: base64.StdEncoding.EncodeToString([]byte{answer})}},
+		},
+	}
+}
+
+func TestRecordedSSLAnswer(t *testing.T) {
+	sslRequest := (&pgproto3.SSLRequest{}).Encode(nil)
+	for _, tt := range []struct {
+		name  string
+		mocks []*models.Mock
+		want  byte
+	}{
+		{name: "no negotiation recorded", want: 'S'},
+		{name: "refused", mocks: []*models.Mock{sslAnswerMock('N')}, want: 'N'},
+		{name: "accepted", mocks: []*models.Mock{sslAnswerMock('S')}, want: 'S'},
+		{name: "startup only", mocks: []*models.Mock{startupMock("mock-0", nil)}, want: 'S'},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := hooks.NewHook(nil, 0, zap.NewNop())
+			if err != nil {
+				t.Fatalf("failed to create the hooks: %v", err)
+			}
+			h.SetConfigMocks(tt.mocks)
+			if got := recordedSSLAnswer(h, sslRequest); got != tt.want {
+				t.Errorf("got the answer %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestSSLRefusedOnReplay refuses the SSLRequest of the client when the
+// recorded server did, though the sessions are decrypted.
+func TestSSLRefusedOnReplay(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetConfigMocks([]*models.Mock{sslAnswerMock('N')})
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{
+		TLSDecryption: &util.TLSDecryptConfig{Server: &tls.Config{}, Client: &tls.Config{}},
+	})
+
+	client, proxied := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- decodePostgresOutgoing((&pgproto3.SSLRequest{}).Encode(nil), proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+	}()
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set the read deadline: %v", err)
+	}
+	answer := make([]byte, 1)
+	if _, err := io.ReadFull(client, answer); err != nil {
+		t.Fatalf("failed to read the answer to the SSLRequest: %v", err)
+	}
+	if !bytes.Equal(answer, []byte{'N'}) {
+		t.Errorf("answered %q, want the refusal", answer)
+	}
+	client.Close()
+	<-done
+}
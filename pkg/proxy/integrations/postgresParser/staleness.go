@@ -0,0 +1,170 @@
+package postgresparser
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.uber.org/zap"
+)
+
+// passThroughWithinTolerance passes the unmatched requests of test mode
+// through to the database and verifies its live responses against the ones
+// recorded for the same requests by the mocks loaded for the replay, the
+// consumed ones included. It reports whether they are within the staleness
+// tolerance, the database having only drifted since the recording.
+func passThroughWithinTolerance(clientConn, destConn net.Conn, pgRequests, requestBuffers [][]byte, conn connectionState, h *hooks.Hook, logger *zap.Logger, opts PostgresOptions) (bool, error) {
+	if destConn == nil {
+		return false, errors.New("failed to pass network traffic to the destination connection")
+	}
+	if util.IsSelfReferential(clientConn, destConn) {
+		logger.Error("refusing to pass the network traffic back to the proxy", zap.Any("Destination Addr", destConn.RemoteAddr().String()))
+		return false, util.ErrSelfReferentialDestination
+	}
+	for _, request := range pgRequests {
+		if _, err := destConn.Write(request); err != nil {
+			logger.Error("failed to write request message to the destination server", zap.Error(err))
+			return false, err
+		}
+	}
+	live, err := util.ReadBytes(destConn)
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	if _, err := clientConn.Write(live); err != nil {
+		logger.Error("failed to write response to the client", zap.Error(err))
+		return false, err
+	}
+
+	opts.DriftMocks = nil
+	for _, mock := range h.GetLoadedMocks() {
+		if mock.Kind == models.Postgres {
+			opts.DriftMocks = append(opts.DriftMocks, mock)
+		}
+	}
+	recordedResponses, matched := findDriftMatch(requestBuffers, conn, opts)
+	if !matched {
+		return false, nil
+	}
+	recorded, err := responsesWireBytes(recordedResponses)
+	if err != nil {
+		logger.Debug("failed to encode the recorded postgres responses to verify the live ones", zap.Error(err))
+		return false, nil
+	}
+	return responsesWithinTolerance(recorded, live, opts.StalenessTolerance, opts.IgnoredFields), nil
+}
+
+// responsesWithinTolerance compares a recorded response with the live one of
+// the same requests. The values of the columns listed in tolerances may
+// differ by at most the delta configured for them, as the rows read from a
+// replica can lag behind the recording. All the other messages and values
+// must be the same, but for the ignored fields.
+func responsesWithinTolerance(recorded, live []byte, tolerances map[string]float64, ignored IgnoredFields) bool {
+	recordedMsgs, ok := splitMessages(recorded)
+	if !ok {
+		return bytes.Equal(recorded, live)
+	}
+	liveMsgs, ok := splitMessages(live)
+	if !ok || len(recordedMsgs) != len(liveMsgs) {
+		return false
+	}
+
+	var columns []string
+	for i, recordedMsg := range recordedMsgs {
+		liveMsg := liveMsgs[i]
+		if recordedMsg[0] != liveMsg[0] {
+			return false
+		}
+		switch recordedMsg[0] {
+		case 'T':
+			if !bytes.Equal(recordedMsg, liveMsg) {
+				return false
+			}
+			description := &pgproto3.RowDescription{}
+			if description.Decode(recordedMsg[5:]) != nil {
+				return false
+			}
+			columns = make([]string, len(description.Fields))
+			for j, field := range description.Fields {
+				columns[j] = strings.ToLower(string(field.Name))
+			}
+		case 'D':
+			if !dataRowsWithinTolerance(recordedMsg[5:], liveMsg[5:], columns, tolerances, ignored) {
+				return false
+			}
+		default:
+			if !bytes.Equal(recordedMsg, liveMsg) && !messagesEqualIgnoringFields(recordedMsg, liveMsg, ignored) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func dataRowsWithinTolerance(recordedBody, liveBody []byte, columns []string, tolerances map[string]float64, ignored IgnoredFields) bool {
+	if bytes.Equal(recordedBody, liveBody) {
+		return true
+	}
+	recordedRow, liveRow := &pgproto3.DataRow{}, &pgproto3.DataRow{}
+	if recordedRow.Decode(recordedBody) != nil || liveRow.Decode(liveBody) != nil {
+		return false
+	}
+	if len(recordedRow.Values) != len(liveRow.Values) {
+		return false
+	}
+	for j := range recordedRow.Values {
+		recordedValue, liveValue := recordedRow.Values[j], liveRow.Values[j]
+		if bytes.Equal(recordedValue, liveValue) {
+			continue
+		}
+		column := ""
+		if j < len(columns) {
+			column = columns[j]
+		}
+		if ignored.ignoresColumn(j, column) {
+			continue
+		}
+		if j >= len(columns) || recordedValue == nil || liveValue == nil {
+			return false
+		}
+		delta, ok := tolerances[columns[j]]
+		if !ok {
+			return false
+		}
+		recordedNumber, err := strconv.ParseFloat(string(recordedValue), 64)
+		if err != nil {
+			return false
+		}
+		liveNumber, err := strconv.ParseFloat(string(liveValue), 64)
+		if err != nil {
+			return false
+		}
+		if math.Abs(recordedNumber-liveNumber) > delta {
+			return false
+		}
+	}
+	return true
+}
+
+// splitMessages returns the messages of the buffer. It reports false when the
+// buffer does not hold whole messages.
+func splitMessages(buffer []byte) ([][]byte, bool) {
+	msgs := [][]byte{}
+	for i := 0; i < len(buffer); {
+		bodyLen, err := readMessageBodyLen(buffer, i)
+		if err != nil {
+			return nil, false
+		}
+		msgs = append(msgs, buffer[i:i+5+bodyLen])
+		i += 5 + bodyLen
+	}
+	return msgs, true
+}
@@ -0,0 +1,103 @@
+package postgresparser
+
+import (
+	"encoding/base64"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// balanceResponse returns the wire bytes of the response to the query of the
+// balance of an account.
+func balanceResponse(balance string) []byte {
+	buffer := (&pgproto3.RowDescription{Fields: []pgproto3.FieldDescription{{Name: []byte("balance"), DataTypeOID: 1700, DataTypeSize: -1, TypeModifier: -1}}}).Encode(nil)
+	buffer = (&pgproto3.DataRow{RowValues: []string{balance}}).Encode(buffer)
+	buffer = (&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")}).Encode(buffer)
+	return (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(buffer)
+}
+
+func TestResponsesWithinTolerance(t *testing.T) {
+	tolerances := map[string]float64{"balance": 0.5}
+	for _, tt := range []struct {
+		live string
+		want bool
+	}{
+		{live: "100.50", want: true},
+		{live: "100.90", want: true},
+		{live: "100.00", want: true},
+		{live: "101.10", want: false},
+		{live: "closed", want: false},
+	} {
+		if got := responsesWithinTolerance(balanceResponse("100.50"), balanceResponse(tt.live), tolerances, IgnoredFields{}); got != tt.want {
+			t.Errorf("live balance %s: got within tolerance %v, want %v", tt.live, got, tt.want)
+		}
+	}
+	if responsesWithinTolerance(balanceResponse("100.50"), balanceResponse("100.90"), nil, IgnoredFields{}) {
+		t.Errorf("accepted a drifted balance without a tolerance")
+	}
+}
+
+// TestPassThroughWithinTolerance replays a query whose mock was consumed, the
+// request is passed through to the replica whose balance drifted.
+func TestPassThroughWithinTolerance(t *testing.T) {
+	query := (&pgproto3.Query{String: "SELECT balance FROM accounts WHERE id = 1"}).Encode(nil)
+	for _, tt := range []struct {
+		live string
+		want bool
+	}{
+		{live: "100.90", want: true},
+		{live: "102.00", want: false},
+	} {
+		h, err := hooks.NewHook(nil, 0, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create the hooks: %v", err)
+		}
+		mock := &models.Mock{
+			Name: "mock-0",
+			Kind: models.Postgres,
+			Spec: models.MockSpec{
+				PostgresRequests:  []models.Backend{{Payload: base64.StdEncoding.EncodeToString(query)}},
+				PostgresResponses: []models.Frontend{{Payload: base64.StdEncoding.EncodeToString(balanceResponse("100.50"))}},
+			},
+		}
+		h.SetTcsMocks([]*models.Mock{mock})
+		if !h.DeleteTcsMock(mock) {
+			t.Fatalf("failed to consume the mock")
+		}
+		opts := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{StalenessTolerance: map[string]float64{"balance": 0.5}}).opts
+
+		app, clientConn := net.Pipe()
+		destConn, replica := net.Pipe()
+		go func() {
+			buffer := make([]byte, len(query))
+			if _, err := io.ReadFull(replica, buffer); err != nil {
+				return
+			}
+			replica.Write(balanceResponse(tt.live))
+		}()
+		forwarded := make(chan []byte, 1)
+		go func() {
+			buffer := make([]byte, len(balanceResponse(tt.live)))
+			io.ReadFull(app, buffer)
+			forwarded <- buffer
+		}()
+
+		got, err := passThroughWithinTolerance(clientConn, destConn, [][]byte{query}, [][]byte{query}, connectionState{}, h, zap.NewNop(), opts)
+		if err != nil {
+			t.Fatalf("failed to pass the query through: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("live balance %s: got within tolerance %v, want %v", tt.live, got, tt.want)
+		}
+		if response := <-forwarded; string(response) != string(balanceResponse(tt.live)) {
+			t.Errorf("live balance %s: forwarded %q to the client, want the live response", tt.live, response)
+		}
+		app.Close()
+		replica.Close()
+	}
+}
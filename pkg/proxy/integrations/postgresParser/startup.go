@@ -0,0 +1,84 @@
+package postgresparser
+
+import (
+	"encoding/binary"
+	"strings"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// volatileStartupParameters differ across the runs of the same application
+// and are never compared when matching the startup message.
+var volatileStartupParameters = []string{"application_name"}
+
+// decodeStartupMessage decodes a startup message, returning nil for any other
+// message.
+func decodeStartupMessage(buffer []byte) *pgproto3.StartupMessage {
+	if len(buffer) < 8 || int(binary.BigEndian.Uint32(buffer[0:4])) != len(buffer) || !isStartupPacket(buffer) {
+		return nil
+	}
+	msg := &pgproto3.StartupMessage{}
+	if err := msg.Decode(buffer[4:]); err != nil {
+		return nil
+	}
+	return msg
+}
+
+// findStartupMatch returns the index of the mock recorded for the same
+// startup message, or -1. The parameters in ignored (compared
+// case-insensitively) and the volatile ones are left out of the comparison
+// while the rest, such as the database and user, have to be equal.
+func findStartupMatch(tcsMocks []*models.Mock, requestBuffers [][]byte, ignored []string) int {
+	if len(requestBuffers) != 1 {
+		return -1
+	}
+	actual := decodeStartupMessage(requestBuffers[0])
+	if actual == nil {
+		return -1
+	}
+	skip := make(map[string]bool, len(ignored)+len(volatileStartupParameters))
+	for _, name := range ignored {
+		skip[strings.ToLower(name)] = true
+	}
+	for _, name := range volatileStartupParameters {
+		skip[name] = true
+	}
+	for idx, mock := range tcsMocks {
+		if mock == nil || len(mock.Spec.PostgresRequests) != 1 || mock.Spec.PostgresRequests[0].Identfier != "StartupRequest" {
+			continue
+		}
+		encoded, err := PostgresDecoder(mock.Spec.PostgresRequests[0].Payload)
+		if err != nil {
+			continue
+		}
+		recorded := decodeStartupMessage(encoded)
+		if recorded == nil || recorded.ProtocolVersion != actual.ProtocolVersion {
+			continue
+		}
+		if startupParametersEqual(recorded.Parameters, actual.Parameters, skip) {
+			return idx
+		}
+	}
+	return -1
+}
+
+func startupParametersEqual(recorded, actual map[string]string, skip map[string]bool) bool {
+	for name, value := range recorded {
+		if skip[strings.ToLower(name)] {
+			continue
+		}
+		if v, ok := actual[name]; !ok || v != value {
+			return false
+		}
+	}
+	for name := range actual {
+		if skip[strings.ToLower(name)] {
+			continue
+		}
+		if _, ok := recorded[name]; !ok {
+			return false
+		}
+	}
+	return true
+}
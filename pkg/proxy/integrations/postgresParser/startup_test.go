@@ -0,0 +1,138 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func startupWith(parameters map[string]string) []byte {
+	return (&pgproto3.StartupMessage{ProtocolVersion: pgproto3.ProtocolVersionNumber, Parameters: parameters}).Encode(nil)
+}
+
+func startupMock(name string, parameters map[string]string) *models.Mock {
+	return &models.Mock{
+		Name: name,
+		Kind: models.Postgres,
+		Spec: models.MockSpec{
+			PostgresRequests:  []models.Backend{{Identfier: "StartupRequest", Payload: base64.StdEncoding.EncodeToString(startupWith(parameters))}},
+			PostgresResponses: []models.Frontend{{PacketTypes: []string{"R"}, AuthType: AuthTypeOk}},
+		},
+	}
+}
+
+func TestFindStartupMatch(t *testing.T) {
+	mocks := []*models.Mock{
+		recordedMock("mock-0", SimpleQueryRequest("SELECT 1")[0]),
+		startupMock("mock-1", map[string]string{"user": "keploy", "database": "orders", "application_name": "orders-7f9c"}),
+		startupMock("mock-2", map[string]string{"user": "keploy", "database": "bank", "application_name": "bank-1a2b", "client_encoding": "UTF8"}),
+	}
+	for _, tt := range []struct {
+		name       string
+		parameters map[string]string
+		ignored    []string
+		want       int
+	}{
+		{name: "another application name", parameters: map[string]string{"user": "keploy", "database": "orders", "application_name": "orders-d41e"}, want: 1},
+		{name: "no application name", parameters: map[string]string{"user": "keploy", "database": "orders"}, want: 1},
+		{name: "another database", parameters: map[string]string{"user": "keploy", "database": "billing", "application_name": "orders-7f9c"}, want: -1},
+		{name: "another user", parameters: map[string]string{"user": "admin", "database": "orders"}, want: -1},
+		{name: "missing parameter", parameters: map[string]string{"user": "keploy", "database": "bank"}, want: -1},
+		{name: "ignored parameter", parameters: map[string]string{"user": "keploy", "database": "bank"}, ignored: []string{"Client_Encoding"}, want: 2},
+		{name: "extra parameter", parameters: map[string]string{"user": "keploy", "database": "orders", "TimeZone": "UTC"}, want: -1},
+	} {
+		if got := findStartupMatch(mocks, [][]byte{startupWith(tt.parameters)}, tt.ignored); got != tt.want {
+			t.Errorf("%s: matched the mock %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	if got := findStartupMatch(mocks, SimpleQueryRequest("SELECT 1"), nil); got != -1 {
+		t.Errorf("matched the startup mock %d with a query", got)
+	}
+	if got := decodeStartupMessage(startupWith(map[string]string{"user": "keploy"})[:10]); got != nil {
+		t.Errorf("decoded the truncated startup message %+v", got)
+	}
+}
+
+// TestSplitStartupRecorded records a startup message the client sends in
+// three writes, then one whose first writes outgrow the pending limit, which
+// passes the connection through unrecorded.
+func TestSplitStartupRecorded(t *testing.T) {
+	startup := startupRequest("-c application_name=" + strings.Repeat("x", 200))
+	started := encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	for _, tt := range []struct {
+		name            string
+		maxPendingBytes int
+		wantRecorded    bool
+	}{
+		{name: "recorded whole", wantRecorded: true},
+		{name: "outgrowing the limit", maxPendingBytes: 64},
+	} {
+		db := &mockDB{}
+		h, err := hooks.NewHook(db, 0, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create the hooks: %v", err)
+		}
+		p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{MaxPendingBytes: tt.maxPendingBytes})
+
+		app, clientConn := net.Pipe()
+		destConn, server := net.Pipe()
+		received := make(chan []byte, 1)
+		go func() {
+			defer server.Close()
+			got := make([]byte, len(startup))
+			if _, err := io.ReadFull(server, got); err != nil {
+				received <- nil
+				return
+			}
+			received <- got
+			server.Write(started)
+			io.Copy(io.Discard, server)
+		}()
+		go func() {
+			defer app.Close()
+			// the first part is sent along with the connection
+			for _, part := range [][]byte{startup[20:100], startup[100:]} {
+				time.Sleep(20 * time.Millisecond)
+				app.Write(part)
+			}
+			io.ReadFull(app, make([]byte, len(started)))
+		}()
+		done := make(chan struct{})
+		go func() {
+			encodePostgresOutgoing(startup[:20], clientConn, destConn, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatalf("%s: the recording of the session did not end", tt.name)
+		}
+
+		if got := <-received; !bytes.Equal(got, startup) {
+			t.Errorf("%s: the server received %q, want the startup message %q", tt.name, got, startup)
+		}
+		if !tt.wantRecorded {
+			if len(db.mocks) != 0 {
+				t.Errorf("%s: recorded %d mocks, want the connection passed through", tt.name, len(db.mocks))
+			}
+			continue
+		}
+		if len(db.mocks) == 0 || len(db.mocks[0].Spec.PostgresRequests) == 0 {
+			t.Fatalf("%s: recorded no startup", tt.name)
+		}
+		if got, err := BackendWireBytes(db.mocks[0].Spec.PostgresRequests[0]); err != nil || !bytes.Equal(got, startup) {
+			t.Errorf("%s: recorded the startup %q (%v), want %q", tt.name, got, err, startup)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package postgresparser
+
+import (
+	"sort"
+	"strings"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// startupOptionsMetadata is the metadata key of the settings passed in the
+// options of the startup message a mock was recorded with.
+const startupOptionsMetadata = "startup_options"
+
+// startupOptionsFromRequests returns the settings passed in the options of
+// the startup message of the requests, such as
+// "-c default_transaction_isolation=serializable", as sorted name=value pairs.
+// The search_path is left out as the mocks are partitioned by it already.
+func startupOptionsFromRequests(requestBuffers [][]byte) (string, bool) {
+	for _, buffer := range requestBuffers {
+		if startup := decodeStartupMessage(buffer); startup != nil {
+			return normalizeStartupOptions(startup.Parameters["options"]), true
+		}
+	}
+	return "", false
+}
+
+// normalizeStartupOptions parses the -c name=value and --name=value settings
+// of the options parameter. The names and values are lower cased, as the
+// server reads them case-insensitively, and the dashes of the names are read
+// as underscores.
+func normalizeStartupOptions(options string) string {
+	settings := []string{}
+	args := splitStartupOptions(options)
+	for i := 0; i < len(args); i++ {
+		var setting string
+		switch arg := args[i]; {
+		case arg == "-c" && i+1 < len(args):
+			i++
+			setting = args[i]
+		case strings.HasPrefix(arg, "--"):
+			setting = arg[2:]
+		case strings.HasPrefix(arg, "-c"):
+			setting = arg[2:]
+		default:
+			continue
+		}
+		name, value, ok := strings.Cut(setting, "=")
+		if !ok {
+			continue
+		}
+		name = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "-", "_")
+		if name == "search_path" {
+			continue
+		}
+		value = strings.ToLower(strings.Trim(strings.TrimSpace(value), `'"`))
+		settings = append(settings, name+"="+value)
+	}
+	sort.Strings(settings)
+	return strings.Join(settings, ",")
+}
+
+// splitStartupOptions splits the options at the whitespace, which a backslash
+// escapes as the server does.
+func splitStartupOptions(options string) []string {
+	var args []string
+	var arg strings.Builder
+	for i := 0; i < len(options); i++ {
+		c := options[i]
+		switch {
+		case c == '\\' && i+1 < len(options):
+			i++
+			arg.WriteByte(options[i])
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if arg.Len() > 0 {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+		default:
+			arg.WriteByte(c)
+		}
+	}
+	if arg.Len() > 0 {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// filterMocksByStartupOptions leaves out the mocks recorded on connections
+// started with other settings than the active connection, so that a query
+// recorded under serializable isolation doesn't replay for a read committed
+// connection. The mocks recorded without the settings match any connection.
+func filterMocksByStartupOptions(mocks []*models.Mock, startupOptions string) []*models.Mock {
+	filtered := make([]*models.Mock, 0, len(mocks))
+	for _, mock := range mocks {
+		if mock == nil {
+			continue
+		}
+		if recorded, ok := mock.Spec.Metadata[startupOptionsMetadata]; ok && recorded != startupOptions {
+			continue
+		}
+		filtered = append(filtered, mock)
+	}
+	return filtered
+}
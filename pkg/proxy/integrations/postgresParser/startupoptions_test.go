@@ -0,0 +1,149 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func startupRequest(options string) []byte {
+	parameters := map[string]string{"user": "keploy", "database": "bank"}
+	if options != "" {
+		parameters["options"] = options
+	}
+	return (&pgproto3.StartupMessage{ProtocolVersion: pgproto3.ProtocolVersionNumber, Parameters: parameters}).Encode(nil)
+}
+
+func TestNormalizeStartupOptions(t *testing.T) {
+	for _, tt := range []struct {
+		options string
+		want    string
+	}{
+		{options: "", want: ""},
+		{options: "-c default_transaction_isolation=serializable", want: "default_transaction_isolation=serializable"},
+		{options: "-cdefault_transaction_isolation=SERIALIZABLE", want: "default_transaction_isolation=serializable"},
+		{options: `--default-transaction-isolation=read\ committed`, want: "default_transaction_isolation=read committed"},
+		{options: `-c default_transaction_isolation=read\ committed -c statement_timeout=5s`, want: "default_transaction_isolation=read committed,statement_timeout=5s"},
+		{options: "-c statement_timeout=5s -c default_transaction_isolation=serializable", want: "default_transaction_isolation=serializable,statement_timeout=5s"},
+		// the mocks are partitioned by the search_path already
+		{options: "-c search_path=billing -c timezone=UTC", want: "timezone=utc"},
+		{options: "-c geqo", want: ""},
+	} {
+		if got := normalizeStartupOptions(tt.options); got != tt.want {
+			t.Errorf("%q: got the settings %q, want %q", tt.options, got, tt.want)
+		}
+	}
+}
+
+func TestStartupOptionsFromRequests(t *testing.T) {
+	if _, ok := startupOptionsFromRequests(SimpleQueryRequest("SELECT 1")); ok {
+		t.Errorf("read the startup options of a query")
+	}
+	options, ok := startupOptionsFromRequests([][]byte{startupRequest("-c default_transaction_isolation=serializable")})
+	if !ok || options != "default_transaction_isolation=serializable" {
+		t.Errorf("got the startup options %q, want the isolation", options)
+	}
+}
+
+// isolationMock returns the mock of the query recorded on a connection
+// started with the isolation, answered with the balance.
+func isolationMock(name, isolation, balance string) *models.Mock {
+	mock := balanceMock("1", balance)
+	mock.Name = name
+	if isolation != "" {
+		mock.Spec.Metadata = map[string]string{startupOptionsMetadata: normalizeStartupOptions("-c default_transaction_isolation=" + isolation)}
+	}
+	return mock
+}
+
+// TestMocksScopedByIsolation replays the query recorded under serializable
+// isolation and under read committed isolation to connections started with
+// differing isolation options.
+func TestMocksScopedByIsolation(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		options string
+		mocks   []*models.Mock
+		want    string
+	}{
+		{
+			name:    "serializable",
+			options: "-c default_transaction_isolation=serializable",
+			mocks:   []*models.Mock{isolationMock("mock-0", `read\ committed`, "100.50"), isolationMock("mock-1", "serializable", "99.00")},
+			want:    "99.00",
+		},
+		{
+			name:    "read committed",
+			options: `-c default_transaction_isolation=read\ committed`,
+			mocks:   []*models.Mock{isolationMock("mock-0", "serializable", "99.00"), isolationMock("mock-1", `read\ committed`, "100.50")},
+			want:    "100.50",
+		},
+		{
+			name:    "recorded without options",
+			options: "-c default_transaction_isolation=serializable",
+			mocks:   []*models.Mock{isolationMock("mock-0", "", "100.50")},
+			want:    "100.50",
+		},
+	} {
+		h, err := hooks.NewHook(nil, 0, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to create the hooks: %v", err)
+		}
+		h.SetConfigMocks(tt.mocks)
+		opts := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{}).opts
+		options, _ := startupOptionsFromRequests([][]byte{startupRequest(tt.options)})
+
+		matched, responses, _, _, err := matchingReadablePG([][]byte{balanceQuery("1")}, connectionState{startupOptions: options}, zap.NewNop(), h, opts)
+		if err != nil {
+			t.Fatalf("%s: failed to match the query: %v", tt.name, err)
+		}
+		if !matched || len(responses) != 1 {
+			t.Fatalf("%s: matched no mock", tt.name)
+		}
+		if got, _ := base64.StdEncoding.DecodeString(responses[0].Payload); !bytes.Equal(got, balanceResponse(tt.want)) {
+			t.Errorf("%s: got the responses %q, want the balance %s", tt.name, got, tt.want)
+		}
+	}
+
+	// no mock is recorded with the isolation of the connection
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetConfigMocks([]*models.Mock{isolationMock("mock-0", "serializable", "99.00")})
+	opts := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{}).opts
+	options, _ := startupOptionsFromRequests([][]byte{startupRequest(`-c default_transaction_isolation=repeatable\ read`)})
+	if matched, _, _, _, _ := matchingReadablePG([][]byte{balanceQuery("1")}, connectionState{startupOptions: options}, zap.NewNop(), h, opts); matched {
+		t.Errorf("replayed the serializable mock to a repeatable read connection")
+	}
+}
+
+func TestStartupOptionsRecorded(t *testing.T) {
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+	conn := p.newConnection(context.Background(), zap.NewNop())
+	requests, responses := liveExchange("1", "99.00")
+	conn.appendMock(requests, responses, time.Now(), time.Now(), mockScope{startupOptions: "default_transaction_isolation=serializable"}, false)
+	conn.appendMock(requests, responses, time.Now(), time.Now(), mockScope{}, false)
+
+	if len(db.mocks) != 2 {
+		t.Fatalf("recorded %d mocks, want 2", len(db.mocks))
+	}
+	if got := db.mocks[0].Spec.Metadata[startupOptionsMetadata]; got != "default_transaction_isolation=serializable" {
+		t.Errorf("recorded the startup options %q, want the isolation", got)
+	}
+	if _, ok := db.mocks[1].Spec.Metadata[startupOptionsMetadata]; ok {
+		t.Errorf("recorded the startup options of a connection started without any")
+	}
+}
@@ -0,0 +1,285 @@
+package postgresparser
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.uber.org/zap"
+)
+
+var deallocateQueryRe = regexp.MustCompile(`(?i)^\s*deallocate\s+(?:prepare\s+)?("[^"]+"|[a-z0-9_]+)\s*;?\s*$`)
+
+// statementRegistry tracks the prepared statements of a connection, mapping
+// their names to their queries. The named statements persist until they are
+// closed or deallocated while the unnamed statement ("") is replaced by the
+// next Parse and destroyed by a simple query.
+type statementRegistry struct {
+	names map[string]string
+	// unnamedPortal is the query of the statement bound to the unnamed
+	// portal, until it is bound again, closed or destroyed by a simple query.
+	unnamedPortal string
+}
+
+func newStatementRegistry() *statementRegistry {
+	return &statementRegistry{names: make(map[string]string)}
+}
+
+// observe updates the registry with the Parse, Close, Query and DEALLOCATE
+// messages of the requests.
+func (r *statementRegistry) observe(requestBuffers [][]byte) {
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		switch msgType {
+		case 'P':
+			parse := &pgproto3.Parse{}
+			if parse.Decode(body) == nil {
+				r.names[parse.Name] = parse.Query
+			}
+		case 'B':
+			bind := &pgproto3.Bind{}
+			if bind.Decode(body) == nil && bind.DestinationPortal == "" {
+				r.unnamedPortal = r.names[bind.PreparedStatement]
+			}
+		case 'C':
+			closeMsg := &pgproto3.Close{}
+			if closeMsg.Decode(body) != nil {
+				return
+			}
+			if closeMsg.Object_Type == 'S' {
+				delete(r.names, closeMsg.Name)
+			} else if closeMsg.Name == "" {
+				r.unnamedPortal = ""
+			}
+		case 'Q':
+			query := &pgproto3.Query{}
+			if query.Decode(body) != nil {
+				return
+			}
+			delete(r.names, "")
+			r.unnamedPortal = ""
+			if match := deallocateQueryRe.FindStringSubmatch(query.String); match != nil {
+				if isDeallocateAll(match[1]) {
+					r.names = make(map[string]string)
+					return
+				}
+				delete(r.names, unquoteIdentifier(match[1]))
+			}
+		}
+	})
+}
+
+// clone returns a copy of the registry, nil for a nil registry.
+func (r *statementRegistry) clone() *statementRegistry {
+	if r == nil {
+		return nil
+	}
+	c := &statementRegistry{names: make(map[string]string, len(r.names)), unnamedPortal: r.unnamedPortal}
+	for name, query := range r.names {
+		c.names[name] = query
+	}
+	return c
+}
+
+// statementNames tracks the named statements prepared by all the connections
+// of a recording. The pooled connections of an application may prepare
+// different queries under the same name, which the replay can't tell apart
+// when a statement is bound apart from its Parse.
+type statementNames struct {
+	mutex   sync.Mutex
+	queries map[string]preparedStatement
+	warned  map[string]bool
+}
+
+// preparedStatement is a query prepared under a name and the connection which
+// prepared it.
+type preparedStatement struct {
+	query string
+	conn  *statementRegistry
+}
+
+func newStatementNames() *statementNames {
+	return &statementNames{
+		queries: make(map[string]preparedStatement),
+		warned:  make(map[string]bool),
+	}
+}
+
+// observe warns, once per name, when the requests of the connection prepare a
+// named statement with another query than a different connection did.
+func (s *statementNames) observe(requestBuffers [][]byte, conn *statementRegistry, logger *zap.Logger) {
+	if s == nil {
+		return
+	}
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		if msgType != 'P' {
+			return
+		}
+		parse := &pgproto3.Parse{}
+		if parse.Decode(body) != nil || parse.Name == "" {
+			return
+		}
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		previous, ok := s.queries[parse.Name]
+		s.queries[parse.Name] = preparedStatement{query: parse.Query, conn: conn}
+		if !ok || previous.conn == conn || s.warned[parse.Name] || normalizeQuery(previous.query) == normalizeQuery(parse.Query) {
+			return
+		}
+		s.warned[parse.Name] = true
+		logger.Warn("the same prepared statement name is used for different queries by several postgres connections, the mocks binding it may replay the wrong statement",
+			zap.String("statement", parse.Name), zap.String("query", parse.Query), zap.String("other query", previous.query))
+	})
+}
+
+// forget drops the statements prepared by the connection, once it is closed.
+func (s *statementNames) forget(conn *statementRegistry) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for name, statement := range s.queries {
+		if statement.conn == conn {
+			delete(s.queries, name)
+		}
+	}
+}
+
+// reset forgets all the prepared statements, for a new test-set.
+func (s *statementNames) reset() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.queries = make(map[string]preparedStatement)
+	s.warned = make(map[string]bool)
+}
+
+// conflicts reports whether the recorded requests prepare, under the name of a
+// statement the requests bind without preparing it, a query other than the
+// one the statement was prepared with on the connection. Such recordings
+// executed a different statement.
+func (r *statementRegistry) conflicts(requestBuffers, recordedBuffers [][]byte) bool {
+	bound := make(map[string]bool)
+	parsed := make(map[string]bool)
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		switch msgType {
+		case 'P':
+			parse := &pgproto3.Parse{}
+			if parse.Decode(body) == nil {
+				parsed[parse.Name] = true
+			}
+		case 'B':
+			bind := &pgproto3.Bind{}
+			if bind.Decode(body) == nil && !parsed[bind.PreparedStatement] {
+				bound[bind.PreparedStatement] = true
+			}
+		}
+	})
+	if len(bound) == 0 {
+		return false
+	}
+	conflict := false
+	forEachMessage(recordedBuffers, func(msgType byte, body []byte) {
+		if msgType != 'P' || conflict {
+			return
+		}
+		parse := &pgproto3.Parse{}
+		if parse.Decode(body) != nil || !bound[parse.Name] {
+			return
+		}
+		if query, ok := r.names[parse.Name]; ok && normalizeQuery(query) != normalizeQuery(parse.Query) {
+			conflict = true
+		}
+	})
+	return conflict
+}
+
+// deallocationResponse acknowledges requests made only of Close, Flush and
+// Sync messages, or of a DEALLOCATE simple query, when every statement they
+// deallocate is open on the connection. The ReadyForQuery echoes txStatus, the
+// transaction status of the last replayed one. It returns nil for any other
+// request.
+func (r *statementRegistry) deallocationResponse(requestBuffers [][]byte, txStatus byte) []byte {
+	var response []byte
+	closes, others, sync := 0, 0, false
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		switch msgType {
+		case 'C':
+			closeMsg := &pgproto3.Close{}
+			if closeMsg.Decode(body) != nil {
+				others++
+				return
+			}
+			if _, ok := r.names[closeMsg.Name]; closeMsg.Object_Type == 'S' && !ok {
+				others++
+				return
+			}
+			closes++
+			response = (&pgproto3.CloseComplete{}).Encode(response)
+		case 'S':
+			sync = true
+		case 'H':
+		case 'Q':
+			query := &pgproto3.Query{}
+			if query.Decode(body) != nil {
+				others++
+				return
+			}
+			match := deallocateQueryRe.FindStringSubmatch(query.String)
+			if match == nil {
+				others++
+				return
+			}
+			tag := "DEALLOCATE"
+			if isDeallocateAll(match[1]) {
+				tag = "DEALLOCATE ALL"
+			} else if _, ok := r.names[unquoteIdentifier(match[1])]; !ok {
+				others++
+				return
+			}
+			closes++
+			sync = true
+			response = (&pgproto3.CommandComplete{CommandTag: []byte(tag)}).Encode(response)
+		default:
+			others++
+		}
+	})
+	if closes == 0 || others > 0 {
+		return nil
+	}
+	if sync {
+		response = (&pgproto3.ReadyForQuery{TxStatus: txStatus}).Encode(response)
+	}
+	return response
+}
+
+// forEachMessage calls fn with the type and body of every regular message of
+// the request buffers.
+func forEachMessage(requestBuffers [][]byte, fn func(msgType byte, body []byte)) {
+	for _, buffer := range requestBuffers {
+		if len(buffer) >= 8 && isStartupPacket(buffer) {
+			continue
+		}
+		for i := 0; i+5 <= len(buffer); {
+			bodyLen, err := readMessageBodyLen(buffer, i)
+			if err != nil {
+				break
+			}
+			fn(buffer[i], buffer[i+5:i+5+bodyLen])
+			i += bodyLen + 5
+		}
+	}
+}
+
+func isDeallocateAll(name string) bool {
+	return len(name) == 3 && (name[0]|0x20) == 'a' && (name[1]|0x20) == 'l' && (name[2]|0x20) == 'l'
+}
+
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 && name[0] == '"' && name[len(name)-1] == '"' {
+		return name[1 : len(name)-1]
+	}
+	return name
+}
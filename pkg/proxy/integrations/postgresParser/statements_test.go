@@ -0,0 +1,286 @@
+package postgresparser
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// prepareRequest returns the buffer preparing the named statements of the
+// queries, given as name and query pairs.
+func prepareRequest(statements ...string) []byte {
+	var buffer []byte
+	for i := 0; i+1 < len(statements); i += 2 {
+		buffer = (&pgproto3.Parse{Name: statements[i], Query: statements[i+1]}).Encode(buffer)
+	}
+	return (&pgproto3.Sync{}).Encode(buffer)
+}
+
+func TestStatementRegistryObserve(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		requests [][]byte
+		want     map[string]string
+	}{
+		{
+			name:     "prepared",
+			requests: [][]byte{prepareRequest("s1", "SELECT 1", "s2", "SELECT 2")},
+			want:     map[string]string{"s1": "SELECT 1", "s2": "SELECT 2"},
+		},
+		{
+			name: "closed",
+			requests: [][]byte{
+				prepareRequest("s1", "SELECT 1", "s2", "SELECT 2"),
+				encodeMessages(&pgproto3.Close{Object_Type: 'S', Name: "s1"}, &pgproto3.Sync{}),
+			},
+			want: map[string]string{"s2": "SELECT 2"},
+		},
+		{
+			name: "closing a portal keeps the statement",
+			requests: [][]byte{
+				prepareRequest("s1", "SELECT 1"),
+				encodeMessages(&pgproto3.Close{Object_Type: 'P', Name: "s1"}, &pgproto3.Sync{}),
+			},
+			want: map[string]string{"s1": "SELECT 1"},
+		},
+		{
+			name: "deallocated",
+			requests: [][]byte{
+				prepareRequest("s1", "SELECT 1", "S2", "SELECT 2"),
+				(&pgproto3.Query{String: `DEALLOCATE PREPARE "S2"`}).Encode(nil),
+			},
+			want: map[string]string{"s1": "SELECT 1"},
+		},
+		{
+			name: "all deallocated",
+			requests: [][]byte{
+				prepareRequest("s1", "SELECT 1", "s2", "SELECT 2"),
+				(&pgproto3.Query{String: "deallocate all;"}).Encode(nil),
+			},
+			want: map[string]string{},
+		},
+		{
+			name: "unnamed statement destroyed by a simple query",
+			requests: [][]byte{
+				prepareRequest("", "SELECT 1", "s1", "SELECT 2"),
+				(&pgproto3.Query{String: "SELECT 3"}).Encode(nil),
+			},
+			want: map[string]string{"s1": "SELECT 2"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			statements := newStatementRegistry()
+			statements.observe(tt.requests)
+			if !reflect.DeepEqual(statements.names, tt.want) {
+				t.Errorf("got the statements %v, want %v", statements.names, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatementRegistryPerConnection(t *testing.T) {
+	first, second := newStatementRegistry(), newStatementRegistry()
+	first.observe([][]byte{prepareRequest("s1", "SELECT 1")})
+	second.observe([][]byte{prepareRequest("s2", "SELECT 2")})
+	second.observe([][]byte{(&pgproto3.Query{String: "DEALLOCATE ALL"}).Encode(nil)})
+
+	if want := map[string]string{"s1": "SELECT 1"}; !reflect.DeepEqual(first.names, want) {
+		t.Errorf("got the statements %v of the first connection, want %v", first.names, want)
+	}
+	if len(second.names) != 0 {
+		t.Errorf("got the statements %v of the second connection, want none", second.names)
+	}
+	// the deallocations of a connection only acknowledge its own statements
+	if response := second.deallocationResponse([][]byte{encodeMessages(&pgproto3.Close{Object_Type: 'S', Name: "s1"}, &pgproto3.Sync{})}, 'I'); response != nil {
+		t.Errorf("got the response %q closing the statement of another connection", response)
+	}
+}
+
+func TestDeallocationResponse(t *testing.T) {
+	statements := newStatementRegistry()
+	statements.observe([][]byte{prepareRequest("s1", "SELECT 1", "s2", "SELECT 2")})
+
+	for _, tt := range []struct {
+		name     string
+		request  []byte
+		txStatus byte
+		want     []byte
+	}{
+		{
+			name:     "close and sync",
+			request:  encodeMessages(&pgproto3.Close{Object_Type: 'S', Name: "s1"}, &pgproto3.Close{Object_Type: 'S', Name: "s2"}, &pgproto3.Sync{}),
+			txStatus: 'I',
+			want:     encodeMessages(&pgproto3.CloseComplete{}, &pgproto3.CloseComplete{}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		},
+		{
+			name:    "close and flush",
+			request: encodeMessages(&pgproto3.Close{Object_Type: 'S', Name: "s1"}, &pgproto3.Flush{}),
+			want:    encodeMessages(&pgproto3.CloseComplete{}),
+		},
+		{
+			name:     "close of a portal in a transaction",
+			request:  encodeMessages(&pgproto3.Close{Object_Type: 'P', Name: "p1"}, &pgproto3.Sync{}),
+			txStatus: 'T',
+			want:     encodeMessages(&pgproto3.CloseComplete{}, &pgproto3.ReadyForQuery{TxStatus: 'T'}),
+		},
+		{
+			name:     "deallocate",
+			request:  (&pgproto3.Query{String: "DEALLOCATE s2"}).Encode(nil),
+			txStatus: 'I',
+			want:     encodeMessages(&pgproto3.CommandComplete{CommandTag: []byte("DEALLOCATE")}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		},
+		{
+			name:     "deallocate all",
+			request:  (&pgproto3.Query{String: "DEALLOCATE ALL"}).Encode(nil),
+			txStatus: 'I',
+			want:     encodeMessages(&pgproto3.CommandComplete{CommandTag: []byte("DEALLOCATE ALL")}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		},
+		{
+			name:    "unknown statement",
+			request: encodeMessages(&pgproto3.Close{Object_Type: 'S', Name: "s3"}, &pgproto3.Sync{}),
+		},
+		{
+			name:    "deallocate of an unknown statement",
+			request: (&pgproto3.Query{String: "DEALLOCATE s3"}).Encode(nil),
+		},
+		{
+			name:    "close with a query",
+			request: encodeMessages(&pgproto3.Close{Object_Type: 'S', Name: "s1"}, &pgproto3.Parse{Query: "SELECT 3"}, &pgproto3.Sync{}),
+		},
+		{
+			name:    "other query",
+			request: (&pgproto3.Query{String: "SELECT 1"}).Encode(nil),
+		},
+		{
+			name:    "sync alone",
+			request: encodeMessages(&pgproto3.Sync{}),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := statements.deallocationResponse([][]byte{tt.request}, tt.txStatus)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got the response %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// bindRequest returns the buffer binding the prepared statement and executing
+// it.
+func bindRequest(statement, param string) []byte {
+	return encodeMessages(&pgproto3.Bind{PreparedStatement: statement, Parameters: [][]byte{[]byte(param)}}, &pgproto3.Execute{}, &pgproto3.Sync{})
+}
+
+func TestFilterMocksByStatements(t *testing.T) {
+	prepared := func(name, query string) []byte {
+		return append(prepareRequest(name, query), bindRequest(name, "1")...)
+	}
+	usersNamed := recordedMock("users-named", prepared("s1", "SELECT name FROM users WHERE id = $1"))
+	ordersNamed := recordedMock("orders-named", prepared("s1", "SELECT total FROM orders WHERE id = $1"))
+	usersUnnamed := recordedMock("users-unnamed", prepared("", "SELECT name FROM users WHERE id = $1"))
+	ordersUnnamed := recordedMock("orders-unnamed", prepared("", "SELECT total FROM orders WHERE id = $1"))
+	bound := recordedMock("bound", bindRequest("s1", "1"))
+	mocks := []*models.Mock{usersNamed, ordersNamed, usersUnnamed, ordersUnnamed, bound}
+
+	statements := newStatementRegistry()
+	statements.observe([][]byte{prepareRequest("s1", "SELECT name FROM users WHERE id = $1", "", "SELECT total FROM orders WHERE id = $1")})
+
+	names := func(mocks []*models.Mock) []string {
+		var names []string
+		for _, mock := range mocks {
+			names = append(names, mock.Name)
+		}
+		return names
+	}
+	for _, tt := range []struct {
+		name       string
+		request    []byte
+		statements *statementRegistry
+		want       []string
+	}{
+		{
+			name:       "named statement bound apart from its Parse",
+			request:    bindRequest("s1", "2"),
+			statements: statements,
+			want:       []string{"users-named", "users-unnamed", "orders-unnamed", "bound"},
+		},
+		{
+			name:       "unnamed statement bound apart from its Parse",
+			request:    bindRequest("", "2"),
+			statements: statements,
+			want:       []string{"users-named", "orders-named", "orders-unnamed", "bound"},
+		},
+		{
+			name:       "statement prepared by the request",
+			request:    prepared("s1", "SELECT total FROM orders WHERE id = $1"),
+			statements: statements,
+			want:       names(mocks),
+		},
+		{
+			name:    "no prepared statement",
+			request: bindRequest("s1", "2"),
+			want:    names(mocks),
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			got := names(filterMocksByStatements(mocks, [][]byte{tt.request}, tt.statements))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got the mocks %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatementNamesObserve(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+	logger := zap.New(core)
+	names := newStatementNames()
+	first, second, third := newStatementRegistry(), newStatementRegistry(), newStatementRegistry()
+
+	// a connection preparing its statement again is no conflict
+	names.observe([][]byte{prepareRequest("users", "SELECT name FROM users WHERE id = $1")}, first, logger)
+	names.observe([][]byte{prepareRequest("users", "SELECT email FROM users WHERE id = $1")}, first, logger)
+	// nor is another connection preparing the same query, however written
+	names.observe([][]byte{prepareRequest("users", "SELECT email\n FROM users WHERE id = $1;")}, second, logger)
+	// nor are the unnamed statements
+	names.observe([][]byte{prepareRequest("", "SELECT 1")}, second, logger)
+	names.observe([][]byte{prepareRequest("", "SELECT 2")}, third, logger)
+	if logs.Len() != 0 {
+		t.Fatalf("warned %d times before any conflict", logs.Len())
+	}
+
+	names.observe([][]byte{prepareRequest("users", "SELECT id FROM users WHERE email = $1")}, third, logger)
+	names.observe([][]byte{prepareRequest("users", "SELECT name FROM users WHERE id = $1")}, first, logger)
+	if logs.Len() != 1 {
+		t.Fatalf("warned %d times, want once per name", logs.Len())
+	}
+	if fields := logs.All()[0].ContextMap(); fields["statement"] != "users" || fields["query"] != "SELECT id FROM users WHERE email = $1" {
+		t.Errorf("warned about %v, want the statement users", fields)
+	}
+
+	// the statements of a closed connection are forgotten
+	names.forget(first)
+	names.observe([][]byte{prepareRequest("orders", "SELECT id FROM orders")}, first, logger)
+	names.forget(first)
+	names.observe([][]byte{prepareRequest("orders", "SELECT total FROM orders")}, second, logger)
+	if logs.Len() != 1 {
+		t.Errorf("warned about the statement of a closed connection")
+	}
+
+	// a new test-set warns again
+	names.reset()
+	names.observe([][]byte{prepareRequest("users", "SELECT name FROM users WHERE id = $1")}, first, logger)
+	names.observe([][]byte{prepareRequest("users", "SELECT id FROM users WHERE email = $1")}, second, logger)
+	if logs.Len() != 2 {
+		t.Errorf("warned %d times after the reset, want 2", logs.Len())
+	}
+
+	// the parser may record without tracking the names
+	var untracked *statementNames
+	untracked.observe([][]byte{prepareRequest("users", "SELECT 1")}, first, logger)
+}
@@ -0,0 +1,125 @@
+package postgresparser
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+
+	"go.keploy.io/server/pkg/proxy/util"
+)
+
+// errTooManyPendingRequests closes the connections of the clients whose
+// requests waiting to be matched exceed the configured size.
+var errTooManyPendingRequests = errors.New("too many postgres requests are waiting to be matched")
+
+// messageBuffer joins the chunks read from a connection until they end at a
+// message boundary. Clients and proxies may deliver the messages in chunks as
+// small as a byte, leaving the messages split across the reads. Each chunk is
+// scanned once from the last boundary, rather than re-splitting the whole
+// buffer on every read, which grows quadratic on the long COPY streams.
+type messageBuffer struct {
+	bytes []byte
+	// boundary is the end of the last whole message of the buffer
+	boundary int
+	// last is the type of the last whole message, 0 after an untyped packet
+	last byte
+	// types marks the types of the whole messages of the buffer
+	types [256]bool
+}
+
+// write appends the chunk to the buffer and scans the messages it completes.
+func (b *messageBuffer) write(chunk []byte) {
+	b.bytes = append(b.bytes, chunk...)
+	for b.boundary < len(b.bytes) {
+		if b.boundary == 0 && len(b.bytes) >= 8 && isUntypedPacket(b.bytes) {
+			size := int(binary.BigEndian.Uint32(b.bytes[0:4]))
+			if size < 8 || size > len(b.bytes) {
+				return
+			}
+			b.boundary, b.last = size, 0
+			continue
+		}
+		bodyLen, err := readMessageBodyLen(b.bytes, b.boundary)
+		if err != nil {
+			return
+		}
+		b.last = b.bytes[b.boundary]
+		b.types[b.last] = true
+		b.boundary += 5 + bodyLen
+	}
+}
+
+// whole reports whether the buffer ends at a message boundary, so that it can
+// be parsed.
+func (b *messageBuffer) whole() bool {
+	return len(b.bytes) > 0 && b.boundary == len(b.bytes)
+}
+
+// holds reports whether one of the whole messages of the buffer has the type.
+func (b *messageBuffer) holds(msgType byte) bool {
+	return b.types[msgType]
+}
+
+// endsCopyIn reports whether the buffer holds the CopyDone or the CopyFail
+// ending a COPY FROM STDIN stream.
+func (b *messageBuffer) endsCopyIn() bool {
+	return b.holds('c') || b.holds('f')
+}
+
+// endsPipeline reports whether the last message of the buffer completes an
+// extended query pipeline, that is whether the server answers it. The Parse,
+// Bind, Describe, Execute and Close messages are only answered once the client
+// sends the Sync or the Flush following them.
+func (b *messageBuffer) endsPipeline() bool {
+	switch b.last {
+	case 'P', 'B', 'D', 'E', 'C':
+		return false
+	}
+	return true
+}
+
+func (b *messageBuffer) reset() {
+	*b = messageBuffer{}
+}
+
+// isUntypedPacket reports whether the buffer starts with one of the packets
+// sent before the startup completes, which carry no message type.
+func isUntypedPacket(buffer []byte) bool {
+	switch binary.BigEndian.Uint32(buffer[4:8]) {
+	case 196608, sslRequestNumber, cancelRequestCode, gssEncReqNumber:
+		return true
+	}
+	return false
+}
+
+// readWholeMessages reads from the connection until the buffer ends at a
+// message boundary, so that a packet split across the TCP segments, such as a
+// large startup message, is handled at once. It fails with
+// errTooManyPendingRequests once the buffer outgrows the limit.
+func readWholeMessages(conn net.Conn, buffer []byte, limit int) ([]byte, error) {
+	if len(buffer) == 0 {
+		return buffer, nil
+	}
+	var pending messageBuffer
+	pending.write(buffer)
+	for !pending.whole() {
+		if len(pending.bytes) > limit {
+			return pending.bytes, errTooManyPendingRequests
+		}
+		chunk, err := util.ReadBytes(conn)
+		pending.write(chunk)
+		if err != nil {
+			return pending.bytes, err
+		}
+	}
+	return pending.bytes, nil
+}
+
+// pendingSize returns the size of the requests read from a connection.
+func pendingSize(requests [][]byte) int {
+	size := 0
+	for _, request := range requests {
+		size += len(request)
+	}
+	return size
+}
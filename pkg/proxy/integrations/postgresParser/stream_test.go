@@ -0,0 +1,165 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestMessageBufferByteByByte(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		request []byte
+	}{
+		{name: "startup", request: startupRequest("-c search_path=app")},
+		{name: "ssl request", request: (&pgproto3.SSLRequest{}).Encode(nil)},
+		{name: "pipeline", request: extendedQueryRequest("SELECT name FROM users WHERE id = $1", "42")},
+		{name: "simple query", request: SimpleQueryRequest("SELECT 1")[0]},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			boundaries := map[int]bool{len(tt.request): true}
+			if !isUntypedPacket(tt.request) {
+				for i := 0; i < len(tt.request); {
+					bodyLen, _ := readMessageBodyLen(tt.request, i)
+					i += 5 + bodyLen
+					boundaries[i] = true
+				}
+			}
+			var buffer messageBuffer
+			for i := range tt.request {
+				buffer.write(tt.request[i : i+1])
+				if buffer.whole() != boundaries[i+1] {
+					t.Fatalf("got whole %v after %d of %d bytes", buffer.whole(), i+1, len(tt.request))
+				}
+			}
+			if !buffer.whole() {
+				t.Fatalf("got a partial buffer once the %d bytes are written", len(tt.request))
+			}
+			if !bytes.Equal(buffer.bytes, tt.request) {
+				t.Errorf("got %q, want %q", buffer.bytes, tt.request)
+			}
+		})
+	}
+}
+
+func TestMessageBufferPipelines(t *testing.T) {
+	var buffer messageBuffer
+	buffer.write(encodeMessages(&pgproto3.Parse{Query: "SELECT 1"}, &pgproto3.Bind{}))
+	if !buffer.whole() || buffer.endsPipeline() {
+		t.Errorf("got whole %v and ending %v for a pipeline without its Sync, want true and false", buffer.whole(), buffer.endsPipeline())
+	}
+	buffer.write(encodeMessages(&pgproto3.Execute{}, &pgproto3.Sync{}))
+	if !buffer.endsPipeline() || !buffer.holds('E') || buffer.holds('Q') {
+		t.Errorf("got ending %v, Execute %v and Query %v, want true, true and false", buffer.endsPipeline(), buffer.holds('E'), buffer.holds('Q'))
+	}
+}
+
+func TestMessageBufferEndsCopyIn(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		chunks [][]byte
+		want   bool
+	}{
+		{name: "rows only", chunks: [][]byte{encodeMessages(&pgproto3.CopyData{Data: []byte("1\n")}, &pgproto3.CopyData{Data: []byte("2\n")})}},
+		{name: "CopyDone", chunks: [][]byte{encodeMessages(&pgproto3.CopyData{Data: []byte("1\n")}), encodeMessages(&pgproto3.CopyDone{})}, want: true},
+		{name: "CopyFail", chunks: [][]byte{encodeMessages(&pgproto3.CopyData{Data: []byte("1\n")}, &pgproto3.CopyFail{Message: "aborted"})}, want: true},
+		{name: "partial CopyDone", chunks: [][]byte{encodeMessages(&pgproto3.CopyData{Data: []byte("1\n")}), encodeMessages(&pgproto3.CopyDone{})[:3]}},
+		// the bytes of a row are no message of their own
+		{name: "row holding a CopyDone", chunks: [][]byte{encodeMessages(&pgproto3.CopyData{Data: encodeMessages(&pgproto3.CopyDone{})})}},
+	} {
+		var buffer messageBuffer
+		for _, chunk := range tt.chunks {
+			buffer.write(chunk)
+		}
+		if got := buffer.endsCopyIn(); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestJoinPipelines(t *testing.T) {
+	pipeline := extendedQueryRequest("SELECT name FROM users WHERE id = $1", "42")
+	// the client flushes the pipeline message by message
+	var flushes [][]byte
+	forEachMessage([][]byte{pipeline}, func(msgType byte, body []byte) {
+		flushes = append(flushes, encodeMessages(rawMessage{msgType, body}))
+	})
+
+	joined, complete := joinPipelines(append(flushes, SimpleQueryRequest("COMMIT")...))
+	if want := [][]byte{pipeline, SimpleQueryRequest("COMMIT")[0]}; !complete || !reflect.DeepEqual(joined, want) {
+		t.Errorf("got %q, complete %v, want %q", joined, complete, want)
+	}
+
+	joined, complete = joinPipelines(flushes[:3])
+	if want := bytes.Join(flushes[:3], nil); complete || len(joined) != 1 || !bytes.Equal(joined[0], want) {
+		t.Errorf("got %q, complete %v, want the incomplete pipeline %q", joined, complete, want)
+	}
+}
+
+// rawMessage encodes a message of the type and body.
+type rawMessage struct {
+	msgType byte
+	body    []byte
+}
+
+func (m rawMessage) Encode(dst []byte) []byte {
+	dst = append(dst, m.msgType)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(len(m.body)+4))
+	return append(dst, m.body...)
+}
+
+func TestReadWholeMessages(t *testing.T) {
+	request := extendedQueryRequest("SELECT name FROM users WHERE id = $1", "42")
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		defer client.Close()
+		for i := 5; i < len(request); i += 7 {
+			end := i + 7
+			if end > len(request) {
+				end = len(request)
+			}
+			if _, err := client.Write(request[i:end]); err != nil {
+				return
+			}
+		}
+	}()
+
+	got, err := readWholeMessages(server, request[:5], 1<<20)
+	if err != nil {
+		t.Fatalf("failed to read the messages: %v", err)
+	}
+	if !bytes.Equal(got, request) {
+		t.Errorf("got %q, want %q", got, request)
+	}
+}
+
+func TestReadWholeMessagesLimit(t *testing.T) {
+	request := extendedQueryRequest("SELECT name FROM users WHERE id = $1", "42")
+	client, server := net.Pipe()
+	defer server.Close()
+	go func() {
+		defer client.Close()
+		client.Write(request[10:])
+	}()
+
+	got, err := readWholeMessages(server, request[:10], 8)
+	if !errors.Is(err, errTooManyPendingRequests) {
+		t.Fatalf("got the error %v, want %v", err, errTooManyPendingRequests)
+	}
+	// the bytes read so far are returned, to be passed through
+	if !bytes.Equal(got, request[:10]) {
+		t.Errorf("got %q, want %q", got, request[:10])
+	}
+
+	// the whole messages within the buffer are not limited
+	got, err = readWholeMessages(server, request, 8)
+	if err != nil || !bytes.Equal(got, request) {
+		t.Errorf("got %q: %v, want the whole request", got, err)
+	}
+}
@@ -0,0 +1,181 @@
+package postgresparser
+
+import (
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// syncBoundaries returns the number of ReadyForQuery messages the server
+// sends for the requests of an extended query pipeline, one per Sync and per
+// simple query. It returns zero when the requests hold no Sync.
+func syncBoundaries(requestBuffers [][]byte) int {
+	syncs, queries := 0, 0
+	forEachMessage(requestBuffers, func(msgType byte, body []byte) {
+		switch msgType {
+		case 'S':
+			syncs++
+		case 'Q':
+			queries++
+		}
+	})
+	if syncs == 0 {
+		return 0
+	}
+	return syncs + queries
+}
+
+// alignReadyForQuery makes the replayed response end every pipeline delimited
+// by a Sync of the requests with a ReadyForQuery. The pipelines end where they
+// were recorded to, or at the ReadyForQuery messages of the response for the
+// mocks recorded without their pipelines. The messages recorded after the
+// last expected ReadyForQuery belong to pipelines the client has not sent yet
+// and are left out, except for the notifications pushed by the server
+// meanwhile, while the missing ReadyForQuery messages are appended with the
+// last recorded transaction status.
+func alignReadyForQuery(response []byte, boundaries int, pipelines []int) []byte {
+	if boundaries == 0 {
+		return response
+	}
+	if end, ok := pipelinesEnd(response, boundaries, pipelines); ok {
+		return response[:end+asynchronousPrefixLen(response[end:])]
+	}
+	seen := 0
+	txStatus := byte('I')
+	for i := 0; i+5 <= len(response); {
+		bodyLen, err := readMessageBodyLen(response, i)
+		if err != nil || i+5+bodyLen > len(response) {
+			return response
+		}
+		msgType := response[i]
+		i += 5 + bodyLen
+		if msgType != 'Z' {
+			continue
+		}
+		if bodyLen > 0 {
+			txStatus = response[i-1]
+		}
+		seen++
+		if seen == boundaries {
+			return response[:i+asynchronousPrefixLen(response[i:])]
+		}
+	}
+	aligned := make([]byte, len(response), len(response)+(boundaries-seen)*6)
+	copy(aligned, response)
+	for ; seen < boundaries; seen++ {
+		aligned = (&pgproto3.ReadyForQuery{TxStatus: txStatus}).Encode(aligned)
+	}
+	return aligned
+}
+
+// pipelinesEnd returns the offset of the response following the messages of
+// its first recorded pipelines, as many as the boundaries. It reports false
+// when fewer pipelines were recorded or when they don't end the response
+// with a ReadyForQuery.
+func pipelinesEnd(response []byte, boundaries int, pipelines []int) (int, bool) {
+	if len(pipelines) < boundaries {
+		return 0, false
+	}
+	messages := 0
+	for _, count := range pipelines[:boundaries] {
+		messages += count
+	}
+	last := 0
+	for i := 0; i+5 <= len(response) && messages > 0; messages-- {
+		bodyLen, err := readMessageBodyLen(response, i)
+		if err != nil || i+5+bodyLen > len(response) {
+			return 0, false
+		}
+		last = i
+		i += 5 + bodyLen
+		if messages == 1 {
+			return i, response[last] == 'Z'
+		}
+	}
+	return 0, false
+}
+
+// recordPipelines marks the first response of an exchange whose requests hold
+// Sync messages with the number of messages answering each of its pipelines,
+// for the replay to end them where they ended.
+func recordPipelines(pgRequests []models.Backend, pgResponses []models.Frontend) {
+	if len(pgResponses) == 0 {
+		return
+	}
+	requestBuffers := make([][]byte, 0, len(pgRequests))
+	for _, request := range pgRequests {
+		buffer, err := BackendWireBytes(request)
+		if err != nil {
+			return
+		}
+		requestBuffers = append(requestBuffers, buffer)
+	}
+	if syncBoundaries(requestBuffers) == 0 {
+		return
+	}
+	response, err := responsesWireBytes(pgResponses)
+	if err != nil {
+		return
+	}
+	pipelines := []int{}
+	messages := 0
+	for i := 0; i+5 <= len(response); {
+		bodyLen, err := readMessageBodyLen(response, i)
+		if err != nil || i+5+bodyLen > len(response) {
+			return
+		}
+		messages++
+		if response[i] == 'Z' {
+			pipelines = append(pipelines, messages)
+			messages = 0
+		}
+		i += 5 + bodyLen
+	}
+	if len(pipelines) > 0 {
+		pgResponses[0].Pipelines = pipelines
+	}
+}
+
+// recordedPipelines returns the pipelines recorded for the responses.
+func recordedPipelines(pgResponses []models.Frontend) []int {
+	for _, response := range pgResponses {
+		if len(response.Pipelines) > 0 {
+			return response.Pipelines
+		}
+	}
+	return nil
+}
+
+// lastTxStatus returns the transaction status of the last ReadyForQuery of
+// the response, or status when the response holds none.
+func lastTxStatus(response []byte, status byte) byte {
+	for i := 0; i+5 <= len(response); {
+		bodyLen, err := readMessageBodyLen(response, i)
+		if err != nil || i+5+bodyLen > len(response) {
+			break
+		}
+		if response[i] == 'Z' && bodyLen > 0 {
+			status = response[i+5]
+		}
+		i += 5 + bodyLen
+	}
+	return status
+}
+
+// joinPipelines joins the request buffers of a pipeline the client wrote in
+// several flushes, so that it is matched as the single request it was
+// recorded as. It reports whether the last pipeline is complete.
+func joinPipelines(requestBuffers [][]byte) ([][]byte, bool) {
+	joined := make([][]byte, 0, len(requestBuffers))
+	var pending messageBuffer
+	for _, buffer := range requestBuffers {
+		pending.write(buffer)
+		if !pending.whole() || pending.endsPipeline() {
+			joined = append(joined, pending.bytes)
+			pending.reset()
+		}
+	}
+	if len(pending.bytes) > 0 {
+		return append(joined, pending.bytes), false
+	}
+	return joined, true
+}
@@ -0,0 +1,194 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+// pipelineResponse returns the response of a pipeline running a statement,
+// ended by the ReadyForQuery of the transaction status.
+func pipelineResponse(tag string, txStatus byte) []byte {
+	return encodeMessages(
+		&pgproto3.ParseComplete{},
+		&pgproto3.BindComplete{},
+		&pgproto3.CommandComplete{CommandTag: []byte(tag)},
+		&pgproto3.ReadyForQuery{TxStatus: txStatus},
+	)
+}
+
+func TestSyncBoundaries(t *testing.T) {
+	pipeline := extendedQueryRequest("UPDATE accounts SET balance = $1", "10")
+	for _, tt := range []struct {
+		name     string
+		requests [][]byte
+		want     int
+	}{
+		{name: "pipeline", requests: [][]byte{pipeline}, want: 1},
+		{name: "pipelines", requests: [][]byte{append(append([]byte{}, pipeline...), pipeline...)}, want: 2},
+		{name: "pipelines in several buffers", requests: [][]byte{pipeline, pipeline, pipeline}, want: 3},
+		{name: "pipeline and simple query", requests: [][]byte{pipeline, SimpleQueryRequest("COMMIT")[0]}, want: 2},
+		{name: "simple query", requests: SimpleQueryRequest("SELECT 1")},
+		{name: "flushed without sync", requests: [][]byte{encodeMessages(&pgproto3.Parse{Query: "SELECT 1"}, &pgproto3.Flush{})}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := syncBoundaries(tt.requests); got != tt.want {
+				t.Errorf("got %d boundaries, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAlignReadyForQuery(t *testing.T) {
+	first := pipelineResponse("UPDATE 1", 'T')
+	second := pipelineResponse("UPDATE 2", 'T')
+	recorded := append(append([]byte{}, first...), second...)
+	notification := encodeMessages(&pgproto3.NotificationResponse{PID: 7, Channel: "events", Payload: "created"})
+
+	for _, tt := range []struct {
+		name       string
+		response   []byte
+		boundaries int
+		want       []byte
+	}{
+		{
+			name:       "as many pipelines as recorded",
+			response:   recorded,
+			boundaries: 2,
+			want:       recorded,
+		},
+		{
+			name:       "pipelines the client has not sent yet",
+			response:   recorded,
+			boundaries: 1,
+			want:       first,
+		},
+		{
+			name:       "notification kept after the last pipeline",
+			response:   append(append(append([]byte{}, first...), notification...), second...),
+			boundaries: 1,
+			want:       append(append([]byte{}, first...), notification...),
+		},
+		{
+			name:       "missing ReadyForQuery appended",
+			response:   first,
+			boundaries: 3,
+			want:       append(append([]byte{}, first...), encodeMessages(&pgproto3.ReadyForQuery{TxStatus: 'T'}, &pgproto3.ReadyForQuery{TxStatus: 'T'})...),
+		},
+		{
+			name:       "response without ReadyForQuery",
+			response:   encodeMessages(&pgproto3.ParseComplete{}, &pgproto3.BindComplete{}),
+			boundaries: 1,
+			want:       encodeMessages(&pgproto3.ParseComplete{}, &pgproto3.BindComplete{}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		},
+		{
+			name:     "no Sync",
+			response: recorded,
+			want:     recorded,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := alignReadyForQuery(tt.response, tt.boundaries, nil); !bytes.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// pipelinedStatement returns the messages of the statement run and closed
+// without a Sync.
+func pipelinedStatement(name, query string) []byte {
+	return encodeMessages(
+		&pgproto3.Parse{Name: name, Query: query},
+		&pgproto3.Bind{PreparedStatement: name},
+		&pgproto3.Describe{ObjectType: 'S', Name: name},
+		&pgproto3.Execute{},
+		&pgproto3.Close{Object_Type: 'S', Name: name},
+	)
+}
+
+// TestPipelinedStatementsRecordedAndReplayed records the statements the
+// client pipelines in two flushes before a single Sync as one request, then
+// replays its response once the Sync comes.
+func TestPipelinedStatementsRecordedAndReplayed(t *testing.T) {
+	first := pipelinedStatement("first", "SELECT 1")
+	second := append(pipelinedStatement("second", "SELECT 2"), (&pgproto3.Sync{}).Encode(nil)...)
+	response := encodeMessages(
+		&pgproto3.ParseComplete{},
+		&pgproto3.BindComplete{},
+		&pgproto3.ParameterDescription{},
+		&pgproto3.NoData{},
+		&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")},
+		&pgproto3.CloseComplete{},
+		&pgproto3.ParseComplete{},
+		&pgproto3.BindComplete{},
+		&pgproto3.ParameterDescription{},
+		&pgproto3.NoData{},
+		&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")},
+		&pgproto3.CloseComplete{},
+		&pgproto3.ReadyForQuery{TxStatus: 'I'},
+	)
+
+	mocks := recordExchanges(t, PostgresOptions{}, pgExchange{request: first}, pgExchange{request: second, response: response, pause: 50 * time.Millisecond})
+	var pipeline *models.Mock
+	for _, mock := range mocks {
+		if len(mock.Spec.PostgresRequests) > 0 && len(mock.Spec.PostgresRequests[0].Parses) > 0 {
+			pipeline = mock
+		}
+	}
+	if pipeline == nil || len(pipeline.Spec.PostgresRequests) != 1 {
+		t.Fatalf("recorded the mocks %+v, want the pipeline as a single request", mocks)
+	}
+	request := pipeline.Spec.PostgresRequests[0]
+	if len(request.Describes) != 2 || request.Describes[1].Name != "second" || len(request.Closes) != 2 || request.Closes[0].Name != "first" {
+		t.Errorf("recorded the describes %+v and the closes %+v, want those of both statements", request.Describes, request.Closes)
+	}
+	if got, err := PostgresDecoderBackend(request); err != nil || !bytes.Equal(got, append(append([]byte{}, first...), second...)) {
+		t.Errorf("decoded the request %q (%v), want the pipeline %q", got, err, append(append([]byte{}, first...), second...))
+	}
+
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	h.SetConfigMocks(mocks)
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{})
+	client, proxied := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- decodePostgresOutgoing(startupRequest(""), proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+	}()
+	if err := client.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set the deadline: %v", err)
+	}
+	started := encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	if _, err := io.ReadFull(client, make([]byte, len(started))); err != nil {
+		t.Fatalf("failed to read the replayed startup: %v", err)
+	}
+	if _, err := client.Write(first); err != nil {
+		t.Fatalf("failed to write the first statement: %v", err)
+	}
+	// the first flush is left unanswered until the Sync
+	time.Sleep(50 * time.Millisecond)
+	if _, err := client.Write(second); err != nil {
+		t.Fatalf("failed to write the second statement: %v", err)
+	}
+	replayed := make([]byte, len(response))
+	if _, err := io.ReadFull(client, replayed); err != nil {
+		t.Fatalf("failed to read the replayed response: %v", err)
+	}
+	if !bytes.Equal(replayed, response) {
+		t.Errorf("replayed %q, want %q", replayed, response)
+	}
+	client.Close()
+	<-done
+}
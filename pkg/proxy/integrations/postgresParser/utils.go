@@ -1,11 +1,14 @@
 package postgresparser
 
 import (
+	"bytes"
 	"encoding/base64"
+	"encoding/binary"
 	"math"
 
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgproto3/v2"
 	"go.keploy.io/server/pkg/hooks"
@@ -28,7 +31,10 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 	var resbuffer []byte
 	// list of packets available in the buffer
 	packets := response.PacketTypes
-	var cc, dtr, ps int = 0, 0, 0
+	var cc, dtr, ps, pd, cd int = 0, 0, 0, 0, 0
+	// the messages repeated in a buffer of pipelined responses are replayed
+	// from their lists, in the order they were received
+	var er, nr, rd, rq, an int = 0, 0, 0, 0, 0
 	for _, packet := range packets {
 		var msg pgproto3.BackendMessage
 
@@ -40,10 +46,15 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 		case string('3'):
 			msg = &pgproto3.CloseComplete{}
 		case string('A'):
+			notification := response.NotificationResponse
+			if an < len(response.NotificationResponses) {
+				notification = response.NotificationResponses[an]
+				an++
+			}
 			msg = &pgproto3.NotificationResponse{
-				PID:     response.NotificationResponse.PID,
-				Channel: response.NotificationResponse.Channel,
-				Payload: response.NotificationResponse.Payload,
+				PID:     notification.PID,
+				Channel: notification.Channel,
+				Payload: notification.Payload,
 			}
 		case string('c'):
 			msg = &pgproto3.CopyDone{}
@@ -56,6 +67,12 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 			msg = &pgproto3.CopyData{
 				Data: response.CopyData.Data,
 			}
+			if cd < len(response.CopyDatas) {
+				msg = &pgproto3.CopyData{
+					Data: response.CopyDatas[cd].Data,
+				}
+				cd++
+			}
 		case string('D'):
 			msg = &pgproto3.DataRow{
 				RowValues: response.DataRows[dtr].RowValues,
@@ -63,24 +80,30 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 			}
 			dtr++
 		case string('E'):
+			errorResponse := response.ErrorResponse
+			if er < len(response.ErrorResponses) {
+				errorResponse = response.ErrorResponses[er]
+				er++
+			}
 			msg = &pgproto3.ErrorResponse{
-				Severity:         response.ErrorResponse.Severity,
-				Code:             response.ErrorResponse.Code,
-				Message:          response.ErrorResponse.Message,
-				Detail:           response.ErrorResponse.Detail,
-				Hint:             response.ErrorResponse.Hint,
-				Position:         response.ErrorResponse.Position,
-				InternalPosition: response.ErrorResponse.InternalPosition,
-				InternalQuery:    response.ErrorResponse.InternalQuery,
-				Where:            response.ErrorResponse.Where,
-				SchemaName:       response.ErrorResponse.SchemaName,
-				TableName:        response.ErrorResponse.TableName,
-				ColumnName:       response.ErrorResponse.ColumnName,
-				DataTypeName:     response.ErrorResponse.DataTypeName,
-				ConstraintName:   response.ErrorResponse.ConstraintName,
-				File:             response.ErrorResponse.File,
-				Line:             response.ErrorResponse.Line,
-				Routine:          response.ErrorResponse.Routine,
+				Severity:            errorResponse.Severity,
+				SeverityUnlocalized: errorResponse.SeverityUnlocalized,
+				Code:                errorResponse.Code,
+				Message:             errorResponse.Message,
+				Detail:              errorResponse.Detail,
+				Hint:                errorResponse.Hint,
+				Position:            errorResponse.Position,
+				InternalPosition:    errorResponse.InternalPosition,
+				InternalQuery:       errorResponse.InternalQuery,
+				Where:               errorResponse.Where,
+				SchemaName:          errorResponse.SchemaName,
+				TableName:           errorResponse.TableName,
+				ColumnName:          errorResponse.ColumnName,
+				DataTypeName:        errorResponse.DataTypeName,
+				ConstraintName:      errorResponse.ConstraintName,
+				File:                errorResponse.File,
+				Line:                errorResponse.Line,
+				Routine:             errorResponse.Routine,
 			}
 		case string('G'):
 			msg = &pgproto3.CopyInResponse{
@@ -102,24 +125,30 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 		case string('n'):
 			msg = &pgproto3.NoData{}
 		case string('N'):
+			noticeResponse := response.NoticeResponse
+			if nr < len(response.NoticeResponses) {
+				noticeResponse = response.NoticeResponses[nr]
+				nr++
+			}
 			msg = &pgproto3.NoticeResponse{
-				Severity:         response.NoticeResponse.Severity,
-				Code:             response.NoticeResponse.Code,
-				Message:          response.NoticeResponse.Message,
-				Detail:           response.NoticeResponse.Detail,
-				Hint:             response.NoticeResponse.Hint,
-				Position:         response.NoticeResponse.Position,
-				InternalPosition: response.NoticeResponse.InternalPosition,
-				InternalQuery:    response.NoticeResponse.InternalQuery,
-				Where:            response.NoticeResponse.Where,
-				SchemaName:       response.NoticeResponse.SchemaName,
-				TableName:        response.NoticeResponse.TableName,
-				ColumnName:       response.NoticeResponse.ColumnName,
-				DataTypeName:     response.NoticeResponse.DataTypeName,
-				ConstraintName:   response.NoticeResponse.ConstraintName,
-				File:             response.NoticeResponse.File,
-				Line:             response.NoticeResponse.Line,
-				Routine:          response.NoticeResponse.Routine,
+				Severity:            noticeResponse.Severity,
+				SeverityUnlocalized: noticeResponse.SeverityUnlocalized,
+				Code:                noticeResponse.Code,
+				Message:             noticeResponse.Message,
+				Detail:              noticeResponse.Detail,
+				Hint:                noticeResponse.Hint,
+				Position:            noticeResponse.Position,
+				InternalPosition:    noticeResponse.InternalPosition,
+				InternalQuery:       noticeResponse.InternalQuery,
+				Where:               noticeResponse.Where,
+				SchemaName:          noticeResponse.SchemaName,
+				TableName:           noticeResponse.TableName,
+				ColumnName:          noticeResponse.ColumnName,
+				DataTypeName:        noticeResponse.DataTypeName,
+				ConstraintName:      noticeResponse.ConstraintName,
+				File:                noticeResponse.File,
+				Line:                noticeResponse.Line,
+				Routine:             noticeResponse.Routine,
 			}
 
 		case string('R'):
@@ -161,14 +190,33 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 			msg = &pgproto3.ParameterDescription{
 				ParameterOIDs: response.ParameterDescription.ParameterOIDs,
 			}
+			if pd < len(response.ParameterDescriptions) {
+				msg = &pgproto3.ParameterDescription{
+					ParameterOIDs: response.ParameterDescriptions[pd].ParameterOIDs,
+				}
+				pd++
+			}
 		case string('T'):
 			msg = &pgproto3.RowDescription{
 				Fields: response.RowDescription.Fields,
 			}
+			if rd < len(response.RowDescriptions) {
+				msg = &pgproto3.RowDescription{
+					Fields: response.RowDescriptions[rd].Fields,
+				}
+				rd++
+			}
 		case string('V'):
+			result := response.FunctionCallResponse.Result
+			if result == nil && !response.FunctionCallResultNull {
+				result = []byte{}
+			}
 			msg = &pgproto3.FunctionCallResponse{
-				Result: response.FunctionCallResponse.Result,
+				Result: result,
 			}
+		case string('v'):
+			negotiation := response.NegotiateProtocolVersion
+			msg = negotiateProtocolVersion{&negotiation}
 		case string('W'):
 			msg = &pgproto3.CopyBothResponse{
 				OverallFormat:     response.CopyBothResponse.OverallFormat,
@@ -178,6 +226,12 @@ func PostgresDecoderFrontend(response models.Frontend) ([]byte, error) {
 			msg = &pgproto3.ReadyForQuery{
 				TxStatus: response.ReadyForQuery.TxStatus,
 			}
+			if rq < len(response.ReadyForQueries) {
+				msg = &pgproto3.ReadyForQuery{
+					TxStatus: response.ReadyForQueries[rq].TxStatus,
+				}
+				rq++
+			}
 		default:
 			return nil, fmt.Errorf("unknown message type: %q", packet)
 		}
@@ -195,7 +249,7 @@ func PostgresDecoderBackend(request models.Backend) ([]byte, error) {
 
 	var reqbuffer []byte
 	// list of packets available in the buffer
-	var b, e, p int = 0, 0, 0
+	var b, e, p, cd, ds, cl int = 0, 0, 0, 0, 0, 0
 	packets := request.PacketTypes
 	for _, packet := range packets {
 		var msg pgproto3.FrontendMessage
@@ -214,11 +268,25 @@ func PostgresDecoderBackend(request models.Backend) ([]byte, error) {
 				Object_Type: request.Close.Object_Type,
 				Name:        request.Close.Name,
 			}
+			if cl < len(request.Closes) {
+				msg = &pgproto3.Close{
+					Object_Type: request.Closes[cl].Object_Type,
+					Name:        request.Closes[cl].Name,
+				}
+			}
+			cl++
 		case string('D'):
 			msg = &pgproto3.Describe{
 				ObjectType: request.Describe.ObjectType,
 				Name:       request.Describe.Name,
 			}
+			if ds < len(request.Describes) {
+				msg = &pgproto3.Describe{
+					ObjectType: request.Describes[ds].ObjectType,
+					Name:       request.Describes[ds].Name,
+				}
+			}
+			ds++
 		case string('E'):
 			msg = &pgproto3.Execute{
 				Portal:  request.Executes[e].Portal,
@@ -240,6 +308,12 @@ func PostgresDecoderBackend(request models.Backend) ([]byte, error) {
 			msg = &pgproto3.CopyData{
 				Data: request.CopyData.Data,
 			}
+			if cd < len(request.CopyDatas) {
+				msg = &pgproto3.CopyData{
+					Data: request.CopyDatas[cd].Data,
+				}
+			}
+			cd++
 		case string('c'):
 			msg = &pgproto3.CopyDone{}
 		case string('H'):
@@ -307,7 +381,10 @@ func PostgresEncoder(buffer []byte) string {
 	return encoded
 }
 
-func findBinaryStreamMatch(tcsMocks []*models.Mock, requestBuffers [][]byte, logger *zap.Logger, isSorted bool) int {
+// findBinaryStreamMatch returns the index of the mock whose requests are the
+// most similar to the request buffers, and their similarity. The sorted mocks
+// need a similarity of 0.78 at least, the others of minSimilarity.
+func findBinaryStreamMatch(tcsMocks []*models.Mock, requestBuffers [][]byte, logger *zap.Logger, isSorted bool, minSimilarity float64) (int, float64) {
 
 	mxSim := -1.0
 	mxIdx := -1
@@ -327,7 +404,7 @@ func findBinaryStreamMatch(tcsMocks []*models.Mock, requestBuffers [][]byte, log
 					encoded64, err = PostgresDecoder(mock.Spec.PostgresRequests[requestIndex].Payload)
 					if err != nil {
 						logger.Debug("Error while decoding postgres request", zap.Error(err))
-						return -1
+						return -1, 0
 					}
 				}
 				var similarity1, similarity2 float64
@@ -356,11 +433,55 @@ func findBinaryStreamMatch(tcsMocks []*models.Mock, requestBuffers [][]byte, log
 			mxIdx = -1
 		}
 	} else {
-		if mxIdx != -1 {
+		if mxIdx != -1 && mxSim >= minSimilarity {
 			logger.Debug("Matched with Unsorted Stream", zap.Float64("similarity", mxSim))
+		} else {
+			mxIdx = -1
+		}
+	}
+	return mxIdx, mxSim
+}
+
+// findExactMatches returns the indexes of the mocks whose recorded requests
+// are byte for byte identical to the request buffers, in order, once their
+// statements are normalized by the match mode. The noise of the requests, the
+// JSON paths and the ignored parameters, is left out of the comparison.
+func findExactMatches(tcsMocks []*models.Mock, requestBuffers [][]byte, noise requestNoise, mode MatchMode) []int {
+	matches := []int{}
+	for idx, mock := range tcsMocks {
+		if mock == nil || len(mock.Spec.PostgresRequests) != len(requestBuffers) {
+			continue
+		}
+		matched := true
+		for requestIndex, reqBuff := range requestBuffers {
+			if !requestEquals(mock.Spec.PostgresRequests[requestIndex], reqBuff, noise, mode) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			matches = append(matches, idx)
+		}
+	}
+	return matches
+}
+
+// requestEquals reports whether the recorded request, either its raw payload
+// or its structured form, is identical to the buffer once both have their
+// statements normalized by the match mode.
+func requestEquals(request models.Backend, buffer []byte, noise requestNoise, mode MatchMode) bool {
+	buffer = mode.normalize(noise.remove(buffer))
+	if request.Payload != "" {
+		encoded, err := PostgresDecoder(request.Payload)
+		if err == nil && bytes.Equal(mode.normalize(noise.remove(encoded)), buffer) {
+			return true
 		}
 	}
-	return mxIdx
+	if len(request.PacketTypes) == 0 {
+		return false
+	}
+	encoded, err := PostgresDecoderBackend(request)
+	return err == nil && bytes.Equal(mode.normalize(noise.remove(encoded)), buffer)
 }
 
 func CheckValidEncode(tcsMocks []*models.Mock, h *hooks.Hook, log *zap.Logger) {
@@ -409,12 +530,39 @@ func CheckValidEncode(tcsMocks []*models.Mock, h *hooks.Hook, log *zap.Logger) {
 	h.SetTcsMocks(tcsMocks)
 }
 
-func matchingReadablePG(requestBuffers [][]byte, logger *zap.Logger, h *hooks.Hook) (bool, []models.Frontend, error) {
+// filterDisabledMocks leaves out the mocks switched off by the user, which
+// stay in the mock file but must never answer a request.
+func filterDisabledMocks(mocks []*models.Mock) []*models.Mock {
+	filtered := make([]*models.Mock, 0, len(mocks))
+	for _, mock := range mocks {
+		if mock == nil || mock.Spec.Disabled {
+			continue
+		}
+		filtered = append(filtered, mock)
+	}
+	return filtered
+}
+
+// matchingReadablePG returns the recorded responses and requests of the mock
+// matching the requests, along with the latency the responses were recorded
+// with.
+func matchingReadablePG(requestBuffers [][]byte, conn connectionState, logger *zap.Logger, h *hooks.Hook, opts PostgresOptions) (bool, []models.Frontend, []models.Backend, time.Duration, error) {
+	replayed := func(requests string) int {
+		if conn.replays == nil {
+			return 1
+		}
+		return conn.replays.next(h.GetCurrentTestSet(), requests)
+	}
 	for {
 		tcsMocks, err := h.GetConfigMocks()
 		if err != nil {
-			return false, nil, fmt.Errorf("error while getting tcs mocks %v", err)
+			return false, nil, nil, 0, fmt.Errorf("error while getting tcs mocks %v", err)
 		}
+		tcsMocks = filterDisabledMocks(tcsMocks)
+		tcsMocks = filterMocksBySearchPath(tcsMocks, conn.searchPath)
+		tcsMocks = filterMocksByStartupOptions(tcsMocks, conn.startupOptions)
+		tcsMocks = filterMocksByPortal(tcsMocks, conn.portalQuery)
+		tcsMocks = filterMocksByStatements(tcsMocks, requestBuffers, conn.statements)
 
 		var isMatched, sortFlag bool = false, true
 		var sortedTcsMocks []*models.Mock
@@ -447,11 +595,11 @@ func matchingReadablePG(requestBuffers [][]byte, logger *zap.Logger, h *hooks.Ho
 						ssl := models.Frontend{
 							Payload: "Tg==",
 						}
-						return true, []models.Frontend{ssl}, nil
+						return true, []models.Frontend{ssl}, nil, 0, nil
 					case mock.Spec.PostgresRequests[requestIndex].Identfier == "StartupRequest" && isStartupPacket(reqBuff) && mock.Spec.PostgresRequests[requestIndex].Payload != "AAAACATSFi8=" && mock.Spec.PostgresResponses[requestIndex].AuthType == 10:
 						logger.Debug("CHANGING TO MD5 for Response", zap.String("mock", mock.Name), zap.String("Req", bufStr))
 						initMock.Spec.PostgresResponses[requestIndex].AuthType = 5
-						return true, initMock.Spec.PostgresResponses, nil
+						return true, initMock.Spec.PostgresResponses, initMock.Spec.PostgresRequests, recordedLatency(&initMock), nil
 					case len(encodedMock) > 0 && encodedMock[0] == 'p' && mock.Spec.PostgresRequests[requestIndex].PacketTypes[0] == "p" && reqBuff[0] == 'p':
 						logger.Debug("CHANGING TO MD5 for Request and Response", zap.String("mock", mock.Name), zap.String("Req", bufStr))
 
@@ -510,7 +658,7 @@ func matchingReadablePG(requestBuffers [][]byte, logger *zap.Logger, h *hooks.Ho
 								Value: "Etc/UTC",
 							},
 						}
-						return true, initMock.Spec.PostgresResponses, nil
+						return true, initMock.Spec.PostgresResponses, initMock.Spec.PostgresRequests, recordedLatency(&initMock), nil
 					}
 
 				}
@@ -521,14 +669,39 @@ func matchingReadablePG(requestBuffers [][]byte, logger *zap.Logger, h *hooks.Ho
 
 		isSorted := false
 		var idx int
+		var similarity float64
+		// whether the mock was only matched by similarity
+		fuzzy := false
+		// prefer the mocks recorded with exactly the same requests, replaying
+		// the repeated requests in the order they were recorded, and only
+		// then the templates of the requests recorded with other values
+		for _, mode := range opts.MatchMode.stages() {
+			if idx = findSequencedMatch(sortedTcsMocks, requestBuffers, opts.requestNoise(conn.statements), mode, replayed); idx != -1 {
+				isMatched = true
+				matchedMock = sortedTcsMocks[idx]
+				break
+			}
+			if idx = findSequencedMatch(tcsMocks, requestBuffers, opts.requestNoise(conn.statements), mode, replayed); idx != -1 {
+				isMatched = true
+				matchedMock = tcsMocks[idx]
+				break
+			}
+		}
+		if !isMatched {
+			if idx = findStartupMatch(tcsMocks, requestBuffers, opts.IgnoredStartupParameters); idx != -1 {
+				isMatched = true
+				matchedMock = tcsMocks[idx]
+			}
+		}
+
 		if !isMatched {
 			//use findBinaryMatch twice one for sorted and another for unsorted
 			// give more priority to sorted like if you find more than 0.5 in sorted then return that
 			if len(sortedTcsMocks) > 0 {
 				isSorted = true
-				idx = findBinaryStreamMatch(sortedTcsMocks, requestBuffers, logger, isSorted)
+				idx, similarity = findBinaryStreamMatch(sortedTcsMocks, requestBuffers, logger, isSorted, opts.MinSimilarity)
 				if idx != -1 {
-					isMatched = true
+					isMatched, fuzzy = true, true
 					matchedMock = tcsMocks[idx]
 				}
 			}
@@ -536,13 +709,21 @@ func matchingReadablePG(requestBuffers [][]byte, logger *zap.Logger, h *hooks.Ho
 
 		if !isMatched {
 			isSorted = false
-			idx = findBinaryStreamMatch(tcsMocks, requestBuffers, logger, isSorted)
+			idx, similarity = findBinaryStreamMatch(tcsMocks, requestBuffers, logger, isSorted, opts.MinSimilarity)
 			if idx != -1 {
-				isMatched = true
+				isMatched, fuzzy = true, true
 				matchedMock = tcsMocks[idx]
 			}
 		}
 
+		if fuzzy {
+			reportFuzzyMatch(FuzzyMatch{
+				MockName:   matchedMock.Name,
+				Similarity: similarity,
+				Requests:   requestBuffers,
+			}, logger, opts.FuzzyMatchReporter)
+		}
+
 		if isMatched {
 			logger.Debug("Matched mock", zap.String("mock", matchedMock.Name))
 			if matchedMock.TestModeInfo.IsFiltered {
@@ -553,13 +734,15 @@ func matchingReadablePG(requestBuffers [][]byte, logger *zap.Logger, h *hooks.Ho
 				if !isUpdated {
 					continue
 				}
+			} else {
+				h.UpdateConsumedMocks(matchedMock.Name, false)
 			}
-			return true, matchedMock.Spec.PostgresResponses, nil
+			return true, matchedMock.Spec.PostgresResponses, matchedMock.Spec.PostgresRequests, recordedLatency(matchedMock), nil
 		}
 
 		break
 	}
-	return false, nil, nil
+	return false, nil, nil, 0, nil
 }
 
 func FuzzyCheck(encoded, reqBuff []byte) float64 {
@@ -576,3 +759,17 @@ func max(a, b float64) float64 {
 	}
 	return b
 }
+
+// readMessageBodyLen returns the body length of the message which starts at
+// offset i of the buffer. It fails when the buffer is too short to hold the
+// length prefix or the complete message body.
+func readMessageBodyLen(buffer []byte, i int) (int, error) {
+	if len(buffer) < i+5 {
+		return 0, fmt.Errorf("buffer of length %d is truncated at the length prefix of the message at offset %d", len(buffer), i)
+	}
+	bodyLen := int(binary.BigEndian.Uint32(buffer[i+1:i+5])) - 4
+	if bodyLen < 0 || len(buffer) < i+5+bodyLen {
+		return 0, fmt.Errorf("buffer of length %d is shorter than the message of body length %d at offset %d", len(buffer), bodyLen, i)
+	}
+	return bodyLen, nil
+}
@@ -0,0 +1,52 @@
+package postgresparser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+)
+
+func TestReadMessageBodyLen(t *testing.T) {
+	query := (&pgproto3.Query{String: "SELECT 1"}).Encode(nil)
+	sync := (&pgproto3.Sync{}).Encode(nil)
+	buffer := append(append([]byte{}, query...), sync...)
+
+	bodyLen, err := readMessageBodyLen(buffer, 0)
+	if err != nil || bodyLen != len(query)-5 {
+		t.Fatalf("read the body length %d: %v, want %d", bodyLen, err, len(query)-5)
+	}
+	if bodyLen, err := readMessageBodyLen(buffer, len(query)); err != nil || bodyLen != 0 {
+		t.Fatalf("read the body length %d of the Sync: %v, want 0", bodyLen, err)
+	}
+
+	tests := map[string][]byte{
+		// the buffer ends within the length prefix of the second message
+		"truncated length field":     append(append([]byte{}, query...), 'S', 0, 0),
+		"missing length field":       append(append([]byte{}, query...), 'S'),
+		"truncated body":             query[:len(query)-2],
+		"length shorter than itself": {'Q', 0, 0, 0, 2},
+	}
+	for name, truncated := range tests {
+		t.Run(name, func(t *testing.T) {
+			offset := 0
+			if len(truncated) > len(query) {
+				offset = len(query)
+			}
+			if _, err := readMessageBodyLen(truncated, offset); err == nil {
+				t.Fatalf("read the body length of the message at %d of % x", offset, truncated)
+			}
+		})
+	}
+}
+
+func TestNormalizeQueriesTruncatedLengthField(t *testing.T) {
+	query := (&pgproto3.Query{String: "SELECT  1;"}).Encode(nil)
+	for cut := 1; cut < 5; cut++ {
+		// a Parse message cut within its length prefix
+		buffer := append(append([]byte{}, query...), []byte{'P', 0, 0, 0, 20}[:cut]...)
+		if got := normalizeQueries(buffer); !bytes.Equal(got, buffer) {
+			t.Errorf("normalized the buffer truncated after %d bytes of its length field into % x", cut, got)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package postgresparser
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// QueryMatch is the outcome of matching requests with the recorded mocks
+// without a live connection.
+type QueryMatch struct {
+	// MockName is the mock recorded with exactly the same requests, empty
+	// when none was.
+	MockName string
+	// Closest is the most similar mock when no mock matches exactly, along
+	// with its similarity and the differences of its requests.
+	Closest    string
+	Similarity float64
+	Diff       []string
+}
+
+// Matched reports whether a mock was recorded with exactly the same requests.
+func (m *QueryMatch) Matched() bool {
+	return m.MockName != ""
+}
+
+// SimpleQueryRequest returns the request buffers of a simple query, to be
+// validated with ValidateRequests.
+func SimpleQueryRequest(query string) [][]byte {
+	return [][]byte{(&pgproto3.Query{String: query}).Encode(nil)}
+}
+
+// ValidateRequests reports whether the request buffers, such as a simple
+// query or a Parse/Bind/Execute sequence, would match one of the postgres
+// mocks of the test-set in test mode. It uses the matchers of the replay but
+// does not consume the mocks.
+func ValidateRequests(db platform.TestCaseDB, testSet string, requestBuffers [][]byte, logger *zap.Logger) (*QueryMatch, error) {
+	read, err := db.ReadConfigMocks(testSet)
+	if err != nil {
+		return nil, err
+	}
+	mocks := make([]*models.Mock, 0, len(read))
+	for _, kind := range read {
+		if mock, ok := kind.(*models.Mock); ok && mock.Kind == models.Postgres {
+			mocks = append(mocks, mock)
+		}
+	}
+	return matchRecordedRequests(filterDisabledMocks(mocks), requestBuffers, logger), nil
+}
+
+func matchRecordedRequests(mocks []*models.Mock, requestBuffers [][]byte, logger *zap.Logger) *QueryMatch {
+	if matches := findExactMatches(mocks, requestBuffers, requestNoise{}, MatchNormalized); len(matches) > 0 {
+		return &QueryMatch{MockName: mocks[matches[0]].Name}
+	}
+	idx, similarity := findBinaryStreamMatch(mocks, requestBuffers, logger, false, 0)
+	if idx == -1 {
+		return &QueryMatch{}
+	}
+	return &QueryMatch{
+		Closest:    mocks[idx].Name,
+		Similarity: similarity,
+		Diff:       diffRequests(mocks[idx].Spec.PostgresRequests, requestBuffers),
+	}
+}
+
+// diffRequests describes the messages of the request buffers which differ
+// from the recorded requests, once their statements are normalized.
+func diffRequests(recorded []models.Backend, requestBuffers [][]byte) []string {
+	diff := []string{}
+	for i, buffer := range requestBuffers {
+		if i >= len(recorded) {
+			diff = append(diff, fmt.Sprintf("request %d: not recorded", i))
+			continue
+		}
+		encoded, err := PostgresDecoderBackend(recorded[i])
+		if recorded[i].Payload != "" {
+			encoded, err = PostgresDecoder(recorded[i].Payload)
+		}
+		if err != nil {
+			diff = append(diff, fmt.Sprintf("request %d: failed to decode the recorded request: %v", i, err))
+			continue
+		}
+		want, ok := splitMessages(normalizeQueries(encoded))
+		got, ok2 := splitMessages(normalizeQueries(buffer))
+		if !ok || !ok2 {
+			if !bytes.Equal(encoded, buffer) {
+				diff = append(diff, fmt.Sprintf("request %d: recorded %d bytes, got %d bytes", i, len(encoded), len(buffer)))
+			}
+			continue
+		}
+		for j := 0; j < len(want) || j < len(got); j++ {
+			switch {
+			case j >= len(want):
+				diff = append(diff, fmt.Sprintf("request %d message %d: unexpected %s", i, j, describeMessage(got[j])))
+			case j >= len(got):
+				diff = append(diff, fmt.Sprintf("request %d message %d: missing %s", i, j, describeMessage(want[j])))
+			case !bytes.Equal(want[j], got[j]):
+				diff = append(diff, fmt.Sprintf("request %d message %d: %s", i, j, diffMessage(want[j], got[j])))
+			}
+		}
+	}
+	for i := len(requestBuffers); i < len(recorded); i++ {
+		diff = append(diff, fmt.Sprintf("request %d: recorded but not sent", i))
+	}
+	return diff
+}
+
+// describeMessage returns a readable form of a frontend message: the text of
+// the statements and the parameters of the binds.
+func describeMessage(msg []byte) string {
+	switch msg[0] {
+	case 'Q':
+		query := &pgproto3.Query{}
+		if query.Decode(msg[5:]) == nil {
+			return fmt.Sprintf("Query %q", query.String)
+		}
+	case 'P':
+		parse := &pgproto3.Parse{}
+		if parse.Decode(msg[5:]) == nil {
+			return fmt.Sprintf("Parse %q", parse.Query)
+		}
+	case 'B':
+		bind := &pgproto3.Bind{}
+		if bind.Decode(msg[5:]) == nil {
+			params := make([]string, len(bind.Parameters))
+			for i, param := range bind.Parameters {
+				params[i] = fmt.Sprintf("%q", param)
+			}
+			return fmt.Sprintf("Bind %v", params)
+		}
+	}
+	return fmt.Sprintf("message %q of %d bytes", msg[0], len(msg))
+}
@@ -0,0 +1,108 @@
+package postgresparser
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// configDB serves the config mocks of a test-set.
+type configDB struct {
+	platform.TestCaseDB
+	mocks map[string][]*models.Mock
+}
+
+func (db *configDB) ReadConfigMocks(testSet string) ([]platform.KindSpecifier, error) {
+	mocks := []platform.KindSpecifier{}
+	for _, mock := range db.mocks[testSet] {
+		mocks = append(mocks, mock)
+	}
+	return mocks, nil
+}
+
+// extendedQueryRequest returns the request buffer of the statement run with
+// the parameters through the extended query protocol.
+func extendedQueryRequest(query string, params ...string) []byte {
+	parameters := make([][]byte, len(params))
+	for i, param := range params {
+		parameters[i] = []byte(param)
+	}
+	buffer := (&pgproto3.Parse{Query: query}).Encode(nil)
+	buffer = (&pgproto3.Bind{Parameters: parameters}).Encode(buffer)
+	buffer = (&pgproto3.Describe{ObjectType: 'P'}).Encode(buffer)
+	buffer = (&pgproto3.Execute{}).Encode(buffer)
+	return (&pgproto3.Sync{}).Encode(buffer)
+}
+
+// recordedMock returns the postgres mock recorded for the request buffers.
+func recordedMock(name string, requestBuffers ...[]byte) *models.Mock {
+	mock := &models.Mock{Name: name, Kind: models.Postgres}
+	for _, buffer := range requestBuffers {
+		mock.Spec.PostgresRequests = append(mock.Spec.PostgresRequests, models.Backend{Payload: base64.StdEncoding.EncodeToString(buffer)})
+	}
+	mock.Spec.PostgresResponses = []models.Frontend{{Payload: base64.StdEncoding.EncodeToString((&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(nil))}}
+	return mock
+}
+
+func validationDB() *configDB {
+	disabled := recordedMock("mock-3", SimpleQueryRequest("DELETE FROM users")[0])
+	disabled.Spec.Disabled = true
+	return &configDB{mocks: map[string][]*models.Mock{"test-set-0": {
+		recordedMock("mock-0", SimpleQueryRequest("SELECT name FROM users WHERE id = 1")[0]),
+		recordedMock("mock-1", extendedQueryRequest("SELECT name FROM users WHERE id = $1", "42")),
+		{Name: "mock-2", Kind: models.HTTP},
+		disabled,
+	}}}
+}
+
+func TestValidateRequestsMatch(t *testing.T) {
+	db := validationDB()
+	for _, tt := range []struct {
+		requests [][]byte
+		want     string
+	}{
+		{requests: SimpleQueryRequest("SELECT name FROM users WHERE id = 1"), want: "mock-0"},
+		// the statements are compared once normalized
+		{requests: SimpleQueryRequest("SELECT name\n  FROM users /* by id */ WHERE id = 1;"), want: "mock-0"},
+		{requests: [][]byte{extendedQueryRequest("SELECT name FROM users WHERE id = $1", "42")}, want: "mock-1"},
+	} {
+		match, err := ValidateRequests(db, "test-set-0", tt.requests, zap.NewNop())
+		if err != nil {
+			t.Fatalf("failed to validate the requests: %v", err)
+		}
+		if !match.Matched() || match.MockName != tt.want {
+			t.Errorf("got the match %+v, want %s", match, tt.want)
+		}
+	}
+}
+
+func TestValidateRequestsNearMiss(t *testing.T) {
+	db := validationDB()
+	match, err := ValidateRequests(db, "test-set-0", [][]byte{extendedQueryRequest("SELECT name FROM users WHERE id = $1", "43")}, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to validate the requests: %v", err)
+	}
+	if match.Matched() {
+		t.Fatalf("matched the mock %s with another bind parameter", match.MockName)
+	}
+	if match.Closest != "mock-1" || match.Similarity <= 0 || match.Similarity >= 1 {
+		t.Errorf("got the closest mock %s with the similarity %.2f, want mock-1 below 1", match.Closest, match.Similarity)
+	}
+	if len(match.Diff) != 1 || !strings.Contains(match.Diff[0], `parameter $1 recorded "42", got "43"`) {
+		t.Errorf("got the diff %q, want the bind parameter only", match.Diff)
+	}
+
+	// the disabled mocks are never matched
+	match, err = ValidateRequests(db, "test-set-0", SimpleQueryRequest("DELETE FROM users"), zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to validate the requests: %v", err)
+	}
+	if match.Matched() {
+		t.Errorf("matched the disabled mock %s", match.MockName)
+	}
+}
@@ -0,0 +1,81 @@
+package postgresparser
+
+import (
+	"encoding/base64"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// VirtualMockHandler computes the response of the requests which matched no
+// recorded mock. It receives the decoded requests, in the form they are
+// recorded in the mocks, and returns the response to replay or nil to pass
+// the requests through to the destination server.
+type VirtualMockHandler func(requests []models.Backend) *models.Frontend
+
+// decodeBackendRequests decodes the request buffers of the client into their
+// readable form.
+func decodeBackendRequests(requestBuffers [][]byte) []models.Backend {
+	requests := make([]models.Backend, 0, len(requestBuffers))
+	for _, buffer := range requestBuffers {
+		if len(buffer) >= 8 && isStartupPacket(buffer) {
+			requests = append(requests, models.Backend{
+				Identfier: "StartupRequest",
+				Length:    uint32(len(buffer)),
+				Payload:   base64.StdEncoding.EncodeToString(buffer),
+			})
+			continue
+		}
+
+		pg := NewBackend()
+		for i := 0; i+5 <= len(buffer); {
+			pg.BackendWrapper.MsgType = buffer[i]
+			bodyLen, err := readMessageBodyLen(buffer, i)
+			if err != nil {
+				break
+			}
+			pg.BackendWrapper.BodyLen = bodyLen
+			msg, err := pg.TranslateToReadableBackend(buffer[i : i+bodyLen+5])
+			if err == nil {
+				switch m := msg.(type) {
+				case *pgproto3.Parse:
+					pg.BackendWrapper.Parses = append(pg.BackendWrapper.Parses, *m)
+				case *pgproto3.Bind:
+					pg.BackendWrapper.Binds = append(pg.BackendWrapper.Binds, *m)
+				case *pgproto3.Execute:
+					pg.BackendWrapper.Executes = append(pg.BackendWrapper.Executes, *m)
+				case *pgproto3.PasswordMessage:
+					pg.BackendWrapper.PasswordMessage = *m
+				}
+			}
+			pg.BackendWrapper.PacketTypes = append(pg.BackendWrapper.PacketTypes, string(pg.BackendWrapper.MsgType))
+			i += bodyLen + 5
+		}
+
+		requests = append(requests, models.Backend{
+			PacketTypes:     pg.BackendWrapper.PacketTypes,
+			Identfier:       "ClientRequest",
+			Length:          uint32(len(buffer)),
+			Payload:         base64.StdEncoding.EncodeToString(buffer),
+			Bind:            pg.BackendWrapper.Bind,
+			Binds:           pg.BackendWrapper.Binds,
+			PasswordMessage: pg.BackendWrapper.PasswordMessage,
+			Close:           pg.BackendWrapper.Close,
+			CopyData:        pg.BackendWrapper.CopyData,
+			CopyDone:        pg.BackendWrapper.CopyDone,
+			CopyFail:        pg.BackendWrapper.CopyFail,
+			Describe:        pg.BackendWrapper.Describe,
+			Execute:         pg.BackendWrapper.Execute,
+			Executes:        pg.BackendWrapper.Executes,
+			Flush:           pg.BackendWrapper.Flush,
+			FunctionCall:    pg.BackendWrapper.FunctionCall,
+			Parse:           pg.BackendWrapper.Parse,
+			Parses:          pg.BackendWrapper.Parses,
+			Query:           pg.BackendWrapper.Query,
+			Sync:            pg.BackendWrapper.Sync,
+			Terminate:       pg.BackendWrapper.Terminate,
+			MsgType:         pg.BackendWrapper.MsgType,
+		})
+	}
+	return requests
+}
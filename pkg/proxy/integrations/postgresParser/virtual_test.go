@@ -0,0 +1,63 @@
+package postgresparser
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.uber.org/zap"
+)
+
+func TestVirtualMockHandlerAnswersUnmatchedQuery(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	response := (&pgproto3.CommandComplete{CommandTag: []byte("SELECT 1")}).Encode(nil)
+	response = (&pgproto3.ReadyForQuery{TxStatus: 'I'}).Encode(response)
+
+	var queries []string
+	p := NewPostgresParserWithOptions(zap.NewNop(), h, PostgresOptions{
+		VirtualMockHandler: func(requests []models.Backend) *models.Frontend {
+			for _, request := range requests {
+				queries = append(queries, request.Query.String)
+			}
+			return &models.Frontend{Payload: base64.StdEncoding.EncodeToString(response)}
+		},
+	})
+
+	client, proxied := net.Pipe()
+	defer client.Close()
+	done := make(chan error, 1)
+	go func() {
+		// no mock was loaded, nor is there a server to pass the query to
+		query := (&pgproto3.Query{String: "SELECT now()"}).Encode(nil)
+		done <- decodePostgresOutgoing(query, proxied, nil, p.opts, p.newConnection(context.Background(), zap.NewNop()))
+	}()
+
+	if err := client.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set the read deadline: %v", err)
+	}
+	replayed := make([]byte, len(response))
+	if _, err := io.ReadFull(client, replayed); err != nil {
+		t.Fatalf("failed to read the virtual response: %v", err)
+	}
+	if !bytes.Equal(replayed, response) {
+		t.Fatalf("replayed %q, want the response of the handler %q", replayed, response)
+	}
+	client.Close()
+	<-done
+	if len(queries) != 1 || queries[0] != "SELECT now()" {
+		t.Fatalf("handed the queries %q to the handler, want the decoded query", queries)
+	}
+	if h.GetDivergences() != 0 {
+		t.Fatalf("counted the virtual response as a divergence")
+	}
+}
@@ -0,0 +1,87 @@
+package postgresparser
+
+import (
+	"regexp"
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// RecordingWarmup delays the recording of the exchanges of every connection,
+// to leave out the queries an application runs while it boots, such as its
+// migrations and the warmup of its pools. The exchanges are forwarded as
+// usual meanwhile. The zero value records from the start.
+type RecordingWarmup struct {
+	// Duration is how long after its startup a connection is recorded.
+	Duration time.Duration
+	// Requests is the number of exchanges of a connection left unrecorded.
+	Requests int
+}
+
+// warmupGate tracks the warmup of a recorded connection.
+type warmupGate struct {
+	warmup    RecordingWarmup
+	startedAt time.Time
+	skipped   int
+}
+
+func newWarmupGate(warmup RecordingWarmup, startedAt time.Time) *warmupGate {
+	return &warmupGate{warmup: warmup, startedAt: startedAt}
+}
+
+// sessionStatementRe matches the statements changing the state of the
+// session, which the later statements depend on.
+var sessionStatementRe = regexp.MustCompile(`(?i)^\s*(set|reset|prepare|deallocate|discard)\b`)
+
+// admits reports whether the exchange of the requests is recorded. The
+// startup and the authentication are always recorded, as the connection
+// can't be replayed without them, and so are the exchanges setting the state
+// of the session, as the later exchanges can't be replayed without them.
+// These don't count towards the warmup. The warmup is over once both its
+// duration has elapsed and its count of exchanges has been skipped.
+func (g *warmupGate) admits(requests []models.Backend) bool {
+	if isAuthenticationExchange(requests) || setsSessionState(requests) {
+		return true
+	}
+	if time.Since(g.startedAt) >= g.warmup.Duration && g.skipped >= g.warmup.Requests {
+		return true
+	}
+	g.skipped++
+	return false
+}
+
+// isAuthenticationExchange reports whether the requests start the connection
+// or answer the authentication requests of the server.
+func isAuthenticationExchange(requests []models.Backend) bool {
+	for _, request := range requests {
+		if request.Identfier == "StartupRequest" {
+			return true
+		}
+		for _, packetType := range request.PacketTypes {
+			if packetType == "p" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setsSessionState reports whether the requests prepare a named statement,
+// which the later Binds refer to, or run a statement of the session such as
+// SET.
+func setsSessionState(requests []models.Backend) bool {
+	for _, request := range requests {
+		if sessionStatementRe.MatchString(request.Query.String) {
+			return true
+		}
+		if request.Parse.Name != "" || sessionStatementRe.MatchString(request.Parse.Query) {
+			return true
+		}
+		for _, parse := range request.Parses {
+			if parse.Name != "" || sessionStatementRe.MatchString(parse.Query) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package postgresparser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestWarmupGate(t *testing.T) {
+	query := []models.Backend{{PacketTypes: []string{"Q"}, Query: pgproto3.Query{String: "SELECT 1"}}}
+	for _, tt := range []struct {
+		name     string
+		warmup   RecordingWarmup
+		started  time.Duration
+		requests [][]models.Backend
+		want     []bool
+	}{
+		{name: "no warmup", requests: [][]models.Backend{query}, want: []bool{true}},
+		{name: "warmup requests", warmup: RecordingWarmup{Requests: 2}, requests: [][]models.Backend{query, query, query}, want: []bool{false, false, true}},
+		{name: "warmup duration", warmup: RecordingWarmup{Duration: time.Hour}, requests: [][]models.Backend{query, query}, want: []bool{false, false}},
+		{name: "warmup duration elapsed", warmup: RecordingWarmup{Duration: time.Minute}, started: time.Hour, requests: [][]models.Backend{query}, want: []bool{true}},
+		{name: "duration elapsed before the requests", warmup: RecordingWarmup{Duration: time.Minute, Requests: 1}, started: time.Hour, requests: [][]models.Backend{query, query}, want: []bool{false, true}},
+		{
+			name:   "startup and authentication",
+			warmup: RecordingWarmup{Requests: 1},
+			requests: [][]models.Backend{
+				{{Identfier: "StartupRequest"}},
+				{{PacketTypes: []string{"p"}}},
+				query,
+				query,
+			},
+			want: []bool{true, true, false, true},
+		},
+		{
+			name:   "session state",
+			warmup: RecordingWarmup{Requests: 1},
+			requests: [][]models.Backend{
+				{{PacketTypes: []string{"Q"}, Query: pgproto3.Query{String: "SET search_path TO bank"}}},
+				{{PacketTypes: []string{"P", "D", "S"}, Parse: pgproto3.Parse{Name: "stmt_1", Query: "SELECT $1"}}},
+				{{PacketTypes: []string{"P", "B", "E", "S"}, Parses: []pgproto3.Parse{{Query: "deallocate all"}}}},
+				query,
+				query,
+			},
+			want: []bool{true, true, true, false, true},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			gate := newWarmupGate(tt.warmup, time.Now().Add(-tt.started))
+			for i, requests := range tt.requests {
+				if got := gate.admits(requests); got != tt.want[i] {
+					t.Errorf("admitted the exchange %d: %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRecordingWarmup(t *testing.T) {
+	set := encodeMessages(&pgproto3.CommandComplete{CommandTag: []byte("SET")}, &pgproto3.ReadyForQuery{TxStatus: 'I'})
+	mocks := recordExchanges(t, PostgresOptions{RecordingWarmup: RecordingWarmup{Requests: 2}},
+		pgExchange{request: SimpleQueryRequest("SELECT 1")[0], response: rowsResponse(1)},
+		pgExchange{request: SimpleQueryRequest("SET search_path TO bank")[0], response: set},
+		pgExchange{request: SimpleQueryRequest("SELECT 2")[0], response: rowsResponse(2)},
+		pgExchange{request: SimpleQueryRequest("SELECT 3")[0], response: rowsResponse(3)},
+	)
+
+	var recorded []string
+	for _, mock := range mocks {
+		for _, request := range mock.Spec.PostgresRequests {
+			if request.Identfier == "StartupRequest" {
+				recorded = append(recorded, "startup")
+			} else {
+				recorded = append(recorded, request.Query.String)
+			}
+		}
+	}
+	want := []string{"startup", "SET search_path TO bank", "SELECT 3"}
+	if len(recorded) != len(want) {
+		t.Fatalf("recorded the requests %q, want %q", recorded, want)
+	}
+	for i := range want {
+		if recorded[i] != want[i] {
+			t.Errorf("recorded the requests %q, want %q", recorded, want)
+			break
+		}
+	}
+}
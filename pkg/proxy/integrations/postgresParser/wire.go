@@ -0,0 +1,47 @@
+package postgresparser
+
+import (
+	"go.keploy.io/server/pkg/models"
+)
+
+// The recorded postgres messages are stored both in a readable form (the
+// structured fields of models.Frontend and models.Backend) and, whenever the
+// readable form can't reproduce the original bytes exactly, as the raw base64
+// Payload. The functions below reconstruct the wire bytes of a recorded
+// message the same way the proxy replays it:
+//
+//  1. a non-empty Payload always takes precedence, it holds the exact bytes
+//     observed on the wire;
+//  2. otherwise the messages listed in PacketTypes are encoded, in order,
+//     from the structured fields;
+//  3. a message having neither a Payload nor PacketTypes has no wire bytes.
+//
+// The RawPayload recorded for debugging along with the readable form is not
+// replayed.
+
+// FrontendWireBytes returns the wire bytes of a recorded server response.
+func FrontendWireBytes(response models.Frontend) ([]byte, error) {
+	if response.Payload != "" {
+		return PostgresDecoder(response.Payload)
+	}
+	if len(response.PacketTypes) == 0 {
+		return nil, nil
+	}
+	return PostgresDecoderFrontend(response)
+}
+
+// BackendWireBytes returns the wire bytes of a recorded client request.
+func BackendWireBytes(request models.Backend) ([]byte, error) {
+	if request.Payload != "" {
+		return PostgresDecoder(request.Payload)
+	}
+	if len(request.PacketTypes) == 0 {
+		return nil, nil
+	}
+	return PostgresDecoderBackend(request)
+}
+
+// PayloadWireBytes returns the wire bytes of a stored base64 payload.
+func PayloadWireBytes(payload string) ([]byte, error) {
+	return PostgresDecoder(payload)
+}
@@ -0,0 +1,145 @@
+package postgresparser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/jackc/pgproto3/v2"
+	"go.keploy.io/server/pkg/models"
+)
+
+// encodeMessages returns the wire bytes of the messages.
+func encodeMessages(msgs ...interface{ Encode([]byte) []byte }) []byte {
+	var buffer []byte
+	for _, msg := range msgs {
+		buffer = msg.Encode(buffer)
+	}
+	return buffer
+}
+
+func TestFrontendWireBytes(t *testing.T) {
+	fields := []pgproto3.FieldDescription{{Name: []byte("balance"), DataTypeOID: 1700, DataTypeSize: -1, TypeModifier: -1}}
+	for _, tt := range []struct {
+		name     string
+		response models.Frontend
+		want     []byte
+	}{
+		{
+			name: "authentication",
+			response: models.Frontend{
+				PacketTypes:             []string{"R", "S", "K", "Z"},
+				AuthType:                AuthTypeOk,
+				ParameterStatusCombined: []pgproto3.ParameterStatus{{Name: "server_version", Value: "15.4"}},
+				BackendKeyData:          pgproto3.BackendKeyData{ProcessID: 42, SecretKey: 7},
+				ReadyForQuery:           pgproto3.ReadyForQuery{TxStatus: 'I'},
+			},
+			want: encodeMessages(&pgproto3.AuthenticationOk{}, &pgproto3.ParameterStatus{Name: "server_version", Value: "15.4"}, &pgproto3.BackendKeyData{ProcessID: 42, SecretKey: 7}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		},
+		{
+			name: "rows",
+			response: models.Frontend{
+				PacketTypes:      []string{"1", "2", "T", "D", "D", "C", "Z"},
+				RowDescription:   pgproto3.RowDescription{Fields: fields},
+				DataRows:         []pgproto3.DataRow{{Values: [][]byte{[]byte("100.50")}}, {Values: [][]byte{nil}}},
+				CommandCompletes: []pgproto3.CommandComplete{{CommandTag: []byte("SELECT 2")}},
+				ReadyForQuery:    pgproto3.ReadyForQuery{TxStatus: 'T'},
+			},
+			want: encodeMessages(&pgproto3.ParseComplete{}, &pgproto3.BindComplete{}, &pgproto3.RowDescription{Fields: fields}, &pgproto3.DataRow{Values: [][]byte{[]byte("100.50")}}, &pgproto3.DataRow{Values: [][]byte{nil}}, &pgproto3.CommandComplete{CommandTag: []byte("SELECT 2")}, &pgproto3.ReadyForQuery{TxStatus: 'T'}),
+		},
+		{
+			name: "error",
+			response: models.Frontend{
+				PacketTypes:   []string{"E", "Z"},
+				ErrorResponse: pgproto3.ErrorResponse{Severity: "ERROR", Code: "42P01", Message: `relation "users" does not exist`},
+				ReadyForQuery: pgproto3.ReadyForQuery{TxStatus: 'I'},
+			},
+			want: encodeMessages(&pgproto3.ErrorResponse{Severity: "ERROR", Code: "42P01", Message: `relation "users" does not exist`}, &pgproto3.ReadyForQuery{TxStatus: 'I'}),
+		},
+	} {
+		got, err := FrontendWireBytes(tt.response)
+		if err != nil {
+			t.Fatalf("%s: failed to encode the response: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got the wire bytes %q, want %q", tt.name, got, tt.want)
+		}
+
+		// the payload stored for the same bytes decodes to them as well
+		payload := base64.StdEncoding.EncodeToString(tt.want)
+		if got, err := PayloadWireBytes(payload); err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got the wire bytes %q of the payload, want %q (%v)", tt.name, got, tt.want, err)
+		}
+	}
+}
+
+func TestBackendWireBytes(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		request models.Backend
+		want    []byte
+	}{
+		{
+			name:    "simple query",
+			request: models.Backend{PacketTypes: []string{"Q"}, Query: pgproto3.Query{String: "SELECT 1"}},
+			want:    encodeMessages(&pgproto3.Query{String: "SELECT 1"}),
+		},
+		{
+			name: "extended query",
+			request: models.Backend{
+				PacketTypes: []string{"P", "B", "D", "E", "S"},
+				Parses:      []pgproto3.Parse{{Name: "stmt_1", Query: "SELECT name FROM users WHERE id = $1", ParameterOIDs: []uint32{23}}},
+				Binds:       []pgproto3.Bind{{PreparedStatement: "stmt_1", ParameterFormatCodes: []int16{0}, Parameters: [][]byte{[]byte("42")}}},
+				Describe:    pgproto3.Describe{ObjectType: 'P'},
+				Executes:    []pgproto3.Execute{{}},
+			},
+			want: encodeMessages(&pgproto3.Parse{Name: "stmt_1", Query: "SELECT name FROM users WHERE id = $1", ParameterOIDs: []uint32{23}}, &pgproto3.Bind{PreparedStatement: "stmt_1", ParameterFormatCodes: []int16{0}, Parameters: [][]byte{[]byte("42")}}, &pgproto3.Describe{ObjectType: 'P'}, &pgproto3.Execute{}, &pgproto3.Sync{}),
+		},
+		{
+			name:    "terminate",
+			request: models.Backend{PacketTypes: []string{"X"}},
+			want:    encodeMessages(&pgproto3.Terminate{}),
+		},
+	} {
+		got, err := BackendWireBytes(tt.request)
+		if err != nil {
+			t.Fatalf("%s: failed to encode the request: %v", tt.name, err)
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: got the wire bytes %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWireBytesPrecedence(t *testing.T) {
+	query := encodeMessages(&pgproto3.Query{String: "SELECT  1 -- kept verbatim"})
+	// the payload holds the exact bytes, the readable form is ignored
+	request := models.Backend{
+		PacketTypes: []string{"Q"},
+		Query:       pgproto3.Query{String: "SELECT 1"},
+		Payload:     base64.StdEncoding.EncodeToString(query),
+	}
+	if got, err := BackendWireBytes(request); err != nil || !bytes.Equal(got, query) {
+		t.Errorf("got the wire bytes %q, want the payload %q (%v)", got, query, err)
+	}
+	ready := encodeMessages(&pgproto3.ReadyForQuery{TxStatus: 'E'})
+	response := models.Frontend{
+		PacketTypes:   []string{"Z"},
+		ReadyForQuery: pgproto3.ReadyForQuery{TxStatus: 'I'},
+		Payload:       base64.StdEncoding.EncodeToString(ready),
+	}
+	if got, err := FrontendWireBytes(response); err != nil || !bytes.Equal(got, ready) {
+		t.Errorf("got the wire bytes %q, want the payload %q (%v)", got, ready, err)
+	}
+
+	// neither a payload nor packet types
+	if got, err := BackendWireBytes(models.Backend{}); err != nil || got != nil {
+		t.Errorf("got the wire bytes %q of an empty request (%v)", got, err)
+	}
+	if got, err := FrontendWireBytes(models.Frontend{}); err != nil || got != nil {
+		t.Errorf("got the wire bytes %q of an empty response (%v)", got, err)
+	}
+	if _, err := PayloadWireBytes("not base64!"); err == nil {
+		t.Errorf("decoded an invalid payload")
+	}
+}
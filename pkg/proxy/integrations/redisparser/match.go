@@ -0,0 +1,56 @@
+package redisparser
+
+import (
+	"strings"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+)
+
+// matchCommand returns the mock recorded for the command. The mocks of the
+// test cases are consumed in the order they were recorded in, the mocks of
+// the connection set up are shared.
+func matchCommand(command models.RedisRequest, h *hooks.Hook) *models.Mock {
+	tcsMocks, err := h.GetTcsMocks()
+	if err == nil {
+		for _, mock := range tcsMocks {
+			if isRedisMockOf(mock, command) && h.DeleteTcsMock(mock) {
+				return mock
+			}
+		}
+	}
+	configMocks, err := h.GetConfigMocks()
+	if err == nil {
+		for _, mock := range configMocks {
+			if isRedisMockOf(mock, command) {
+				h.UpdateConsumedMocks(mock.Name, false)
+				return mock
+			}
+		}
+	}
+	return nil
+}
+
+func isRedisMockOf(mock *models.Mock, command models.RedisRequest) bool {
+	if mock.Kind != models.Redis || mock.Spec.Disabled || len(mock.Spec.RedisRequests) != 1 {
+		return false
+	}
+	return sameCommand(mock.Spec.RedisRequests[0], command)
+}
+
+// sameCommand reports whether the commands are the same, the names of the
+// commands being case insensitive, however they were sent.
+func sameCommand(recorded, command models.RedisRequest) bool {
+	if len(recorded.Command) != len(command.Command) || len(command.Command) == 0 {
+		return false
+	}
+	if !strings.EqualFold(recorded.Command[0], command.Command[0]) {
+		return false
+	}
+	for i := 1; i < len(command.Command); i++ {
+		if recorded.Command[i] != command.Command[i] {
+			return false
+		}
+	}
+	return true
+}
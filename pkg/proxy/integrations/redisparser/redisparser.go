@@ -0,0 +1,349 @@
+package redisparser
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"go.keploy.io/server/pkg"
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/proxy/util"
+	"go.keploy.io/server/utils"
+	"go.uber.org/zap"
+)
+
+// inlineCommands are the commands recognized at the start of a connection
+// when sent inline. The others, such as GET, are left to the parsers of the
+// text protocols they are ambiguous with.
+var inlineCommands = []string{"PING", "AUTH", "HELLO", "SELECT", "CLIENT", "INFO", "QUIT"}
+
+// connectionCommands set up the connections rather than access the data, so
+// that their mocks are shared by all the test cases.
+var connectionCommands = map[string]bool{
+	"AUTH":     true,
+	"HELLO":    true,
+	"SELECT":   true,
+	"PING":     true,
+	"CLIENT":   true,
+	"READONLY": true,
+	"QUIT":     true,
+}
+
+type RedisParser struct {
+	logger *zap.Logger
+	hooks  *hooks.Hook
+}
+
+func NewRedisParser(logger *zap.Logger, h *hooks.Hook) *RedisParser {
+	return &RedisParser{
+		logger: logger,
+		hooks:  h,
+	}
+}
+
+// OutgoingType reports whether the buffer starts with a command of the Redis
+// serialization protocol (RESP): an array of bulk strings, as sent by the
+// clients, or one of the inline commands sent to set up a connection.
+func (r *RedisParser) OutgoingType(buffer []byte) bool {
+	if len(buffer) == 0 {
+		return false
+	}
+	if buffer[0] == '*' {
+		commands, _, err := decodeCommands(buffer)
+		return err == nil && len(commands) > 0 && !commands[0].Inline
+	}
+	line, _, ok := bytes.Cut(buffer, []byte("\r\n"))
+	if !ok {
+		return false
+	}
+	name, _, _ := strings.Cut(string(line), " ")
+	for _, command := range inlineCommands {
+		if strings.EqualFold(name, command) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RedisParser) ProcessOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, ctx context.Context) {
+	switch models.GetMode() {
+	case models.MODE_RECORD:
+		err := encodeRedisOutgoing(requestBuffer, clientConn, destConn, r.hooks, r.logger, ctx)
+		if err != nil {
+			r.logger.Debug("failed to encode the outgoing redis call", zap.Error(err))
+		}
+	case models.MODE_TEST:
+		err := decodeRedisOutgoing(requestBuffer, clientConn, destConn, r.hooks, r.logger)
+		if err != nil {
+			r.logger.Debug("failed to decode the outgoing redis call", zap.Error(err))
+		}
+	default:
+		r.logger.Info("Invalid mode detected while intercepting outgoing redis call", zap.Any("mode", models.GetMode()))
+	}
+}
+
+// encodeRedisOutgoing records the commands of the client and the replies of
+// the server. Each command is recorded as a mock with its reply, or the
+// confirmations of all the channels of a subscription command, along with
+// the attributes and the messages pushed before it, so that the pipelined
+// commands are replayed however they are batched. The messages pushed to the
+// subscribers outside of the replies are not recorded.
+func encodeRedisOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger, ctx context.Context) error {
+	_, err := destConn.Write(requestBuffer)
+	if err != nil {
+		logger.Error("failed to write request message to the destination server", zap.Error(err))
+		return err
+	}
+
+	clientBufferChannel := make(chan []byte)
+	destBufferChannel := make(chan []byte)
+	errChannel := make(chan error)
+	done := make(chan struct{})
+	defer func() {
+		close(done)
+		clientConn.Close()
+		destConn.Close()
+	}()
+	go func() {
+		// Recover from panic and gracefully shutdown
+		defer h.Recover(pkg.GenerateRandomID())
+		defer utils.HandlePanic()
+		readBuffConn(clientConn, clientBufferChannel, errChannel, done, logger, h)
+	}()
+	go func() {
+		// Recover from panic and gracefully shutdown
+		defer h.Recover(pkg.GenerateRandomID())
+		defer utils.HandlePanic()
+		readBuffConn(destConn, destBufferChannel, errChannel, done, logger, h)
+	}()
+
+	// the commands waiting for their reply, with the time they were sent at
+	type sentCommand struct {
+		request models.RedisRequest
+		sentAt  time.Time
+	}
+	sent := []sentCommand{}
+	var requestPending, responsePending []byte
+	replies := []models.RedisReply{}
+	recording := true
+
+	readCommands := func(buffer []byte) {
+		requestPending = append(requestPending, buffer...)
+		commands, ends, err := decodeCommands(requestPending)
+		if err != nil {
+			logger.Debug("stopped recording the redis connection sending a message which is not RESP", zap.Error(err))
+			recording = false
+			return
+		}
+		for _, command := range commands {
+			sent = append(sent, sentCommand{request: command, sentAt: time.Now()})
+		}
+		if len(ends) > 0 {
+			requestPending = append([]byte{}, requestPending[ends[len(ends)-1]:]...)
+		}
+	}
+	readCommands(requestBuffer)
+
+	for {
+		select {
+		case buffer := <-clientBufferChannel:
+			_, err := destConn.Write(buffer)
+			if err != nil {
+				logger.Error("failed to write request message to the destination server", zap.Error(err))
+				return err
+			}
+			if recording {
+				readCommands(buffer)
+			}
+		case buffer := <-destBufferChannel:
+			_, err := clientConn.Write(buffer)
+			if err != nil {
+				logger.Error("failed to write response to the client", zap.Error(err))
+				return err
+			}
+			if !recording {
+				continue
+			}
+			responsePending = append(responsePending, buffer...)
+			decoded, n, err := decodeReplies(responsePending)
+			if err != nil {
+				logger.Debug("stopped recording the redis connection receiving a message which is not RESP", zap.Error(err))
+				recording = false
+				continue
+			}
+			responsePending = append([]byte{}, responsePending[n:]...)
+			for _, reply := range decoded {
+				if len(sent) == 0 {
+					logger.Debug("skipped recording the redis message pushed outside of a reply", zap.Any("type", reply.Type))
+					continue
+				}
+				replies = append(replies, reply)
+				if !answered(sent[0].request, replies) {
+					continue
+				}
+				appendRedisMock(sent[0].request, replies, sent[0].sentAt, time.Now(), h, logger, ctx)
+				sent = sent[1:]
+				replies = []models.RedisReply{}
+			}
+		case err := <-errChannel:
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// appendRedisMock records the command with its replies as a redis mock.
+func appendRedisMock(request models.RedisRequest, replies []models.RedisReply, reqTimestampMock, resTimestampMock time.Time, h *hooks.Hook, logger *zap.Logger, ctx context.Context) {
+	name := strings.ToUpper(request.Command[0])
+	metadata := map[string]string{
+		"operation": name,
+	}
+	if connectionCommands[name] {
+		metadata["type"] = "config"
+	}
+	err := h.AppendMocks(&models.Mock{
+		Version: models.GetVersion(),
+		Name:    "mocks",
+		Kind:    models.Redis,
+		Spec: models.MockSpec{
+			Metadata:         metadata,
+			RedisRequests:    []models.RedisRequest{request},
+			RedisResponses:   replies,
+			ReqTimestampMock: reqTimestampMock,
+			ResTimestampMock: resTimestampMock,
+		},
+	}, ctx)
+	if err != nil {
+		logger.Error("failed to append the redis mock", zap.Error(err))
+	}
+}
+
+// decodeRedisOutgoing replays the recorded replies of the commands of the
+// client. The commands pipelined in a read are matched one by one, and their
+// replies written at once. The commands matching no mock are passed through
+// to the server.
+func decodeRedisOutgoing(requestBuffer []byte, clientConn, destConn net.Conn, h *hooks.Hook, logger *zap.Logger) error {
+	defer clientConn.Close()
+	if destConn != nil {
+		defer destConn.Close()
+	}
+	pending := requestBuffer
+	for {
+		commands, ends, err := decodeCommands(pending)
+		if err != nil {
+			logger.Error("failed to decode the redis commands of the client", zap.Error(err))
+			return err
+		}
+		if len(commands) == 0 {
+			buffer, err := util.ReadBytes(clientConn)
+			pending = append(pending, buffer...)
+			if err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				logger.Error("failed to read the commands of the redis client", zap.Error(err))
+				return err
+			}
+			continue
+		}
+
+		var replies []byte
+		start := 0
+		for i, command := range commands {
+			mock := matchCommand(command, h)
+			if mock != nil {
+				h.RecordRoundTrip(models.Redis)
+				replies = append(replies, encodeReplies(mock.Spec.RedisResponses)...)
+				start = ends[i]
+				continue
+			}
+			logger.Debug("the redis command matched no mock, passing it through", zap.String("command", strings.ToUpper(command.Command[0])))
+			if len(replies) > 0 {
+				_, err := clientConn.Write(replies)
+				if err != nil {
+					logger.Error("failed to write the replies to the redis client", zap.Error(err))
+					return err
+				}
+				replies = nil
+			}
+			err := passthrough(clientConn, destConn, command, pending[start:ends[i]], h, logger)
+			if err != nil {
+				logger.Error("failed to pass the redis command through to the server", zap.Error(err))
+				return err
+			}
+			start = ends[i]
+		}
+		if len(replies) > 0 {
+			_, err := clientConn.Write(replies)
+			if err != nil {
+				logger.Error("failed to write the replies to the redis client", zap.Error(err))
+				return err
+			}
+		}
+		pending = append([]byte{}, pending[ends[len(ends)-1]:]...)
+	}
+}
+
+// passthrough forwards the command to the server, then its replies back to the
+// client.
+func passthrough(clientConn, destConn net.Conn, command models.RedisRequest, buffer []byte, h *hooks.Hook, logger *zap.Logger) error {
+	if destConn == nil {
+		return errors.New("no connection to the redis server to pass the command through")
+	}
+	if util.IsSelfReferential(clientConn, destConn) {
+		return util.ErrSelfReferentialDestination
+	}
+	_, err := destConn.Write(buffer)
+	if err != nil {
+		return err
+	}
+	var reply []byte
+	for {
+		buffer, err := util.ReadBytes(destConn)
+		reply = append(reply, buffer...)
+		replies, n, decodeErr := decodeReplies(reply)
+		if decodeErr != nil {
+			return decodeErr
+		}
+		if answered(command, replies) && n == len(reply) {
+			_, err := clientConn.Write(reply)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readBuffConn reads the connection into the channel until it fails or done
+// is closed.
+func readBuffConn(conn net.Conn, bufferChannel chan []byte, errChannel chan error, done <-chan struct{}, logger *zap.Logger, h *hooks.Hook) {
+	for {
+		buffer, err := util.ReadBytes(conn)
+		if len(buffer) > 0 {
+			select {
+			case bufferChannel <- buffer:
+			case <-done:
+				return
+			}
+		}
+		if err == nil {
+			continue
+		}
+		if !h.IsUserAppTerminateInitiated() && err != io.EOF && !strings.Contains(err.Error(), "use of closed network connection") {
+			logger.Error("failed to read the packet message in proxy for redis dependency", zap.Error(err))
+		}
+		select {
+		case errChannel <- err:
+		case <-done:
+		}
+		return
+	}
+}
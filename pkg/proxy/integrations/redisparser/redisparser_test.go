@@ -0,0 +1,246 @@
+package redisparser
+
+import (
+	"context"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+	"go.uber.org/zap"
+)
+
+// mockDB keeps the mocks recorded through the hooks.
+type mockDB struct {
+	platform.TestCaseDB
+	mocks []*models.Mock
+}
+
+func (db *mockDB) WriteMock(mock platform.KindSpecifier, ctx context.Context) error {
+	db.mocks = append(db.mocks, mock.(*models.Mock))
+	return nil
+}
+
+func TestAnswered(t *testing.T) {
+	ok := models.RedisReply{Type: models.RedisSimpleString, Value: "OK"}
+	push := models.RedisReply{Type: models.RedisPush, Elements: []models.RedisReply{{Type: models.RedisBulkString, Value: "invalidate"}}}
+	attribute := models.RedisReply{Type: models.RedisAttribute, Elements: []models.RedisReply{{Type: models.RedisSimpleString, Value: "ttl"}, {Type: models.RedisInteger, Value: "3600"}}}
+	confirmation := func(kind, channel string, count string) models.RedisReply {
+		return models.RedisReply{Type: models.RedisArray, Elements: []models.RedisReply{{Type: models.RedisBulkString, Value: kind}, {Type: models.RedisBulkString, Value: channel}, {Type: models.RedisInteger, Value: count}}}
+	}
+	message := models.RedisReply{Type: models.RedisArray, Elements: []models.RedisReply{{Type: models.RedisBulkString, Value: "message"}, {Type: models.RedisBulkString, Value: "news"}, {Type: models.RedisBulkString, Value: "hello"}}}
+
+	for _, tt := range []struct {
+		name    string
+		command []string
+		replies []models.RedisReply
+		want    bool
+	}{
+		{name: "no reply", command: []string{"GET", "k"}, want: false},
+		{name: "reply", command: []string{"GET", "k"}, replies: []models.RedisReply{ok}, want: true},
+		{name: "error", command: []string{"GET", "k"}, replies: []models.RedisReply{{Type: models.RedisError, Value: "WRONGTYPE"}}, want: true},
+		{name: "push only", command: []string{"GET", "k"}, replies: []models.RedisReply{push}, want: false},
+		{name: "attribute only", command: []string{"GET", "k"}, replies: []models.RedisReply{attribute}, want: false},
+		{name: "push and attribute then reply", command: []string{"GET", "k"}, replies: []models.RedisReply{push, attribute, ok}, want: true},
+		{name: "subscription partly confirmed", command: []string{"SUBSCRIBE", "news", "sports"}, replies: []models.RedisReply{confirmation("subscribe", "news", "1")}, want: false},
+		{name: "subscription with a message", command: []string{"subscribe", "news", "sports"}, replies: []models.RedisReply{confirmation("subscribe", "news", "1"), message}, want: false},
+		{name: "subscription confirmed", command: []string{"SUBSCRIBE", "news", "sports"}, replies: []models.RedisReply{confirmation("subscribe", "news", "1"), confirmation("subscribe", "sports", "2")}, want: true},
+		{name: "resp3 subscription confirmed", command: []string{"SSUBSCRIBE", "orders"}, replies: []models.RedisReply{{Type: models.RedisPush, Elements: confirmation("ssubscribe", "orders", "1").Elements}}, want: true},
+		{name: "unsubscribe of all channels", command: []string{"UNSUBSCRIBE"}, replies: []models.RedisReply{confirmation("unsubscribe", "news", "0")}, want: true},
+		{name: "subscription refused", command: []string{"PSUBSCRIBE", "n*", "s*"}, replies: []models.RedisReply{{Type: models.RedisError, Value: "NOPERM"}}, want: true},
+	} {
+		if got := answered(models.RedisRequest{Command: tt.command}, tt.replies); got != tt.want {
+			t.Errorf("%s: got answered %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// session is the traffic of a connection: the inline PING starting it, then
+// a SET and a GET pipelined in a single buffer.
+var session = []struct{ request, response string }{
+	{request: "PING\r\n", response: "+PONG\r\n"},
+	{request: "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$1\r\nv\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\n", response: "+OK\r\n$1\r\nv\r\n"},
+}
+
+// runClient sends the requests of the session, after the first one already
+// sent, and checks the responses it reads back.
+func runClient(t *testing.T, app net.Conn) {
+	defer app.Close()
+	for i, exchange := range session {
+		if i > 0 {
+			if _, err := app.Write([]byte(exchange.request)); err != nil {
+				t.Errorf("failed to write the request %q: %v", exchange.request, err)
+				return
+			}
+		}
+		response := make([]byte, len(exchange.response))
+		if _, err := io.ReadFull(app, response); err != nil {
+			t.Errorf("failed to read the response to %q: %v", exchange.request, err)
+			return
+		}
+		if string(response) != exchange.response {
+			t.Errorf("got the response %q, want %q", response, exchange.response)
+		}
+	}
+}
+
+func waitFor(t *testing.T, done <-chan error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("the connection ended with the error %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("the connection did not end")
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		for _, exchange := range session {
+			if _, err := io.ReadFull(server, make([]byte, len(exchange.request))); err != nil {
+				return
+			}
+			if _, err := server.Write([]byte(exchange.response)); err != nil {
+				return
+			}
+		}
+		io.Copy(io.Discard, server)
+	}()
+	go runClient(t, app)
+	done := make(chan error, 1)
+	go func() {
+		done <- encodeRedisOutgoing([]byte(session[0].request), clientConn, destConn, h, zap.NewNop(), context.Background())
+	}()
+	waitFor(t, done)
+
+	want := []struct {
+		command []string
+		inline  bool
+		replies []models.RedisReply
+		config  bool
+	}{
+		{command: []string{"PING"}, inline: true, replies: []models.RedisReply{{Type: models.RedisSimpleString, Value: "PONG"}}, config: true},
+		{command: []string{"SET", "k", "v"}, replies: []models.RedisReply{{Type: models.RedisSimpleString, Value: "OK"}}},
+		{command: []string{"GET", "k"}, replies: []models.RedisReply{{Type: models.RedisBulkString, Value: "v"}}},
+	}
+	if len(db.mocks) != len(want) {
+		t.Fatalf("recorded %d mocks, want one per command", len(db.mocks))
+	}
+	var tcsMocks, configMocks []*models.Mock
+	for i, mock := range db.mocks {
+		request := mock.Spec.RedisRequests[0]
+		if !reflect.DeepEqual(request.Command, want[i].command) || request.Inline != want[i].inline {
+			t.Errorf("recorded the command %+v, want %v", request, want[i].command)
+		}
+		if !reflect.DeepEqual(mock.Spec.RedisResponses, want[i].replies) {
+			t.Errorf("recorded the replies %+v to %v, want %+v", mock.Spec.RedisResponses, want[i].command, want[i].replies)
+		}
+		if config := mock.Spec.Metadata["type"] == "config"; config != want[i].config {
+			t.Errorf("recorded the mock of %v as config %v, want %v", want[i].command, config, want[i].config)
+		}
+		// the mocks are told apart by their names once loaded
+		mock.Name = "mock-" + strconv.Itoa(i)
+		if want[i].config {
+			configMocks = append(configMocks, mock)
+		} else {
+			tcsMocks = append(tcsMocks, mock)
+		}
+	}
+
+	// the same traffic is replayed without the server
+	h.SetTcsMocks(tcsMocks)
+	h.SetConfigMocks(configMocks)
+	app, clientConn = net.Pipe()
+	go runClient(t, app)
+	go func() {
+		done <- decodeRedisOutgoing([]byte(session[0].request), clientConn, nil, h, zap.NewNop())
+	}()
+	waitFor(t, done)
+
+	if remaining, _ := h.GetTcsMocks(); len(remaining) != 0 {
+		t.Errorf("left %d mocks of the test case unconsumed", len(remaining))
+	}
+}
+
+func TestSubscriptionRecordedAndReplayed(t *testing.T) {
+	subscribe := "*3\r\n$9\r\nSUBSCRIBE\r\n$4\r\nnews\r\n$6\r\nsports\r\n"
+	// the channels are confirmed one at a time
+	confirmations := []string{"*3\r\n$9\r\nsubscribe\r\n$4\r\nnews\r\n:1\r\n", "*3\r\n$9\r\nsubscribe\r\n$6\r\nsports\r\n:2\r\n"}
+	client := func(app net.Conn) {
+		defer app.Close()
+		want := confirmations[0] + confirmations[1]
+		got := make([]byte, len(want))
+		if _, err := io.ReadFull(app, got); err != nil {
+			t.Errorf("failed to read the confirmations: %v", err)
+			return
+		}
+		if string(got) != want {
+			t.Errorf("got the confirmations %q, want %q", got, want)
+		}
+	}
+
+	db := &mockDB{}
+	h, err := hooks.NewHook(db, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		if _, err := io.ReadFull(server, make([]byte, len(subscribe))); err != nil {
+			return
+		}
+		for _, confirmation := range confirmations {
+			if _, err := server.Write([]byte(confirmation)); err != nil {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		io.Copy(io.Discard, server)
+	}()
+	go client(app)
+	done := make(chan error, 1)
+	go func() {
+		done <- encodeRedisOutgoing([]byte(subscribe), clientConn, destConn, h, zap.NewNop(), context.Background())
+	}()
+	waitFor(t, done)
+
+	if len(db.mocks) != 1 {
+		t.Fatalf("recorded %d mocks, want the subscription with all its confirmations", len(db.mocks))
+	}
+	mock := db.mocks[0]
+	if len(mock.Spec.RedisResponses) != 2 || mock.Spec.RedisResponses[1].Elements[1].Value != "sports" {
+		t.Fatalf("recorded the replies %+v, want the confirmations of both channels", mock.Spec.RedisResponses)
+	}
+
+	// both confirmations are replayed without the server
+	mock.Name = "mock-0"
+	if mock.Spec.Metadata["type"] == "config" {
+		h.SetConfigMocks([]*models.Mock{mock})
+	} else {
+		h.SetTcsMocks([]*models.Mock{mock})
+	}
+	app, clientConn = net.Pipe()
+	go client(app)
+	go func() {
+		done <- decodeRedisOutgoing([]byte(subscribe), clientConn, nil, h, zap.NewNop())
+	}()
+	waitFor(t, done)
+}
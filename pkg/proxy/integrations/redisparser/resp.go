@@ -0,0 +1,330 @@
+package redisparser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+// errIncomplete is returned when the buffer ends within a message, which is
+// then completed by the next reads of the connection.
+var errIncomplete = errors.New("the RESP message is incomplete")
+
+// replyTypes maps the first byte of the RESP2 and RESP3 messages to the type of
+// the reply.
+var replyTypes = map[byte]models.RedisReplyType{
+	'+': models.RedisSimpleString,
+	'-': models.RedisError,
+	':': models.RedisInteger,
+	'$': models.RedisBulkString,
+	'*': models.RedisArray,
+	'_': models.RedisNull,
+	'#': models.RedisBoolean,
+	',': models.RedisDouble,
+	'(': models.RedisBigNumber,
+	'!': models.RedisBulkError,
+	'=': models.RedisVerbatimString,
+	'%': models.RedisMap,
+	'~': models.RedisSet,
+	'|': models.RedisAttribute,
+	'>': models.RedisPush,
+}
+
+// decodeCommands decodes the commands pipelined in the buffer. It returns the
+// complete commands, along with the offset each of them ends at, and leaves
+// out the trailing partial command. It fails when the buffer is not RESP.
+func decodeCommands(buffer []byte) ([]models.RedisRequest, []int, error) {
+	commands := []models.RedisRequest{}
+	ends := []int{}
+	for offset := 0; offset < len(buffer); {
+		command, next, err := decodeCommand(buffer, offset)
+		if errors.Is(err, errIncomplete) {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		offset = next
+		if len(command.Command) == 0 {
+			// the empty inline commands are ignored by the server
+			continue
+		}
+		commands = append(commands, command)
+		ends = append(ends, offset)
+	}
+	return commands, ends, nil
+}
+
+// decodeCommand decodes the command at the offset, either an array of bulk
+// strings or an inline command, and returns the offset following it.
+func decodeCommand(buffer []byte, offset int) (models.RedisRequest, int, error) {
+	if buffer[offset] != '*' {
+		end := bytes.IndexByte(buffer[offset:], '\n')
+		if end < 0 {
+			return models.RedisRequest{}, 0, errIncomplete
+		}
+		line := strings.TrimSuffix(string(buffer[offset:offset+end]), "\r")
+		args, err := splitInline(line)
+		if err != nil {
+			return models.RedisRequest{}, 0, err
+		}
+		return models.RedisRequest{Command: args, Inline: true}, offset + end + 1, nil
+	}
+	reply, next, err := decodeReply(buffer, offset)
+	if err != nil {
+		return models.RedisRequest{}, 0, err
+	}
+	args := make([]string, 0, len(reply.Elements))
+	for _, element := range reply.Elements {
+		if element.Type != models.RedisBulkString || element.Nil {
+			return models.RedisRequest{}, 0, errors.New("the arguments of a command must be bulk strings")
+		}
+		args = append(args, element.Value)
+	}
+	return models.RedisRequest{Command: args}, next, nil
+}
+
+// splitInline splits the line of an inline command into its arguments, the
+// way the server does: they are separated by spaces and may be quoted.
+func splitInline(line string) ([]string, error) {
+	args := []string{}
+	for i := 0; i < len(line); {
+		if line[i] == ' ' || line[i] == '\t' {
+			i++
+			continue
+		}
+		var arg strings.Builder
+		switch line[i] {
+		case '"':
+			i++
+			for ; i < len(line) && line[i] != '"'; i++ {
+				if line[i] == '\\' && i+1 < len(line) {
+					i++
+					switch line[i] {
+					case 'n':
+						arg.WriteByte('\n')
+					case 'r':
+						arg.WriteByte('\r')
+					case 't':
+						arg.WriteByte('\t')
+					case 'x':
+						if i+2 < len(line) {
+							if n, err := strconv.ParseUint(line[i+1:i+3], 16, 8); err == nil {
+								arg.WriteByte(byte(n))
+								i += 2
+								continue
+							}
+						}
+						arg.WriteByte('x')
+					default:
+						arg.WriteByte(line[i])
+					}
+					continue
+				}
+				arg.WriteByte(line[i])
+			}
+			if i == len(line) {
+				return nil, errors.New("unbalanced quotes in the inline command")
+			}
+			i++
+		case '\'':
+			i++
+			for ; i < len(line) && line[i] != '\''; i++ {
+				if line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+				}
+				arg.WriteByte(line[i])
+			}
+			if i == len(line) {
+				return nil, errors.New("unbalanced quotes in the inline command")
+			}
+			i++
+		default:
+			for ; i < len(line) && line[i] != ' ' && line[i] != '\t'; i++ {
+				arg.WriteByte(line[i])
+			}
+		}
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// decodeReplies decodes the replies in the buffer. It returns the complete
+// replies and the length of the buffer they take, leaving out the trailing
+// partial reply. It fails when the buffer is not RESP.
+func decodeReplies(buffer []byte) ([]models.RedisReply, int, error) {
+	replies := []models.RedisReply{}
+	offset := 0
+	for offset < len(buffer) {
+		reply, next, err := decodeReply(buffer, offset)
+		if errors.Is(err, errIncomplete) {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		replies = append(replies, reply)
+		offset = next
+	}
+	return replies, offset, nil
+}
+
+// decodeReply decodes the RESP message at the offset and returns the offset
+// following it.
+func decodeReply(buffer []byte, offset int) (models.RedisReply, int, error) {
+	if offset >= len(buffer) {
+		return models.RedisReply{}, 0, errIncomplete
+	}
+	replyType, ok := replyTypes[buffer[offset]]
+	if !ok {
+		return models.RedisReply{}, 0, fmt.Errorf("unknown RESP type %q", buffer[offset])
+	}
+	end := bytes.Index(buffer[offset:], []byte("\r\n"))
+	if end < 0 {
+		return models.RedisReply{}, 0, errIncomplete
+	}
+	line := string(buffer[offset+1 : offset+end])
+	next := offset + end + 2
+	reply := models.RedisReply{Type: replyType}
+
+	switch replyType {
+	case models.RedisSimpleString, models.RedisError, models.RedisInteger, models.RedisNull,
+		models.RedisBoolean, models.RedisDouble, models.RedisBigNumber:
+		reply.Value = line
+		return reply, next, nil
+	case models.RedisBulkString, models.RedisBulkError, models.RedisVerbatimString:
+		length, err := strconv.Atoi(line)
+		if err != nil || length < -1 {
+			return models.RedisReply{}, 0, fmt.Errorf("invalid length %q of a RESP string", line)
+		}
+		if length == -1 {
+			reply.Nil = true
+			return reply, next, nil
+		}
+		if next+length+2 > len(buffer) {
+			return models.RedisReply{}, 0, errIncomplete
+		}
+		if !bytes.Equal(buffer[next+length:next+length+2], []byte("\r\n")) {
+			return models.RedisReply{}, 0, errors.New("a RESP string doesn't end with CRLF")
+		}
+		reply.Value = string(buffer[next : next+length])
+		return reply, next + length + 2, nil
+	}
+
+	count, err := strconv.Atoi(line)
+	if err != nil || count < -1 {
+		return models.RedisReply{}, 0, fmt.Errorf("invalid length %q of a RESP aggregate", line)
+	}
+	if count == -1 {
+		reply.Nil = true
+		return reply, next, nil
+	}
+	if replyType == models.RedisMap || replyType == models.RedisAttribute {
+		// the keys and the values are counted in pairs
+		count *= 2
+	}
+	reply.Elements = []models.RedisReply{}
+	for i := 0; i < count; i++ {
+		var element models.RedisReply
+		element, next, err = decodeReply(buffer, next)
+		if err != nil {
+			return models.RedisReply{}, 0, err
+		}
+		reply.Elements = append(reply.Elements, element)
+	}
+	return reply, next, nil
+}
+
+// encodeReplies encodes the replies back into RESP.
+func encodeReplies(replies []models.RedisReply) []byte {
+	var buffer []byte
+	for _, reply := range replies {
+		buffer = encodeReply(buffer, reply)
+	}
+	return buffer
+}
+
+func encodeReply(buffer []byte, reply models.RedisReply) []byte {
+	var prefix byte
+	for b, replyType := range replyTypes {
+		if replyType == reply.Type {
+			prefix = b
+		}
+	}
+	buffer = append(buffer, prefix)
+	switch reply.Type {
+	case models.RedisBulkString, models.RedisBulkError, models.RedisVerbatimString:
+		if reply.Nil {
+			return append(buffer, "-1\r\n"...)
+		}
+		buffer = strconv.AppendInt(buffer, int64(len(reply.Value)), 10)
+		buffer = append(buffer, "\r\n"...)
+		buffer = append(buffer, reply.Value...)
+		return append(buffer, "\r\n"...)
+	case models.RedisArray, models.RedisMap, models.RedisSet, models.RedisAttribute, models.RedisPush:
+		if reply.Nil {
+			return append(buffer, "-1\r\n"...)
+		}
+		count := len(reply.Elements)
+		if reply.Type == models.RedisMap || reply.Type == models.RedisAttribute {
+			count /= 2
+		}
+		buffer = strconv.AppendInt(buffer, int64(count), 10)
+		buffer = append(buffer, "\r\n"...)
+		for _, element := range reply.Elements {
+			buffer = encodeReply(buffer, element)
+		}
+		return buffer
+	}
+	buffer = append(buffer, reply.Value...)
+	return append(buffer, "\r\n"...)
+}
+
+// subscriptionCommands are confirmed once per channel, by an array in RESP2
+// and a pushed message in RESP3.
+var subscriptionCommands = map[string]bool{
+	"SUBSCRIBE":    true,
+	"PSUBSCRIBE":   true,
+	"SSUBSCRIBE":   true,
+	"UNSUBSCRIBE":  true,
+	"PUNSUBSCRIBE": true,
+	"SUNSUBSCRIBE": true,
+}
+
+// answered reports whether the replies complete the answer of the command: a
+// subscription command awaits the confirmations of all its channels, the
+// others a reply, the pushed messages and the attributes preceding it
+// answering none. An UNSUBSCRIBE naming no channel awaits a confirmation.
+func answered(command models.RedisRequest, replies []models.RedisReply) bool {
+	subscription := len(command.Command) > 0 && subscriptionCommands[strings.ToUpper(command.Command[0])]
+	expected := 1
+	if subscription && len(command.Command) > 1 {
+		expected = len(command.Command) - 1
+	}
+	n := 0
+	for _, reply := range replies {
+		switch {
+		case reply.Type == models.RedisError || reply.Type == models.RedisBulkError:
+			return true
+		case subscription:
+			if isConfirmation(reply) {
+				n++
+			}
+		case reply.Type != models.RedisPush && reply.Type != models.RedisAttribute:
+			n++
+		}
+	}
+	return n >= expected
+}
+
+// isConfirmation reports whether the reply confirms a subscription command.
+func isConfirmation(reply models.RedisReply) bool {
+	if reply.Type != models.RedisArray && reply.Type != models.RedisPush || len(reply.Elements) == 0 {
+		return false
+	}
+	return subscriptionCommands[strings.ToUpper(reply.Elements[0].Value)]
+}
@@ -0,0 +1,97 @@
+package redisparser
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestDecodeReply(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame string
+		want  models.RedisReply
+	}{
+		{"simple string", "+OK\r\n", models.RedisReply{Type: models.RedisSimpleString, Value: "OK"}},
+		{"error", "-ERR unknown command\r\n", models.RedisReply{Type: models.RedisError, Value: "ERR unknown command"}},
+		{"integer", ":-42\r\n", models.RedisReply{Type: models.RedisInteger, Value: "-42"}},
+		{"bulk string", "$7\r\nfoo\r\nba\r\n", models.RedisReply{Type: models.RedisBulkString, Value: "foo\r\nba"}},
+		{"empty bulk string", "$0\r\n\r\n", models.RedisReply{Type: models.RedisBulkString}},
+		{"null bulk string", "$-1\r\n", models.RedisReply{Type: models.RedisBulkString, Nil: true}},
+		{"null array", "*-1\r\n", models.RedisReply{Type: models.RedisArray, Nil: true}},
+		{"nested array", "*2\r\n*2\r\n:1\r\n$1\r\na\r\n$-1\r\n", models.RedisReply{Type: models.RedisArray, Elements: []models.RedisReply{
+			{Type: models.RedisArray, Elements: []models.RedisReply{
+				{Type: models.RedisInteger, Value: "1"},
+				{Type: models.RedisBulkString, Value: "a"},
+			}},
+			{Type: models.RedisBulkString, Nil: true},
+		}}},
+		{"map", "%1\r\n+key\r\n#t\r\n", models.RedisReply{Type: models.RedisMap, Elements: []models.RedisReply{
+			{Type: models.RedisSimpleString, Value: "key"},
+			{Type: models.RedisBoolean, Value: "t"},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, next, err := decodeReply([]byte(tt.frame), 0)
+			if err != nil {
+				t.Fatalf("failed to decode %q: %v", tt.frame, err)
+			}
+			if next != len(tt.frame) {
+				t.Errorf("decoded %d bytes of %q, want %d", next, tt.frame, len(tt.frame))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decoded %q as %+v, want %+v", tt.frame, got, tt.want)
+			}
+			if encoded := encodeReply(nil, got); string(encoded) != tt.frame {
+				t.Errorf("encoded the reply back into %q, want %q", encoded, tt.frame)
+			}
+		})
+	}
+}
+
+func TestDecodeRepliesLeavesOutTheTruncatedFrame(t *testing.T) {
+	complete := "+OK\r\n:1\r\n"
+	for _, partial := range []string{"$5\r\nhel", "*2\r\n:1\r\n", "$5", "+PON"} {
+		buffer := []byte(complete + partial)
+		if _, _, err := decodeReply(buffer, len(complete)); !errors.Is(err, errIncomplete) {
+			t.Errorf("decoded the truncated frame %q with the error %v, want errIncomplete", partial, err)
+		}
+		replies, length, err := decodeReplies(buffer)
+		if err != nil {
+			t.Fatalf("failed to decode the replies before %q: %v", partial, err)
+		}
+		if len(replies) != 2 || length != len(complete) {
+			t.Errorf("decoded %d replies over %d bytes before %q, want 2 over %d", len(replies), length, partial, len(complete))
+		}
+	}
+}
+
+func TestDecodeReplyRejectsMalformedFrames(t *testing.T) {
+	for _, frame := range []string{"?1\r\n", "$x\r\n", "$3\r\nabcde\r\n", "*-2\r\n"} {
+		if _, _, err := decodeReply([]byte(frame), 0); err == nil || errors.Is(err, errIncomplete) {
+			t.Errorf("decoded the malformed frame %q with the error %v", frame, err)
+		}
+	}
+}
+
+func TestDecodeCommands(t *testing.T) {
+	buffer := []byte("*2\r\n$3\r\nGET\r\n$3\r\nkey\r\n\r\nSET k 'it\\'s' \"a\\x41\"\r\n*1\r\n$4\r\nPI")
+	commands, ends, err := decodeCommands(buffer)
+	if err != nil {
+		t.Fatalf("failed to decode the commands: %v", err)
+	}
+	want := []models.RedisRequest{
+		{Command: []string{"GET", "key"}},
+		{Command: []string{"SET", "k", "it's", "aA"}, Inline: true},
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("decoded the commands %+v, want %+v", commands, want)
+	}
+	if last := bytes.LastIndex(buffer, []byte("*1")); len(ends) != 2 || ends[1] != last {
+		t.Fatalf("ended the commands at %v, want the last one at %d", ends, last)
+	}
+}
@@ -1,7 +1,133 @@
 package proxy
 
+import (
+	"time"
+
+	"go.keploy.io/server/pkg/models"
+	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
+	"go.keploy.io/server/pkg/proxy/util"
+)
+
 // Option provides a means to initiate the proxy based on user input.
 type Option struct {
 	Port          uint32
 	MongoPassword string
+	// Http3Port is the UDP port serving the HTTP/3 calls, such as 443. The
+	// HTTP/3 calls are not intercepted when zero.
+	Http3Port uint32
+	// TLSDecrypt provides the key material used to decrypt and record the
+	// SSL sessions of postgres dependencies. The certificates signed by the
+	// CA of the proxy are used when none is provided.
+	TLSDecrypt util.TLSDecryptOptions
+	// PostgresPassthroughSSL forwards the SSL sessions of the postgres
+	// clients encrypted, instead of decrypting them with the CA of the proxy.
+	PostgresPassthroughSSL bool
+	// PostgresIntrospection overrides the server settings replayed for the
+	// postgres introspection queries, keyed by the setting name.
+	PostgresIntrospection map[string]string
+	// PostgresPassword is the password of the database user, used to replay
+	// the SCRAM and MD5 authentications of the postgres clients.
+	PostgresPassword string
+	// PostgresNoticePolicy is either "keep" (default) or "drop" to leave the
+	// NoticeResponse messages out of the postgres mocks.
+	PostgresNoticePolicy string
+	// PostgresMinSimilarity is the lowest similarity with a mock accepted
+	// for the postgres requests without an exact match, 0.5 when zero.
+	PostgresMinSimilarity float64
+	// PostgresMaxSessionDuration flushes the recorded postgres sessions at
+	// this interval. The rest of the session is not recorded unless
+	// PostgresContinueSession is set.
+	PostgresMaxSessionDuration time.Duration
+	PostgresContinueSession    bool
+	// PostgresIgnoredStartupParameters are ignored, besides application_name,
+	// when matching the startup message of the postgres clients.
+	PostgresIgnoredStartupParameters []string
+	// PostgresJSONNoise are the JSON paths ignored in the JSON parameters of
+	// the postgres queries while matching them with the mocks.
+	PostgresJSONNoise []string
+	// PostgresWriteChunkSize is the size of the writes of the replayed
+	// postgres responses. The parser default is used when zero.
+	PostgresWriteChunkSize int
+	// PostgresStalenessTolerance maps the columns read from a replica to the
+	// largest difference accepted between their recorded and live values.
+	PostgresStalenessTolerance map[string]float64
+	// PostgresIntrospectionOnly records only the schema introspection of the
+	// postgres sessions, leaving out the queries of the tests.
+	PostgresIntrospectionOnly bool
+	// PostgresMaxDataRows caps the rows recorded in a readable form for a
+	// postgres response, the larger responses are stored as raw payloads.
+	PostgresMaxDataRows int
+	// PostgresRecordUnmatched records, in test mode, the postgres connections
+	// for which no session was recorded, replaying the others.
+	PostgresRecordUnmatched bool
+	// PostgresPaceCopyOut replays the chunks of the postgres COPY OUT streams
+	// at the pace they were recorded at.
+	PostgresPaceCopyOut bool
+	// PostgresReadTimeout is how long the requests of the postgres clients
+	// are read in test mode before they are matched, 10ms when zero.
+	PostgresReadTimeout time.Duration
+	// PostgresReplayJitter delays the replayed postgres responses by a random
+	// duration, to simulate the latency of a loaded database.
+	PostgresReplayJitter postgresparser.ReplayJitter
+	// PostgresMatchMode is either "exact" (default), "normalized" or
+	// "template" to also ignore the literal values of the statements.
+	PostgresMatchMode string
+	// PostgresPaceNotifications replays the postgres notifications of the
+	// listened channels at the pace they were pushed at.
+	PostgresPaceNotifications bool
+	// PostgresSkipAuthentication lets the postgres clients in without
+	// replaying their authentication in test mode.
+	PostgresSkipAuthentication bool
+	// PostgresMaxPendingBytes caps the size of the incomplete postgres
+	// messages of a connection, 64MB when zero. In record mode the connection
+	// exceeding it is passed through unrecorded, in test mode it is closed.
+	PostgresMaxPendingBytes int
+	// PostgresRecordingWarmup leaves out the postgres exchanges of every
+	// connection until its duration or count of exchanges is over.
+	PostgresRecordingWarmup postgresparser.RecordingWarmup
+	// PostgresIgnoredFields are the values generated anew on every run left
+	// out when matching the postgres requests, replayed as recorded.
+	PostgresIgnoredFields postgresparser.IgnoredFields
+	// PostgresReplayLatency delays the replayed postgres responses by the
+	// latency they were recorded with, capped by PostgresMaxReplayLatency.
+	PostgresReplayLatency    bool
+	PostgresMaxReplayLatency time.Duration
+	// PostgresRecordRawPayloads keeps the raw payload of every recorded
+	// postgres message along with its readable form.
+	PostgresRecordRawPayloads bool
+	// PostgresDriftReporter is notified, in record mode, of the postgres
+	// exchanges whose live responses diverged from the mocks of the
+	// PostgresDriftTestSet, which the recorder loads into PostgresDriftMocks.
+	PostgresDriftReporter func(postgresparser.Drift)
+	PostgresDriftTestSet  string
+	PostgresDriftMocks    []*models.Mock
+	// PostgresDeduplicateMocks records the postgres exchanges repeated
+	// identically during a recording as a single mock.
+	PostgresDeduplicateMocks bool
+	// PostgresVirtualMockHandler answers, in test mode, the postgres requests
+	// which matched no mock. They are passed through when it returns nil.
+	// It is set by the programs embedding the proxy, not by the config.
+	PostgresVirtualMockHandler postgresparser.VirtualMockHandler
+	// PostgresStripSchemas are the schemas whose qualifiers are left out of
+	// the recorded postgres queries, and of the replayed ones to match them.
+	PostgresStripSchemas []string
+	// HeaderPredicates scope the HTTP and gRPC mocks to the requests whose
+	// headers satisfy all of them.
+	HeaderPredicates []models.HeaderPredicate
+	// DisabledIntegrations are the names of the parsers (mysql, postgres,
+	// mongo, redis, http, grpc) left out of the detection of the outgoing calls.
+	DisabledIntegrations []string
+	// MySQLGreetingCapabilities masks the capability flags advertised by the
+	// replayed MySQL server greeting, to test the clients against an older
+	// server. The recorded capabilities are replayed when zero.
+	MySQLGreetingCapabilities uint32
+	// MySQLOKNoise are the fields of the recorded MySQL OK packets generated
+	// anew on every run. The requests carrying the last insert id of their
+	// connection match the mocks recorded with another one when LastInsertID
+	// is set.
+	MySQLOKNoise models.MySQLOKNoise
+	// PassThroughRawTCP forwards the connections of the protocols none of the
+	// parsers detects unrecorded. They are recorded as generic mocks, framed
+	// by the reads of the proxy, otherwise.
+	PassThroughRawTCP bool
 }
@@ -21,6 +21,7 @@ import (
 
 	"go.keploy.io/server/pkg"
 	"go.keploy.io/server/pkg/proxy/integrations/grpcparser"
+	"go.keploy.io/server/pkg/proxy/integrations/http3parser"
 	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
 	"go.keploy.io/server/utils"
 
@@ -34,12 +35,14 @@ import (
 	"time"
 
 	"github.com/miekg/dns"
+	"github.com/quic-go/quic-go/http3"
 	"go.keploy.io/server/pkg/hooks"
 	"go.keploy.io/server/pkg/models"
 	genericparser "go.keploy.io/server/pkg/proxy/integrations/genericParser"
 	"go.keploy.io/server/pkg/proxy/integrations/httpparser"
 	"go.keploy.io/server/pkg/proxy/integrations/mongoparser"
 	"go.keploy.io/server/pkg/proxy/integrations/mysqlparser"
+	"go.keploy.io/server/pkg/proxy/integrations/redisparser"
 	"go.keploy.io/server/pkg/proxy/util"
 	"go.uber.org/zap"
 )
@@ -53,6 +56,12 @@ type DependencyHandler interface {
 
 var ParsersMap = make(map[string]DependencyHandler)
 
+// StateResetter is implemented by the parsers which keep matching state
+// across the connections, such as the prepared statements.
+type StateResetter interface {
+	ResetState()
+}
+
 type ProxySet struct {
 	IP4               uint32
 	IP6               [4]uint32
@@ -66,10 +75,15 @@ type ProxySet struct {
 	Listener          net.Listener
 	UdpDnsServer      *dns.Server
 	TcpDnsServer      *dns.Server
+	Http3Port         uint32
+	Http3Conn         net.PacketConn
+	Http3Server       *http3.Server
 	DnsServerTimeout  time.Duration
 	dockerAppCmd      bool
 	PassThroughPorts  []uint
 	MongoPassword     string // password to mock the mongo connection and pass the authentication requests
+	disabledParsers   map[string]bool
+	passThroughRawTCP bool
 }
 
 type CustomConn struct {
@@ -305,6 +319,18 @@ func Register(parserName string, parser DependencyHandler) {
 	ParsersMap[parserName] = parser
 }
 
+// ResetState restores the mocks consumed from h and resets the state kept by
+// the registered parsers, so that a test-set is replayed against its own
+// mocks only. The open connections are left untouched.
+func ResetState(h *hooks.Hook) {
+	h.ResetState()
+	for _, parser := range ParsersMap {
+		if resetter, ok := parser.(StateResetter); ok {
+			resetter.ResetState()
+		}
+	}
+}
+
 func SetupCA(logger *zap.Logger, pid uint32, lang string) error {
 	// assign default values if not provided
 	caPaths, err := getCaPaths()
@@ -365,16 +391,76 @@ func SetupCA(logger *zap.Logger, pid uint32, lang string) error {
 	return nil
 }
 
+// postgresOptions returns the options of the postgres parser configured by
+// opt. The mocks matched by similarity only are noted in h, for the reports
+// of the tests.
+func postgresOptions(opt Option, tlsDecrypt *util.TLSDecryptConfig, h *hooks.Hook) postgresparser.PostgresOptions {
+	return postgresparser.PostgresOptions{
+		TLSDecryption:          tlsDecrypt,
+		IntrospectionOverrides: opt.PostgresIntrospection,
+		Password:               opt.PostgresPassword,
+		NoticePolicy:           postgresparser.NoticePolicy(opt.PostgresNoticePolicy),
+		FuzzyMatchReporter: func(match postgresparser.FuzzyMatch) {
+			h.RecordFuzzyMatch(match.MockName)
+		},
+		MinSimilarity:            opt.PostgresMinSimilarity,
+		MaxSessionDuration:       opt.PostgresMaxSessionDuration,
+		ContinueSession:          opt.PostgresContinueSession,
+		IgnoredStartupParameters: opt.PostgresIgnoredStartupParameters,
+		JSONNoise:                opt.PostgresJSONNoise,
+		WriteChunkSize:           opt.PostgresWriteChunkSize,
+		StalenessTolerance:       opt.PostgresStalenessTolerance,
+		QueryTransform:           postgresparser.StripSchemaQualifiers(opt.PostgresStripSchemas),
+		MaxDataRows:              opt.PostgresMaxDataRows,
+		IntrospectionOnly:        opt.PostgresIntrospectionOnly,
+		RecordUnmatched:          opt.PostgresRecordUnmatched,
+		PaceCopyOut:              opt.PostgresPaceCopyOut,
+		ReadTimeout:              opt.PostgresReadTimeout,
+		ReplayJitter:             opt.PostgresReplayJitter,
+		MatchMode:                postgresparser.MatchMode(opt.PostgresMatchMode),
+		PaceNotifications:        opt.PostgresPaceNotifications,
+		SkipAuthentication:       opt.PostgresSkipAuthentication,
+		MaxPendingBytes:          opt.PostgresMaxPendingBytes,
+		RecordingWarmup:          opt.PostgresRecordingWarmup,
+		IgnoredFields:            opt.PostgresIgnoredFields,
+		ReplayLatency:            opt.PostgresReplayLatency,
+		MaxReplayLatency:         opt.PostgresMaxReplayLatency,
+		RecordRawPayloads:        opt.PostgresRecordRawPayloads,
+		DriftReporter:            opt.PostgresDriftReporter,
+		DriftMocks:               opt.PostgresDriftMocks,
+		DeduplicateMocks:         opt.PostgresDeduplicateMocks,
+		VirtualMockHandler:       opt.PostgresVirtualMockHandler,
+	}
+}
+
 // BootProxy starts proxy server on the idle local port, Default:16789
 func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid uint32, lang string, passThroughPorts []uint, h *hooks.Hook, ctx context.Context, delay uint64) *ProxySet {
 	//Register all the parsers in the map.
-	Register("grpc", grpcparser.NewGrpcParser(logger, h))
-	Register("postgres", postgresparser.NewPostgresParser(logger, h))
+	grpcParser := grpcparser.NewGrpcParser(logger, h)
+	grpcParser.SetHeaderPredicates(opt.HeaderPredicates)
+	Register("grpc", grpcParser)
+	tlsDecrypt, err := util.LoadTLSDecryptConfig(opt.TLSDecrypt)
+	if err != nil {
+		logger.Error("failed to load the keys for decrypting the postgres SSL sessions", zap.Error(err))
+	}
+	if tlsDecrypt == nil && !opt.PostgresPassthroughSSL {
+		tlsDecrypt, err = caTLSDecryptConfig()
+		if err != nil {
+			logger.Error("failed to load the CA of the proxy for decrypting the postgres SSL sessions", zap.Error(err))
+		}
+	}
+	Register("postgres", postgresparser.NewPostgresParserWithOptions(logger, h, postgresOptions(opt, tlsDecrypt, h)))
 	Register("mongo", mongoparser.NewMongoParser(logger, h, opt.MongoPassword))
-	Register("http", httpparser.NewHttpParser(logger, h))
-	Register("mysql", mysqlparser.NewMySqlParser(logger, h, delay))
+	httpParser := httpparser.NewHttpParser(logger, h)
+	httpParser.SetHeaderPredicates(opt.HeaderPredicates)
+	Register("http", httpParser)
+	mysqlParser := mysqlparser.NewMySqlParser(logger, h, delay)
+	mysqlParser.SetGreetingCapabilities(opt.MySQLGreetingCapabilities)
+	mysqlParser.SetOKNoise(opt.MySQLOKNoise)
+	Register("mysql", mysqlParser)
+	Register("redis", redisparser.NewRedisParser(logger, h))
 	// Setup the CA store for TLS-integeration
-	err := SetupCA(logger, pid, lang)
+	err = SetupCA(logger, pid, lang)
 	if err != nil {
 		return nil
 	}
@@ -421,6 +507,7 @@ func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid
 
 	var proxySet = ProxySet{
 		Port:              opt.Port,
+		Http3Port:         opt.Http3Port,
 		IP4:               proxyAddr4,
 		IP6:               proxyAddr6,
 		logger:            logger,
@@ -430,6 +517,8 @@ func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid
 		PassThroughPorts:  passThroughPorts,
 		hook:              h,
 		MongoPassword:     opt.MongoPassword,
+		disabledParsers:   disabledIntegrations(opt.DisabledIntegrations, logger),
+		passThroughRawTCP: opt.PassThroughRawTCP,
 	}
 
 	//setting the proxy port field in hook
@@ -463,6 +552,22 @@ func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid
 				proxySet.startUdpDnsServer()
 			}()
 		}
+
+		// Serve the HTTP/3 calls over QUIC when a port is set for them. The
+		// server is set up before the proxy is returned, so that stopping the
+		// proxy closes it.
+		if proxySet.Http3Port != 0 {
+			err := proxySet.listenHttp3(http3parser.NewHttp3Parser(logger, h, ctx))
+			if err != nil {
+				proxySet.logger.Warn("failed to start the http3 proxy, the HTTP/3 calls are not intercepted", zap.Uint32("port", proxySet.Http3Port), zap.Error(err))
+			} else {
+				go func() {
+					defer h.Recover(pkg.GenerateRandomID())
+					defer utils.HandlePanic()
+					proxySet.serveHttp3()
+				}()
+			}
+		}
 	} else {
 		// TODO: Release eBPF resources if failed abruptly
 		log.Fatalf(Emoji+"Failed to start Proxy at [Port:%v]: %v", opt.Port, err)
@@ -476,6 +581,24 @@ func BootProxy(logger *zap.Logger, opt Option, appCmd, appContainer string, pid
 }
 
 // isPortAvailable function checks whether a local port is occupied and returns a boolean value indicating its availability.
+// disabledIntegrations returns the set of the registered parsers named in
+// names, case insensitively. Unknown names are logged and ignored.
+func disabledIntegrations(names []string, logger *zap.Logger) map[string]bool {
+	disabled := make(map[string]bool)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := ParsersMap[name]; !ok {
+			logger.Warn("ignoring an unknown integration to disable", zap.String("integration", name))
+			continue
+		}
+		disabled[name] = true
+	}
+	if len(disabled) > 0 {
+		logger.Info("disabled the integrations", zap.Any("integrations", names))
+	}
+	return disabled
+}
+
 func isPortAvailable(port uint32) bool {
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%v", port))
 	if err != nil {
@@ -517,14 +640,19 @@ var (
 func certForClient(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	// Generate a new server certificate and private key for the given hostname
 	destinationUrl = clientHello.ServerName
+	return signCertificate(clientHello.ServerName, caPrivKey, caCertParsed)
+}
 
+// signCertificate issues a server certificate for the host, signed by the CA
+// of the proxy which is trusted by the application.
+func signCertificate(host string, caKey interface{}, caCert *x509.Certificate) (*tls.Certificate, error) {
 	cfsslLog.Level = cfsslLog.LevelError
 
 	serverReq := &csr.CertificateRequest{
 		//Make the name accordng to the ip of the request
-		CN: clientHello.ServerName,
+		CN: host,
 		Hosts: []string{
-			clientHello.ServerName,
+			host,
 		},
 		KeyRequest: csr.NewKeyRequest(),
 	}
@@ -533,11 +661,11 @@ func certForClient(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 	if err != nil {
 		return nil, fmt.Errorf(Emoji+"failed to create server CSR: %v", err)
 	}
-	cryptoSigner, ok := caPrivKey.(crypto.Signer)
+	cryptoSigner, ok := caKey.(crypto.Signer)
 	if !ok {
 		log.Printf(Emoji, "Error in typecasting the caPrivKey")
 	}
-	signerd, err := local.NewSigner(cryptoSigner, caCertParsed, signer.DefaultSigAlgo(cryptoSigner), nil)
+	signerd, err := local.NewSigner(cryptoSigner, caCert, signer.DefaultSigAlgo(cryptoSigner), nil)
 	if err != nil {
 		return nil, fmt.Errorf(Emoji+"failed to create signer: %v", err)
 	}
@@ -772,6 +900,34 @@ func isTLSHandshake(data []byte) bool {
 	return data[0] == 0x16 && data[1] == 0x03 && (data[2] == 0x00 || data[2] == 0x01 || data[2] == 0x02 || data[2] == 0x03)
 }
 
+// caTLSDecryptConfig terminates the SSL sessions of the postgres clients with
+// certificates signed by the CA of the proxy, the same way as the TLS
+// connections of the HTTP clients. The clients connecting by IP send no server
+// name, their certificate is issued for localhost.
+func caTLSDecryptConfig() (*util.TLSDecryptConfig, error) {
+	caKey, err := helpers.ParsePrivateKeyPEM(caPKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the CA private key: %v", err)
+	}
+	caCert, err := helpers.ParseCertificatePEM(caCrt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the CA certificate: %v", err)
+	}
+	return &util.TLSDecryptConfig{
+		Server: &tls.Config{
+			GetCertificate: func(clientHello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+				if clientHello.ServerName == "" {
+					return signCertificate("localhost", caKey, caCert)
+				}
+				return signCertificate(clientHello.ServerName, caKey, caCert)
+			},
+		},
+		Client: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}, nil
+}
+
 func (ps *ProxySet) handleTLSConnection(conn net.Conn) (net.Conn, error) {
 	//Load the CA certificate and private key
 
@@ -843,7 +999,7 @@ func (ps *ProxySet) handleConnection(conn net.Conn, port uint32, ctx context.Con
 	// releases the occupied source port when done fetching the destination info
 	ps.hook.CleanProxyEntry(uint16(sourcePort))
 	//checking for the destination port of mysql
-	if destInfo.DestPort == 3306 {
+	if destInfo.DestPort == 3306 && !ps.disabledParsers["mysql"] {
 		var dst net.Conn
 		var actualAddress = ""
 		if destInfo.IpVersion == 4 {
@@ -955,13 +1111,22 @@ func (ps *ProxySet) handleConnection(conn net.Conn, port uint32, ctx context.Con
 		}
 		genericCheck := true
 		//Checking for all the parsers.
-		for _, parser := range ParsersMap {
+		for name, parser := range ParsersMap {
+			if ps.disabledParsers[name] {
+				continue
+			}
 			if parser.OutgoingType(buffer) {
 				parser.ProcessOutgoing(buffer, conn, dst, ctx)
 				genericCheck = false
 			}
 		}
-		if genericCheck {
+		if genericCheck && models.GetMode() == models.MODE_RECORD && ps.passThroughRawTCP {
+			logger.Debug("The external dependency is not supported. Hence passing it through unrecorded")
+			err = ps.callNext(buffer, conn, dst, logger)
+			if err != nil {
+				logger.Error("failed to pass through the outgoing call", zap.Error(err))
+			}
+		} else if genericCheck {
 			logger.Debug("The external dependency is not supported. Hence using generic parser")
 			genericparser.ProcessGeneric(buffer, conn, dst, ps.hook, logger, ctx)
 		}
@@ -1061,6 +1226,19 @@ func (ps *ProxySet) StopProxyServer() {
 		}
 	}
 
+	if ps.Http3Server != nil {
+		err := ps.Http3Server.Close()
+		if err != nil {
+			ps.logger.Error("failed to stop http3 proxy", zap.Error(err))
+		}
+		// the server doesn't close the UDP socket it was handed
+		err = ps.Http3Conn.Close()
+		if err != nil {
+			ps.logger.Error("failed to close the http3 proxy socket", zap.Error(err))
+		}
+		ps.logger.Info("Http3 proxy stopped")
+	}
+
 	// stop udp dns server & tcp dns server
 	if ps.UdpDnsServer != nil {
 		err := ps.UdpDnsServer.Shutdown()
@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.keploy.io/server/pkg/hooks"
+	"go.keploy.io/server/pkg/models"
+	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
+	"go.uber.org/zap"
+)
+
+// resettableParser counts the resets of its state.
+type resettableParser struct {
+	resets int
+}
+
+func (p *resettableParser) OutgoingType(buffer []byte) bool { return false }
+
+func (p *resettableParser) ProcessOutgoing(buffer []byte, conn net.Conn, dst net.Conn, ctx context.Context) {
+}
+
+func (p *resettableParser) ResetState() { p.resets++ }
+
+func TestResetStateRestoresTheConsumedMocks(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	parser := &resettableParser{}
+	Register("resettable", parser)
+	defer delete(ParsersMap, "resettable")
+
+	tcsMocks := []*models.Mock{{Name: "mock-0"}, {Name: "mock-1"}}
+	configMocks := []*models.Mock{{Name: "config-0"}}
+	h.SetTcsMocks(tcsMocks)
+	h.SetConfigMocks(configMocks)
+
+	// the first scenario consumes a mock of each kind
+	if !h.DeleteTcsMock(tcsMocks[0]) || !h.DeleteConfigMock(configMocks[0]) {
+		t.Fatalf("failed to consume the mocks")
+	}
+	if mocks, _ := h.GetTcsMocks(); len(mocks) != 1 {
+		t.Fatalf("left %d mocks to match after consuming one, want 1", len(mocks))
+	}
+
+	ResetState(h)
+	tcs, _ := h.GetTcsMocks()
+	config, _ := h.GetConfigMocks()
+	if len(tcs) != 2 || tcs[0].Name != "mock-0" || len(config) != 1 {
+		t.Fatalf("left %d mocks and %d config mocks to match after the reset, want all of them", len(tcs), len(config))
+	}
+	if h.IsMockConsumed("mock-0") || h.IsMockConsumed("config-0") {
+		t.Fatalf("the mocks are still consumed after the reset")
+	}
+	if parser.resets != 1 {
+		t.Fatalf("reset the state of the parser %d times, want once", parser.resets)
+	}
+
+	// the next scenario consumes the restored mock again
+	if !h.DeleteTcsMock(tcs[0]) {
+		t.Fatalf("failed to consume the restored mock")
+	}
+
+	// a matcher updating a mock in place leaves the loaded one untouched
+	tcs[1].Spec.Metadata = map[string]string{"updated": "true"}
+	ResetState(h)
+	tcs, _ = h.GetTcsMocks()
+	if len(tcs) != 2 || tcs[1].Spec.Metadata["updated"] != "" {
+		t.Fatalf("restored the mocks %v updated by the former scenario", tcs)
+	}
+}
+
+func TestPostgresOptionsReportTheFuzzyMatches(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	opts := postgresOptions(Option{}, nil, h)
+	opts.FuzzyMatchReporter(postgresparser.FuzzyMatch{MockName: "mock-3", Similarity: 0.8})
+	opts.FuzzyMatchReporter(postgresparser.FuzzyMatch{MockName: "mock-5", Similarity: 0.6})
+	if matches := h.GetFuzzyMatches(); len(matches) != 2 || matches[0] != "mock-3" || matches[1] != "mock-5" {
+		t.Fatalf("noted the fuzzy matches %v, want mock-3 and mock-5", matches)
+	}
+
+	ResetState(h)
+	if matches := h.GetFuzzyMatches(); len(matches) != 0 {
+		t.Fatalf("kept the fuzzy matches %v after the reset", matches)
+	}
+}
+
+func TestPostgresOptionsVirtualMockHandler(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	if opts := postgresOptions(Option{}, nil, h); opts.VirtualMockHandler != nil {
+		t.Fatalf("answers the unmatched requests without a handler configured")
+	}
+	answered := false
+	opts := postgresOptions(Option{PostgresVirtualMockHandler: func(requests []models.Backend) *models.Frontend {
+		answered = true
+		return nil
+	}}, nil, h)
+	if opts.VirtualMockHandler == nil {
+		t.Fatalf("dropped the virtual mock handler of the options")
+	}
+	opts.VirtualMockHandler(nil)
+	if !answered {
+		t.Fatalf("the parser is handed another handler than the configured one")
+	}
+}
+
+func TestPostgresOptionsStripSchemas(t *testing.T) {
+	h, err := hooks.NewHook(nil, 0, zap.NewNop())
+	if err != nil {
+		t.Fatalf("failed to create the hooks: %v", err)
+	}
+	if opts := postgresOptions(Option{}, nil, h); opts.QueryTransform != nil {
+		t.Fatalf("transforms the queries without any schema to strip")
+	}
+	opts := postgresOptions(Option{PostgresStripSchemas: []string{"app"}}, nil, h)
+	if opts.QueryTransform == nil {
+		t.Fatalf("dropped the schemas to strip of the options")
+	}
+	if got := opts.QueryTransform("SELECT * FROM app.users"); got != "SELECT * FROM users" {
+		t.Fatalf("transformed the query into %q", got)
+	}
+}
+
+func TestDisabledIntegrations(t *testing.T) {
+	Register("resettable", &resettableParser{})
+	defer delete(ParsersMap, "resettable")
+
+	disabled := disabledIntegrations([]string{" Resettable ", "cassandra"}, zap.NewNop())
+	if len(disabled) != 1 || !disabled["resettable"] {
+		t.Fatalf("disabled the integrations %v, want resettable only", disabled)
+	}
+	if disabled := disabledIntegrations(nil, zap.NewNop()); len(disabled) != 0 {
+		t.Fatalf("disabled the integrations %v without any configured", disabled)
+	}
+}
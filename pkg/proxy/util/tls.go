@@ -0,0 +1,77 @@
+package util
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+)
+
+// TLSDecryptOptions holds the key material used to terminate a TLS session
+// between the application and a dependency so that the plaintext wire
+// messages can be parsed and recorded.
+type TLSDecryptOptions struct {
+	// CertFile and KeyFile are the PEM encoded certificate and private key of
+	// the dependency server. The proxy presents them to the application.
+	CertFile string
+	KeyFile  string
+	// KeyLogFile, when set, receives the TLS session secrets in the NSS key log
+	// format (same as SSLKEYLOGFILE) so captured sessions can be decrypted later.
+	KeyLogFile string
+}
+
+// Enabled reports whether key material has been provided.
+func (o TLSDecryptOptions) Enabled() bool {
+	return o.CertFile != "" && o.KeyFile != ""
+}
+
+// TLSDecryptConfig is the loaded form of TLSDecryptOptions. Server is used
+// to terminate the client side of the connection and Client to establish the
+// session with the destination server.
+type TLSDecryptConfig struct {
+	Server *tls.Config
+	Client *tls.Config
+}
+
+// LoadTLSDecryptConfig loads the certificate and key referenced by opts. It
+// returns nil when no key material has been provided.
+func LoadTLSDecryptConfig(opts TLSDecryptOptions) (*TLSDecryptConfig, error) {
+	if !opts.Enabled() {
+		return nil, nil
+	}
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the tls key pair: %v", err)
+	}
+	cfg := &TLSDecryptConfig{
+		Server: &tls.Config{
+			Certificates: []tls.Certificate{cert},
+		},
+		Client: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}
+	if opts.KeyLogFile != "" {
+		keyLog, err := os.OpenFile(opts.KeyLogFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the tls key log file: %v", err)
+		}
+		cfg.Server.KeyLogWriter = keyLog
+		cfg.Client.KeyLogWriter = keyLog
+	}
+	return cfg, nil
+}
+
+// UpgradeToTLS performs the TLS handshakes on both sides of an intercepted
+// connection and returns the wrapped client and destination connections.
+func UpgradeToTLS(clientConn, destConn net.Conn, cfg *TLSDecryptConfig) (net.Conn, net.Conn, error) {
+	clientTLS := tls.Server(clientConn, cfg.Server)
+	if err := clientTLS.Handshake(); err != nil {
+		return nil, nil, fmt.Errorf("failed to complete the tls handshake with the client: %w", err)
+	}
+	destTLS := tls.Client(destConn, cfg.Client)
+	if err := destTLS.Handshake(); err != nil {
+		return nil, nil, fmt.Errorf("failed to complete the tls handshake with the destination: %v", err)
+	}
+	return clientTLS, destTLS, nil
+}
@@ -0,0 +1,163 @@
+package util
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeKeyPair writes a self-signed certificate of the dependency server and
+// its private key into the directory and returns their paths.
+func writeKeyPair(t *testing.T, dir string) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate the key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "postgres"},
+		DNSNames:     []string{"postgres"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create the certificate: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal the key: %v", err)
+	}
+	certFile, keyFile := filepath.Join(dir, "server.crt"), filepath.Join(dir, "server.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatalf("failed to write the certificate: %v", err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatalf("failed to write the key: %v", err)
+	}
+	return certFile, keyFile
+}
+
+func TestLoadTLSDecryptConfigWithoutKeys(t *testing.T) {
+	cfg, err := LoadTLSDecryptConfig(TLSDecryptOptions{CertFile: "server.crt"})
+	if cfg != nil || err != nil {
+		t.Fatalf("loaded the config %v: %v without a key", cfg, err)
+	}
+	if _, err := LoadTLSDecryptConfig(TLSDecryptOptions{CertFile: "missing.crt", KeyFile: "missing.key"}); err == nil {
+		t.Fatalf("loaded the config of missing key files")
+	}
+}
+
+func TestUpgradeToTLSDecryptsTheSession(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeKeyPair(t, dir)
+	keyLogFile := filepath.Join(dir, "keys.log")
+	cfg, err := LoadTLSDecryptConfig(TLSDecryptOptions{CertFile: certFile, KeyFile: keyFile, KeyLogFile: keyLogFile})
+	if err != nil {
+		t.Fatalf("failed to load the config: %v", err)
+	}
+	defer cfg.Server.KeyLogWriter.(*os.File).Close()
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("failed to load the key pair: %v", err)
+	}
+
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		t.Fatalf("failed to read the certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+
+	app, clientConn := net.Pipe()
+	destConn, server := net.Pipe()
+	defer app.Close()
+	defer server.Close()
+	request, response := []byte("SELECT 1"), []byte("1 row")
+
+	appErr := make(chan error, 1)
+	go func() {
+		// the application trusts the certificate of the dependency served
+		// by the proxy
+		conn := tls.Client(app, &tls.Config{RootCAs: roots, ServerName: "postgres"})
+		if _, err := conn.Write(request); err != nil {
+			appErr <- err
+			return
+		}
+		answer := make([]byte, len(response))
+		if _, err := io.ReadFull(conn, answer); err != nil {
+			appErr <- err
+			return
+		}
+		if !bytes.Equal(answer, response) {
+			appErr <- io.ErrUnexpectedEOF
+			return
+		}
+		appErr <- nil
+	}()
+	serverErr := make(chan error, 1)
+	go func() {
+		conn := tls.Server(server, &tls.Config{Certificates: []tls.Certificate{cert}})
+		received := make([]byte, len(request))
+		if _, err := io.ReadFull(conn, received); err != nil {
+			serverErr <- err
+			return
+		}
+		if !bytes.Equal(received, request) {
+			serverErr <- io.ErrUnexpectedEOF
+			return
+		}
+		_, err := conn.Write(response)
+		serverErr <- err
+	}()
+
+	clientTLS, destTLS, err := UpgradeToTLS(clientConn, destConn, cfg)
+	if err != nil {
+		t.Fatalf("failed to upgrade the connections: %v", err)
+	}
+	// the proxy reads the plaintext of both sides
+	plaintext := make([]byte, len(request))
+	if _, err := io.ReadFull(clientTLS, plaintext); err != nil || !bytes.Equal(plaintext, request) {
+		t.Fatalf("decrypted the request %q: %v, want %q", plaintext, err, request)
+	}
+	if _, err := destTLS.Write(plaintext); err != nil {
+		t.Fatalf("failed to forward the request: %v", err)
+	}
+	plaintext = make([]byte, len(response))
+	if _, err := io.ReadFull(destTLS, plaintext); err != nil || !bytes.Equal(plaintext, response) {
+		t.Fatalf("decrypted the response %q: %v, want %q", plaintext, err, response)
+	}
+	if _, err := clientTLS.Write(plaintext); err != nil {
+		t.Fatalf("failed to forward the response: %v", err)
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatalf("the server failed to read the request: %v", err)
+	}
+	if err := <-appErr; err != nil {
+		t.Fatalf("the application failed to read the response: %v", err)
+	}
+
+	// the secrets of both sessions are logged for the captures
+	keyLog, err := os.ReadFile(keyLogFile)
+	if err != nil {
+		t.Fatalf("failed to read the key log: %v", err)
+	}
+	if count := bytes.Count(keyLog, []byte("CLIENT_TRAFFIC_SECRET_0")); count != 2 {
+		t.Fatalf("logged the secrets of %d sessions, want 2:\n%s", count, keyLog)
+	}
+}
@@ -64,6 +64,12 @@ func ValidatePath(path string) (string, error) {
 
 // createYamlFile is used to create the yaml file along with the path directory (if does not exists)
 func CreateYamlFile(path string, fileName string, Logger *zap.Logger) (bool, error) {
+	return CreateYamlFileWith(path, fileName, ShareWithAllUsers, Logger)
+}
+
+// CreateYamlFileWith creates the yaml file like CreateYamlFile, the keploy
+// directory of the created file being shared through share.
+func CreateYamlFileWith(path string, fileName string, share func(path string) error, Logger *zap.Logger) (bool, error) {
 	// checks id the yaml exists
 	yamlPath, err := ValidatePath(filepath.Join(path, fileName+".yaml"))
 	if err != nil {
@@ -91,8 +97,7 @@ func CreateYamlFile(path string, fileName string, Logger *zap.Logger) (bool, err
 			keployPath = filepath.Join(strings.TrimSuffix(path, filepath.Base(path)))
 		}
 		Logger.Debug("the path to the generated keploy directory", zap.Any("path", keployPath))
-		cmd := exec.Command("sudo", "chmod", "-R", "777", keployPath)
-		err = cmd.Run()
+		err = share(keployPath)
 		if err != nil {
 			Logger.Error("failed to set the permission of keploy directory", zap.Error(err))
 			return false, err
@@ -103,11 +108,44 @@ func CreateYamlFile(path string, fileName string, Logger *zap.Logger) (bool, err
 	return false, nil
 }
 
+// ShareWithAllUsers opens the permissions of the directory, and of the files
+// in it, to all the users.
+func ShareWithAllUsers(path string) error {
+	return exec.Command("sudo", "chmod", "-R", "777", path).Run()
+}
+
+// ErrSelfReferentialDestination is returned when the destination of an
+// outgoing call is the proxy itself, which would forward the call to itself
+// forever.
+var ErrSelfReferentialDestination = errors.New("the destination of the outgoing call is the keploy proxy itself, check that the proxy port is not the port of the dependency")
+
+// IsSelfReferential reports whether destConn is connected to the address the
+// proxy accepted clientConn on.
+func IsSelfReferential(clientConn, destConn net.Conn) bool {
+	if clientConn == nil || destConn == nil {
+		return false
+	}
+	proxyAddr, ok := clientConn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	destAddr, ok := destConn.RemoteAddr().(*net.TCPAddr)
+	if !ok || destAddr.Port != proxyAddr.Port {
+		return false
+	}
+	// the proxy listens on every interface, so that the loopback reaches it
+	return destAddr.IP.Equal(proxyAddr.IP) || destAddr.IP.IsLoopback() || destAddr.IP.IsUnspecified()
+}
+
 func Passthrough(clientConn, destConn net.Conn, requestBuffer [][]byte, recover func(id int), logger *zap.Logger) ([]byte, error) {
 
 	if destConn == nil {
 		return nil, errors.New("failed to pass network traffic to the destination connection")
 	}
+	if IsSelfReferential(clientConn, destConn) {
+		logger.Error("refusing to pass the network traffic back to the proxy", zap.Any("Destination Addr", destConn.RemoteAddr().String()))
+		return nil, ErrSelfReferentialDestination
+	}
 	logger.Debug("trying to forward requests to target", zap.Any("Destination Addr", destConn.RemoteAddr().String()))
 	for _, v := range requestBuffer {
 		_, err := destConn.Write(v)
@@ -274,6 +312,30 @@ func ReadRequiredBytes(reader io.Reader, numBytes int) ([]byte, error) {
 	return buffer, nil
 }
 
+// WriteChunked writes the buffer in chunks of at most chunkSize bytes, going
+// on from where a partial write stopped. A chunkSize of zero or less writes
+// the buffer at once.
+func WriteChunked(writer io.Writer, buffer []byte, chunkSize int) error {
+	if chunkSize <= 0 {
+		chunkSize = len(buffer)
+	}
+	for len(buffer) > 0 {
+		end := chunkSize
+		if end > len(buffer) {
+			end = len(buffer)
+		}
+		n, err := writer.Write(buffer[:end])
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return io.ErrShortWrite
+		}
+		buffer = buffer[n:]
+	}
+	return nil
+}
+
 func GetLocalIPv4() (net.IP, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// chunkWriter records the size of the writes and accepts at most limit bytes
+// of each, all of them when limit is zero.
+type chunkWriter struct {
+	bytes.Buffer
+	limit  int
+	writes []int
+	err    error
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.writes = append(w.writes, len(p))
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.limit > 0 && len(p) > w.limit {
+		p = p[:w.limit]
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestWriteChunked(t *testing.T) {
+	buffer := bytes.Repeat([]byte("0123456789"), 10)
+	for _, tt := range []struct {
+		name      string
+		chunkSize int
+		limit     int
+		writes    []int
+	}{
+		{name: "chunks", chunkSize: 30, writes: []int{30, 30, 30, 10}},
+		{name: "single chunk", chunkSize: 100, writes: []int{100}},
+		{name: "larger chunk", chunkSize: 1000, writes: []int{100}},
+		{name: "unbounded", chunkSize: 0, writes: []int{100}},
+		{name: "partial writes", chunkSize: 40, limit: 25, writes: []int{40, 40, 40, 25}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			writer := &chunkWriter{limit: tt.limit}
+			if err := WriteChunked(writer, buffer, tt.chunkSize); err != nil {
+				t.Fatalf("failed to write: %v", err)
+			}
+			if !bytes.Equal(writer.Bytes(), buffer) {
+				t.Errorf("got %q, want %q", writer.Bytes(), buffer)
+			}
+			if !reflect.DeepEqual(writer.writes, tt.writes) {
+				t.Errorf("got the writes %v, want %v", writer.writes, tt.writes)
+			}
+		})
+	}
+}
+
+func TestWriteChunkedErrors(t *testing.T) {
+	closed := errors.New("closed")
+	if err := WriteChunked(&chunkWriter{err: closed}, []byte("data"), 2); err != closed {
+		t.Errorf("got the error %v, want %v", err, closed)
+	}
+	if err := WriteChunked(&zeroWriter{}, []byte("data"), 2); err != io.ErrShortWrite {
+		t.Errorf("got the error %v, want %v", err, io.ErrShortWrite)
+	}
+	if err := WriteChunked(&chunkWriter{err: closed}, nil, 2); err != nil {
+		t.Errorf("got the error %v writing nothing", err)
+	}
+}
+
+// zeroWriter accepts nothing without failing.
+type zeroWriter struct{}
+
+func (zeroWriter) Write(p []byte) (int, error) {
+	return 0, nil
+}
+
+// acceptedConn returns the connection the listener accepted from a client
+// dialing it, closed along with the test.
+func acceptedConn(t *testing.T, listener net.Listener) net.Conn {
+	t.Helper()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- conn
+	}()
+	client, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial the listener: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	conn := <-accepted
+	if conn == nil {
+		t.Fatalf("failed to accept the connection")
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestIsSelfReferential(t *testing.T) {
+	proxy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer proxy.Close()
+	dependency, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer dependency.Close()
+
+	clientConn := acceptedConn(t, proxy)
+	looped, err := net.Dial("tcp", proxy.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial the proxy: %v", err)
+	}
+	defer looped.Close()
+	destConn, err := net.Dial("tcp", dependency.Addr().String())
+	if err != nil {
+		t.Fatalf("failed to dial the dependency: %v", err)
+	}
+	defer destConn.Close()
+	piped, other := net.Pipe()
+	defer piped.Close()
+	defer other.Close()
+
+	for _, tt := range []struct {
+		name     string
+		destConn net.Conn
+		want     bool
+	}{
+		{name: "proxy", destConn: looped, want: true},
+		{name: "dependency", destConn: destConn},
+		{name: "not a TCP connection", destConn: piped},
+		{name: "no destination"},
+	} {
+		if got := IsSelfReferential(clientConn, tt.destConn); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := Passthrough(clientConn, looped, [][]byte{[]byte("PING")}, func(int) {}, zap.NewNop()); err != ErrSelfReferentialDestination {
+		t.Errorf("got the error %v passing through to the proxy, want %v", err, ErrSelfReferentialDestination)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -15,6 +17,7 @@ import (
 	"go.keploy.io/server/pkg/platform/telemetry"
 	"go.keploy.io/server/pkg/platform/yaml"
 	"go.keploy.io/server/pkg/proxy"
+	postgresparser "go.keploy.io/server/pkg/proxy/integrations/postgresParser"
 	"go.uber.org/zap"
 )
 
@@ -30,20 +33,94 @@ func NewRecorder(logger *zap.Logger) Recorder {
 	}
 }
 
-func (r *recorder) StartCaptureTraffic(path string, proxyPort uint32, appCmd, appContainer, appNetwork string, delay uint64, buildDelay time.Duration, ports []uint, filters *models.TestFilter, enableTele bool, passThroughHosts []models.Filters, recordTimer time.Duration) {
+func (r *recorder) StartCaptureTraffic(path string, proxyPort uint32, appCmd, appContainer, appNetwork string, delay uint64, buildDelay time.Duration, ports []uint, filters *models.TestFilter, enableTele bool, passThroughHosts []models.Filters, recordTimer time.Duration, introspectionOnly bool, mockFormat, mockLayout string, proxyOptions proxy.Option) {
 	teleFS := fs.NewTeleFS(r.Logger)
 	tele := telemetry.NewTelemetry(enableTele, false, teleFS, r.Logger, "", nil)
 	tele.Ping(false)
-	dirName, err := yaml.NewSessionIndex(path, r.Logger)
-	if err != nil {
-		r.Logger.Error("Failed to create the session index file", zap.Error(err))
-		return
+	// the schema introspection is recorded as a fixture of its own, which is
+	// not a test-set and is recorded anew on every run
+	dirName := models.IntrospectionTestSet
+	if introspectionOnly {
+		err := os.RemoveAll(filepath.Join(path, dirName))
+		if err != nil {
+			r.Logger.Error("failed to remove the previously recorded introspection fixture", zap.Error(err))
+			return
+		}
+	} else {
+		var err error
+		dirName, err = yaml.NewSessionIndex(path, r.Logger)
+		if err != nil {
+			r.Logger.Error("Failed to create the session index file", zap.Error(err))
+			return
+		}
 	}
 	tcDB := yaml.NewYamlStore(path+"/"+dirName+"/tests", path+"/"+dirName, "", "", r.Logger, tele)
-	r.CaptureTraffic(path, proxyPort, appCmd, appContainer, appNetwork, dirName, delay, buildDelay, ports, filters, tcDB, tele, passThroughHosts, recordTimer)
+	if mockFormat != "" {
+		err := tcDB.(*yaml.Yaml).SetMockFormat(mockFormat)
+		if err != nil {
+			r.Logger.Error("failed to set the format of the mocks", zap.Error(err))
+			return
+		}
+	}
+	if mockLayout != "" {
+		err := tcDB.(*yaml.Yaml).SetMockLayout(mockLayout)
+		if err != nil {
+			r.Logger.Error("failed to set the layout of the mocks", zap.Error(err))
+			return
+		}
+	}
+	if introspectionOnly {
+		// the fixture holds the mocks only, not the test cases of the
+		// traffic driving it
+		tcDB = mocksOnlyDB{tcDB}
+	}
+	// the postgres exchanges are compared with the mocks of a previous
+	// recording to report the drift of the database since then
+	var drifts int64
+	if proxyOptions.PostgresDriftTestSet != "" {
+		mocks, err := r.readDriftMocks(path, proxyOptions.PostgresDriftTestSet)
+		if err != nil {
+			r.Logger.Error("failed to read the postgres mocks to detect the drift", zap.Error(err), zap.String("test-set", proxyOptions.PostgresDriftTestSet))
+			return
+		}
+		proxyOptions.PostgresDriftMocks = mocks
+		proxyOptions.PostgresDriftReporter = func(postgresparser.Drift) {
+			atomic.AddInt64(&drifts, 1)
+		}
+	}
+	r.CaptureTraffic(path, proxyPort, appCmd, appContainer, appNetwork, dirName, delay, buildDelay, ports, filters, tcDB, tele, passThroughHosts, recordTimer, introspectionOnly, proxyOptions)
+	if proxyOptions.PostgresDriftTestSet != "" {
+		r.Logger.Info("compared the postgres exchanges with the recorded test-set", zap.String("test-set", proxyOptions.PostgresDriftTestSet), zap.Int64("drifted exchanges", atomic.LoadInt64(&drifts)))
+	}
+}
+
+// readDriftMocks reads the postgres mocks of the recorded test-set. They are
+// only read by the drift detection, never consumed as in test mode.
+func (r *recorder) readDriftMocks(path, testSet string) ([]*models.Mock, error) {
+	ys := yaml.NewYamlStore(filepath.Join(path, testSet, "tests"), path, "", "", r.Logger, nil)
+	configMocks, err := ys.ReadConfigMocks(testSet)
+	if err != nil {
+		return nil, err
+	}
+	mocks := []*models.Mock{}
+	for _, configMock := range configMocks {
+		if mock, ok := configMock.(*models.Mock); ok && mock.Kind == models.Postgres {
+			mocks = append(mocks, mock)
+		}
+	}
+	return mocks, nil
+}
+
+// mocksOnlyDB records the mocks of the store and drops its test cases.
+type mocksOnlyDB struct {
+	platform.TestCaseDB
+}
+
+func (mocksOnlyDB) WriteTestcase(tc platform.KindSpecifier, ctx context.Context, filters platform.KindSpecifier) error {
+	return nil
 }
 
-func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appContainer, appNetwork string, dirName string, Delay uint64, buildDelay time.Duration, ports []uint, filters *models.TestFilter, ys platform.TestCaseDB, tele *telemetry.Telemetry, passThroughHosts []models.Filters, recordTimer time.Duration) {
+func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appContainer, appNetwork string, dirName string, Delay uint64, buildDelay time.Duration, ports []uint, filters *models.TestFilter, ys platform.TestCaseDB, tele *telemetry.Telemetry, passThroughHosts []models.Filters, recordTimer time.Duration, introspectionOnly bool, proxyOptions proxy.Option) {
 
 	var ps *proxy.ProxySet
 	stopper := make(chan os.Signal, 1)
@@ -85,7 +162,9 @@ func (r *recorder) CaptureTraffic(path string, proxyPort uint32, appCmd, appCont
 		return
 	default:
 		// start the BootProxy
-		ps = proxy.BootProxy(r.Logger, proxy.Option{Port: proxyPort}, appCmd, appContainer, 0, "", ports, loadedHooks, ctx, 0)
+		proxyOptions.Port = proxyPort
+		proxyOptions.PostgresIntrospectionOnly = introspectionOnly
+		ps = proxy.BootProxy(r.Logger, proxyOptions, appCmd, appContainer, 0, "", ports, loadedHooks, ctx, 0)
 	}
 
 	//proxy fetches the destIp and destPort from the redirect proxy map
@@ -0,0 +1,39 @@
+package record
+
+import (
+	"context"
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+	"go.keploy.io/server/pkg/platform"
+)
+
+// recordingDB counts the test cases and the mocks written to it.
+type recordingDB struct {
+	platform.TestCaseDB
+	testCases, mocks int
+}
+
+func (db *recordingDB) WriteTestcase(tc platform.KindSpecifier, ctx context.Context, filters platform.KindSpecifier) error {
+	db.testCases++
+	return nil
+}
+
+func (db *recordingDB) WriteMock(mock platform.KindSpecifier, ctx context.Context) error {
+	db.mocks++
+	return nil
+}
+
+func TestMocksOnlyDBDropsTheTestCases(t *testing.T) {
+	db := &recordingDB{}
+	var fixture platform.TestCaseDB = mocksOnlyDB{db}
+	if err := fixture.WriteTestcase(&models.TestCase{Name: "test-1"}, context.Background(), &models.TestFilter{}); err != nil {
+		t.Fatalf("failed to drop the test case: %v", err)
+	}
+	if err := fixture.WriteMock(&models.Mock{Kind: models.Postgres}, context.Background()); err != nil {
+		t.Fatalf("failed to write the mock: %v", err)
+	}
+	if db.testCases != 0 || db.mocks != 1 {
+		t.Errorf("wrote %d test cases and %d mocks, want the mock only", db.testCases, db.mocks)
+	}
+}
@@ -56,12 +56,31 @@ type TestOptions struct {
 	RemoveUnusedMocks  bool
 	PassthroughHosts   []models.Filters
 	GenerateTestReport bool
+	// DivergenceThreshold is the rate of the dependency calls matching none
+	// of the mocks above which a test run fails. Zero disables the check.
+	DivergenceThreshold float64
+	// RecordUnmatched records the postgres connections for which no session
+	// was recorded into the test-set, while the others are replayed.
+	RecordUnmatched bool
+	// PostgresReadTimeout is how long the requests of the postgres clients
+	// are read before they are matched with the mocks, 10ms when zero.
+	PostgresReadTimeout time.Duration
+	// ReportFormat is the format of the test reports. The JUnit XML reports
+	// are written next to the native ones.
+	ReportFormat models.TestReportFormat
+	// ProxyOptions configure the integrations of the proxy. Its port and the
+	// options above are set apart.
+	ProxyOptions proxy.Option
 }
 
 var (
 	totalTests      int
 	totalTestPassed int
 	totalTestFailed int
+	// the dependency calls of the test run, and those which matched none of
+	// the mocks
+	totalDependencyCalls int
+	totalDivergences     int
 )
 
 var completeTestReport = make(map[string]TestReportVerdict)
@@ -154,7 +173,12 @@ func (t *tester) InitialiseTest(cfg *TestConfig) (TestEnvironmentSetup, error) {
 		return returnVal, errors.New("Keploy was interupted by stopper")
 	default:
 		// start the proxy
-		returnVal.ProxySet = proxy.BootProxy(t.logger, proxy.Option{Port: cfg.Proxyport, MongoPassword: cfg.MongoPassword}, cfg.AppCmd, cfg.AppContainer, 0, "", cfg.PassThroughPorts, returnVal.LoadedHooks, context.Background(), cfg.Delay)
+		proxyOptions := cfg.ProxyOptions
+		proxyOptions.Port = cfg.Proxyport
+		proxyOptions.MongoPassword = cfg.MongoPassword
+		proxyOptions.PostgresRecordUnmatched = cfg.RecordUnmatched
+		proxyOptions.PostgresReadTimeout = cfg.PostgresReadTimeout
+		returnVal.ProxySet = proxy.BootProxy(t.logger, proxyOptions, cfg.AppCmd, cfg.AppContainer, 0, "", cfg.PassThroughPorts, returnVal.LoadedHooks, context.Background(), cfg.Delay)
 	}
 
 	// proxy update its state in the ProxyPorts map
@@ -201,6 +225,8 @@ func (t *tester) InitialiseTest(cfg *TestConfig) (TestEnvironmentSetup, error) {
 	returnVal.IgnoreOrdering = cfg.IgnoreOrdering
 	returnVal.RemoveUnusedMocks = cfg.RemoveUnusedMocks
 	returnVal.GenerateTestReport = cfg.GenerateTestReport
+	returnVal.DivergenceThreshold = cfg.DivergenceThreshold
+	returnVal.ReportFormat = cfg.ReportFormat
 	return returnVal, nil
 }
 
@@ -210,26 +236,31 @@ func (t *tester) Test(path string, testReportPath string, appCmd string, options
 	result := true
 	exitLoop := false
 	cfg := &TestConfig{
-		Path:               path,
-		Proxyport:          options.ProxyPort,
-		TestReportPath:     testReportPath,
-		GenerateTestReport: options.GenerateTestReport,
-		AppCmd:             appCmd,
-		AppContainer:       options.AppContainer,
-		AppNetwork:         options.AppContainer,
-		Delay:              options.Delay,
-		BuildDelay:         options.BuildDelay,
-		PassThroughPorts:   options.PassThroughPorts,
-		ApiTimeout:         options.ApiTimeout,
-		MongoPassword:      options.MongoPassword,
-		WithCoverage:       options.WithCoverage,
-		CoverageReportPath: options.CoverageReportPath,
-		Tele:               tele,
-		TestReport:         testReportStorage,
-		Storage:            tcsStorage,
-		PassThroughHosts:   options.PassthroughHosts,
-		IgnoreOrdering:     options.IgnoreOrdering,
-		RemoveUnusedMocks:  options.RemoveUnusedMocks,
+		Path:                path,
+		Proxyport:           options.ProxyPort,
+		TestReportPath:      testReportPath,
+		GenerateTestReport:  options.GenerateTestReport,
+		AppCmd:              appCmd,
+		AppContainer:        options.AppContainer,
+		AppNetwork:          options.AppContainer,
+		Delay:               options.Delay,
+		BuildDelay:          options.BuildDelay,
+		PassThroughPorts:    options.PassThroughPorts,
+		ApiTimeout:          options.ApiTimeout,
+		MongoPassword:       options.MongoPassword,
+		WithCoverage:        options.WithCoverage,
+		CoverageReportPath:  options.CoverageReportPath,
+		Tele:                tele,
+		TestReport:          testReportStorage,
+		Storage:             tcsStorage,
+		PassThroughHosts:    options.PassthroughHosts,
+		IgnoreOrdering:      options.IgnoreOrdering,
+		RemoveUnusedMocks:   options.RemoveUnusedMocks,
+		DivergenceThreshold: options.DivergenceThreshold,
+		RecordUnmatched:     options.RecordUnmatched,
+		PostgresReadTimeout: options.PostgresReadTimeout,
+		ReportFormat:        options.ReportFormat,
+		ProxyOptions:        options.ProxyOptions,
 	}
 	sessions, err := cfg.Storage.ReadTestSessionIndices()
 	if err != nil {
@@ -276,6 +307,12 @@ func (t *tester) Test(path string, testReportPath string, appCmd string, options
 		}
 	}
 
+	// the divergences are accumulated over the test sets
+	if options.DivergenceThreshold > 0 && exceedsDivergenceThreshold(totalDivergences, totalDependencyCalls, options.DivergenceThreshold) {
+		t.logger.Error("the replay diverged from the recorded dependency calls beyond the threshold", zap.Int("divergences", totalDivergences), zap.Int("dependency calls", totalDependencyCalls), zap.Float64("threshold", options.DivergenceThreshold))
+		result = false
+	}
+
 	// Sorting completeTestReport map according to testSuiteName (Keys)
 	testSuiteNames := make([]string, 0, len(completeTestReport))
 
@@ -421,6 +458,10 @@ func (t *tester) InitialiseRunTestSet(cfg *RunTestSetConfig) InitialiseRunTestSe
 	})
 	t.logger.Debug(fmt.Sprintf("the oss tcs mocks for %s are: %v\n", cfg.TestSet, readTcsMocks))
 	cfg.LoadedHooks.SetTcsMocks(readTcsMocks)
+	cfg.LoadedHooks.SetCurrentTestSet(cfg.TestSet)
+	// the mocks consumed and the statements prepared while replaying the
+	// former test-set are not carried over
+	proxy.ResetState(cfg.LoadedHooks)
 	returnVal.ErrChan = make(chan error, 1)
 	t.logger.Debug("", zap.Any("app pid", cfg.Pid))
 
@@ -512,6 +553,9 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 			t.logger.Debug("", zap.Any("replaced URL in case of docker env", cfg.Tc.HttpReq.URL))
 		}
 		t.logger.Debug(fmt.Sprintf("the url of the testcase: %v", cfg.Tc.HttpReq.URL))
+		roundTripsBefore := cfg.LoadedHooks.GetRoundTrips()
+		divergencesBefore := cfg.LoadedHooks.GetDivergences()
+		fuzzyMatchesBefore := len(cfg.LoadedHooks.GetFuzzyMatches())
 		resp, err := pkg.SimulateHttp(*cfg.Tc, cfg.TestSet, t.logger, cfg.ApiTimeout)
 		t.logger.Debug("After simulating the request", zap.Any("test case id", cfg.Tc.Name))
 		t.logger.Debug("After GetResp of the request", zap.Any("test case id", cfg.Tc.Name))
@@ -521,6 +565,9 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 			return
 		}
 		testPass, testResult := t.testHttp(*cfg.Tc, resp, cfg.NoiseConfig, cfg.IgnoreOrdering)
+		dependencyCalls, dependencyCallsByKind := countRoundTrips(roundTripsBefore, cfg.LoadedHooks.GetRoundTrips())
+		divergences := cfg.LoadedHooks.GetDivergences() - divergencesBefore
+		fuzzyMatches := cfg.LoadedHooks.GetFuzzyMatches()[fuzzyMatchesBefore:]
 
 		if !testPass {
 			t.logger.Info("", zap.Any("matched mocks", GetMatchedMocks(cfg.LoadedHooks.GetConsumedMocks())))
@@ -539,15 +586,18 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 			*cfg.Status = models.TestRunStatusFailed
 		}
 
+		completed := time.Now().UTC()
 		cfg.TestReportFS.SetResult(cfg.TestReport.Name, &models.TestResult{
-			Kind:         models.HTTP,
-			Name:         cfg.TestReport.Name,
-			Status:       testStatus,
-			Started:      started.Unix(),
-			Completed:    time.Now().UTC().Unix(),
-			TestCasePath: cfg.Path + "/" + cfg.TestSet,
-			MockPath:     cfg.Path + "/" + cfg.TestSet + "/mocks.yaml",
-			TestCaseID:   cfg.Tc.Name,
+			Kind:            models.HTTP,
+			Name:            cfg.TestReport.Name,
+			Status:          testStatus,
+			Started:         started.Unix(),
+			Completed:       completed.Unix(),
+			StartedMillis:   started.UnixMilli(),
+			CompletedMillis: completed.UnixMilli(),
+			TestCasePath:    cfg.Path + "/" + cfg.TestSet,
+			MockPath:        cfg.Path + "/" + cfg.TestSet + "/mocks.yaml",
+			TestCaseID:      cfg.Tc.Name,
 			Req: models.HttpReq{
 				Method:     cfg.Tc.HttpReq.Method,
 				ProtoMajor: cfg.Tc.HttpReq.ProtoMajor,
@@ -572,6 +622,11 @@ func (t *tester) SimulateRequest(cfg *SimulateRequestConfig) {
 			},
 			Noise:  cfg.Tc.Noise,
 			Result: *testResult,
+
+			DependencyCalls:       dependencyCalls,
+			DependencyCallsByKind: dependencyCallsByKind,
+			Divergences:           divergences,
+			FuzzyMatches:          fuzzyMatches,
 		})
 
 	}
@@ -598,6 +653,18 @@ func (t *tester) FetchTestResults(cfg *FetchTestResultsConfig) models.TestRunSta
 	cfg.TestReport.Tests = readTestResults
 	cfg.TestReport.Success = *cfg.Success
 	cfg.TestReport.Failure = *cfg.Failure
+	dependencyCalls := 0
+	for _, result := range readTestResults {
+		dependencyCalls += result.DependencyCalls + result.Divergences
+		cfg.TestReport.Divergences += result.Divergences
+	}
+	if dependencyCalls > 0 {
+		cfg.TestReport.DivergenceRate = float64(cfg.TestReport.Divergences) / float64(dependencyCalls)
+	}
+	if cfg.DivergenceThreshold > 0 && *cfg.Status == models.TestRunStatusPassed && exceedsDivergenceThreshold(cfg.TestReport.Divergences, dependencyCalls, cfg.DivergenceThreshold) {
+		*cfg.Status = models.TestRunStatusFailed
+		cfg.TestReport.Status = string(*cfg.Status)
+	}
 
 	resultForTele, ok := cfg.Ctx.Value("resultForTele").(*[]int)
 	if !ok {
@@ -608,6 +675,9 @@ func (t *tester) FetchTestResults(cfg *FetchTestResultsConfig) models.TestRunSta
 
 	if cfg.GenerateTestReport {
 		err = cfg.TestReportFS.Write(context.Background(), cfg.TestReportPath, cfg.TestReport)
+		if err == nil && cfg.ReportFormat == models.TestReportFormatJUnit {
+			err = yaml.WriteJUnitReport(cfg.TestReportPath, cfg.TestReport)
+		}
 		t.logger.Info("test report for "+cfg.TestSet+": ", zap.Any("name: ", cfg.TestReport.Name), zap.Any("path: ", cfg.Path+"/"+cfg.TestReport.Name))
 	}
 
@@ -620,6 +690,8 @@ func (t *tester) FetchTestResults(cfg *FetchTestResultsConfig) models.TestRunSta
 	totalTests += cfg.TestReport.Total
 	totalTestPassed += cfg.TestReport.Success
 	totalTestFailed += cfg.TestReport.Failure
+	totalDependencyCalls += dependencyCalls
+	totalDivergences += cfg.TestReport.Divergences
 
 	verdict := TestReportVerdict{total: cfg.TestReport.Total, failed: cfg.TestReport.Failure, passed: cfg.TestReport.Success}
 
@@ -794,16 +866,18 @@ func (t *tester) RunTestSet(testSet, path, testReportPath string, appCmd, appCon
 		}
 	}
 	resultsCfg := &FetchTestResultsConfig{
-		TestReportFS:       initialisedValues.TestReportFS,
-		TestReport:         initialisedTestSets.TestReport,
-		Status:             &status,
-		TestSet:            testSet,
-		Success:            &success,
-		Failure:            &failure,
-		Ctx:                initialisedValues.Ctx,
-		TestReportPath:     testReportPath,
-		GenerateTestReport: initialisedValues.GenerateTestReport,
-		Path:               path,
+		TestReportFS:        initialisedValues.TestReportFS,
+		TestReport:          initialisedTestSets.TestReport,
+		Status:              &status,
+		TestSet:             testSet,
+		Success:             &success,
+		Failure:             &failure,
+		Ctx:                 initialisedValues.Ctx,
+		TestReportPath:      testReportPath,
+		GenerateTestReport:  initialisedValues.GenerateTestReport,
+		Path:                path,
+		DivergenceThreshold: initialisedValues.DivergenceThreshold,
+		ReportFormat:        initialisedValues.ReportFormat,
 	}
 	status = t.FetchTestResults(resultsCfg)
 	return status
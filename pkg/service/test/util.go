@@ -57,29 +57,36 @@ type TestEnvironmentSetup struct {
 	IgnoreOrdering           bool
 	RemoveUnusedMocks        bool
 	GenerateTestReport       bool
+	DivergenceThreshold      float64
+	ReportFormat             models.TestReportFormat
 }
 
 type TestConfig struct {
-	Path               string
-	Proxyport          uint32
-	TestReportPath     string
-	GenerateTestReport bool
-	AppCmd             string
-	MongoPassword      string
-	AppContainer       string
-	AppNetwork         string
-	Delay              uint64
-	BuildDelay         time.Duration
-	PassThroughPorts   []uint
-	ApiTimeout         uint64
-	WithCoverage       bool
-	CoverageReportPath string
-	TestReport         platform.TestReportDB
-	Storage            platform.TestCaseDB
-	Tele               *telemetry.Telemetry
-	PassThroughHosts   []models.Filters
-	IgnoreOrdering     bool
-	RemoveUnusedMocks  bool
+	Path                string
+	Proxyport           uint32
+	TestReportPath      string
+	GenerateTestReport  bool
+	AppCmd              string
+	MongoPassword       string
+	AppContainer        string
+	AppNetwork          string
+	Delay               uint64
+	BuildDelay          time.Duration
+	PassThroughPorts    []uint
+	ApiTimeout          uint64
+	WithCoverage        bool
+	CoverageReportPath  string
+	TestReport          platform.TestReportDB
+	Storage             platform.TestCaseDB
+	Tele                *telemetry.Telemetry
+	PassThroughHosts    []models.Filters
+	IgnoreOrdering      bool
+	RemoveUnusedMocks   bool
+	DivergenceThreshold float64
+	RecordUnmatched     bool
+	PostgresReadTimeout time.Duration
+	ReportFormat        models.TestReportFormat
+	ProxyOptions        proxy.Option
 }
 
 type RunTestSetConfig struct {
@@ -121,16 +128,18 @@ type SimulateRequestConfig struct {
 }
 
 type FetchTestResultsConfig struct {
-	TestReportFS       platform.TestReportDB
-	TestReport         *models.TestReport
-	Status             *models.TestRunStatus
-	TestSet            string
-	Success            *int
-	Failure            *int
-	Ctx                context.Context
-	TestReportPath     string
-	GenerateTestReport bool
-	Path               string
+	TestReportFS        platform.TestReportDB
+	TestReport          *models.TestReport
+	Status              *models.TestRunStatus
+	TestSet             string
+	Success             *int
+	Failure             *int
+	Ctx                 context.Context
+	TestReportPath      string
+	GenerateTestReport  bool
+	Path                string
+	DivergenceThreshold float64
+	ReportFormat        models.TestReportFormat
 }
 
 type TestReportVerdict struct {
@@ -498,3 +507,26 @@ func makeDirectory(path string) error {
 	syscall.Umask(oldUmask)
 	return nil
 }
+
+// exceedsDivergenceThreshold reports whether the rate of the dependency calls
+// which matched none of the mocks is above the threshold.
+func exceedsDivergenceThreshold(divergences, dependencyCalls int, threshold float64) bool {
+	if dependencyCalls == 0 {
+		return false
+	}
+	return float64(divergences)/float64(dependencyCalls) > threshold
+}
+
+// countRoundTrips returns the dependency calls matched between the two counts
+// of the hooks, in total and per kind of mock.
+func countRoundTrips(before, after map[models.Kind]int) (int, map[models.Kind]int) {
+	total := 0
+	byKind := map[models.Kind]int{}
+	for kind, count := range after {
+		if calls := count - before[kind]; calls > 0 {
+			byKind[kind] = calls
+			total += calls
+		}
+	}
+	return total, byKind
+}
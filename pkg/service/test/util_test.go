@@ -0,0 +1,92 @@
+package test
+
+import (
+	"testing"
+
+	"go.keploy.io/server/pkg/models"
+)
+
+func TestExceedsDivergenceThreshold(t *testing.T) {
+	tests := []struct {
+		name            string
+		divergences     int
+		dependencyCalls int
+		want            bool
+	}{
+		{"no dependency call", 0, 0, false},
+		{"no divergence", 0, 20, false},
+		{"below the threshold", 1, 20, false},
+		{"exactly at the threshold", 2, 20, false},
+		{"past the threshold", 3, 20, true},
+		{"every call diverged", 20, 20, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exceedsDivergenceThreshold(tt.divergences, tt.dependencyCalls, 0.1); got != tt.want {
+				t.Fatalf("%d divergences over %d calls exceed the threshold 0.1: %v, want %v", tt.divergences, tt.dependencyCalls, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCountRoundTrips(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after map[models.Kind]int
+		want          int
+		wantByKind    map[models.Kind]int
+	}{
+		{"no round trip", map[models.Kind]int{}, map[models.Kind]int{}, 0, map[models.Kind]int{}},
+		{"first round trips", map[models.Kind]int{}, map[models.Kind]int{models.Postgres: 2}, 2, map[models.Kind]int{models.Postgres: 2}},
+		{"unchanged kind left out", map[models.Kind]int{models.HTTP: 1}, map[models.Kind]int{models.HTTP: 1, models.Postgres: 3}, 3, map[models.Kind]int{models.Postgres: 3}},
+		{"several kinds", map[models.Kind]int{models.HTTP: 1, models.Postgres: 2}, map[models.Kind]int{models.HTTP: 4, models.Postgres: 3}, 4, map[models.Kind]int{models.HTTP: 3, models.Postgres: 1}},
+		{"counts reset meanwhile", map[models.Kind]int{models.Postgres: 5}, map[models.Kind]int{}, 0, map[models.Kind]int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, byKind := countRoundTrips(tt.before, tt.after)
+			if got != tt.want || len(byKind) != len(tt.wantByKind) {
+				t.Fatalf("counted %d dependency calls %v, want %d %v", got, byKind, tt.want, tt.wantByKind)
+			}
+			for kind, calls := range tt.wantByKind {
+				if byKind[kind] != calls {
+					t.Fatalf("counted %d %s dependency calls, want %d", byKind[kind], kind, calls)
+				}
+			}
+		})
+	}
+}
+
+func TestDivergencesAccumulatedOverTheTestCases(t *testing.T) {
+	// the round trips counted by the hooks after each of three test cases,
+	// which diverged once, never and twice
+	roundTrips := []map[models.Kind]int{
+		{},
+		{models.Postgres: 4},
+		{models.Postgres: 7, models.HTTP: 2},
+		{models.Postgres: 12, models.HTTP: 3},
+	}
+	divergences := []int{1, 0, 2}
+
+	totalCalls, totalDivergences := 0, 0
+	var exceeded []bool
+	for i := range divergences {
+		calls, byKind := countRoundTrips(roundTrips[i], roundTrips[i+1])
+		if i == 1 && (calls != 5 || byKind[models.Postgres] != 3 || byKind[models.HTTP] != 2) {
+			t.Fatalf("counted %d dependency calls %v for the second test case, want 3 postgres and 2 http", calls, byKind)
+		}
+		totalCalls += calls
+		totalDivergences += divergences[i]
+		exceeded = append(exceeded, exceedsDivergenceThreshold(totalDivergences, totalCalls, 0.2))
+	}
+	if totalCalls != 15 {
+		t.Fatalf("counted %d dependency calls, want 15", totalCalls)
+	}
+	// 1 of 4 calls, then 1 of 9, then 3 of 15: exactly the threshold
+	if !exceeded[0] || exceeded[1] || exceeded[2] {
+		t.Fatalf("the divergences exceeded the threshold 0.2 after the test cases %v, want the first one only", exceeded)
+	}
+	if !exceedsDivergenceThreshold(totalDivergences+1, totalCalls, 0.2) {
+		t.Fatalf("another divergence doesn't cross the threshold")
+	}
+}